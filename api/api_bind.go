@@ -465,7 +465,8 @@ func handleGetConfigs(c echo.Context) error {
 	for k, v := range myDice.ConfigManager.Plugins {
 		configs := make([]*dice.ConfigItem, 0, len(v.OrderedConfigKeys))
 		for _, key := range v.OrderedConfigKeys {
-			configs = append(configs, v.Configs[key])
+			// secret 配置项只返回占位值，原样提交回来时保持不变
+			configs = append(configs, v.Configs[key].Masked())
 		}
 		resp[k] = &apiPluginConfig{
 			PluginName: v.PluginName,
@@ -646,6 +647,7 @@ func Bind(e *echo.Echo, _myDice *dice.DiceManager) {
 	e.POST(prefix+"/dice/config/set", DiceConfigSet)
 	e.GET(prefix+"/dice/config/advanced/get", DiceAdvancedConfigGet)
 	e.POST(prefix+"/dice/config/advanced/set", DiceAdvancedConfigSet)
+	e.GET(prefix+"/dice/config/database", DiceDatabaseConfigGet)
 	e.POST(prefix+"/dice/config/mail_test", DiceMailTest)
 	e.GET(prefix+"/dice/exec/split_options", DiceExecSplitOptions)
 	e.POST(prefix+"/dice/exec", DiceExec)
@@ -697,6 +699,7 @@ func Bind(e *echo.Echo, _myDice *dice.DiceManager) {
 	e.POST(prefix+"/force_stop", forceStop)
 
 	e.POST(prefix+"/js/reload", jsReload)
+	e.POST(prefix+"/js/reload_script", jsReloadScript)
 	e.POST(prefix+"/js/execute", jsExec)
 	e.POST(prefix+"/js/upload", jsUpload)
 	e.GET(prefix+"/js/list", jsList)
@@ -729,9 +732,30 @@ func Bind(e *echo.Echo, _myDice *dice.DiceManager) {
 	e.GET(prefix+"/story/items/page", storyGetItemPage)
 	e.DELETE(prefix+"/story/log", storyDelLog)
 	e.POST(prefix+"/story/uploadLog", storyUploadLog)
+	e.POST(prefix+"/story/export", storyExportLog)
+	e.GET(prefix+"/story/report", storyGetReport)
+	e.POST(prefix+"/story/rename", storyRenameLog)
+	e.POST(prefix+"/story/merge", storyMergeLog)
+	e.POST(prefix+"/story/split", storySplitLog)
 	e.GET(prefix+"/story/backup/list", storyGetLogBackupList)
 	e.GET(prefix+"/story/backup/download", storyDownloadLogBackup)
 	e.POST(prefix+"/story/backup/batch_delete", storyBatchDeleteLogBackup)
+	e.GET(prefix+"/story/retention", storyGetRetention)
+	e.POST(prefix+"/story/retention", storySetRetention)
+	e.POST(prefix+"/story/retention/run", storyRunRetention)
+	e.GET(prefix+"/story/media", storyGetMedia)
+	e.POST(prefix+"/story/media", storySetMedia)
+	e.POST(prefix+"/story/media/gc", storyRunMediaGC)
+	e.GET(prefix+"/story/archive/list", storyGetArchiveList)
+	e.POST(prefix+"/story/archive/restore", storyRestoreArchive)
+	e.GET(prefix+"/story/search", storySearch)
+	e.POST(prefix+"/story/search/rebuild", storyRebuildSearch)
+
+	e.GET(prefix+"/attrs/history", attrsGetHistory)
+	e.POST(prefix+"/attrs/history/restore", attrsRestoreHistory)
+	e.GET(prefix+"/attrs/sheet/formats", attrsSheetFormats)
+	e.GET(prefix+"/attrs/sheet/export", attrsSheetExport)
+	e.POST(prefix+"/attrs/sheet/import", attrsSheetImport)
 
 	e.POST(prefix+"/tool/onebot", onebotTool)
 	e.GET(prefix+"/utils/ga/:uid", getGithubAvatar)
@@ -776,6 +800,11 @@ func Bind(e *echo.Echo, _myDice *dice.DiceManager) {
 	// 扩展包管理
 	e.GET(prefix+"/package/list", packageList)
 	e.POST(prefix+"/package/refresh", packageRefresh)
+	e.GET(prefix+"/package/signature-config", packageGetSignatureConfig)
+	e.POST(prefix+"/package/signature-config", packageSetSignatureConfig)
+	e.GET(prefix+"/package/updates", packageUpdates)
+	e.POST(prefix+"/package/upgrade", packageUpgrade)
+	e.POST(prefix+"/package/rollback", packageRollback)
 	e.GET(prefix+"/package/:id", packageGet)
 	e.POST(prefix+"/package/preview-upload", packagePreviewFromUpload)
 	e.POST(prefix+"/package/upload-preview", packagePreviewFromUpload)
@@ -792,4 +821,8 @@ func Bind(e *echo.Echo, _myDice *dice.DiceManager) {
 	e.GET(prefix+"/package/:id/config", packageGetConfig)
 	e.POST(prefix+"/package/:id/config", packageSetConfig)
 	e.GET(prefix+"/package/:id/config-schema", packageGetConfigSchema)
+
+	if dm.StoryBackend != nil {
+		bindStoryBackend(e, dm.StoryBackend)
+	}
 }
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"sealdice-core/dice"
+)
+
+// attrsGetHistory 人物卡的修改记录，从新到旧
+func attrsGetHistory(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	id := c.QueryParam("id")
+	if id == "" {
+		return Error(&c, "缺少人物卡ID", Response{})
+	}
+	list, err := myDice.AttrsManager.History(id, dice.AttrsHistoryLimit)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{
+		"data": list,
+	})
+}
+
+// attrsRestoreHistory 把人物卡恢复到某条记录之前的状态，恢复操作同样会留下记录
+func attrsRestoreHistory(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	v := struct {
+		ID        string `json:"id"`
+		HistoryID uint64 `json:"historyId"`
+	}{}
+	if err := c.Bind(&v); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	if v.ID == "" || v.HistoryID == 0 {
+		return Error(&c, "缺少人物卡ID或记录ID", Response{})
+	}
+	h, err := myDice.AttrsManager.RestoreHistory(v.ID, v.HistoryID, "", "后台")
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{
+		"data": h,
+	})
+}
+
+// attrsSheetFormats 可用的人物卡导入导出格式
+func attrsSheetFormats(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	var items []Response
+	for _, conv := range dice.SheetConverters() {
+		items = append(items, Response{
+			"name":        conv.Name(),
+			"description": conv.Description(),
+			"sheetType":   conv.SheetType(),
+			"ext":         conv.Ext(),
+		})
+	}
+	return Success(&c, Response{
+		"data": items,
+	})
+}
+
+// attrsSheetExport 按指定格式下载人物卡
+func attrsSheetExport(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	id := c.QueryParam("id")
+	if id == "" {
+		return Error(&c, "缺少人物卡ID", Response{})
+	}
+	format := c.QueryParam("format")
+	if format == "" {
+		format = "json"
+	}
+	attrs, err := myDice.AttrsManager.LoadById(id)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	doc := myDice.SheetDocumentFromAttrs(attrs)
+	if doc.Name == "" {
+		doc.Name = id
+	}
+	data, ext, err := myDice.SheetExport(doc, format)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": doc.Name + ext}))
+	return c.Blob(http.StatusOK, echo.MIMEOctetStream, data)
+}
+
+// attrsSheetImport 上传人物卡文件。指定 id 时替换该卡的属性，否则为 userId 新建名为 name 的角色
+func attrsSheetImport(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	src, err := file.Open()
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	defer func(src multipart.File) {
+		_ = src.Close()
+	}(src)
+	data, err := io.ReadAll(io.LimitReader(src, dice.SheetImportMaxBytes+1))
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	if len(data) > dice.SheetImportMaxBytes {
+		return Error(&c, "人物卡文件过大", Response{})
+	}
+
+	am := myDice.AttrsManager
+	id, userID := c.FormValue("id"), c.FormValue("userId")
+	var attrs *dice.AttributesItem
+	if id != "" {
+		if attrs, err = am.LoadById(id); err != nil {
+			return Error(&c, err.Error(), Response{})
+		}
+	} else if userID == "" {
+		return Error(&c, "需要指定人物卡ID，或指定用户以新建角色", Response{})
+	}
+
+	defaultSheetType := "coc7"
+	if attrs != nil && attrs.SheetType != "" {
+		defaultSheetType = attrs.SheetType
+	}
+	doc, err := myDice.SheetImport(data, file.Filename, c.FormValue("format"), defaultSheetType)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+
+	if attrs == nil {
+		name := strings.TrimSpace(c.FormValue("name"))
+		if name == "" {
+			name = strings.TrimSpace(doc.Name)
+		}
+		if name == "" {
+			return Error(&c, "人物卡中没有角色名，请指定", Response{})
+		}
+		if am.CharCheckExists(userID, name) {
+			return Error(&c, "该用户已有同名角色", Response{})
+		}
+		item, errNew := am.CharNew(userID, name, doc.SheetType)
+		if errNew != nil {
+			return Error(&c, errNew.Error(), Response{})
+		}
+		if attrs, err = am.LoadById(item.Id); err != nil {
+			return Error(&c, err.Error(), Response{})
+		}
+	}
+	if err = myDice.SheetApplyDocument(attrs, doc); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	am.RecordHistory("", "后台", "导入人物卡 "+file.Filename, attrs)
+	attrs.SaveToDB(myDice.DBOperator)
+	return Success(&c, Response{
+		"id":        attrs.ID,
+		"sheetType": doc.SheetType,
+		"count":     attrs.Len(),
+	})
+}
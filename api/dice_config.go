@@ -283,6 +283,11 @@ func DiceConfigSet(c echo.Context) error {
 		myDice.ApplyAliveNotice()
 	}
 
+	if val, ok := jsonMap["jsScriptWatchEnable"]; ok {
+		config.JsScriptWatchEnable = val.(bool)
+		myDice.ApplyJsScriptWatch()
+	}
+
 	if val, ok := jsonMap["UILogLimit"]; ok {
 		val, err := strconv.ParseInt(val.(string), 10, 64)
 		if err == nil {
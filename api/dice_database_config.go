@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"sealdice-core/utils/dboperator"
+)
+
+// DiceDatabaseConfigGet 数据库配置与连接池状态。config 为实际生效的配置（含环境变量），
+// configured 为 dice.yaml 中填写的 database 段，二者不同时说明部分配置来自环境变量
+func DiceDatabaseConfigGet(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	if myDice.DBOperator == nil {
+		return Error(&c, "数据库尚未初始化", nil)
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"status":     dboperator.GetStatus(myDice.DBOperator),
+		"configured": myDice.Parent.DatabaseConfig.Redacted(),
+	})
+}
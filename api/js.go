@@ -40,7 +40,7 @@ func jsExec(c echo.Context) error {
 		return c.String(430, err.Error())
 	}
 
-	source := "(function(exports, require, module) {" + v.Value + "\n})()"
+	source := "(function(exports, require, module) {" + myDice.JsTrustedSource(v.Value) + "\n})()"
 	loop := myDice.ExtLoopManager.GetWebLoop()
 	waitRun := make(chan int, 1)
 
@@ -152,6 +152,50 @@ func jsReload(c echo.Context) error {
 	return c.NoContent(200)
 }
 
+// jsReloadScript 只重载指定的脚本，不重建整个 JS 环境
+func jsReloadScript(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	if dm.JustForTest {
+		return c.JSON(200, map[string]interface{}{
+			"testMode": true,
+		})
+	}
+	if !myDice.Config.JsEnable {
+		return c.JSON(200, map[string]interface{}{
+			"result": false,
+			"err":    "js扩展支持已关闭",
+		})
+	}
+
+	v := struct {
+		Filename string `json:"filename"`
+	}{}
+	if err := c.Bind(&v); err != nil || v.Filename == "" {
+		return c.JSON(http.StatusBadRequest, nil)
+	}
+	// 与整体重载互斥
+	locked := myDice.JsReloadLock.TryLock()
+	if !locked {
+		return c.NoContent(400)
+	}
+	defer myDice.JsReloadLock.Unlock()
+
+	jsInfo, err := myDice.JsReloadScript(v.Filename)
+	if err != nil {
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"result": false,
+			"err":    err.Error(),
+			"script": jsInfo,
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"result": true,
+		"script": jsInfo,
+	})
+}
+
 func jsUpload(c echo.Context) error {
 	if !doAuth(c) {
 		return c.JSON(http.StatusForbidden, nil)
@@ -245,8 +289,9 @@ func jsShutdown(c echo.Context) error {
 
 func jsStatus(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"result": true,
-		"status": myDice.Config.JsEnable,
+		"result":   true,
+		"status":   myDice.Config.JsEnable,
+		"extStats": myDice.JsExtStatsList(),
 	})
 }
 
@@ -187,6 +187,10 @@ func packageInstallFromURL(c echo.Context) error {
 //   - "full": 完全删除（默认）
 //   - "keep_data": 保留用户数据
 //   - "disable": 仅禁用，不删除文件
+//   - dependents: string - 仍被已启用的扩展包依赖时的处理方式
+//   - "": 拒绝卸载（默认）
+//   - "force": 强制卸载，依赖方保持启用
+//   - "cascade": 同时禁用所有依赖方
 //
 // 返回: { message: string, result: true }
 // 注意: 卸载后需要调用 js/reload 清理内存中的 JS 扩展
@@ -201,8 +205,9 @@ func packageUninstall(c echo.Context) error {
 	}
 
 	var params struct {
-		ID   string                 `json:"id"`
-		Mode sealpack.UninstallMode `json:"mode"` // full, keep_data, disable
+		ID         string                    `json:"id"`
+		Mode       sealpack.UninstallMode    `json:"mode"`       // full, keep_data, disable
+		Dependents sealpack.DependentsPolicy `json:"dependents"` // 为空时拒绝, force, cascade
 	}
 	err := c.Bind(&params)
 	if err != nil {
@@ -214,7 +219,7 @@ func packageUninstall(c echo.Context) error {
 		params.Mode = sealpack.UninstallModeFull
 	}
 
-	err = myDice.PackageManager.Uninstall(params.ID, params.Mode)
+	err = myDice.PackageManager.UninstallWithPolicy(params.ID, params.Mode, params.Dependents)
 	if err != nil {
 		return Error(&c, err.Error(), Response{})
 	}
@@ -263,7 +268,7 @@ func packageEnable(c echo.Context) error {
 
 // packageDisable 禁用扩展包
 // POST /package/disable
-// 参数: { id: string }
+// 参数: { id: string, dependents?: "" | "force" | "cascade" }，dependents 含义同 /package/uninstall
 // 返回: { data: DisableResult, result: true }
 // 注意: 禁用后需要重载才能从内存中移除资源
 func packageDisable(c echo.Context) error {
@@ -277,14 +282,15 @@ func packageDisable(c echo.Context) error {
 	}
 
 	var params struct {
-		ID string `json:"id"`
+		ID         string                    `json:"id"`
+		Dependents sealpack.DependentsPolicy `json:"dependents"`
 	}
 	err := c.Bind(&params)
 	if err != nil {
 		return Error(&c, err.Error(), Response{})
 	}
 
-	result, err := myDice.PackageManager.Disable(params.ID)
+	result, err := myDice.PackageManager.DisableWithPolicy(params.ID, params.Dependents)
 	if err != nil {
 		return Error(&c, err.Error(), Response{})
 	}
@@ -388,6 +394,138 @@ func packageReloadAll(c echo.Context) error {
 	})
 }
 
+// packageGetSignatureConfig 获取扩展包签名策略
+// GET /package/signature-config
+// 返回: { data: PackageSignatureConfig, result: true }
+func packageGetSignatureConfig(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, "auth")
+	}
+	return Success(&c, Response{
+		"data": myDice.PackageManager.GetSignatureConfig(),
+	})
+}
+
+// packageSetSignatureConfig 设置扩展包签名策略
+// POST /package/signature-config
+// 参数:
+//   - requireSigned: bool - 是否拒绝安装未签名的扩展包
+//   - trustedKeys: [{ name, publicKey }] - 受信任的作者/商店公钥（PEM）
+//
+// 返回: { message: string, result: true }
+func packageSetSignatureConfig(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, "auth")
+	}
+	if dm.JustForTest {
+		return Success(&c, map[string]interface{}{
+			"testMode": true,
+		})
+	}
+
+	var params struct {
+		RequireSigned bool                  `json:"requireSigned"`
+		TrustedKeys   []sealpack.TrustedKey `json:"trustedKeys"`
+	}
+	if err := c.Bind(&params); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+
+	if err := myDice.PackageManager.SetSignatureConfig(params.RequireSigned, params.TrustedKeys); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	myDice.Save(false)
+
+	return Success(&c, Response{
+		"message": "签名策略已更新",
+	})
+}
+
+// packageUpdates 获取已安装扩展包的可用更新
+// GET /package/updates
+// 参数: ?refresh=true 立即向商店重新检查，否则返回最近一次（含定时任务）检查的结果
+// 返回: { data: PackageUpdateCheckResult | null, result: true }
+func packageUpdates(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, "auth")
+	}
+
+	if c.QueryParam("refresh") != "true" {
+		return Success(&c, Response{
+			"data": myDice.PackageManager.LastUpdateCheck(),
+		})
+	}
+	result, err := myDice.PackageManager.CheckUpdates()
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{
+		"data": result,
+	})
+}
+
+// packageUpgrade 从商店升级扩展包到最新版本
+// POST /package/upgrade
+// 参数: { id: string }
+// 返回: { data: OperationResult, result: true }
+// 注意: 旧版本 .sealpack 与用户数据会被保留，可通过 /package/rollback 回滚
+func packageUpgrade(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, "auth")
+	}
+	if dm.JustForTest {
+		return Success(&c, map[string]interface{}{
+			"testMode": true,
+		})
+	}
+
+	var params struct {
+		ID string `json:"id"`
+	}
+	if err := c.Bind(&params); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+
+	result, err := myDice.PackageManager.Upgrade(params.ID)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{
+		"data": result,
+	})
+}
+
+// packageRollback 回滚扩展包到升级前的版本
+// POST /package/rollback
+// 参数: { id: string }
+// 返回: { data: OperationResult, result: true }
+// 注意: 升级前的用户数据与配置会一并恢复，升级后产生的数据将丢失
+func packageRollback(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, "auth")
+	}
+	if dm.JustForTest {
+		return Success(&c, map[string]interface{}{
+			"testMode": true,
+		})
+	}
+
+	var params struct {
+		ID string `json:"id"`
+	}
+	if err := c.Bind(&params); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+
+	result, err := myDice.PackageManager.Rollback(params.ID)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{
+		"data": result,
+	})
+}
+
 // packageGetConfig 获取扩展包的用户配置
 // GET /package/:id/config 或 GET /package/_/config?id=xxx
 // 返回: { data: map[string]interface{}, result: true }
@@ -0,0 +1,15 @@
+package api
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"sealdice-core/dice/storylog"
+)
+
+// bindStoryBackend 挂载内置日志后端。上传接口由后端自己校验 token，查看页面无需登录
+func bindStoryBackend(e *echo.Echo, backend *storylog.Backend) {
+	h := echo.WrapHandler(backend.Handler())
+	e.PUT(storylog.BackendUploadPath, h)
+	e.POST(storylog.BackendUploadPath, h)
+	e.GET(storylog.BackendViewPath+":key", h)
+}
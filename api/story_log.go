@@ -4,12 +4,15 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
 	"strings"
 
 	"github.com/labstack/echo/v4"
 
 	"sealdice-core/dice"
+	"sealdice-core/dice/docengine"
 	"sealdice-core/dice/service"
+	"sealdice-core/dice/storylog"
 	"sealdice-core/model"
 )
 
@@ -122,6 +125,7 @@ func storyDelLog(c echo.Context) error {
 		myDice.Logger.Error("storyDelLog", "failed to delete", err)
 		return c.JSON(http.StatusInternalServerError, false)
 	}
+	myDice.LogMediaCleanup()
 	return c.JSON(http.StatusOK, true)
 }
 
@@ -190,6 +194,336 @@ func storyBatchDeleteLogBackup(c echo.Context) error {
 	return Success(&c, Response{})
 }
 
+func storyGetRetention(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	return Success(&c, Response{
+		"data": myDice.Config.LogRetention,
+	})
+}
+
+func storySetRetention(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	v := dice.LogRetentionConfig{}
+	if err := c.Bind(&v); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	if err := myDice.SetLogRetention(v); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{})
+}
+
+// storyRunRetention 立即按保留策略归档一次，不要求启用定时任务
+func storyRunRetention(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	archives, err := myDice.LogRetentionRun()
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{
+		"data": archives,
+	})
+}
+
+// storyGetMedia 日志附件设置与占用空间
+func storyGetMedia(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	var files int
+	var size int64
+	if store := myDice.Parent.LogMediaStore; store != nil {
+		var err error
+		if files, size, err = store.Usage(); err != nil {
+			return Error(&c, err.Error(), Response{})
+		}
+	}
+	return Success(&c, Response{
+		"data":  myDice.Config.LogMedia,
+		"files": files,
+		"size":  size,
+	})
+}
+
+func storySetMedia(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	v := storylog.MediaConfig{}
+	if err := c.Bind(&v); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	if err := myDice.SetLogMedia(v); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{})
+}
+
+// storyRunMediaGC 立即清理不再被日志或归档引用的附件
+func storyRunMediaGC(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	removed, freed, err := myDice.Parent.LogMediaGC()
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{
+		"removed": removed,
+		"freed":   freed,
+	})
+}
+
+func storyGetArchiveList(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	list, err := storylog.ListArchives(myDice.LogArchiveDir())
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{
+		"data": list,
+	})
+}
+
+func storyRestoreArchive(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	v := struct {
+		File string `json:"file"`
+	}{}
+	if err := c.Bind(&v); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	archive, err := storylog.RestoreArchive(myDice.DBOperator, myDice.LogArchiveDir(), v.File)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{
+		"data": archive,
+	})
+}
+
+// storyExportLog 在本地渲染日志并下载，不经过日志后端
+func storyExportLog(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	v := struct {
+		GroupID string            `json:"groupId"`
+		LogName string            `json:"logName"`
+		Format  string            `json:"format"`
+		HideOOC bool              `json:"hideOoc"`
+		NameMap map[string]string `json:"nameMap"`
+	}{}
+	if err := c.Bind(&v); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	opts := storylog.ExportOptions{HideOOC: v.HideOOC, NameMap: v.NameMap, Format: storylog.ExportFormatTxt, Media: myDice.Parent.LogMediaStore}
+	if v.Format != "" {
+		format, ok := storylog.ParseExportFormat(v.Format)
+		if !ok {
+			return Error(&c, "不支持的导出格式: "+v.Format, Response{})
+		}
+		opts.Format = format
+	}
+
+	path, _, err := storylog.ExportLogFile(myDice.DBOperator, v.GroupID, v.LogName, "sealdice-log-export", opts)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	defer func() { _ = os.Remove(path) }()
+	c.Response().Header().Set(echo.HeaderContentType, opts.Format.ContentType())
+	return c.Attachment(path, storylog.ExportFilename(v.GroupID, v.LogName, opts.Format))
+}
+
+// storyGetReport 跑团报告，idleMinutes 与 bucketMinutes 可选
+func storyGetReport(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	v := struct {
+		GroupID       string `query:"groupId"`
+		LogName       string `query:"logName"`
+		IdleMinutes   int64  `query:"idleMinutes"`
+		BucketMinutes int64  `query:"bucketMinutes"`
+	}{}
+	if err := c.Bind(&v); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	report, err := storylog.BuildReport(myDice.DBOperator, v.GroupID, v.LogName, storylog.ReportOptions{
+		IdleGap: v.IdleMinutes * 60,
+		Bucket:  v.BucketMinutes * 60,
+	})
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{
+		"data": report,
+	})
+}
+
+// storyRenameLog 日志改名
+func storyRenameLog(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	v := struct {
+		GroupID string `json:"groupId"`
+		Name    string `json:"name"`
+		NewName string `json:"newName"`
+	}{}
+	if err := c.Bind(&v); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	change, err := service.LogRename(myDice.DBOperator, v.GroupID, v.Name, v.NewName)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	myDice.LogSyncGroupState(v.GroupID, v.Name, change)
+	return Success(&c, Response{
+		"data": change,
+	})
+}
+
+// storyMergeLog 把 from 的全部消息并入 to，随后删除 from
+func storyMergeLog(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	v := struct {
+		GroupID string `json:"groupId"`
+		From    string `json:"from"`
+		To      string `json:"to"`
+	}{}
+	if err := c.Bind(&v); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	change, err := service.LogMerge(myDice.DBOperator, v.GroupID, v.From, v.To)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	myDice.LogSyncGroupState(v.GroupID, v.From, change)
+	return Success(&c, Response{
+		"data": change,
+	})
+}
+
+// storySplitLog 从分割点（时间、消息 ID 或序号三选一）起的消息拆分为新日志
+func storySplitLog(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	v := struct {
+		GroupID string `json:"groupId"`
+		Name    string `json:"name"`
+		NewName string `json:"newName"`
+		service.LogSplitAt
+	}{}
+	if err := c.Bind(&v); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	change, err := service.LogSplit(myDice.DBOperator, v.GroupID, v.Name, v.NewName, v.LogSplitAt)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{
+		"data": change,
+	})
+}
+
+// storySearch 在日志中全文搜索消息，可按群、日志、发言人、时间与是否为骰子消息筛选
+func storySearch(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	v := struct {
+		Keyword   string `query:"keyword"`
+		GroupID   string `query:"groupId"`
+		LogName   string `query:"logName"`
+		UserID    string `query:"userId"`
+		TimeBegin int64  `query:"timeBegin"`
+		TimeEnd   int64  `query:"timeEnd"`
+		DiceOnly  bool   `query:"diceOnly"`
+		PageNum   int    `query:"pageNum"`
+		PageSize  int    `query:"pageSize"`
+	}{}
+	if err := c.Bind(&v); err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	if strings.TrimSpace(v.Keyword) == "" {
+		return Error(&c, "请输入搜索关键词", Response{})
+	}
+	if myDice.Parent.LogSearch == nil {
+		return Error(&c, "日志搜索不可用", Response{})
+	}
+	if v.PageNum < 1 {
+		v.PageNum = 1
+	}
+	if v.PageSize <= 0 {
+		v.PageSize = 20
+	}
+
+	q := docengine.LogSearchQuery{
+		Keyword:   v.Keyword,
+		GroupID:   v.GroupID,
+		IMUserID:  v.UserID,
+		TimeBegin: v.TimeBegin,
+		TimeEnd:   v.TimeEnd,
+		DiceOnly:  v.DiceOnly,
+		PageNum:   v.PageNum,
+		PageSize:  v.PageSize,
+	}
+	if v.LogName != "" {
+		if v.GroupID == "" {
+			return Error(&c, "按日志名搜索时需要指定群号", Response{})
+		}
+		info, err := service.LogGetInfoByName(myDice.DBOperator, v.GroupID, v.LogName)
+		if err != nil {
+			return Error(&c, err.Error(), Response{})
+		}
+		q.LogID = info.ID
+	}
+
+	hits, total, err := myDice.Parent.LogSearch.Search(myDice.DBOperator, q)
+	if err != nil {
+		return Error(&c, err.Error(), Response{})
+	}
+	return Success(&c, Response{
+		"data":       hits,
+		"total":      total,
+		"pageNum":    v.PageNum,
+		"pageSize":   len(hits),
+		"rebuilding": myDice.Parent.LogSearch.Rebuilding(),
+	})
+}
+
+// storyRebuildSearch 在后台重建日志全文索引
+func storyRebuildSearch(c echo.Context) error {
+	if !doAuth(c) {
+		return c.JSON(http.StatusForbidden, nil)
+	}
+	s := myDice.Parent.LogSearch
+	if s == nil {
+		return Error(&c, "日志搜索不可用", Response{})
+	}
+	if s.Rebuilding() {
+		return Error(&c, "日志索引正在重建中", Response{})
+	}
+	go func() {
+		_ = s.Rebuild(myDice.DBOperator)
+	}()
+	return Success(&c, Response{})
+}
+
 func logSendToBackend(groupID string, logName string) (bool, string, string, error) {
 	ctx := &dice.MsgContext{
 		Dice:     myDice,
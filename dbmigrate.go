@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/joho/godotenv"
+	"go.uber.org/zap/zapcore"
+
+	"sealdice-core/logger"
+	v2 "sealdice-core/migrate/v2"
+	"sealdice-core/utils/constant"
+	"sealdice-core/utils/dboperator"
+	"sealdice-core/utils/dboperator/dbcopy"
+)
+
+// 跨数据库迁移: sealdice migrate-db --to-type <类型> --to-dsn <连接>
+// 需要在海豹停止运行时执行，完成后修改 DB_TYPE/DB_DSN 即可使用新的数据库启动。
+
+type dbMigrateCommand struct {
+	FromType  string `choice:"sqlite" choice:"mysql" choice:"postgres" description:"源数据库类型，默认读取 DB_TYPE，未配置时为 sqlite" long:"from-type"`
+	FromDSN   string `description:"源数据库连接串，sqlite 为数据目录；默认读取 DB_DSN/DATADIR" long:"from-dsn"`
+	ToType    string `choice:"sqlite" choice:"mysql" choice:"postgres" description:"目标数据库类型" long:"to-type" required:"true"`
+	ToDSN     string `description:"目标数据库连接串，sqlite 为数据目录" long:"to-dsn" required:"true"`
+	BatchSize int    `default:"500" description:"每批复制的行数" long:"batch-size"`
+	Overwrite bool   `description:"清空目标库中已有的数据后再复制" long:"overwrite"`
+}
+
+// sourceDSN 与引擎相同的方式补全源库连接串，用于判断源与目标是否相同
+func (c *dbMigrateCommand) sourceDSN(dbType string) string {
+	if c.FromDSN != "" {
+		return c.FromDSN
+	}
+	if dbType != constant.SQLITE {
+		return os.Getenv("DB_DSN")
+	}
+	if dir := os.Getenv("DATADIR"); dir != "" {
+		return dir
+	}
+	return "./data/default"
+}
+
+func (c *dbMigrateCommand) run() error {
+	fromType := c.FromType
+	if fromType == "" {
+		fromType = os.Getenv("DB_TYPE")
+	}
+	if fromType == "" {
+		fromType = constant.SQLITE
+	}
+	fromDSN := c.sourceDSN(fromType)
+	sameSQLiteDir := fromType == constant.SQLITE && filepath.Clean(fromDSN) == filepath.Clean(c.ToDSN)
+	if fromType == c.ToType && (fromDSN == c.ToDSN || sameSQLiteDir) {
+		return errors.New("源数据库与目标数据库相同")
+	}
+
+	locked, err := sealLock.TryLock()
+	if err != nil || !locked {
+		return errors.New("海豹正在运行中，请先停止海豹再进行迁移")
+	}
+	defer func() {
+		_ = sealLock.Unlock()
+	}()
+
+	ctx := context.Background()
+	src, err := dboperator.OpenEngine(ctx, fromType, fromDSN)
+	if err != nil {
+		return fmt.Errorf("打开源数据库失败: %w", err)
+	}
+	defer src.Close()
+	// 先让源库完成全部升级，复制出的数据才与当前版本一致
+	if err = v2.InitUpgrader(src, nil); err != nil {
+		return fmt.Errorf("源数据库升级失败: %w", err)
+	}
+
+	dst, err := dboperator.OpenEngine(ctx, c.ToType, c.ToDSN)
+	if err != nil {
+		return fmt.Errorf("打开目标数据库失败: %w", err)
+	}
+	defer dst.Close()
+	logf := func(s string) {
+		fmt.Fprintln(os.Stdout, s)
+	}
+	if err = v2.PrepareCopyTarget(dst, logf); err != nil {
+		return fmt.Errorf("目标数据库建表失败: %w", err)
+	}
+
+	fmt.Fprintf(os.Stdout, "开始迁移: %s -> %s\n", fromType, c.ToType)
+	results, err := dbcopy.Copy(src, dst, dbcopy.Options{
+		BatchSize: c.BatchSize,
+		Overwrite: c.Overwrite,
+		Progress: func(table string, copied, total int64) {
+			fmt.Fprintf(os.Stdout, "\r%s: %d/%d", table, copied, total)
+			if copied >= total {
+				fmt.Fprintln(os.Stdout)
+			}
+		},
+	})
+	logs := make([]string, 0, len(results))
+	for _, res := range results {
+		status := "一致"
+		if !res.OK() {
+			status = fmt.Sprintf("不一致 (源 %d 行 %s)", res.SourceRows, res.SourceChecksum)
+		}
+		line := res.String() + " " + status
+		logs = append(logs, line)
+		fmt.Fprintln(os.Stdout, line)
+	}
+	if err != nil {
+		return err
+	}
+
+	id := fmt.Sprintf("dbcopy_%s_to_%s_%d", fromType, c.ToType, time.Now().Unix())
+	if err = v2.RecordCopy(dst, id, logs); err != nil {
+		return fmt.Errorf("写入升级记录失败: %w", err)
+	}
+	fmt.Fprintln(os.Stdout, "迁移完成，全部数据表校验一致。")
+	if c.ToType == constant.SQLITE {
+		fmt.Fprintf(os.Stdout, "请在 .env 中设置 DB_TYPE=%s DATADIR=%s 后启动海豹\n", c.ToType, c.ToDSN)
+	} else {
+		fmt.Fprintf(os.Stdout, "请在 .env 中设置 DB_TYPE=%s DB_DSN=<目标连接串> 后启动海豹\n", c.ToType)
+	}
+	return nil
+}
+
+// runDBMigrateCommand 执行 migrate-db 子命令，返回进程退出码
+func runDBMigrateCommand(args []string) int {
+	var cmd dbMigrateCommand
+	parser := flags.NewNamedParser("sealdice migrate-db", flags.Default)
+	_, _ = parser.AddGroup("迁移选项", "在 SQLite、MySQL、PostgreSQL 之间复制全部数据并校验", &cmd)
+	// flags.Default 已负责输出错误信息
+	if _, err := parser.ParseArgs(args); err != nil {
+		var flagsErr *flags.Error
+		if errors.As(err, &flagsErr) && flagsErr.Type == flags.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+
+	logger.InitLogger(zapcore.InfoLevel, logger.NewUIWriter())
+	_ = godotenv.Load()
+	if err := cmd.run(); err != nil {
+		fmt.Fprintln(os.Stderr, "迁移失败:", err)
+		return 1
+	}
+	return 0
+}
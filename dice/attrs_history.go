@@ -0,0 +1,284 @@
+package dice
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	ds "github.com/sealdice/dicescript"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/model"
+)
+
+// 人物卡修改历史：每条指令执行完后，把期间取用并改动过的卡与上次记录时的状态比较，
+// 有变化就保存一条记录，内容为修改前的完整卡数据和变化的属性，供 .st undo 与后台回滚使用。
+
+const (
+	// AttrsHistoryLimit 每张卡保留的修改记录数
+	AttrsHistoryLimit = 30
+
+	attrsHistoryCommandMax = 100
+	// attrsSheetTypeKey 卡片类型的变化在记录中使用的键名
+	attrsSheetTypeKey = "$卡片类型"
+)
+
+var ErrAttrsHistoryEmpty = errors.New("没有可撤销的修改记录")
+
+func attrsDictFromJSON(data []byte) (*ds.ValueMap, error) {
+	if len(data) == 0 {
+		return &ds.ValueMap{}, nil
+	}
+	v, err := ds.VMValueFromJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	dd, ok := v.ReadDictData()
+	if !ok {
+		return nil, errors.New("角色数据类型不正确")
+	}
+	if dd.Dict == nil {
+		dd.Dict = &ds.ValueMap{}
+	}
+	return dd.Dict, nil
+}
+
+// attrsDiff 比较两份卡数据，按属性名排序返回变化
+func attrsDiff(before, after *ds.ValueMap) []model.AttrsChange {
+	type pair struct{ old, new *ds.VMValue }
+	keys := map[string]*pair{}
+	before.Range(func(key string, value *ds.VMValue) bool {
+		keys[key] = &pair{old: value}
+		return true
+	})
+	after.Range(func(key string, value *ds.VMValue) bool {
+		if p, ok := keys[key]; ok {
+			p.new = value
+		} else {
+			keys[key] = &pair{new: value}
+		}
+		return true
+	})
+
+	var changes []model.AttrsChange
+	for key, p := range keys {
+		c := model.AttrsChange{Key: key}
+		if p.old != nil {
+			c.Old = p.old.ToString()
+		}
+		if p.new != nil {
+			c.New = p.new.ToString()
+		}
+		if p.old != nil && p.new != nil && p.old.ToRepr() == p.new.ToRepr() {
+			continue
+		}
+		changes = append(changes, c)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// attrsHistoryCommand 记录用的指令原文，过长时截断
+func attrsHistoryCommand(text string) string {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\n", " "))
+	if utf8.RuneCountInString(text) > attrsHistoryCommandMax {
+		text = string([]rune(text)[:attrsHistoryCommandMax]) + "…"
+	}
+	return text
+}
+
+// attrsHistoryScope 一条指令执行期间取用过的卡，指令结束后只为其中有改动的卡记录修改历史，
+// 这样同时执行的其他指令改动的卡不会记到当前发送者名下
+type attrsHistoryScope struct {
+	mu    sync.Mutex
+	items []*AttributesItem
+}
+
+func (s *attrsHistoryScope) add(i *AttributesItem) {
+	if s == nil || i == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.Contains(s.items, i) {
+		s.items = append(s.items, i)
+	}
+}
+
+func (s *attrsHistoryScope) list() []*AttributesItem {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.items)
+}
+
+// trackAttrsHistory 记下指令中取用的卡，指令结束后由 RecordHistory 检查
+func (ctx *MsgContext) trackAttrsHistory(i *AttributesItem) {
+	if ctx != nil {
+		ctx.attrsHistory.add(i)
+	}
+}
+
+// RecordHistory 为 items 中自上次记录以来有改动的卡各保存一条修改记录
+func (am *AttrsManager) RecordHistory(actorID, actorName, command string, items ...*AttributesItem) {
+	if am.db == nil || len(items) == 0 {
+		return
+	}
+	am.historyMu.Lock()
+	defer am.historyMu.Unlock()
+
+	command = attrsHistoryCommand(command)
+	for _, i := range items {
+		if i == nil || !i.historyDirty.CompareAndSwap(true, false) {
+			continue
+		}
+		data, err := ds.NewDictVal(i.valueMap).V().ToJSON()
+		if err != nil {
+			continue
+		}
+		before, err := attrsDictFromJSON(i.historyBase)
+		if err != nil {
+			before = &ds.ValueMap{}
+		}
+		changes := attrsDiff(before, i.valueMap)
+		if i.SheetType != i.historySheetType {
+			changes = append(changes, model.AttrsChange{Key: attrsSheetTypeKey, Old: i.historySheetType, New: i.SheetType})
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		err = service.AttrsHistoryAdd(am.db, &model.AttributesHistoryModel{
+			AttrsID:   i.ID,
+			Data:      i.historyBase,
+			SheetType: i.historySheetType,
+			ActorID:   actorID,
+			ActorName: actorName,
+			Command:   command,
+			Changes:   changes,
+		}, AttrsHistoryLimit)
+		if err != nil {
+			am.logger.Warnf("记录人物卡修改历史失败 %s: %v", i.ID, err)
+		}
+		i.historyBase, i.historySheetType = data, i.SheetType
+	}
+}
+
+// History 人物卡的修改记录，从新到旧
+func (am *AttrsManager) History(id string, limit int) ([]*model.AttributesHistoryModel, error) {
+	return service.AttrsHistoryList(am.db, id, limit)
+}
+
+// restore 把卡恢复为记录中修改前的状态并立即保存，恢复本身不产生新的修改记录
+func (am *AttrsManager) restore(i *AttributesItem, h *model.AttributesHistoryModel) error {
+	dict, err := attrsDictFromJSON(h.Data)
+	if err != nil {
+		return err
+	}
+	am.historyMu.Lock()
+	defer am.historyMu.Unlock()
+	now := time.Now().Unix()
+	i.valueMap = dict
+	i.SheetType = h.SheetType
+	i.LastModifiedTime, i.LastUsedTime = now, now
+	i.IsSaved = false
+	i.historyBase, i.historySheetType = h.Data, h.SheetType
+	i.historyDirty.Store(false)
+	i.SaveToDB(am.db)
+	return nil
+}
+
+// Undo 撤销卡的最近一次修改，撤销后删除这条记录，因此可以连续撤销
+func (am *AttrsManager) Undo(id string) (*model.AttributesHistoryModel, error) {
+	i, err := am.LoadById(id)
+	if err != nil {
+		return nil, err
+	}
+	// 先记下这张卡尚未记录的改动，免得撤销到更早的状态
+	am.RecordHistory("", "", "", i)
+	list, err := service.AttrsHistoryList(am.db, id, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrAttrsHistoryEmpty
+	}
+	h, err := service.AttrsHistoryGet(am.db, id, list[0].ID)
+	if err != nil {
+		return nil, err
+	}
+	if err = am.restore(i, h); err != nil {
+		return nil, err
+	}
+	return h, service.AttrsHistoryDelete(am.db, h.ID)
+}
+
+// RestoreHistory 把卡恢复到某条记录之前的状态。恢复操作本身也会记录，可以再撤销
+func (am *AttrsManager) RestoreHistory(id string, historyID uint64, actorID, actorName string) (*model.AttributesHistoryModel, error) {
+	h, err := service.AttrsHistoryGet(am.db, id, historyID)
+	if err != nil {
+		return nil, err
+	}
+	i, err := am.LoadById(id)
+	if err != nil {
+		return nil, err
+	}
+	dict, err := attrsDictFromJSON(h.Data)
+	if err != nil {
+		return nil, err
+	}
+	// 按普通修改处理，由 RecordHistory 记下恢复前的状态
+	i.valueMap.Clear()
+	dict.Range(func(key string, value *ds.VMValue) bool {
+		i.valueMap.Store(key, value)
+		return true
+	})
+	i.SetSheetType(h.SheetType)
+	am.RecordHistory(actorID, actorName, fmt.Sprintf("恢复到 #%d 之前", historyID), i)
+	i.SaveToDB(am.db)
+	return h, nil
+}
+
+// AttrsHistoryTitle 记录的简短说明，如 "10-18 21:05 <张三> .st hp-3"
+func AttrsHistoryTitle(h *model.AttributesHistoryModel) string {
+	text := time.Unix(h.CreatedAt, 0).Format("01-02 15:04")
+	if h.ActorName != "" {
+		text += " <" + h.ActorName + ">"
+	}
+	if h.Command != "" {
+		text += " " + h.Command
+	} else {
+		text += " (非指令修改)"
+	}
+	return text
+}
+
+// FormatAttrsChanges 变化的文字形式，如 "hp: 10→5，力量: 50→(删除)"，超过 limit 项时省略
+func FormatAttrsChanges(changes []model.AttrsChange, limit int) string {
+	parts := make([]string, 0, len(changes))
+	for idx, c := range changes {
+		if limit > 0 && idx >= limit {
+			parts = append(parts, fmt.Sprintf("等%d项", len(changes)))
+			break
+		}
+		key := c.Key
+		if key == attrsSheetTypeKey {
+			key = "卡片类型"
+		}
+		switch {
+		case c.Old == "" && c.New != "":
+			parts = append(parts, fmt.Sprintf("%s: +%s", key, c.New))
+		case c.New == "" && c.Old != "":
+			parts = append(parts, fmt.Sprintf("%s: %s→(删除)", key, c.Old))
+		default:
+			parts = append(parts, fmt.Sprintf("%s: %s→%s", key, c.Old, c.New))
+		}
+	}
+	return strings.Join(parts, "，")
+}
@@ -0,0 +1,189 @@
+//nolint:testpackage
+package dice
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/samber/lo"
+	ds "github.com/sealdice/dicescript"
+
+	sealdiceLogger "sealdice-core/logger"
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+)
+
+func newAttrsHistoryTestManager(t *testing.T) *AttrsManager {
+	t.Helper()
+
+	mockDB, err := newMockDatabaseOperator(filepath.Join(t.TempDir(), "data.db"))
+	if err != nil {
+		t.Fatalf("newMockDatabaseOperator: %v", err)
+	}
+	t.Cleanup(mockDB.Close)
+
+	db := mockDB.GetDataDB(constant.WRITE)
+	if err := db.AutoMigrate(&model.AttributesItemModel{}, &model.AttributesHistoryModel{}); err != nil {
+		t.Fatalf("AutoMigrate: %v", err)
+	}
+	return &AttrsManager{db: mockDB, logger: sealdiceLogger.M()}
+}
+
+func attrsHistoryTestValue(t *testing.T, i *AttributesItem, key string) string {
+	t.Helper()
+	v := i.Load(key)
+	if v == nil {
+		return ""
+	}
+	return v.ToString()
+}
+
+func TestAttrsHistoryRecordAndUndo(t *testing.T) {
+	am := newAttrsHistoryTestManager(t)
+	char, err := am.CharNew("QQ:1", "阿尔法", "coc7")
+	if err != nil {
+		t.Fatalf("CharNew: %v", err)
+	}
+	item, err := am.LoadById(char.Id)
+	if err != nil {
+		t.Fatalf("LoadById: %v", err)
+	}
+
+	item.Store("hp", ds.NewIntVal(10))
+	item.Store("力量", ds.NewIntVal(50))
+	am.RecordHistory("QQ:1", "甲", ".st hp10 力量50", item)
+	item.Store("hp", ds.NewIntVal(5))
+	item.Delete("力量")
+	am.RecordHistory("QQ:1", "甲", ".st hp-5 力量-50", item)
+	// 没有改动时不产生记录
+	am.RecordHistory("QQ:1", "甲", ".st show", item)
+
+	list, err := am.History(char.Id, 0)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("History() len = %d, want 2", len(list))
+	}
+	if got := FormatAttrsChanges(list[0].Changes, 0); got != "hp: 10→5，力量: 50→(删除)" {
+		t.Fatalf("newest changes = %q", got)
+	}
+	if list[0].Command != ".st hp-5 力量-50" || list[0].ActorName != "甲" {
+		t.Fatalf("newest entry = %+v", list[0])
+	}
+
+	if _, err = am.Undo(char.Id); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if hp, str := attrsHistoryTestValue(t, item, "hp"), attrsHistoryTestValue(t, item, "力量"); hp != "10" || str != "50" {
+		t.Fatalf("after first undo hp=%q 力量=%q", hp, str)
+	}
+	if _, err = am.Undo(char.Id); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if item.Len() != 0 {
+		t.Fatalf("after second undo len = %d, want 0", item.Len())
+	}
+	if _, err = am.Undo(char.Id); !errors.Is(err, ErrAttrsHistoryEmpty) {
+		t.Fatalf("third Undo() error = %v, want ErrAttrsHistoryEmpty", err)
+	}
+
+	// 撤销结果已写入数据库
+	am.m.Delete(char.Id)
+	item, err = am.LoadById(char.Id)
+	if err != nil {
+		t.Fatalf("LoadById: %v", err)
+	}
+	if item.Len() != 0 {
+		t.Fatalf("reloaded len = %d, want 0", item.Len())
+	}
+}
+
+func TestAttrsHistoryRestoreCanBeUndone(t *testing.T) {
+	am := newAttrsHistoryTestManager(t)
+	char, err := am.CharNew("QQ:1", "阿尔法", "coc7")
+	if err != nil {
+		t.Fatalf("CharNew: %v", err)
+	}
+	item, err := am.LoadById(char.Id)
+	if err != nil {
+		t.Fatalf("LoadById: %v", err)
+	}
+	for _, hp := range []int64{10, 8, 3} {
+		item.Store("hp", ds.NewIntVal(ds.IntType(hp)))
+		am.RecordHistory("QQ:1", "甲", ".st hp", item)
+	}
+	list, err := am.History(char.Id, 0)
+	if err != nil || len(list) != 3 {
+		t.Fatalf("History() = %d, %v", len(list), err)
+	}
+
+	// 恢复到 hp 8→3 之前
+	if _, err = am.RestoreHistory(char.Id, list[0].ID, "", "后台"); err != nil {
+		t.Fatalf("RestoreHistory: %v", err)
+	}
+	if hp := attrsHistoryTestValue(t, item, "hp"); hp != "8" {
+		t.Fatalf("after restore hp = %q, want 8", hp)
+	}
+	list, _ = am.History(char.Id, 0)
+	if len(list) != 4 || list[0].ActorName != "后台" {
+		t.Fatalf("restore should be recorded, got %d entries", len(list))
+	}
+
+	if _, err = am.Undo(char.Id); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if hp := attrsHistoryTestValue(t, item, "hp"); hp != "3" {
+		t.Fatalf("after undoing restore hp = %q, want 3", hp)
+	}
+}
+
+func TestAttrsHistoryKeepsLimit(t *testing.T) {
+	am := newAttrsHistoryTestManager(t)
+	char, err := am.CharNew("QQ:1", "阿尔法", "coc7")
+	if err != nil {
+		t.Fatalf("CharNew: %v", err)
+	}
+	item, err := am.LoadById(char.Id)
+	if err != nil {
+		t.Fatalf("LoadById: %v", err)
+	}
+	for n := 0; n < AttrsHistoryLimit+5; n++ {
+		item.Store("hp", ds.NewIntVal(ds.IntType(n)))
+		am.RecordHistory("QQ:1", "甲", ".st hp", item)
+	}
+	list, err := am.History(char.Id, 0)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(list) != AttrsHistoryLimit {
+		t.Fatalf("History() len = %d, want %d", len(list), AttrsHistoryLimit)
+	}
+}
+
+func TestAttrsHistoryScopeOnlyRecordsTouchedSheets(t *testing.T) {
+	am := newAttrsHistoryTestManager(t)
+	mine, other := lo.Must(am.CharNew("QQ:1", "阿尔法", "coc7")), lo.Must(am.CharNew("QQ:2", "贝塔", "coc7"))
+	mineItem, otherItem := lo.Must(am.LoadById(mine.Id)), lo.Must(am.LoadById(other.Id))
+
+	ctx := &MsgContext{attrsHistory: &attrsHistoryScope{}}
+	ctx.trackAttrsHistory(mineItem)
+	ctx.ShallowCopy().trackAttrsHistory(mineItem)
+	mineItem.Store("hp", ds.NewIntVal(10))
+	// 同时执行的其他指令改动的卡
+	otherItem.Store("hp", ds.NewIntVal(3))
+	am.RecordHistory("QQ:1", "甲", ".st hp10", ctx.attrsHistory.list()...)
+
+	if list, _ := am.History(mine.Id, 0); len(list) != 1 || list[0].ActorName != "甲" {
+		t.Fatalf("touched sheet history = %+v", list)
+	}
+	if list, _ := am.History(other.Id, 0); len(list) != 0 {
+		t.Fatalf("untouched sheet should not be charged to the actor, got %+v", list)
+	}
+
+	am.RecordHistory("QQ:2", "乙", ".st hp3", otherItem)
+	if list, _ := am.History(other.Id, 0); len(list) != 1 || list[0].ActorName != "乙" {
+		t.Fatalf("other sheet history = %+v", list)
+	}
+}
@@ -0,0 +1,240 @@
+package dice
+
+import (
+	"errors"
+	"strings"
+
+	ds "github.com/sealdice/dicescript"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/model"
+)
+
+// 人物卡共享与群NPC卡：
+// 群NPC卡是 OwnerId 为群号的角色卡，群成员都可以读取，群管理可以管理，创建者自动获得编辑权限；
+// 角色卡也可以共享给指定用户，只读或可编辑。这些卡不需要绑定，在指令中用 @npc:名字 引用。
+
+// SheetPermission 用户对一张人物卡的权限，数值越大权限越高
+type SheetPermission int
+
+const (
+	SheetPermNone  SheetPermission = iota
+	SheetPermRead                  // 可以在检定中引用
+	SheetPermEdit                  // 可以修改属性
+	SheetPermOwner                 // 可以共享和删除。群NPC卡的所有者是群管理
+)
+
+var (
+	ErrSheetNotFound   = errors.New("找不到这张卡")
+	ErrSheetPermission = errors.New("没有这张卡的权限")
+)
+
+func (p SheetPermission) String() string {
+	switch p {
+	case SheetPermOwner:
+		return "所有者"
+	case SheetPermEdit:
+		return "可编辑"
+	case SheetPermRead:
+		return "只读"
+	default:
+		return "无权限"
+	}
+}
+
+// CharPermission 用户对卡的权限。groupID 为当前群，groupAdmin 表示用户是当前群的管理
+func (am *AttrsManager) CharPermission(id, userID, groupID string, groupAdmin bool) (SheetPermission, error) {
+	item, err := service.AttrsGetById(am.db, id)
+	if err != nil {
+		return SheetPermNone, err
+	}
+	if item.Id == "" || item.AttrsType != service.AttrsTypeCharacter {
+		return SheetPermNone, ErrSheetNotFound
+	}
+
+	userID = am.UIDConvert(userID)
+	if item.OwnerId == userID {
+		return SheetPermOwner, nil
+	}
+	isGroupSheet := groupID != "" && item.OwnerId == groupID
+	if isGroupSheet && groupAdmin {
+		return SheetPermOwner, nil
+	}
+
+	shared, err := service.AttrsShareGet(am.db, id, userID)
+	if err != nil {
+		return SheetPermNone, err
+	}
+	if perm := sheetShareToPermission(shared); perm > SheetPermNone {
+		return perm, nil
+	}
+	if isGroupSheet {
+		return SheetPermRead, nil
+	}
+	return SheetPermNone, nil
+}
+
+// sheetShareToPermission 共享记录中的权限
+func sheetShareToPermission(shared string) SheetPermission {
+	switch shared {
+	case model.AttrsShareEdit:
+		return SheetPermEdit
+	case model.AttrsShareRead:
+		return SheetPermRead
+	default:
+		return SheetPermNone
+	}
+}
+
+// sheetTypeSuffix 角色列表中卡片类型的后缀，如 " #coc7"
+func sheetTypeSuffix(sheetType string) string {
+	if sheetType == "" {
+		return ""
+	}
+	return " #" + sheetType
+}
+
+// CharPermissionByCtx 当前指令发送者对卡的权限，邀请者及以上视为群管理
+func (am *AttrsManager) CharPermissionByCtx(ctx *MsgContext, id string) (SheetPermission, error) {
+	return am.CharPermission(id, ctx.Player.UserID, ctx.attrsSheetGroupID(), ctx.PrivilegeLevel >= 40)
+}
+
+// CheckEditableByCtx 检查 ctx 当前使用的卡能否修改。自己绑定的卡总是可以修改，
+// 通过 @npc:名字 引用的卡需要编辑权限
+func (am *AttrsManager) CheckEditableByCtx(ctx *MsgContext) error {
+	if ctx.AttrsSheetID != "" && ctx.attrsSheetReadOnly {
+		return ErrSheetPermission
+	}
+	return nil
+}
+
+// SheetResolve 按名字查找用户在当前群可以引用的卡，依次查找群NPC卡、自己的角色卡、别人共享的卡
+func (am *AttrsManager) SheetResolve(groupID, userID, name string, groupAdmin bool) (string, SheetPermission, error) {
+	userID = am.UIDConvert(userID)
+	var ids []string
+	if groupID != "" {
+		id, err := am.CharIdGetByName(groupID, name)
+		if err != nil {
+			return "", SheetPermNone, err
+		}
+		ids = append(ids, id)
+	}
+	id, err := am.CharIdGetByName(userID, name)
+	if err != nil {
+		return "", SheetPermNone, err
+	}
+	ids = append(ids, id)
+	shared, err := service.AttrsSharedListByUserId(am.db, userID, name)
+	if err != nil {
+		return "", SheetPermNone, err
+	}
+	for _, i := range shared {
+		ids = append(ids, i.ID)
+	}
+
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+		perm, err := am.CharPermission(id, userID, groupID, groupAdmin)
+		if err != nil && !errors.Is(err, ErrSheetNotFound) {
+			return "", SheetPermNone, err
+		}
+		if perm > SheetPermNone {
+			return id, perm, nil
+		}
+	}
+	return "", SheetPermNone, ErrSheetNotFound
+}
+
+// NpcNew 新建群NPC卡，创建者获得编辑权限
+func (am *AttrsManager) NpcNew(groupID, creatorID, name, sheetType string) (*model.AttributesItemModel, error) {
+	item, err := am.CharNew(groupID, name, sheetType)
+	if err != nil {
+		return nil, err
+	}
+	if err = service.AttrsShareSet(am.db, item.Id, am.UIDConvert(creatorID), model.AttrsShareEdit); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// NpcList 群NPC卡列表
+func (am *AttrsManager) NpcList(groupID string) ([]*model.AttributesItemModel, error) {
+	return service.AttrsGetCharacterListByUserId(am.db, groupID)
+}
+
+// CharShare 把卡共享给用户，permission 为 model.AttrsShareRead 或 model.AttrsShareEdit
+func (am *AttrsManager) CharShare(id, userID, permission string) error {
+	if permission != model.AttrsShareRead && permission != model.AttrsShareEdit {
+		return errors.New("未知的共享权限: " + permission)
+	}
+	return service.AttrsShareSet(am.db, id, am.UIDConvert(userID), permission)
+}
+
+// CharUnshare 取消对用户的共享，没有共享时返回 false
+func (am *AttrsManager) CharUnshare(id, userID string) (bool, error) {
+	n, err := service.AttrsShareDelete(am.db, id, am.UIDConvert(userID))
+	return n > 0, err
+}
+
+// CharShareList 卡的共享对象
+func (am *AttrsManager) CharShareList(id string) ([]*model.AttributesShareModel, error) {
+	return service.AttrsShareListByAttrsID(am.db, id)
+}
+
+// SharedCharList 别人共享给用户的卡
+func (am *AttrsManager) SharedCharList(userID string) ([]*service.AttrsSharedItem, error) {
+	return service.AttrsSharedListByUserId(am.db, am.UIDConvert(userID), "")
+}
+
+func (ctx *MsgContext) attrsSheetGroupID() string {
+	if ctx.Group == nil || ctx.IsPrivate {
+		return ""
+	}
+	return ctx.Group.GroupID
+}
+
+// SheetProxyCtx 以 @npc:名字 引用的卡创建一个代理 ctx，之后通过 LoadByCtx 读取的都是这张卡。
+// 没有可以引用的同名卡时返回 ErrSheetNotFound
+func (am *AttrsManager) SheetProxyCtx(ctx *MsgContext, name string) (*MsgContext, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || ctx.Player == nil {
+		return nil, ErrSheetNotFound
+	}
+	id, perm, err := am.SheetResolve(ctx.attrsSheetGroupID(), ctx.Player.UserID, name, ctx.PrivilegeLevel >= 40)
+	if err != nil {
+		return nil, err
+	}
+
+	mctx := ctx.ShallowCopy()
+	mctx.vm = nil
+	mctx.AttrsSheetID = id
+	mctx.attrsSheetReadOnly = perm < SheetPermEdit
+	// 引用的卡没有对应的群成员，以卡名作为玩家名，账号仍为指令发送者
+	mctx.Player = &GroupPlayerInfo{
+		Name:         name,
+		UserID:       ctx.Player.UserID,
+		DiceSideNum:  ctx.Player.DiceSideNum,
+		ValueMapTemp: &ds.ValueMap{},
+	}
+	return mctx, nil
+}
+
+// snapshot 卡的副本，不进入缓存，对它的修改不会保存
+func (i *AttributesItem) snapshot() *AttributesItem {
+	dict := &ds.ValueMap{}
+	i.valueMap.Range(func(key string, value *ds.VMValue) bool {
+		dict.Store(key, value)
+		return true
+	})
+	return &AttributesItem{
+		ID:               i.ID,
+		valueMap:         dict,
+		LastModifiedTime: i.LastModifiedTime,
+		LastUsedTime:     i.LastUsedTime,
+		IsSaved:          true,
+		Name:             i.Name,
+		SheetType:        i.SheetType,
+	}
+}
@@ -0,0 +1,164 @@
+//nolint:testpackage
+package dice
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	ds "github.com/sealdice/dicescript"
+
+	sealdiceLogger "sealdice-core/logger"
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+)
+
+func newAttrsShareTestManager(t *testing.T) *AttrsManager {
+	t.Helper()
+
+	mockDB, err := newMockDatabaseOperator(filepath.Join(t.TempDir(), "data.db"))
+	if err != nil {
+		t.Fatalf("newMockDatabaseOperator: %v", err)
+	}
+	t.Cleanup(mockDB.Close)
+
+	db := mockDB.GetDataDB(constant.WRITE)
+	if err := db.AutoMigrate(&model.AttributesItemModel{}, &model.AttributesHistoryModel{}, &model.AttributesShareModel{}); err != nil {
+		t.Fatalf("AutoMigrate: %v", err)
+	}
+	return &AttrsManager{db: mockDB, logger: sealdiceLogger.M()}
+}
+
+func newAttrsShareTestCtx(am *AttrsManager, groupID, userID string, privilege int) *MsgContext {
+	return &MsgContext{
+		MessageType:    "group",
+		Group:          &GroupInfo{GroupID: groupID},
+		Player:         &GroupPlayerInfo{Name: "玩家", UserID: userID, ValueMapTemp: &ds.ValueMap{}},
+		Dice:           &Dice{AttrsManager: am},
+		PrivilegeLevel: privilege,
+	}
+}
+
+func TestAttrsSharePermission(t *testing.T) {
+	am := newAttrsShareTestManager(t)
+	const group = "QQ-Group:1"
+
+	npc, err := am.NpcNew(group, "QQ:10", "守卫", "coc7")
+	if err != nil {
+		t.Fatalf("NpcNew: %v", err)
+	}
+	char, err := am.CharNew("QQ:20", "阿尔法", "coc7")
+	if err != nil {
+		t.Fatalf("CharNew: %v", err)
+	}
+	if err = am.CharShare(char.Id, "QQ:30", model.AttrsShareRead); err != nil {
+		t.Fatalf("CharShare: %v", err)
+	}
+
+	cases := []struct {
+		id, user, group string
+		admin           bool
+		want            SheetPermission
+	}{
+		{npc.Id, "QQ:10", group, false, SheetPermEdit},       // 创建者
+		{npc.Id, "QQ:11", group, false, SheetPermRead},       // 群成员
+		{npc.Id, "QQ:12", group, true, SheetPermOwner},       // 群管理
+		{npc.Id, "QQ:11", "QQ-Group:2", true, SheetPermNone}, // 其他群
+		{char.Id, "QQ:20", group, false, SheetPermOwner},
+		{char.Id, "QQ:30", "", false, SheetPermRead},
+		{char.Id, "QQ:31", group, true, SheetPermNone},
+	}
+	for _, c := range cases {
+		got, err := am.CharPermission(c.id, c.user, c.group, c.admin)
+		if err != nil {
+			t.Fatalf("CharPermission(%s, %s): %v", c.id, c.user, err)
+		}
+		if got != c.want {
+			t.Fatalf("CharPermission(%s, %s, %s) = %s, want %s", c.id, c.user, c.group, got, c.want)
+		}
+	}
+
+	// 共享权限可以升级，取消后失去权限
+	if err = am.CharShare(char.Id, "QQ:30", model.AttrsShareEdit); err != nil {
+		t.Fatalf("CharShare edit: %v", err)
+	}
+	if got, _ := am.CharPermission(char.Id, "QQ:30", "", false); got != SheetPermEdit {
+		t.Fatalf("after upgrade = %s, want %s", got, SheetPermEdit)
+	}
+	if ok, _ := am.CharUnshare(char.Id, "QQ:30"); !ok {
+		t.Fatal("CharUnshare returned false")
+	}
+	if got, _ := am.CharPermission(char.Id, "QQ:30", "", false); got != SheetPermNone {
+		t.Fatalf("after unshare = %s, want %s", got, SheetPermNone)
+	}
+
+	// 删除卡时一并删除共享记录
+	if err = am.CharDelete(npc.Id); err != nil {
+		t.Fatalf("CharDelete: %v", err)
+	}
+	if list, _ := am.CharShareList(npc.Id); len(list) != 0 {
+		t.Fatalf("shares after delete = %d, want 0", len(list))
+	}
+}
+
+func TestAttrsShareProxyCtx(t *testing.T) {
+	am := newAttrsShareTestManager(t)
+	const group = "QQ-Group:1"
+
+	npc, err := am.NpcNew(group, "QQ:10", "守卫", "coc7")
+	if err != nil {
+		t.Fatalf("NpcNew: %v", err)
+	}
+	attrs, err := am.LoadById(npc.Id)
+	if err != nil {
+		t.Fatalf("LoadById: %v", err)
+	}
+	attrs.Store("侦查", ds.NewIntVal(60))
+
+	cmdArgs := CommandParse(".ra 侦查 @npc:守卫", []string{"ra"}, []string{"."}, "QQ", false)
+	if cmdArgs == nil || len(cmdArgs.AtSheets) != 1 || cmdArgs.AtSheets[0] != "守卫" || cmdArgs.CleanArgs != "侦查" {
+		t.Fatalf("parse = %+v", cmdArgs)
+	}
+
+	// 创建者可以修改
+	ctx := newAttrsShareTestCtx(am, group, "QQ:10", 0)
+	mctx := GetCtxProxyAtPosRaw(ctx, cmdArgs, 0, false)
+	if mctx.AttrsSheetID != npc.Id || mctx.Player.Name != "守卫" {
+		t.Fatalf("proxy = %q %q, want NPC sheet", mctx.AttrsSheetID, mctx.Player.Name)
+	}
+	if err = am.CheckEditableByCtx(mctx); err != nil {
+		t.Fatalf("CheckEditableByCtx creator: %v", err)
+	}
+	loaded, _ := am.LoadByCtx(mctx)
+	loaded.Store("侦查", ds.NewIntVal(70))
+	if v := attrs.Load("侦查"); v == nil || v.ToString() != "70" {
+		t.Fatalf("editable change not applied: %v", v)
+	}
+
+	// 群成员只读：读到的是副本，改动不写回
+	ctx = newAttrsShareTestCtx(am, group, "QQ:11", 0)
+	mctx = GetCtxProxyAtPosRaw(ctx, cmdArgs, 0, false)
+	if !errors.Is(am.CheckEditableByCtx(mctx), ErrSheetPermission) {
+		t.Fatal("member should not edit NPC sheet")
+	}
+	loaded, _ = am.LoadByCtx(mctx)
+	if v := loaded.Load("侦查"); v == nil || v.ToString() != "70" {
+		t.Fatalf("read-only load = %v, want 70", v)
+	}
+	loaded.Store("侦查", ds.NewIntVal(1))
+	if v := attrs.Load("侦查"); v.ToString() != "70" {
+		t.Fatalf("read-only change leaked: %v", v)
+	}
+
+	// 其他群找不到这张卡，仍使用自己的卡
+	ctx = newAttrsShareTestCtx(am, "QQ-Group:2", "QQ:11", 0)
+	if mctx = GetCtxProxyAtPosRaw(ctx, cmdArgs, 0, false); mctx != ctx {
+		t.Fatal("NPC sheet should not be visible in other groups")
+	}
+
+	// 不支持代骰的指令把 @npc:名字 放回参数
+	cmdArgs.RevokeAtSheets()
+	if len(cmdArgs.AtSheets) != 0 || cmdArgs.CleanArgs != "侦查 @npc:守卫" {
+		t.Fatalf("revoke = %q %v", cmdArgs.CleanArgs, cmdArgs.AtSheets)
+	}
+}
@@ -1,8 +1,10 @@
 package dice
 
 import (
+	"context"
 	crand "crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 	"math/rand"
@@ -22,6 +24,8 @@ import (
 	ds "github.com/sealdice/dicescript"
 
 	"sealdice-core/dice/docengine"
+	"sealdice-core/dice/storylog"
+	"sealdice-core/model"
 )
 
 type dismissConfirmState struct {
@@ -2179,6 +2183,9 @@ func (d *Dice) registerCoreCommands() {
 						extNames := []string{}
 						extNames = append(extNames, tmpl.Commands.Set.RelatedExt...)
 						tmpl.SetConfig.EnableTip = fmt.Sprintf("已切换至 %s(%s) 规则，默认骰子面数 %s，自动启用关联扩展: %s", tmpl.FullName, tmpl.Name, tmpl.Commands.Set.DiceSideExpr, strings.Join(extNames, ", "))
+						if names := tmpl.CheckCmdNames(); len(names) > 0 {
+							tmpl.SetConfig.EnableTip += "，检定指令: ." + strings.Join(names, " .")
+						}
 					}
 
 					tipText += tmpl.SetConfig.EnableTip
@@ -2269,6 +2276,14 @@ func (d *Dice) registerCoreCommands() {
 		".pc save [<角色名>] // [不绑卡]保存角色，角色名可省略\n" +
 		".pc load (<角色名> | <角色序号>) // [不绑卡]加载角色\n" +
 		".pc del/rm (<角色名> | <角色序号>) // 删除角色 角色序号可用pc list查询\n" +
+		".pc history [<条数>] // 查看当前卡的修改记录\n" +
+		".pc diff <序号> // 查看某次修改的全部改动，序号见pc history\n" +
+		".pc import [<格式>] [<网址>] // 从随指令发送的文件或网址(仅骰主)导入为新角色并绑卡，格式可省略\n" +
+		".pc export [<格式>] // 以文件导出当前卡，默认json，可用格式见.pc export help\n" +
+		".pc npc new/del <名字> // 新建/删除本群NPC卡，在检定中用 @npc:名字 引用\n" +
+		".pc npc list // 列出本群NPC卡\n" +
+		".pc share <角色名> @某人 [edit] // 共享角色卡或NPC卡，默认只读，edit为可编辑；不@人时列出共享对象\n" +
+		".pc unshare <角色名> @某人 // 取消共享\n" +
 		"> 注: 海豹各群数据独立(多张空白卡)，单群游戏不需要存角色。"
 
 	cmdChar := &CmdItemInfo{
@@ -2276,7 +2291,7 @@ func (d *Dice) registerCoreCommands() {
 		ShortHelp: helpCh,
 		Help:      "角色管理:\n" + helpCh,
 		Solve: func(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs) (result CmdExecuteResult) {
-			cmdArgs.ChopPrefixToArgsWith("list", "lst", "load", "save", "del", "rm", "new", "tag", "untagAll", "rename")
+			cmdArgs.ChopPrefixToArgsWith("list", "lst", "load", "save", "del", "rm", "new", "tag", "untagAll", "rename", "history", "diff", "import", "export", "npc", "share", "unshare")
 			val1 := cmdArgs.GetArgN(1)
 			am := d.AttrsManager
 
@@ -2345,10 +2360,7 @@ func (d *Dice) registerCoreCommands() {
 					if bindingId == item.Id {
 						prefix = "[√]"
 					}
-					suffix := ""
-					if item.SheetType != "" {
-						suffix = fmt.Sprintf(" #%s", item.SheetType)
-					}
+					suffix := sheetTypeSuffix(item.SheetType)
 
 					// 格式参考:
 					// 01[×] 张三 #dnd5e
@@ -2358,11 +2370,133 @@ func (d *Dice) registerCoreCommands() {
 					newChars = append(newChars, fmt.Sprintf("%2d %s %s%s", idx+1, prefix, item.Name, suffix))
 				}
 
-				if len(list) == 0 {
+				// 别人共享的卡和本群NPC卡不占序号，用 @npc:名字 引用
+				var sharedChars []string
+				for _, item := range lo.Must(am.SharedCharList(ctx.Player.UserID)) {
+					sharedChars = append(sharedChars, fmt.Sprintf("   [%s] %s%s 来自%s", sheetShareToPermission(item.Permission), item.Name, sheetTypeSuffix(item.SheetType), item.OwnerID))
+				}
+				var npcChars []string
+				if !ctx.IsPrivate {
+					for _, item := range lo.Must(am.NpcList(ctx.Group.GroupID)) {
+						perm, _ := am.CharPermissionByCtx(ctx, item.Id)
+						npcChars = append(npcChars, fmt.Sprintf("   [%s] %s%s", perm, item.Name, sheetTypeSuffix(item.SheetType)))
+					}
+				}
+
+				if len(list) == 0 && len(sharedChars) == 0 && len(npcChars) == 0 {
 					ReplyToSender(ctx, msg, fmt.Sprintf("<%s>当前还没有角色列表", ctx.Player.Name))
 				} else {
-					ReplyToSender(ctx, msg, fmt.Sprintf("<%s>的角色列表为:\n%s\n[√]已绑 [×]未绑 [★]其他群绑定", ctx.Player.Name, strings.Join(newChars, "\n")))
+					text := fmt.Sprintf("<%s>的角色列表为:\n%s", ctx.Player.Name, strings.Join(newChars, "\n"))
+					if len(sharedChars) > 0 {
+						text += "\n共享给你的卡:\n" + strings.Join(sharedChars, "\n")
+					}
+					if len(npcChars) > 0 {
+						text += "\n本群NPC卡:\n" + strings.Join(npcChars, "\n")
+					}
+					text += "\n[√]已绑 [×]未绑 [★]其他群绑定"
+					if len(sharedChars) > 0 || len(npcChars) > 0 {
+						text += "\n共享卡与NPC卡无需绑定，用 @npc:名字 引用"
+					}
+					ReplyToSender(ctx, msg, text)
+				}
+				return CmdExecuteResult{Matched: true, Solved: true}
+
+			case "npc":
+				if ctx.IsPrivate {
+					ReplyToSender(ctx, msg, "NPC卡属于群，请在群内使用")
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				groupID := ctx.Group.GroupID
+				name := cmdArgs.GetRestArgsFrom(3)
+				switch cmdArgs.GetArgN(2) {
+				case "", "list", "lst":
+					var lines []string
+					for _, item := range lo.Must(am.NpcList(groupID)) {
+						perm, _ := am.CharPermissionByCtx(ctx, item.Id)
+						lines = append(lines, fmt.Sprintf("[%s] %s%s", perm, item.Name, sheetTypeSuffix(item.SheetType)))
+					}
+					if len(lines) == 0 {
+						ReplyToSender(ctx, msg, "本群还没有NPC卡，可用 .pc npc new <名字> 新建")
+					} else {
+						ReplyToSender(ctx, msg, "本群NPC卡:\n"+strings.Join(lines, "\n"))
+					}
+				case "new":
+					if name == "" {
+						return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+					}
+					if am.CharCheckExists(groupID, name) {
+						ReplyToSender(ctx, msg, fmt.Sprintf("本群已有NPC卡<%s>", name))
+						return CmdExecuteResult{Matched: true, Solved: true}
+					}
+					lo.Must(am.NpcNew(groupID, ctx.Player.UserID, name, ctx.Group.System))
+					ReplyToSender(ctx, msg, fmt.Sprintf("已新建本群NPC卡<%s>，可用 .st @npc:%s <属性><值> 录入属性，.ra @npc:%s <技能> 检定", name, name, name))
+				case "del", "rm":
+					id := lo.Must(am.CharIdGetByName(groupID, name))
+					if name == "" || id == "" {
+						ReplyToSender(ctx, msg, fmt.Sprintf("本群没有NPC卡<%s>", name))
+						return CmdExecuteResult{Matched: true, Solved: true}
+					}
+					if perm, _ := am.CharPermissionByCtx(ctx, id); perm < SheetPermEdit {
+						ReplyToSender(ctx, msg, "只有群管理或拥有编辑权限的人可以删除NPC卡")
+						return CmdExecuteResult{Matched: true, Solved: true}
+					}
+					lo.Must0(am.CharDelete(id))
+					ReplyToSender(ctx, msg, fmt.Sprintf("已删除本群NPC卡<%s>", name))
+				default:
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+				return CmdExecuteResult{Matched: true, Solved: true}
+
+			case "share", "unshare":
+				name := cmdArgs.GetArgN(2)
+				if name == "" {
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+				id, perm, err := am.SheetResolve(ctx.attrsSheetGroupID(), ctx.Player.UserID, name, ctx.PrivilegeLevel >= 40)
+				if errors.Is(err, ErrSheetNotFound) {
+					ReplyToSender(ctx, msg, fmt.Sprintf("找不到角色<%s>", name))
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				lo.Must0(err)
+				if perm < SheetPermOwner {
+					ReplyToSender(ctx, msg, fmt.Sprintf("只有<%s>的所有者可以管理共享", name))
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+
+				var targets []string
+				for _, i := range cmdArgs.At {
+					if i.UserID != ctx.EndPoint.UserID && i.UserID != ctx.Player.UserID {
+						targets = append(targets, i.UserID)
+					}
+				}
+				if len(targets) == 0 {
+					var lines []string
+					for _, i := range lo.Must(am.CharShareList(id)) {
+						lines = append(lines, fmt.Sprintf("%s [%s]", i.UserID, sheetShareToPermission(i.Permission)))
+					}
+					if len(lines) == 0 {
+						ReplyToSender(ctx, msg, fmt.Sprintf("<%s>没有共享给任何人", name))
+					} else {
+						ReplyToSender(ctx, msg, fmt.Sprintf("<%s>的共享对象:\n%s", name, strings.Join(lines, "\n")))
+					}
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+
+				if val1 == "unshare" {
+					for _, uid := range targets {
+						lo.Must(am.CharUnshare(id, uid))
+					}
+					ReplyToSender(ctx, msg, fmt.Sprintf("已取消<%s>对%d人的共享", name, len(targets)))
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				permission := model.AttrsShareRead
+				if cmdArgs.IsArgEqual(3, "edit", "编辑") {
+					permission = model.AttrsShareEdit
+				}
+				for _, uid := range targets {
+					lo.Must0(am.CharShare(id, uid, permission))
 				}
+				ReplyToSender(ctx, msg, fmt.Sprintf("已将<%s>共享给%d人[%s]，对方可用 @npc:%s 引用", name, len(targets), sheetShareToPermission(permission), name))
 				return CmdExecuteResult{Matched: true, Solved: true}
 
 			case "new":
@@ -2462,6 +2596,7 @@ func (d *Dice) registerCoreCommands() {
 					// ReplyToSender(ctx, msg, DiceFormatTmpl(ctx, "核心:角色管理_序列化失败"))
 				} else {
 					attrs := lo.Must(am.LoadById(charId))
+					ctx.trackAttrsHistory(attrsCur)
 
 					attrsCur.Clear()
 					attrs.Range(func(key string, value *ds.VMValue) bool {
@@ -2493,6 +2628,7 @@ func (d *Dice) registerCoreCommands() {
 							ReplyToSender(ctx, msg, DiceFormatTmpl(ctx, "核心:角色管理_序列化失败"))
 							return CmdExecuteResult{Matched: true, Solved: true}
 						}
+						ctx.trackAttrsHistory(attrsNew)
 
 						attrs.Range(func(key string, value *ds.VMValue) bool {
 							attrsNew.Store(key, value)
@@ -2521,6 +2657,7 @@ func (d *Dice) registerCoreCommands() {
 					if len(bindingGroups) == 0 {
 						attrs := lo.Must(am.Load(ctx.Group.GroupID, ctx.Player.UserID))
 						attrsExisting := lo.Must(am.LoadById(charId))
+						ctx.trackAttrsHistory(attrsExisting)
 
 						attrsExisting.Clear()
 						attrs.Range(func(key string, value *ds.VMValue) bool {
@@ -2571,6 +2708,138 @@ func (d *Dice) registerCoreCommands() {
 					ReplyToSender(ctx, msg, "这张卡片并未绑定到任何群")
 				}
 				return CmdExecuteResult{Matched: true, Solved: true}
+			case "import":
+				format, source := cmdArgs.GetArgN(2), cmdArgs.GetArgN(3)
+				if strings.HasPrefix(format, "http://") || strings.HasPrefix(format, "https://") {
+					format, source = "", format
+				}
+				url, stream, filename, fromArg, ok := sheetImportSource(msg, source)
+				if !ok {
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+				// 网址会由骰子所在的机器去访问，只允许骰主使用
+				if fromArg && ctx.PrivilegeLevel < 100 {
+					ReplyToSender(ctx, msg, "从网址导入人物卡需要骰主权限，可以直接随指令发送人物卡文件")
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				fetchCtx, cancel := context.WithTimeout(context.Background(), sheetFetchTimeout)
+				defer cancel()
+				data, err := sheetFetch(fetchCtx, url, stream, fromArg)
+				if err != nil {
+					ReplyToSender(ctx, msg, "读取人物卡失败: "+err.Error())
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				doc, err := d.SheetImport(data, filename, format, ctx.Group.System)
+				if err != nil {
+					ReplyToSender(ctx, msg, "导入失败: "+err.Error())
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+
+				name := strings.TrimSpace(doc.Name)
+				if name == "" {
+					name = ctx.Player.Name
+				}
+				if am.CharCheckExists(ctx.Player.UserID, name) {
+					ReplyToSender(ctx, msg, fmt.Sprintf("已存在名为<%s>的角色，请先改名或删除后再导入", name))
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				item := lo.Must(am.CharNew(ctx.Player.UserID, name, doc.SheetType))
+				attrs := lo.Must(am.LoadById(item.Id))
+				ctx.trackAttrsHistory(attrs)
+				if err = d.SheetApplyDocument(attrs, doc); err != nil {
+					_ = am.CharDelete(item.Id)
+					ReplyToSender(ctx, msg, "导入失败: "+err.Error())
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				attrs.SaveToDB(am.db)
+				lo.Must0(am.CharBind(item.Id, ctx.Group.GroupID, ctx.Player.UserID))
+				setCurPlayerName(name)
+				if ctx.Player.AutoSetNameTemplate != "" {
+					_, _ = SetPlayerGroupCardByTemplate(ctx, ctx.Player.AutoSetNameTemplate)
+				}
+				ReplyToSender(ctx, msg, fmt.Sprintf("已导入角色<%s>(%s)，共%d项属性，并已绑定到当前群", name, doc.SheetType, attrs.Len()))
+				return CmdExecuteResult{Matched: true, Solved: true}
+			case "export":
+				format := strings.ToLower(cmdArgs.GetArgN(2))
+				if format == "help" {
+					var lines []string
+					for _, c := range SheetConverters() {
+						lines = append(lines, fmt.Sprintf("%s: %s", c.Name(), c.Description()))
+					}
+					ReplyToSender(ctx, msg, "可用的人物卡格式:\n"+strings.Join(lines, "\n"))
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				if format == "" {
+					format = "json"
+				}
+				attrs := lo.Must(am.Load(ctx.Group.GroupID, ctx.Player.UserID))
+				doc := d.SheetDocumentFromAttrs(attrs)
+				if doc.Name == "" {
+					doc.Name = ctx.Player.Name
+				}
+				if doc.SheetType == "" {
+					doc.SheetType = ctx.Group.System
+				}
+				data, ext, err := d.SheetExport(doc, format)
+				if err != nil {
+					ReplyToSender(ctx, msg, "导出失败: "+err.Error())
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				pattern, _ := storylog.BuildTempPatternExt(doc.Name, ext)
+				f, err := os.CreateTemp("", pattern)
+				if err != nil {
+					ReplyToSender(ctx, msg, "导出失败: "+err.Error())
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				_, err = f.Write(data)
+				_ = f.Close()
+				defer os.Remove(f.Name())
+				if err != nil {
+					ReplyToSender(ctx, msg, "导出失败: "+err.Error())
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				uri := "files://" + f.Name()
+				if runtime.GOOS == "windows" {
+					uri = "files:///" + f.Name()
+				}
+				SendFileToSenderRaw(ctx, msg, uri, "skip")
+				ReplyToSender(ctx, msg, fmt.Sprintf("已导出<%s>的人物卡(%s格式)", doc.Name, format))
+				return CmdExecuteResult{Matched: true, Solved: true}
+			case "history":
+				limit := 10
+				if n, err := strconv.Atoi(cmdArgs.GetArgN(2)); err == nil && n > 0 {
+					limit = min(n, AttrsHistoryLimit)
+				}
+				attrs := lo.Must(am.Load(ctx.Group.GroupID, ctx.Player.UserID))
+				list := lo.Must(am.History(attrs.ID, limit))
+				if len(list) == 0 {
+					ReplyToSender(ctx, msg, fmt.Sprintf("<%s>的当前卡还没有修改记录", ctx.Player.Name))
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				lines := make([]string, 0, len(list))
+				for idx, h := range list {
+					lines = append(lines, fmt.Sprintf("%d. %s\n   %s", idx+1, AttrsHistoryTitle(h), FormatAttrsChanges(h.Changes, 5)))
+				}
+				ReplyToSender(ctx, msg, fmt.Sprintf("<%s>当前卡的修改记录(新→旧):\n%s\n使用.pc diff <序号>查看完整改动，.st undo撤销最近一次", ctx.Player.Name, strings.Join(lines, "\n")))
+				return CmdExecuteResult{Matched: true, Solved: true}
+			case "diff":
+				n, err := strconv.Atoi(cmdArgs.GetArgN(2))
+				if err != nil || n <= 0 || n > AttrsHistoryLimit {
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+				attrs := lo.Must(am.Load(ctx.Group.GroupID, ctx.Player.UserID))
+				list := lo.Must(am.History(attrs.ID, n))
+				if len(list) < n {
+					ReplyToSender(ctx, msg, fmt.Sprintf("没有第%d条修改记录", n))
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				h := list[n-1]
+				lines := make([]string, 0, len(h.Changes))
+				for k := range h.Changes {
+					lines = append(lines, FormatAttrsChanges(h.Changes[k:k+1], 0))
+				}
+				ReplyToSender(ctx, msg, fmt.Sprintf("%d. %s\n%s", n, AttrsHistoryTitle(h), strings.Join(lines, "\n")))
+				return CmdExecuteResult{Matched: true, Solved: true}
 			case "del", "rm":
 				name := getNicknameRaw(false, true)
 				if name == "" {
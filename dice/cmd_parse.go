@@ -38,6 +38,7 @@ type AtInfo struct {
 func (i *AtInfo) CopyCtx(ctx *MsgContext) (*MsgContext, bool) {
 	mctx := ctx.ShallowCopy() // 复制一个ctx，用于其他用途
 	mctx.vm = nil
+	mctx.AttrsSheetID, mctx.attrsSheetReadOnly = "", false
 	if ctx.Group != nil {
 		p := ctx.Group.PlayerGet(ctx.Dice.DBOperator, i.UserID)
 		if p != nil {
@@ -67,6 +68,7 @@ type CmdArgs struct {
 	Args                       []string  `jsbind:"args"                     json:"args"`
 	Kwargs                     []*Kwarg  `jsbind:"kwargs"                   json:"kwargs"`
 	At                         []*AtInfo `jsbind:"at"                       json:"atInfo"`
+	AtSheets                   []string  `jsbind:"atSheets"                 json:"atSheets"` // @npc:名字 引用的人物卡名
 	RawArgs                    string    `jsbind:"rawArgs"                  json:"rawArgs"`
 	AmIBeMentioned             bool      `jsbind:"amIBeMentioned"           json:"amIBeMentioned"`
 	AmIBeMentionedFirst        bool      `jsbind:"amIBeMentionedFirst"      json:"amIBeMentionedFirst"` // 同上，但要求是第一个被@的
@@ -78,6 +80,7 @@ type CmdArgs struct {
 	prefixStr                  string    // 命令前导符号，这几个用于基于当前cmdArgs信息重走解析流程，暂不对js开放
 	platformPrefix             string    // 平台前缀
 	uidForAtInfo               string    // 用于处理@的uid
+	argsWithAtSheets           []string  // 取出 @npc:名字 之前的参数
 
 	MentionedOtherDice bool   // 似乎没有在用
 	CleanArgsChopRest  string // 未来可能移除
@@ -183,6 +186,37 @@ func (cmdArgs *CmdArgs) RevokeExecuteTimesParse(ctx *MsgContext, msg *Message) {
 	}
 }
 
+var reAtSheet = regexp.MustCompile(`^@(?i:npc)[:：](.+)$`)
+
+// parseAtSheets 从参数中取出 @npc:名字 形式的人物卡引用，存入 AtSheets
+func (cmdArgs *CmdArgs) parseAtSheets() {
+	cmdArgs.AtSheets, cmdArgs.argsWithAtSheets = nil, nil
+	rest := make([]string, 0, len(cmdArgs.Args))
+	for _, arg := range cmdArgs.Args {
+		if m := reAtSheet.FindStringSubmatch(arg); m != nil {
+			cmdArgs.AtSheets = append(cmdArgs.AtSheets, m[1])
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	if len(cmdArgs.AtSheets) == 0 {
+		return
+	}
+	cmdArgs.argsWithAtSheets = cmdArgs.Args
+	cmdArgs.Args = rest
+	cmdArgs.CleanArgs = strings.TrimSpace(strings.Join(rest, " "))
+}
+
+// RevokeAtSheets 把 @npc:名字 放回参数中，用于不支持代骰的指令
+func (cmdArgs *CmdArgs) RevokeAtSheets() {
+	if cmdArgs.argsWithAtSheets == nil {
+		return
+	}
+	cmdArgs.Args = cmdArgs.argsWithAtSheets
+	cmdArgs.CleanArgs = strings.TrimSpace(strings.Join(cmdArgs.Args, " "))
+	cmdArgs.AtSheets, cmdArgs.argsWithAtSheets = nil, nil
+}
+
 func (cmdArgs *CmdArgs) SetupAtInfo(uid string) {
 	// 设置AmIBeMentioned
 	cmdArgs.AmIBeMentioned = false
@@ -315,6 +349,7 @@ func (cmdArgs *CmdArgs) commandParse(rawCmd string, currentCmdLst []string, pref
 		// 将所有args连接起来，存入一个cleanArgs变量。主要用于兼容非标准参数
 		stText := strings.Join(cmdArgs.Args, " ")
 		cmdArgs.CleanArgs = strings.TrimSpace(stText)
+		cmdArgs.parseAtSheets()
 		// NOTE(Xiangze Li): 不要在解析指令时直接修改轮数
 		// if specialExecuteTimes > 25 {
 		// 	specialExecuteTimes = 25
@@ -547,6 +582,9 @@ func findMatchingCommand(restText string, d *Dice, group *GroupInfo) (string, bo
 				cmdLst = append(cmdLst, k)
 			}
 		}
+		for k := range group.checkCmdMap(d) {
+			cmdLst = append(cmdLst, k)
+		}
 	}
 
 	// 按长度排序，优先匹配长命令
@@ -597,6 +635,7 @@ func buildCmdArgs(cmdArgs *CmdArgs, matched, restText, rawCmd string,
 	cmdArgs.Kwargs = a.Kwargs
 	cmdArgs.RawText = rawCmd
 	cmdArgs.CleanArgs = strings.TrimSpace(strings.Join(cmdArgs.Args, " "))
+	cmdArgs.parseAtSheets()
 	cmdArgs.IsSpaceBeforeArgs = isSpaceBeforeArgs
 	cmdArgs.SpecialExecuteTimes = specialExecuteTimes
 	cmdArgs.prefixStr = prefixStr
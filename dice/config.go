@@ -16,6 +16,7 @@ import (
 	wr "github.com/mroth/weightedrand"
 	"gopkg.in/yaml.v3"
 
+	"sealdice-core/dice/sealpack"
 	"sealdice-core/dice/service"
 	"sealdice-core/logger"
 	"sealdice-core/model"
@@ -75,6 +76,9 @@ type ConfigItem struct {
 
 	Description string `jsbind:"description" json:"description"`
 
+	// Schema 仅 schema 类型使用，与扩展包 info.toml 中的 ConfigSchema 一致
+	Schema *sealpack.ConfigSchema `jsbind:"schema" json:"schema,omitempty"`
+
 	task *JsScriptTask
 }
 
@@ -169,6 +173,22 @@ func (i *ConfigItem) UnmarshalJSON(data []byte) error {
 			return fmt.Errorf("ConfigItem (%s-%s): unmarshal 'option' failed as %w", i.Key, i.Type, err)
 		}
 		i.Option = optionVal
+	case "schema":
+		if v, ok := raw["schema"]; ok {
+			if err := json.Unmarshal(v, &i.Schema); err != nil {
+				return fmt.Errorf("ConfigItem (%s-%s): unmarshal 'schema' failed as %w", i.Key, i.Type, err)
+			}
+		}
+		if v, ok := raw["defaultValue"]; ok {
+			if err := json.Unmarshal(v, &i.DefaultValue); err != nil {
+				return fmt.Errorf("ConfigItem (%s-%s): unmarshal 'defaultValue' failed as %w", i.Key, i.Type, err)
+			}
+		}
+		if v, ok := raw["value"]; ok {
+			if err := json.Unmarshal(v, &i.Value); err != nil {
+				return fmt.Errorf("ConfigItem (%s-%s): unmarshal 'value' failed as %w", i.Key, i.Type, err)
+			}
+		}
 	default:
 		return errors.New("ConfigItem.UnmarshalJSON: unsupported type " + i.Type)
 	}
@@ -177,6 +197,35 @@ func (i *ConfigItem) UnmarshalJSON(data []byte) error {
 
 var _ json.Unmarshaler = (*ConfigItem)(nil)
 
+// parseJsConfigSchema 将脚本传入的 schema 对象转换为 ConfigSchema 并校验
+func parseJsConfigSchema(key string, def map[string]interface{}) (*sealpack.ConfigSchema, error) {
+	raw, err := json.Marshal(def)
+	if err != nil {
+		return nil, fmt.Errorf("配置项 %s 的 schema 无法解析: %w", key, err)
+	}
+	var schema sealpack.ConfigSchema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, fmt.Errorf("配置项 %s 的 schema 无法解析: %w", key, err)
+	}
+	if err := sealpack.ValidateConfigSchema(key, schema); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// Masked 返回用于展示的副本，secret 值被替换为 sealpack.SecretMask
+func (i *ConfigItem) Masked() *ConfigItem {
+	if i == nil {
+		return nil
+	}
+	n := *i
+	if i.Type == "schema" && i.Schema != nil {
+		n.Value = sealpack.MaskSecretConfigValue(i.Value, *i.Schema)
+		n.DefaultValue = sealpack.MaskSecretConfigValue(i.DefaultValue, *i.Schema)
+	}
+	return &n
+}
+
 type PluginConfig struct {
 	PluginName        string                 `json:"pluginName"`
 	Configs           map[string]*ConfigItem `jsbind:"configs"           json:"configs"`
@@ -242,10 +291,15 @@ func (cm *ConfigManager) RegisterPluginConfig(pluginName string, configItems ...
 				existingItem.DefaultValue = newItem.DefaultValue
 				existingItem.Option = newItem.Option
 				existingItem.Description = newItem.Description
+				existingItem.Schema = newItem.Schema
 				existingItem.Deprecated = false // Reset deprecated flag
 				existingItem.task = newItem.task
 				if typeChanged {
 					existingItem.Value = newItem.DefaultValue
+				} else if existingItem.Type == "schema" && existingItem.Schema != nil &&
+					sealpack.ValidateConfigValue(existingItem.Key, existingItem.Value, *existingItem.Schema) != nil {
+					// schema 变更后旧值不再合法时恢复默认值
+					existingItem.Value = newItem.DefaultValue
 				}
 				existingPlugin.Configs[newItem.Key] = existingItem
 				// Extension can reorder config by re-registering it
@@ -311,6 +365,22 @@ func (cm *ConfigManager) UnregisterConfig(pluginName string, keys ...string) {
 	_ = cm.save()
 }
 
+// stopPluginTasks 停止插件通过配置项注册的定时任务，配置本身保留
+func (cm *ConfigManager) stopPluginTasks(pluginName string) {
+	cm.lock.RLock()
+	defer cm.lock.RUnlock()
+
+	plugin, ok := cm.Plugins[pluginName]
+	if !ok {
+		return
+	}
+	for _, config := range plugin.Configs {
+		if strings.HasPrefix(config.Type, "task:") && config.task != nil {
+			config.task.Off()
+		}
+	}
+}
+
 func (cm *ConfigManager) SetConfig(pluginName, key string, value interface{}) error {
 	cm.lock.Lock()
 	defer cm.lock.Unlock()
@@ -322,6 +392,13 @@ func (cm *ConfigManager) SetConfig(pluginName, key string, value interface{}) er
 
 	configItem, exists := plugin.Configs[key]
 	if exists {
+		// 只校验 schema 类型；旧的简单类型保持原有行为，已保存的旧配置不受影响
+		if configItem.Type == "schema" && configItem.Schema != nil {
+			value = sealpack.NormalizeConfigValue(sealpack.RestoreSecretConfigValue(value, configItem.Value, *configItem.Schema))
+			if err := sealpack.ValidateConfigValue(key, value, *configItem.Schema); err != nil {
+				return fmt.Errorf("plugin %s: %w", pluginName, err)
+			}
+		}
 		switch configItem.Type {
 		case "task:cron", "task:daily":
 			val := value.(string)
@@ -0,0 +1,95 @@
+package dice //nolint:testpackage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"sealdice-core/dice/sealpack"
+)
+
+func TestConfigManagerValidatesSchemaConfig(t *testing.T) {
+	cm := NewConfigManager(filepath.Join(t.TempDir(), "plugin-configs.json"))
+	schema, err := parseJsConfigSchema("server", map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"port":  map[string]interface{}{"type": "integer", "min": 1, "max": 65535},
+			"token": map[string]interface{}{"type": "string", "secret": true},
+		},
+		"default": map[string]interface{}{"port": 8080, "token": "s3cret"},
+	})
+	if err != nil {
+		t.Fatalf("parseJsConfigSchema() error = %v", err)
+	}
+	if _, err := parseJsConfigSchema("bad", map[string]interface{}{"type": "integer", "max": 3, "default": 5}); err == nil {
+		t.Fatal("parseJsConfigSchema() should reject a default outside the range")
+	}
+
+	defaultValue := sealpack.NormalizeConfigValue(schema.Default)
+	cm.RegisterPluginConfig("demo",
+		&ConfigItem{Key: "server", Type: "schema", Value: defaultValue, DefaultValue: defaultValue, Schema: schema},
+		&ConfigItem{Key: "mode", Type: "option", Value: "a", DefaultValue: "a", Option: []string{"a", "b"}},
+	)
+
+	if err := cm.SetConfig("demo", "server", map[string]interface{}{"port": 70000, "token": "x"}); err == nil {
+		t.Fatal("SetConfig() should reject a port above max")
+	}
+	// 旧的简单类型不做校验，保持原有行为
+	if err := cm.SetConfig("demo", "mode", "c"); err != nil {
+		t.Fatalf("SetConfig() on a legacy config error = %v", err)
+	}
+
+	masked := cm.getConfig("demo", "server").Masked()
+	if masked.Value.(map[string]interface{})["token"] != sealpack.SecretMask {
+		t.Fatalf("secret property should be masked, got %v", masked.Value)
+	}
+	// 原样提交占位值时保留原值
+	if err := cm.SetConfig("demo", "server", map[string]interface{}{"port": 9000, "token": sealpack.SecretMask}); err != nil {
+		t.Fatalf("SetConfig() error = %v", err)
+	}
+
+	reloaded := NewConfigManager(cm.filename)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	item := reloaded.getConfig("demo", "server")
+	value := item.Value.(map[string]interface{})
+	if item.Schema == nil || value["port"] != float64(9000) || value["token"] != "s3cret" {
+		t.Fatalf("unexpected stored schema config: %+v", item)
+	}
+}
+
+func TestConfigItemMaskedRecursesIntoArrays(t *testing.T) {
+	cm := NewConfigManager(filepath.Join(t.TempDir(), "plugin-configs.json"))
+	schema, err := parseJsConfigSchema("accounts", map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name":  map[string]interface{}{"type": "string"},
+				"token": map[string]interface{}{"type": "string", "secret": true},
+			},
+		},
+		"default": []interface{}{map[string]interface{}{"name": "a", "token": "t1"}},
+	})
+	if err != nil {
+		t.Fatalf("parseJsConfigSchema() error = %v", err)
+	}
+	defaultValue := sealpack.NormalizeConfigValue(schema.Default)
+	cm.RegisterPluginConfig("demo", &ConfigItem{Key: "accounts", Type: "schema", Value: defaultValue, DefaultValue: defaultValue, Schema: schema})
+
+	masked := cm.getConfig("demo", "accounts").Masked()
+	first := masked.Value.([]interface{})[0].(map[string]interface{})
+	if first["token"] != sealpack.SecretMask || first["name"] != "a" {
+		t.Fatalf("secret inside array should be masked, got %v", masked.Value)
+	}
+	if err := cm.SetConfig("demo", "accounts", []interface{}{
+		map[string]interface{}{"name": "b", "token": sealpack.SecretMask},
+		map[string]interface{}{"name": "c", "token": "t2"},
+	}); err != nil {
+		t.Fatalf("SetConfig() error = %v", err)
+	}
+	value := cm.getConfig("demo", "accounts").Value.([]interface{})
+	if value[0].(map[string]interface{})["token"] != "t1" || value[1].(map[string]interface{})["token"] != "t2" {
+		t.Fatalf("masked array element should keep the stored secret, got %v", value)
+	}
+}
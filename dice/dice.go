@@ -92,6 +92,8 @@ type ExtInfo struct {
 
 	// 定时任务列表，用于避免 task 失去引用
 	taskList []*JsScriptTask `json:"-" yaml:"-"`
+	// JS 执行统计，由 watchdog 更新
+	execStats jsExecStats `yaml:"-"`
 
 	OnNotCommandReceived func(ctx *MsgContext, msg *Message)                        `jsbind:"onNotCommandReceived" json:"-" yaml:"-"` // 指令过滤后剩下的
 	OnCommandOverride    func(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs) bool `json:"-"                      yaml:"-"`          // 覆盖指令行为
@@ -244,6 +246,15 @@ type Dice struct {
 	JsBuiltinDigestSet map[string]bool `json:"-" yaml:"-"`
 	// 当前在加载的脚本路径，用于关联 jsScriptInfo 和 ExtInfo
 	JsLoadingScript *JsScriptInfo `json:"-" yaml:"-"`
+	// 扩展包脚本沙箱，随 JsInit 重建
+	jsSandboxes *jsPackageSandboxes
+	// 扩展间通信总线，随 JsInit 重建
+	jsIPC *jsIPCBus
+	// require 使用的模块路径 -> 所属脚本文件，用于单独重载时回收资源
+	jsModuleOwners *SyncMap[string, string]
+	// 脚本目录监视的停止信号，未在监视时为 nil
+	jsScriptWatchStop chan struct{}
+	jsScriptWatchLock sync.Mutex
 
 	// 游戏系统规则模板
 	GameSystemMap *SyncMap[string, *GameSystemTemplate] `json:"-" yaml:"-"`
@@ -464,6 +475,8 @@ func (d *Dice) Init(operator engine.DatabaseOperator, uiWriter *logger.UIWriter)
 	} else {
 		loggerInstance.Info("js扩展支持已关闭，跳过js脚本的加载")
 	}
+	// 开发模式的脚本目录监视
+	d.ApplyJsScriptWatch()
 
 	if d.Config.UpgradeWindowID != "" {
 		go func() {
@@ -511,6 +524,7 @@ func (d *Dice) Init(operator engine.DatabaseOperator, uiWriter *logger.UIWriter)
 	}
 
 	d.ResetQuitInactiveCron()
+	d.ResetLogRetentionCron()
 
 	d.MarkModified()
 }
@@ -889,11 +903,26 @@ func (d *Dice) GameSystemTemplateAddEx(tmpl *GameSystemTemplate, overwrite bool)
 	if !exists || overwrite {
 		tmpl.Init()
 		d.GameSystemMap.Store(tmpl.Name, tmpl)
+		d.warnCheckCmdCollisions(tmpl)
 		return true
 	}
 	return false
 }
 
+// warnCheckCmdCollisions 检定指令与扩展指令同名时给出提示，启用该规则的群中检定指令优先
+func (d *Dice) warnCheckCmdCollisions(tmpl *GameSystemTemplate) {
+	if d.Logger == nil {
+		return
+	}
+	for _, cmd := range sheetSortedKeys(tmpl.checkCmdMap) {
+		for _, ext := range d.ExtList {
+			if ext.GetCmdMap()[cmd] != nil {
+				d.Logger.Warnf("规则模板 %s 的检定指令 %s 与扩展 %s 的指令同名，启用该规则的群中优先使用检定指令", tmpl.Name, cmd, ext.Name)
+			}
+		}
+	}
+}
+
 // GameSystemTemplateAdd 应用一个角色模板，当已存在时返回false
 func (d *Dice) GameSystemTemplateAdd(tmpl *GameSystemTemplate) bool {
 	return d.GameSystemTemplateAddEx(tmpl, false)
@@ -1186,4 +1215,7 @@ func (d *Dice) PackageSetup() {
 	if err := d.PackageManager.Init(); err != nil {
 		d.Logger.Errorf("初始化扩展包管理器失败: %v", err)
 	}
+	if d.Cron != nil {
+		_, _ = d.Cron.AddFunc("@every 6h", d.PackageManager.checkUpdatesTask)
+	}
 }
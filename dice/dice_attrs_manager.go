@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	ds "github.com/sealdice/dicescript"
@@ -23,6 +24,8 @@ type AttrsManager struct {
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
 	m      SyncMap[string, *AttributesItem]
+
+	historyMu sync.Mutex // 保证修改记录按指令顺序写入
 }
 
 func (am *AttrsManager) Stop() {
@@ -33,11 +36,25 @@ func (am *AttrsManager) Stop() {
 
 // LoadByCtx 获取当前角色，如有绑定，则获取绑定的角色，若无绑定，获取群内默认卡
 func (am *AttrsManager) LoadByCtx(ctx *MsgContext) (*AttributesItem, error) {
+	if ctx.AttrsSheetID != "" {
+		i, err := am.LoadById(ctx.AttrsSheetID)
+		if err != nil || !ctx.attrsSheetReadOnly {
+			ctx.trackAttrsHistory(i)
+			return i, err
+		}
+		// 只读引用的卡给出副本，检定等指令中的改动不会写回
+		return i.snapshot(), nil
+	}
+	var i *AttributesItem
+	var err error
 	// 如果是兼容性测试环境，跳过绑定查询以避免不必要的数据库操作
 	if ctx.IsCompatibilityTest {
-		return am.LoadByIdDirect(ctx.Group.GroupID, ctx.Player.UserID)
+		i, err = am.LoadByIdDirect(ctx.Group.GroupID, ctx.Player.UserID)
+	} else {
+		i, err = am.Load(ctx.Group.GroupID, ctx.Player.UserID)
 	}
-	return am.Load(ctx.Group.GroupID, ctx.Player.UserID)
+	ctx.trackAttrsHistory(i)
+	return i, err
 }
 
 func (am *AttrsManager) Load(groupId string, userId string) (*AttributesItem, error) {
@@ -105,6 +122,12 @@ func (am *AttrsManager) CharDelete(id string) error {
 	if err := service.AttrsDeleteById(am.db, id); err != nil {
 		return err
 	}
+	if err := service.AttrsHistoryDeleteByAttrsID(am.db, id); err != nil {
+		am.logger.Warnf("删除角色的修改记录失败: %v", err)
+	}
+	if err := service.AttrsShareDeleteByAttrsID(am.db, id); err != nil {
+		am.logger.Warnf("删除角色的共享记录失败: %v", err)
+	}
 	// 从缓存中删除
 	am.m.Delete(id)
 	return nil
@@ -143,6 +166,9 @@ func (am *AttrsManager) LoadById(id string) (*AttributesItem, error) {
 					SheetType:    data.SheetType,
 					LastUsedTime: time.Now().Unix(),
 					IsSaved:      true,
+
+					historyBase:      data.Data,
+					historySheetType: data.SheetType,
 				}
 				am.m.Store(id, i)
 				return i, nil
@@ -352,6 +378,11 @@ type AttributesItem struct {
 	IsSaved          bool
 	Name             string
 	SheetType        string
+
+	// 上次记录修改历史时的卡数据，下一次修改以此为修改前的状态
+	historyBase      []byte
+	historySheetType string
+	historyDirty     atomic.Bool
 }
 
 func (i *AttributesItem) SaveToDB(db engine.DatabaseOperator) {
@@ -396,11 +427,13 @@ func (i *AttributesItem) LoadX(name string) (*ds.VMValue, bool) {
 func (i *AttributesItem) Delete(name string) {
 	i.valueMap.Delete(name)
 	i.LastModifiedTime = time.Now().Unix()
+	i.historyDirty.Store(true)
 }
 
 func (i *AttributesItem) SetModified() {
 	i.LastModifiedTime = time.Now().Unix()
 	i.IsSaved = false
+	i.historyDirty.Store(true)
 }
 
 func (i *AttributesItem) Store(name string, value *ds.VMValue) {
@@ -409,6 +442,7 @@ func (i *AttributesItem) Store(name string, value *ds.VMValue) {
 	i.LastModifiedTime = now
 	i.LastUsedTime = now
 	i.IsSaved = false
+	i.historyDirty.Store(true)
 }
 
 func (i *AttributesItem) Clear() int {
@@ -416,6 +450,7 @@ func (i *AttributesItem) Clear() int {
 	i.valueMap.Clear()
 	i.LastModifiedTime = time.Now().Unix()
 	i.IsSaved = false
+	i.historyDirty.Store(true)
 	return size
 }
 
@@ -457,6 +492,7 @@ func (i *AttributesItem) SetSheetType(system string) {
 	i.SheetType = system
 	i.LastModifiedTime = time.Now().Unix()
 	i.IsSaved = false
+	i.historyDirty.Store(true)
 }
 
 func (i *AttributesItem) Len() int {
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/alexmullins/zip"
+	"go.uber.org/zap"
 
 	"sealdice-core/dice/service"
 	"sealdice-core/logger"
@@ -42,13 +43,14 @@ const (
 )
 
 type backupConfigGlobal struct {
-	Global  bool                         `json:"global"`
-	Decks   bool                         `json:"decks"`
-	HelpDoc bool                         `json:"helpDoc"`
-	Censor  bool                         `json:"censor"`
-	Names   bool                         `json:"names"`
-	Images  bool                         `json:"images"`
-	Dices   map[string]*backupConfigDice `json:"dices"`
+	Global   bool                         `json:"global"`
+	Decks    bool                         `json:"decks"`
+	HelpDoc  bool                         `json:"helpDoc"`
+	Censor   bool                         `json:"censor"`
+	Names    bool                         `json:"names"`
+	Images   bool                         `json:"images"`
+	LogMedia bool                         `json:"logMedia"` // 日志中的图片与文件
+	Dices    map[string]*backupConfigDice `json:"dices"`
 }
 
 type backupConfigDice struct {
@@ -69,9 +71,10 @@ const (
 	BackupSelectionCensor
 	BackupSelectionNames
 	BackupSelectionImages
+	BackupSelectionLogMedia
 
 	BackupSelectionBasic     BackupSelection = 0
-	BackupSelectionResources BackupSelection = BackupSelectionImages
+	BackupSelectionResources BackupSelection = BackupSelectionImages | BackupSelectionLogMedia
 	BackupSelectionAll       BackupSelection = BackupSelectionBasic |
 		BackupSelectionJS |
 		BackupSelectionDecks |
@@ -83,7 +86,17 @@ const (
 
 func (dm *DiceManager) Backup(sel BackupSelection, fromAuto bool) (string, error) {
 	_ = os.MkdirAll(BackupDir, 0o755)
-	logger := dm.Dice[0].Logger
+	// 升级前备份时骰子尚未 Init，日志、数据目录与数据库都可能还没有设置
+	logger := logger.M()
+	if len(dm.Dice) > 0 && dm.Dice[0].Logger != nil {
+		logger = dm.Dice[0].Logger
+	}
+	diceLogger := func(d *Dice) *zap.SugaredLogger {
+		if d != nil && d.Logger != nil {
+			return d.Logger
+		}
+		return logger
+	}
 
 	cfgGlb := backupConfigGlobal{
 		Global: true,
@@ -129,11 +142,7 @@ func (dm *DiceManager) Backup(sel BackupSelection, fromAuto bool) (string, error
 	backup := func(d *Dice, fn string) {
 		file, err := os.Open(fn)
 		if err != nil && !strings.Contains(fn, "session.token") {
-			if d != nil {
-				d.Logger.Errorf("备份文件失败: %s, 原因: %s", fn, err.Error())
-			} else {
-				logger.Errorf("备份文件失败: %s, 原因: %s", fn, err.Error())
-			}
+			diceLogger(d).Errorf("备份文件失败: %s, 原因: %s", fn, err.Error())
 			return
 		}
 		defer file.Close()
@@ -141,21 +150,13 @@ func (dm *DiceManager) Backup(sel BackupSelection, fromAuto bool) (string, error
 		h := &zip.FileHeader{Name: fn, Method: zip.Deflate, Flags: 0x800}
 		fileWriter, err := writer.CreateHeader(h)
 		if err != nil {
-			if d != nil {
-				d.Logger.Errorf("备份文件失败: %s, 原因: %s", fn, err.Error())
-			} else {
-				logger.Errorf("备份文件失败: %s, 原因: %s", fn, err.Error())
-			}
+			diceLogger(d).Errorf("备份文件失败: %s, 原因: %s", fn, err.Error())
 			return
 		}
 
 		_, err = io.Copy(fileWriter, file)
 		if err != nil {
-			if d != nil {
-				d.Logger.Errorf("备份文件失败: %s, 原因: %s", fn, err.Error())
-			} else {
-				logger.Errorf("备份文件失败: %s, 原因: %s", fn, err.Error())
-			}
+			diceLogger(d).Errorf("备份文件失败: %s, 原因: %s", fn, err.Error())
 		}
 	}
 
@@ -222,12 +223,27 @@ func (dm *DiceManager) Backup(sel BackupSelection, fromAuto bool) (string, error
 		}
 	}
 
+	if sel&BackupSelectionLogMedia != 0 && dm.LogMediaStore != nil {
+		// 附件目录在首次记录图片前不存在，此时无需备份
+		if dirOK(dm.LogMediaStore.Dir) {
+			cfgGlb.LogMedia = true
+			_ = filepath.Walk(dm.LogMediaStore.Dir, backupDir)
+		}
+	}
+
 	withJS := sel&BackupSelectionJS != 0
 	cfgDice.JSScripts = withJS
 
 	for _, d := range dm.Dice {
 		cfgGlb.Dices[d.BaseConfig.Name] = &cfgDice
 		dataDir := d.BaseConfig.DataDir
+		if dataDir == "" {
+			dataDir = filepath.Join("./data", d.BaseConfig.Name)
+		}
+		dbOperator := d.DBOperator
+		if dbOperator == nil {
+			dbOperator = dm.Operator
+		}
 
 		backup(d, filepath.Join(dataDir, "serve.yaml"))
 		if fn := filepath.Join(dataDir, "advanced.yaml"); fileOK(fn) {
@@ -237,30 +253,41 @@ func (dm *DiceManager) Backup(sel BackupSelection, fromAuto bool) (string, error
 			backup(d, fn)
 		}
 
-		err := service.FlushWAL(d.DBOperator.GetDataDB(constant.WRITE))
+		// database 段中单独配置了库文件路径时以配置为准
+		dbFile := func(configured, filename string) string {
+			if configured != "" && dbOperator.Type() == constant.SQLITE {
+				return filepath.Clean(configured)
+			}
+			return filepath.Join(dataDir, filename)
+		}
+		err := service.FlushWAL(dbOperator.GetDataDB(constant.WRITE))
 		if err != nil {
-			d.Logger.Errorf("备份时data数据库flush出错 错误为:%v", err.Error())
+			diceLogger(d).Errorf("备份时data数据库flush出错 错误为:%v", err.Error())
 		} else {
-			backup(d, filepath.Join(dataDir, "data.db"))
+			backup(d, dbFile(dm.DatabaseConfig.DataDSN, "data.db"))
 		}
-		err = service.FlushWAL(d.DBOperator.GetLogDB(constant.WRITE))
+		err = service.FlushWAL(dbOperator.GetLogDB(constant.WRITE))
 		if err != nil {
-			d.Logger.Errorf("备份时logs数据库flush出错 错误为:%v", err.Error())
+			diceLogger(d).Errorf("备份时logs数据库flush出错 错误为:%v", err.Error())
 		} else {
-			backup(d, filepath.Join(dataDir, "data-logs.db"))
+			backup(d, dbFile(dm.DatabaseConfig.LogDSN, "data-logs.db"))
 		}
 		if d.CensorManager != nil && d.CensorManager.DB != nil {
-			err = service.FlushWAL(d.DBOperator.GetCensorDB(constant.WRITE))
+			err = service.FlushWAL(dbOperator.GetCensorDB(constant.WRITE))
 			if err != nil {
-				d.Logger.Errorf("备份时censor数据库flush出错 %v", err.Error())
+				diceLogger(d).Errorf("备份时censor数据库flush出错 %v", err.Error())
 			} else {
-				backup(d, filepath.Join(dataDir, "data-censor.db"))
+				backup(d, dbFile(dm.DatabaseConfig.CensorDSN, "data-censor.db"))
 			}
 		}
 
 		backup(d, filepath.Join(dataDir, "configs/text-template.yaml"))
 
-		_ = filepath.WalkDir(filepath.Join(dataDir, "extensions/reply"), func(path string, info fs.DirEntry, _ error) error {
+		_ = filepath.WalkDir(filepath.Join(dataDir, "extensions/reply"), func(path string, info fs.DirEntry, err error) error {
+			// 升级前备份时目录可能尚未创建
+			if err != nil {
+				return nil //nolint:nilerr
+			}
 			// NOTE(Xiangze Li): copied from dice.ReplyReload. Should extract as function, but I'm lazy
 			if info.IsDir() {
 				if strings.EqualFold(info.Name(), "assets") || strings.EqualFold(info.Name(), "images") {
@@ -279,7 +306,11 @@ func (dm *DiceManager) Backup(sel BackupSelection, fromAuto bool) (string, error
 			return nil
 		})
 
-		for _, i := range d.ImSession.EndPoints {
+		var endpoints []*EndPointInfo
+		if d.ImSession != nil {
+			endpoints = d.ImSession.EndPoints
+		}
+		for _, i := range endpoints {
 			if i.Platform == "QQ" {
 				if pa, ok := i.Adapter.(*PlatformAdapterGocq); ok && pa.UseInPackClient {
 					workDir := i.RelWorkDir
@@ -297,7 +328,10 @@ func (dm *DiceManager) Backup(sel BackupSelection, fromAuto bool) (string, error
 		}
 
 		if withJS {
-			_ = filepath.WalkDir(filepath.Join(dataDir, "scripts"), func(path string, info fs.DirEntry, _ error) error {
+			_ = filepath.WalkDir(filepath.Join(dataDir, "scripts"), func(path string, info fs.DirEntry, err error) error {
+				if err != nil {
+					return nil //nolint:nilerr
+				}
 				if info.IsDir() {
 					if info.Name() == "_builtin" {
 						return filepath.SkipDir
@@ -311,6 +345,9 @@ func (dm *DiceManager) Backup(sel BackupSelection, fromAuto bool) (string, error
 			})
 			extDataDir := filepath.Join(dataDir, "extensions")
 			_ = filepath.WalkDir(extDataDir, func(path string, info fs.DirEntry, err error) error {
+				if err != nil {
+					return nil //nolint:nilerr
+				}
 				if info.IsDir() {
 					if filepath.Dir(path) == extDataDir {
 						if ext := d.ExtFind(info.Name(), false); ext == nil || !ext.IsJsExt {
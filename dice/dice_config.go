@@ -8,6 +8,8 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"sealdice-core/dice/censor"
+	"sealdice-core/dice/sealpack"
+	"sealdice-core/dice/storylog"
 	"sealdice-core/utils"
 )
 
@@ -45,6 +47,8 @@ type Config struct {
 	PublicDiceConfig `yaml:",inline"`
 	// 商店设置
 	StoreConfig `yaml:",inline"`
+	// 扩展包设置
+	PackageConfig `yaml:",inline"`
 
 	// 其它设置，包含由于被导出无法从 Dice 上迁移过来的配置项，为了在 DefaultConfig 上统一设置默认值增加此结构
 	DirtyConfig `yaml:",inline"`
@@ -213,11 +217,19 @@ type BanConfig struct {
 type JsConfig struct {
 	JsEnable          bool            `json:"jsEnable"          yaml:"jsEnable"`
 	DisabledJsScripts map[string]bool `json:"disabledJsScripts" yaml:"disabledJsScripts"` // 作为set
+
+	JsExecTimeout         int64            `json:"jsExecTimeout"         yaml:"jsExecTimeout"`         // 扩展单次执行指令、事件回调或定时任务的时间预算(毫秒)，0 为不限制
+	JsExtExecTimeouts     map[string]int64 `json:"jsExtExecTimeouts"     yaml:"jsExtExecTimeouts"`     // 按扩展名单独设置的时间预算(毫秒)
+	JsOverrunDisableCount int              `json:"jsOverrunDisableCount" yaml:"jsOverrunDisableCount"` // 扩展累计超时达到此次数后自动停用，0 为不停用
+	JsScriptWatchEnable   bool             `json:"jsScriptWatchEnable"   yaml:"jsScriptWatchEnable"`   // 开发用：监视 scripts 目录，文件变化时单独重载脚本
 }
 
 type StoryLogConfig struct {
 	LogSizeNoticeEnable bool `json:"logSizeNoticeEnable" yaml:"logSizeNoticeEnable"` // 开启日志数量提示
 	LogSizeNoticeCount  int  `json:"logSizeNoticeCount"  yaml:"LogSizeNoticeCount"`  // 日志数量提示阈值，默认500
+
+	LogRetention LogRetentionConfig   `json:"-" yaml:"logRetention"` // 日志保留策略，通过单独的接口修改
+	LogMedia     storylog.MediaConfig `json:"-" yaml:"logMedia"`     // 日志附件，通过单独的接口修改
 }
 
 type MailConfig struct {
@@ -261,3 +273,9 @@ type StoreConfig struct {
 	BackendUrls         []string `json:"backendUrls" yaml:"backendUrls"`
 	DisabledBackendUrls []string `json:"disabledBackendUrls" yaml:"disabledBackendUrls"`
 }
+
+type PackageConfig struct {
+	PackageTrustedKeys         []sealpack.TrustedKey `json:"packageTrustedKeys"         yaml:"packageTrustedKeys"`         // 受信任的作者/商店公钥
+	PackageRequireSigned       bool                  `json:"packageRequireSigned"       yaml:"packageRequireSigned"`       // 拒绝安装未签名的扩展包
+	PackageUpdateCheckDisabled bool                  `json:"packageUpdateCheckDisabled" yaml:"packageUpdateCheckDisabled"` // 关闭定时检查扩展包更新
+}
@@ -6,6 +6,8 @@ import (
 	"golang.org/x/time/rate"
 
 	"sealdice-core/dice/censor"
+	"sealdice-core/dice/sealpack"
+	"sealdice-core/dice/storylog"
 )
 
 var DefaultConfig = Config{
@@ -76,10 +78,24 @@ var DefaultConfig = Config{
 	JsConfig{
 		JsEnable:          true,
 		DisabledJsScripts: make(map[string]bool),
+
+		JsExecTimeout:         5000,
+		JsExtExecTimeouts:     make(map[string]int64),
+		JsOverrunDisableCount: 5,
+		JsScriptWatchEnable:   false,
 	},
 	StoryLogConfig{
 		LogSizeNoticeEnable: true,
 		LogSizeNoticeCount:  500,
+		LogRetention: LogRetentionConfig{
+			Enable: false,
+			Cron:   "0 4 * * *",
+		},
+		LogMedia: storylog.MediaConfig{
+			Enable:    false,
+			Files:     false,
+			MaxSizeMB: 20,
+		},
 	},
 	MailConfig{
 		MailEnable:   false,
@@ -107,6 +123,11 @@ var DefaultConfig = Config{
 		BackendUrls:         []string{},
 		DisabledBackendUrls: []string{},
 	},
+	PackageConfig{
+		PackageTrustedKeys:         []sealpack.TrustedKey{},
+		PackageRequireSigned:       false,
+		PackageUpdateCheckDisabled: false,
+	},
 	DirtyConfig{
 		DeckList: nil,
 		CommandPrefix: []string{
@@ -421,83 +421,104 @@ func (m *HelpManager) SaveHelpConfig(config *HelpConfig) error {
 func (m *HelpManager) loadHelpDoc(group string, path string) bool {
 	log := logger.M()
 	fileExt := filepath.Ext(path)
+	if fileExt != ".json" && fileExt != ".xlsx" {
+		return false
+	}
+
+	m.LoadingFn = path
+	items, err := parseHelpDocFile(group, path)
+	if err != nil && fileExt == ".xlsx" {
+		log.Error("HelpManager.loadHelpDoc", err)
+		if items == nil {
+			return false
+		}
+	}
+	for _, item := range items {
+		_ = m.AddItem(item)
+	}
+	return true
+}
 
-	switch fileExt {
+// parseHelpDocFile 解析 json 或 xlsx(梨骰格式) 帮助文档。
+// 文件无法读取时返回 nil 词条；xlsx 部分工作表表头有误时返回其余词条，并在 error 中说明。
+func parseHelpDocFile(group string, path string) ([]docengine.HelpTextItem, error) {
+	switch filepath.Ext(path) {
 	case ".json":
-		m.LoadingFn = path
 		data := HelpDocFormat{}
 		pack, err := os.ReadFile(path)
-		if err == nil {
-			err = json.Unmarshal(pack, &data)
-			if err == nil {
-				for k, v := range data.Helpdoc {
-					_ = m.AddItem(docengine.HelpTextItem{
-						Group:       group,
-						From:        path,
-						Title:       k,
-						Content:     v,
-						PackageName: data.Mod,
-					})
-				}
-			}
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(pack, &data); err != nil {
+			return nil, err
+		}
+		items := make([]docengine.HelpTextItem, 0, len(data.Helpdoc))
+		for k, v := range data.Helpdoc {
+			items = append(items, docengine.HelpTextItem{
+				Group:       group,
+				From:        path,
+				Title:       k,
+				Content:     v,
+				PackageName: data.Mod,
+			})
 		}
-		return true
+		return items, nil
 	case ".xlsx":
-		// 梨骰帮助文件
-		m.LoadingFn = path
 		f, err := excelize.OpenFile(path)
 		if err != nil {
-			log.Error("HelpManager.loadHelpDoc", err)
-			break
+			return nil, err
 		}
+		// Close the spreadsheet.
+		defer func() {
+			if err := f.Close(); err != nil {
+				logger.M().Error("HelpManager.loadHelpDoc", err)
+			}
+		}()
 
+		items := make([]docengine.HelpTextItem, 0)
+		var sheetErrs []error
 		for index, s := range f.GetSheetList() {
 			rows, err := f.GetRows(s)
-			if err == nil {
-				var synonymCount int
-				for i, row := range rows {
-					if i == 0 {
-						synonymCount, err = validateXlsxHeaders(row)
-						if err == nil {
-							// 跳过第一行
-							continue
-						} else {
-							log.Errorf("%s sheet %d(zero-based): %s\n", path, index, err)
-							break
-						}
-					}
-					if len(row) < 3 {
+			if err != nil {
+				continue
+			}
+			var synonymCount int
+			for i, row := range rows {
+				if i == 0 {
+					synonymCount, err = validateXlsxHeaders(row)
+					if err == nil {
+						// 跳过第一行
 						continue
 					}
-					var keyBuilder strings.Builder
-					keyBuilder.WriteString(row[0])
-					for j := range synonymCount {
-						if len(row[1+j]) > 0 {
-							keyBuilder.WriteString("/")
-							keyBuilder.WriteString(row[1+j])
-						}
+					sheetErrs = append(sheetErrs, fmt.Errorf("%s sheet %d(zero-based): %w", path, index, err))
+					break
+				}
+				if len(row) < 3 {
+					continue
+				}
+				var keyBuilder strings.Builder
+				keyBuilder.WriteString(row[0])
+				for j := range synonymCount {
+					if len(row[1+j]) > 0 {
+						keyBuilder.WriteString("/")
+						keyBuilder.WriteString(row[1+j])
 					}
-					key := keyBuilder.String()
-					content := row[synonymCount+1]
-
-					_ = m.AddItem(docengine.HelpTextItem{
-						Group:       group,
-						From:        path,
-						Title:       key,
-						Content:     content,
-						PackageName: s,
-					})
 				}
+				key := keyBuilder.String()
+				content := row[synonymCount+1]
+
+				items = append(items, docengine.HelpTextItem{
+					Group:       group,
+					From:        path,
+					Title:       key,
+					Content:     content,
+					PackageName: s,
+				})
 			}
 		}
-
-		// Close the spreadsheet.
-		if err := f.Close(); err != nil {
-			log.Error("HelpManager.loadHelpDoc", err)
-		}
-		return true
+		return items, errors.Join(sheetErrs...)
 	}
-	return false
+	return nil, fmt.Errorf("不支持的帮助文档格式: %s", path)
 }
 
 // validateXlsxHeaders 验证 xlsx 格式 helpdoc 的表头是否是 Key Synonym（可能有多列） Content Description Catalogue Tag
@@ -31,6 +31,7 @@ import (
 	"go.uber.org/zap"
 	"gopkg.in/elazarl/goproxy.v1"
 
+	"sealdice-core/dice/sealpack"
 	"sealdice-core/static"
 	"sealdice-core/utils/crypto"
 
@@ -88,7 +89,9 @@ func (d *Dice) JsInit() {
 	d.jsClear()
 
 	// 重建js vm
-	reg := new(require.Registry)
+	// 扩展包内的脚本经由 sourceLoader 注入沙箱前置代码
+	d.jsSandboxes = newJsPackageSandboxes(d)
+	reg := require.NewRegistry(require.WithLoader(d.jsSandboxes.sourceLoader))
 
 	loop := eventloop.NewEventLoop(eventloop.EnableConsole(false),
 		eventloop.WithRegistry(reg),
@@ -96,6 +99,8 @@ func (d *Dice) JsInit() {
 		eventloop.WithLogger(d.Logger))
 	_ = fetch.Enable(loop, goproxy.NewProxyHttpServer())
 	versionID := d.ExtLoopManager.SetLoop(loop)
+	// 扩展间通信总线与 loop 同生命周期
+	d.jsIPC = newJsIPCBus(d, loop)
 
 	printer := &PrinterFunc{d, false, []string{}}
 	d.JsPrinter = printer
@@ -106,6 +111,9 @@ func (d *Dice) JsInit() {
 	d.JsScriptCron.Start()
 	// 单独给WebSocket一个Logger
 	sealws.SetLogger(d.Logger)
+	// 按调用栈记录连接所属的脚本，单独重载脚本时关闭
+	d.jsModuleOwners = new(SyncMap[string, string])
+	sealws.OwnerResolver = d.jsScriptOwner
 	// 关闭之前的所有WebSocket
 	sealws.GlobalConnManager.CloseAll()
 	// 初始化
@@ -118,6 +126,7 @@ func (d *Dice) JsInit() {
 		sealws.Enable(vm, loop)
 		// require 模块
 		reg.Enable(vm)
+		d.jsSandboxes.enable(vm, loop)
 
 		seal := vm.NewObject()
 
@@ -350,6 +359,28 @@ func (d *Dice) JsInit() {
 			d.ConfigManager.RegisterPluginConfig(ei.Name, config)
 			return nil
 		})
+		_ = ext.Set("registerSchemaConfig", func(ei *ExtInfo, key string, schemaDef map[string]interface{}, group string) error {
+			if ei.dice == nil {
+				return errors.New("请先完成此扩展的注册")
+			}
+			schema, err := parseJsConfigSchema(key, schemaDef)
+			if err != nil {
+				return err
+			}
+			defaultValue := sealpack.NormalizeConfigValue(schema.Default)
+			schema.Default = defaultValue
+			config := &ConfigItem{
+				Key:          key,
+				Type:         "schema",
+				Group:        group,
+				Value:        defaultValue,
+				DefaultValue: defaultValue,
+				Description:  schema.Description,
+				Schema:       schema,
+			}
+			d.ConfigManager.RegisterPluginConfig(ei.Name, config)
+			return nil
+		})
 		_ = ext.Set("newConfigItem", func(ei *ExtInfo, key string, defaultValue interface{}, description string) *ConfigItem {
 			if ei.dice == nil {
 				panic(errors.New("请先完成此扩展的注册"))
@@ -405,6 +436,16 @@ func (d *Dice) JsInit() {
 			}
 			return d.ConfigManager.getConfig(ei.Name, key).Value.(string)
 		})
+		_ = ext.Set("getSchemaConfig", func(ei *ExtInfo, key string) interface{} {
+			if ei.dice == nil {
+				panic("配置不存在或类型不匹配")
+			}
+			config := d.ConfigManager.getConfig(ei.Name, key)
+			if config == nil || config.Type != "schema" {
+				panic("配置不存在或类型不匹配")
+			}
+			return config.Value
+		})
 		_ = ext.Set("unregisterConfig", func(ei *ExtInfo, key ...string) {
 			if ei.dice == nil {
 				return
@@ -421,7 +462,7 @@ func (d *Dice) JsInit() {
 				panic(errors.New("插件cron未成功初始化")) // 按理是不会发生的
 			}
 
-			task := JsScriptTask{cron: scriptCron, key: key, task: fn, lock: ei.dice.JsScriptCronLock, logger: ei.dice.Logger}
+			task := JsScriptTask{cron: scriptCron, key: key, task: fn, lock: ei.dice.JsScriptCronLock, logger: ei.dice.Logger, ext: ei, vm: vm}
 			expr := value
 			if key != "" {
 				if config := d.ConfigManager.getConfig(ei.Name, key); config != nil {
@@ -626,6 +667,7 @@ func (d *Dice) JsInit() {
 		// 1.2新增结束
 		_ = seal.Set("setPlayerGroupCard", SetPlayerGroupCardByTemplate)
 		_ = seal.Set("base64ToImage", Base64ToImageFunc())
+		_ = seal.Set("ipc", d.jsIPC.newJsIPCObject(vm, ""))
 
 		// Note: Szzrain 暴露dice对象给js会导致js可以调用dice的所有Export的方法
 		// 这是不安全的, 所有需要用到dice实例的函数都可以以传入ctx作为替代
@@ -654,9 +696,13 @@ func (d *Dice) JsInit() {
 		//  }
 		// }
 		// `)
-		_, _ = vm.RunString(`Object.freeze(seal);Object.freeze(seal.deck);Object.freeze(seal.coc);Object.freeze(seal.ext);Object.freeze(seal.vars);`)
+		_, _ = vm.RunString(`Object.freeze(seal);Object.freeze(seal.deck);Object.freeze(seal.coc);Object.freeze(seal.ext);Object.freeze(seal.vars);Object.freeze(seal.ipc);`)
+		// fetch 在排队的任务中注册，移除全局对象需要排在它之后
+		loop.RunOnLoop(d.jsSandboxes.hideGlobals)
 	})
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		defer func() {
 			if r := recover(); r != nil {
 				d.Logger.Errorf("JS核心执行异常: %v 堆栈: %v", r, string(debug.Stack()))
@@ -664,8 +710,16 @@ func (d *Dice) JsInit() {
 		}()
 		loop.StartInForeground()
 	}()
+	// 等待 loop 开始运行：在此之前调用 Terminate（如随后的关闭流程）不会生效，loop 会一直运行下去
+	started := make(chan struct{})
+	loop.RunOnLoop(func(*goja.Runtime) { close(started) })
+	select {
+	case <-started:
+	case <-stopped:
+	}
 	// loop.Start()
 	(&d.Config).JsEnable = true
+	d.ApplyJsScriptWatch()
 	d.Logger.Info("已加载JS环境")
 	d.MarkModified()
 	d.Save(false)
@@ -673,6 +727,7 @@ func (d *Dice) JsInit() {
 
 func (d *Dice) JsShutdown() {
 	(&d.Config).JsEnable = false
+	d.ApplyJsScriptWatch()
 	d.jsClear()
 	d.Logger.Info("已关闭JS环境")
 	d.MarkModified()
@@ -1000,6 +1055,8 @@ type JsScriptInfo struct {
 	StoreID string `json:"storeID"`
 	/** Owning package ID */
 	PackageID string `json:"packageID,omitempty"`
+	/** 单独重载的序号，用于绕过 require 缓存 */
+	reloadSeq int64
 }
 
 type JsScriptDepends struct {
@@ -1149,8 +1206,16 @@ func (d *Dice) JsLoadScriptRaw(jsInfo *JsScriptInfo) {
 			}(targetPath)
 		} else {
 			targetPath = jsInfo.Filename
+			if jsInfo.reloadSeq > 0 {
+				// 同一路径的模块会被 require 缓存，单独重载时使用带序号的别名
+				targetPath = fmt.Sprintf("%s?reload=%d", targetPath, jsInfo.reloadSeq)
+			}
 		}
 		if err == nil {
+			if d.jsSandboxes != nil && (jsInfo.PackageID != "" || jsInfo.needCompiled) {
+				d.jsSandboxes.bindCompiledPath(targetPath, jsInfo.PackageID)
+			}
+			d.jsRecordModuleOwner(targetPath, jsInfo.Filename)
 			_, err = d.ExtLoopManager.GetWebLoop().RequireModule(targetPath)
 		}
 		d.JsLoadingScript = nil
@@ -1163,6 +1228,11 @@ func (d *Dice) JsLoadScriptRaw(jsInfo *JsScriptInfo) {
 		jsInfo.ErrText = errText
 		jsInfo.Enable = false
 		d.Logger.Error("读取脚本失败(解析失败): ", errText)
+		// 加载期间越权（如顶层 require('fs') 读取未声明路径）同样反映到包的 ErrText
+		var permErr *sealpack.PermissionError
+		if errors.As(err, &permErr) && d.PackageManager != nil {
+			d.PackageManager.RecordPermissionError(permErr)
+		}
 	}
 }
 
@@ -1246,8 +1316,13 @@ func JsDelete(d *Dice, jsInfo *JsScriptInfo) {
 
 		// 从 JsExtRegistry 移除
 		if d.JsExtRegistry != nil {
-			if realExt, ok := d.JsExtRegistry.Load(jsInfo.Name); ok && realExt != nil && realExt.Storage != nil {
-				_ = realExt.StorageClose()
+			if realExt, ok := d.JsExtRegistry.Load(jsInfo.Name); ok && realExt != nil {
+				if realExt.Storage != nil {
+					_ = realExt.StorageClose()
+				}
+				if d.jsIPC != nil {
+					d.jsIPC.removeExt(realExt)
+				}
 			}
 			d.JsExtRegistry.Delete(jsInfo.Name)
 		}
@@ -1500,6 +1575,10 @@ type JsScriptTask struct {
 	lock     *sync.Mutex
 
 	logger *zap.SugaredLogger
+
+	// 所属扩展与运行时，用于执行超时中断与统计
+	ext *ExtInfo
+	vm  *goja.Runtime
 }
 
 type JsScriptTaskCtx struct {
@@ -1519,7 +1598,16 @@ func (t *JsScriptTask) run() {
 	}
 	defer t.lock.Unlock()
 	t.lock.Lock()
-	t.task(taskCtx)
+	if t.ext == nil || t.ext.dice == nil {
+		t.task(taskCtx)
+		return
+	}
+	err := t.ext.dice.jsWatchedCall(t.vm, t.ext, "定时任务", func() {
+		t.task(taskCtx)
+	})
+	if err != nil && !errors.Is(err, ErrJsExtSuspended) {
+		t.logger.Errorf("插件定时任务异常: %v", err)
+	}
 }
 
 func (t *JsScriptTask) On() bool {
@@ -0,0 +1,367 @@
+package dice
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/parser"
+	"go.uber.org/zap"
+)
+
+//go:generate go run gen/seal-dts.go
+
+// SealDtsFilename go generate 输出的类型声明文件
+const SealDtsFilename = "seal.d.ts"
+
+// jsDtsGlobals 除 seal 之外由 JsInit 注入的全局函数
+var jsDtsGlobals = []string{"atob", "btoa"}
+
+var (
+	jsDtsTypeGojaValue  = reflect.TypeOf((*goja.Value)(nil)).Elem()
+	jsDtsTypeGojaObject = reflect.TypeOf((*goja.Object)(nil))
+	jsDtsTypeError      = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// GenerateSealDts 在临时的 JS 环境中构造 seal 对象，并根据其中的 Go 函数签名与 jsbind 标签生成 TypeScript 声明
+func GenerateSealDts() (string, error) {
+	dataDir, err := os.MkdirTemp("", "sealdice-dts-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dataDir)
+
+	d := &Dice{
+		Logger:     zap.NewNop().Sugar(),
+		BaseConfig: BaseConfig{DataDir: dataDir},
+		ImSession: &IMSession{
+			ServiceAtNew: new(SyncMap[string, *GroupInfo]),
+			EndPoints:    []*EndPointInfo{},
+		},
+		DirtyGroups:  new(SyncMap[string, int64]),
+		AttrsManager: &AttrsManager{},
+	}
+	d.JsInit()
+	defer func() {
+		if d.JsScriptCron != nil {
+			d.JsScriptCron.Stop()
+		}
+		d.ExtLoopManager.SetLoop(nil)
+	}()
+	return d.JsGenerateDts()
+}
+
+// JsGenerateDts 根据当前 JS 环境中的 seal 对象生成 TypeScript 声明
+func (d *Dice) JsGenerateDts() (string, error) {
+	if d.ExtLoopManager == nil || d.ExtLoopManager.GetWebLoop() == nil {
+		return "", errors.New("js扩展支持已关闭")
+	}
+
+	var text string
+	var err error
+	done := make(chan struct{})
+	d.ExtLoopManager.GetWebLoop().RunOnLoop(func(vm *goja.Runtime) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("生成类型声明失败: %v", r)
+			}
+			close(done)
+		}()
+		seal := d.jsSandboxes.fullSeal(vm)
+		if seal == nil {
+			err = errors.New("seal 对象不存在")
+			return
+		}
+		g := newJsDtsGenerator()
+		text = g.generate(vm, seal)
+	})
+	<-done
+	return text, err
+}
+
+type jsDtsGenerator struct {
+	// 已命名的 Go 结构体 -> 声明中的接口名
+	names map[reflect.Type]string
+	used  map[string]bool
+	// 等待输出接口的结构体
+	pending []reflect.Type
+}
+
+func newJsDtsGenerator() *jsDtsGenerator {
+	return &jsDtsGenerator{
+		names: map[reflect.Type]string{},
+		used:  map[string]bool{},
+	}
+}
+
+func (g *jsDtsGenerator) generate(vm *goja.Runtime, seal *goja.Object) string {
+	var b strings.Builder
+	b.WriteString("// Code generated by go generate in sealdice-core/dice; DO NOT EDIT.\n")
+	b.WriteString("// 由 seal 对象的 Go 绑定与 jsbind 标签生成，请勿手动修改。\n\n")
+
+	sealBody := g.objectType(seal, "  ")
+	var globals strings.Builder
+	for _, name := range jsDtsGlobals {
+		v := vm.Get(name)
+		if v == nil {
+			continue
+		}
+		if rt := reflect.TypeOf(v.Export()); rt != nil && rt.Kind() == reflect.Func {
+			fmt.Fprintf(&globals, "declare function %s%s;\n", name, g.signature(rt, ": "))
+		}
+	}
+
+	var decls []string
+	emitted := map[reflect.Type]bool{}
+	for len(g.pending) > 0 {
+		t := g.pending[0]
+		g.pending = g.pending[1:]
+		if emitted[t] {
+			continue
+		}
+		emitted[t] = true
+		decls = append(decls, g.interfaceDecl(t))
+	}
+	sort.Strings(decls)
+
+	b.WriteString("declare namespace seal {\n")
+	for i, decl := range decls {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(decl)
+	}
+	b.WriteString("}\n\n")
+	b.WriteString("declare const seal: " + sealBody + ";\n\n")
+	b.WriteString(globals.String())
+	return b.String()
+}
+
+// objectType 输出 JS 对象的类型字面量，键顺序与注册顺序一致
+func (g *jsDtsGenerator) objectType(obj *goja.Object, indent string) string {
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, key := range obj.Keys() {
+		b.WriteString(indent + g.member(key, obj.Get(key), indent))
+	}
+	b.WriteString(indent[:len(indent)-2] + "}")
+	return b.String()
+}
+
+func (g *jsDtsGenerator) member(key string, v goja.Value, indent string) string {
+	name := jsDtsPropName(key)
+	if obj, ok := v.(*goja.Object); ok {
+		exported := obj.Export()
+		if _, native := exported.(func(goja.FunctionCall) goja.Value); native {
+			return name + "(...args: any[]): any;\n"
+		}
+		rt := reflect.TypeOf(exported)
+		if rt != nil && rt.Kind() == reflect.Func {
+			return name + g.signature(rt, ": ") + ";\n"
+		}
+		if _, isFunc := goja.AssertFunction(obj); isFunc {
+			return name + "(...args: any[]): any;\n"
+		}
+		if obj.ClassName() == "Object" && rt != nil && rt.Kind() == reflect.Map {
+			return name + ": " + g.objectType(obj, indent+"  ") + ";\n"
+		}
+		return name + ": " + g.tsType(rt) + ";\n"
+	}
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return name + ": any;\n"
+	}
+	return name + ": " + g.tsType(reflect.TypeOf(v.Export())) + ";\n"
+}
+
+// signature 输出函数的参数与返回值，sep 为方法形式的 ": " 或箭头函数形式的 " => "
+func (g *jsDtsGenerator) signature(t reflect.Type, sep string) string {
+	return g.signatureFrom(t, 0, sep)
+}
+
+func (g *jsDtsGenerator) signatureFrom(t reflect.Type, first int, sep string) string {
+	params := make([]string, 0, t.NumIn())
+	seen := map[string]int{}
+	for i := first; i < t.NumIn(); i++ {
+		in := t.In(i)
+		name := jsDtsParamName(in, i-first)
+		if n := seen[name]; n > 0 {
+			name = fmt.Sprintf("%s%d", name, n+1)
+		}
+		seen[jsDtsParamName(in, i-first)]++
+		if t.IsVariadic() && i == t.NumIn()-1 {
+			params = append(params, "..."+name+": "+g.tsType(in.Elem())+"[]")
+			continue
+		}
+		params = append(params, name+": "+g.tsType(in))
+	}
+
+	var outs []string
+	for i := 0; i < t.NumOut(); i++ {
+		// goja 将最后一个 error 返回值转换为异常
+		if i == t.NumOut()-1 && t.Out(i) == jsDtsTypeError {
+			continue
+		}
+		outs = append(outs, g.tsType(t.Out(i)))
+	}
+	ret := "void"
+	switch len(outs) {
+	case 0:
+	case 1:
+		ret = outs[0]
+	default:
+		ret = "[" + strings.Join(outs, ", ") + "]"
+	}
+	return "(" + strings.Join(params, ", ") + ")" + sep + ret
+}
+
+// tsType 将 Go 类型映射为 goja 转换后的 JS 类型
+func (g *jsDtsGenerator) tsType(t reflect.Type) string {
+	if t == nil {
+		return "any"
+	}
+	switch t {
+	case jsDtsTypeGojaValue:
+		return "any"
+	case jsDtsTypeGojaObject:
+		return "object"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Ptr:
+		return g.tsType(t.Elem())
+	case reflect.Slice, reflect.Array:
+		elem := g.tsType(t.Elem())
+		if strings.Contains(elem, " ") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]"
+	case reflect.Map:
+		return "Record<string, " + g.tsType(t.Elem()) + ">"
+	case reflect.Func:
+		return "(" + g.signature(t, " => ") + ")"
+	case reflect.Struct:
+		return g.structName(t)
+	default:
+		return "any"
+	}
+}
+
+// structName 返回本项目结构体对应的接口名，外部包的结构体视为 any
+func (g *jsDtsGenerator) structName(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return "seal." + name
+	}
+	if t.Name() == "" || !strings.HasPrefix(t.PkgPath(), "sealdice-core") {
+		return "any"
+	}
+	name := t.Name()
+	if g.used[name] {
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = jsDtsUpperFirst(pkg) + name
+	}
+	g.used[name] = true
+	g.names[t] = name
+	g.pending = append(g.pending, t)
+	return "seal." + name
+}
+
+// interfaceDecl 输出结构体的接口声明：带 jsbind 标签的字段，以及指针类型上的导出方法（首字母小写）
+func (g *jsDtsGenerator) interfaceDecl(t reflect.Type) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "  interface %s {\n", g.names[t])
+
+	fields := map[string]bool{}
+	g.writeFields(&b, t, fields)
+
+	pt := reflect.PointerTo(t)
+	for i := 0; i < pt.NumMethod(); i++ {
+		m := pt.Method(i)
+		name := strings.ToLower(m.Name[:1]) + m.Name[1:]
+		if fields[name] {
+			continue
+		}
+		fmt.Fprintf(&b, "    %s%s;\n", jsDtsPropName(name), g.signatureFrom(m.Type, 1, ": "))
+	}
+	b.WriteString("  }\n")
+	return b.String()
+}
+
+func (g *jsDtsGenerator) writeFields(b *strings.Builder, t reflect.Type, fields map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && f.Tag.Get("jsbind") == "" {
+				g.writeFields(b, ft, fields)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Tag.Get("jsbind")
+		if idx := strings.IndexByte(name, ','); idx != -1 {
+			name = name[:idx]
+		}
+		if !parser.IsIdentifier(name) || fields[name] {
+			continue
+		}
+		fields[name] = true
+		fmt.Fprintf(b, "    %s: %s;\n", name, g.tsType(f.Type))
+	}
+}
+
+// jsDtsParamName 反射拿不到参数名，按类型取一个可读的名字
+func jsDtsParamName(t reflect.Type, index int) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Name() {
+	case "MsgContext":
+		return "ctx"
+	case "Message":
+		return "msg"
+	case "CmdArgs":
+		return "cmdArgs"
+	case "ExtInfo":
+		return "ext"
+	}
+	return fmt.Sprintf("arg%d", index)
+}
+
+// jsDtsPropName 保留字与非标识符的属性名需要加引号
+func jsDtsPropName(name string) string {
+	if parser.IsIdentifier(name) && !jsDtsReserved[name] {
+		return name
+	}
+	return fmt.Sprintf("%q", name)
+}
+
+func jsDtsUpperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// jsDtsReserved 作为类型字面量成员名时有特殊含义的关键字
+var jsDtsReserved = map[string]bool{
+	"new": true, "delete": true, "function": true, "class": true, "default": true,
+}
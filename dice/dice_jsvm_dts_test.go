@@ -0,0 +1,50 @@
+package dice //nolint:testpackage
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+func TestSealDtsUpToDate(t *testing.T) {
+	text, err := GenerateSealDts()
+	if err != nil {
+		t.Fatalf("GenerateSealDts: %v", err)
+	}
+	committed, err := os.ReadFile(SealDtsFilename)
+	if err != nil {
+		t.Fatalf("read %s: %v", SealDtsFilename, err)
+	}
+	if string(committed) != text {
+		t.Fatalf("%s 与 JS 绑定不一致，请在 dice 目录执行 go generate 重新生成", SealDtsFilename)
+	}
+}
+
+func TestJsDtsGeneratorCoversBindings(t *testing.T) {
+	vm := goja.New()
+	vm.SetFieldNameMapper(goja.TagFieldNameMapper("jsbind", true))
+	seal := vm.NewObject()
+	sub := vm.NewObject()
+	_ = seal.Set("sub", sub)
+	_ = sub.Set("new", func(_ string) *ExtInfo { return nil })
+	_ = seal.Set("roll", func(_ *MsgContext, _ string, _ ...int) (int64, error) { return 0, nil })
+	_ = seal.Set("pair", func() (string, bool) { return "", false })
+	_ = seal.Set("raw", func(_ goja.FunctionCall) goja.Value { return nil })
+
+	text := newJsDtsGenerator().generate(vm, seal)
+	for _, want := range []string{
+		`"new"(arg0: string): seal.ExtInfo;`,
+		`roll(ctx: seal.MsgContext, arg1: string, ...arg2: number[]): number;`,
+		`pair(): [string, boolean];`,
+		`raw(...args: any[]): any;`,
+		"interface ExtInfo {",
+		"interface MsgContext {",
+		"    isPrivate: boolean;",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("generated declarations missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package dice
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja_nodejs/eventloop"
+)
+
+const (
+	// jsIPCDefaultTimeout seal.ipc.call 未指定超时时的默认值
+	jsIPCDefaultTimeout = 10 * time.Second
+	// jsIPCMaxTimeout seal.ipc.call 允许的最大超时
+	jsIPCMaxTimeout = 60 * time.Second
+)
+
+// jsIPCBus 扩展间通信总线。
+// 扩展包以包 ID 作为通信标识，普通脚本以扩展名作为标识；
+// 所有调用与事件投递都受目标扩展包 Permissions.IPC 白名单约束，并在 JS loop 上异步执行。
+type jsIPCBus struct {
+	d    *Dice
+	loop *eventloop.EventLoop
+
+	lock     sync.Mutex
+	handlers map[string]map[string]*jsIPCHandler // ownerID -> method -> handler
+	subs     map[string][]*jsIPCSubscription     // sourceID + "\x00" + event -> 订阅
+	nextSub  int64
+}
+
+type jsIPCHandler struct {
+	ext *ExtInfo
+	fn  goja.Callable
+}
+
+type jsIPCSubscription struct {
+	id         int64
+	ext        *ExtInfo
+	subscriber string
+	fn         goja.Callable
+}
+
+func newJsIPCBus(d *Dice, loop *eventloop.EventLoop) *jsIPCBus {
+	return &jsIPCBus{
+		d:        d,
+		loop:     loop,
+		handlers: map[string]map[string]*jsIPCHandler{},
+		subs:     map[string][]*jsIPCSubscription{},
+	}
+}
+
+// jsIPCOwnerID 扩展在 IPC 中的标识，来自扩展包的扩展使用包 ID。
+// boundPkgID 为调用方所在的扩展包（全局 seal.ipc 为空），扩展必须属于该包，
+// 避免脚本通过 seal.ext.find 拿到其他扩展后冒用其身份。
+func jsIPCOwnerID(ei *ExtInfo, boundPkgID string) (string, error) {
+	if ei == nil || ei.dice == nil {
+		return "", errors.New("请先完成此扩展的注册")
+	}
+	pkgID := ""
+	if ei.Source != nil {
+		pkgID = ei.Source.PackageID
+	}
+	if pkgID != boundPkgID {
+		return "", fmt.Errorf("扩展 %s 不属于当前脚本，无法使用其身份进行 IPC", ei.Name)
+	}
+	if pkgID != "" {
+		return pkgID, nil
+	}
+	// 包 ID 均为 作者/包名 格式，禁止普通脚本冒用
+	if strings.Contains(ei.Name, "/") {
+		return "", errors.New("非扩展包的扩展名不能包含 /，无法使用 IPC")
+	}
+	return ei.Name, nil
+}
+
+// checkPermission 检查 callerID 是否被 targetID 的 IPC 白名单允许
+func (b *jsIPCBus) checkPermission(targetID, callerID string) error {
+	if targetID == callerID || b.d.PackageManager == nil {
+		return nil
+	}
+	sandbox, err := b.d.PackageManager.GetSandbox(targetID)
+	if err != nil {
+		// 目标不是扩展包（普通脚本），没有声明任何限制
+		return nil //nolint:nilerr
+	}
+	err = sandbox.CheckIPCPermission(callerID)
+	if b.d.jsSandboxes != nil {
+		err = b.d.jsSandboxes.report(err)
+	}
+	return err
+}
+
+// extAlive 判断扩展是否仍属于当前 loop，重载或删除后的扩展不再接收调用
+func (b *jsIPCBus) extAlive(ei *ExtInfo) bool {
+	if b.d.ExtLoopManager == nil {
+		return false
+	}
+	_, err := b.d.ExtLoopManager.GetLoop(ei.JSLoopVersion)
+	return err == nil
+}
+
+func (b *jsIPCBus) handle(ei *ExtInfo, boundPkgID, method string, fn goja.Callable) error {
+	ownerID, err := jsIPCOwnerID(ei, boundPkgID)
+	if err != nil {
+		return err
+	}
+	if method == "" || fn == nil {
+		return errors.New("IPC 方法名与处理函数不能为空")
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	methods := b.handlers[ownerID]
+	if methods == nil {
+		methods = map[string]*jsIPCHandler{}
+		b.handlers[ownerID] = methods
+	}
+	methods[method] = &jsIPCHandler{ext: ei, fn: fn}
+	return nil
+}
+
+func (b *jsIPCBus) unhandle(ei *ExtInfo, boundPkgID, method string) {
+	ownerID, err := jsIPCOwnerID(ei, boundPkgID)
+	if err != nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	delete(b.handlers[ownerID], method)
+}
+
+// call 调用目标扩展的 IPC 方法，只能在 loop 内调用。
+// 处理函数总是在之后的 loop 轮次中执行，可以返回值或 Promise；超时后调用方收到 reject，仍在同步执行的处理函数会被中断。
+func (b *jsIPCBus) call(vm *goja.Runtime, ei *ExtInfo, boundPkgID, targetID, method string, payload goja.Value, timeoutMs int64) *goja.Promise {
+	promise, resolve, reject := vm.NewPromise()
+	settled := false
+	settle := func(v interface{}, err error) {
+		// 只在 loop 内调用，无需加锁
+		if settled {
+			return
+		}
+		settled = true
+		if err != nil {
+			_ = reject(vm.NewGoError(err))
+		} else {
+			_ = resolve(v)
+		}
+	}
+
+	callerID, err := jsIPCOwnerID(ei, boundPkgID)
+	if err != nil {
+		settle(nil, err)
+		return promise
+	}
+	if err := b.checkPermission(targetID, callerID); err != nil {
+		settle(nil, err)
+		return promise
+	}
+
+	b.lock.Lock()
+	handler := b.handlers[targetID][method]
+	b.lock.Unlock()
+	if handler == nil || !b.extAlive(handler.ext) {
+		settle(nil, fmt.Errorf("扩展 %s 未提供 IPC 方法 %s", targetID, method))
+		return promise
+	}
+
+	timeout := jsIPCDefaultTimeout
+	if timeoutMs > 0 {
+		timeout = min(time.Duration(timeoutMs)*time.Millisecond, jsIPCMaxTimeout)
+	}
+	timeoutErr := fmt.Errorf("调用 %s.%s 超时(%s)", targetID, method, timeout)
+	// 处理函数同步执行时会占住 loop，投递到 loop 上的超时回调无法运行，
+	// 因此计时器在 loop 外直接中断 vm
+	var (
+		timerLock   sync.Mutex
+		running     bool
+		interrupted bool
+	)
+	timer := time.AfterFunc(timeout, func() {
+		timerLock.Lock()
+		if running {
+			interrupted = true
+			vm.Interrupt(timeoutErr)
+		}
+		timerLock.Unlock()
+		b.loop.RunOnLoop(func(*goja.Runtime) {
+			settle(nil, timeoutErr)
+		})
+	})
+
+	// 导出后重新包装，避免双方共享同一个可变对象
+	arg := vm.ToValue(payload.Export())
+	b.loop.RunOnLoop(func(vm *goja.Runtime) {
+		if settled {
+			return
+		}
+		onDone := func(v goja.Value, err error) {
+			timer.Stop()
+			if err != nil {
+				settle(nil, err)
+				return
+			}
+			settle(v.Export(), nil)
+		}
+
+		timerLock.Lock()
+		running = true
+		timerLock.Unlock()
+		ret, err := jsIPCInvoke(handler.fn, arg, vm.ToValue(callerID))
+		timerLock.Lock()
+		running = false
+		fired := interrupted
+		timerLock.Unlock()
+		if fired {
+			// 中断可能在 JS 返回后才触发，需要清除，避免影响下一次执行
+			vm.ClearInterrupt()
+			onDone(nil, timeoutErr)
+			return
+		}
+		if err != nil {
+			onDone(nil, err)
+			return
+		}
+		if p, ok := ret.Export().(*goja.Promise); ok {
+			b.awaitPromise(vm, p, onDone)
+			return
+		}
+		onDone(ret, nil)
+	})
+	return promise
+}
+
+// awaitPromise 在 promise 完成后回调 onDone
+func (b *jsIPCBus) awaitPromise(vm *goja.Runtime, p *goja.Promise, onDone func(goja.Value, error)) {
+	switch p.State() {
+	case goja.PromiseStateFulfilled:
+		onDone(p.Result(), nil)
+		return
+	case goja.PromiseStateRejected:
+		onDone(nil, fmt.Errorf("%v", p.Result()))
+		return
+	}
+	then, _ := goja.AssertFunction(vm.ToValue(p).ToObject(vm).Get("then"))
+	_, err := then(vm.ToValue(p),
+		vm.ToValue(func(v goja.Value) { onDone(v, nil) }),
+		vm.ToValue(func(v goja.Value) { onDone(nil, fmt.Errorf("%v", v)) }),
+	)
+	if err != nil {
+		onDone(nil, err)
+	}
+}
+
+func jsIPCInvoke(fn goja.Callable, args ...goja.Value) (ret goja.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("IPC 处理函数异常: %v", r)
+		}
+	}()
+	return fn(goja.Undefined(), args...)
+}
+
+func (b *jsIPCBus) subscribe(ei *ExtInfo, boundPkgID, sourceID, event string, fn goja.Callable) (int64, error) {
+	subscriberID, err := jsIPCOwnerID(ei, boundPkgID)
+	if err != nil {
+		return 0, err
+	}
+	if event == "" || fn == nil {
+		return 0, errors.New("IPC 事件名与回调函数不能为空")
+	}
+	if err := b.checkPermission(sourceID, subscriberID); err != nil {
+		return 0, err
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.nextSub++
+	key := sourceID + "\x00" + event
+	b.subs[key] = append(b.subs[key], &jsIPCSubscription{id: b.nextSub, ext: ei, subscriber: subscriberID, fn: fn})
+	return b.nextSub, nil
+}
+
+func (b *jsIPCBus) unsubscribe(ei *ExtInfo, id int64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for key, list := range b.subs {
+		for i, sub := range list {
+			if sub.id == id && sub.ext == ei {
+				b.subs[key] = append(list[:i], list[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// publish 向订阅者异步投递事件，返回投递的订阅数
+func (b *jsIPCBus) publish(vm *goja.Runtime, ei *ExtInfo, boundPkgID, event string, payload goja.Value) (int, error) {
+	sourceID, err := jsIPCOwnerID(ei, boundPkgID)
+	if err != nil {
+		return 0, err
+	}
+
+	b.lock.Lock()
+	subs := append([]*jsIPCSubscription(nil), b.subs[sourceID+"\x00"+event]...)
+	b.lock.Unlock()
+
+	count := 0
+	for _, sub := range subs {
+		// 白名单可能在订阅后变化（包更新），投递时再次检查
+		if !b.extAlive(sub.ext) || b.checkPermission(sourceID, sub.subscriber) != nil {
+			continue
+		}
+		count++
+		arg := vm.ToValue(payload.Export())
+		b.loop.RunOnLoop(func(vm *goja.Runtime) {
+			if _, err := jsIPCInvoke(sub.fn, arg, vm.ToValue(sourceID)); err != nil {
+				b.d.Logger.Warnf("IPC 事件 %s.%s 投递给 %s 失败: %v", sourceID, event, sub.subscriber, err)
+			}
+		})
+	}
+	return count, nil
+}
+
+// removeExt 移除扩展注册的全部 IPC 方法与订阅
+func (b *jsIPCBus) removeExt(ei *ExtInfo) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for ownerID, methods := range b.handlers {
+		for method, h := range methods {
+			if h.ext == ei {
+				delete(methods, method)
+			}
+		}
+		if len(methods) == 0 {
+			delete(b.handlers, ownerID)
+		}
+	}
+	for key, list := range b.subs {
+		kept := list[:0]
+		for _, sub := range list {
+			if sub.ext != ei {
+				kept = append(kept, sub)
+			}
+		}
+		b.subs[key] = kept
+	}
+}
+
+// newJsIPCObject 构建 seal.ipc，pkgID 为空时为全局对象，否则绑定到对应扩展包
+func (b *jsIPCBus) newJsIPCObject(vm *goja.Runtime, pkgID string) *goja.Object {
+	ipc := vm.NewObject()
+	_ = ipc.Set("handle", func(ei *ExtInfo, method string, fn goja.Callable) error {
+		return b.handle(ei, pkgID, method, fn)
+	})
+	_ = ipc.Set("unhandle", func(ei *ExtInfo, method string) {
+		b.unhandle(ei, pkgID, method)
+	})
+	_ = ipc.Set("call", func(ei *ExtInfo, targetID string, method string, payload goja.Value, timeoutMs int64) *goja.Promise {
+		return b.call(vm, ei, pkgID, targetID, method, payload, timeoutMs)
+	})
+	_ = ipc.Set("subscribe", func(ei *ExtInfo, sourceID string, event string, fn goja.Callable) (int64, error) {
+		return b.subscribe(ei, pkgID, sourceID, event, fn)
+	})
+	_ = ipc.Set("unsubscribe", func(ei *ExtInfo, id int64) {
+		b.unsubscribe(ei, id)
+	})
+	_ = ipc.Set("publish", func(ei *ExtInfo, event string, payload goja.Value) (int, error) {
+		return b.publish(vm, ei, pkgID, event, payload)
+	})
+	return ipc
+}
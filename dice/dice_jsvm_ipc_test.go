@@ -0,0 +1,127 @@
+package dice //nolint:testpackage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func withIPCPermission(pkgIDs ...string) manifestOption {
+	return func(b *strings.Builder) {
+		quoted := make([]string, 0, len(pkgIDs))
+		for _, id := range pkgIDs {
+			quoted = append(quoted, fmt.Sprintf("%q", id))
+		}
+		b.WriteString("\n[permissions]\n")
+		fmt.Fprintf(b, "ipc = [%s]\n", strings.Join(quoted, ", "))
+	}
+}
+
+func TestJsIPCCallHonorsAllowlistAndTimeout(t *testing.T) {
+	d, pm := newTestPackageManager(t)
+	d.ImSession = &IMSession{
+		ServiceAtNew: new(SyncMap[string, *GroupInfo]),
+		EndPoints:    []*EndPointInfo{},
+	}
+	d.DirtyGroups = new(SyncMap[string, int64])
+	d.AttrsManager = &AttrsManager{}
+	d.ExtRegistry = new(SyncMap[string, *ExtInfo])
+	if err := pm.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	scripts := []struct {
+		pkgID  string
+		script string
+		opts   []manifestOption
+	}{
+		{"alice/service", strings.Join([]string{
+			"const ext = seal.ext.new('ipc-service', 'alice', '1.0.0');",
+			"seal.ext.register(ext);",
+			"seal.ipc.handle(ext, 'add', (p, caller) => ({ sum: p.a + p.b, caller: caller }));",
+			"seal.ipc.handle(ext, 'hang', () => new Promise(() => {}));",
+			"seal.ipc.handle(ext, 'spin', () => { while (true) {} });",
+		}, "\n"), []manifestOption{withIPCPermission("bob/client")}},
+		{"bob/client", strings.Join([]string{
+			"const fs = require('fs');",
+			"const ext = seal.ext.new('ipc-client', 'bob', '1.0.0');",
+			"seal.ext.register(ext);",
+			"seal.ipc.call(ext, 'alice/service', 'add', { a: 1, b: 2 }).then(r => fs.writeFileSync('_userdata/add.txt', r.sum + ',' + r.caller));",
+			"seal.ipc.call(ext, 'alice/service', 'hang', null, 50).catch(e => fs.writeFileSync('_userdata/hang.txt', String(e)));",
+			// 同步死循环的处理函数占住 loop，超时后应被中断，loop 随后继续处理其他调用
+			"seal.ipc.call(ext, 'alice/service', 'spin', null, 50).catch(e => {",
+			"  fs.writeFileSync('_userdata/spin.txt', String(e));",
+			"  return seal.ipc.call(ext, 'alice/service', 'add', { a: 2, b: 3 });",
+			"}).then(r => fs.writeFileSync('_userdata/after-spin.txt', String(r.sum)));",
+		}, "\n"), nil},
+		{"eve/other", strings.Join([]string{
+			"const fs = require('fs');",
+			"const ext = seal.ext.new('ipc-other', 'eve', '1.0.0');",
+			"seal.ext.register(ext);",
+			"seal.ipc.call(ext, 'alice/service', 'add', { a: 1, b: 2 }).catch(e => fs.writeFileSync('_userdata/denied.txt', String(e)));",
+			// 拿到其他包的扩展也不能冒用其身份
+			"try { seal.ipc.handle(seal.ext.find('ipc-client'), 'x', () => 1); } catch (e) { fs.writeFileSync('_userdata/impersonate.txt', String(e)); }",
+		}, "\n"), nil},
+	}
+	for _, s := range scripts {
+		archive := createTestSealPack(t, "", s.pkgID, "1.0.0",
+			map[string][]string{"scripts": {"scripts/*.js"}},
+			map[string]string{"scripts/main.js": s.script}, s.opts...)
+		if err := pm.Install(archive); err != nil {
+			t.Fatalf("Install(%s) error = %v", s.pkgID, err)
+		}
+		if _, err := pm.Enable(s.pkgID); err != nil {
+			t.Fatalf("Enable(%s) error = %v", s.pkgID, err)
+		}
+	}
+
+	d.JsInit()
+	defer func() {
+		if d.JsScriptCron != nil {
+			d.JsScriptCron.Stop()
+		}
+		d.ExtLoopManager.SetLoop(nil)
+	}()
+
+	files := map[string]PackageContentFile{}
+	for _, f := range pm.GetEnabledContentFiles("scripts") {
+		files[f.PackageID] = f
+	}
+	for _, s := range scripts {
+		jsInfo := &JsScriptInfo{Name: s.pkgID, Filename: "./" + files[s.pkgID].Path, Enable: true, PackageID: s.pkgID}
+		d.JsLoadScriptRaw(jsInfo)
+		if jsInfo.ErrText != "" {
+			t.Fatalf("load %s: %s", s.pkgID, jsInfo.ErrText)
+		}
+	}
+
+	expect := map[string]map[string]string{
+		"bob/client": {"add.txt": "3,bob/client", "hang.txt": "超时", "spin.txt": "超时", "after-spin.txt": "5"},
+		"eve/other":  {"denied.txt": "ipc", "impersonate.txt": "无法使用其身份"},
+	}
+	for pkgID, want := range expect {
+		pkg, _ := pm.Get(pkgID)
+		for name, substr := range want {
+			got := waitForFile(t, filepath.Join(pkg.UserDataPath, name))
+			if !strings.Contains(got, substr) {
+				t.Fatalf("%s %s = %q, want containing %q", pkgID, name, got, substr)
+			}
+		}
+	}
+}
+
+func waitForFile(t *testing.T, path string) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if data, err := os.ReadFile(path); err == nil {
+			return string(data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", path)
+	return ""
+}
@@ -0,0 +1,349 @@
+package dice
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dop251/goja"
+
+	sealws "sealdice-core/utils/plugin/websocket"
+)
+
+// jsReloadSeq 单独重载时为模块路径追加的序号，使 require 缓存失效
+var jsReloadSeq atomic.Int64
+
+var jsReloadSuffixRe = regexp.MustCompile(`\?reload=\d+$`)
+
+// stripJsReloadSuffix 去掉单独重载时追加在模块路径上的序号，得到真实文件路径
+func stripJsReloadSuffix(filename string) string {
+	return jsReloadSuffixRe.ReplaceAllString(filename, "")
+}
+
+// jsModuleKey 将模块路径规范化，与 require 解析得到的路径保持一致
+func jsModuleKey(filename string) string {
+	p := filepath.Clean(filename)
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		p = resolved
+	}
+	return filepath.ToSlash(p)
+}
+
+// jsRecordModuleOwner 记录 require 时使用的模块路径所属的脚本文件
+func (d *Dice) jsRecordModuleOwner(modulePath, scriptFilename string) {
+	if d.jsModuleOwners == nil {
+		d.jsModuleOwners = new(SyncMap[string, string])
+	}
+	d.jsModuleOwners.Store(jsModuleKey(modulePath), jsModuleKey(scriptFilename))
+}
+
+// jsScriptOwner 根据调用栈找到当前正在执行的脚本文件，用于 WebSocket 等资源的归属
+func (d *Dice) jsScriptOwner(vm *goja.Runtime) string {
+	if d.jsModuleOwners == nil {
+		return ""
+	}
+	for _, frame := range vm.CaptureCallStack(0, nil) {
+		if owner, ok := d.jsModuleOwners.Load(jsModuleKey(frame.SrcName())); ok {
+			return owner
+		}
+	}
+	return ""
+}
+
+// JsReloadScript 只重载一个脚本：卸载它注册的扩展、定时任务、IPC 处理器与 WebSocket 连接，
+// 然后重新执行该文件。其他脚本的内存状态与定时器不受影响；
+// 依赖此脚本的其他脚本不会重新执行，仍持有旧的导出。
+// filename 不在脚本列表中时按新脚本加载。
+func (d *Dice) JsReloadScript(filename string) (*JsScriptInfo, error) {
+	if !d.Config.JsEnable || d.ExtLoopManager == nil || d.ExtLoopManager.GetWebLoop() == nil {
+		return nil, errors.New("js扩展支持已关闭")
+	}
+	if !isScriptFile(filename) {
+		return nil, errors.New("不是js或ts脚本")
+	}
+
+	old := d.jsFindScript(filename)
+	if old != nil {
+		if old.Builtin {
+			return nil, errors.New("内置脚本不支持单独重载")
+		}
+		filename = old.Filename
+	} else if !d.jsInScriptDir(filename) {
+		return nil, errors.New("只能加载 scripts 目录中的脚本")
+	}
+	stat, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filename) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+
+	startTime := time.Now()
+	jsInfo, err := d.JsParseMeta(filename, stat.ModTime(), data, false)
+	// JsParseMeta 总会把新信息追加到列表末尾；检查未通过时旧版本继续运行
+	parsed := d.JsScriptList[len(d.JsScriptList)-1]
+	if err != nil {
+		if old != nil {
+			d.jsReplaceScriptInfo(parsed, nil)
+		}
+		return nil, err
+	}
+	if old != nil {
+		jsInfo.PackageID = old.PackageID
+	} else if d.PackageManager != nil {
+		jsInfo.PackageID = d.PackageManager.PackageIDForPath(filename)
+	}
+	if strings.ToLower(filepath.Ext(jsInfo.Filename)) == ".ts" {
+		jsInfo.needCompiled = true
+	}
+
+	// 依赖只对照已加载的其他脚本检查，使用副本避免改动它们的状态
+	candidates := []*JsScriptInfo{jsInfo}
+	for _, info := range d.JsScriptList {
+		if info != jsInfo && info.Enable {
+			cp := *info
+			candidates = append(candidates, &cp)
+		}
+	}
+	_, invalidInfoMap := checkJsScriptsDeps(candidates)
+	if reasons := invalidInfoMap[jsInfo.Author+":"+jsInfo.Name]; len(reasons) > 0 {
+		if old != nil {
+			d.jsReplaceScriptInfo(jsInfo, nil)
+		}
+		return jsInfo, errors.New(strings.Join(reasons, "\n"))
+	}
+
+	var oldExtNames []string
+	if old != nil {
+		oldExtNames = d.jsUnloadScript(old)
+		d.jsReplaceScriptInfo(old, jsInfo)
+	}
+
+	jsInfo.reloadSeq = jsReloadSeq.Add(1)
+	d.JsLoadScriptRaw(jsInfo)
+
+	// 新版本不再注册的扩展按删除处理
+	for _, name := range oldExtNames {
+		if _, ok := d.JsExtRegistry.Load(name); ok {
+			continue
+		}
+		if wrapper, ok := d.ExtRegistry.Load(name); ok && wrapper != nil && wrapper.IsWrapper {
+			wrapper.IsDeleted = true
+		}
+	}
+	d.ApplyExtDefaultSettings()
+	d.ExtUpdateTime = time.Now().Unix()
+
+	if jsInfo.Enable && jsInfo.ErrText == "" {
+		d.Logger.Infof("脚本「%s」已单独重载，耗时 %dms", jsInfo.Name, time.Since(startTime).Milliseconds())
+	}
+	if jsInfo.ErrText != "" {
+		return jsInfo, errors.New(jsInfo.ErrText)
+	}
+	return jsInfo, nil
+}
+
+// JsUnloadScript 卸载一个脚本注册的全部内容并从脚本列表移除，不删除文件
+func (d *Dice) JsUnloadScript(filename string) error {
+	jsInfo := d.jsFindScript(filename)
+	if jsInfo == nil {
+		return fmt.Errorf("脚本未加载: %s", filename)
+	}
+	if jsInfo.Builtin {
+		return errors.New("内置脚本不支持单独卸载")
+	}
+	for _, name := range d.jsUnloadScript(jsInfo) {
+		if wrapper, ok := d.ExtRegistry.Load(name); ok && wrapper != nil && wrapper.IsWrapper {
+			wrapper.IsDeleted = true
+		}
+	}
+	d.jsReplaceScriptInfo(jsInfo, nil)
+	d.Logger.Infof("脚本「%s」已卸载", jsInfo.Name)
+	return nil
+}
+
+// jsInScriptDir 判断文件是否位于 scripts 目录中（不含内置脚本目录）
+func (d *Dice) jsInScriptDir(filename string) bool {
+	root, err := filepath.Abs(filepath.Join(d.BaseConfig.DataDir, "scripts"))
+	if err != nil {
+		return false
+	}
+	target, err := filepath.Abs(filename)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, target)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	return strings.Split(filepath.ToSlash(rel), "/")[0] != "_builtin"
+}
+
+func (d *Dice) jsFindScript(filename string) *JsScriptInfo {
+	target := filepath.Clean(filename)
+	for _, info := range d.JsScriptList {
+		if filepath.Clean(info.Filename) == target {
+			return info
+		}
+	}
+	return nil
+}
+
+// jsReplaceScriptInfo 用 replacement 替换列表中的 old 并移除 replacement 原来的位置，
+// replacement 为 nil 时仅移除 old
+func (d *Dice) jsReplaceScriptInfo(old, replacement *JsScriptInfo) {
+	if old == nil {
+		return
+	}
+	list := make([]*JsScriptInfo, 0, len(d.JsScriptList))
+	for _, info := range d.JsScriptList {
+		switch info {
+		case replacement:
+			continue
+		case old:
+			if replacement != nil {
+				list = append(list, replacement)
+			}
+		default:
+			list = append(list, info)
+		}
+	}
+	d.JsScriptList = list
+}
+
+// jsUnloadScript 注销脚本注册的真实扩展及其定时任务、存储、IPC 处理器与 WebSocket 连接，
+// wrapper 保留在群组中，重新注册时会被复用。返回被注销的扩展名。
+func (d *Dice) jsUnloadScript(jsInfo *JsScriptInfo) []string {
+	var names []string
+	if d.JsExtRegistry != nil {
+		d.JsExtRegistry.Range(func(name string, ext *ExtInfo) bool {
+			if ext != nil && ext.Source == jsInfo {
+				names = append(names, name)
+			}
+			return true
+		})
+	}
+	for _, name := range names {
+		ext, ok := d.JsExtRegistry.Load(name)
+		if !ok {
+			continue
+		}
+		for _, task := range ext.taskList {
+			task.Off()
+		}
+		ext.taskList = nil
+		if d.ConfigManager != nil {
+			d.ConfigManager.stopPluginTasks(name)
+		}
+		if ext.Storage != nil {
+			_ = ext.StorageClose()
+		}
+		if d.jsIPC != nil {
+			d.jsIPC.removeExt(ext)
+		}
+		d.JsExtRegistry.Delete(name)
+	}
+	if n := sealws.GlobalConnManager.CloseByOwner(jsModuleKey(jsInfo.Filename)); n > 0 {
+		d.Logger.Infof("脚本「%s」卸载，关闭了 %d 个 WebSocket 连接", jsInfo.Name, n)
+	}
+	d.ExtUpdateTime = time.Now().Unix()
+	return names
+}
+
+// jsScriptWatchInterval 开发模式下扫描脚本目录的间隔
+const jsScriptWatchInterval = 2 * time.Second
+
+// ApplyJsScriptWatch 按 JsEnable 与 JsScriptWatchEnable 启动或停止脚本目录监视，
+// 开关变化后调用；已处于目标状态时不做处理
+func (d *Dice) ApplyJsScriptWatch() {
+	d.jsScriptWatchLock.Lock()
+	defer d.jsScriptWatchLock.Unlock()
+
+	enable := d.Config.JsEnable && d.Config.JsScriptWatchEnable
+	if enable == (d.jsScriptWatchStop != nil) {
+		return
+	}
+	if !enable {
+		close(d.jsScriptWatchStop)
+		d.jsScriptWatchStop = nil
+		d.Logger.Info("已停止监视脚本目录")
+		return
+	}
+	stop := make(chan struct{})
+	d.jsScriptWatchStop = stop
+	go d.jsScriptWatchLoop(stop)
+	d.Logger.Info("已开始监视脚本目录，脚本变更后将单独重载")
+}
+
+// jsScriptWatchLoop 定期扫描 scripts 目录（不含内置脚本与扩展包），
+// 文件新增或修改时单独重载该脚本，被删除时卸载，直到 stop 关闭。用于开发调试。
+func (d *Dice) jsScriptWatchLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(jsScriptWatchInterval)
+	defer ticker.Stop()
+
+	snapshot := d.jsScanScriptDir()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		snapshot = d.jsApplyScriptChanges(snapshot, d.jsScanScriptDir())
+	}
+}
+
+// jsScanScriptDir 返回 scripts 目录下第三方脚本的修改时间，路径格式与 JsLoadScripts 一致
+func (d *Dice) jsScanScriptDir() map[string]time.Time {
+	result := map[string]time.Time{}
+	root := filepath.Join(d.BaseConfig.DataDir, "scripts")
+	_ = filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return nil //nolint:nilerr
+		}
+		if info.IsDir() && info.Name() == "_builtin" {
+			return fs.SkipDir
+		}
+		if !info.IsDir() && isScriptFile(path) {
+			result["./"+path] = info.ModTime()
+		}
+		return nil
+	})
+	return result
+}
+
+// jsApplyScriptChanges 对比两次扫描结果并重载或卸载脚本，返回新的基准；
+// 正在整体重载时跳过本次处理，留待下次扫描
+func (d *Dice) jsApplyScriptChanges(previous, current map[string]time.Time) map[string]time.Time {
+	if !d.JsReloadLock.TryLock() {
+		return previous
+	}
+	defer d.JsReloadLock.Unlock()
+
+	for filename, modTime := range current {
+		if prev, ok := previous[filename]; ok && prev.Equal(modTime) {
+			continue
+		}
+		if _, err := d.JsReloadScript(filename); err != nil {
+			d.Logger.Warnf("脚本 %s 变更后重载失败: %v", filename, err)
+		}
+	}
+	for filename := range previous {
+		if _, ok := current[filename]; ok {
+			continue
+		}
+		if d.jsFindScript(filename) != nil {
+			if err := d.JsUnloadScript(filename); err != nil {
+				d.Logger.Warnf("脚本 %s 删除后卸载失败: %v", filename, err)
+			}
+		}
+	}
+	return current
+}
@@ -0,0 +1,95 @@
+package dice //nolint:testpackage
+
+import (
+	"testing"
+
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+func TestStripJsReloadSuffix(t *testing.T) {
+	cases := map[string]string{
+		"./data/default/scripts/a.js?reload=12": "./data/default/scripts/a.js",
+		"./data/default/scripts/a.js":           "./data/default/scripts/a.js",
+		"./data/default/scripts/a?reload=x.js":  "./data/default/scripts/a?reload=x.js",
+	}
+	for in, want := range cases {
+		if got := stripJsReloadSuffix(in); got != want {
+			t.Errorf("stripJsReloadSuffix(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestJsUnloadScriptKeepsOtherScripts(t *testing.T) {
+	d := &Dice{Logger: zap.NewNop().Sugar()}
+	d.JsExtRegistry = new(SyncMap[string, *ExtInfo])
+	d.ExtRegistry = new(SyncMap[string, *ExtInfo])
+
+	target := &JsScriptInfo{Name: "target", Filename: "./scripts/target.js", Enable: true}
+	other := &JsScriptInfo{Name: "other", Filename: "./scripts/other.js", Enable: true}
+	d.JsScriptList = []*JsScriptInfo{target, other}
+
+	c := cron.New()
+	task := &JsScriptTask{cron: c, cronExpr: "* * * * *", task: func(JsScriptTaskCtx) {}}
+	if !task.On() {
+		t.Fatal("task should be scheduled")
+	}
+	d.JsExtRegistry.Store("target-ext", &ExtInfo{Name: "target-ext", Source: target, taskList: []*JsScriptTask{task}})
+	d.JsExtRegistry.Store("other-ext", &ExtInfo{Name: "other-ext", Source: other})
+
+	names := d.jsUnloadScript(target)
+	if len(names) != 1 || names[0] != "target-ext" {
+		t.Fatalf("unloaded = %v, want [target-ext]", names)
+	}
+	if len(c.Entries()) != 0 {
+		t.Fatalf("task of unloaded script should be removed from cron")
+	}
+	if _, ok := d.JsExtRegistry.Load("target-ext"); ok {
+		t.Fatalf("target-ext should be unregistered")
+	}
+	if _, ok := d.JsExtRegistry.Load("other-ext"); !ok {
+		t.Fatalf("other-ext should stay registered")
+	}
+
+	replacement := &JsScriptInfo{Name: "target", Filename: target.Filename, Enable: true}
+	d.JsScriptList = append(d.JsScriptList, replacement)
+	d.jsReplaceScriptInfo(target, replacement)
+	if len(d.JsScriptList) != 2 || d.JsScriptList[0] != replacement || d.JsScriptList[1] != other {
+		t.Fatalf("script list = %v, want replacement kept in place", d.JsScriptList)
+	}
+	if d.jsFindScript("scripts/target.js") != replacement {
+		t.Fatalf("jsFindScript should match cleaned path")
+	}
+}
+
+func TestApplyJsScriptWatchFollowsConfig(t *testing.T) {
+	d := &Dice{Logger: zap.NewNop().Sugar(), BaseConfig: BaseConfig{DataDir: t.TempDir()}}
+	d.Config.JsEnable = true
+
+	d.ApplyJsScriptWatch()
+	if d.jsScriptWatchStop != nil {
+		t.Fatal("watch should not start while the option is off")
+	}
+
+	d.Config.JsScriptWatchEnable = true
+	d.ApplyJsScriptWatch()
+	stop := d.jsScriptWatchStop
+	if stop == nil {
+		t.Fatal("watch should start once the option is on")
+	}
+	d.ApplyJsScriptWatch()
+	if d.jsScriptWatchStop != stop {
+		t.Fatal("applying the same config should keep the running watch")
+	}
+
+	d.Config.JsScriptWatchEnable = false
+	d.ApplyJsScriptWatch()
+	if d.jsScriptWatchStop != nil {
+		t.Fatal("watch should stop once the option is off")
+	}
+	select {
+	case <-stop:
+	default:
+		t.Fatal("stop signal should be closed")
+	}
+}
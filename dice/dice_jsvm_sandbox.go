@@ -0,0 +1,575 @@
+package dice
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja_nodejs/eventloop"
+	"github.com/dop251/goja_nodejs/require"
+
+	"sealdice-core/dice/sealpack"
+)
+
+// jsSandboxFetchMaxBody 沙箱 fetch 单次响应体的大小上限
+const jsSandboxFetchMaxBody int64 = 32 << 20
+
+// jsSandboxPrelude 注入到扩展包脚本模块作用域的前置代码。
+// 写在同一行内，不改变脚本报错时的行号；fetch/WebSocket 与完整的 seal 不在全局对象上，
+// 包脚本只能在模块作用域内取得自己的 Sandbox 提供的版本，seal 也是绑定了包身份的视图。
+const jsSandboxPrelude = `var __sealSandbox__=__sealPackageSandbox__(%q),fetch=__sealSandbox__.fetch,WebSocket=__sealSandbox__.WebSocket,__fetch__=undefined,seal=__sealSandbox__.seal;require=__sealSandbox__.wrapRequire(require,__dirname);`
+
+// jsTrustedPrelude 注入到普通脚本(非扩展包)的前置代码，在模块作用域内取回原始的 fetch/WebSocket/seal
+const jsTrustedPrelude = `var __sealScope__=__sealScriptScope__(%q),fetch=__sealScope__.fetch,WebSocket=__sealScope__.WebSocket,seal=__sealScope__.seal;`
+
+var jsUseStrictRe = regexp.MustCompile(`^\s*(['"])use strict['"];?`)
+
+// jsPackageSandboxes 管理当前 JS 环境中扩展包脚本的沙箱。
+// 每次 JsInit 重建，生命周期与 loop 一致。
+type jsPackageSandboxes struct {
+	d *Dice
+
+	lock sync.Mutex
+	// 一次性令牌 -> 包 ID，防止包脚本伪造其他包的身份获取沙箱
+	tokens map[string]string
+	// 普通脚本的一次性令牌，防止包脚本取得原始对象
+	trustedTokens map[string]struct{}
+	// 编译产物（如 ts 编译后的临时文件）路径 -> 包 ID，普通脚本的编译产物对应空串
+	compiledPaths map[string]string
+	// 正在经由包的 require 加载模块的包 ID，嵌套 require 时入栈
+	requirers []string
+	// 包 ID -> 已构建的沙箱 JS 对象
+	objects map[string]*goja.Object
+
+	// 从全局对象上移走的原始对象，只在 loop 内访问
+	realFetch     goja.Value
+	realWebSocket goja.Value
+	realSeal      *goja.Object
+	trustedScope  *goja.Object
+}
+
+func newJsPackageSandboxes(d *Dice) *jsPackageSandboxes {
+	return &jsPackageSandboxes{
+		d:             d,
+		tokens:        map[string]string{},
+		trustedTokens: map[string]struct{}{},
+		compiledPaths: map[string]string{},
+		objects:       map[string]*goja.Object{},
+	}
+}
+
+// bindCompiledPath 将不在包目录中的编译产物关联到包，pkgID 为空时表示普通脚本的编译产物
+func (s *jsPackageSandboxes) bindCompiledPath(filename, pkgID string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.compiledPaths[jsModuleKey(stripJsReloadSuffix(filename))] = pkgID
+}
+
+func (s *jsPackageSandboxes) compiledPackageID(filename string) (string, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	pkgID, ok := s.compiledPaths[jsModuleKey(filename)]
+	return pkgID, ok
+}
+
+func (s *jsPackageSandboxes) packageIDForPath(filename string) string {
+	if pkgID, ok := s.compiledPackageID(filename); ok {
+		return pkgID
+	}
+	if s.d.PackageManager == nil {
+		return ""
+	}
+	return s.d.PackageManager.PackageIDForPath(filename)
+}
+
+// isTrustedPath 普通脚本只能来自 scripts 目录，或是其中 ts 脚本的编译产物
+func (s *jsPackageSandboxes) isTrustedPath(filename string) bool {
+	if pkgID, ok := s.compiledPackageID(filename); ok {
+		return pkgID == ""
+	}
+	scriptsDir, err := filepath.Abs(filepath.Join(s.d.BaseConfig.DataDir, "scripts"))
+	if err != nil {
+		return false
+	}
+	absPath, err := filepath.Abs(filename)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(jsModuleKey(scriptsDir), jsModuleKey(absPath))
+	rel = filepath.ToSlash(rel)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+func (s *jsPackageSandboxes) pushRequirer(pkgID string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.requirers = append(s.requirers, pkgID)
+}
+
+func (s *jsPackageSandboxes) popRequirer() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.requirers = s.requirers[:len(s.requirers)-1]
+}
+
+func (s *jsPackageSandboxes) currentRequirer() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.requirers) == 0 {
+		return ""
+	}
+	return s.requirers[len(s.requirers)-1]
+}
+
+func (s *jsPackageSandboxes) issueToken(pkgID string) string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	token := hex.EncodeToString(buf)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.tokens[token] = pkgID
+	return token
+}
+
+func (s *jsPackageSandboxes) consumeToken(token string) (string, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	pkgID, ok := s.tokens[token]
+	delete(s.tokens, token)
+	return pkgID, ok
+}
+
+func (s *jsPackageSandboxes) issueTrustedToken() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	token := hex.EncodeToString(buf)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.trustedTokens[token] = struct{}{}
+	return token
+}
+
+func (s *jsPackageSandboxes) consumeTrustedToken(token string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, ok := s.trustedTokens[token]
+	delete(s.trustedTokens, token)
+	return ok
+}
+
+// sourceLoader 作为 require.Registry 的 SourceLoader，为扩展包内的文件注入沙箱前置代码。
+// 只有 scripts 目录中的文件按普通脚本加载；由包 require 到的其他文件一律使用该包的沙箱，
+// 既不属于包、也不在 scripts 目录中的文件拒绝加载。
+func (s *jsPackageSandboxes) sourceLoader(filename string) ([]byte, error) {
+	filename = stripJsReloadSuffix(filename)
+	data, err := require.DefaultSourceLoader(filename)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		return data, nil
+	}
+	pkgID := s.packageIDForPath(filename)
+	if pkgID == "" {
+		pkgID = s.currentRequirer()
+	}
+	switch {
+	case pkgID != "":
+		return injectJsSandboxPrelude(data, s.issueToken(pkgID)), nil
+	case s.isTrustedPath(filename):
+		return injectJsPrelude(data, fmt.Sprintf(jsTrustedPrelude, s.issueTrustedToken())), nil
+	default:
+		return nil, fmt.Errorf("脚本 %s 不在 scripts 目录中，拒绝加载", filename)
+	}
+}
+
+// JsTrustedSource 为不经过 require 执行的可信代码(如 WebUI 的 JS 控制台)加上普通脚本的前置代码
+func (d *Dice) JsTrustedSource(source string) string {
+	if d.jsSandboxes == nil {
+		return source
+	}
+	return string(injectJsPrelude([]byte(source), fmt.Sprintf(jsTrustedPrelude, d.jsSandboxes.issueTrustedToken())))
+}
+
+func injectJsSandboxPrelude(data []byte, token string) []byte {
+	return injectJsPrelude(data, fmt.Sprintf(jsSandboxPrelude, token))
+}
+
+func injectJsPrelude(data []byte, prelude string) []byte {
+	if isPrefixWithUtf8Bom(data) {
+		data = data[3:]
+	}
+	// "use strict" 必须位于函数体开头才生效，前置代码放在其后
+	if loc := jsUseStrictRe.FindIndex(data); loc != nil {
+		out := make([]byte, 0, len(data)+len(prelude))
+		out = append(out, data[:loc[1]]...)
+		out = append(out, prelude...)
+		return append(out, data[loc[1]:]...)
+	}
+	return append([]byte(prelude), data...)
+}
+
+// report 记录权限错误并原样返回，供调用方抛给脚本
+func (s *jsPackageSandboxes) report(err error) error {
+	var permErr *sealpack.PermissionError
+	if errors.As(err, &permErr) && s.d.PackageManager != nil {
+		s.d.PackageManager.RecordPermissionError(permErr)
+	}
+	return err
+}
+
+// enable 在 vm 上注册 __sealPackageSandbox__，只能在 loop 内调用
+func (s *jsPackageSandboxes) enable(vm *goja.Runtime, loop *eventloop.EventLoop) {
+	getter := func(token string) (*goja.Object, error) {
+		pkgID, ok := s.consumeToken(token)
+		if !ok {
+			return nil, errors.New("无效的扩展包沙箱令牌")
+		}
+		if obj, ok := s.objects[pkgID]; ok {
+			return obj, nil
+		}
+		if s.d.PackageManager == nil {
+			return nil, errors.New("扩展包管理器未初始化")
+		}
+		sandbox, err := s.d.PackageManager.GetSandbox(pkgID)
+		if err != nil {
+			return nil, err
+		}
+		obj := s.newSandboxObject(vm, loop, sandbox)
+		s.objects[pkgID] = obj
+		return obj, nil
+	}
+	_ = vm.GlobalObject().DefineDataProperty("__sealPackageSandbox__", vm.ToValue(getter), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+}
+
+// hideGlobals 把 fetch/WebSocket 从全局对象上移走，全局 seal 换成不含 ipc 的视图。
+// 包脚本可以通过 globalThis 绕过模块作用域的遮蔽，所以原始对象只能经由前置代码取得。
+// 需要在 fetch、WebSocket、seal 都注册完成后在 loop 内调用
+func (s *jsPackageSandboxes) hideGlobals(vm *goja.Runtime) {
+	global := vm.GlobalObject()
+	s.realFetch = global.Get("fetch")
+	s.realWebSocket = global.Get("WebSocket")
+	for _, name := range []string{"fetch", "WebSocket", "__fetch__"} {
+		_ = global.Delete(name)
+	}
+	if seal, ok := global.Get("seal").(*goja.Object); ok {
+		s.realSeal = seal
+		_ = global.Set("seal", newJsSealView(vm, seal, goja.Undefined()))
+	}
+
+	scope := vm.NewObject()
+	_ = scope.Set("fetch", s.realFetch)
+	_ = scope.Set("WebSocket", s.realWebSocket)
+	_ = scope.Set("seal", s.realSeal)
+	jsFreeze(vm, scope)
+	s.trustedScope = scope
+
+	getter := func(token string) (*goja.Object, error) {
+		if !s.consumeTrustedToken(token) {
+			return nil, errors.New("无效的脚本令牌")
+		}
+		return s.trustedScope, nil
+	}
+	_ = global.DefineDataProperty("__sealScriptScope__", vm.ToValue(getter), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+}
+
+// fullSeal 完整的 seal 对象，hideGlobals 之前为全局 seal
+func (s *jsPackageSandboxes) fullSeal(vm *goja.Runtime) *goja.Object {
+	if s.realSeal != nil {
+		return s.realSeal
+	}
+	seal, _ := vm.Get("seal").(*goja.Object)
+	return seal
+}
+
+func (s *jsPackageSandboxes) newSandboxObject(vm *goja.Runtime, loop *eventloop.EventLoop, sandbox *sealpack.Sandbox) *goja.Object {
+	obj := vm.NewObject()
+	fsObj := s.newFSObject(vm, sealpack.NewSandboxedFS(sandbox))
+
+	_ = obj.Set("packageId", sandbox.PackageID)
+	_ = obj.Set("fs", fsObj)
+	_ = obj.Set("fetch", s.newFetch(vm, loop, sealpack.NewSandboxedHTTP(sandbox)))
+	_ = obj.Set("WebSocket", func(call goja.ConstructorCall) *goja.Object {
+		if err := sandbox.CheckNetworkPermission(call.Argument(0).String()); err != nil {
+			panic(vm.NewGoError(s.report(err)))
+		}
+		inst, err := vm.New(s.realWebSocket, call.Arguments...)
+		if err != nil {
+			panic(err)
+		}
+		return inst
+	})
+	_ = obj.Set("wrapRequire", func(origin goja.Callable, dirname string) func(string) (goja.Value, error) {
+		return func(name string) (goja.Value, error) {
+			switch name {
+			case "fs", "node:fs":
+				return fsObj, nil
+			}
+			target, err := resolvePackageModule(sandbox, dirname, name)
+			if err != nil {
+				return nil, s.report(err)
+			}
+			s.pushRequirer(sandbox.PackageID)
+			defer s.popRequirer()
+			return origin(goja.Undefined(), vm.ToValue(target))
+		}
+	})
+	_ = obj.Set("checkPermission", func(permission string, target string) error {
+		var err error
+		switch permission {
+		case "network":
+			err = sandbox.CheckNetworkPermission(target)
+		case "file_read":
+			err = sandbox.CheckFileReadPermission(target)
+		case "file_write":
+			err = sandbox.CheckFileWritePermission(target)
+		case "dangerous":
+			err = sandbox.CheckDangerousPermission(target)
+		case "http_server":
+			err = sandbox.CheckHTTPServerPermission()
+		case "ipc":
+			err = sandbox.CheckIPCPermission(target)
+		default:
+			return fmt.Errorf("未知的权限类型: %s", permission)
+		}
+		return s.report(err)
+	})
+	if seal := s.fullSeal(vm); seal != nil {
+		// 仅替换 ipc，使包内的 IPC 调用无法冒用其他扩展的身份
+		var ipc goja.Value = goja.Undefined()
+		if s.d.jsIPC != nil {
+			ipcObj := s.d.jsIPC.newJsIPCObject(vm, sandbox.PackageID)
+			jsFreeze(vm, ipcObj)
+			ipc = ipcObj
+		}
+		_ = obj.Set("seal", newJsSealView(vm, seal, ipc))
+	}
+	jsFreeze(vm, obj)
+	return obj
+}
+
+// newJsSealView 复制 seal 的成员并替换 ipc。
+// 不能以 seal 为原型，否则脚本可以经 Object.getPrototypeOf 取回原始的 ipc
+func newJsSealView(vm *goja.Runtime, seal *goja.Object, ipc goja.Value) *goja.Object {
+	view := vm.NewObject()
+	for _, key := range seal.Keys() {
+		if key != "ipc" {
+			_ = view.Set(key, seal.Get(key))
+		}
+	}
+	_ = view.DefineDataProperty("ipc", ipc, goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE)
+	jsFreeze(vm, view)
+	return view
+}
+
+// resolvePackageModule 将包内 require 的模块名解析为包目录内的绝对路径。
+// 相对或绝对路径必须落在包目录内；裸模块名只在包目录内的 node_modules 中查找，
+// 找不到时原样返回，交给 require 按内置模块处理，加载到的文件仍由 sourceLoader 套上该包的沙箱
+func resolvePackageModule(sandbox *sealpack.Sandbox, dirname, name string) (string, error) {
+	dir, err := filepath.Abs(dirname)
+	if err != nil {
+		return "", err
+	}
+	if jsIsPathModule(name) {
+		target := filepath.FromSlash(name)
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+		return target, sandbox.CheckModulePath(target)
+	}
+	for {
+		if sandbox.CheckModulePath(dir) != nil {
+			return name, nil
+		}
+		candidate := filepath.Join(dir, "node_modules", filepath.FromSlash(name))
+		for _, p := range []string{candidate, candidate + ".js", candidate + ".json"} {
+			if _, err := os.Stat(p); err == nil {
+				return candidate, sandbox.CheckModulePath(p)
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return name, nil
+		}
+		dir = parent
+	}
+}
+
+// jsIsPathModule 与 require 的规则一致，判断模块名是否为文件路径
+func jsIsPathModule(name string) bool {
+	return name == "." || name == ".." || filepath.IsAbs(name) ||
+		strings.HasPrefix(name, "/") || strings.HasPrefix(name, "./") || strings.HasPrefix(name, "../") ||
+		strings.HasPrefix(name, `.\`) || strings.HasPrefix(name, `..\`)
+}
+
+// newFSObject 构建 node 风格的同步文件接口，所有访问都经过 SandboxedFS
+func (s *jsPackageSandboxes) newFSObject(vm *goja.Runtime, sfs *sealpack.SandboxedFS) *goja.Object {
+	obj := vm.NewObject()
+	_ = obj.Set("readFileSync", func(path string, encoding string) (string, error) {
+		data, err := sfs.ReadFile(path)
+		if err != nil {
+			return "", s.report(err)
+		}
+		if encoding == "base64" {
+			return base64.StdEncoding.EncodeToString(data), nil
+		}
+		return string(data), nil
+	})
+	_ = obj.Set("writeFileSync", func(path string, data string) error {
+		return s.report(sfs.WriteFile(path, []byte(data), 0o644))
+	})
+	_ = obj.Set("existsSync", func(path string) (bool, error) {
+		_, err := sfs.Stat(path)
+		if err == nil {
+			return true, nil
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, s.report(err)
+	})
+	_ = obj.Set("statSync", func(path string) (map[string]interface{}, error) {
+		info, err := sfs.Stat(path)
+		if err != nil {
+			return nil, s.report(err)
+		}
+		return map[string]interface{}{
+			"size":        info.Size(),
+			"isFile":      !info.IsDir(),
+			"isDirectory": info.IsDir(),
+			"mtimeMs":     info.ModTime().UnixMilli(),
+		}, nil
+	})
+	_ = obj.Set("readdirSync", func(path string) ([]string, error) {
+		entries, err := sfs.ReadDir(path)
+		if err != nil {
+			return nil, s.report(err)
+		}
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names, nil
+	})
+	_ = obj.Set("mkdirSync", func(path string) error {
+		return s.report(sfs.Mkdir(path, 0o755))
+	})
+	_ = obj.Set("unlinkSync", func(path string) error {
+		return s.report(sfs.Remove(path))
+	})
+	jsFreeze(vm, obj)
+	return obj
+}
+
+// newFetch 构建走 SandboxedHTTP 的 fetch，返回 Promise，权限错误以 reject 的形式交给脚本
+func (s *jsPackageSandboxes) newFetch(vm *goja.Runtime, loop *eventloop.EventLoop, client *sealpack.SandboxedHTTP) func(call goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		promise, resolve, reject := vm.NewPromise()
+
+		method := http.MethodGet
+		header := make(http.Header)
+		var body io.Reader
+		if opts, ok := call.Argument(1).Export().(map[string]interface{}); ok {
+			if m, ok := opts["method"].(string); ok && m != "" {
+				method = strings.ToUpper(m)
+			}
+			if h, ok := opts["headers"].(map[string]interface{}); ok {
+				for k, v := range h {
+					header.Set(k, fmt.Sprint(v))
+				}
+			}
+			if b, ok := opts["body"].(string); ok {
+				body = strings.NewReader(b)
+			}
+		}
+
+		req, err := http.NewRequest(method, call.Argument(0).String(), body)
+		if err != nil {
+			_ = reject(vm.NewGoError(err))
+			return vm.ToValue(promise)
+		}
+		req.Header = header
+
+		go func() {
+			resp, err := client.Do(req)
+			if err != nil {
+				err = s.report(err)
+				loop.RunOnLoop(func(vm *goja.Runtime) { _ = reject(vm.NewGoError(err)) })
+				return
+			}
+			defer resp.Body.Close()
+			data, err := io.ReadAll(io.LimitReader(resp.Body, jsSandboxFetchMaxBody))
+			if err != nil {
+				loop.RunOnLoop(func(vm *goja.Runtime) { _ = reject(vm.NewGoError(err)) })
+				return
+			}
+			loop.RunOnLoop(func(vm *goja.Runtime) {
+				_ = resolve(newJsFetchResponse(vm, resp, data))
+			})
+		}()
+		return vm.ToValue(promise)
+	}
+}
+
+func newJsFetchResponse(vm *goja.Runtime, resp *http.Response, data []byte) *goja.Object {
+	settle := func(fn func() (interface{}, error)) *goja.Promise {
+		p, resolve, reject := vm.NewPromise()
+		v, err := fn()
+		if err != nil {
+			_ = reject(vm.NewGoError(err))
+		} else {
+			_ = resolve(v)
+		}
+		return p
+	}
+
+	headers := vm.NewObject()
+	_ = headers.Set("get", func(name string) goja.Value {
+		if v := resp.Header.Get(name); v != "" {
+			return vm.ToValue(v)
+		}
+		return goja.Null()
+	})
+	_ = headers.Set("has", func(name string) bool {
+		return resp.Header.Get(name) != ""
+	})
+
+	obj := vm.NewObject()
+	_ = obj.Set("ok", resp.StatusCode >= 200 && resp.StatusCode < 300)
+	_ = obj.Set("status", resp.StatusCode)
+	_ = obj.Set("statusText", http.StatusText(resp.StatusCode))
+	_ = obj.Set("url", resp.Request.URL.String())
+	_ = obj.Set("headers", headers)
+	_ = obj.Set("text", func() *goja.Promise {
+		return settle(func() (interface{}, error) { return string(data), nil })
+	})
+	_ = obj.Set("json", func() *goja.Promise {
+		return settle(func() (interface{}, error) {
+			var v interface{}
+			if err := json.Unmarshal(data, &v); err != nil {
+				return nil, err
+			}
+			return v, nil
+		})
+	})
+	return obj
+}
+
+func jsFreeze(vm *goja.Runtime, obj *goja.Object) {
+	if freeze, ok := goja.AssertFunction(vm.Get("Object").ToObject(vm).Get("freeze")); ok {
+		_, _ = freeze(goja.Undefined(), obj)
+	}
+}
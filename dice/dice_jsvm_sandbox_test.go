@@ -0,0 +1,264 @@
+package dice //nolint:testpackage
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestInjectJsSandboxPreludeKeepsUseStrict(t *testing.T) {
+	got := string(injectJsSandboxPrelude([]byte("'use strict';\nlet a = 1;"), "tok"))
+	if !strings.HasPrefix(got, "'use strict';var __sealSandbox__=") {
+		t.Fatalf("prelude should follow the directive, got %q", got)
+	}
+	if strings.Count(got, "\n") != 1 {
+		t.Fatalf("prelude should not add lines, got %q", got)
+	}
+
+	got = string(injectJsSandboxPrelude([]byte("let a = 1;"), "tok"))
+	if !strings.HasPrefix(got, "var __sealSandbox__=__sealPackageSandbox__(\"tok\")") {
+		t.Fatalf("unexpected prelude: %q", got)
+	}
+}
+
+func TestJsPackageScriptRunsInSandbox(t *testing.T) {
+	d, pm := newTestPackageManager(t)
+	d.ImSession = &IMSession{
+		ServiceAtNew: new(SyncMap[string, *GroupInfo]),
+		EndPoints:    []*EndPointInfo{},
+	}
+	d.DirtyGroups = new(SyncMap[string, int64])
+	d.AttrsManager = &AttrsManager{}
+	if err := pm.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	const pkgID = "alice/sandboxed"
+	script := strings.Join([]string{
+		"const fs = require('fs');",
+		"fs.writeFileSync('_userdata/ok.txt', 'hello');",
+		"let denied = '';",
+		"try { fs.readFileSync('assets/secret.txt'); } catch (e) { denied = String(e); }",
+		"if (denied.indexOf('file_read') < 0) throw new Error('read should be denied: ' + denied);",
+		"fs.readFileSync('../outside.txt');",
+	}, "\n")
+	archive := createTestSealPack(t, "", pkgID, "1.0.0",
+		map[string][]string{"scripts": {"scripts/*.js"}},
+		map[string]string{"scripts/main.js": script, "assets/secret.txt": "s"})
+	if err := pm.Install(archive); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if _, err := pm.Enable(pkgID); err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+
+	d.JsInit()
+	defer func() {
+		if d.JsScriptCron != nil {
+			d.JsScriptCron.Stop()
+		}
+		d.ExtLoopManager.SetLoop(nil)
+	}()
+
+	files := pm.GetEnabledContentFiles("scripts")
+	if len(files) != 1 {
+		t.Fatalf("expected one package script, got %d", len(files))
+	}
+	jsInfo := &JsScriptInfo{Name: "sandboxed", Filename: "./" + files[0].Path, Enable: true, PackageID: pkgID}
+	d.JsLoadScriptRaw(jsInfo)
+
+	if !strings.Contains(jsInfo.ErrText, "file_access") {
+		t.Fatalf("expected path traversal to be rejected, ErrText = %q", jsInfo.ErrText)
+	}
+	pkg, _ := pm.Get(pkgID)
+	if !strings.Contains(pkg.ErrText, "file_access") {
+		t.Fatalf("expected package ErrText to record the violation, got %q", pkg.ErrText)
+	}
+	data, err := os.ReadFile(filepath.Join(pkg.UserDataPath, "ok.txt"))
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("expected _userdata write to succeed, data=%q err=%v", data, err)
+	}
+}
+
+func TestJsPackageScriptCannotReachGlobals(t *testing.T) {
+	d, pm := newTestPackageManager(t)
+	d.ImSession = &IMSession{
+		ServiceAtNew: new(SyncMap[string, *GroupInfo]),
+		EndPoints:    []*EndPointInfo{},
+	}
+	d.DirtyGroups = new(SyncMap[string, int64])
+	d.AttrsManager = &AttrsManager{}
+	if err := pm.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	// 包没有声明 network 权限，全局对象上的原始 fetch/WebSocket/seal.ipc 都不应可达
+	const pkgID = "alice/escape"
+	script := strings.Join([]string{
+		"const g = Function('return this')();",
+		"let called = false;",
+		"try { globalThis.fetch('http://127.0.0.1:1/'); called = true; } catch (e) {}",
+		"if (called) throw new Error('globalThis.fetch reachable');",
+		"if (typeof g.fetch !== 'undefined' || typeof g.WebSocket !== 'undefined') throw new Error('global network objects reachable');",
+		"if (globalThis.seal.ipc !== undefined) throw new Error('unbound seal.ipc reachable');",
+		"if (typeof fetch !== 'function' || seal.ipc === undefined) throw new Error('sandbox objects missing');",
+	}, "\n")
+	archive := createTestSealPack(t, "", pkgID, "1.0.0",
+		map[string][]string{"scripts": {"scripts/*.js"}},
+		map[string]string{"scripts/main.js": script})
+	if err := pm.Install(archive); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if _, err := pm.Enable(pkgID); err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+
+	d.JsInit()
+	defer func() {
+		if d.JsScriptCron != nil {
+			d.JsScriptCron.Stop()
+		}
+		d.ExtLoopManager.SetLoop(nil)
+	}()
+
+	files := pm.GetEnabledContentFiles("scripts")
+	if len(files) != 1 {
+		t.Fatalf("expected one package script, got %d", len(files))
+	}
+	jsInfo := &JsScriptInfo{Name: "escape", Filename: "./" + files[0].Path, Enable: true, PackageID: pkgID}
+	d.JsLoadScriptRaw(jsInfo)
+	if jsInfo.ErrText != "" {
+		t.Fatalf("package script escaped the sandbox: %s", jsInfo.ErrText)
+	}
+}
+
+// loadTestPackageScript 安装以 scripts/main.js 为唯一脚本的扩展包并加载该脚本，files 为包内的其他文件
+func loadTestPackageScript(t *testing.T, d *Dice, pm *PackageManager, pkgID, script string, files map[string]string) *JsScriptInfo {
+	t.Helper()
+	packFiles := map[string]string{"scripts/main.js": script}
+	for name, content := range files {
+		packFiles[name] = content
+	}
+	archive := createTestSealPack(t, "", pkgID, "1.0.0",
+		map[string][]string{"scripts": {"scripts/main.js"}}, packFiles)
+	if err := pm.Install(archive); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if _, err := pm.Enable(pkgID); err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+
+	d.JsInit()
+	t.Cleanup(func() {
+		if d.JsScriptCron != nil {
+			d.JsScriptCron.Stop()
+		}
+		d.ExtLoopManager.SetLoop(nil)
+	})
+
+	scripts := pm.GetEnabledContentFiles("scripts")
+	if len(scripts) != 1 {
+		t.Fatalf("expected one package script, got %d", len(scripts))
+	}
+	jsInfo := &JsScriptInfo{Name: pkgID, Filename: "./" + scripts[0].Path, Enable: true, PackageID: pkgID}
+	d.JsLoadScriptRaw(jsInfo)
+	return jsInfo
+}
+
+func newTestSandboxDice(t *testing.T) (*Dice, *PackageManager) {
+	t.Helper()
+	d, pm := newTestPackageManager(t)
+	d.ImSession = &IMSession{
+		ServiceAtNew: new(SyncMap[string, *GroupInfo]),
+		EndPoints:    []*EndPointInfo{},
+	}
+	d.DirtyGroups = new(SyncMap[string, int64])
+	d.AttrsManager = &AttrsManager{}
+	if err := pm.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	return d, pm
+}
+
+func TestJsPackageRequireStaysInPackage(t *testing.T) {
+	d, pm := newTestSandboxDice(t)
+
+	// _userdata 可写但不在安装目录中，写入的脚本不能被 require 成普通脚本取得原始 fetch/seal
+	const pkgID = "alice/require-escape"
+	payload, err := filepath.Abs(filepath.Join(pm.getUserDataPath(pkgID), "x.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, err := filepath.Rel(filepath.Join(pm.getPackageInstallPath(pkgID), "scripts"), filepath.Join(pm.getUserDataPath(pkgID), "x.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := strings.Join([]string{
+		"if (require('./lib/util').ok !== true) throw new Error('package module not loaded');",
+		"const fs = require('fs');",
+		"fs.writeFileSync('_userdata/x.js', 'module.exports={fetch:fetch,WebSocket:WebSocket,seal:seal};');",
+		"for (const p of [" + strconv.Quote(payload) + ", " + strconv.Quote("./"+filepath.ToSlash(rel)) + "]) {",
+		"  let err = '';",
+		"  try { require(p); } catch (e) { err = String(e); }",
+		"  if (err.indexOf('file_access') < 0) throw new Error('required module outside the package: ' + p + ' ' + err);",
+		"}",
+	}, "\n")
+	jsInfo := loadTestPackageScript(t, d, pm, pkgID, script, map[string]string{"scripts/lib/util.js": "module.exports = { ok: true };"})
+	if jsInfo.ErrText != "" {
+		t.Fatalf("package script escaped the sandbox: %s", jsInfo.ErrText)
+	}
+}
+
+func TestJsPackageSealViewHidesRealIPC(t *testing.T) {
+	d, pm := newTestSandboxDice(t)
+
+	// seal 视图不能以原始 seal 为原型，否则可以经原型取回未绑定身份的 ipc
+	script := strings.Join([]string{
+		"if (Object.getPrototypeOf(seal).ipc !== undefined) throw new Error('real ipc reachable from package seal');",
+		"if (Object.getPrototypeOf(globalThis.seal).ipc !== undefined) throw new Error('real ipc reachable from global seal');",
+		"if (seal.ipc === undefined || typeof seal.ext.find !== 'function') throw new Error('seal view incomplete');",
+	}, "\n")
+	jsInfo := loadTestPackageScript(t, d, pm, "alice/ipc-escape", script, nil)
+	if jsInfo.ErrText != "" {
+		t.Fatalf("package script escaped the sandbox: %s", jsInfo.ErrText)
+	}
+}
+
+func TestJsNonPackageScriptIsNotSandboxed(t *testing.T) {
+	d := &Dice{
+		Logger:     zap.NewNop().Sugar(),
+		BaseConfig: BaseConfig{DataDir: t.TempDir()},
+		ImSession: &IMSession{
+			ServiceAtNew: new(SyncMap[string, *GroupInfo]),
+			EndPoints:    []*EndPointInfo{},
+		},
+		DirtyGroups:  new(SyncMap[string, int64]),
+		AttrsManager: &AttrsManager{},
+	}
+	d.JsInit()
+	defer func() {
+		if d.JsScriptCron != nil {
+			d.JsScriptCron.Stop()
+		}
+		d.ExtLoopManager.SetLoop(nil)
+	}()
+
+	path := filepath.Join(d.BaseConfig.DataDir, "scripts", "plain.js")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("if (typeof __sealSandbox__ !== 'undefined') throw new Error('sandboxed');\n"+
+		"if (typeof fetch !== 'function' || typeof WebSocket !== 'function' || !seal.ipc) throw new Error('missing globals');"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	jsInfo := &JsScriptInfo{Name: "plain", Filename: path, Enable: true, InstallTime: time.Now().Unix()}
+	d.JsLoadScriptRaw(jsInfo)
+	if jsInfo.ErrText != "" {
+		t.Fatalf("plain script failed: %s", jsInfo.ErrText)
+	}
+}
@@ -0,0 +1,212 @@
+package dice
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// ErrJsExtSuspended 扩展因多次执行超时已被自动停用，回调不再执行
+var ErrJsExtSuspended = errors.New("扩展因多次执行超时已被停用")
+
+// JsExtStats 单个 JS 扩展的执行统计，供 /js/status 展示
+type JsExtStats struct {
+	Name          string `json:"name"`
+	Calls         int64  `json:"calls"`         // 指令、事件回调与定时任务的执行次数
+	Errors        int64  `json:"errors"`        // 抛出异常的次数，包含超时中断
+	Overruns      int64  `json:"overruns"`      // 超出执行时间预算被中断的次数
+	CPUTimeMs     int64  `json:"cpuTimeMs"`     // 在 JS 运行时中累计占用的时间
+	MaxTimeMs     int64  `json:"maxTimeMs"`     // 单次执行的最长耗时
+	BudgetMs      int64  `json:"budgetMs"`      // 当前生效的单次执行预算，0 为不限制
+	LastOverrunAt int64  `json:"lastOverrunAt"` // 最近一次超时的时间戳
+	Suspended     bool   `json:"suspended"`     // 是否已被自动停用
+}
+
+// jsExecStats 挂在 ExtInfo 上的执行计数，随扩展重载而重置
+type jsExecStats struct {
+	mu            sync.Mutex
+	calls         int64
+	errors        int64
+	overruns      int64
+	cpuTime       time.Duration
+	maxTime       time.Duration
+	lastOverrunAt int64
+	suspended     bool
+}
+
+func (s *jsExecStats) isSuspended() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.suspended
+}
+
+// jsExecOverrun 超时中断时传给 vm.Interrupt 的值
+type jsExecOverrun struct {
+	budget time.Duration
+}
+
+func (e *jsExecOverrun) Error() string {
+	return fmt.Sprintf("执行超过 %v 被中断", e.budget)
+}
+
+// jsExecBudget 返回扩展单次执行的时间预算，扩展单独的设置优先于全局设置，0 为不限制
+func (d *Dice) jsExecBudget(ext *ExtInfo) time.Duration {
+	ms := d.Config.JsExecTimeout
+	if ext != nil {
+		if v, ok := d.Config.JsExtExecTimeouts[ext.Name]; ok {
+			ms = v
+		}
+	}
+	if ms <= 0 {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// jsWatchedCall 执行扩展的 JS 回调：超出时间预算时中断 vm，并记录扩展的调用次数、耗时与错误。
+// f 中的 panic 会转为 error 返回；扩展已被自动停用时不执行 f，返回 ErrJsExtSuspended。
+// ext 为 nil 时仍按全局预算执行，但不记录统计。
+func (d *Dice) jsWatchedCall(vm *goja.Runtime, ext *ExtInfo, kind string, f func()) (err error) {
+	if ext != nil && ext.execStats.isSuspended() {
+		return ErrJsExtSuspended
+	}
+
+	budget := d.jsExecBudget(ext)
+	var (
+		timerLock sync.Mutex
+		finished  bool
+		fired     bool
+		timer     *time.Timer
+	)
+	if budget > 0 && vm != nil {
+		timer = time.AfterFunc(budget, func() {
+			timerLock.Lock()
+			defer timerLock.Unlock()
+			if finished {
+				return
+			}
+			fired = true
+			vm.Interrupt(&jsExecOverrun{budget: budget})
+		})
+	}
+
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		r := recover()
+
+		timerLock.Lock()
+		finished = true
+		overrun := fired
+		timerLock.Unlock()
+		if timer != nil {
+			timer.Stop()
+		}
+		if overrun {
+			// 中断可能在 JS 返回后才触发，需要清除，避免影响下一次执行
+			vm.ClearInterrupt()
+			err = &jsExecOverrun{budget: budget}
+		} else if r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+		if ext != nil {
+			d.recordJsExec(ext, kind, elapsed, err != nil, overrun)
+		}
+	}()
+
+	f()
+	return nil
+}
+
+// recordJsExec 累计执行统计，超时次数达到上限时停用扩展
+func (d *Dice) recordJsExec(ext *ExtInfo, kind string, elapsed time.Duration, failed bool, overrun bool) {
+	stats := &ext.execStats
+	stats.mu.Lock()
+	stats.calls++
+	stats.cpuTime += elapsed
+	if elapsed > stats.maxTime {
+		stats.maxTime = elapsed
+	}
+	if failed {
+		stats.errors++
+	}
+	suspend := false
+	if overrun {
+		stats.overruns++
+		stats.lastOverrunAt = time.Now().Unix()
+		limit := int64(d.Config.JsOverrunDisableCount)
+		if limit > 0 && stats.overruns >= limit && !stats.suspended {
+			stats.suspended = true
+			suspend = true
+		}
+	}
+	overruns := stats.overruns
+	stats.mu.Unlock()
+
+	if !overrun {
+		return
+	}
+	d.Logger.Warnf("扩展<%s>的%s执行耗时 %v，超出预算被中断(累计 %d 次)", ext.Name, kind, elapsed, overruns)
+	if suspend {
+		d.Logger.Errorf("扩展<%s>累计 %d 次执行超时，已自动停用", ext.Name, overruns)
+		d.jsSuspendScript(ext)
+	}
+}
+
+// jsSuspendScript 将扩展所在的脚本标记为禁用，重载后不再加载；由自动保存写入配置
+func (d *Dice) jsSuspendScript(ext *ExtInfo) {
+	if ext.Source == nil || ext.Source.Name == "" {
+		return
+	}
+	if d.Config.DisabledJsScripts == nil {
+		d.Config.DisabledJsScripts = map[string]bool{}
+	}
+	d.Config.DisabledJsScripts[ext.Source.Name] = true
+	ext.Source.Enable = false
+	d.LastUpdatedTime = time.Now().Unix()
+}
+
+// Stats 返回扩展的执行统计快照
+func (i *ExtInfo) Stats() JsExtStats {
+	stats := &i.execStats
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	result := JsExtStats{
+		Name:          i.Name,
+		Calls:         stats.calls,
+		Errors:        stats.errors,
+		Overruns:      stats.overruns,
+		CPUTimeMs:     stats.cpuTime.Milliseconds(),
+		MaxTimeMs:     stats.maxTime.Milliseconds(),
+		LastOverrunAt: stats.lastOverrunAt,
+		Suspended:     stats.suspended,
+	}
+	if i.dice != nil {
+		result.BudgetMs = i.dice.jsExecBudget(i).Milliseconds()
+	}
+	return result
+}
+
+// JsExtStatsList 返回已加载 JS 扩展的执行统计，按名称排序
+func (d *Dice) JsExtStatsList() []JsExtStats {
+	result := []JsExtStats{}
+	if d.JsExtRegistry == nil {
+		return result
+	}
+	d.JsExtRegistry.Range(func(_ string, ext *ExtInfo) bool {
+		result = append(result, ext.Stats())
+		return true
+	})
+	sort.Slice(result, func(a, b int) bool {
+		return result[a].Name < result[b].Name
+	})
+	return result
+}
@@ -0,0 +1,70 @@
+package dice //nolint:testpackage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dop251/goja"
+	"go.uber.org/zap"
+)
+
+func TestJsWatchedCallInterruptsAndSuspends(t *testing.T) {
+	d := &Dice{Logger: zap.NewNop().Sugar()}
+	d.Config.JsExecTimeout = 1000
+	d.Config.JsExtExecTimeouts = map[string]int64{"loop-ext": 30}
+	d.Config.JsOverrunDisableCount = 2
+	d.Config.DisabledJsScripts = map[string]bool{}
+
+	script := &JsScriptInfo{Name: "loop-script", Enable: true}
+	ext := &ExtInfo{Name: "loop-ext", IsJsExt: true, Source: script, dice: d}
+	vm := goja.New()
+
+	runLoop := func() error {
+		return d.jsWatchedCall(vm, ext, "指令", func() {
+			_, err := vm.RunString("while (true) {}")
+			if err != nil {
+				panic(err)
+			}
+		})
+	}
+
+	var overrun *jsExecOverrun
+	if err := runLoop(); !errors.As(err, &overrun) {
+		t.Fatalf("first call error = %v, want overrun", err)
+	}
+	// 中断标记已清除，正常脚本可以继续执行
+	var got int64
+	if err := d.jsWatchedCall(vm, ext, "指令", func() {
+		v, err := vm.RunString("1 + 1")
+		if err != nil {
+			panic(err)
+		}
+		got = v.ToInteger()
+	}); err != nil || got != 2 {
+		t.Fatalf("normal call = (%d, %v), want (2, nil)", got, err)
+	}
+	if err := d.jsWatchedCall(vm, ext, "指令", func() {
+		panic("boom")
+	}); err == nil || err.Error() != "boom" {
+		t.Fatalf("panic call error = %v, want boom", err)
+	}
+
+	if err := runLoop(); !errors.As(err, &overrun) {
+		t.Fatalf("second call error = %v, want overrun", err)
+	}
+	stats := ext.Stats()
+	if stats.Calls != 4 || stats.Errors != 3 || stats.Overruns != 2 || !stats.Suspended || stats.BudgetMs != 30 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if !d.Config.DisabledJsScripts["loop-script"] || script.Enable {
+		t.Fatalf("script should be disabled after repeated overruns")
+	}
+
+	called := false
+	if err := d.jsWatchedCall(vm, ext, "指令", func() { called = true }); !errors.Is(err, ErrJsExtSuspended) || called {
+		t.Fatalf("suspended call = (%v, called=%v), want ErrJsExtSuspended", err, called)
+	}
+	if ext.Stats().Calls != 4 {
+		t.Fatalf("suspended calls should not be counted")
+	}
+}
@@ -11,7 +11,9 @@ import (
 	"github.com/robfig/cron/v3"
 	"gopkg.in/yaml.v3"
 
+	"sealdice-core/dice/storylog"
 	"sealdice-core/logger"
+	"sealdice-core/utils/dboperator/dbconfig"
 	"sealdice-core/utils/dboperator/engine"
 )
 
@@ -63,6 +65,9 @@ type DiceManager struct { //nolint:revive
 	BackupCleanCron      string              // 如果使用cron触发, 表达式
 	backupCleanCronID    cron.EntryID
 
+	// dice.yaml 中的 database 段，由 dboperator 在启动时读取，这里只负责原样写回
+	DatabaseConfig dbconfig.Config
+
 	AppBootTime      int64
 	AppVersionCode   int64
 	AppVersionOnline *VersionInfo
@@ -77,6 +82,10 @@ type DiceManager struct { //nolint:revive
 	UserIDCache    SyncMap[string, int64]               // 用户id缓存 key username (string) value int64 目前仅Telegram adapter使用
 
 	Cron                 *cron.Cron
+	LogSearch            *LogSearch // 日志全文搜索，索引打开失败时为 nil
+	StoryBackendConfig   storylog.BackendConfig
+	StoryBackend         *storylog.Backend    // 内置日志后端，未启用时为 nil
+	LogMediaStore        *storylog.MediaStore // 日志中的图片与文件
 	ServiceName          string
 	JustForTest          bool
 	JsRegistry           *require.Registry
@@ -110,6 +119,11 @@ type Configs struct { //nolint:revive
 
 	ServiceName string `yaml:"serviceName"`
 
+	// Database 数据库连接配置，修改后需重启生效
+	Database dbconfig.Config `yaml:"database,omitempty"`
+	// StoryLogBackend 内置日志后端，修改后需重启生效
+	StoryLogBackend storylog.BackendConfig `yaml:"storyLogBackend,omitempty"`
+
 	ConfigVersion int `yaml:"configVersion"`
 }
 
@@ -199,6 +213,8 @@ func (dm *DiceManager) LoadDice() {
 	}
 
 	dm.ServeAddress = dc.ServeAddress
+	dm.DatabaseConfig = dc.Database
+	dm.StoryBackendConfig = dc.StoryLogBackend
 	dm.HelpDocEngineType = dc.HelpDocEngineType
 	dm.UIPasswordHash = dc.UIPasswordHash
 	dm.UIPasswordSalt = dc.UIPasswordSalt
@@ -247,6 +263,8 @@ func (dm *DiceManager) Save() {
 	dc.BackupClean.Trigger = int(dm.BackupCleanTrigger)
 	dc.BackupClean.Cron = dm.BackupCleanCron
 	dc.ServiceName = dm.ServiceName
+	dc.Database = dm.DatabaseConfig
+	dc.StoryLogBackend = dm.StoryBackendConfig
 	dc.ConfigVersion = 9914
 
 	dm.AccessTokens.Range(func(k string, v bool) bool {
@@ -298,6 +316,9 @@ func (dm *DiceManager) InitDice(writer *logger.UIWriter) {
 		dm.InitHelp()
 	}()
 
+	dm.InitLogSearch()
+	dm.InitStoryBackend()
+	dm.InitLogMedia()
 	dm.ResetAutoBackup()
 	dm.ResetBackupClean()
 }
@@ -0,0 +1,209 @@
+package docengine
+
+import (
+	"strconv"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/analysis/lang/cjk"
+)
+
+// LogIndex 跑团日志消息的全文索引，文档 ID 为 log_items.id。
+// 只索引用于筛选的字段和消息正文，搜索结果需要回数据库取完整内容。
+type LogIndex struct {
+	Index          bleve.Index
+	freshlyCreated bool
+}
+
+// LogIndexItem 一条待索引的日志消息
+type LogIndexItem struct {
+	ID       uint64
+	LogID    uint64
+	GroupID  string
+	IMUserID string
+	Time     int64
+	IsDice   bool
+	Message  string
+}
+
+type logIndexDoc struct {
+	Group   string  `json:"group"`
+	Log     string  `json:"log"`
+	User    string  `json:"user"`
+	Time    float64 `json:"time"`
+	Dice    bool    `json:"dice"`
+	Message string  `json:"message"`
+}
+
+// LogSearchQuery 搜索条件，零值的条件不参与筛选
+type LogSearchQuery struct {
+	Keyword   string
+	GroupID   string
+	LogID     uint64
+	IMUserID  string
+	TimeBegin int64 // 包含，unix 秒
+	TimeEnd   int64 // 包含，unix 秒
+	DiceOnly  bool
+	PageSize  int
+	PageNum   int
+}
+
+// OpenLogIndex 打开 dir 下的索引，不存在时新建
+func OpenLogIndex(dir string) (*LogIndex, error) {
+	i, err := bleve.Open(dir)
+	if err == nil {
+		return &LogIndex{Index: i}, nil
+	}
+
+	keywordMapping := bleve.NewKeywordFieldMapping()
+	keywordMapping.Analyzer = keyword.Name
+	// 中日韩文字按二元组切分，长度为 2 以上的关键词才能命中
+	messageMapping := bleve.NewTextFieldMapping()
+	messageMapping.Analyzer = cjk.AnalyzerName
+	messageMapping.Store = false
+
+	docMapping := bleve.NewDocumentMapping()
+	docMapping.AddFieldMappingsAt("group", keywordMapping)
+	docMapping.AddFieldMappingsAt("log", keywordMapping)
+	docMapping.AddFieldMappingsAt("user", keywordMapping)
+	docMapping.AddFieldMappingsAt("time", bleve.NewNumericFieldMapping())
+	docMapping.AddFieldMappingsAt("dice", bleve.NewBooleanFieldMapping())
+	docMapping.AddFieldMappingsAt("message", messageMapping)
+	mapping := bleve.NewIndexMapping()
+	mapping.DefaultMapping = docMapping
+
+	i, err = bleve.New(dir, mapping)
+	if err != nil {
+		return nil, err
+	}
+	return &LogIndex{Index: i, freshlyCreated: true}, nil
+}
+
+// FreshlyCreated 索引是否为本次新建，新建的索引需要从数据库重建
+func (l *LogIndex) FreshlyCreated() bool {
+	return l.freshlyCreated
+}
+
+func (l *LogIndex) Close() {
+	_ = l.Index.Close()
+}
+
+// Add 添加或覆盖索引中的消息
+func (l *LogIndex) Add(items []LogIndexItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	batch := l.Index.NewBatch()
+	for _, item := range items {
+		doc := logIndexDoc{
+			Group:   item.GroupID,
+			Log:     strconv.FormatUint(item.LogID, 10),
+			User:    item.IMUserID,
+			Time:    float64(item.Time),
+			Dice:    item.IsDice,
+			Message: item.Message,
+		}
+		if err := batch.Index(strconv.FormatUint(item.ID, 10), doc); err != nil {
+			return err
+		}
+	}
+	return l.Index.Batch(batch)
+}
+
+// Delete 从索引中移除消息
+func (l *LogIndex) Delete(ids []uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	batch := l.Index.NewBatch()
+	for _, id := range ids {
+		batch.Delete(strconv.FormatUint(id, 10))
+	}
+	return l.Index.Batch(batch)
+}
+
+// DeleteLog 移除整份日志的消息
+func (l *LogIndex) DeleteLog(logID uint64) error {
+	q := bleve.NewTermQuery(strconv.FormatUint(logID, 10))
+	q.SetField("log")
+	for {
+		req := bleve.NewSearchRequestOptions(q, deleteSearchBatchSize, 0, false)
+		res, err := l.Index.Search(req)
+		if err != nil {
+			return err
+		}
+		if len(res.Hits) == 0 {
+			return nil
+		}
+		batch := l.Index.NewBatch()
+		for _, hit := range res.Hits {
+			batch.Delete(hit.ID)
+		}
+		if err = l.Index.Batch(batch); err != nil {
+			return err
+		}
+	}
+}
+
+// Search 搜索消息，返回按相关度排序的消息 ID 与命中总数
+func (l *LogIndex) Search(q LogSearchQuery) ([]uint64, uint64, error) {
+	message := bleve.NewMatchPhraseQuery(q.Keyword)
+	message.SetField("message")
+	conj := bleve.NewConjunctionQuery(message)
+
+	addTerm := func(field, value string) {
+		if value == "" {
+			return
+		}
+		t := bleve.NewTermQuery(value)
+		t.SetField(field)
+		conj.AddQuery(t)
+	}
+	addTerm("group", q.GroupID)
+	if q.LogID > 0 {
+		addTerm("log", strconv.FormatUint(q.LogID, 10))
+	}
+	addTerm("user", q.IMUserID)
+	if q.TimeBegin > 0 || q.TimeEnd > 0 {
+		var minTime, maxTime *float64
+		if q.TimeBegin > 0 {
+			v := float64(q.TimeBegin)
+			minTime = &v
+		}
+		if q.TimeEnd > 0 {
+			v := float64(q.TimeEnd)
+			maxTime = &v
+		}
+		inclusive := true
+		r := bleve.NewNumericRangeInclusiveQuery(minTime, maxTime, &inclusive, &inclusive)
+		r.SetField("time")
+		conj.AddQuery(r)
+	}
+	if q.DiceOnly {
+		b := bleve.NewBoolFieldQuery(true)
+		b.SetField("dice")
+		conj.AddQuery(b)
+	}
+
+	if q.PageSize <= 0 {
+		q.PageSize = 10
+	}
+	if q.PageNum < 1 {
+		q.PageNum = 1
+	}
+	req := bleve.NewSearchRequestOptions(conj, q.PageSize, (q.PageNum-1)*q.PageSize, false)
+	req.SortBy([]string{"-_score", "-time"})
+	res, err := l.Index.Search(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	ids := make([]uint64, 0, len(res.Hits))
+	for _, hit := range res.Hits {
+		id, errParse := strconv.ParseUint(hit.ID, 10, 64)
+		if errParse != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, res.Total, nil
+}
@@ -0,0 +1,124 @@
+package docengine //nolint:testpackage // Keep alongside the other index tests.
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func newTestLogIndex(t *testing.T) *LogIndex {
+	t.Helper()
+
+	index, err := OpenLogIndex(filepath.Join(t.TempDir(), "log_index"))
+	if err != nil {
+		t.Fatalf("OpenLogIndex() error = %v", err)
+	}
+	t.Cleanup(index.Close)
+	if !index.FreshlyCreated() {
+		t.Fatalf("FreshlyCreated() = false for a new index")
+	}
+
+	items := []LogIndexItem{
+		{ID: 1, LogID: 10, GroupID: "QQ-Group:1", IMUserID: "QQ:100", Time: 1000, Message: "我们在港口遭遇了深潜者"},
+		{ID: 2, LogID: 10, GroupID: "QQ-Group:1", IMUserID: "QQ:200", Time: 2000, Message: "深潜者从水里爬了出来"},
+		{ID: 3, LogID: 10, GroupID: "QQ-Group:1", IMUserID: "QQ:1", Time: 2500, IsDice: true, Message: "<KP>的侦查检定结果为 深潜者 30/60 成功"},
+		{ID: 4, LogID: 20, GroupID: "QQ-Group:2", IMUserID: "QQ:100", Time: 3000, Message: "另一个群里的深潜者"},
+		{ID: 5, LogID: 20, GroupID: "QQ-Group:2", IMUserID: "QQ:100", Time: 3500, Message: "the deep one rises"},
+	}
+	if err = index.Add(items); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	return index
+}
+
+func searchIDs(t *testing.T, index *LogIndex, q LogSearchQuery) []uint64 {
+	t.Helper()
+
+	ids, total, err := index.Search(q)
+	if err != nil {
+		t.Fatalf("Search(%+v) error = %v", q, err)
+	}
+	if int(total) < len(ids) {
+		t.Fatalf("Search(%+v) total = %d, less than %d hits", q, total, len(ids))
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestLogIndexSearchFilters(t *testing.T) {
+	index := newTestLogIndex(t)
+
+	tests := []struct {
+		name string
+		q    LogSearchQuery
+		want []uint64
+	}{
+		{name: "keyword", q: LogSearchQuery{Keyword: "深潜者"}, want: []uint64{1, 2, 3, 4}},
+		{name: "english", q: LogSearchQuery{Keyword: "deep one"}, want: []uint64{5}},
+		{name: "group", q: LogSearchQuery{Keyword: "深潜者", GroupID: "QQ-Group:1"}, want: []uint64{1, 2, 3}},
+		{name: "log", q: LogSearchQuery{Keyword: "深潜者", LogID: 20}, want: []uint64{4}},
+		{name: "user", q: LogSearchQuery{Keyword: "深潜者", IMUserID: "QQ:100"}, want: []uint64{1, 4}},
+		{name: "time range", q: LogSearchQuery{Keyword: "深潜者", TimeBegin: 2000, TimeEnd: 2500}, want: []uint64{2, 3}},
+		{name: "dice only", q: LogSearchQuery{Keyword: "深潜者", DiceOnly: true}, want: []uint64{3}},
+		{name: "no match", q: LogSearchQuery{Keyword: "拜亚基"}, want: []uint64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchIDs(t, index, tt.q); !slices.Equal(got, tt.want) {
+				t.Fatalf("Search() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogIndexUpdateAndDelete(t *testing.T) {
+	index := newTestLogIndex(t)
+
+	if err := index.Add([]LogIndexItem{{ID: 2, LogID: 10, GroupID: "QQ-Group:1", Time: 2000, Message: "改过的消息"}}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if got := searchIDs(t, index, LogSearchQuery{Keyword: "深潜者", GroupID: "QQ-Group:1"}); !slices.Equal(got, []uint64{1, 3}) {
+		t.Fatalf("after edit Search() = %v, want [1 3]", got)
+	}
+
+	if err := index.Delete([]uint64{1}); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if got := searchIDs(t, index, LogSearchQuery{Keyword: "深潜者"}); !slices.Equal(got, []uint64{3, 4}) {
+		t.Fatalf("after delete Search() = %v, want [3 4]", got)
+	}
+
+	if err := index.DeleteLog(20); err != nil {
+		t.Fatalf("DeleteLog() error = %v", err)
+	}
+	if got := searchIDs(t, index, LogSearchQuery{Keyword: "深潜者"}); !slices.Equal(got, []uint64{3}) {
+		t.Fatalf("after DeleteLog Search() = %v, want [3]", got)
+	}
+	if got := searchIDs(t, index, LogSearchQuery{Keyword: "deep"}); !slices.Equal(got, []uint64{}) {
+		t.Fatalf("after DeleteLog Search() = %v, want []", got)
+	}
+}
+
+func TestLogIndexReopen(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "log_index")
+	index, err := OpenLogIndex(dir)
+	if err != nil {
+		t.Fatalf("OpenLogIndex() error = %v", err)
+	}
+	if err = index.Add([]LogIndexItem{{ID: 1, LogID: 1, GroupID: "g", Message: "调查员"}}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	index.Close()
+
+	index, err = OpenLogIndex(dir)
+	if err != nil {
+		t.Fatalf("OpenLogIndex() reopen error = %v", err)
+	}
+	defer index.Close()
+	if index.FreshlyCreated() {
+		t.Fatalf("FreshlyCreated() = true for an existing index")
+	}
+	if got := searchIDs(t, index, LogSearchQuery{Keyword: "调查员"}); !slices.Equal(got, []uint64{1}) {
+		t.Fatalf("Search() = %v, want [1]", got)
+	}
+}
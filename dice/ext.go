@@ -332,13 +332,12 @@ func (i *ExtInfo) callWithJsCheck(d *Dice, f func()) {
 			waitRun := make(chan int, 1)
 			loop.RunOnLoop(func(vm *goja.Runtime) {
 				defer func() {
-					if r := recover(); r != nil {
-						d.Logger.Error("JS脚本异常:", r)
-					}
 					waitRun <- 1
 				}()
 
-				f()
+				if err := d.jsWatchedCall(vm, i, "事件回调", f); err != nil && !errors.Is(err, ErrJsExtSuspended) {
+					d.Logger.Error("JS脚本异常:", err)
+				}
 			})
 			<-waitRun
 		} else {
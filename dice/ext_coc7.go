@@ -71,6 +71,7 @@ func RegisterBuiltinExtCoc7(self *Dice) {
 		".ra p2 <属性表达式> // 多个奖励骰或惩罚骰\n" +
 		".ra 3#p <属性表达式> // 多重检定\n" +
 		".ra <属性表达式> @某人 // 对某人做检定(使用他的属性)\n" +
+		".ra <属性表达式> @npc:<名字> // 使用本群NPC卡或他人共享的卡检定\n" +
 		".rch/rah // 暗中检定，和检定指令用法相同"
 
 	cmdRc := &CmdItemInfo{
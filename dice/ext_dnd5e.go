@@ -531,9 +531,10 @@ func RegisterBuiltinExtDnd5e(self *Dice) {
 					}
 					// 添加对应commandItems
 					commandItems = append(commandItems, map[string]interface{}{
-						"expr":   expr,
-						"reason": reason,
-						"result": d20Result + modifier,
+						"expr":    expr,
+						"reason":  reason,
+						"result":  d20Result + modifier,
+						"outcome": d20Result,
 					})
 				}
 				// 拼接文本
@@ -185,10 +185,17 @@ func RegisterBuiltinExtLog(self *Dice) {
 .log del <日志名> // 删除一份日志
 .log stat [<日志名>] // 查看统计
 .log stat [<日志名>] --all // 查看统计(全团)，--all前必须有空格
+.log report [<日志名>] // 跑团报告：时长、每人发言、各调查员检定与理智损失
 .log list <群号> // 查看指定群的日志列表(无法取得日志时，找骰主做这个操作)
 .log masterget <群号> <日志名> // 重新上传日志，并获取链接(无法取得日志时，找骰主做这个操作)
-.log export <日志名> // 直接取得日志txt(服务出问题或有其他需要时使用)
-.log export <日志名> <邮箱地址> // 通过邮件取得日志txt，多个邮箱用空格隔开`
+.log export [<格式>] <日志名> // 直接取得日志文件(服务出问题或有其他需要时使用)，格式可选 txt(默认)/html/md/docx/json
+.log export [<格式>] <日志名> <邮箱地址> // 通过邮件取得日志文件，多个邮箱用空格隔开
+.log export ... --hideooc // 导出时去掉场外发言(以括号开头的消息)
+.log export ... --rename=昵称:角色名,昵称2:角色名2 // 导出时替换发言人的名字
+.log search <关键词> // 在本群的全部日志中搜索消息
+.log rename <日志名> <新日志名> // 日志改名
+.log merge <日志A> <日志B> // 把日志A的全部消息并入日志B，之后日志A不再存在
+.log split <日志名> <分割点> [<新日志名>] // 从分割点起的消息拆分为新日志，分割点可以是第几条消息(如 #120)或时间(如 2024-05-01 21:30、21:30)`
 
 	// const txtLogTip = "若未出现线上日志地址，可换时间获取，或联系骰主在data/default/log-exports路径下取出日志\n文件名: 群号_日志名_随机数.zip\n注意此文件log end/get后才会生成"
 
@@ -199,7 +206,7 @@ func RegisterBuiltinExtLog(self *Dice) {
 		Solve: func(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs) CmdExecuteResult {
 			group := ctx.Group
 			cmdArgs.ChopPrefixToArgsWith("on", "off", "del", "rm", "masterget",
-				"get", "end", "halt", "list", "new", "stat", "export")
+				"get", "end", "halt", "list", "new", "stat", "export", "search", "rename", "merge", "split", "report")
 
 			groupNotActiveCheck := func() bool {
 				if !group.IsActive(ctx) {
@@ -367,6 +374,7 @@ func RegisterBuiltinExtLog(self *Dice) {
 				} else {
 					err := service.LogDelete(ctx.Dice.DBOperator, group.GroupID, name)
 					if err == nil {
+						ctx.Dice.LogMediaCleanup()
 						ReplyToSender(ctx, msg, DiceFormatTmpl(ctx, "日志:记录_删除_成功"))
 					} else if errors.Is(err, service.ErrLogNotFound) {
 						ReplyToSender(ctx, msg, DiceFormatTmpl(ctx, "日志:记录_删除_失败_找不到"))
@@ -515,6 +523,30 @@ func RegisterBuiltinExtLog(self *Dice) {
 
 				ReplyToSender(ctx, msg, DiceFormatTmpl(ctx, "日志:记录_新建"))
 				return CmdExecuteResult{Matched: true, Solved: true}
+			} else if cmdArgs.IsArgEqual(1, "report") {
+				_, name := getLogName(ctx, msg, cmdArgs, 2)
+				if name == "" {
+					ReplyToSender(ctx, msg, "当前没有进行中的日志，请指定日志名")
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				var ok bool
+				if name, ok = resolveLogNameWithReply(group.GroupID, name); !ok {
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				tmpl := group.GetCharTemplate(ctx.Dice)
+				report, err := storylog.BuildReport(ctx.Dice.DBOperator, group.GroupID, name, storylog.ReportOptions{
+					Alias: tmpl.GetAlias,
+				})
+				if err != nil {
+					reply := err.Error()
+					if errors.Is(err, storylog.ErrExportEmpty) {
+						reply += logKeyHintText()
+					}
+					ReplyToSender(ctx, msg, reply)
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				ReplyToSender(ctx, msg, report.Text())
+				return CmdExecuteResult{Matched: true, Solved: true}
 			} else if cmdArgs.IsArgEqual(1, "stat") {
 				// group := ctx.Group
 				_, name := getLogName(ctx, msg, cmdArgs, 2)
@@ -565,8 +597,22 @@ func RegisterBuiltinExtLog(self *Dice) {
 					return CmdExecuteResult{Matched: true, Solved: true}
 				}
 
+				exportOpts := storylog.ExportOptions{Format: storylog.ExportFormatTxt}
+				argIndex := 2
+				if format, ok := storylog.ParseExportFormat(cmdArgs.GetArgN(2)); ok {
+					exportOpts.Format = format
+					argIndex = 3
+				}
+				exportOpts.HideOOC = cmdArgs.GetKwarg("hideooc") != nil
+				if kw := cmdArgs.GetKwarg("rename"); kw != nil {
+					exportOpts.NameMap = parseExportNameMap(kw.Value)
+				}
+				if ctx.Dice.Parent != nil {
+					exportOpts.Media = ctx.Dice.Parent.LogMediaStore
+				}
+
 				logName := getGroupLogName(group)
-				if newName := cmdArgs.GetArgN(2); newName != "" {
+				if newName := cmdArgs.GetArgN(argIndex); newName != "" {
 					logName = newName
 				}
 				if logName == "" {
@@ -584,7 +630,13 @@ func RegisterBuiltinExtLog(self *Dice) {
 				VarSetValueStr(ctx, "$t日期", now.ToShortDateString())
 				VarSetValueStr(ctx, "$t时间", now.ToShortTimeString())
 				logFileNamePrefix := DiceFormatTmpl(ctx, "日志:记录_导出_文件名前缀")
-				logFile, notice, err := GetLogTxt(ctx, group.GroupID, logName, logFileNamePrefix)
+				var logFile, notice string
+				var err error
+				if exportOpts.Format == storylog.ExportFormatTxt && !exportOpts.HideOOC && len(exportOpts.NameMap) == 0 {
+					logFile, notice, err = GetLogTxt(ctx, group.GroupID, logName, logFileNamePrefix)
+				} else {
+					logFile, notice, err = storylog.ExportLogFile(ctx.Dice.DBOperator, group.GroupID, logName, logFileNamePrefix, exportOpts)
+				}
 				if err != nil {
 					reply := err.Error()
 					if strings.Contains(reply, "此log不存在") || strings.Contains(reply, "名字是否正确") {
@@ -596,8 +648,8 @@ func RegisterBuiltinExtLog(self *Dice) {
 				defer os.Remove(logFile)
 
 				var emails []string
-				if len(cmdArgs.Args) > 2 {
-					emails = cmdArgs.Args[2:]
+				if len(cmdArgs.Args) > argIndex {
+					emails = cmdArgs.Args[argIndex:]
 					// 试图发送邮件
 					dice := ctx.Session.Parent
 					if dice.CanSendMail() {
@@ -639,6 +691,82 @@ func RegisterBuiltinExtLog(self *Dice) {
 				}
 				ReplyToSenderRaw(ctx, msg, reply, "skip")
 				return CmdExecuteResult{Matched: true, Solved: true}
+			} else if cmdArgs.IsArgEqual(1, "rename", "merge") {
+				nameA, nameB := cmdArgs.GetArgN(2), cmdArgs.GetArgN(3)
+				if nameA == "" || nameB == "" {
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+				var ok bool
+				if nameA, ok = resolveLogNameWithReply(group.GroupID, nameA); !ok {
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+
+				var change *service.LogChange
+				var err error
+				var reply string
+				if cmdArgs.IsArgEqual(1, "rename") {
+					change, err = service.LogRename(ctx.Dice.DBOperator, group.GroupID, nameA, nameB)
+					if err == nil {
+						reply = fmt.Sprintf("日志 %s 已改名为 %s", nameA, change.ToName)
+					}
+				} else {
+					if nameB, ok = resolveLogNameWithReply(group.GroupID, nameB); !ok {
+						return CmdExecuteResult{Matched: true, Solved: true}
+					}
+					change, err = service.LogMerge(ctx.Dice.DBOperator, group.GroupID, nameA, nameB)
+					if err == nil {
+						reply = fmt.Sprintf("已将日志 %s 的%d条消息并入 %s", nameA, change.Moved, nameB)
+					}
+				}
+				if err != nil {
+					reply = "操作失败: " + err.Error()
+					if errors.Is(err, service.ErrLogNotFound) {
+						reply += logKeyHintText()
+					}
+					ReplyToSender(ctx, msg, reply)
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				ctx.Dice.LogSyncGroupState(group.GroupID, nameA, change)
+				ReplyToSender(ctx, msg, reply)
+				return CmdExecuteResult{Matched: true, Solved: true}
+			} else if cmdArgs.IsArgEqual(1, "split") {
+				name := cmdArgs.GetArgN(2)
+				if name == "" || len(cmdArgs.Args) < 3 {
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+				at, used, ok := parseLogSplitAt(cmdArgs.Args[2:], time.Now())
+				if !ok {
+					ReplyToSender(ctx, msg, "无法识别分割点: "+cmdArgs.GetArgN(3)+"\n可以是第几条消息(如 #120)，或时间(如 2024-05-01 21:30、21:30)")
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				newName := cmdArgs.GetArgN(3 + used)
+				if name, ok = resolveLogNameWithReply(group.GroupID, name); !ok {
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				// 与 log del 一致，不处理当前日志：之后的消息仍会写入原日志，拆分出的部分就不再完整
+				if name == getGroupLogName(group) {
+					ReplyToSender(ctx, msg, fmt.Sprintf("记录 %s 正在进行，无法拆分。请先用 log end 结束记录，或用 log new 切换到其他日志", name))
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+
+				change, err := service.LogSplit(ctx.Dice.DBOperator, group.GroupID, name, newName, at)
+				if err != nil {
+					reply := "拆分失败: " + err.Error()
+					if errors.Is(err, service.ErrLogNotFound) {
+						reply += logKeyHintText()
+					}
+					ReplyToSender(ctx, msg, reply)
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				ReplyToSender(ctx, msg, fmt.Sprintf("已从日志 %s 拆分出%d条消息，新日志名为 %s", name, change.Moved, change.ToName))
+				return CmdExecuteResult{Matched: true, Solved: true}
+			} else if cmdArgs.IsArgEqual(1, "search") {
+				keyword := strings.TrimSpace(strings.Join(cmdArgs.Args[1:], " "))
+				if keyword == "" {
+					return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+				}
+				ReplyToSender(ctx, msg, logSearchText(ctx, group.GroupID, keyword))
+				return CmdExecuteResult{Matched: true, Solved: true}
 			} else {
 				return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
 			}
@@ -1037,7 +1165,9 @@ func RegisterBuiltinExtLog(self *Dice) {
 						RawMsgID:  msg.RawID,
 					}
 
-					LogAppend(ctx, ctx.Group.GroupID, logState.ID, logState.Name, &a)
+					if LogAppend(ctx, ctx.Group.GroupID, logState.ID, logState.Name, &a) {
+						ctx.Dice.logCaptureMedia(a.ID, msg.Segment)
+					}
 				}
 			}
 		},
@@ -1095,6 +1225,20 @@ func getSpecifiedGroupIfMaster(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs)
 	return "", false
 }
 
+// parseExportNameMap 解析 "昵称:角色名,昵称2:角色名2"，全角标点同样可用
+func parseExportNameMap(s string) map[string]string {
+	s = strings.NewReplacer("，", ",", "：", ":").Replace(s)
+	nameMap := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		from, to, ok := strings.Cut(pair, ":")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if ok && from != "" && to != "" {
+			nameMap[from] = to
+		}
+	}
+	return nameMap
+}
+
 func FilenameReplace(name string) string {
 	re := regexp.MustCompile(`[/:\*\?"<>\|\\]`)
 	return re.ReplaceAllString(name, "")
@@ -1264,6 +1408,9 @@ func logSendToBackend(ctx *MsgContext, groupID string, logName string, skipResol
 		UniformID: ctx.EndPoint.UserID,
 		GroupID:   groupID,
 	}
+	if dice.Parent != nil {
+		uploadCtx.Media = dice.Parent.LogMediaStore
+	}
 	uploadCtx.Version = storylog.StoryVersionV1
 
 	var unofficial bool
@@ -1271,6 +1418,12 @@ func logSendToBackend(ctx *MsgContext, groupID string, logName string, skipResol
 		unofficial = true
 		uploadCtx.Backends = []string{dice.AdvancedConfig.StoryLogBackendUrl}
 		uploadCtx.Token = dice.AdvancedConfig.StoryLogBackendToken
+	} else if dice.Parent != nil && dice.Parent.StoryBackend != nil {
+		// 启用了内置日志后端且没有另行指定后端时，上传到本机
+		unofficial = true
+		uploadCtx.Backends = []string{dice.Parent.StoryBackendUploadURL()}
+		uploadCtx.Token = dice.Parent.StoryBackendConfig.Token
+		uploadCtx.Version = storylog.StoryVersionV105
 	}
 	// 原则上1.5支持兼容V1的上传接口，只是需要木落改改代码
 	if dice.AdvancedConfig.Enable && dice.AdvancedConfig.StoryLogApiVersion != "" {
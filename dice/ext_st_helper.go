@@ -341,6 +341,13 @@ func cmdStValueMod(mctx *MsgContext, tmpl *GameSystemTemplate, attrs *Attributes
 		theNewValue = stInfo.ToModResult(mctx, cmdArgs, i, attrs, tmpl, theOldValue, theNewValue)
 	}
 
+	if isSetNew {
+		if err := tmpl.CheckAttrValue(i.name, theNewValue); err != nil {
+			i.invalid = fmt.Sprintf("%s=%s(%s)", i.name, theNewValue.ToString(), err.Error())
+			return
+		}
+	}
+
 	// 指令信息
 	commandInfo["items"] = append(commandInfo["items"].([]any), map[string]any{
 		"type":    "mod",
@@ -371,6 +378,7 @@ type stSetOrModInfoItem struct {
 	op           string
 	expr         string
 	appendedText string
+	invalid      string // 不符合模板属性约束的原因，此时不写入
 }
 
 func cmdStReadOrMod(ctx *MsgContext, tmpl *GameSystemTemplate, text string) (r *ds.VMValue, toSetItems []*stSetOrModInfoItem, toModItems []*stSetOrModInfoItem, err error) {
@@ -486,8 +494,10 @@ func getCmdStBase(soi CmdStOverrideInfo) *CmdItemInfo {
 		helpSt += ".st show <数字> // 展示高于<数字>的属性，如.st show 30\n"
 		helpSt += ".st clr // 清除属性\n"
 		helpSt += ".st fmt // 强制转卡为当前规则(改变卡片类型，转换同义词)\n"
+		helpSt += ".st undo // 撤销对当前卡的上一次修改，可连续撤销\n"
 		helpSt += ".st del <属性1> <属性2> ... // 删除属性，可多项，以空格间隔\n"
 		helpSt += ".st export // 导出\n"
+		helpSt += ".st <属性><值> @npc:<名字> // 修改本群NPC卡或共享卡的属性，需要编辑权限\n"
 		helpSt += ".st help // 帮助\n"
 		helpSt += ".st <属性><值> // 例：.st 敏捷50 力量3d6*5\n"
 		helpSt += ".st &<属性>=<式子> // 例：.st &手枪=1d6\n"
@@ -503,7 +513,7 @@ func getCmdStBase(soi CmdStOverrideInfo) *CmdItemInfo {
 		Help:          soi.HelpPrefix + helpSt,
 		AllowDelegate: true,
 		Solve: func(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs) CmdExecuteResult {
-			cmdArgs.ChopPrefixToArgsWith("del", "rm", "show", "list", "export")
+			cmdArgs.ChopPrefixToArgsWith("del", "rm", "show", "list", "export", "undo")
 			dice := ctx.Dice
 			val := cmdArgs.GetArgN(1)
 			mctx := GetCtxProxyFirst(ctx, cmdArgs)
@@ -545,6 +555,15 @@ func getCmdStBase(soi CmdStOverrideInfo) *CmdItemInfo {
 				}
 			}
 
+			switch val {
+			case "help", "show", "list", "export":
+			default:
+				if err := dice.AttrsManager.CheckEditableByCtx(mctx); err != nil {
+					ReplyToSender(mctx, msg, fmt.Sprintf("<%s>是只读的共享卡，无法修改", mctx.Player.Name))
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+			}
+
 			switch val {
 			case "help":
 				return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
@@ -643,6 +662,18 @@ func getCmdStBase(soi CmdStOverrideInfo) *CmdItemInfo {
 				VarSetValueInt64(mctx, "$t数量", int64(num))
 				ReplyToSender(mctx, msg, DiceFormatTmpl(mctx, "COC:属性设置_清除"))
 
+			case "undo":
+				h, err := dice.AttrsManager.Undo(attrs.ID)
+				if errors.Is(err, ErrAttrsHistoryEmpty) {
+					ReplyToSender(mctx, msg, "当前卡没有可撤销的修改")
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				if err != nil {
+					ReplyToSender(mctx, msg, "撤销失败: "+err.Error())
+					return CmdExecuteResult{Matched: true, Solved: true}
+				}
+				ReplyToSender(mctx, msg, fmt.Sprintf("已撤销修改 [%s]\n%s", AttrsHistoryTitle(h), FormatAttrsChanges(h.Changes, 10)))
+
 			case "fmt", "format":
 				cmdStCharFormat(mctx, tmpl)
 				ReplyToSender(mctx, msg, "角色卡片类型被强制修改为: "+ctx.Group.System)
@@ -719,6 +750,7 @@ func getCmdStBase(soi CmdStOverrideInfo) *CmdItemInfo {
 
 				// 处理直接设置属性
 				var text string
+				var changed, violations []string
 				validNum := int64(0)
 				if len(toSetItems) > 0 {
 					// 是 set
@@ -742,7 +774,12 @@ func getCmdStBase(soi CmdStOverrideInfo) *CmdItemInfo {
 									continue
 								}
 							}
+							if err := tmpl.CheckAttrValue(i.name, i.value); err != nil {
+								violations = append(violations, fmt.Sprintf("%s=%s(%s)", i.name, i.value.ToString(), err.Error()))
+								continue
+							}
 							attrs.Store(i.name, i.value)
+							changed = append(changed, i.name)
 						}
 						validNum++
 					}
@@ -770,6 +807,11 @@ func getCmdStBase(soi CmdStOverrideInfo) *CmdItemInfo {
 					chName := lo.Must(mctx.Dice.AttrsManager.LoadByCtx(mctx)).Name
 					for _, i := range toModItems {
 						cmdStValueMod(mctx, tmpl, attrs, commandInfo, i, cmdArgs, &soi)
+						if i.invalid != "" {
+							violations = append(violations, i.invalid)
+							continue
+						}
+						changed = append(changed, i.name)
 						VarSetValueStr(mctx, "$t当前绑定角色", chName)
 						text2 := DiceFormatTmpl(mctx, "COC:属性设置_增减_单项")
 						textItems = append(textItems, text2)
@@ -779,10 +821,12 @@ func getCmdStBase(soi CmdStOverrideInfo) *CmdItemInfo {
 					}
 
 					// text = DiceFormatTmpl(mctx, "COC:属性设置_增减")
-					VarSetValueStr(mctx, "$t变更列表", strings.Join(textItems, "\n"))
-					text = DiceFormatTmpl(mctx, "COC:属性设置_增减")
-					if len(appendedText) > 0 {
-						text = strings.TrimSpace(text) + strings.Join(appendedText, "\n")
+					if len(textItems) > 0 {
+						VarSetValueStr(mctx, "$t变更列表", strings.Join(textItems, "\n"))
+						text = DiceFormatTmpl(mctx, "COC:属性设置_增减")
+						if len(appendedText) > 0 {
+							text = strings.TrimSpace(text) + strings.Join(appendedText, "\n")
+						}
 					}
 
 					ctx.CommandInfo = commandInfo
@@ -790,11 +834,23 @@ func getCmdStBase(soi CmdStOverrideInfo) *CmdItemInfo {
 					SetCardType(mctx, tmpl.Name)
 				}
 
+				// 模板中的派生属性随输入一起更新
+				updated, failed := tmpl.RecomputeDerived(mctx, attrs, changed)
+				if len(updated) > 0 {
+					text += "\n自动计算: " + strings.Join(updated, " ")
+				}
+				if len(failed) > 0 {
+					text += "\n派生属性计算失败: " + strings.Join(failed, "；")
+				}
+				if len(violations) > 0 {
+					text += "\n以下属性不符合规则，未写入: " + strings.Join(violations, " ")
+				}
+
 				if rRestIput != "" {
 					text += "\n解析失败: " + rRestIput
 				}
 
-				ReplyToSender(mctx, msg, text)
+				ReplyToSender(mctx, msg, strings.TrimPrefix(text, "\n"))
 			}
 
 			if ctx.Player.AutoSetNameTemplate != "" {
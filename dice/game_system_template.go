@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/Masterminds/semver/v3"
+	wr "github.com/mroth/weightedrand"
 	"github.com/samber/lo"
 	ds "github.com/sealdice/dicescript"
 	"gopkg.in/yaml.v3"
@@ -18,9 +19,11 @@ import (
 
 // Attrs keeps attribute defaults and computed expressions.
 type Attrs struct {
-	Defaults         map[string]int    `yaml:"defaults"`
-	DefaultsComputed map[string]string `yaml:"defaultsComputed"`
-	DetailOverwrite  map[string]string `yaml:"detailOverwrite"`
+	Defaults         map[string]int          `yaml:"defaults"`
+	DefaultsComputed map[string]string       `yaml:"defaultsComputed"`
+	DetailOverwrite  map[string]string       `yaml:"detailOverwrite"`
+	Schema           map[string]*AttrSchema  `yaml:"schema"`  // 属性约束，.st 写入时检查
+	Derived          map[string]*AttrDerived `yaml:"derived"` // 派生属性，.st 修改输入后自动重算
 
 	DefaultsComputedReal map[string]*ds.VMValue `json:"-" yaml:"-"`
 }
@@ -61,6 +64,8 @@ type Commands struct {
 	Set SetConfig `yaml:"set"`
 	Sn  SnConfig  `yaml:"sn"`
 	St  StConfig  `yaml:"st"`
+
+	Checks map[string]*CheckConfig `yaml:"checks"` // 检定指令，键为指令名
 }
 
 // SetConfig configures the set command.
@@ -140,6 +145,9 @@ type GameSystemTemplate struct {
 	SetConfig    LegacySetConfig             `yaml:"-"`
 	NameTemplate map[string]NameTemplateItem `yaml:"-"`
 
+	checkCmdMap  CmdMapCls              // commands.checks 生成的指令
+	checkTextMap map[string]*wr.Chooser // textMap 中的文本，供检定指令使用
+
 	inited bool
 }
 
@@ -396,6 +404,7 @@ func (t *GameSystemTemplate) Init() {
 	if t.Attrs.DetailOverwrite == nil {
 		t.Attrs.DetailOverwrite = map[string]string{}
 	}
+	t.initChecks()
 
 	t.inited = true
 }
@@ -608,6 +617,9 @@ func loadGameSystemTemplateFromData(data []byte, format string) (*GameSystemTemp
 		tmpl.GameSystemTemplateV2 = &GameSystemTemplateV2{}
 	}
 	tmpl.Init()
+	if err := tmpl.Validate(); err != nil {
+		return nil, err
+	}
 	return tmpl, nil
 }
 
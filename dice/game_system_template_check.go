@@ -0,0 +1,501 @@
+package dice
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	wr "github.com/mroth/weightedrand"
+	ds "github.com/sealdice/dicescript"
+)
+
+// 模板中的检定指令:
+//
+//	commands:
+//	  checks:
+//	    move:
+//	      name: 行动
+//	      roll: 2d6
+//	      tiers:
+//	        - { name: 完全成功, op: '>=', value: '10', text: pbta:完全成功 }
+//	        - { name: 部分成功, op: '>=', value: '7' }
+//	        - { name: 失败, text: pbta:失败, modify: { 经验: '1' } }
+//	textMap:
+//	  pbta:
+//	    完全成功: [['你做到了，而且没有代价', 1]]
+//	    失败: [['事情变糟了', 1]]
+//
+// 以 .move 酷+1 突围 为例，参数开头的表达式(酷+1)为属性值，其后为原因；开头不是已知属性时整段都是原因。依次计算 roll 和 expr，
+// 按顺序取第一个满足条件的 tiers 作为判定结果，没有 op 的一项总是满足。
+// 群内通过 .set 切换到该规则后这些指令生效，优先于扩展中的同名指令，但不会覆盖内置指令。
+
+// 检定表达式与结果文本中可用的变量
+const (
+	checkVarAttr       = "$t属性"     // 属性表达式，如 酷+1
+	checkVarAttrValue  = "$t属性值"    // 属性表达式的值
+	checkVarReason     = "$t原因"     // 属性表达式之后的文本
+	checkVarRoll       = "$t出目"     // roll 的结果
+	checkVarResult     = "$t结果"     // expr 的结果
+	checkVarDetail     = "$t检定过程文本" // expr 的计算过程
+	checkVarName       = "$t检定名"
+	checkVarTier       = "$t判定结果"
+	checkVarTierText   = "$t判定文本"
+	checkVarAttrChange = "$t属性变化"
+)
+
+const checkDefaultExpr = checkVarRoll + " + " + checkVarAttrValue
+
+// checkDefaultText 未指定 text 时的结果文本
+const checkDefaultText = "{$t玩家}的{$t检定名}检定{% $t原因 ? `(` + $t原因 + `)` %}: {$t检定过程文本}={$t结果} {$t判定结果}" +
+	"{% $t判定文本 ? `\n` + $t判定文本 %}{% $t属性变化 ? `\n` + $t属性变化 %}"
+
+// CheckConfig 模板定义的一条检定指令
+type CheckConfig struct {
+	Name        string       `yaml:"name"` // 显示的检定名，默认为指令名
+	Aliases     []string     `yaml:"aliases"`
+	Help        string       `yaml:"help"`
+	Roll        string       `yaml:"roll"`        // 掷骰表达式，结果为 $t出目
+	Expr        string       `yaml:"expr"`        // 检定结果表达式，默认为 $t出目 + $t属性值
+	RequireAttr bool         `yaml:"requireAttr"` // 为 true 时必须给出属性
+	Tiers       []*CheckTier `yaml:"tiers"`
+	Text        string       `yaml:"text"` // 结果文本的模板键，如 pbta:检定
+}
+
+// CheckTier 检定的一档结果
+type CheckTier struct {
+	Name   string            `yaml:"name"`
+	Target string            `yaml:"target"` // 比较的左侧，默认为 $t结果
+	Op     string            `yaml:"op"`     // >= > <= < == !=，为空时总是满足
+	Value  string            `yaml:"value"`  // 比较的右侧，是一个表达式，如 $t属性值
+	Text   string            `yaml:"text"`   // 判定文本的模板键
+	Modify map[string]string `yaml:"modify"` // 满足时修改属性，值为增量表达式，以 = 开头时直接赋值
+}
+
+var (
+	checkLeadingNameRe = regexp.MustCompile(`^\$?[\p{L}_][\p{L}\p{N}_]*`)
+	checkDiceTokenRe   = regexp.MustCompile(`^[dD]\d*$`)
+)
+
+var checkCompareOps = map[string]func(a, b float64) bool{
+	">=": func(a, b float64) bool { return a >= b },
+	">":  func(a, b float64) bool { return a > b },
+	"<=": func(a, b float64) bool { return a <= b },
+	"<":  func(a, b float64) bool { return a < b },
+	"==": func(a, b float64) bool { return a == b },
+	"!=": func(a, b float64) bool { return a != b },
+}
+
+// validateChecks 检查 commands.checks 的定义
+func (t *GameSystemTemplateV2) validateChecks() []string {
+	var problems []string
+	parseProblem := func(where, expr string) {
+		vm := ds.NewVM()
+		if err := vm.Parse(expr); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: 表达式无法解析: %v", where, err))
+		} else if rest := strings.TrimSpace(expr[vm.GetParsedOffset():]); rest != "" {
+			problems = append(problems, fmt.Sprintf("%s: 表达式有无法解析的部分: %s", where, rest))
+		}
+	}
+
+	owner := map[string]string{}
+	for _, name := range sheetSortedKeys(t.Commands.Checks) {
+		c := t.Commands.Checks[name]
+		where := "commands.checks." + name
+		if c == nil {
+			problems = append(problems, where+": 定义为空")
+			continue
+		}
+		for _, cmd := range append([]string{name}, c.Aliases...) {
+			cmd = strings.ToLower(strings.TrimSpace(cmd))
+			if cmd == "" || strings.ContainsAny(cmd, " \t\r\n") {
+				problems = append(problems, fmt.Sprintf("%s: 指令名 %q 无效", where, cmd))
+				continue
+			}
+			if prev, exists := owner[cmd]; exists {
+				problems = append(problems, fmt.Sprintf("%s: 指令名 %s 与 %s 重复", where, cmd, prev))
+				continue
+			}
+			owner[cmd] = name
+		}
+
+		if strings.TrimSpace(c.Roll) == "" {
+			problems = append(problems, where+": 缺少 roll")
+		} else {
+			parseProblem(where+".roll", c.Roll)
+		}
+		if c.Expr != "" {
+			parseProblem(where+".expr", c.Expr)
+		}
+		if len(c.Tiers) == 0 {
+			problems = append(problems, where+": 缺少 tiers")
+		}
+		for i, tier := range c.Tiers {
+			tierWhere := fmt.Sprintf("%s.tiers[%d]", where, i)
+			if tier == nil || tier.Name == "" {
+				problems = append(problems, tierWhere+": 缺少 name")
+				continue
+			}
+			if tier.Op == "" {
+				if tier.Value != "" || tier.Target != "" {
+					problems = append(problems, tierWhere+": 设置了 value/target 但缺少 op")
+				}
+			} else {
+				if _, ok := checkCompareOps[tier.Op]; !ok {
+					problems = append(problems, fmt.Sprintf("%s: 未知的比较方式 %q，可用 >= > <= < == !=", tierWhere, tier.Op))
+				}
+				if strings.TrimSpace(tier.Value) == "" {
+					problems = append(problems, tierWhere+": 缺少 value")
+				} else {
+					parseProblem(tierWhere+".value", tier.Value)
+				}
+				if tier.Target != "" {
+					parseProblem(tierWhere+".target", tier.Target)
+				}
+			}
+			for _, attr := range sheetSortedKeys(tier.Modify) {
+				expr := strings.TrimPrefix(strings.TrimSpace(tier.Modify[attr]), "=")
+				if strings.TrimSpace(expr) == "" {
+					problems = append(problems, fmt.Sprintf("%s.modify.%s: 缺少表达式", tierWhere, attr))
+					continue
+				}
+				parseProblem(fmt.Sprintf("%s.modify.%s", tierWhere, attr), expr)
+			}
+		}
+	}
+	return problems
+}
+
+// initChecks 把 commands.checks 转为指令，同时准备模板自带的文本
+func (t *GameSystemTemplate) initChecks() {
+	t.checkTextMap = map[string]*wr.Chooser{}
+	if t.TextMap != nil {
+		for category, item := range *t.TextMap {
+			for k, v := range item {
+				var choices []wr.Choice
+				for _, textItem := range v {
+					if len(textItem) < 2 {
+						continue
+					}
+					text, ok := textItem[0].(string)
+					if !ok {
+						continue
+					}
+					choices = append(choices, wr.Choice{Item: text, Weight: getNumVal(textItem[1])})
+				}
+				if pool, err := wr.NewChooser(choices...); err == nil {
+					t.checkTextMap[fmt.Sprintf("%s:%s", category, k)] = pool
+				}
+			}
+		}
+	}
+
+	t.checkCmdMap = CmdMapCls{}
+	for _, name := range sheetSortedKeys(t.Commands.Checks) {
+		c := t.Commands.Checks[name]
+		if c == nil {
+			continue
+		}
+		item := t.newCheckCmdItem(name, c)
+		for _, cmd := range append([]string{name}, c.Aliases...) {
+			cmd = strings.ToLower(strings.TrimSpace(cmd))
+			if _, exists := t.checkCmdMap[cmd]; cmd != "" && !exists {
+				t.checkCmdMap[cmd] = item
+			}
+		}
+	}
+}
+
+// CheckCmdNames 模板定义的检定指令名，不含别名
+func (t *GameSystemTemplate) CheckCmdNames() []string {
+	if t == nil || t.GameSystemTemplateV2 == nil {
+		return nil
+	}
+	names := make([]string, 0, len(t.Commands.Checks))
+	for name := range t.Commands.Checks {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// formatCheckText 按模板键取文本，先找模板自带的 textMap，再找全局文本模板
+func (t *GameSystemTemplate) formatCheckText(ctx *MsgContext, key string) string {
+	if pool := t.checkTextMap[key]; pool != nil {
+		return DiceFormat(ctx, pool.PickSource(randSourceDrawAndTmplSelect).(string))
+	}
+	return DiceFormatTmpl(ctx, key)
+}
+
+func (t *GameSystemTemplate) newCheckCmdItem(name string, c *CheckConfig) *CmdItemInfo {
+	title := c.Name
+	if title == "" {
+		title = name
+	}
+	help := strings.TrimSpace(c.Help)
+	if help == "" {
+		attr := "[属性][+调整]"
+		if c.RequireAttr {
+			attr = "<属性>[+调整]"
+		}
+		help = fmt.Sprintf(".%s %s [原因] // %s 检定", name, attr, title)
+	}
+
+	return &CmdItemInfo{
+		Name:          name,
+		ShortHelp:     help,
+		Help:          fmt.Sprintf("%s %s 检定:\n%s", t.FullName, title, help),
+		AllowDelegate: true,
+		Solve: func(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs) CmdExecuteResult {
+			arg := strings.TrimSpace(cmdArgs.CleanArgs)
+			if cmdArgs.IsArgEqual(1, "help") || (arg == "" && c.RequireAttr) {
+				return CmdExecuteResult{Matched: true, Solved: true, ShowHelp: true}
+			}
+
+			mctx := GetCtxProxyFirst(ctx, cmdArgs)
+			mctx.DelegateText = ctx.DelegateText
+			mctx.SystemTemplate = t
+			mctx.CreateVmIfNotExists()
+			mctx.Eval(t.InitScript, nil)
+
+			text, err := t.runCheck(mctx, name, title, c, arg)
+			if err != nil {
+				ReplyToSender(mctx, msg, err.Error())
+				return CmdExecuteResult{Matched: true, Solved: true}
+			}
+			ReplyToSender(mctx, msg, text)
+			return CmdExecuteResult{Matched: true, Solved: true}
+		},
+	}
+}
+
+// runCheck 执行一次检定，返回结果文本
+func (t *GameSystemTemplate) runCheck(mctx *MsgContext, name, title string, c *CheckConfig, arg string) (string, error) {
+	attrText, reason := "", ""
+	attrValue := ds.NewIntVal(0)
+	// 开头不是属性或数字时整段都是原因，如 .move 突围，免得把原因当作值为 0 的属性
+	if name := checkLeadingName(arg); name != "" && !t.checkAttrKnown(mctx, name) {
+		if c.RequireAttr {
+			return "", fmt.Errorf("未知的属性: %s", name)
+		}
+		reason, arg = arg, ""
+	}
+	if arg != "" {
+		r := mctx.Eval(arg, nil)
+		if r.vm.Error != nil {
+			return "", fmt.Errorf("无法解析表达式: %s", arg)
+		}
+		if r.TypeId != ds.VMTypeInt && r.TypeId != ds.VMTypeFloat {
+			return "", fmt.Errorf("属性非数字类型，无法用于检定: %s", arg)
+		}
+		reason = strings.TrimSpace(r.vm.RestInput)
+		attrText = strings.TrimSpace(arg[:len(arg)-len(r.vm.RestInput)])
+		attrValue = r.VMValue.Clone()
+	}
+	VarSetValueStr(mctx, checkVarName, title)
+	VarSetValueStr(mctx, checkVarAttr, attrText)
+	VarSetValue(mctx, checkVarAttrValue, attrValue)
+	VarSetValueStr(mctx, checkVarReason, LimitCommandReasonText(reason))
+
+	roll := mctx.Eval(c.Roll, nil)
+	if roll.vm.Error != nil {
+		return "", fmt.Errorf("检定 %s 的 roll 执行出错: %v", name, roll.vm.Error)
+	}
+	rollDetail := roll.vm.GetDetailText()
+	VarSetValue(mctx, checkVarRoll, roll.VMValue.Clone())
+
+	expr := c.Expr
+	if expr == "" {
+		expr = checkDefaultExpr
+	}
+	result := mctx.Eval(expr, nil)
+	if result.vm.Error != nil {
+		return "", fmt.Errorf("检定 %s 的 expr 执行出错: %v", name, result.vm.Error)
+	}
+	detail := rollDetail
+	if detail == "" {
+		detail = roll.ToString()
+	}
+	if attrText != "" {
+		detail = fmt.Sprintf("%s + %s(%s)", detail, attrText, attrValue.ToString())
+	}
+	VarSetValue(mctx, checkVarResult, result.VMValue.Clone())
+	VarSetValueStr(mctx, checkVarDetail, detail)
+
+	tier, err := matchCheckTier(mctx, c.Tiers)
+	if err != nil {
+		return "", fmt.Errorf("检定 %s 的判定执行出错: %v", name, err)
+	}
+	VarSetValueStr(mctx, checkVarTier, "")
+	VarSetValueStr(mctx, checkVarTierText, "")
+	VarSetValueStr(mctx, checkVarAttrChange, "")
+	tierName := ""
+	if tier != nil {
+		tierName = tier.Name
+		VarSetValueStr(mctx, checkVarTier, tier.Name)
+		if tier.Text != "" {
+			VarSetValueStr(mctx, checkVarTierText, t.formatCheckText(mctx, tier.Text))
+		}
+		if changes := t.applyCheckModify(mctx, tier.Modify); len(changes) > 0 {
+			VarSetValueStr(mctx, checkVarAttrChange, strings.Join(changes, "\n"))
+		}
+	}
+
+	mctx.CommandInfo = map[string]any{
+		"cmd":    name,
+		"rule":   t.Name,
+		"pcName": mctx.Player.Name,
+		"items": []any{
+			map[string]any{
+				"expr":    expr,
+				"attr":    attrText,
+				"reason":  reason,
+				"outcome": roll.ToString(),
+				"result":  result.ToString(),
+				"tier":    tierName,
+			},
+		},
+	}
+
+	if c.Text != "" {
+		return t.formatCheckText(mctx, c.Text), nil
+	}
+	return DiceFormat(mctx, checkDefaultText), nil
+}
+
+// checkLeadingName 参数开头的属性名，以数字、运算符或骰子(如 d6)开头时为空
+func checkLeadingName(arg string) string {
+	name := checkLeadingNameRe.FindString(arg)
+	if checkDiceTokenRe.MatchString(name) {
+		return ""
+	}
+	return name
+}
+
+// checkAttrKnown 是否为卡上已有、模板给出默认值或在 schema 中定义的属性
+func (t *GameSystemTemplate) checkAttrKnown(mctx *MsgContext, name string) bool {
+	if strings.HasPrefix(name, "$") {
+		return true
+	}
+	name = t.GetAlias(name)
+	if _, _, _, exists := t.GetDefaultValueEx0(mctx, name); exists {
+		return true
+	}
+	if _, exists := t.Attrs.Schema[name]; exists {
+		return true
+	}
+	if am := mctx.Dice.AttrsManager; am != nil {
+		if attrs, err := am.LoadByCtx(mctx); err == nil && attrs != nil {
+			if _, exists := attrs.LoadX(name); exists {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchCheckTier 按顺序找到第一个满足条件的档位，都不满足时返回 nil
+func matchCheckTier(mctx *MsgContext, tiers []*CheckTier) (*CheckTier, error) {
+	evalNum := func(expr string) (float64, error) {
+		r := mctx.Eval(expr, nil)
+		if r.vm.Error != nil {
+			return 0, r.vm.Error
+		}
+		switch r.TypeId {
+		case ds.VMTypeInt:
+			n, _ := r.ReadInt()
+			return float64(n), nil
+		case ds.VMTypeFloat:
+			f, _ := r.ReadFloat()
+			return f, nil
+		default:
+			return 0, fmt.Errorf("%s 的结果不是数字: %s", expr, r.ToString())
+		}
+	}
+
+	for _, tier := range tiers {
+		if tier == nil {
+			continue
+		}
+		if tier.Op == "" {
+			return tier, nil
+		}
+		compare := checkCompareOps[tier.Op]
+		if compare == nil {
+			return nil, fmt.Errorf("未知的比较方式 %q", tier.Op)
+		}
+		target := tier.Target
+		if target == "" {
+			target = checkVarResult
+		}
+		a, err := evalNum(target)
+		if err != nil {
+			return nil, err
+		}
+		b, err := evalNum(tier.Value)
+		if err != nil {
+			return nil, err
+		}
+		if compare(a, b) {
+			return tier, nil
+		}
+	}
+	return nil, nil
+}
+
+// applyCheckModify 按档位的 modify 修改当前卡的属性，返回变化说明，如 "经验: 3→4"
+func (t *GameSystemTemplate) applyCheckModify(mctx *MsgContext, modify map[string]string) []string {
+	if len(modify) == 0 {
+		return nil
+	}
+	am := mctx.Dice.AttrsManager
+	if err := am.CheckEditableByCtx(mctx); err != nil {
+		return []string{fmt.Sprintf("<%s>是只读的共享卡，属性未修改", mctx.Player.Name)}
+	}
+	attrs, err := am.LoadByCtx(mctx)
+	if err != nil {
+		return []string{"读取人物卡失败，属性未修改"}
+	}
+
+	var changes, changed []string
+	for _, key := range sheetSortedKeys(modify) {
+		name := t.GetAlias(key)
+		expr := strings.TrimSpace(modify[key])
+		isSet := strings.HasPrefix(expr, "=")
+
+		r := mctx.Eval(strings.TrimPrefix(expr, "="), nil)
+		if r.vm.Error != nil {
+			changes = append(changes, fmt.Sprintf("%s: 修改失败(%v)", name, r.vm.Error))
+			continue
+		}
+		oldValue, err := t.GetRealValue(mctx, name)
+		if err != nil {
+			changes = append(changes, fmt.Sprintf("%s: 修改失败(%v)", name, err))
+			continue
+		}
+		newValue := r.VMValue.Clone()
+		if !isSet {
+			newValue = oldValue.OpAdd(mctx.vm, newValue)
+			if newValue == nil {
+				changes = append(changes, fmt.Sprintf("%s: 无法与 %s 相加", name, r.ToString()))
+				continue
+			}
+		}
+		if err := t.CheckAttrValue(name, newValue); err != nil {
+			changes = append(changes, fmt.Sprintf("%s: %s%s，未修改", name, newValue.ToString(), err.Error()))
+			continue
+		}
+		attrs.Store(name, newValue)
+		changed = append(changed, name)
+		changes = append(changes, fmt.Sprintf("%s: %s→%s", name, oldValue.ToString(), newValue.ToString()))
+	}
+
+	updated, failed := t.RecomputeDerived(mctx, attrs, changed)
+	if len(updated) > 0 {
+		changes = append(changes, "自动计算: "+strings.Join(updated, " "))
+	}
+	if len(failed) > 0 {
+		changes = append(changes, "派生属性计算失败: "+strings.Join(failed, "；"))
+	}
+	return changes
+}
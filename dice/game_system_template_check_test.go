@@ -0,0 +1,124 @@
+//nolint:testpackage
+package dice
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	ds "github.com/sealdice/dicescript"
+)
+
+const checkTestTemplate = `
+name: checktest
+fullName: 检定测试
+templateVer: '2.0'
+attrs:
+  defaults:
+    酷: 1
+  schema:
+    经验: { type: int, max: 1 }
+commands:
+  checks:
+    move:
+      name: 行动
+      aliases: [行动]
+      roll: '8'
+      tiers:
+        - { name: 完全成功, op: '>=', value: '10', text: checktest:完全成功 }
+        - { name: 部分成功, op: '>=', value: '7' }
+        - { name: 失败, modify: { 经验: '1' } }
+textMap:
+  checktest:
+    完全成功: [['没有代价', 1]]
+`
+
+func TestGameSystemTemplateCheckValidate(t *testing.T) {
+	bad := `
+name: badcheck
+templateVer: '2.0'
+commands:
+  checks:
+    roll:
+      aliases: [Roll]
+      tiers:
+        - { name: 成功, op: '=>', value: '10' }
+        - { name: 失败, value: '3' }
+`
+	_, err := LoadGameSystemTemplateFromBytes([]byte(bad), "yaml")
+	var verr *TemplateValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected TemplateValidationError, got %v", err)
+	}
+	text := verr.Error()
+	for _, want := range []string{"指令名 roll 与 roll 重复", "缺少 roll", "未知的比较方式 \"=>\"", "设置了 value/target 但缺少 op"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("validation error missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestGameSystemTemplateRunCheck(t *testing.T) {
+	ctx, cleanup := newTemplateFallbackTestCtx(t, "checktest", map[string]*ds.VMValue{})
+	defer cleanup()
+	tmpl, err := LoadGameSystemTemplateFromBytes([]byte(checkTestTemplate), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.SystemTemplate = tmpl
+	ctx.EndPoint = &EndPointInfo{EndPointInfoBase: EndPointInfoBase{UserID: "QQ:1"}}
+	if tmpl.checkCmdMap["move"] == nil || tmpl.checkCmdMap["行动"] != tmpl.checkCmdMap["move"] {
+		t.Fatalf("check commands = %v", tmpl.checkCmdMap)
+	}
+	c := tmpl.Commands.Checks["move"]
+
+	cases := []struct {
+		arg  string
+		want []string
+	}{
+		{"酷+1 突围", []string{"行动检定(突围)", "=10 完全成功", "没有代价"}},
+		{"", []string{"=8 部分成功"}},
+		{"突围", []string{"行动检定(突围)", "=8 部分成功"}},
+		{"-5", []string{"=3 失败", "经验: 0→1"}},
+		{"-5", []string{"=3 失败", "经验: 2不能大于1，未修改"}},
+	}
+	for _, tc := range cases {
+		text, err := tmpl.runCheck(ctx, "move", c.Name, c, tc.arg)
+		if err != nil {
+			t.Fatalf("runCheck(%q): %v", tc.arg, err)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(text, want) {
+				t.Errorf("runCheck(%q) = %q, missing %q", tc.arg, text, want)
+			}
+		}
+	}
+
+	attrs, _ := ctx.Dice.AttrsManager.LoadByCtx(ctx)
+	if v := attrs.Load("经验"); v == nil || v.ToString() != "1" {
+		t.Fatalf("经验 = %v, want 1", v)
+	}
+}
+
+func TestExtCmdDisabledCoversTemplateChecks(t *testing.T) {
+	ext := &ExtInfo{
+		Name:       "move-ext",
+		AutoActive: true,
+		CmdMap:     CmdMapCls{"move": {Name: "move"}},
+	}
+	dice := newTestDice([]*ExtInfo{ext})
+	dice.Config.ExtDefaultSettings = []*ExtDefaultSettingItem{
+		{Name: "move-ext", AutoActive: true, DisabledCommand: map[string]bool{}},
+	}
+	dice.ApplyExtDefaultSettings()
+	dice.ExtUpdateTime = 1
+	group := &GroupInfo{InactivatedExtSet: make(StringSet)}
+
+	if group.extCmdDisabled(dice, "move") {
+		t.Fatalf("未禁用的扩展指令不应影响检定指令")
+	}
+	ext.DefaultSetting.DisabledCommand["move"] = true
+	if !group.extCmdDisabled(dice, "move") || group.extCmdDisabled(dice, "行动") {
+		t.Fatalf("禁用扩展指令 move 后应只屏蔽同名检定指令")
+	}
+}
@@ -0,0 +1,291 @@
+package dice
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	ds "github.com/sealdice/dicescript"
+)
+
+// 模板中的属性约束与派生属性:
+//
+//	attrs:
+//	  schema:
+//	    力量: { type: int, min: 0 }
+//	    职业: { type: string, values: [侦探, 记者] }
+//	  derived:
+//	    生命值上限: { expr: (体质 + 体型) / 10, inputs: [体质, 体型] }
+//
+// schema 在 .st 写入属性时检查，不符合的属性不会写入；derived 在 .st 修改了 inputs 中的属性后重新计算并写入卡中，
+// 不写 inputs 时任何属性变化都会重算。两者都在加载模板时校验。
+
+// 属性约束中可用的类型
+const (
+	AttrTypeInt      = "int"
+	AttrTypeFloat    = "float" // 整数也视为合法
+	AttrTypeString   = "string"
+	AttrTypeComputed = "computed" // 如 &手枪=1d6
+)
+
+// derivedMaxRounds 派生属性链式重算的最大轮数，超过说明 inputs 成环
+const derivedMaxRounds = 16
+
+// AttrSchema 单个属性的约束，各项为空时不限制
+type AttrSchema struct {
+	Type   string   `yaml:"type"`
+	Min    *float64 `yaml:"min"`
+	Max    *float64 `yaml:"max"`
+	Values []string `yaml:"values"` // 允许的取值
+}
+
+// AttrDerived 派生属性，由其他属性计算得出并写入卡中
+type AttrDerived struct {
+	Expr   string   `yaml:"expr"`
+	Inputs []string `yaml:"inputs"`
+}
+
+// TemplateValidationError 模板校验失败，Problems 为每一处问题
+type TemplateValidationError struct {
+	Name     string
+	Problems []string
+}
+
+func (e *TemplateValidationError) Error() string {
+	return fmt.Sprintf("模板 %s 校验失败:\n- %s", e.Name, strings.Join(e.Problems, "\n- "))
+}
+
+func formatSchemaNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// Validate 检查 schema、derived 与检定指令的定义，以及 defaults 是否满足 schema
+func (t *GameSystemTemplateV2) Validate() error {
+	if t == nil {
+		return nil
+	}
+	var problems []string
+	for _, name := range sheetSortedKeys(t.Attrs.Schema) {
+		s := t.Attrs.Schema[name]
+		if s == nil {
+			problems = append(problems, fmt.Sprintf("schema.%s: 约束为空", name))
+			continue
+		}
+		switch s.Type {
+		case "", AttrTypeInt, AttrTypeFloat, AttrTypeString, AttrTypeComputed:
+		default:
+			problems = append(problems, fmt.Sprintf("schema.%s: 未知类型 %q，可用 int、float、string、computed", name, s.Type))
+		}
+		if (s.Min != nil || s.Max != nil) && (s.Type == AttrTypeString || s.Type == AttrTypeComputed) {
+			problems = append(problems, fmt.Sprintf("schema.%s: %s 类型不能设置 min/max", name, s.Type))
+		}
+		if s.Min != nil && s.Max != nil && *s.Min > *s.Max {
+			problems = append(problems, fmt.Sprintf("schema.%s: min(%s) 大于 max(%s)", name, formatSchemaNumber(*s.Min), formatSchemaNumber(*s.Max)))
+		}
+		if v, exists := t.Attrs.Defaults[name]; exists {
+			if err := t.CheckAttrValue(name, ds.NewIntVal(ds.IntType(v))); err != nil {
+				problems = append(problems, fmt.Sprintf("defaults.%s: 默认值%d%s", name, v, err.Error()))
+			}
+		}
+	}
+
+	for _, name := range sheetSortedKeys(t.Attrs.Derived) {
+		d := t.Attrs.Derived[name]
+		if d == nil || strings.TrimSpace(d.Expr) == "" {
+			problems = append(problems, fmt.Sprintf("derived.%s: 缺少 expr", name))
+			continue
+		}
+		vm := ds.NewVM()
+		if err := vm.Parse(d.Expr); err != nil {
+			problems = append(problems, fmt.Sprintf("derived.%s: 表达式无法解析: %v", name, err))
+		} else if rest := strings.TrimSpace(d.Expr[vm.GetParsedOffset():]); rest != "" {
+			problems = append(problems, fmt.Sprintf("derived.%s: 表达式有无法解析的部分: %s", name, rest))
+		}
+		for _, input := range d.Inputs {
+			if t.GetAlias(input) == name {
+				problems = append(problems, fmt.Sprintf("derived.%s: inputs 不能包含自身", name))
+			}
+		}
+		if _, exists := t.Attrs.DefaultsComputed[name]; exists {
+			problems = append(problems, fmt.Sprintf("derived.%s: 与 defaultsComputed 中的同名属性冲突", name))
+		}
+	}
+	if cycle := t.derivedCycle(); cycle != "" {
+		problems = append(problems, "derived: inputs 成环: "+cycle)
+	}
+	problems = append(problems, t.validateChecks()...)
+
+	if len(problems) > 0 {
+		return &TemplateValidationError{Name: t.Name, Problems: problems}
+	}
+	return nil
+}
+
+// derivedCycle 派生属性之间的依赖环，如 "a → b → a"，没有环时返回空字符串
+func (t *GameSystemTemplateV2) derivedCycle() string {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var path []string
+	var visit func(name string) string
+	visit = func(name string) string {
+		d := t.Attrs.Derived[name]
+		if d == nil {
+			return ""
+		}
+		switch state[name] {
+		case visiting:
+			for i, p := range path {
+				if p == name {
+					return strings.Join(append(path[i:], name), " → ")
+				}
+			}
+		case done:
+			return ""
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, input := range d.Inputs {
+			if c := visit(t.GetAlias(input)); c != "" {
+				return c
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		return ""
+	}
+	for _, name := range sheetSortedKeys(t.Attrs.Derived) {
+		if c := visit(name); c != "" {
+			return c
+		}
+	}
+	return ""
+}
+
+// CheckAttrValue 按 schema 检查属性值，不符合时返回原因，如 "不能大于99"
+func (t *GameSystemTemplateV2) CheckAttrValue(name string, v *ds.VMValue) error {
+	if t == nil || v == nil {
+		return nil
+	}
+	s := t.Attrs.Schema[name]
+	if s == nil {
+		return nil
+	}
+
+	switch s.Type {
+	case AttrTypeInt:
+		if v.TypeId != ds.VMTypeInt {
+			return errors.New("应为整数")
+		}
+	case AttrTypeFloat:
+		if v.TypeId != ds.VMTypeInt && v.TypeId != ds.VMTypeFloat {
+			return errors.New("应为数字")
+		}
+	case AttrTypeString:
+		if v.TypeId != ds.VMTypeString {
+			return errors.New("应为文本")
+		}
+	case AttrTypeComputed:
+		if v.TypeId != ds.VMTypeComputedValue {
+			return fmt.Errorf("应为算式，如 &%s=1d6", name)
+		}
+	}
+
+	var num float64
+	isNum := true
+	switch v.TypeId {
+	case ds.VMTypeInt:
+		n, _ := v.ReadInt()
+		num = float64(n)
+	case ds.VMTypeFloat:
+		num, _ = v.ReadFloat()
+	default:
+		isNum = false
+	}
+	if isNum && s.Min != nil && num < *s.Min {
+		return fmt.Errorf("不能小于%s", formatSchemaNumber(*s.Min))
+	}
+	if isNum && s.Max != nil && num > *s.Max {
+		return fmt.Errorf("不能大于%s", formatSchemaNumber(*s.Max))
+	}
+
+	if len(s.Values) > 0 {
+		text := v.ToString()
+		for _, allowed := range s.Values {
+			if allowed == text {
+				return nil
+			}
+		}
+		return fmt.Errorf("只能是 %s 之一", strings.Join(s.Values, "、"))
+	}
+	return nil
+}
+
+// RecomputeDerived 重新计算受 changed 中属性影响的派生属性并写入卡中，派生属性的变化会继续传递。
+// 返回更新的项，如 "生命值上限=13"，以及计算失败的说明
+func (t *GameSystemTemplate) RecomputeDerived(ctx *MsgContext, attrs *AttributesItem, changed []string) (updated []string, failed []string) {
+	if t == nil || t.GameSystemTemplateV2 == nil || len(t.Attrs.Derived) == 0 || len(changed) == 0 {
+		return nil, nil
+	}
+
+	dirty := map[string]bool{}
+	for _, name := range changed {
+		dirty[t.GetAlias(name)] = true
+	}
+	names := sheetSortedKeys(t.Attrs.Derived)
+	done := map[string]bool{}
+	broken := map[string]bool{}
+	for round := 0; round < derivedMaxRounds && len(dirty) > 0; round++ {
+		next := map[string]bool{}
+		for _, name := range names {
+			d := t.Attrs.Derived[name]
+			if d == nil || broken[name] || !t.derivedAffected(d, dirty) {
+				continue
+			}
+			result := ctx.Eval(d.Expr, nil)
+			if result == nil || result.vm.Error != nil {
+				reason := "未知错误"
+				if result != nil {
+					reason = result.vm.Error.Error()
+				}
+				failed = append(failed, fmt.Sprintf("%s: %s", name, reason))
+				broken[name] = true
+				continue
+			}
+			val := result.VMValue.Clone()
+			if old, exists := attrs.LoadX(name); exists && ds.ValueEqual(old, val, true) {
+				continue
+			}
+			attrs.Store(name, val)
+			next[name] = true
+			if !done[name] {
+				done[name] = true
+				updated = append(updated, name)
+			}
+		}
+		dirty = next
+	}
+
+	for i, name := range updated {
+		v, _ := attrs.LoadX(name)
+		updated[i] = fmt.Sprintf("%s=%s", name, v.ToString())
+	}
+	sort.Strings(updated)
+	return updated, failed
+}
+
+func (t *GameSystemTemplate) derivedAffected(d *AttrDerived, dirty map[string]bool) bool {
+	if len(d.Inputs) == 0 {
+		return true
+	}
+	for _, input := range d.Inputs {
+		if dirty[t.GetAlias(input)] {
+			return true
+		}
+	}
+	return false
+}
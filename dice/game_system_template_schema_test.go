@@ -0,0 +1,124 @@
+//nolint:testpackage
+package dice
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	ds "github.com/sealdice/dicescript"
+)
+
+const schemaTestTemplate = `
+name: schematest
+fullName: 约束测试
+templateVer: '2.0'
+attrs:
+  defaults:
+    体质: 50
+  schema:
+    体质: { type: int, min: 0, max: 99 }
+    职业: { type: string, values: [侦探, 记者] }
+  derived:
+    生命上限: { expr: (体质 + 体型) / 10, inputs: [体质, 体型] }
+    重伤线: { expr: 生命上限 / 2, inputs: [生命上限] }
+`
+
+func TestGameSystemTemplateValidate(t *testing.T) {
+	if _, err := LoadGameSystemTemplateFromBytes([]byte(schemaTestTemplate), "yaml"); err != nil {
+		t.Fatalf("valid template: %v", err)
+	}
+
+	bad := `
+name: badschema
+templateVer: '2.0'
+attrs:
+  defaults:
+    体质: 120
+  schema:
+    体质: { type: int, max: 99 }
+    名号: { type: text }
+    敏捷: { min: 10, max: 5 }
+  derived:
+    甲: { expr: 乙 + 1, inputs: [乙] }
+    乙: { expr: 甲 + 1, inputs: [甲] }
+    丙: { expr: '' }
+`
+	_, err := LoadGameSystemTemplateFromBytes([]byte(bad), "yaml")
+	var verr *TemplateValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected TemplateValidationError, got %v", err)
+	}
+	text := verr.Error()
+	for _, want := range []string{"默认值120不能大于99", "未知类型 \"text\"", "min(10) 大于 max(5)", "inputs 成环", "丙: 缺少 expr"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("validation error missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestGameSystemTemplateCheckAttrValue(t *testing.T) {
+	tmpl, err := LoadGameSystemTemplateFromBytes([]byte(schemaTestTemplate), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		v    *ds.VMValue
+		want string
+	}{
+		{"体质", ds.NewIntVal(60), ""},
+		{"体质", ds.NewIntVal(100), "不能大于99"},
+		{"体质", ds.NewIntVal(-1), "不能小于0"},
+		{"体质", ds.NewStrVal("高"), "应为整数"},
+		{"职业", ds.NewStrVal("记者"), ""},
+		{"职业", ds.NewStrVal("医生"), "只能是 侦探、记者 之一"},
+		{"敏捷", ds.NewIntVal(1000), ""}, // 没有约束
+	}
+	for _, c := range cases {
+		got := ""
+		if err := tmpl.CheckAttrValue(c.name, c.v); err != nil {
+			got = err.Error()
+		}
+		if got != c.want {
+			t.Errorf("CheckAttrValue(%s, %s) = %q, want %q", c.name, c.v.ToString(), got, c.want)
+		}
+	}
+}
+
+func TestGameSystemTemplateRecomputeDerived(t *testing.T) {
+	ctx, cleanup := newTemplateFallbackTestCtx(t, "schematest", map[string]*ds.VMValue{
+		"体质": ds.NewIntVal(60),
+		"体型": ds.NewIntVal(70),
+	})
+	defer cleanup()
+	tmpl, err := LoadGameSystemTemplateFromBytes([]byte(schemaTestTemplate), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.SystemTemplate = tmpl
+	attrs, err := ctx.Dice.AttrsManager.LoadByCtx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, failed := tmpl.RecomputeDerived(ctx, attrs, []string{"体型"})
+	if len(failed) != 0 {
+		t.Fatalf("unexpected failures: %v", failed)
+	}
+	if got := strings.Join(updated, " "); got != "生命上限=13 重伤线=6" {
+		t.Fatalf("updated = %q", got)
+	}
+
+	// 与派生属性无关的属性不触发重算
+	if updated, _ = tmpl.RecomputeDerived(ctx, attrs, []string{"侦查"}); len(updated) != 0 {
+		t.Fatalf("unrelated change recomputed %v", updated)
+	}
+
+	// 值没有变化时不重复写入
+	attrs.Store("体质", ds.NewIntVal(61))
+	if updated, _ = tmpl.RecomputeDerived(ctx, attrs, []string{"体质"}); len(updated) != 0 {
+		t.Fatalf("unchanged result reported %v", updated)
+	}
+}
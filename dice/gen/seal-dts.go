@@ -0,0 +1,18 @@
+package main
+
+import (
+	"os"
+
+	"sealdice-core/dice"
+)
+
+func main() {
+	text, err := dice.GenerateSealDts()
+	if err != nil {
+		panic("生成 seal.d.ts 失败: " + err.Error())
+	}
+	err = os.WriteFile(dice.SealDtsFilename, []byte(text), 0o644) //nolint:gosec
+	if err != nil {
+		panic("写入 seal.d.ts 失败: " + err.Error())
+	}
+}
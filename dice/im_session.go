@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"regexp"
@@ -403,6 +404,25 @@ func (group *GroupInfo) GetCharTemplate(dice *Dice) *GameSystemTemplate {
 	return blankTmpl
 }
 
+// checkCmdMap 当前规则模板定义的检定指令
+func (group *GroupInfo) checkCmdMap(dice *Dice) CmdMapCls {
+	tmpl := group.GetCharTemplate(dice)
+	if tmpl == nil {
+		return nil
+	}
+	return tmpl.checkCmdMap
+}
+
+// extCmdDisabled 群内启用的扩展中是否有被骰主禁用的同名指令
+func (group *GroupInfo) extCmdDisabled(dice *Dice, cmd string) bool {
+	for _, wrapper := range group.GetActivatedExtList(dice) {
+		if item := wrapper.GetCmdMap()[cmd]; item != nil && wrapper.DefaultSetting != nil && wrapper.DefaultSetting.DisabledCommand[item.Name] {
+			return true
+		}
+	}
+	return false
+}
+
 type EndpointState int
 
 type EndPointInfoBase struct {
@@ -702,6 +722,10 @@ type MsgContext struct {
 
 	IsCompatibilityTest bool // 是否为兼容性测试环境，用于跳过不必要的数据库查询
 
+	AttrsSheetID       string             // 通过 @npc:名字 引用的人物卡，不为空时 LoadByCtx 读取这张卡
+	attrsSheetReadOnly bool               // 引用的卡只读，LoadByCtx 返回副本
+	attrsHistory       *attrsHistoryScope // 当前指令取用过的卡，指令结束后为有改动的卡记录修改历史
+
 	EndPoint        *EndPointInfo `jsbind:"endPoint"` // 对应的Endpoint
 	Session         *IMSession    // 对应的IMSession
 	Dice            *Dice         // 对应的 Dice
@@ -913,6 +937,9 @@ func (s *IMSession) Execute(ep *EndPointInfo, msg *Message, runInSync bool) {
 						cmdLst = append(cmdLst, k)
 					}
 				}
+				for k := range g.checkCmdMap(d) {
+					cmdLst = append(cmdLst, k)
+				}
 			}
 			sort.Sort(ByLength(cmdLst))
 		}
@@ -1118,13 +1145,23 @@ func (s *IMSession) Execute(ep *EndPointInfo, msg *Message, runInSync bool) {
 									waitRun := make(chan int, 1)
 									loop.RunOnLoop(func(runtime *goja.Runtime) {
 										defer func() {
-											if r := recover(); r != nil {
-												mctx.Dice.Logger.Errorf("扩展<%s>处理非指令消息异常: %v 堆栈: %v", i.Name, r, string(debug.Stack()))
-											}
 											waitRun <- 1
 										}()
 
-										i.OnNotCommandReceived(mctx, msg)
+										var stack []byte
+										err := mctx.Dice.jsWatchedCall(runtime, i, "非指令消息回调", func() {
+											defer func() {
+												// 在 panic 现场记录堆栈，再交给 jsWatchedCall 统计
+												if r := recover(); r != nil {
+													stack = debug.Stack()
+													panic(r)
+												}
+											}()
+											i.OnNotCommandReceived(mctx, msg)
+										})
+										if err != nil && !errors.Is(err, ErrJsExtSuspended) {
+											mctx.Dice.Logger.Errorf("扩展<%s>处理非指令消息异常: %v 堆栈: %v", i.Name, err, string(stack))
+										}
 									})
 									<-waitRun
 								} else {
@@ -1407,12 +1444,22 @@ func (s *IMSession) ExecuteNew(ep *EndPointInfo, msg *Message) {
 								waitRun := make(chan int, 1)
 								loop.RunOnLoop(func(runtime *goja.Runtime) {
 									defer func() {
-										if r := recover(); r != nil {
-											mctx.Dice.Logger.Errorf("扩展<%s>处理非指令消息异常: %v 堆栈: %v", i.Name, r, string(debug.Stack()))
-										}
 										waitRun <- 1
 									}()
-									i.OnNotCommandReceived(mctx, msg)
+									var stack []byte
+									err := mctx.Dice.jsWatchedCall(runtime, i, "非指令消息回调", func() {
+										defer func() {
+											// 在 panic 现场记录堆栈，再交给 jsWatchedCall 统计
+											if r := recover(); r != nil {
+												stack = debug.Stack()
+												panic(r)
+											}
+										}()
+										i.OnNotCommandReceived(mctx, msg)
+									})
+									if err != nil && !errors.Is(err, ErrJsExtSuspended) {
+										mctx.Dice.Logger.Errorf("扩展<%s>处理非指令消息异常: %v 堆栈: %v", i.Name, err, string(stack))
+									}
 								})
 								<-waitRun
 							} else {
@@ -2096,6 +2143,20 @@ func (s *IMSession) commandSolve(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs
 			}
 		}
 
+		// @npc:名字 只对允许代骰的指令生效，其他指令原样保留在参数中
+		if len(cmdArgs.AtSheets) > 0 {
+			if item.Raw || !item.AllowDelegate {
+				cmdArgs.RevokeAtSheets()
+			} else if am := ctx.Dice.AttrsManager; am != nil && ctx.Player != nil {
+				for _, name := range cmdArgs.AtSheets {
+					if _, _, err := am.SheetResolve(ctx.attrsSheetGroupID(), ctx.Player.UserID, name, ctx.PrivilegeLevel >= 40); err != nil {
+						ReplyToSender(ctx, msg, fmt.Sprintf("找不到可以引用的人物卡: %s", name))
+						return true
+					}
+				}
+			}
+		}
+
 		// 加载规则模板
 		// TODO: 注意一下这里使用群模板还是个人卡模板，目前群模板，可有情况特殊？
 		tmpl := ctx.SystemTemplate
@@ -2108,6 +2169,7 @@ func (s *IMSession) commandSolve(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs
 		}
 
 		var ret CmdExecuteResult
+		ctx.attrsHistory = &attrsHistoryScope{}
 		// 如果是js命令，那么加锁
 		if item.IsJsSolveFunc {
 			loop, err := s.Parent.ExtLoopManager.GetLoop(item.JSLoopVersion)
@@ -2116,22 +2178,31 @@ func (s *IMSession) commandSolve(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs
 				s.Parent.Logger.Errorf("扩展注册的指令<%s>运行环境已经过期: %v", item.Name, err)
 				return false
 			}
+			var realExt *ExtInfo
+			if ext != nil {
+				realExt = ext.GetRealExt()
+			}
 			waitRun := make(chan int, 1)
 			loop.RunOnLoop(func(vm *goja.Runtime) {
 				defer func() {
-					if r := recover(); r != nil {
-						// log.Errorf("异常: %v 堆栈: %v", r, string(debug.Stack()))
-						ReplyToSender(ctx, msg, fmt.Sprintf("JS执行异常，请反馈给该扩展的作者：\n%v", r))
-					}
 					waitRun <- 1
 				}()
 
-				ret = item.Solve(ctx, msg, cmdArgs)
+				err := s.Parent.jsWatchedCall(vm, realExt, "指令", func() {
+					ret = item.Solve(ctx, msg, cmdArgs)
+				})
+				if err != nil && !errors.Is(err, ErrJsExtSuspended) {
+					ReplyToSender(ctx, msg, fmt.Sprintf("JS执行异常，请反馈给该扩展的作者：\n%v", err))
+				}
 			})
 			<-waitRun
 		} else {
 			ret = item.Solve(ctx, msg, cmdArgs)
 		}
+		if am := ctx.Dice.AttrsManager; am != nil && ctx.Player != nil {
+			am.RecordHistory(ctx.Player.UserID, ctx.Player.Name, msg.Message, ctx.attrsHistory.list()...)
+		}
+		ctx.attrsHistory = nil
 
 		if ret.Solved {
 			if ret.ShowHelp {
@@ -2165,6 +2236,10 @@ func (s *IMSession) commandSolve(ctx *MsgContext, msg *Message, cmdArgs *CmdArgs
 		}
 
 		if group != nil && (group.Active || ctx.IsCurGroupBotOn) {
+			// 规则模板定义的检定指令优先于扩展中的同名指令，但骰主禁用了同名扩展指令时一并视为禁用
+			if !group.extCmdDisabled(ctx.Dice, cmdArgs.Command) && tryItemSolve(nil, group.checkCmdMap(ctx.Dice)[cmdArgs.Command]) {
+				return true
+			}
 			for _, wrapper := range group.GetActivatedExtList(ctx.Dice) {
 				cmdMap := wrapper.GetCmdMap()
 				item := cmdMap[cmdArgs.Command]
@@ -2615,6 +2690,9 @@ func (ctx *MsgContext) ShallowCopy() *MsgContext {
 		Group:               ctx.Group,
 		Player:              ctx.Player,
 		IsCompatibilityTest: ctx.IsCompatibilityTest,
+		AttrsSheetID:        ctx.AttrsSheetID,
+		attrsSheetReadOnly:  ctx.attrsSheetReadOnly,
+		attrsHistory:        ctx.attrsHistory,
 		EndPoint:            ctx.EndPoint,
 		Session:             ctx.Session,
 		Dice:                ctx.Dice,
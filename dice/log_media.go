@@ -0,0 +1,173 @@
+package dice
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/dice/storylog"
+	"sealdice-core/logger"
+	"sealdice-core/message"
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+)
+
+// logMediaFetchTimeout 单条消息中全部附件的下载时限
+const logMediaFetchTimeout = 2 * time.Minute
+
+// InitLogMedia 日志附件目录与日志库放在一起，所有骰子共用
+func (dm *DiceManager) InitLogMedia() {
+	dm.LogMediaStore = storylog.NewMediaStore(dm.logMediaDir())
+	if dm.StoryBackend != nil {
+		dm.StoryBackend.Media = dm.LogMediaStore
+	}
+}
+
+// logMediaDir SQLite 下为日志库文件所在的目录；日志库不在本地时使用 DATADIR，未设置则为默认数据目录
+func (dm *DiceManager) logMediaDir() string {
+	if dm.Operator != nil && dm.Operator.Type() == constant.SQLITE {
+		if path := sqliteMainFile(dm.Operator.GetLogDB(constant.READ)); path != "" {
+			return filepath.Join(filepath.Dir(path), storylog.MediaDirName)
+		}
+	}
+	dir := os.Getenv("DATADIR")
+	if dir == "" {
+		dir = "./data/default"
+	}
+	return filepath.Join(dir, storylog.MediaDirName)
+}
+
+// sqliteMainFile 连接实际打开的数据库文件，内存库或查询失败时为空
+func sqliteMainFile(db *gorm.DB) string {
+	if db == nil {
+		return ""
+	}
+	var rows []struct {
+		Name string
+		File string
+	}
+	if err := db.Raw("PRAGMA database_list").Scan(&rows).Error; err != nil {
+		return ""
+	}
+	for _, row := range rows {
+		if row.Name == "main" {
+			return row.File
+		}
+	}
+	return ""
+}
+
+var logMediaGCMutex sync.Mutex
+
+// LogMediaGC 删除数据库、各骰子的日志归档与内置日志后端都不再引用的附件
+func (dm *DiceManager) LogMediaGC() (int, int64, error) {
+	if dm.LogMediaStore == nil {
+		return 0, 0, nil
+	}
+	logMediaGCMutex.Lock()
+	defer logMediaGCMutex.Unlock()
+
+	dirs := make([]string, 0, len(dm.Dice)+1)
+	for _, d := range dm.Dice {
+		dirs = append(dirs, d.LogArchiveDir())
+	}
+	// 内置日志后端的查看页面也会显示附件。后端暂时关闭时同样保留，重新开启后仍能看到
+	backendDir := StoryBackendDir
+	if dm.StoryBackend != nil {
+		backendDir = dm.StoryBackend.Dir
+	}
+	dirs = append(dirs, backendDir)
+	return storylog.MediaGC(dm.Operator, dm.LogMediaStore, dirs...)
+}
+
+// LogMediaCleanup 删除日志后在后台清理附件
+func (d *Dice) LogMediaCleanup() {
+	dm := d.Parent
+	if dm == nil || dm.LogMediaStore == nil {
+		return
+	}
+	go func() {
+		removed, freed, err := dm.LogMediaGC()
+		if err != nil {
+			d.Logger.Warnf("清理日志附件失败: %v", err)
+			return
+		}
+		if removed > 0 {
+			d.Logger.Infof("清理了 %d 个不再使用的日志附件，释放 %d KB", removed, freed>>10)
+		}
+	}()
+}
+
+// SetLogMedia 修改日志附件设置
+func (d *Dice) SetLogMedia(cfg storylog.MediaConfig) error {
+	if cfg.MaxSizeMB < 0 {
+		return errors.New("附件大小上限不能为负数")
+	}
+	d.Config.LogMedia = cfg
+	d.MarkModified()
+	d.Save(false)
+	return nil
+}
+
+// logMediaSources 消息中需要保存的图片与文件，只认适配器解析出的消息段，不读取本地路径
+func logMediaSources(segments []message.IMessageElement, withFiles bool) []*storylog.MediaSource {
+	var sources []*storylog.MediaSource
+	for _, elem := range segments {
+		switch e := elem.(type) {
+		case *message.ImageElement:
+			src := &storylog.MediaSource{Kind: model.LogMediaKindImage, URL: e.URL}
+			if e.File != nil {
+				src.Name, src.ContentType, src.Stream = e.File.File, e.File.ContentType, e.File.Stream
+				if src.URL == "" {
+					src.URL = e.File.URL
+				}
+			}
+			sources = append(sources, src)
+		case *message.FileElement:
+			if withFiles {
+				sources = append(sources, &storylog.MediaSource{
+					Kind: model.LogMediaKindFile, Name: e.File, ContentType: e.ContentType, URL: e.URL, Stream: e.Stream,
+				})
+			}
+		}
+	}
+	return sources
+}
+
+// logCaptureMedia 在后台下载消息中的附件并补记到已写入的日志条目上
+func (d *Dice) logCaptureMedia(itemID uint64, segments []message.IMessageElement) {
+	cfg := d.Config.LogMedia
+	dm := d.Parent
+	if !cfg.Enable || itemID == 0 || dm == nil || dm.LogMediaStore == nil {
+		return
+	}
+	sources := logMediaSources(segments, cfg.Files)
+	if len(sources) == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), logMediaFetchTimeout)
+		defer cancel()
+		log := d.Logger
+		if log == nil {
+			log = logger.M()
+		}
+		media := make([]model.LogMedia, 0, len(sources))
+		for _, src := range sources {
+			m, err := dm.LogMediaStore.Fetch(ctx, src, cfg.MaxBytes())
+			if err != nil {
+				log.Debugf("日志附件下载失败 %s: %v", src.URL, err)
+			}
+			media = append(media, m)
+		}
+		if err := service.LogSetItemMedia(d.DBOperator, itemID, media); err != nil {
+			log.Warnf("日志附件记录失败: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,62 @@
+//nolint:testpackage
+package dice
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"sealdice-core/dice/storylog"
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+)
+
+func TestSqliteMainFile(t *testing.T) {
+	mockDB := newLogAliasTestDB(t)
+	got := sqliteMainFile(mockDB.GetLogDB(constant.READ))
+	if filepath.Base(got) != "logs.db" {
+		t.Fatalf("sqliteMainFile = %q, want the logs.db path", got)
+	}
+}
+
+func TestLogMediaGCKeepsStoryBackendMedia(t *testing.T) {
+	mockDB := newLogAliasTestDB(t)
+	store := storylog.NewMediaStore(t.TempDir())
+	backend, err := storylog.NewBackend(t.TempDir(), storylog.BackendConfig{Enable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dm := &DiceManager{Operator: mockDB, LogMediaStore: store, StoryBackend: backend}
+
+	put := func(content string) string {
+		hash, _, errPut := store.Put(strings.NewReader(content), 1<<20)
+		if errPut != nil {
+			t.Fatal(errPut)
+		}
+		path, _ := store.Path(hash)
+		old := time.Now().Add(-2 * time.Hour)
+		if errPut = os.Chtimes(path, old, old); errPut != nil {
+			t.Fatal(errPut)
+		}
+		return hash
+	}
+	uploaded, unused := put("uploaded"), put("unused")
+
+	mediaStr, _ := json.Marshal([]model.LogMedia{{Kind: model.LogMediaKindImage, Hash: uploaded}})
+	if _, err = backend.Save(&storylog.StoredLog{Name: "团"}, []model.LogOneItemParquet{{
+		Nickname: "张三", Message: "图", MediaStr: string(mediaStr),
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, _, err := dm.LogMediaGC()
+	if err != nil {
+		t.Fatalf("LogMediaGC: %v", err)
+	}
+	if removed != 1 || store.Has(unused) || !store.Has(uploaded) {
+		t.Fatalf("removed = %d, uploaded kept = %v, unused kept = %v", removed, store.Has(uploaded), store.Has(unused))
+	}
+}
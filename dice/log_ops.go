@@ -0,0 +1,101 @@
+package dice
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"sealdice-core/dice/service"
+)
+
+var (
+	logSplitDateLayouts  = []string{"2006-01-02", "2006/01/02", "01-02", "01/02"}
+	logSplitClockLayouts = []string{"15:04:05", "15:04"}
+)
+
+func parseLogSplitLayout(s string, layouts []string) (time.Time, bool) {
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseLogSplitAt 解析 .log split 的分割点，返回解析结果和占用的参数个数。
+// 支持 #序号/序号、日期、时刻、日期 时刻。不带年份时取今年，只有时刻时取今天，晚于当前时间则往前推一年或一天
+func parseLogSplitAt(args []string, now time.Time) (service.LogSplitAt, int, bool) {
+	if len(args) == 0 {
+		return service.LogSplitAt{}, 0, false
+	}
+	first := args[0]
+	if n, err := strconv.Atoi(strings.TrimPrefix(first, "#")); err == nil {
+		if n <= 0 {
+			return service.LogSplitAt{}, 0, false
+		}
+		return service.LogSplitAt{Index: n}, 1, true
+	}
+
+	var date, clock time.Time
+	used := 0
+	hasDate, hasClock := false, false
+	if date, hasDate = parseLogSplitLayout(first, logSplitDateLayouts); hasDate {
+		used = 1
+		if len(args) > 1 {
+			if clock, hasClock = parseLogSplitLayout(args[1], logSplitClockLayouts); hasClock {
+				used = 2
+			}
+		}
+	} else if clock, hasClock = parseLogSplitLayout(first, logSplitClockLayouts); hasClock {
+		used = 1
+	} else {
+		return service.LogSplitAt{}, 0, false
+	}
+
+	var t time.Time
+	switch {
+	case hasDate:
+		year := date.Year()
+		if year == 0 {
+			year = now.Year()
+		}
+		t = time.Date(year, date.Month(), date.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, time.Local)
+		if date.Year() == 0 && t.After(now) {
+			t = t.AddDate(-1, 0, 0)
+		}
+	default:
+		t = time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, time.Local)
+		if t.After(now) {
+			t = t.AddDate(0, 0, -1)
+		}
+	}
+	return service.LogSplitAt{Time: t.Unix()}, used, true
+}
+
+// LogSyncGroupState 日志改名或被合并后，把各骰子中正指向旧日志的群记录状态改到新日志上，记录开关保持不变
+func (d *Dice) LogSyncGroupState(groupID string, fromName string, change *service.LogChange) {
+	if change == nil || change.FromID == 0 || change.ToID == 0 {
+		return
+	}
+	dices := []*Dice{d}
+	if d.Parent != nil && len(d.Parent.Dice) > 0 {
+		dices = d.Parent.Dice
+	}
+	for _, each := range dices {
+		if each == nil || each.ImSession == nil || each.ImSession.ServiceAtNew == nil {
+			continue
+		}
+		group, ok := each.ImSession.ServiceAtNew.Load(groupID)
+		if !ok || group == nil {
+			continue
+		}
+		state := group.GetLogState()
+		// 旧版本数据可能只记录了名字
+		if state.ID != change.FromID && (state.ID != 0 || state.Name != fromName) {
+			continue
+		}
+		group.SetLogState(change.ToID, change.ToName, state.On)
+		group.MarkDirty(each)
+		each.Logger.Infof("日志状态切换: 群=%s 当前日志 %s -> %s id=%d", groupID, fromName, change.ToName, change.ToID)
+	}
+}
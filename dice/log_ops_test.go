@@ -0,0 +1,36 @@
+package dice //nolint:testpackage
+
+import (
+	"testing"
+	"time"
+
+	"sealdice-core/dice/service"
+)
+
+func TestParseLogSplitAt(t *testing.T) {
+	now := time.Date(2024, 5, 2, 20, 0, 0, 0, time.Local)
+	cases := []struct {
+		args []string
+		want service.LogSplitAt
+		used int
+	}{
+		{[]string{"#120", "新名"}, service.LogSplitAt{Index: 120}, 1},
+		{[]string{"7"}, service.LogSplitAt{Index: 7}, 1},
+		{[]string{"2024-05-01", "21:30", "新名"}, service.LogSplitAt{Time: time.Date(2024, 5, 1, 21, 30, 0, 0, time.Local).Unix()}, 2},
+		{[]string{"05-01"}, service.LogSplitAt{Time: time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local).Unix()}, 1},
+		{[]string{"12-31", "23:00"}, service.LogSplitAt{Time: time.Date(2023, 12, 31, 23, 0, 0, 0, time.Local).Unix()}, 2},
+		{[]string{"19:15"}, service.LogSplitAt{Time: time.Date(2024, 5, 2, 19, 15, 0, 0, time.Local).Unix()}, 1},
+		{[]string{"21:15"}, service.LogSplitAt{Time: time.Date(2024, 5, 1, 21, 15, 0, 0, time.Local).Unix()}, 1},
+	}
+	for _, c := range cases {
+		got, used, ok := parseLogSplitAt(c.args, now)
+		if !ok || got != c.want || used != c.used {
+			t.Errorf("parseLogSplitAt(%v) = %+v, %d, %v; want %+v, %d", c.args, got, used, ok, c.want, c.used)
+		}
+	}
+	for _, bad := range [][]string{{}, {"#0"}, {"昨天"}} {
+		if _, _, ok := parseLogSplitAt(bad, now); ok {
+			t.Errorf("parseLogSplitAt(%v) should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package dice
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/dice/storylog"
+	"sealdice-core/model"
+)
+
+// LogRetentionRule 日志保留规则，各项为 0 时不限制
+type LogRetentionRule struct {
+	MaxAgeDays   int   `json:"maxAgeDays"   yaml:"maxAgeDays"`   // 最后一次更新超过该天数的日志会被归档
+	MaxItems     int64 `json:"maxItems"     yaml:"maxItems"`     // 群内全部日志的条目总数上限，超出时从最久未更新的日志开始归档
+	UploadedOnly bool  `json:"uploadedOnly" yaml:"uploadedOnly"` // 只归档已上传且上传后没有更新的日志，其余日志始终保留
+}
+
+// LogRetentionConfig 日志保留策略，过期的日志归档为 Parquet 文件后从数据库删除
+type LogRetentionConfig struct {
+	Enable  bool                        `json:"enable"  yaml:"enable"`
+	Cron    string                      `json:"cron"    yaml:"cron"`    // 执行时间
+	Default LogRetentionRule            `json:"default" yaml:"default"` // 未单独设置的群使用的规则
+	Groups  map[string]LogRetentionRule `json:"groups"  yaml:"groups"`  // 按群设置的规则，完全替代默认规则
+
+	cronEntry cron.EntryID
+}
+
+// RuleFor 返回群实际使用的规则
+func (c *LogRetentionConfig) RuleFor(groupID string) LogRetentionRule {
+	if rule, ok := c.Groups[groupID]; ok {
+		return rule
+	}
+	return c.Default
+}
+
+func (r *LogRetentionRule) eligible(info *model.LogInfo) bool {
+	if !r.UploadedOnly {
+		return true
+	}
+	return info.UploadURL != "" && int64(info.UploadTime) >= info.UpdatedAt
+}
+
+func logItemCount(info *model.LogInfo) int64 {
+	if info.Size == nil {
+		return 0
+	}
+	return int64(*info.Size)
+}
+
+// expiredLogs 按保留策略挑出需要归档的日志。logs 需按 updated_at 从旧到新排列，skip 返回 true 的日志不会被选中
+func (c *LogRetentionConfig) expiredLogs(logs []*model.LogInfo, now time.Time, skip func(info *model.LogInfo) bool) []*model.LogInfo {
+	groupItems := map[string]int64{}
+	for _, info := range logs {
+		groupItems[info.GroupID] += logItemCount(info)
+	}
+
+	var expired []*model.LogInfo
+	for _, info := range logs {
+		rule := c.RuleFor(info.GroupID)
+		if !rule.eligible(info) || skip(info) {
+			continue
+		}
+		tooOld := rule.MaxAgeDays > 0 && info.UpdatedAt < now.AddDate(0, 0, -rule.MaxAgeDays).Unix()
+		tooMany := rule.MaxItems > 0 && groupItems[info.GroupID] > rule.MaxItems
+		if tooOld || tooMany {
+			groupItems[info.GroupID] -= logItemCount(info)
+			expired = append(expired, info)
+		}
+	}
+	return expired
+}
+
+// Validate 检查设置，返回的错误可直接展示给用户
+func (c *LogRetentionConfig) Validate() error {
+	if c.Enable {
+		if _, err := cron.ParseStandard(c.Cron); err != nil {
+			return fmt.Errorf("日志归档的执行时间 %q 有误: %w", c.Cron, err)
+		}
+	}
+	rules := map[string]LogRetentionRule{"默认规则": c.Default}
+	for groupID, rule := range c.Groups {
+		rules[groupID] = rule
+	}
+	for name, rule := range rules {
+		if rule.MaxAgeDays < 0 || rule.MaxItems < 0 {
+			return fmt.Errorf("%s 的保留天数与条目上限不能为负数", name)
+		}
+	}
+	return nil
+}
+
+// SetLogRetention 修改日志保留策略并重新安排归档任务
+func (d *Dice) SetLogRetention(cfg LogRetentionConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	cfg.cronEntry = d.Config.LogRetention.cronEntry
+	d.Config.LogRetention = cfg
+	d.ResetLogRetentionCron()
+	d.MarkModified()
+	d.Save(false)
+	return nil
+}
+
+// LogArchiveDir 归档文件所在目录
+func (d *Dice) LogArchiveDir() string {
+	return filepath.Join(d.BaseConfig.DataDir, storylog.ArchiveDirName)
+}
+
+// isLogRecording 日志是否正在记录中
+func (d *Dice) isLogRecording(info *model.LogInfo) bool {
+	if d.ImSession == nil || d.ImSession.ServiceAtNew == nil {
+		return false
+	}
+	group, ok := d.ImSession.ServiceAtNew.Load(info.GroupID)
+	return ok && group != nil && group.LogOn && group.LogCurName == info.Name
+}
+
+var logRetentionMutex sync.Mutex
+
+// LogRetentionRun 按保留策略归档过期日志，正在记录的日志不会被归档
+func (d *Dice) LogRetentionRun() ([]*storylog.ArchiveInfo, error) {
+	if !logRetentionMutex.TryLock() {
+		return nil, errors.New("日志归档正在进行中")
+	}
+	defer logRetentionMutex.Unlock()
+
+	logs, err := service.LogGetRetentionInfos(d.DBOperator)
+	if err != nil {
+		return nil, err
+	}
+	expired := d.Config.LogRetention.expiredLogs(logs, time.Now(), d.isLogRecording)
+
+	archives := make([]*storylog.ArchiveInfo, 0, len(expired))
+	for _, info := range expired {
+		archive, errArchive := storylog.ArchiveLog(d.DBOperator, d.LogArchiveDir(), info)
+		if errArchive != nil {
+			d.Logger.Warnf("归档日志失败 <%s>(%s): %v", info.Name, info.GroupID, errArchive)
+			continue
+		}
+		archives = append(archives, archive)
+	}
+	if len(expired) > 0 {
+		d.Logger.Infof("日志保留策略: 归档了 %d/%d 个过期日志", len(archives), len(expired))
+	}
+	return archives, nil
+}
+
+// ResetLogRetentionCron 按当前设置重新安排日志归档任务
+func (d *Dice) ResetLogRetentionCron() {
+	cfg := &d.Config.LogRetention
+	if cfg.cronEntry > 0 {
+		d.Cron.Remove(cfg.cronEntry)
+		cfg.cronEntry = 0
+	}
+	if !cfg.Enable {
+		return
+	}
+	entry, err := d.Cron.AddFunc(cfg.Cron, func() {
+		if _, errRun := d.LogRetentionRun(); errRun != nil {
+			d.Logger.Errorf("日志保留策略执行失败: %v", errRun)
+		}
+	})
+	if err != nil {
+		d.Logger.Errorf("设定的日志归档cron有误: %q %v", cfg.Cron, err)
+		return
+	}
+	cfg.cronEntry = entry
+}
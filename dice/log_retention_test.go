@@ -0,0 +1,88 @@
+package dice //nolint:testpackage
+
+import (
+	"testing"
+	"time"
+
+	"sealdice-core/model"
+)
+
+func retentionLog(id uint64, group string, daysAgo int, size int, now time.Time) *model.LogInfo {
+	return &model.LogInfo{
+		ID:        id,
+		Name:      "log",
+		GroupID:   group,
+		UpdatedAt: now.AddDate(0, 0, -daysAgo).Unix(),
+		Size:      &size,
+	}
+}
+
+func expiredIDs(logs []*model.LogInfo) []uint64 {
+	ids := make([]uint64, 0, len(logs))
+	for _, info := range logs {
+		ids = append(ids, info.ID)
+	}
+	return ids
+}
+
+func TestLogRetentionExpiredLogs(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	uploaded := retentionLog(3, "g1", 40, 10, now)
+	uploaded.UploadURL = "https://example.com/log"
+	uploaded.UploadTime = int(uploaded.UpdatedAt)
+	// 按 updated_at 从旧到新
+	logs := []*model.LogInfo{
+		retentionLog(1, "g1", 100, 10, now),
+		retentionLog(2, "g2", 90, 10, now),
+		uploaded,
+		retentionLog(4, "g2", 20, 50, now),
+		retentionLog(5, "g2", 10, 50, now),
+		retentionLog(6, "g3", 5, 10, now),
+	}
+	cfg := LogRetentionConfig{
+		Default: LogRetentionRule{MaxAgeDays: 30},
+		Groups: map[string]LogRetentionRule{
+			"g2": {MaxItems: 60},
+			"g3": {UploadedOnly: true, MaxAgeDays: 1},
+		},
+	}
+	skip := func(info *model.LogInfo) bool { return info.ID == 5 }
+
+	got := expiredIDs(cfg.expiredLogs(logs, now, skip))
+	// g1: 超过 30 天的 1、3；g2: 共 110 条超过 60，依次归档 2、4，5 正在记录；g3: 未上传不归档
+	want := []uint64{1, 3, 2, 4}
+	if len(got) != len(want) {
+		t.Fatalf("expired = %v, want %v", got, want)
+	}
+	seen := map[uint64]bool{}
+	for _, id := range got {
+		seen[id] = true
+	}
+	for _, id := range want {
+		if !seen[id] {
+			t.Fatalf("expired = %v, want %v", got, want)
+		}
+	}
+
+	cfg.Groups["g1"] = LogRetentionRule{UploadedOnly: true, MaxAgeDays: 30}
+	got = expiredIDs(cfg.expiredLogs(logs[:3], now, skip))
+	if len(got) != 1 || got[0] != 3 {
+		t.Fatalf("uploaded only: expired = %v, want [3]", got)
+	}
+}
+
+func TestLogRetentionConfigValidate(t *testing.T) {
+	cfg := LogRetentionConfig{Enable: true, Cron: "0 4 * * *"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	cfg.Cron = "every day"
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("invalid cron should fail")
+	}
+	cfg.Cron = "@daily"
+	cfg.Groups = map[string]LogRetentionRule{"g": {MaxItems: -1}}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("negative limit should fail")
+	}
+}
@@ -0,0 +1,190 @@
+package dice
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sealdice-core/dice/docengine"
+	"sealdice-core/dice/service"
+	"sealdice-core/logger"
+	"sealdice-core/model"
+	"sealdice-core/utils/dboperator/engine"
+)
+
+// LogSearchIndexDir 日志全文索引目录。所有骰子共用一个日志库，索引也只有一份
+var LogSearchIndexDir = "./data/_log_index"
+
+const logSearchRebuildBatch = 1000
+
+// LogSearch 跑团日志全文搜索，通过 service.SetLogIndexer 接收日志的增删改
+type LogSearch struct {
+	// mu 保护 index 的替换：重建时整体换成新索引，其余操作只读
+	mu         sync.RWMutex
+	index      *docengine.LogIndex
+	rebuilding atomic.Bool
+}
+
+func toLogIndexItems(items []model.LogOneItem) []docengine.LogIndexItem {
+	ret := make([]docengine.LogIndexItem, 0, len(items))
+	for _, item := range items {
+		ret = append(ret, docengine.LogIndexItem{
+			ID:       item.ID,
+			LogID:    item.LogID,
+			GroupID:  item.GroupID,
+			IMUserID: item.IMUserID,
+			Time:     item.Time,
+			IsDice:   item.IsDice,
+			Message:  item.Message,
+		})
+	}
+	return ret
+}
+
+func (s *LogSearch) LogItemsChanged(items []model.LogOneItem) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index.Add(toLogIndexItems(items))
+}
+
+func (s *LogSearch) LogItemsRemoved(ids []uint64) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index.Delete(ids)
+}
+
+func (s *LogSearch) LogRemoved(logID uint64) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index.DeleteLog(logID)
+}
+
+// Rebuilding 是否正在重建索引，重建期间搜索结果不完整
+func (s *LogSearch) Rebuilding() bool {
+	return s.rebuilding.Load()
+}
+
+// Rebuild 清空索引后从数据库重新导入全部消息
+func (s *LogSearch) Rebuild(operator engine.DatabaseOperator) error {
+	if !s.rebuilding.CompareAndSwap(false, true) {
+		return errors.New("日志索引正在重建中")
+	}
+	defer s.rebuilding.Store(false)
+
+	s.mu.Lock()
+	s.index.Close()
+	_ = os.RemoveAll(LogSearchIndexDir)
+	index, err := docengine.OpenLogIndex(LogSearchIndexDir)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.index = index
+	s.mu.Unlock()
+
+	return s.importAll(operator)
+}
+
+func (s *LogSearch) importAll(operator engine.DatabaseOperator) error {
+	log := logger.M()
+	log.Info("开始建立日志全文索引")
+	var count int
+	err := service.LogForEachItem(operator, logSearchRebuildBatch, func(items []model.LogOneItem) error {
+		count += len(items)
+		return s.LogItemsChanged(items)
+	})
+	if err != nil {
+		log.Errorf("建立日志全文索引失败: %v", err)
+		return err
+	}
+	log.Infof("日志全文索引建立完成，共 %d 条消息", count)
+	return nil
+}
+
+// Search 搜索消息并从数据库取出内容
+func (s *LogSearch) Search(operator engine.DatabaseOperator, q docengine.LogSearchQuery) ([]*service.LogItemHit, uint64, error) {
+	s.mu.RLock()
+	ids, total, err := s.index.Search(q)
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, 0, err
+	}
+	hits, err := service.LogGetItemsByIDs(operator, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	return hits, total, nil
+}
+
+func (s *LogSearch) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.index.Close()
+}
+
+// InitLogSearch 打开日志全文索引，新建的索引在后台从数据库导入
+func (dm *DiceManager) InitLogSearch() {
+	log := logger.M()
+	index, err := docengine.OpenLogIndex(LogSearchIndexDir)
+	if err != nil {
+		log.Errorf("日志全文索引打开失败，日志搜索不可用: %v", err)
+		return
+	}
+	s := &LogSearch{index: index}
+	dm.LogSearch = s
+	service.SetLogIndexer(s)
+	if index.FreshlyCreated() {
+		go func() {
+			if !s.rebuilding.CompareAndSwap(false, true) {
+				return
+			}
+			defer s.rebuilding.Store(false)
+			_ = s.importAll(dm.Operator)
+		}()
+	}
+}
+
+const logSearchReplyLimit = 5
+
+// logSearchText .log search 的回复：列出本群最相关的几条消息
+func logSearchText(ctx *MsgContext, groupID string, keyword string) string {
+	dm := ctx.Dice.Parent
+	if dm == nil || dm.LogSearch == nil {
+		return "日志搜索不可用，请联系骰主查看后台日志"
+	}
+	hits, total, err := dm.LogSearch.Search(ctx.Dice.DBOperator, docengine.LogSearchQuery{
+		Keyword:  keyword,
+		GroupID:  groupID,
+		PageSize: logSearchReplyLimit,
+	})
+	if err != nil {
+		return "日志搜索出错: " + err.Error()
+	}
+
+	var text strings.Builder
+	if len(hits) == 0 {
+		text.WriteString(fmt.Sprintf("没有在本群的日志中找到“%s”", keyword))
+	} else {
+		text.WriteString(fmt.Sprintf("在本群的日志中找到 %d 条包含“%s”的消息", total, keyword))
+		if total > uint64(len(hits)) {
+			text.WriteString(fmt.Sprintf("，以下是最相关的 %d 条", len(hits)))
+		}
+		text.WriteString(":")
+		for _, hit := range hits {
+			message := []rune(strings.TrimSpace(hit.Item.Message))
+			if len(message) > 50 {
+				message = append(message[:50], []rune("…")...)
+			}
+			text.WriteString(fmt.Sprintf("\n[%s] %s %s: %s",
+				hit.LogName, time.Unix(hit.Item.Time, 0).Format("2006-01-02 15:04"), hit.Item.Nickname, string(message)))
+		}
+	}
+	if dm.LogSearch.Rebuilding() {
+		text.WriteString("\n日志索引正在建立中，结果可能不完整")
+	}
+	return text.String()
+}
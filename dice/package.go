@@ -9,6 +9,8 @@ type DependencyError struct {
 	PackageID       string   `json:"packageId"`
 	MissingDeps     []string `json:"missingDeps"`
 	VersionMismatch []string `json:"versionMismatch"`
+	// Conflicts 自动解析依赖时无法满足的约束
+	Conflicts []string `json:"conflicts,omitempty"`
 }
 
 func (e *DependencyError) Error() string {
@@ -34,5 +36,9 @@ func (e *DependencyError) Error() string {
 			b.WriteString(dep)
 		}
 	}
+	if len(e.Conflicts) > 0 {
+		b.WriteString(", 无法自动解决: ")
+		b.WriteString(strings.Join(e.Conflicts, "; "))
+	}
 	return b.String()
 }
@@ -0,0 +1,384 @@
+package dice
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+
+	"sealdice-core/dice/sealpack"
+)
+
+// 依赖解析的最大迭代次数，防止商店数据异常时无限重解析
+const packageDependencyResolveMaxIterations = 256
+
+// 安装计划动作
+const (
+	PackagePlanActionInstall = "install"
+	PackagePlanActionUpgrade = "upgrade"
+)
+
+// PackageInstallPlanItem 安装计划中需要从商店获取的一个依赖包
+type PackageInstallPlanItem struct {
+	ID             string   `json:"id"`
+	Version        string   `json:"version"`
+	CurrentVersion string   `json:"currentVersion,omitempty"`
+	Action         string   `json:"action"`
+	Constraints    []string `json:"constraints"`
+	RequiredBy     []string `json:"requiredBy"`
+
+	store *StorePackage
+}
+
+// PackageInstallPlan 安装扩展包前需要补齐的依赖。
+// Items 按被依赖者在前的拓扑顺序排列；Conflicts 非空时无法自动安装。
+type PackageInstallPlan struct {
+	Items     []*PackageInstallPlanItem `json:"items"`
+	Conflicts []string                  `json:"conflicts"`
+}
+
+type packageDependencyRequest struct {
+	constraints []string
+	requiredBy  []string
+}
+
+func (r *packageDependencyRequest) add(constraint, requiredBy string) bool {
+	changed := false
+	if !slices.Contains(r.constraints, constraint) {
+		r.constraints = append(r.constraints, constraint)
+		changed = true
+	}
+	if !slices.Contains(r.requiredBy, requiredBy) {
+		r.requiredBy = append(r.requiredBy, requiredBy)
+	}
+	return changed
+}
+
+func versionSatisfiesAll(version string, constraints []string) bool {
+	for _, constraint := range constraints {
+		if ok, err := sealpack.CheckDependencyConstraint(constraint, version); err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedDependencyIDs(deps map[string]string) []string {
+	ids := make([]string, 0, len(deps))
+	for id := range deps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// resolveStorePackage 在商店中查找满足约束的包
+func (pm *PackageManager) resolveStorePackage(id string, constraints []string) (*StorePackage, error) {
+	if pm.parent == nil || pm.parent.StoreManager == nil {
+		return nil, fmt.Errorf("未配置扩展商店，无法获取 %s", id)
+	}
+	return pm.parent.StoreManager.ResolvePackage(id, constraints)
+}
+
+// ResolveDependencies 根据 manifest 的依赖约束，对照已安装的包与当前商店后端生成安装计划。
+// 已安装的其他包对同一依赖的约束也会参与求解，避免升级依赖后破坏它们。
+// 该方法会访问商店，调用时不能持有 pm.lock。
+func (pm *PackageManager) ResolveDependencies(manifest *sealpack.Manifest) *PackageInstallPlan {
+	rootID := manifest.Package.ID
+
+	installed := map[string]string{}
+	installedConstraints := map[string][]string{}
+	pm.lock.RLock()
+	for pkgID, pkg := range pm.packages {
+		if pkg.Manifest == nil {
+			continue
+		}
+		installed[pkgID] = pkg.Manifest.Package.Version
+		if pkgID == rootID {
+			// 即将被替换，旧版本的约束不再生效
+			continue
+		}
+		for depID, constraint := range pkg.Manifest.Dependencies {
+			installedConstraints[depID] = append(installedConstraints[depID], constraint)
+		}
+	}
+	pm.lock.RUnlock()
+
+	requests := map[string]*packageDependencyRequest{}
+	queue := make([]string, 0, len(manifest.Dependencies))
+	require := func(depID, constraint, requiredBy string) {
+		req := requests[depID]
+		if req == nil {
+			req = &packageDependencyRequest{}
+			requests[depID] = req
+		}
+		if req.add(constraint, requiredBy) {
+			queue = append(queue, depID)
+		}
+	}
+	for _, depID := range sortedDependencyIDs(manifest.Dependencies) {
+		require(depID, manifest.Dependencies[depID], rootID)
+	}
+
+	resolved := map[string]*PackageInstallPlanItem{}
+	conflicts := map[string]string{}
+	for i := 0; len(queue) > 0; i++ {
+		if i >= packageDependencyResolveMaxIterations {
+			conflicts[queue[0]] = "依赖解析次数过多，可能存在无法满足的约束"
+			break
+		}
+		depID := queue[0]
+		queue = queue[1:]
+		req := requests[depID]
+		constraintText := strings.Join(req.constraints, ", ")
+
+		if depID == rootID {
+			if !versionSatisfiesAll(manifest.Package.Version, req.constraints) {
+				conflicts[depID] = fmt.Sprintf("%s 被要求 %s，与正在安装的版本 %s 冲突", depID, constraintText, manifest.Package.Version)
+			}
+			continue
+		}
+		current, isInstalled := installed[depID]
+		if isInstalled && versionSatisfiesAll(current, req.constraints) {
+			delete(resolved, depID)
+			continue
+		}
+		all := append(append([]string(nil), req.constraints...), installedConstraints[depID]...)
+		if item := resolved[depID]; item != nil && versionSatisfiesAll(item.Version, all) {
+			item.Constraints = req.constraints
+			item.RequiredBy = req.requiredBy
+			continue
+		}
+
+		storePkg, err := pm.resolveStorePackage(depID, all)
+		if err != nil {
+			delete(resolved, depID)
+			conflicts[depID] = fmt.Sprintf("%s (需要 %s): %v", depID, strings.Join(all, ", "), err)
+			continue
+		}
+		item := &PackageInstallPlanItem{
+			ID:          depID,
+			Version:     storePkg.Version,
+			Action:      PackagePlanActionInstall,
+			Constraints: req.constraints,
+			RequiredBy:  req.requiredBy,
+			store:       storePkg,
+		}
+		if isInstalled {
+			item.CurrentVersion = current
+			item.Action = PackagePlanActionUpgrade
+			currentVer, curErr := semver.NewVersion(current)
+			nextVer, nextErr := semver.NewVersion(storePkg.Version)
+			if curErr == nil && nextErr == nil && !nextVer.GreaterThan(currentVer) {
+				delete(resolved, depID)
+				conflicts[depID] = fmt.Sprintf("%s 已安装 %s，不满足 %s，且商店中没有更高的可用版本", depID, current, constraintText)
+				continue
+			}
+		}
+		delete(conflicts, depID)
+		resolved[depID] = item
+
+		for _, next := range sortedDependencyIDs(storePkg.Dependencies) {
+			require(next, storePkg.Dependencies[next], depID)
+		}
+	}
+
+	items, cycles := orderPlanItems(resolved)
+	plan := &PackageInstallPlan{Items: items, Conflicts: append([]string{}, cycles...)}
+	for _, depID := range sortedKeys(conflicts) {
+		plan.Conflicts = append(plan.Conflicts, conflicts[depID])
+	}
+	return plan
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// orderPlanItems 按依赖关系排序，被依赖者在前；发现循环依赖时返回冲突说明
+func orderPlanItems(resolved map[string]*PackageInstallPlanItem) ([]*PackageInstallPlanItem, []string) {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	marks := map[string]int{}
+	ordered := make([]*PackageInstallPlanItem, 0, len(resolved))
+	var conflicts []string
+
+	var visit func(id string, path []string)
+	visit = func(id string, path []string) {
+		item := resolved[id]
+		if item == nil || marks[id] == visited {
+			return
+		}
+		if marks[id] == visiting {
+			conflicts = append(conflicts, "循环依赖: "+strings.Join(append(path, id), " -> "))
+			return
+		}
+		marks[id] = visiting
+		for _, depID := range sortedDependencyIDs(item.store.Dependencies) {
+			visit(depID, append(path, id))
+		}
+		marks[id] = visited
+		ordered = append(ordered, item)
+	}
+	for _, id := range sortedKeys(resolved) {
+		visit(id, nil)
+	}
+	return ordered, conflicts
+}
+
+// installMissingDependencies 在安装 pkgPath 之前，按安装计划从商店补齐缺失或版本不足的依赖。
+// 返回本次新装与升级的依赖，失败时也会返回已完成的部分，供调用方回退或报告
+func (pm *PackageManager) installMissingDependencies(pkgPath string) (added []string, upgraded []string, err error) {
+	archiveInfo, err := sealpack.InspectArchive(pkgPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifest := archiveInfo.Manifest
+
+	pm.lock.RLock()
+	satisfied, missing := pm.CheckDependencies(manifest)
+	pm.lock.RUnlock()
+	if satisfied {
+		return nil, nil, nil
+	}
+
+	plan := pm.ResolveDependencies(manifest)
+	if len(plan.Conflicts) > 0 {
+		return nil, nil, &DependencyError{
+			PackageID:   manifest.Package.ID,
+			MissingDeps: missing,
+			Conflicts:   plan.Conflicts,
+		}
+	}
+
+	for _, item := range plan.Items {
+		pm.parent.Logger.Infof("正在为 %s 安装依赖 %s v%s", manifest.Package.ID, item.ID, item.Version)
+		tmpPath, errDownload := pm.prepareDownloadedPackage(item.store.Download.URL, item.store.Download.Hash, "package_dependency_*.sealpack")
+		if errDownload != nil {
+			return added, upgraded, fmt.Errorf("下载依赖 %s 失败: %w", item.ID, errDownload)
+		}
+		_, existed := pm.Get(item.ID)
+		err = pm.installFromSource(tmpPath)
+		if removeErr := os.Remove(tmpPath); removeErr != nil {
+			pm.parent.Logger.Warnf("清理临时扩展包文件失败 %s: %v", tmpPath, removeErr)
+		}
+		if err != nil {
+			return added, upgraded, fmt.Errorf("安装依赖 %s 失败: %w", item.ID, err)
+		}
+		if existed {
+			upgraded = append(upgraded, item.ID)
+		} else {
+			added = append(added, item.ID)
+		}
+	}
+	return added, upgraded, nil
+}
+
+// revertDependencies 安装失败后按相反顺序卸载本次新装的依赖（保留用户数据），
+// 无法回退的升级与卸载失败的依赖附在错误信息中
+func (pm *PackageManager) revertDependencies(cause error, added []string, upgraded []string) error {
+	var leftover []string
+	for i := len(added) - 1; i >= 0; i-- {
+		if err := pm.Uninstall(added[i], sealpack.UninstallModeKeepData); err != nil {
+			pm.parent.Logger.Warnf("回退依赖 %s 失败: %v", added[i], err)
+			leftover = append(leftover, added[i])
+		}
+	}
+	if len(leftover) > 0 {
+		cause = fmt.Errorf("%w；以下依赖已安装但未能移除: %s", cause, strings.Join(leftover, ", "))
+	}
+	if len(upgraded) > 0 {
+		cause = fmt.Errorf("%w；以下依赖已在本次安装中升级: %s", cause, strings.Join(upgraded, ", "))
+	}
+	return cause
+}
+
+// dependencyOrderLocked 返回 pkgID 已安装的直接与间接依赖，被依赖者在前，不含自身；调用方需持有锁
+func (pm *PackageManager) dependencyOrderLocked(pkgID string) []string {
+	visited := map[string]bool{pkgID: true}
+	ordered := make([]string, 0)
+	var visit func(id string)
+	visit = func(id string) {
+		deps := append([]string(nil), pm.dependencyGraph[id]...)
+		sort.Strings(deps)
+		for _, depID := range deps {
+			if visited[depID] {
+				continue
+			}
+			visited[depID] = true
+			visit(depID)
+			ordered = append(ordered, depID)
+		}
+	}
+	visit(pkgID)
+	return ordered
+}
+
+// enabledDependentsLocked 返回直接或间接依赖 pkgID 的已启用扩展包，调用方需持有锁
+func (pm *PackageManager) enabledDependentsLocked(pkgID string, transitive bool) []string {
+	seen := map[string]bool{pkgID: true}
+	queue := []string{pkgID}
+	result := make([]string, 0)
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, depID := range pm.reverseDependencyGraph[current] {
+			if seen[depID] {
+				continue
+			}
+			seen[depID] = true
+			if depPkg, ok := pm.packages[depID]; ok && depPkg.State == sealpack.PackageStateEnabled {
+				result = append(result, depID)
+				if transitive {
+					queue = append(queue, depID)
+				}
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// applyDependentsPolicyLocked 在禁用或卸载 pkgID 前按策略处理仍启用的依赖方。
+// 级联时返回被一并禁用的包及其重载提示。
+func (pm *PackageManager) applyDependentsPolicyLocked(pkgID string, policy sealpack.DependentsPolicy, refuseMsg string) ([]string, []string, error) {
+	direct := pm.enabledDependentsLocked(pkgID, false)
+	if len(direct) == 0 {
+		return nil, nil, nil
+	}
+
+	switch policy {
+	case sealpack.DependentsPolicyForce:
+		pm.parent.Logger.Warnf("强制操作扩展包 %s，以下依赖它的扩展包可能无法正常工作: %s", pkgID, strings.Join(direct, ", "))
+		return nil, nil, nil
+	case sealpack.DependentsPolicyCascade:
+		dependents := pm.enabledDependentsLocked(pkgID, true)
+		hints := make([]string, 0)
+		for _, depID := range dependents {
+			result, err := pm.disableInternal(depID)
+			if err != nil {
+				return nil, nil, fmt.Errorf("禁用依赖方 %s 失败: %w", depID, err)
+			}
+			for _, hint := range result.ReloadHints {
+				if !slices.Contains(hints, hint) {
+					hints = append(hints, hint)
+				}
+			}
+		}
+		return dependents, hints, nil
+	case sealpack.DependentsPolicyRefuse:
+		return nil, nil, fmt.Errorf("%s: %s", refuseMsg, strings.Join(direct, ", "))
+	default:
+		return nil, nil, fmt.Errorf("未知的依赖处理方式: %s", policy)
+	}
+}
@@ -0,0 +1,260 @@
+package dice //nolint:testpackage
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sealdice-core/dice/sealpack"
+)
+
+func withDependencies(deps map[string]string) manifestOption {
+	return func(b *strings.Builder) {
+		b.WriteString("\n[dependencies]\n")
+		for _, id := range sortedDependencyIDs(deps) {
+			fmt.Fprintf(b, "%q = %q\n", id, deps[id])
+		}
+	}
+}
+
+type testStorePackage struct {
+	id      string
+	version string
+	deps    map[string]string
+	opts    []manifestOption
+
+	contents map[string][]string
+	files    map[string]string
+}
+
+// newTestDependencyStore 启动一个提供 /page 与 .sealpack 下载的商店后端
+func newTestDependencyStore(t *testing.T, d *Dice, packages []testStorePackage) {
+	t.Helper()
+	archives := map[string][]byte{}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/dice/api/store/info":
+			_, _ = w.Write([]byte(`{"name":"Test Store","protocolVersions":["2.0"]}`))
+		case r.URL.Path == "/dice/api/store/page":
+			id := r.URL.Query().Get("author") + "/" + r.URL.Query().Get("name")
+			data := make([]*StorePackage, 0)
+			for _, pkg := range packages {
+				if pkg.id != id {
+					continue
+				}
+				data = append(data, &StorePackage{
+					ID:           pkg.id,
+					Version:      pkg.version,
+					Name:         pkg.id,
+					Dependencies: pkg.deps,
+					Download: StorePackageDownload{
+						URL: fmt.Sprintf("%s/dl/%s-%s.sealpack", server.URL, strings.ReplaceAll(pkg.id, "/", "-"), pkg.version),
+					},
+				})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"result": true,
+				"data":   map[string]interface{}{"data": data, "pageNum": 1, "pageSize": 50, "next": false},
+			})
+		case strings.HasPrefix(r.URL.Path, "/dl/"):
+			body, ok := archives[strings.TrimPrefix(r.URL.Path, "/dl/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write(body)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	withOfficialStoreBackendBaseURL(t, server.URL)
+
+	storeDir := t.TempDir()
+	for _, pkg := range packages {
+		opts := append([]manifestOption{withDependencies(pkg.deps)}, pkg.opts...)
+		path := createTestSealPack(t, storeDir, pkg.id, pkg.version, pkg.contents, pkg.files, opts...)
+		body, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		archives[filepath.Base(path)] = body
+	}
+	d.StoreManager = NewStoreManager(d)
+}
+
+func TestPackageManagerInstallResolvesDependenciesFromStore(t *testing.T) {
+	d, pm := newTestPackageManager(t)
+	if err := pm.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	newTestDependencyStore(t, d, []testStorePackage{
+		{id: "alice/lib", version: "1.0.0", deps: map[string]string{"alice/base": "^1.0.0"}},
+		{id: "alice/lib", version: "1.2.0", deps: map[string]string{"alice/base": "^1.0.0"}},
+		{id: "alice/lib", version: "2.0.0", deps: map[string]string{"alice/base": "^1.0.0"}},
+		{id: "alice/base", version: "1.0.0"},
+	})
+
+	archive := createTestSealPack(t, "", "alice/app", "1.0.0", nil, nil,
+		withDependencies(map[string]string{"alice/lib": ">=1.1.0 <2.0.0"}))
+
+	preview, err := pm.Preview(archive)
+	if err != nil {
+		t.Fatalf("Preview() error = %v", err)
+	}
+	plan := preview.InstallPlan
+	if plan == nil || len(plan.Conflicts) != 0 || len(plan.Items) != 2 {
+		t.Fatalf("unexpected install plan: %+v", plan)
+	}
+	if plan.Items[0].ID != "alice/base" || plan.Items[1].ID != "alice/lib" || plan.Items[1].Version != "1.2.0" {
+		t.Fatalf("plan should install base then lib 1.2.0, got %s@%s, %s@%s",
+			plan.Items[0].ID, plan.Items[0].Version, plan.Items[1].ID, plan.Items[1].Version)
+	}
+
+	if err := pm.Install(archive); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	for id, version := range map[string]string{"alice/app": "1.0.0", "alice/lib": "1.2.0", "alice/base": "1.0.0"} {
+		pkg, ok := pm.Get(id)
+		if !ok || pkg.Manifest.Package.Version != version {
+			t.Fatalf("expected %s@%s to be installed", id, version)
+		}
+	}
+}
+
+func TestPackageManagerInstallReportsUnresolvableDependencies(t *testing.T) {
+	d, pm := newTestPackageManager(t)
+	if err := pm.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	newTestDependencyStore(t, d, []testStorePackage{{id: "alice/lib", version: "1.0.0"}})
+
+	archive := createTestSealPack(t, "", "alice/app", "1.0.0", nil, nil,
+		withDependencies(map[string]string{"alice/lib": "^2.0.0"}))
+	err := pm.Install(archive)
+	var depErr *DependencyError
+	if !errors.As(err, &depErr) || len(depErr.Conflicts) != 1 {
+		t.Fatalf("expected DependencyError with a conflict, got %v", err)
+	}
+	if _, ok := pm.Get("alice/app"); ok {
+		t.Fatal("package should not be installed when dependencies cannot be resolved")
+	}
+}
+
+func TestPackageManagerInstallChecksRootBeforeDependencies(t *testing.T) {
+	d, pm := newTestPackageManager(t)
+	if err := pm.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	d.Config.PackageRequireSigned = true
+	// 依赖本身带有签名，能否安装只取决于根包
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	libManifest := buildTestManifest("alice/lib", "1.0.0", nil, withDependencies(nil))
+	signature, err := sealpack.SignFiles(map[string][]byte{"info.toml": []byte(libManifest)},
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newTestDependencyStore(t, d, []testStorePackage{{
+		id: "alice/lib", version: "1.0.0",
+		files: map[string]string{sealpack.SignatureFile: string(signature)},
+	}})
+
+	archive := createTestSealPack(t, "", "alice/app", "1.0.0", nil, nil,
+		withDependencies(map[string]string{"alice/lib": "^1.0.0"}))
+	if err := pm.Install(archive); err == nil {
+		t.Fatal("unsigned package should be rejected")
+	}
+	if list := pm.List(); len(list) != 0 {
+		t.Fatalf("rejected install should leave no packages, got %d", len(list))
+	}
+}
+
+func TestPackageManagerInstallRevertsDependenciesOnFailure(t *testing.T) {
+	d, pm := newTestPackageManager(t)
+	if err := pm.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	tooNew := func(b *strings.Builder) { b.WriteString("\n[package.seal]\nmin_version = \"99.0.0\"\n") }
+	newTestDependencyStore(t, d, []testStorePackage{
+		{id: "alice/lib", version: "1.0.0", deps: map[string]string{"alice/base": "^1.0.0"}, opts: []manifestOption{tooNew}},
+		{id: "alice/base", version: "1.0.0"},
+	})
+
+	archive := createTestSealPack(t, "", "alice/app", "1.0.0", nil, nil,
+		withDependencies(map[string]string{"alice/lib": "^1.0.0"}))
+	if err := pm.Install(archive); err == nil || !strings.Contains(err.Error(), "alice/lib") {
+		t.Fatalf("expected the lib install to fail, got %v", err)
+	}
+	if list := pm.List(); len(list) != 0 {
+		t.Fatalf("dependencies installed by a failed install should be removed, got %d packages", len(list))
+	}
+}
+
+func TestPackageManagerDisableDependentsPolicy(t *testing.T) {
+	_, pm := newTestPackageManager(t)
+	if err := pm.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	for _, pkg := range []testStorePackage{
+		{id: "alice/base", version: "1.0.0"},
+		{id: "alice/lib", version: "1.0.0", deps: map[string]string{"alice/base": "^1.0.0"}},
+		{id: "alice/app", version: "1.0.0", deps: map[string]string{"alice/lib": "^1.0.0"}},
+	} {
+		if err := pm.Install(createTestSealPack(t, "", pkg.id, pkg.version, nil, nil, withDependencies(pkg.deps))); err != nil {
+			t.Fatalf("Install(%s) error = %v", pkg.id, err)
+		}
+	}
+	if _, err := pm.Enable("alice/app"); err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+
+	if _, err := pm.Disable("alice/base"); err == nil || !strings.Contains(err.Error(), "alice/lib") {
+		t.Fatalf("Disable() should refuse while alice/lib depends on it, err = %v", err)
+	}
+	if err := pm.Uninstall("alice/base", sealpack.UninstallModeFull); err == nil {
+		t.Fatal("Uninstall() should refuse while dependents are enabled")
+	}
+
+	result, err := pm.DisableWithPolicy("alice/base", sealpack.DependentsPolicyCascade)
+	if err != nil {
+		t.Fatalf("DisableWithPolicy(cascade) error = %v", err)
+	}
+	if !strings.Contains(result.Message, "alice/app") {
+		t.Fatalf("cascade message should list disabled dependents, got %q", result.Message)
+	}
+	for _, id := range []string{"alice/base", "alice/lib", "alice/app"} {
+		if pkg, _ := pm.Get(id); pkg.State != sealpack.PackageStateDisabled {
+			t.Fatalf("%s should be disabled after cascade, state = %s", id, pkg.State)
+		}
+	}
+
+	if _, err := pm.Enable("alice/lib"); err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+	if _, err := pm.DisableWithPolicy("alice/base", sealpack.DependentsPolicyForce); err != nil {
+		t.Fatalf("DisableWithPolicy(force) error = %v", err)
+	}
+	if pkg, _ := pm.Get("alice/lib"); pkg.State != sealpack.PackageStateEnabled {
+		t.Fatal("force should leave dependents enabled")
+	}
+}
@@ -0,0 +1,244 @@
+package dice
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	esbuild "github.com/evanw/esbuild/pkg/api"
+	"go.uber.org/zap"
+
+	"sealdice-core/dice/sealpack"
+)
+
+// PackageLintReport 扩展包源目录的检查结果
+type PackageLintReport struct {
+	Manifest *sealpack.Manifest `json:"manifest"`
+	Errors   []string           `json:"errors"`
+	Warnings []string           `json:"warnings"`
+
+	// Files 通过检查后将被打包的文件
+	Files map[string][]byte `json:"-"`
+}
+
+// OK 没有错误时可以打包
+func (r *PackageLintReport) OK() bool {
+	return len(r.Errors) == 0
+}
+
+func (r *PackageLintReport) addError(file string, err error) {
+	r.Errors = append(r.Errors, fmt.Sprintf("%s: %v", file, err))
+}
+
+// LintPackageDir 对扩展包源目录执行与安装、加载时相同的校验：
+// info.toml、contents 模式、配置默认值、归档路径，以及脚本、牌堆、回复、模板与帮助文档的解析。
+// 只有目录无法读取时返回 error，校验问题记录在报告中。
+func LintPackageDir(dir string) (*PackageLintReport, error) {
+	files, skipped, err := sealpack.CollectDirectory(dir)
+	if err != nil {
+		return nil, err
+	}
+	report := &PackageLintReport{Files: files}
+	for _, name := range skipped {
+		report.Warnings = append(report.Warnings, name+": 不属于扩展包目录布局，打包时将被忽略")
+	}
+
+	archiveInfo, err := sealpack.InspectFiles(files)
+	if err != nil {
+		report.addError(sealpack.InfoFile, err)
+		return report, nil
+	}
+	manifest := archiveInfo.Manifest
+	report.Manifest = manifest
+	for _, issue := range sealpack.ValidateManifest(manifest) {
+		report.addError(sealpack.InfoFile, errors.New(issue))
+	}
+	fileErrs, fileWarnings := sealpack.ValidatePackageFiles(manifest, files)
+	for _, issue := range fileErrs {
+		report.addError(sealpack.InfoFile, errors.New(issue))
+	}
+	report.Warnings = append(report.Warnings, fileWarnings...)
+	if err := sealpack.CheckSealVersion(manifest, VERSION.String()); err != nil {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("%s: 当前海豹 %s 无法安装: %v", sealpack.InfoFile, VERSION.String(), err))
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// 仅用于承载解析器所需的日志与配置，不会加载任何内容
+	scratch := &Dice{Logger: zap.NewNop().Sugar()}
+	for _, contentType := range []string{"scripts", "decks", "reply", "templates", "helpdoc"} {
+		patterns := packageContentPatterns(manifest, contentType)
+		for _, name := range names {
+			if !matchesPackageContentPatterns(patterns, name) {
+				continue
+			}
+			fullPath := filepath.Join(dir, filepath.FromSlash(name))
+			if err := lintPackageContentFile(scratch, contentType, fullPath, files[name]); err != nil {
+				report.addError(name, err)
+			}
+		}
+	}
+	return report, nil
+}
+
+func lintPackageContentFile(d *Dice, contentType, fullPath string, data []byte) error {
+	ext := strings.ToLower(filepath.Ext(fullPath))
+	switch contentType {
+	case "scripts":
+		if !isScriptFile(fullPath) {
+			return nil
+		}
+		return lintScriptFile(d, fullPath, data)
+	case "decks":
+		deckInfo := &DeckInfo{
+			DeckItems:          map[string][]string{},
+			Command:            map[string]bool{},
+			CloudDeckItemInfos: map[string]*CloudDeckItemInfo{},
+		}
+		if !parseDeck(d, fullPath, data, deckInfo) {
+			return errors.New(deckInfo.ErrText)
+		}
+	case "reply":
+		if ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		_, err := CustomReplyConfigReadFromPath(d, fullPath, filepath.Base(fullPath))
+		return err
+	case "templates":
+		_, err := LoadGameSystemTemplateFromFile(fullPath)
+		return err
+	case "helpdoc":
+		if ext != ".json" && ext != ".xlsx" {
+			return nil
+		}
+		_, err := parseHelpDocFile("lint", fullPath)
+		return err
+	}
+	return nil
+}
+
+// lintScriptFile 解析脚本头部元信息并编译脚本，与 JsLoadScripts 的处理顺序一致
+func lintScriptFile(d *Dice, fullPath string, data []byte) error {
+	jsInfo, err := d.JsParseMeta(fullPath, time.Now(), data, false)
+	if err != nil {
+		return err
+	}
+	source := string(data)
+	if strings.ToLower(filepath.Ext(fullPath)) == ".ts" || jsInfo.needCompiled {
+		compiled := esbuild.Transform(source, esbuild.TransformOptions{
+			Loader: esbuild.LoaderTS,
+		})
+		if len(compiled.Errors) > 0 {
+			msgs := make([]string, 0, len(compiled.Errors))
+			for _, e := range compiled.Errors {
+				if e.Location != nil {
+					msgs = append(msgs, fmt.Sprintf("%d:%d %s", e.Location.Line, e.Location.Column, e.Text))
+				} else {
+					msgs = append(msgs, e.Text)
+				}
+			}
+			return errors.New(strings.Join(msgs, "; "))
+		}
+		source = string(compiled.Code)
+	}
+	// 与 require 加载模块时相同的包装，允许顶层 return
+	wrapped := "(function(exports, require, module, __filename, __dirname) {" + source + "\n})"
+	if _, err := goja.Compile(fullPath, wrapped, false); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ScaffoldPackageDir 在 dir 中创建新扩展包的 info.toml、README.md 与示例脚本，已存在 info.toml 时拒绝覆盖
+func ScaffoldPackageDir(dir, pkgID, name, author string) error {
+	manifestPath := filepath.Join(dir, sealpack.InfoFile)
+	if _, err := os.Stat(manifestPath); err == nil {
+		return fmt.Errorf("%s 已存在", manifestPath)
+	}
+	manifest, err := sealpack.ScaffoldManifest(pkgID, name, author)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "scripts"), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(manifestPath, manifest, 0o644); err != nil {
+		return err
+	}
+
+	_, pkgName, _ := sealpack.ParsePackageID(pkgID)
+	if name == "" {
+		name = pkgName
+	}
+	if author == "" {
+		author, _, _ = sealpack.ParsePackageID(pkgID)
+	}
+	scaffold := map[string]string{
+		"README.md": "# " + name + "\n",
+		filepath.Join("scripts", "main.js"): "// ==UserScript==\n" +
+			"// @name         " + name + "\n" +
+			"// @author       " + author + "\n" +
+			"// @version      0.1.0\n" +
+			"// @license      MIT\n" +
+			"// ==/UserScript==\n\n" +
+			"let ext = seal.ext.find('" + pkgName + "');\n" +
+			"if (!ext) {\n" +
+			"  ext = seal.ext.new('" + pkgName + "', '" + author + "', '0.1.0');\n" +
+			"  seal.ext.register(ext);\n" +
+			"}\n",
+	}
+	for rel, body := range scaffold {
+		target := filepath.Join(dir, rel)
+		if _, err := os.Stat(target); err == nil {
+			continue
+		}
+		if err := os.WriteFile(target, []byte(body), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PackPackageDir 检查源目录并写出可复现的 .sealpack；提供私钥时同时写入 signature.json。
+// 检查存在错误时不会写出文件。
+func PackPackageDir(dir, outPath, privateKeyPEM, signer string) (*PackageLintReport, error) {
+	report, err := LintPackageDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !report.OK() {
+		return report, errors.New("扩展包检查未通过")
+	}
+
+	files := report.Files
+	if privateKeyPEM != "" {
+		sig, err := sealpack.SignFiles(files, privateKeyPEM, signer)
+		if err != nil {
+			return report, err
+		}
+		files[sealpack.SignatureFile] = sig
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
+		return report, err
+	}
+	out, err := os.Create(outPath)
+	if err != nil {
+		return report, err
+	}
+	if err := sealpack.WriteArchive(out, files); err != nil {
+		_ = out.Close()
+		_ = os.Remove(outPath)
+		return report, err
+	}
+	return report, out.Close()
+}
@@ -0,0 +1,78 @@
+package dice //nolint:testpackage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sealdice-core/dice/sealpack"
+)
+
+func TestLintPackageDirUsesRuntimeParsers(t *testing.T) {
+	dir := t.TempDir()
+	if err := ScaffoldPackageDir(dir, "alice/demo", "Demo", ""); err != nil {
+		t.Fatalf("ScaffoldPackageDir() error = %v", err)
+	}
+	if err := ScaffoldPackageDir(dir, "alice/demo", "Demo", ""); err == nil {
+		t.Fatal("ScaffoldPackageDir() should refuse to overwrite info.toml")
+	}
+	report, err := LintPackageDir(dir)
+	if err != nil {
+		t.Fatalf("LintPackageDir() error = %v", err)
+	}
+	if !report.OK() || len(report.Warnings) != 0 {
+		t.Fatalf("scaffold should lint cleanly, errors=%v warnings=%v", report.Errors, report.Warnings)
+	}
+
+	manifest, err := os.ReadFile(filepath.Join(dir, sealpack.InfoFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest = []byte(strings.Replace(string(manifest), "[contents]\n", "[contents]\ndecks = [\"decks/*.json\"]\n", 1))
+	for name, body := range map[string]string{
+		sealpack.InfoFile:   string(manifest),
+		"scripts/bad.js":    "function (",
+		"decks/broken.json": "{not json",
+	} {
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(target, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err = LintPackageDir(dir)
+	if err != nil {
+		t.Fatalf("LintPackageDir() error = %v", err)
+	}
+	joined := strings.Join(report.Errors, "\n")
+	if !strings.Contains(joined, "scripts/bad.js") || !strings.Contains(joined, "decks/broken.json") {
+		t.Fatalf("expected script and deck errors, got %v", report.Errors)
+	}
+
+	out := filepath.Join(t.TempDir(), "demo.sealpack")
+	if _, err := PackPackageDir(dir, out, "", ""); err == nil {
+		t.Fatal("PackPackageDir() should refuse to pack a package with lint errors")
+	}
+	if _, statErr := os.Stat(out); !os.IsNotExist(statErr) {
+		t.Fatal("no archive should be written when lint fails")
+	}
+
+	_ = os.Remove(filepath.Join(dir, "scripts", "bad.js"))
+	if err := os.WriteFile(filepath.Join(dir, "decks", "broken.json"), []byte(`{"_title":["demo"],"draw":["a","b"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PackPackageDir(dir, out, "", ""); err != nil {
+		t.Fatalf("PackPackageDir() error = %v", err)
+	}
+	info, err := sealpack.InspectArchive(out)
+	if err != nil {
+		t.Fatalf("InspectArchive() error = %v", err)
+	}
+	if info.Manifest.Package.ID != "alice/demo" || len(info.Files) != 4 {
+		t.Fatalf("unexpected archive contents: %v", info.Files)
+	}
+}
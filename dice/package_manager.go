@@ -10,8 +10,8 @@ import (
 	"io/fs"
 	"net/http"
 	"os"
-	"path"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -37,6 +37,9 @@ type PackageManager struct {
 
 	// 反向依赖图: A -> [B, C] 表示 B 和 C 依赖 A
 	reverseDependencyGraph map[string][]string
+
+	// 最近一次商店更新检查的结果
+	lastUpdateCheck *PackageUpdateCheckResult
 }
 
 type packageArtifactCandidate struct {
@@ -72,6 +75,12 @@ type PackageUploadPreview struct {
 	ContentCounts   map[string]int     `json:"contentCounts"`
 	ExistingVersion string             `json:"existingVersion,omitempty"`
 	InstallAction   string             `json:"installAction"`
+
+	Signature *sealpack.SignatureInfo `json:"signature"`
+	// SignatureError 非空时表示当前签名策略会拒绝安装此包
+	SignatureError string `json:"signatureError,omitempty"`
+	// InstallPlan 依赖未满足时，安装前需要从商店补齐的依赖
+	InstallPlan *PackageInstallPlan `json:"installPlan,omitempty"`
 }
 
 // NewPackageManager 创建包管理器
@@ -332,6 +341,7 @@ func (pm *PackageManager) materializeCandidate(candidate *packageArtifactCandida
 	state := sealpack.PackageStateDisabled
 	installTime := time.Now()
 	var pendingReload []string
+	var rollback *sealpack.RollbackInfo
 	if persisted != nil {
 		if persisted.State != "" {
 			state = persisted.State
@@ -341,10 +351,16 @@ func (pm *PackageManager) materializeCandidate(candidate *packageArtifactCandida
 		}
 		config = sealpack.MergeConfig(config, persisted.Config)
 		pendingReload = append([]string(nil), persisted.PendingReload...)
+		rollback = persisted.Rollback
 	} else if info, err := os.Stat(candidate.SourcePath); err == nil {
 		installTime = info.ModTime()
 	}
 
+	signature, err := sealpack.VerifyArchiveSignature(candidate.SourcePath, pm.trustedKeys())
+	if err != nil {
+		pm.parent.Logger.Warnf("校验扩展包 %s 签名失败: %v", pkgID, err)
+	}
+
 	return &sealpack.Instance{
 		Manifest:      candidate.Manifest,
 		State:         state,
@@ -354,6 +370,8 @@ func (pm *PackageManager) materializeCandidate(candidate *packageArtifactCandida
 		UserDataPath:  userDataPath,
 		Config:        config,
 		SourceStatus:  sealpack.PackageSourceStatusPresent,
+		Signature:     signature,
+		Rollback:      rollback,
 		PendingReload: pendingReload,
 	}, nil
 }
@@ -370,6 +388,7 @@ func (pm *PackageManager) materializeCacheCandidate(candidate *packageCacheCandi
 	installTime := time.Now()
 	sourcePath := ""
 	var pendingReload []string
+	var rollback *sealpack.RollbackInfo
 	if persisted != nil {
 		if persisted.State != "" {
 			state = persisted.State
@@ -380,6 +399,7 @@ func (pm *PackageManager) materializeCacheCandidate(candidate *packageCacheCandi
 		sourcePath = persisted.SourcePath
 		config = sealpack.MergeConfig(config, persisted.Config)
 		pendingReload = append([]string(nil), persisted.PendingReload...)
+		rollback = persisted.Rollback
 	} else if info, err := os.Stat(candidate.InstallPath); err == nil {
 		installTime = info.ModTime()
 	}
@@ -394,6 +414,7 @@ func (pm *PackageManager) materializeCacheCandidate(candidate *packageCacheCandi
 		Config:        config,
 		SourceStatus:  sealpack.PackageSourceStatusCacheOnly,
 		SourceWarning: packageCacheOnlyWarning(sourcePath),
+		Rollback:      rollback,
 		PendingReload: pendingReload,
 	}, nil
 }
@@ -635,6 +656,7 @@ func (pm *PackageManager) loadState() error {
 			UserDataPath:  userDataPath,
 			Config:        persist.Config,
 			PendingReload: append([]string(nil), persist.PendingReload...),
+			Rollback:      persist.Rollback,
 		}
 		if pm.packages[id].Config == nil {
 			pm.packages[id].Config = make(map[string]interface{})
@@ -660,6 +682,7 @@ func (pm *PackageManager) saveState() error {
 			UserDataPath:  pkg.UserDataPath,
 			Config:        pkg.Config,
 			PendingReload: append([]string(nil), pkg.PendingReload...),
+			Rollback:      pkg.Rollback,
 		}
 	}
 
@@ -671,6 +694,17 @@ func (pm *PackageManager) saveState() error {
 	return os.WriteFile(pm.getStatePath(), data, 0644)
 }
 
+func writePackageConfigToUserData(userDataPath string, config map[string]interface{}) error {
+	if err := os.MkdirAll(userDataPath, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(userDataPath, sealpack.ConfigFile), data, 0644)
+}
+
 func loadPackageConfigFromUserData(userDataPath string) (map[string]interface{}, error) {
 	if userDataPath == "" {
 		return map[string]interface{}{}, nil
@@ -693,33 +727,76 @@ func loadPackageConfigFromUserData(userDataPath string) (map[string]interface{},
 }
 
 // Install 安装扩展包
-// 将 .sealpack 复制到 data/packages/，并解压到 cache/packages/
+// 将 .sealpack 复制到 data/packages/，并解压到 cache/packages/；缺失的依赖会先按安装计划从商店安装。
+// 包本身在安装依赖之前完成检查，之后任一步失败时移除本次新装的依赖
 func (pm *PackageManager) Install(pkgPath string) error {
 	validatedPath, err := pm.validateManagedPackageSource(pkgPath)
 	if err != nil {
 		return err
 	}
-	return pm.installFromSource(validatedPath)
-}
+	pm.lock.RLock()
+	_, _, _, err = pm.checkInstallableLocked(validatedPath)
+	pm.lock.RUnlock()
+	if err != nil {
+		return err
+	}
 
-func (pm *PackageManager) installFromSource(pkgPath string) error {
-	pm.lock.Lock()
-	defer pm.lock.Unlock()
+	added, upgraded, err := pm.installMissingDependencies(validatedPath)
+	if err == nil {
+		err = pm.installFromSource(validatedPath)
+	}
+	if err != nil {
+		return pm.revertDependencies(err, added, upgraded)
+	}
+	return nil
+}
 
+// checkInstallableLocked 检查清单、签名策略、海豹版本与版本号，不检查依赖；调用方需持有锁。
+// 返回包清单、签名状态与已安装的旧版本
+func (pm *PackageManager) checkInstallableLocked(pkgPath string) (*sealpack.Manifest, *sealpack.SignatureInfo, *sealpack.Instance, error) {
 	archiveInfo, err := sealpack.InspectArchive(pkgPath)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
 	manifest := archiveInfo.Manifest
 	pkgID := manifest.Package.ID
 	newVersion, err := semver.NewVersion(manifest.Package.Version)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
+	}
+	signature, err := sealpack.VerifyArchiveSignature(pkgPath, pm.trustedKeys())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if policyErr := pm.checkSignaturePolicy(signature); policyErr != nil {
+		return nil, nil, nil, policyErr
 	}
-
 	if checkErr := sealpack.CheckSealVersion(manifest, VERSION.String()); checkErr != nil {
-		return checkErr
+		return nil, nil, nil, checkErr
+	}
+
+	if _, statErr := os.Stat(pm.getPackageSourcePath(pkgID, manifest.Package.Version)); statErr == nil {
+		return nil, nil, nil, errors.New("the package version is already installed")
+	}
+	existing := pm.packages[pkgID]
+	if existing != nil && existing.Manifest != nil {
+		existingVersion, parseErr := semver.NewVersion(existing.Manifest.Package.Version)
+		if parseErr == nil && !newVersion.GreaterThan(existingVersion) {
+			return nil, nil, nil, errors.New("the same or a newer package version is already installed")
+		}
+	}
+	return manifest, signature, existing, nil
+}
+
+func (pm *PackageManager) installFromSource(pkgPath string) error {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+
+	manifest, signature, existing, err := pm.checkInstallableLocked(pkgPath)
+	if err != nil {
+		return err
 	}
+	pkgID := manifest.Package.ID
 	if satisfied, missing := pm.CheckDependencies(manifest); !satisfied {
 		return &DependencyError{
 			PackageID:   pkgID,
@@ -732,20 +809,6 @@ func (pm *PackageManager) installFromSource(pkgPath string) error {
 		return mkdirErr
 	}
 	destPkgPath := pm.getPackageSourcePath(pkgID, manifest.Package.Version)
-	if _, statErr := os.Stat(destPkgPath); statErr == nil {
-		return errors.New("the package version is already installed")
-	}
-
-	var existing *sealpack.Instance
-	if current, exists := pm.packages[pkgID]; exists {
-		existing = current
-		if current.Manifest != nil {
-			existingVersion, parseErr := semver.NewVersion(current.Manifest.Package.Version)
-			if parseErr == nil && !newVersion.GreaterThan(existingVersion) {
-				return errors.New("the same or a newer package version is already installed")
-			}
-		}
-	}
 
 	stagedSourcePath, err := pm.stageSourceArtifact(pkgPath, destDir)
 	if err != nil {
@@ -776,9 +839,19 @@ func (pm *PackageManager) installFromSource(pkgPath string) error {
 	config := sealpack.InitDefaultConfig(manifest.Config)
 	state := sealpack.PackageStateDisabled
 	pendingReload := []string(nil)
+	var rollback *sealpack.RollbackInfo
 	if existing != nil {
 		state = existing.State
-		config = sealpack.MergeConfig(config, existing.Config)
+		// 先备份旧版本的用户数据，再写入迁移后的配置
+		rollback = pm.prepareRollbackLocked(existing)
+		var oldSchemas map[string]sealpack.ConfigSchema
+		if existing.Manifest != nil {
+			oldSchemas = existing.Manifest.Config
+		}
+		config = sealpack.MigrateConfig(existing.Config, oldSchemas, manifest.Config)
+		if err := writePackageConfigToUserData(userDataPath, config); err != nil {
+			pm.parent.Logger.Warnf("写入扩展包 %s 迁移后的配置失败: %v", pkgID, err)
+		}
 		if state == sealpack.PackageStateEnabled {
 			pendingReload = append(pendingReload, pm.generateReloadHints(manifest).ReloadHints...)
 		}
@@ -797,10 +870,18 @@ func (pm *PackageManager) installFromSource(pkgPath string) error {
 		UserDataPath:  userDataPath,
 		Config:        config,
 		SourceStatus:  sealpack.PackageSourceStatusPresent,
+		Signature:     signature,
+		Rollback:      rollback,
 		PendingReload: pendingReload,
 	}
 
-	if existing != nil && existing.SourcePath != "" && !samePackagePath(existing.SourcePath, destPkgPath) {
+	// 旧版 .sealpack 作为回滚源保留，更早的回滚源不再需要
+	if existing != nil && existing.Rollback != nil && existing.Rollback.SourcePath != "" &&
+		(rollback == nil || !samePackagePath(existing.Rollback.SourcePath, rollback.SourcePath)) &&
+		!samePackagePath(existing.Rollback.SourcePath, destPkgPath) {
+		_ = os.Remove(existing.Rollback.SourcePath)
+	}
+	if existing != nil && existing.SourcePath != "" && rollback == nil && !samePackagePath(existing.SourcePath, destPkgPath) {
 		_ = os.Remove(existing.SourcePath)
 		pm.removeEmptyParents(filepath.Dir(existing.SourcePath), pm.getSourcePackagesPath())
 	}
@@ -942,6 +1023,11 @@ func (pm *PackageManager) previewFromSource(pkgPath string) (*PackageUploadPrevi
 		return nil, err
 	}
 
+	signature, err := sealpack.VerifyArchiveSignature(pkgPath, pm.trustedKeys())
+	if err != nil {
+		return nil, err
+	}
+
 	contentCounts := packageUploadContentCounts(archiveInfo.Files)
 	preview := &PackageUploadPreview{
 		Manifest:      manifest,
@@ -949,18 +1035,93 @@ func (pm *PackageManager) previewFromSource(pkgPath string) (*PackageUploadPrevi
 		FileCount:     len(archiveInfo.Files),
 		ContentCounts: contentCounts,
 		InstallAction: "install",
+		Signature:     signature,
+	}
+	if policyErr := pm.checkSignaturePolicy(signature); policyErr != nil {
+		preview.SignatureError = policyErr.Error()
 	}
 
 	pm.lock.RLock()
-	defer pm.lock.RUnlock()
 	if existing, ok := pm.packages[manifest.Package.ID]; ok && existing != nil && existing.Manifest != nil {
 		preview.ExistingVersion = existing.Manifest.Package.Version
 		preview.InstallAction = "upgrade"
 	}
+	satisfied, _ := pm.CheckDependencies(manifest)
+	pm.lock.RUnlock()
 
+	if !satisfied {
+		preview.InstallPlan = pm.ResolveDependencies(manifest)
+	}
 	return preview, nil
 }
 
+// trustedKeys 返回配置中受信任的签名公钥
+func (pm *PackageManager) trustedKeys() []sealpack.TrustedKey {
+	if pm.parent == nil {
+		return nil
+	}
+	return pm.parent.Config.PackageTrustedKeys
+}
+
+// checkSignaturePolicy 按签名策略判断是否允许安装：签名无效总是拒绝，未签名仅在开启 PackageRequireSigned 时拒绝
+func (pm *PackageManager) checkSignaturePolicy(signature *sealpack.SignatureInfo) error {
+	if signature == nil {
+		return errors.New("无法获取扩展包签名状态")
+	}
+	switch signature.Status {
+	case sealpack.SignatureStatusInvalid:
+		return errors.New("扩展包签名校验失败: " + signature.Message)
+	case sealpack.SignatureStatusUnsigned:
+		if pm.parent != nil && pm.parent.Config.PackageRequireSigned {
+			return errors.New("已开启仅允许安装签名扩展包，拒绝安装未签名的扩展包")
+		}
+	}
+	return nil
+}
+
+// PackageSignatureConfig 扩展包签名策略
+type PackageSignatureConfig struct {
+	RequireSigned bool                    `json:"requireSigned"`
+	TrustedKeys   []PackageTrustedKeyView `json:"trustedKeys"`
+}
+
+// PackageTrustedKeyView 带指纹的受信任公钥，供 UI 展示
+type PackageTrustedKeyView struct {
+	sealpack.TrustedKey
+	Fingerprint string `json:"fingerprint"`
+}
+
+// GetSignatureConfig 获取当前的签名策略
+func (pm *PackageManager) GetSignatureConfig() *PackageSignatureConfig {
+	cfg := &PackageSignatureConfig{
+		RequireSigned: pm.parent.Config.PackageRequireSigned,
+		TrustedKeys:   make([]PackageTrustedKeyView, 0, len(pm.parent.Config.PackageTrustedKeys)),
+	}
+	for _, key := range pm.parent.Config.PackageTrustedKeys {
+		fingerprint, _ := sealpack.KeyFingerprint(key.PublicKey)
+		cfg.TrustedKeys = append(cfg.TrustedKeys, PackageTrustedKeyView{TrustedKey: key, Fingerprint: fingerprint})
+	}
+	return cfg
+}
+
+// SetSignatureConfig 设置签名策略，公钥必须是可解析的 PEM 格式
+func (pm *PackageManager) SetSignatureConfig(requireSigned bool, keys []sealpack.TrustedKey) error {
+	trusted := make([]sealpack.TrustedKey, 0, len(keys))
+	for _, key := range keys {
+		key.Name = strings.TrimSpace(key.Name)
+		key.PublicKey = strings.TrimSpace(key.PublicKey)
+		if _, err := sealpack.KeyFingerprint(key.PublicKey); err != nil {
+			return fmt.Errorf("公钥「%s」无效: %w", key.Name, err)
+		}
+		trusted = append(trusted, key)
+	}
+
+	pm.parent.Config.PackageRequireSigned = requireSigned
+	pm.parent.Config.PackageTrustedKeys = trusted
+	pm.parent.MarkModified()
+	return nil
+}
+
 func packageUploadContentCounts(files []string) map[string]int {
 	counts := map[string]int{
 		"scripts":   0,
@@ -1073,8 +1234,13 @@ func packageVersionOf(pkg *sealpack.Instance) string {
 	return pkg.Manifest.Package.Version
 }
 
-// Uninstall 卸载扩展包
+// Uninstall 卸载扩展包，仍被其他已启用扩展包依赖时拒绝
 func (pm *PackageManager) Uninstall(pkgID string, mode sealpack.UninstallMode) error {
+	return pm.UninstallWithPolicy(pkgID, mode, sealpack.DependentsPolicyRefuse)
+}
+
+// UninstallWithPolicy 卸载扩展包，policy 决定如何处理仍启用的依赖方
+func (pm *PackageManager) UninstallWithPolicy(pkgID string, mode sealpack.UninstallMode, policy sealpack.DependentsPolicy) error {
 	pm.lock.Lock()
 	defer pm.lock.Unlock()
 
@@ -1084,16 +1250,12 @@ func (pm *PackageManager) Uninstall(pkgID string, mode sealpack.UninstallMode) e
 	}
 
 	// 检查反向依赖
-	if deps := pm.reverseDependencyGraph[pkgID]; len(deps) > 0 {
-		enabledDeps := make([]string, 0)
-		for _, depID := range deps {
-			if depPkg, ok := pm.packages[depID]; ok && depPkg.State == sealpack.PackageStateEnabled {
-				enabledDeps = append(enabledDeps, depID)
-			}
-		}
-		if len(enabledDeps) > 0 {
-			return errors.New("以下扩展包依赖此包: " + strings.Join(enabledDeps, ", "))
-		}
+	cascaded, _, err := pm.applyDependentsPolicyLocked(pkgID, policy, "以下扩展包依赖此包")
+	if err != nil {
+		return err
+	}
+	if len(cascaded) > 0 {
+		pm.parent.Logger.Infof("卸载扩展包 %s 时一并禁用了依赖它的扩展包: %s", pkgID, strings.Join(cascaded, ", "))
 	}
 
 	if pkg.State == sealpack.PackageStateEnabled {
@@ -1102,6 +1264,10 @@ func (pm *PackageManager) Uninstall(pkgID string, mode sealpack.UninstallMode) e
 		}
 	}
 
+	if mode != sealpack.UninstallModeDisable {
+		pm.discardRollbackLocked(pkg)
+	}
+
 	switch mode {
 	case sealpack.UninstallModeFull:
 		if err := os.RemoveAll(pkg.InstallPath); err != nil {
@@ -1177,8 +1343,8 @@ func (pm *PackageManager) Enable(pkgID string) (*sealpack.OperationResult, error
 		}
 	}
 
-	// 启用依赖的包
-	for depID := range pkg.Manifest.Dependencies {
+	// 启用依赖的包（含间接依赖，被依赖者优先）
+	for _, depID := range pm.dependencyOrderLocked(pkgID) {
 		if depPkg, ok := pm.packages[depID]; ok && depPkg.State != sealpack.PackageStateEnabled {
 			if _, err := pm.enableInternal(depID); err != nil {
 				return nil, errors.New("启用依赖包 " + depID + " 失败: " + err.Error())
@@ -1227,8 +1393,13 @@ func (pm *PackageManager) enableInternal(pkgID string) (*sealpack.OperationResul
 	return result, nil
 }
 
-// Disable 禁用扩展包
+// Disable 禁用扩展包，仍被其他已启用扩展包依赖时拒绝
 func (pm *PackageManager) Disable(pkgID string) (*sealpack.OperationResult, error) {
+	return pm.DisableWithPolicy(pkgID, sealpack.DependentsPolicyRefuse)
+}
+
+// DisableWithPolicy 禁用扩展包，policy 决定如何处理仍启用的依赖方
+func (pm *PackageManager) DisableWithPolicy(pkgID string, policy sealpack.DependentsPolicy) (*sealpack.OperationResult, error) {
 	pm.lock.Lock()
 	defer pm.lock.Unlock()
 
@@ -1246,22 +1417,24 @@ func (pm *PackageManager) Disable(pkgID string) (*sealpack.OperationResult, erro
 	}
 
 	// 检查反向依赖
-	if deps := pm.reverseDependencyGraph[pkgID]; len(deps) > 0 {
-		enabledDeps := make([]string, 0)
-		for _, depID := range deps {
-			if depPkg, ok := pm.packages[depID]; ok && depPkg.State == sealpack.PackageStateEnabled {
-				enabledDeps = append(enabledDeps, depID)
-			}
-		}
-		if len(enabledDeps) > 0 {
-			return nil, errors.New("以下已启用的扩展包依赖此包: " + strings.Join(enabledDeps, ", "))
-		}
+	cascaded, cascadedHints, err := pm.applyDependentsPolicyLocked(pkgID, policy, "以下已启用的扩展包依赖此包")
+	if err != nil {
+		return nil, err
 	}
 
 	result, err := pm.disableInternal(pkgID)
 	if err != nil {
 		return nil, err
 	}
+	if len(cascaded) > 0 {
+		result.Message += "；同时禁用了依赖它的扩展包: " + strings.Join(cascaded, ", ")
+		for _, hint := range cascadedHints {
+			if !slices.Contains(result.ReloadHints, hint) {
+				result.ReloadHints = append(result.ReloadHints, hint)
+			}
+		}
+		result.ReloadNeeded = len(result.ReloadHints) > 0
+	}
 
 	return result, nil
 }
@@ -1378,16 +1551,7 @@ func (pm *PackageManager) SetConfig(pkgID string, config map[string]interface{})
 	pkg.Config = config
 
 	// 保存到用户数据目录
-	if err := os.MkdirAll(pkg.UserDataPath, 0o755); err != nil {
-		return err
-	}
-	configPath := filepath.Join(pkg.UserDataPath, sealpack.ConfigFile)
-	data, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
+	if err := writePackageConfigToUserData(pkg.UserDataPath, config); err != nil {
 		return err
 	}
 
@@ -1549,34 +1713,7 @@ func matchesPackageContentPatterns(patterns []string, packagePath string) bool {
 }
 
 func matchPackageContentPattern(pattern, packagePath string) bool {
-	patternSegments := strings.Split(filepath.ToSlash(pattern), "/")
-	pathSegments := strings.Split(filepath.ToSlash(packagePath), "/")
-	return matchPackageContentPatternSegments(patternSegments, pathSegments)
-}
-
-func matchPackageContentPatternSegments(patternSegments, pathSegments []string) bool {
-	if len(patternSegments) == 0 {
-		return len(pathSegments) == 0
-	}
-	if patternSegments[0] == "**" {
-		if matchPackageContentPatternSegments(patternSegments[1:], pathSegments) {
-			return true
-		}
-		for idx := range pathSegments {
-			if matchPackageContentPatternSegments(patternSegments[1:], pathSegments[idx+1:]) {
-				return true
-			}
-		}
-		return false
-	}
-	if len(pathSegments) == 0 {
-		return false
-	}
-	matched, err := path.Match(patternSegments[0], pathSegments[0])
-	if err != nil || !matched {
-		return false
-	}
-	return matchPackageContentPatternSegments(patternSegments[1:], pathSegments[1:])
+	return sealpack.MatchContentPattern(pattern, packagePath)
 }
 
 // GetEnabledContentDirs 获取已启用包中包含该资源的目录列表
@@ -1622,6 +1759,48 @@ func (pm *PackageManager) GetSandbox(pkgID string) (*sealpack.Sandbox, error) {
 	return sealpack.NewSandboxFromInstance(pkg), nil
 }
 
+// PackageIDForPath 返回运行时缓存中包含该文件的扩展包 ID，不属于任何包时返回空串
+func (pm *PackageManager) PackageIDForPath(filename string) string {
+	absPath, err := filepath.Abs(filename)
+	if err != nil {
+		return ""
+	}
+
+	pm.lock.RLock()
+	defer pm.lock.RUnlock()
+
+	for pkgID, pkg := range pm.packages {
+		if pkg.InstallPath == "" {
+			continue
+		}
+		installPath, err := filepath.Abs(pkg.InstallPath)
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix(absPath, installPath+string(filepath.Separator)) {
+			return pkgID
+		}
+	}
+	return ""
+}
+
+// RecordPermissionError 记录包脚本的越权访问，写入 ErrText 供管理员查看
+func (pm *PackageManager) RecordPermissionError(permErr *sealpack.PermissionError) {
+	if permErr == nil {
+		return
+	}
+
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+
+	pkg, exists := pm.packages[permErr.PackageID]
+	if !exists {
+		return
+	}
+	pkg.ErrText = permErr.Error()
+	pm.parent.Logger.Warnf("扩展包 %s 越权访问 [%s]: %s", permErr.PackageID, permErr.Permission, permErr.Requested)
+}
+
 // generateReloadHints 根据包的内容生成重载提示
 func (pm *PackageManager) generateReloadHints(manifest *sealpack.Manifest) *sealpack.OperationResult {
 	hints := make([]string, 0)
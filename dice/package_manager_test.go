@@ -409,6 +409,49 @@ func TestPackageManagerPreviewFromStream(t *testing.T) {
 	}
 }
 
+func TestPackageManagerRequireSignedRejectsUnsignedPackage(t *testing.T) {
+	d, pm := newTestPackageManager(t)
+	if err := pm.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	d.Config.PackageRequireSigned = true
+
+	archive := createTestSealPack(t, "", "alice/unsigned", "1.0.0", nil, nil)
+	preview, err := pm.Preview(archive)
+	if err != nil {
+		t.Fatalf("Preview() error = %v", err)
+	}
+	if preview.Signature.Status != sealpack.SignatureStatusUnsigned || preview.SignatureError == "" {
+		t.Fatalf("preview signature = %#v, error = %q, want unsigned and blocked", preview.Signature, preview.SignatureError)
+	}
+	if err := pm.Install(archive); err == nil || !strings.Contains(err.Error(), "未签名") {
+		t.Fatalf("Install() error = %v, want unsigned rejection", err)
+	}
+
+	d.Config.PackageRequireSigned = false
+	if err := pm.Install(archive); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	pkg, _ := pm.Get("alice/unsigned")
+	if pkg.Signature == nil || pkg.Signature.Status != sealpack.SignatureStatusUnsigned {
+		t.Fatalf("installed signature = %#v, want unsigned", pkg.Signature)
+	}
+}
+
+func TestPackageManagerRejectsInvalidSignature(t *testing.T) {
+	_, pm := newTestPackageManager(t)
+	if err := pm.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	archive := createTestSealPack(t, "", "alice/forged", "1.0.0", nil, map[string]string{
+		sealpack.SignatureFile: `{"algorithm":"rsa-pss-sha256","publicKey":"","signature":""}`,
+	})
+	if err := pm.Install(archive); err == nil || !strings.Contains(err.Error(), "签名校验失败") {
+		t.Fatalf("Install() error = %v, want signature rejection", err)
+	}
+}
+
 func TestPackageManagerPreviewFromURL(t *testing.T) {
 	_, pm := newTestPackageManager(t)
 	if err := pm.Init(); err != nil {
@@ -520,8 +563,11 @@ func TestPackageManagerInstallUpgradePreservesConfigAndUserData(t *testing.T) {
 	if _, err := os.Stat(markerPath); err != nil {
 		t.Fatalf("expected userdata marker to remain: %v", err)
 	}
-	if _, err := os.Stat(oldSourcePath); !os.IsNotExist(err) {
-		t.Fatalf("expected old source artifact to be removed, stat err = %v", err)
+	if _, err := os.Stat(oldSourcePath); err != nil {
+		t.Fatalf("expected old source artifact to be kept for rollback: %v", err)
+	}
+	if pkg.Rollback == nil || pkg.Rollback.Version != "1.0.0" {
+		t.Fatalf("Rollback = %+v, want version 1.0.0", pkg.Rollback)
 	}
 	if err := pm.Install(v1); err == nil {
 		t.Fatal("expected lower version install to fail")
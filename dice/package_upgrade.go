@@ -0,0 +1,386 @@
+package dice
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+
+	"sealdice-core/dice/sealpack"
+)
+
+// PackageUpdateInfo 已安装扩展包在商店中的可用更新
+type PackageUpdateInfo struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	CurrentVersion string `json:"currentVersion"`
+	LatestVersion  string `json:"latestVersion"`
+}
+
+// PackageUpdateCheckResult 一次更新检查的结果
+type PackageUpdateCheckResult struct {
+	CheckedAt time.Time            `json:"checkedAt"`
+	Updates   []*PackageUpdateInfo `json:"updates"`
+	Errors    map[string]string    `json:"errors,omitempty"` // 包ID -> 检查失败原因
+}
+
+// getRollbackUserDataPath 获取升级前用户数据的备份目录
+// 路径: data/extensions/<author>/<package>/_rollback/_userdata/
+func (pm *PackageManager) getRollbackUserDataPath(pkgID string) string {
+	return filepath.Join(".", "data", "extensions", sealpack.PackageIDToSafePath(pkgID), "_rollback", sealpack.UserDataDir)
+}
+
+// prepareRollbackLocked 在升级前记录旧版本，备份其用户数据；旧版源文件不可用时返回 nil
+func (pm *PackageManager) prepareRollbackLocked(existing *sealpack.Instance) *sealpack.RollbackInfo {
+	if existing == nil || existing.Manifest == nil || existing.SourcePath == "" {
+		return nil
+	}
+	if _, err := os.Stat(existing.SourcePath); err != nil {
+		return nil
+	}
+	pkgID := existing.Manifest.Package.ID
+
+	backup := pm.getRollbackUserDataPath(pkgID)
+	_ = os.RemoveAll(backup)
+	if info, err := os.Stat(existing.UserDataPath); err == nil && info.IsDir() {
+		if err := os.MkdirAll(filepath.Dir(backup), 0o755); err != nil {
+			pm.parent.Logger.Warnf("备份扩展包 %s 用户数据失败: %v", pkgID, err)
+			backup = ""
+		} else if err := os.CopyFS(backup, os.DirFS(existing.UserDataPath)); err != nil {
+			pm.parent.Logger.Warnf("备份扩展包 %s 用户数据失败: %v", pkgID, err)
+			_ = os.RemoveAll(backup)
+			backup = ""
+		}
+	} else {
+		backup = ""
+	}
+
+	return &sealpack.RollbackInfo{
+		Version:        existing.Manifest.Package.Version,
+		SourcePath:     existing.SourcePath,
+		Config:         maps.Clone(existing.Config),
+		UserDataBackup: backup,
+		UpgradeTime:    time.Now(),
+	}
+}
+
+// discardRollbackLocked 删除保留的旧版源文件与用户数据备份
+func (pm *PackageManager) discardRollbackLocked(pkg *sealpack.Instance) {
+	if pkg == nil || pkg.Rollback == nil {
+		return
+	}
+	rollback := pkg.Rollback
+	pkg.Rollback = nil
+	if rollback.SourcePath != "" && !samePackagePath(rollback.SourcePath, pkg.SourcePath) {
+		_ = os.Remove(rollback.SourcePath)
+		pm.removeEmptyParents(filepath.Dir(rollback.SourcePath), pm.getSourcePackagesPath())
+	}
+	pm.removeRollbackBackup(rollback)
+}
+
+func (pm *PackageManager) removeRollbackBackup(rollback *sealpack.RollbackInfo) {
+	if rollback.UserDataBackup == "" {
+		return
+	}
+	_ = os.RemoveAll(rollback.UserDataBackup)
+	pm.removeEmptyParents(filepath.Dir(rollback.UserDataBackup), filepath.Join(".", "data", "extensions"))
+}
+
+// CheckUpdates 向商店查询所有已安装扩展包的最新版本
+func (pm *PackageManager) CheckUpdates() (*PackageUpdateCheckResult, error) {
+	if pm.parent == nil || pm.parent.StoreManager == nil {
+		return nil, errors.New("商店未初始化")
+	}
+
+	result := &PackageUpdateCheckResult{
+		CheckedAt: time.Now(),
+		Updates:   make([]*PackageUpdateInfo, 0),
+	}
+	for _, pkg := range pm.List() {
+		if pkg.Manifest == nil {
+			continue
+		}
+		pkgID := pkg.Manifest.Package.ID
+		current, err := semver.NewVersion(pkg.Manifest.Package.Version)
+		if err != nil {
+			continue
+		}
+		storePkg, err := pm.resolveStorePackage(pkgID, nil)
+		if err != nil {
+			// 未上架商店的扩展包不视为检查失败
+			if !errors.Is(err, ErrStorePackageNotFound) {
+				if result.Errors == nil {
+					result.Errors = map[string]string{}
+				}
+				result.Errors[pkgID] = err.Error()
+			}
+			continue
+		}
+		latest, err := semver.NewVersion(storePkg.Version)
+		if err != nil || !latest.GreaterThan(current) {
+			continue
+		}
+		result.Updates = append(result.Updates, &PackageUpdateInfo{
+			ID:             pkgID,
+			Name:           pkg.Manifest.Package.Name,
+			CurrentVersion: pkg.Manifest.Package.Version,
+			LatestVersion:  storePkg.Version,
+		})
+	}
+	sort.Slice(result.Updates, func(i, j int) bool {
+		return result.Updates[i].ID < result.Updates[j].ID
+	})
+
+	pm.lock.Lock()
+	pm.lastUpdateCheck = result
+	pm.lock.Unlock()
+	return result, nil
+}
+
+// LastUpdateCheck 返回最近一次更新检查的结果，尚未检查过时返回 nil
+func (pm *PackageManager) LastUpdateCheck() *PackageUpdateCheckResult {
+	pm.lock.RLock()
+	defer pm.lock.RUnlock()
+	return pm.lastUpdateCheck
+}
+
+// checkUpdatesTask 定时任务入口
+func (pm *PackageManager) checkUpdatesTask() {
+	if pm.parent.Config.PackageUpdateCheckDisabled || len(pm.List()) == 0 {
+		return
+	}
+	result, err := pm.CheckUpdates()
+	if err != nil {
+		pm.parent.Logger.Warnf("检查扩展包更新失败: %v", err)
+		return
+	}
+	if len(result.Updates) > 0 {
+		names := make([]string, 0, len(result.Updates))
+		for _, item := range result.Updates {
+			names = append(names, fmt.Sprintf("%s(%s -> %s)", item.ID, item.CurrentVersion, item.LatestVersion))
+		}
+		pm.parent.Logger.Infof("以下扩展包有可用更新: %s", strings.Join(names, ", "))
+	}
+}
+
+// Upgrade 从商店下载并安装扩展包的最新版本，旧版本保留以便回滚。
+// 已启用的扩展包升级后立即重载，新版本加载失败时自动回滚到旧版本
+func (pm *PackageManager) Upgrade(pkgID string) (*sealpack.OperationResult, error) {
+	pkg, exists := pm.Get(pkgID)
+	if !exists {
+		return nil, errors.New("扩展包不存在: " + pkgID)
+	}
+	currentVersion := packageVersionOf(pkg)
+	current, err := semver.NewVersion(currentVersion)
+	if err != nil {
+		return nil, fmt.Errorf("无法解析当前版本号: %w", err)
+	}
+
+	storePkg, err := pm.resolveStorePackage(pkgID, nil)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := semver.NewVersion(storePkg.Version)
+	if err != nil {
+		return nil, fmt.Errorf("商店版本号无效: %w", err)
+	}
+	if !latest.GreaterThan(current) {
+		return nil, fmt.Errorf("扩展包 %s 已是最新版本 %s", pkgID, currentVersion)
+	}
+
+	tempPath, err := pm.prepareDownloadedPackage(storePkg.Download.URL, storePkg.Download.Hash, "package_upgrade_*.sealpack")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tempPath)
+	if err := pm.Install(tempPath); err != nil {
+		return nil, err
+	}
+	if loadErr := pm.loadUpgradedPackage(pkgID); loadErr != nil {
+		pm.parent.Logger.Warnf("扩展包 %s 新版本 %s 加载失败，自动回滚到 %s: %v", pkgID, storePkg.Version, currentVersion, loadErr)
+		if _, rollbackErr := pm.Rollback(pkgID); rollbackErr != nil {
+			return nil, fmt.Errorf("扩展包 %s 新版本 %s 加载失败: %v；自动回滚失败: %w", pkgID, storePkg.Version, loadErr, rollbackErr)
+		}
+		// 重新加载旧版本，恢复升级前的运行状态
+		if pkg, ok := pm.Get(pkgID); ok && pkg.State == sealpack.PackageStateEnabled {
+			if _, err := pm.Reload(pkgID); err != nil {
+				pm.parent.Logger.Warnf("重载回滚后的扩展包 %s 失败: %v", pkgID, err)
+			}
+		}
+		return nil, fmt.Errorf("扩展包 %s 新版本 %s 加载失败，已自动回滚到 %s: %w", pkgID, storePkg.Version, currentVersion, loadErr)
+	}
+
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+	if pm.lastUpdateCheck != nil {
+		updates := make([]*PackageUpdateInfo, 0, len(pm.lastUpdateCheck.Updates))
+		for _, item := range pm.lastUpdateCheck.Updates {
+			if item.ID != pkgID {
+				updates = append(updates, item)
+			}
+		}
+		checked := *pm.lastUpdateCheck
+		checked.Updates = updates
+		pm.lastUpdateCheck = &checked
+	}
+
+	upgraded := pm.packages[pkgID]
+	result := &sealpack.OperationResult{
+		Success: true,
+		Message: fmt.Sprintf("扩展包 %s 已从 %s 升级到 %s", pkgID, currentVersion, storePkg.Version),
+	}
+	if upgraded != nil && upgraded.Rollback != nil {
+		result.Message += fmt.Sprintf("，如新版本无法正常加载可回滚到 %s", upgraded.Rollback.Version)
+	}
+	if upgraded != nil && len(upgraded.PendingReload) > 0 {
+		result.ReloadNeeded = true
+		result.ReloadHints = append([]string(nil), upgraded.PendingReload...)
+	}
+	return result, nil
+}
+
+// loadUpgradedPackage 升级后立即重载已启用的扩展包，返回新版本的加载错误；未启用的扩展包不做检查
+func (pm *PackageManager) loadUpgradedPackage(pkgID string) error {
+	pkg, ok := pm.Get(pkgID)
+	if !ok || pkg.State != sealpack.PackageStateEnabled {
+		return nil
+	}
+	result, err := pm.Reload(pkgID)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	if !result.Success {
+		for _, kind := range []string{"helpdoc", "templates"} {
+			if msg, ok := result.ReloadedItems[kind]; ok && strings.Contains(msg, "失败") {
+				errs = append(errs, msg)
+			}
+		}
+	}
+	for _, info := range pm.parent.JsScriptList {
+		if info.PackageID == pkgID && info.ErrText != "" {
+			errs = append(errs, fmt.Sprintf("脚本 %s: %s", info.Name, info.ErrText))
+		}
+	}
+	if pkg, ok := pm.Get(pkgID); ok && pkg.ErrText != "" {
+		errs = append(errs, pkg.ErrText)
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Rollback 回滚到升级前保留的版本，同时恢复其用户数据与配置
+func (pm *PackageManager) Rollback(pkgID string) (*sealpack.OperationResult, error) {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+
+	pkg, exists := pm.packages[pkgID]
+	if !exists {
+		return nil, errors.New("扩展包不存在: " + pkgID)
+	}
+	rollback := pkg.Rollback
+	if rollback == nil {
+		return nil, fmt.Errorf("扩展包 %s 没有可回滚的版本", pkgID)
+	}
+	if _, err := os.Stat(rollback.SourcePath); err != nil {
+		return nil, fmt.Errorf("旧版本源文件不存在: %s", rollback.SourcePath)
+	}
+	archiveInfo, err := sealpack.InspectArchive(rollback.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	manifest := archiveInfo.Manifest
+	if manifest.Package.ID != pkgID || manifest.Package.Version != rollback.Version {
+		return nil, errors.New("旧版本源文件与回滚记录不一致")
+	}
+
+	// 已启用的依赖方必须仍能接受旧版本
+	var broken []string
+	for _, dependentID := range pm.reverseDependencyGraph[pkgID] {
+		dependent := pm.packages[dependentID]
+		if dependent == nil || dependent.State != sealpack.PackageStateEnabled || dependent.Manifest == nil {
+			continue
+		}
+		constraint := dependent.Manifest.Dependencies[pkgID]
+		if constraint != "" && !versionSatisfiesAll(rollback.Version, []string{constraint}) {
+			broken = append(broken, fmt.Sprintf("%s (需要 %s)", dependentID, constraint))
+		}
+	}
+	if len(broken) > 0 {
+		sort.Strings(broken)
+		return nil, fmt.Errorf("回滚到 %s 后以下扩展包的依赖将不满足: %s", rollback.Version, strings.Join(broken, ", "))
+	}
+
+	signature, err := sealpack.VerifyArchiveSignature(rollback.SourcePath, pm.trustedKeys())
+	if err != nil {
+		return nil, err
+	}
+	stagedCachePath, err := pm.stageExtractPackage(rollback.SourcePath, pkgID)
+	if err != nil {
+		return nil, err
+	}
+	if err := pm.swapInstallDir(stagedCachePath, pm.getPackageInstallPath(pkgID)); err != nil {
+		return nil, err
+	}
+
+	userDataPath := pkg.UserDataPath
+	if userDataPath == "" {
+		userDataPath = pm.getUserDataPath(pkgID)
+	}
+	if rollback.UserDataBackup != "" {
+		if _, statErr := os.Stat(rollback.UserDataBackup); statErr == nil {
+			if err := os.RemoveAll(userDataPath); err != nil {
+				return nil, err
+			}
+			if err := os.CopyFS(userDataPath, os.DirFS(rollback.UserDataBackup)); err != nil {
+				return nil, fmt.Errorf("恢复用户数据失败: %w", err)
+			}
+		}
+	}
+	config := sealpack.MergeConfig(sealpack.InitDefaultConfig(manifest.Config), rollback.Config)
+	if err := writePackageConfigToUserData(userDataPath, config); err != nil {
+		pm.parent.Logger.Warnf("写入扩展包 %s 回滚后的配置失败: %v", pkgID, err)
+	}
+
+	// 删除新版源文件，避免刷新时重新选中更高版本
+	newVersion := packageVersionOf(pkg)
+	if pkg.SourcePath != "" && !samePackagePath(pkg.SourcePath, rollback.SourcePath) {
+		_ = os.Remove(pkg.SourcePath)
+	}
+
+	pkg.Manifest = manifest
+	pkg.SourcePath = rollback.SourcePath
+	pkg.SourceStatus = sealpack.PackageSourceStatusPresent
+	pkg.SourceWarning = ""
+	pkg.UserDataPath = userDataPath
+	pkg.Config = config
+	pkg.Signature = signature
+	pkg.ErrText = ""
+	pkg.Rollback = nil
+	if pkg.State == sealpack.PackageStateEnabled {
+		pm.addPendingReloadHints(pkg, pm.generateReloadHints(manifest).ReloadHints)
+	}
+	pm.removeRollbackBackup(rollback)
+
+	pm.buildDependencyGraph()
+	if err := pm.saveState(); err != nil {
+		pm.parent.Logger.Warnf("failed to save package state: %v", err)
+	}
+
+	pm.parent.Logger.Infof("扩展包 %s 已从 %s 回滚到 %s", pkgID, newVersion, rollback.Version)
+	return &sealpack.OperationResult{
+		Success:      true,
+		Message:      fmt.Sprintf("扩展包 %s 已回滚到 %s", pkgID, rollback.Version),
+		ReloadNeeded: len(pkg.PendingReload) > 0,
+		ReloadHints:  append([]string(nil), pkg.PendingReload...),
+	}, nil
+}
@@ -0,0 +1,156 @@
+package dice //nolint:testpackage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sealdice-core/dice/sealpack"
+)
+
+func withConfigLegacy() manifestOption {
+	return func(b *strings.Builder) {
+		b.WriteString("\n[config.legacy]\n")
+		b.WriteString("type = \"string\"\n")
+		b.WriteString("default = \"old\"\n")
+	}
+}
+
+func TestPackageManagerUpgradeAndRollback(t *testing.T) {
+	d, pm := newTestPackageManager(t)
+	if err := pm.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	const pkgID = "alice/app"
+	if err := pm.Install(createTestSealPack(t, "", pkgID, "1.0.0", nil, nil, withConfigMode(), withConfigLegacy())); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if err := pm.SetConfig(pkgID, map[string]interface{}{"mode": "advanced", "legacy": "kept"}); err != nil {
+		t.Fatalf("SetConfig() error = %v", err)
+	}
+	pkg, _ := pm.Get(pkgID)
+	oldSource := pkg.SourcePath
+	notesPath := filepath.Join(pkg.UserDataPath, "notes.txt")
+	if err := os.WriteFile(notesPath, []byte("v1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	newTestDependencyStore(t, d, []testStorePackage{
+		{id: pkgID, version: "1.1.0", opts: []manifestOption{withConfigMode()}},
+	})
+	check, err := pm.CheckUpdates()
+	if err != nil {
+		t.Fatalf("CheckUpdates() error = %v", err)
+	}
+	if len(check.Updates) != 1 || check.Updates[0].LatestVersion != "1.1.0" {
+		t.Fatalf("expected update to 1.1.0, got %+v", check.Updates)
+	}
+
+	if _, err := pm.Upgrade(pkgID); err != nil {
+		t.Fatalf("Upgrade() error = %v", err)
+	}
+	pkg, _ = pm.Get(pkgID)
+	if packageVersionOf(pkg) != "1.1.0" || pkg.Rollback == nil || pkg.Rollback.Version != "1.0.0" {
+		t.Fatalf("unexpected state after upgrade: version=%s rollback=%+v", packageVersionOf(pkg), pkg.Rollback)
+	}
+	if _, err := os.Stat(oldSource); err != nil {
+		t.Fatalf("previous source should be kept for rollback: %v", err)
+	}
+	if pkg.Config["mode"] != "advanced" {
+		t.Fatalf("existing config value should be migrated, got %v", pkg.Config)
+	}
+	if _, ok := pkg.Config["legacy"]; ok {
+		t.Fatalf("key removed from the new schema should be dropped, got %v", pkg.Config)
+	}
+	if len(pm.LastUpdateCheck().Updates) != 0 {
+		t.Fatal("upgraded package should be removed from the cached update list")
+	}
+	if err := os.WriteFile(notesPath, []byte("v2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	newSource := pkg.SourcePath
+
+	if _, err := pm.Rollback(pkgID); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	pkg, _ = pm.Get(pkgID)
+	if packageVersionOf(pkg) != "1.0.0" || pkg.Rollback != nil {
+		t.Fatalf("unexpected state after rollback: version=%s", packageVersionOf(pkg))
+	}
+	if pkg.Config["legacy"] != "kept" || pkg.Config["mode"] != "advanced" {
+		t.Fatalf("config should be restored, got %v", pkg.Config)
+	}
+	if data, _ := os.ReadFile(notesPath); string(data) != "v1" {
+		t.Fatalf("user data should be restored, got %q", data)
+	}
+	if _, err := os.Stat(newSource); !os.IsNotExist(err) {
+		t.Fatal("newer source should be removed after rollback")
+	}
+
+	if _, err := pm.RefreshFromDisk(); err != nil {
+		t.Fatalf("RefreshFromDisk() error = %v", err)
+	}
+	if pkg, _ := pm.Get(pkgID); packageVersionOf(pkg) != "1.0.0" {
+		t.Fatalf("refresh should keep the rolled back version, got %s", packageVersionOf(pkg))
+	}
+	if _, err := pm.Rollback(pkgID); err == nil {
+		t.Fatal("second rollback should fail without a preserved version")
+	}
+	if err := pm.Uninstall(pkgID, sealpack.UninstallModeFull); err != nil {
+		t.Fatalf("Uninstall() error = %v", err)
+	}
+}
+
+func TestPackageManagerUpgradeRollsBackWhenLoadFails(t *testing.T) {
+	d, pm := newTestPackageManager(t)
+	d.Config.JsEnable = true
+	d.ImSession = &IMSession{
+		ServiceAtNew: new(SyncMap[string, *GroupInfo]),
+		EndPoints:    []*EndPointInfo{},
+	}
+	d.DirtyGroups = new(SyncMap[string, int64])
+	d.AttrsManager = &AttrsManager{}
+	d.ExtRegistry = new(SyncMap[string, *ExtInfo])
+	d.ConfigManager = NewConfigManager(filepath.Join(t.TempDir(), "plugin-configs.json"))
+	defer func() {
+		if d.JsScriptCron != nil {
+			d.JsScriptCron.Stop()
+		}
+		if d.ExtLoopManager != nil {
+			d.ExtLoopManager.SetLoop(nil)
+		}
+	}()
+	if err := pm.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	const pkgID = "alice/app"
+	scripts := map[string][]string{"scripts": {"scripts/*.js"}}
+	archive := createTestSealPack(t, "", pkgID, "1.0.0", scripts,
+		map[string]string{"scripts/main.js": "seal.ext.register(seal.ext.new('upgrade-app', 'alice', '1.0.0'));"})
+	if err := pm.Install(archive); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if _, err := pm.Enable(pkgID); err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+	if _, err := pm.Reload(pkgID); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	newTestDependencyStore(t, d, []testStorePackage{
+		{id: pkgID, version: "1.1.0", contents: scripts, files: map[string]string{"scripts/main.js": "throw new Error('broken');"}},
+	})
+	if _, err := pm.Upgrade(pkgID); err == nil || !strings.Contains(err.Error(), "已自动回滚到 1.0.0") {
+		t.Fatalf("Upgrade() error = %v, want automatic rollback", err)
+	}
+
+	pkg, _ := pm.Get(pkgID)
+	if packageVersionOf(pkg) != "1.0.0" || pkg.Rollback != nil || pkg.State != sealpack.PackageStateEnabled {
+		t.Fatalf("unexpected state after automatic rollback: version=%s state=%s", packageVersionOf(pkg), pkg.State)
+	}
+	if d.ExtFind("upgrade-app", false) == nil {
+		t.Fatal("previous version should be loaded again after rollback")
+	}
+}
@@ -1,7 +1,6 @@
 package dice
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
@@ -23,23 +22,6 @@ type sealChatCharacterTarget struct {
 	Template         *GameSystemTemplate
 }
 
-type sealChatCharacterOutputEntry struct {
-	Value    any
-	Priority int
-}
-
-type sealChatCharacterAttrSnapshot struct {
-	Key   string
-	Value *ds.VMValue
-}
-
-type sealChatCharacterAttrCandidate struct {
-	RawKey string
-	Value  *ds.VMValue
-}
-
-const sealChatCharacterValueMaxDepth = 32
-
 func ensureSealChatCharacterAttrs(target *sealChatCharacterTarget) error {
 	if target == nil {
 		return errors.New("missing character target")
@@ -98,227 +80,6 @@ func (pa *PlatformAdapterSealChat) resolveSealChatCharacterTarget(d *Dice, group
 	}, nil
 }
 
-func sealChatLoadAliasMapValue(aliasMap *SyncMap[string, string], key string) (canonical string, ok bool) {
-	if aliasMap == nil {
-		return "", false
-	}
-
-	defer func() {
-		if recover() != nil {
-			canonical = ""
-			ok = false
-		}
-	}()
-
-	rawValue, exists := aliasMap.m.Load(key)
-	if !exists {
-		return "", false
-	}
-
-	canonical, ok = rawValue.(string)
-	return canonical, ok
-}
-
-func sealChatLookupCanonicalAttrKey(tmpl *GameSystemTemplate, key string) (string, bool) {
-	if tmpl == nil || tmpl.AliasMap == nil {
-		return key, false
-	}
-
-	normalized := strings.ToLower(strings.TrimSpace(key))
-	if normalized == "" {
-		return key, false
-	}
-	if value, ok := sealChatLoadAliasMapValue(tmpl.AliasMap, normalized); ok {
-		return value, true
-	}
-
-	normalized = chsS2T.Read(normalized)
-	if value, ok := sealChatLoadAliasMapValue(tmpl.AliasMap, normalized); ok {
-		return value, true
-	}
-	return key, false
-}
-
-func splitSealChatDisplayLabelKey(key string) (string, string, bool) {
-	for _, pair := range [][2]string{{"（", "）"}, {"(", ")"}} {
-		openIdx := strings.Index(key, pair[0])
-		if openIdx <= 0 {
-			continue
-		}
-		closeIdx := strings.LastIndex(key, pair[1])
-		if closeIdx <= openIdx+len(pair[0]) || closeIdx != len(key)-len(pair[1]) {
-			continue
-		}
-
-		left := strings.TrimSpace(key[:openIdx])
-		right := strings.TrimSpace(key[openIdx+len(pair[0]) : closeIdx])
-		if left == "" || right == "" {
-			continue
-		}
-		return left, right, true
-	}
-
-	return "", "", false
-}
-
-func resolveSealChatCharacterAttrKey(key string, tmpl *GameSystemTemplate) (string, error) {
-	trimmed := strings.TrimSpace(key)
-	if trimmed == "" || tmpl == nil {
-		return trimmed, nil
-	}
-
-	if canonical, ok := sealChatLookupCanonicalAttrKey(tmpl, trimmed); ok {
-		return canonical, nil
-	}
-
-	left, right, ok := splitSealChatDisplayLabelKey(trimmed)
-	if !ok {
-		return trimmed, nil
-	}
-
-	leftCanonical, leftOK := sealChatLookupCanonicalAttrKey(tmpl, left)
-	rightCanonical, rightOK := sealChatLookupCanonicalAttrKey(tmpl, right)
-
-	switch {
-	case leftOK && rightOK && leftCanonical == rightCanonical:
-		return leftCanonical, nil
-	case rightOK && !leftOK:
-		return rightCanonical, nil
-	case leftOK && !rightOK:
-		return leftCanonical, nil
-	case leftOK && rightOK && leftCanonical != rightCanonical:
-		return "", fmt.Errorf("ambiguous attribute display label %q", trimmed)
-	default:
-		return trimmed, nil
-	}
-}
-
-func findSealChatExistingCanonicalAttrValue(attrs *AttributesItem, canonicalKey string, tmpl *GameSystemTemplate) (*ds.VMValue, bool) {
-	if attrs == nil {
-		return nil, false
-	}
-	if value, exists := attrs.LoadX(canonicalKey); exists {
-		return value, true
-	}
-
-	var matchedValue *ds.VMValue
-	matched := false
-	consistent := true
-	attrs.Range(func(key string, value *ds.VMValue) bool {
-		resolvedKey, err := resolveSealChatCharacterAttrKey(key, tmpl)
-		if err != nil || resolvedKey != canonicalKey || key == canonicalKey {
-			return true
-		}
-		if !matched {
-			matchedValue = value
-			matched = true
-			return true
-		}
-		if !ds.ValueEqual(matchedValue, value, false) {
-			consistent = false
-			return false
-		}
-		return true
-	})
-
-	if matched && consistent {
-		return matchedValue, true
-	}
-	return nil, false
-}
-
-func normalizeSealChatCharacterAttrs(attrsData map[string]any, tmpl *GameSystemTemplate, existingAttrs *AttributesItem) (map[string]*ds.VMValue, error) {
-	grouped := make(map[string][]sealChatCharacterAttrCandidate, len(attrsData))
-	for key, rawValue := range attrsData {
-		if strings.TrimSpace(key) == "" {
-			return nil, fmt.Errorf("invalid attribute key %q", key)
-		}
-
-		canonicalKey, err := resolveSealChatCharacterAttrKey(key, tmpl)
-		if err != nil {
-			return nil, err
-		}
-		if canonicalKey == "" {
-			return nil, fmt.Errorf("invalid attribute key %q", key)
-		}
-
-		value, err := anyToVMValue(rawValue)
-		if err != nil {
-			return nil, fmt.Errorf("invalid value for attribute %q: %w", key, err)
-		}
-
-		grouped[canonicalKey] = append(grouped[canonicalKey], sealChatCharacterAttrCandidate{
-			RawKey: key,
-			Value:  value,
-		})
-	}
-
-	normalized := make(map[string]*ds.VMValue, len(grouped))
-	for canonicalKey, candidates := range grouped {
-		uniqueCandidates := make([]sealChatCharacterAttrCandidate, 0, len(candidates))
-		for _, candidate := range candidates {
-			duplicated := false
-			for _, existing := range uniqueCandidates {
-				if ds.ValueEqual(existing.Value, candidate.Value, false) {
-					duplicated = true
-					break
-				}
-			}
-			if !duplicated {
-				uniqueCandidates = append(uniqueCandidates, candidate)
-			}
-		}
-
-		if len(uniqueCandidates) == 1 {
-			normalized[canonicalKey] = uniqueCandidates[0].Value
-			continue
-		}
-
-		if existingValue, exists := findSealChatExistingCanonicalAttrValue(existingAttrs, canonicalKey, tmpl); exists {
-			changedCandidates := make([]sealChatCharacterAttrCandidate, 0, len(uniqueCandidates))
-			for _, candidate := range uniqueCandidates {
-				if ds.ValueEqual(existingValue, candidate.Value, false) {
-					continue
-				}
-				changedCandidates = append(changedCandidates, candidate)
-			}
-			if len(changedCandidates) == 1 {
-				normalized[canonicalKey] = changedCandidates[0].Value
-				continue
-			}
-			if len(changedCandidates) == 0 {
-				normalized[canonicalKey] = uniqueCandidates[0].Value
-				continue
-			}
-		}
-
-		return nil, fmt.Errorf("conflicting values for attribute %q", canonicalKey)
-	}
-	return normalized, nil
-}
-
-func cleanupSealChatCharacterAliasKeys(attrs *AttributesItem, tmpl *GameSystemTemplate, normalizedAttrs map[string]*ds.VMValue) {
-	if attrs == nil || tmpl == nil || len(normalizedAttrs) == 0 {
-		return
-	}
-
-	toDelete := make([]string, 0)
-	attrs.Range(func(key string, value *ds.VMValue) bool {
-		canonicalKey, err := resolveSealChatCharacterAttrKey(key, tmpl)
-		if err != nil || canonicalKey == "" || canonicalKey == key {
-			return true
-		}
-		if _, exists := normalizedAttrs[canonicalKey]; exists {
-			toDelete = append(toDelete, key)
-		}
-		return true
-	})
-
-	for _, key := range toDelete {
-		attrs.Delete(key)
-	}
-}
-
 func (pa *PlatformAdapterSealChat) setSealChatCharacterAttrs(d *Dice, groupID string, userID string, attrsData map[string]any) (*sealChatCharacterTarget, error) {
 	target, err := pa.resolveSealChatCharacterTarget(d, groupID, userID)
 	if err != nil {
@@ -328,39 +89,15 @@ func (pa *PlatformAdapterSealChat) setSealChatCharacterAttrs(d *Dice, groupID st
 		return nil, ensureErr
 	}
 
-	normalizedAttrs, err := normalizeSealChatCharacterAttrs(attrsData, target.Template, target.Attrs)
-	if err != nil {
+	if err = applySheetAttrs(target.Attrs, target.Template, attrsData); err != nil {
 		return nil, err
 	}
-
-	for key, value := range normalizedAttrs {
-		target.Attrs.Store(key, value)
-	}
-	cleanupSealChatCharacterAliasKeys(target.Attrs, target.Template, normalizedAttrs)
+	// 不经过指令执行，在这里记下修改历史
+	d.AttrsManager.RecordHistory(userID, "", "", target.Attrs)
 
 	return target, nil
 }
 
-func snapshotSealChatCharacterAttrs(attrs *AttributesItem, tmpl *GameSystemTemplate) ([]sealChatCharacterAttrSnapshot, map[string]struct{}) {
-	if attrs == nil {
-		return nil, nil
-	}
-
-	snapshots := make([]sealChatCharacterAttrSnapshot, 0)
-	canonicalKeys := make(map[string]struct{})
-	attrs.Range(func(key string, value *ds.VMValue) bool {
-		snapshots = append(snapshots, sealChatCharacterAttrSnapshot{
-			Key:   key,
-			Value: value,
-		})
-		if canonicalKey, err := resolveSealChatCharacterAttrKey(key, tmpl); err == nil && canonicalKey == key && canonicalKey != "" {
-			canonicalKeys[canonicalKey] = struct{}{}
-		}
-		return true
-	})
-	return snapshots, canonicalKeys
-}
-
 func buildSealChatCharacterOutputAttrs(target *sealChatCharacterTarget) map[string]any {
 	attrsData := make(map[string]any)
 	if target == nil || target.Attrs == nil {
@@ -371,45 +108,8 @@ func buildSealChatCharacterOutputAttrs(target *sealChatCharacterTarget) map[stri
 		target.Ctx.syncAttrsForTemplate(target.Attrs, target.Template.GameSystemTemplateV2)
 	}
 
-	snapshots, canonicalKeys := snapshotSealChatCharacterAttrs(target.Attrs, target.Template)
-
-	entries := make(map[string]sealChatCharacterOutputEntry)
-	for _, snapshot := range snapshots {
-		key := snapshot.Key
-		value := snapshot.Value
-		outputKey := key
-		priority := 0
-
-		if canonicalKey, err := resolveSealChatCharacterAttrKey(key, target.Template); err == nil && canonicalKey != "" {
-			if canonicalKey == key {
-				outputKey = canonicalKey
-				priority = 2
-			} else if _, exists := canonicalKeys[canonicalKey]; exists {
-				outputKey = canonicalKey
-				priority = 1
-			}
-		}
-
-		if existing, exists := entries[outputKey]; exists {
-			if existing.Priority > priority {
-				continue
-			}
-			if existing.Priority == priority && existing.Value != nil && value != nil {
-				existingValue, err := anyToVMValue(existing.Value)
-				if err == nil && ds.ValueEqual(existingValue, value, false) {
-					continue
-				}
-			}
-		}
-
-		entries[outputKey] = sealChatCharacterOutputEntry{
-			Value:    vmValueToAny(value),
-			Priority: priority,
-		}
-	}
-
-	for key, entry := range entries {
-		attrsData[key] = entry.Value
+	for key, value := range canonicalSheetAttrs(target.Attrs, target.Template) {
+		attrsData[key] = vmValueToAny(value)
 	}
 	return attrsData
 }
@@ -446,194 +146,6 @@ func (pa *PlatformAdapterSealChat) handleCharacterGet(msg satori.ScApiMsgPayload
 	})
 }
 
-// vmValueToAny 将 VMValue 转换为可 JSON 序列化的值
-func vmValueToAny(v *ds.VMValue) any {
-	if v == nil {
-		return nil
-	}
-
-	value, err := vmValueToAnyDepth(v, 0)
-	if err != nil {
-		return v.ToString()
-	}
-	return value
-}
-
-func vmValueToAnyDepth(v *ds.VMValue, depth int) (any, error) {
-	if depth >= sealChatCharacterValueMaxDepth {
-		return nil, fmt.Errorf("attribute value exceeds max nesting depth %d", sealChatCharacterValueMaxDepth)
-	}
-
-	switch v.TypeId {
-	case ds.VMTypeInt:
-		return v.MustReadInt(), nil
-	case ds.VMTypeFloat:
-		return v.MustReadFloat(), nil
-	case ds.VMTypeString:
-		s, _ := v.ReadString()
-		return s, nil
-	case ds.VMTypeArray:
-		arrayData := v.MustReadArray()
-		result := make([]any, 0, len(arrayData.List))
-		for _, item := range arrayData.List {
-			if item == nil {
-				result = append(result, nil)
-				continue
-			}
-			converted, err := vmValueToAnyDepth(item, depth+1)
-			if err != nil {
-				return nil, err
-			}
-			result = append(result, converted)
-		}
-		return result, nil
-	case ds.VMTypeDict:
-		dictData := v.MustReadDictData()
-		result := make(map[string]any)
-		dictData.Dict.Range(func(key string, value *ds.VMValue) bool {
-			if value == nil {
-				result[key] = nil
-				return true
-			}
-			converted, err := vmValueToAnyDepth(value, depth+1)
-			if err != nil {
-				result = nil
-				return false
-			}
-			result[key] = converted
-			return true
-		})
-		if result == nil {
-			return nil, fmt.Errorf("attribute value exceeds max nesting depth %d", sealChatCharacterValueMaxDepth)
-		}
-		return result, nil
-	default:
-		// 对于复杂类型，尝试转换为字符串
-		return v.ToString(), nil
-	}
-}
-
-func parseSealChatComputedString(value string) (*ds.VMValue, bool, error) {
-	trimmed := strings.TrimSpace(value)
-	if !strings.HasPrefix(trimmed, "&(") || !strings.HasSuffix(trimmed, ")") {
-		return nil, false, nil
-	}
-
-	expr := strings.TrimSpace(trimmed[2 : len(trimmed)-1])
-	if expr == "" {
-		return nil, true, errors.New("empty computed expression")
-	}
-	return ds.NewComputedVal(expr), true, nil
-}
-
-func sealChatTypedEnvelopeType(v any) (ds.VMValueType, bool) {
-	switch val := v.(type) {
-	case float64:
-		if val != float64(int64(val)) {
-			return 0, false
-		}
-		return ds.VMValueType(int64(val)), true
-	case int:
-		return ds.VMValueType(val), true
-	case int64:
-		return ds.VMValueType(val), true
-	default:
-		return 0, false
-	}
-}
-
-func parseSealChatTypedVMValue(v any) (*ds.VMValue, bool, error) {
-	rawMap, ok := v.(map[string]any)
-	if !ok {
-		return nil, false, nil
-	}
-	rawType, exists := rawMap["t"]
-	if !exists {
-		return nil, false, nil
-	}
-	typeID, ok := sealChatTypedEnvelopeType(rawType)
-	if !ok || typeID != ds.VMTypeComputedValue {
-		return nil, false, nil
-	}
-	if _, exists := rawMap["v"]; !exists {
-		return nil, true, errors.New("missing computed value payload")
-	}
-
-	data, err := json.Marshal(rawMap)
-	if err != nil {
-		return nil, true, err
-	}
-	value, err := ds.VMValueFromJSON(data)
-	if err != nil {
-		return nil, true, err
-	}
-	computed, ok := value.ReadComputed()
-	if !ok || strings.TrimSpace(computed.Expr) == "" {
-		return nil, true, errors.New("empty computed expression")
-	}
-	return value, true, nil
-}
-
-// anyToVMValue 将 any 类型转换为 VMValue
-func anyToVMValue(v any) (*ds.VMValue, error) {
-	return anyToVMValueDepth(v, 0)
-}
-
-func anyToVMValueDepth(v any, depth int) (*ds.VMValue, error) {
-	if depth >= sealChatCharacterValueMaxDepth {
-		return nil, fmt.Errorf("attribute value exceeds max nesting depth %d", sealChatCharacterValueMaxDepth)
-	}
-
-	if typedValue, matched, err := parseSealChatTypedVMValue(v); matched || err != nil {
-		return typedValue, err
-	}
-
-	switch val := v.(type) {
-	case float64:
-		// JSON 解析时数字默认为 float64
-		if val == float64(int64(val)) {
-			return ds.NewIntVal(ds.IntType(val)), nil
-		}
-		return ds.NewFloatVal(val), nil
-	case int:
-		return ds.NewIntVal(ds.IntType(val)), nil
-	case int64:
-		return ds.NewIntVal(ds.IntType(val)), nil
-	case string:
-		if computedValue, matched, err := parseSealChatComputedString(val); matched || err != nil {
-			return computedValue, err
-		}
-		return ds.NewStrVal(val), nil
-	case []any:
-		items := make([]*ds.VMValue, 0, len(val))
-		for _, item := range val {
-			converted, err := anyToVMValueDepth(item, depth+1)
-			if err != nil {
-				return nil, err
-			}
-			items = append(items, converted)
-		}
-		return ds.NewArrayValRaw(items), nil
-	case map[string]any:
-		dict := &ds.ValueMap{}
-		for key, item := range val {
-			converted, err := anyToVMValueDepth(item, depth+1)
-			if err != nil {
-				return nil, err
-			}
-			dict.Store(key, converted)
-		}
-		return ds.NewDictVal(dict).V(), nil
-	case bool:
-		if val {
-			return ds.NewIntVal(1), nil
-		}
-		return ds.NewIntVal(0), nil
-	default:
-		return ds.NewStrVal(fmt.Sprintf("%v", v)), nil
-	}
-}
-
 // handleCharacterSet 处理写入角色卡请求
 // 安全限制：仅允许通过此API写入SealChat平台的角色卡
 func (pa *PlatformAdapterSealChat) handleCharacterSet(msg satori.ScApiMsgPayload, d *Dice) {
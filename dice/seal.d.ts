@@ -0,0 +1,594 @@
+// Code generated by go generate in sealdice-core/dice; DO NOT EDIT.
+// 由 seal 对象的 Go 绑定与 jsbind 标签生成，请勿手动修改。
+
+declare namespace seal {
+  interface ArchiveInfo {
+  }
+
+  interface AtInfo {
+    userId: string;
+    copyCtx(ctx: seal.MsgContext): [seal.MsgContext, boolean];
+  }
+
+  interface AttributesBatchUpsertModel {
+  }
+
+  interface AttributesItem {
+    clear(): number;
+    "delete"(arg0: string): void;
+    getBatchSaveModel(): seal.AttributesBatchUpsertModel;
+    len(): number;
+    load(arg0: string): any;
+    loadX(arg0: string): [any, boolean];
+    range(arg0: ((arg0: string, arg1: any) => boolean)): void;
+    saveToDB(arg0: any): void;
+    setModified(): void;
+    setSheetType(arg0: string): void;
+    store(arg0: string, arg1: any): void;
+    toArrayItems(): any[];
+    toArrayKeys(): any[];
+    toArrayValues(): any[];
+  }
+
+  interface BanListInfoItem {
+    id: string;
+    name: string;
+    score: number;
+    rank: number;
+    times: number[];
+    reasons: string[];
+    places: string[];
+    banTime: number;
+  }
+
+  interface CmdArgs {
+    command: string;
+    args: string[];
+    kwargs: seal.Kwarg[];
+    at: seal.AtInfo[];
+    atSheets: string[];
+    rawArgs: string;
+    amIBeMentioned: boolean;
+    amIBeMentionedFirst: boolean;
+    cleanArgs: string;
+    specialExecuteTimes: number;
+    rawText: string;
+    chopPrefixToArgsWith(...arg0: string[]): boolean;
+    eatPrefixWith(...arg0: string[]): [string, boolean];
+    getArgN(arg0: number): string;
+    getKwarg(arg0: string): seal.Kwarg;
+    getRestArgsFrom(arg0: number): string;
+    isArgEqual(arg0: number, ...arg1: string[]): boolean;
+    revokeAtSheets(): void;
+    revokeExecuteTimesParse(ctx: seal.MsgContext, msg: seal.Message): void;
+    setupAtInfo(arg0: string): void;
+  }
+
+  interface CmdExecuteResult {
+    solved: boolean;
+    showHelp: boolean;
+  }
+
+  interface CmdItemInfo {
+    name: string;
+    help: string;
+    helpFunc: ((arg0: boolean) => string);
+    allowDelegate: boolean;
+    disabledInPrivate: boolean;
+    enableExecuteTimesParse: boolean;
+    solve: ((ctx: seal.MsgContext, msg: seal.Message, cmdArgs: seal.CmdArgs) => seal.CmdExecuteResult);
+    raw: boolean;
+    checkCurrentBotOn: boolean;
+    checkMentionOthers: boolean;
+  }
+
+  interface CocRuleCheckRet {
+    successRank: number;
+    criticalSuccessValue: number;
+  }
+
+  interface CocRuleInfo {
+    index: number;
+    key: string;
+    name: string;
+    desc: string;
+    check: ((ctx: seal.MsgContext, arg1: number, arg2: number, arg3: number) => seal.CocRuleCheckRet);
+  }
+
+  interface ConfigItem {
+    key: string;
+    type: string;
+    group: string;
+    defaultValue: any;
+    value: any;
+    option: any;
+    deprecated: boolean;
+    description: string;
+    schema: seal.ConfigSchema;
+    masked(): seal.ConfigItem;
+    unmarshalJSON(arg0: number[]): void;
+  }
+
+  interface ConfigSchema {
+  }
+
+  interface DeckInfo {
+  }
+
+  interface Dice {
+    imSession: seal.IMSession;
+    deckList: seal.DeckInfo[];
+    commandPrefix: string[];
+    diceMasters: string[];
+    applyAliveNotice(): void;
+    applyExtDefaultSettings(): void;
+    applyJsScriptWatch(): void;
+    canSendMail(): boolean;
+    censorMsg(ctx: seal.MsgContext, msg: seal.Message, arg2: string, arg3: string): [boolean, string[], boolean, string];
+    cocExtraRulesAdd(arg0: seal.CocRuleInfo): boolean;
+    deckCheckUpdate(arg0: seal.DeckInfo): [string, string, string];
+    deckDownload(arg0: string, arg1: string, arg2: string, arg3: Record<string, string>): void;
+    deckUpdate(arg0: seal.DeckInfo, arg1: string): void;
+    extAliasToName(arg0: string): string;
+    extFind(arg0: string, arg1: boolean): seal.ExtInfo;
+    extFindAllByName(arg0: string): seal.ExtInfo[];
+    extFindByFullName(arg0: string, arg1: string): seal.ExtInfo;
+    extRemove(ext: seal.ExtInfo): boolean;
+    gameSystemTemplateAdd(arg0: seal.GameSystemTemplate): boolean;
+    gameSystemTemplateAddEx(arg0: seal.GameSystemTemplate, arg1: boolean): boolean;
+    gameSystemTemplateReload(arg0: string): void;
+    gameSystemTemplateReloadFiles(arg0: string[]): void;
+    generateTextMap(): void;
+    getBanList(): seal.BanListInfoItem[];
+    getDiceDataPath(arg0: string): string;
+    getExtConfigFilePath(arg0: string, arg1: string): string;
+    getExtDataDir(arg0: string): string;
+    init(arg0: any, arg1: seal.UIWriter): void;
+    isMaster(arg0: string): boolean;
+    jsCheckUpdate(arg0: seal.JsScriptInfo): [string, string, string];
+    jsDownload(arg0: string, arg1: string, arg2: Record<string, string>): void;
+    jsExtSettingVacuum(): void;
+    jsExtStatsList(): seal.JsExtStats[];
+    jsGenerateDts(): string;
+    jsInit(): void;
+    jsLoadScriptRaw(arg0: seal.JsScriptInfo): void;
+    jsLoadScripts(): void;
+    jsParseMeta(arg0: string, arg1: any, arg2: number[], arg3: boolean): seal.JsScriptInfo;
+    jsReload(): void;
+    jsReloadScript(arg0: string): seal.JsScriptInfo;
+    jsShutdown(): void;
+    jsTrustedSource(arg0: string): string;
+    jsUnloadScript(arg0: string): void;
+    jsUpdate(arg0: seal.JsScriptInfo, arg1: string): void;
+    logArchiveDir(): string;
+    logMediaCleanup(): void;
+    logRetentionRun(): seal.ArchiveInfo[];
+    logSyncGroupState(arg0: string, arg1: string, arg2: seal.LogChange): void;
+    markModified(): void;
+    masterAdd(arg0: string): void;
+    masterCheck(arg0: string, arg1: string): boolean;
+    masterRefresh(): void;
+    masterRemove(arg0: string): boolean;
+    newCensorManager(): void;
+    noticeForEveryEndpoint(arg0: string, arg1: boolean): void;
+    packageSetup(): void;
+    publicDiceEndpointRefresh(): void;
+    publicDiceInfoRegister(): void;
+    publicDiceSetup(): void;
+    publicDiceSetupTick(): void;
+    registerBuiltinExt(): void;
+    registerBuiltinSystemTemplate(): void;
+    registerExtension(ext: seal.ExtInfo): void;
+    resetLogRetentionCron(): void;
+    resetQuitInactiveCron(): void;
+    save(arg0: boolean): void;
+    saveText(): void;
+    sendMail(arg0: string, arg1: number): void;
+    sendMailRow(arg0: string, arg1: string[], arg2: string, arg3: string[]): void;
+    setLogMedia(arg0: seal.MediaConfig): void;
+    setLogRetention(arg0: seal.LogRetentionConfig): void;
+    sheetApplyDocument(arg0: seal.AttributesItem, arg1: seal.SheetDocument): void;
+    sheetDocumentFromAttrs(arg0: seal.AttributesItem): seal.SheetDocument;
+    sheetExport(arg0: seal.SheetDocument, arg1: string): [number[], string];
+    sheetImport(arg0: number[], arg1: string, arg2: string, arg3: string): seal.SheetDocument;
+    storeSetup(): void;
+    unlockCodeUpdate(arg0: boolean): void;
+    unlockCodeVerify(arg0: string): boolean;
+  }
+
+  interface EndPointInfo {
+    baseInfo: seal.EndPointInfoBase;
+    adapterSetup(): void;
+    refreshGroupNum(): void;
+    setEnable(arg0: seal.Dice, arg1: boolean): void;
+    statsDump(arg0: seal.Dice): void;
+    statsRestore(arg0: seal.Dice): void;
+    triggerCommand(ctx: seal.MsgContext, msg: seal.Message, cmdArgs: seal.CmdArgs): boolean;
+    unmarshalYAML(arg0: any): void;
+  }
+
+  interface EndPointInfoBase {
+    id: string;
+    nickname: string;
+    state: number;
+    userId: string;
+    groupNum: number;
+    cmdExecutedNum: number;
+    cmdExecutedLastTime: number;
+    onlineTotalTime: number;
+    platform: string;
+    enable: boolean;
+  }
+
+  interface ExtInfo {
+    name: string;
+    aliases: string[];
+    version: string;
+    autoActive: boolean;
+    cmdMap: Record<string, seal.CmdItemInfo>;
+    author: string;
+    activeWith: string[];
+    onNotCommandReceived: ((ctx: seal.MsgContext, msg: seal.Message) => void);
+    onCommandReceived: ((ctx: seal.MsgContext, msg: seal.Message, cmdArgs: seal.CmdArgs) => void);
+    onMessageReceived: ((ctx: seal.MsgContext, msg: seal.Message) => void);
+    onMessageSend: ((ctx: seal.MsgContext, msg: seal.Message, arg2: string) => void);
+    onMessageDeleted: ((ctx: seal.MsgContext, msg: seal.Message) => void);
+    onMessageEdit: ((ctx: seal.MsgContext, msg: seal.Message) => void);
+    onGroupJoined: ((ctx: seal.MsgContext, msg: seal.Message) => void);
+    onGroupMemberJoined: ((ctx: seal.MsgContext, msg: seal.Message) => void);
+    onGuildJoined: ((ctx: seal.MsgContext, msg: seal.Message) => void);
+    onBecomeFriend: ((ctx: seal.MsgContext, msg: seal.Message) => void);
+    onPoke: ((ctx: seal.MsgContext, arg1: seal.PokeEvent) => void);
+    onGroupLeave: ((ctx: seal.MsgContext, arg1: seal.GroupLeaveEvent) => void);
+    getDescText: ((ext: seal.ExtInfo) => string);
+    isLoaded: boolean;
+    onLoad: (() => void);
+    callOnGroupLeave(arg0: seal.Dice, ctx: seal.MsgContext, arg2: seal.GroupLeaveEvent): void;
+    callOnMessageDeleted(arg0: seal.Dice, ctx: seal.MsgContext, msg: seal.Message): void;
+    callOnMessageSend(arg0: seal.Dice, ctx: seal.MsgContext, msg: seal.Message, arg3: string): void;
+    extGetFullName(): string;
+    getCmdMap(): Record<string, seal.CmdItemInfo>;
+    getPackageConfig(): Record<string, any>;
+    getRealExt(): seal.ExtInfo;
+    stats(): seal.JsExtStats;
+    storageClose(): void;
+    storageGet(arg0: string): string;
+    storageInit(): void;
+    storageSet(arg0: string, arg1: string): void;
+  }
+
+  interface GameSystemTemplate {
+    checkAttrValue(arg0: string, arg1: any): void;
+    checkCmdNames(): string[];
+    getAlias(arg0: string): string;
+    getAttrValue(ctx: seal.MsgContext, arg1: string): [any, boolean];
+    getDefaultValue(arg0: string): [any, string, boolean, boolean];
+    getDefaultValueEx(ctx: seal.MsgContext, arg1: string): any;
+    getDefaultValueEx0(ctx: seal.MsgContext, arg1: string): [any, string, boolean, boolean];
+    getDefaultValueEx0V1(ctx: seal.MsgContext, arg1: string): [any, string, boolean, boolean];
+    getRealValue(ctx: seal.MsgContext, arg1: string): any;
+    getRealValueBase(ctx: seal.MsgContext, arg1: string): any;
+    getShowKeyAs(ctx: seal.MsgContext, arg1: string): string;
+    getShowValueAs(ctx: seal.MsgContext, arg1: string): any;
+    init(): void;
+    recomputeDerived(ctx: seal.MsgContext, arg1: seal.AttributesItem, arg2: string[]): [string[], string[]];
+    validate(): void;
+  }
+
+  interface GroupInfo {
+    active: boolean;
+    groupId: string;
+    guildId: string;
+    channelId: string;
+    groupName: string;
+    cocRuleIndex: number;
+    logCurName: string;
+    logOn: boolean;
+    recentDiceSendTime: number;
+    showGroupWelcome: boolean;
+    groupWelcomeMessage: string;
+    enteredTime: number;
+    inviteUserId: string;
+    addToInactivated(arg0: string): void;
+    clearLogState(): void;
+    extActivateBatch(arg0: seal.ExtInfo[], arg1: Record<string, boolean>): void;
+    extActive(ext: seal.ExtInfo): void;
+    extGetActive(arg0: string): seal.ExtInfo;
+    extInactive(ext: seal.ExtInfo): seal.ExtInfo;
+    extInactiveByName(arg0: string): seal.ExtInfo;
+    extInactiveSystem(ext: seal.ExtInfo): seal.ExtInfo;
+    getActivatedExtList(arg0: seal.Dice): seal.ExtInfo[];
+    getActivatedExtListRaw(): seal.ExtInfo[];
+    getCharTemplate(arg0: seal.Dice): seal.GameSystemTemplate;
+    getLogState(): seal.GroupLogState;
+    isActive(ctx: seal.MsgContext): boolean;
+    isExtInactivated(arg0: string): boolean;
+    markDirty(arg0: seal.Dice): void;
+    marshalJSON(): number[];
+    playerGet(arg0: any, arg1: string): seal.GroupPlayerInfo;
+    removeFromInactivated(arg0: string): void;
+    setActivatedExtList(arg0: seal.ExtInfo[], arg1: seal.Dice): void;
+    setDefaultHelpGroup(arg0: string): void;
+    setLogOn(arg0: boolean): void;
+    setLogState(arg0: number, arg1: string, arg2: boolean): void;
+    syncExtensionsOnMessage(arg0: seal.Dice): void;
+    syncWrapperStatus(arg0: seal.Dice): boolean;
+    triggerExtHook(arg0: seal.Dice, arg1: ((ext: seal.ExtInfo) => (() => void))): void;
+    unmarshalJSON(arg0: number[]): void;
+  }
+
+  interface GroupLeaveEvent {
+    groupId: string;
+    userId: string;
+    operatorId: string;
+  }
+
+  interface GroupLogState {
+  }
+
+  interface GroupPlayerInfo {
+    name: string;
+    userId: string;
+    lastCommandTime: number;
+    autoSetNameTemplate: string;
+    getValueNameByAlias(arg0: string, arg1: Record<string, string[]>): string;
+  }
+
+  interface IMSession {
+    consumePendingQuit(arg0: string, arg1: string): seal.PendingQuitInfo;
+    execute(arg0: seal.EndPointInfo, msg: seal.Message, arg2: boolean): void;
+    executeNew(arg0: seal.EndPointInfo, msg: seal.Message): void;
+    getEpByPlatform(arg0: string): seal.EndPointInfo;
+    longTimeQuitInactiveGroupReborn(arg0: any, arg1: number): void;
+    markPendingQuit(arg0: string, arg1: string, arg2: string, arg3: number): void;
+    onGroupJoined(ctx: seal.MsgContext, msg: seal.Message): void;
+    onGroupLeave(ctx: seal.MsgContext, arg1: seal.GroupLeaveEvent): void;
+    onGroupMemberJoined(ctx: seal.MsgContext, msg: seal.Message): void;
+    onMessageDeleted(ctx: seal.MsgContext, msg: seal.Message): void;
+    onMessageEdit(ctx: seal.MsgContext, msg: seal.Message): void;
+    onMessageSend(ctx: seal.MsgContext, msg: seal.Message, arg2: string): void;
+    onPoke(ctx: seal.MsgContext, arg1: seal.PokeEvent): void;
+    preTriggerCommand(ctx: seal.MsgContext, msg: seal.Message, cmdArgs: seal.CmdArgs): void;
+  }
+
+  interface JsExtStats {
+  }
+
+  interface JsScriptInfo {
+  }
+
+  interface JsScriptTask {
+    off(): boolean;
+    on(): boolean;
+  }
+
+  interface JsScriptTaskCtx {
+    now: number;
+    key: string;
+  }
+
+  interface Kwarg {
+    name: string;
+    valueExists: boolean;
+    value: string;
+    asBool: boolean;
+    string(): string;
+  }
+
+  interface LogChange {
+  }
+
+  interface LogRetentionConfig {
+    ruleFor(arg0: string): seal.LogRetentionRule;
+    validate(): void;
+  }
+
+  interface LogRetentionRule {
+  }
+
+  interface MediaConfig {
+    maxBytes(): number;
+  }
+
+  interface Message {
+    time: number;
+    messageType: string;
+    groupId: string;
+    guildId: string;
+    channelId: string;
+    sender: seal.SenderBase;
+    message: string;
+    rawId: any;
+    platform: string;
+    segment: any[];
+  }
+
+  interface MsgContext {
+    group: seal.GroupInfo;
+    player: seal.GroupPlayerInfo;
+    endPoint: seal.EndPointInfo;
+    isCurGroupBotOn: boolean;
+    isPrivate: boolean;
+    commandHideFlag: string;
+    privilegeLevel: number;
+    delegateText: string;
+    createVmIfNotExists(): void;
+    eval(arg0: string, arg1: any): seal.VMResultV2;
+    evalFString(arg0: string, arg1: any): seal.VMResultV2;
+    genDefaultRollVmConfig(): any;
+    initSplitKey(): void;
+    notice(arg0: string): void;
+    noticeCrossPlatform(arg0: string): void;
+    setSplitKey(arg0: string): void;
+    shallowCopy(): seal.MsgContext;
+    splitText(arg0: string): string[];
+    translateSplit(arg0: string): string;
+  }
+
+  interface PendingQuitInfo {
+  }
+
+  interface PokeEvent {
+    groupId: string;
+    senderId: string;
+    targetId: string;
+    isPrivate: boolean;
+  }
+
+  interface SenderBase {
+    nickname: string;
+    userId: string;
+  }
+
+  interface SheetDocument {
+  }
+
+  interface UIWriter {
+    write(arg0: number[]): number;
+  }
+
+  interface VMResultV2 {
+    arrayFuncKeepBase(arg0: any, arg1: number, arg2: number): [boolean, number];
+    arrayFuncKeepHigh(arg0: any, arg1: number): [boolean, number];
+    arrayFuncKeepLow(arg0: any, arg1: number): [boolean, number];
+    arrayItemGet(arg0: any, arg1: number): any;
+    arrayItemSet(arg0: any, arg1: number, arg2: any): boolean;
+    arrayRepeatTimesEx(arg0: any, arg1: any): any;
+    asBool(): boolean;
+    asDictKey(): string;
+    attrGet(arg0: any, arg1: string): any;
+    attrSet(arg0: any, arg1: string, arg2: any): any;
+    clone(): any;
+    computedExecute(arg0: any, arg1: any): any;
+    funcInvoke(arg0: any, arg1: any[]): any;
+    funcInvokeNative(arg0: any, arg1: any[]): any;
+    funcInvokeRaw(arg0: any, arg1: any[], arg2: boolean): any;
+    getSlice(arg0: any, arg1: number, arg2: number, arg3: number): any;
+    getSliceEx(arg0: any, arg1: any, arg2: any): any;
+    getTypeName(): string;
+    itemGet(arg0: any, arg1: any): any;
+    itemSet(arg0: any, arg1: any, arg2: any): boolean;
+    length(arg0: any): number;
+    mustReadArray(): any;
+    mustReadDictData(): any;
+    mustReadFloat(): number;
+    mustReadInt(): number;
+    opAdd(arg0: any, arg1: any): any;
+    opBitwiseAnd(arg0: any, arg1: any): any;
+    opBitwiseOr(arg0: any, arg1: any): any;
+    opCompEQ(arg0: any, arg1: any): any;
+    opCompGE(arg0: any, arg1: any): any;
+    opCompGT(arg0: any, arg1: any): any;
+    opCompLE(arg0: any, arg1: any): any;
+    opCompLT(arg0: any, arg1: any): any;
+    opCompNE(arg0: any, arg1: any): any;
+    opDivide(arg0: any, arg1: any): any;
+    opModulus(arg0: any, arg1: any): any;
+    opMultiply(arg0: any, arg1: any): any;
+    opNegation(): any;
+    opNullCoalescing(arg0: any, arg1: any): any;
+    opPositive(): any;
+    opPower(arg0: any, arg1: any): any;
+    opSub(arg0: any, arg1: any): any;
+    readArray(): [any, boolean];
+    readComputed(): [any, boolean];
+    readDictData(): [any, boolean];
+    readFloat(): [number, boolean];
+    readFunctionData(): [any, boolean];
+    readInt(): [number, boolean];
+    readNativeFunctionData(): [any, boolean];
+    readNativeObjectData(): [any, boolean];
+    readString(): [string, boolean];
+    setSlice(arg0: any, arg1: number, arg2: number, arg3: number, arg4: any): boolean;
+    setSliceEx(arg0: any, arg1: any, arg2: any, arg3: any): boolean;
+    toJSON(): number[];
+    toJSONRaw(arg0: Record<string, boolean>): number[];
+    toRepr(): string;
+    toString(): string;
+    unmarshalJSON(arg0: number[]): void;
+  }
+}
+
+declare const seal: {
+  vars: {
+    intGet(ctx: seal.MsgContext, arg1: string): [number, boolean];
+    intSet(ctx: seal.MsgContext, arg1: string, arg2: number): void;
+    strGet(ctx: seal.MsgContext, arg1: string): [string, boolean];
+    strSet(ctx: seal.MsgContext, arg1: string, arg2: string): void;
+    computedSet(ctx: seal.MsgContext, arg1: string, arg2: string): void;
+    computedGet(ctx: seal.MsgContext, arg1: string): [string, boolean];
+  };
+  ban: {
+    addBan(ctx: seal.MsgContext, arg1: string, arg2: string, arg3: string): void;
+    addTrust(ctx: seal.MsgContext, arg1: string, arg2: string, arg3: string): void;
+    remove(ctx: seal.MsgContext, arg1: string): void;
+    getList(): seal.BanListInfoItem[];
+    getUser(arg0: string): seal.BanListInfoItem;
+  };
+  ext: {
+    newCmdItemInfo(): seal.CmdItemInfo;
+    newCmdExecuteResult(arg0: boolean): seal.CmdExecuteResult;
+    "new"(arg0: string, arg1: string, arg2: string): seal.ExtInfo;
+    find(arg0: string): seal.ExtInfo;
+    register(ext: seal.ExtInfo): void;
+    registerStringConfig(ext: seal.ExtInfo, arg1: string, arg2: string, arg3: string, arg4: string): void;
+    registerIntConfig(ext: seal.ExtInfo, arg1: string, arg2: number, arg3: string, arg4: string): void;
+    registerBoolConfig(ext: seal.ExtInfo, arg1: string, arg2: boolean, arg3: string, arg4: string): void;
+    registerFloatConfig(ext: seal.ExtInfo, arg1: string, arg2: number, arg3: string, arg4: string): void;
+    registerTemplateConfig(ext: seal.ExtInfo, arg1: string, arg2: string[], arg3: string, arg4: string): void;
+    registerOptionConfig(ext: seal.ExtInfo, arg1: string, arg2: string, arg3: string[], arg4: string, arg5: string): void;
+    registerSchemaConfig(ext: seal.ExtInfo, arg1: string, arg2: Record<string, any>, arg3: string): void;
+    newConfigItem(ext: seal.ExtInfo, arg1: string, arg2: any, arg3: string): seal.ConfigItem;
+    registerConfig(ext: seal.ExtInfo, ...arg1: seal.ConfigItem[]): void;
+    getConfig(ext: seal.ExtInfo, arg1: string): seal.ConfigItem;
+    getStringConfig(ext: seal.ExtInfo, arg1: string): string;
+    getIntConfig(ext: seal.ExtInfo, arg1: string): number;
+    getBoolConfig(ext: seal.ExtInfo, arg1: string): boolean;
+    getFloatConfig(ext: seal.ExtInfo, arg1: string): number;
+    getTemplateConfig(ext: seal.ExtInfo, arg1: string): string[];
+    getOptionConfig(ext: seal.ExtInfo, arg1: string): string;
+    getSchemaConfig(ext: seal.ExtInfo, arg1: string): any;
+    unregisterConfig(ext: seal.ExtInfo, ...arg1: string[]): void;
+    registerTask(ext: seal.ExtInfo, arg1: string, arg2: string, arg3: ((arg0: seal.JsScriptTaskCtx) => void), arg4: string, arg5: string, arg6: string): seal.JsScriptTask;
+  };
+  coc: {
+    newRule(): seal.CocRuleInfo;
+    newRuleCheckResult(): seal.CocRuleCheckRet;
+    registerRule(arg0: seal.CocRuleInfo): boolean;
+  };
+  deck: {
+    draw(ctx: seal.MsgContext, arg1: string, arg2: boolean): Record<string, any>;
+    reload(): void;
+  };
+  replyGroup(ctx: seal.MsgContext, msg: seal.Message, arg2: string): void;
+  replyPerson(ctx: seal.MsgContext, msg: seal.Message, arg2: string): void;
+  replyToSender(ctx: seal.MsgContext, msg: seal.Message, arg2: string): void;
+  memberBan(ctx: seal.MsgContext, arg1: string, arg2: string, arg3: number): void;
+  memberKick(ctx: seal.MsgContext, arg1: string, arg2: string): void;
+  format(ctx: seal.MsgContext, arg1: string): string;
+  formatTmpl(ctx: seal.MsgContext, arg1: string): string;
+  getCtxProxyFirst(ctx: seal.MsgContext, cmdArgs: seal.CmdArgs): seal.MsgContext;
+  newMessage(): seal.Message;
+  createTempCtx(arg0: seal.EndPointInfo, msg: seal.Message): seal.MsgContext;
+  applyPlayerGroupCardByTemplate(ctx: seal.MsgContext, arg1: string): string;
+  gameSystem: {
+    newTemplate(arg0: string): void;
+    newTemplateByYaml(arg0: string): void;
+  };
+  getCtxProxyAtPos(ctx: seal.MsgContext, cmdArgs: seal.CmdArgs, arg2: number): seal.MsgContext;
+  getVersion(): Record<string, any>;
+  getEndPoints(): seal.EndPointInfo[];
+  setPlayerGroupCard(ctx: seal.MsgContext, arg1: string): string;
+  base64ToImage(arg0: string): string;
+  ipc: {
+    handle(ext: seal.ExtInfo, arg1: string, arg2: ((arg0: any, ...arg1: any[]) => any)): void;
+    unhandle(ext: seal.ExtInfo, arg1: string): void;
+    call(ext: seal.ExtInfo, arg1: string, arg2: string, arg3: any, arg4: number): any;
+    subscribe(ext: seal.ExtInfo, arg1: string, arg2: string, arg3: ((arg0: any, ...arg1: any[]) => any)): number;
+    unsubscribe(ext: seal.ExtInfo, arg1: number): void;
+    publish(ext: seal.ExtInfo, arg1: string, arg2: any): number;
+  };
+};
+
+declare function atob(arg0: string): string;
+declare function btoa(arg0: string): string;
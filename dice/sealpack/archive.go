@@ -11,14 +11,15 @@ import (
 )
 
 var allowedArchiveRoots = map[string]struct{}{
-	InfoFile:    {},
-	"README.md": {},
-	"assets":    {},
-	"decks":     {},
-	"helpdoc":   {},
-	"reply":     {},
-	"scripts":   {},
-	"templates": {},
+	InfoFile:      {},
+	SignatureFile: {},
+	"README.md":   {},
+	"assets":      {},
+	"decks":       {},
+	"helpdoc":     {},
+	"reply":       {},
+	"scripts":     {},
+	"templates":   {},
 }
 
 // InspectArchive validates a .sealpack archive and returns its manifest and file list.
@@ -1,6 +1,7 @@
 package sealpack
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 )
@@ -157,3 +158,144 @@ func GetConfigWithDefaults(config map[string]interface{}, schemas map[string]Con
 
 	return result
 }
+
+// MigrateConfig 升级时迁移用户配置：以新 schema 的默认值为基础保留旧值，
+// 丢弃旧 schema 中存在但新 schema 已移除的配置项，以及不再符合新 schema 的值。
+func MigrateConfig(config map[string]interface{}, oldSchemas, newSchemas map[string]ConfigSchema) map[string]interface{} {
+	result := InitDefaultConfig(newSchemas)
+	for key, value := range config {
+		schema, inNew := newSchemas[key]
+		if !inNew {
+			if _, inOld := oldSchemas[key]; inOld {
+				continue
+			}
+			result[key] = value
+			continue
+		}
+		if ValidateConfigValue(key, value, schema) != nil {
+			continue
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// SecretMask 对外展示 secret 配置项时使用的占位值，提交该值表示保持原值不变
+const SecretMask = "******"
+
+var configSchemaTypes = map[string]struct{}{
+	"string": {}, "integer": {}, "number": {}, "boolean": {}, "array": {}, "object": {},
+}
+
+// ValidateConfigSchema 检查 schema 本身：类型有效（含数组子项与对象属性），且默认值满足约束
+func ValidateConfigSchema(key string, schema ConfigSchema) error {
+	if schema.Type == "" {
+		return errors.New("配置项 " + key + " 缺少 type 字段")
+	}
+	if _, ok := configSchemaTypes[schema.Type]; !ok {
+		return errors.New("配置项 " + key + " 的类型无效: " + schema.Type)
+	}
+	if schema.Min != nil && schema.Max != nil && *schema.Min > *schema.Max {
+		return fmt.Errorf("配置项 %s 的 min 大于 max", key)
+	}
+	if schema.Items != nil {
+		if err := ValidateConfigSchema(key+"[]", *schema.Items); err != nil {
+			return err
+		}
+	}
+	for propKey, propSchema := range schema.Properties {
+		if err := ValidateConfigSchema(key+"."+propKey, propSchema); err != nil {
+			return err
+		}
+	}
+	if schema.Default != nil {
+		if err := ValidateConfigValue(key, NormalizeConfigValue(schema.Default), schema); err != nil {
+			return fmt.Errorf("默认值无效: %w", err)
+		}
+	}
+	return nil
+}
+
+// NormalizeConfigValue 将值转换为 JSON 往返后的形式（数字为 float64，数组为 []interface{}），
+// 与经 API 保存、读取的配置值保持一致
+func NormalizeConfigValue(value interface{}) interface{} {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(raw, &normalized); err != nil {
+		return value
+	}
+	return normalized
+}
+
+// MaskSecretConfigValue 将 secret 配置值（含对象属性与数组元素中的 secret 项）替换为 SecretMask，空值保持不变
+func MaskSecretConfigValue(value interface{}, schema ConfigSchema) interface{} {
+	if value == nil {
+		return nil
+	}
+	if schema.Secret {
+		if s, ok := value.(string); ok && s == "" {
+			return value
+		}
+		return SecretMask
+	}
+	if arr, ok := value.([]interface{}); ok && schema.Items != nil {
+		masked := make([]interface{}, len(arr))
+		for i, v := range arr {
+			masked[i] = MaskSecretConfigValue(v, *schema.Items)
+		}
+		return masked
+	}
+	obj, ok := value.(map[string]interface{})
+	if !ok || len(schema.Properties) == 0 {
+		return value
+	}
+	masked := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		if propSchema, exists := schema.Properties[k]; exists {
+			masked[k] = MaskSecretConfigValue(v, propSchema)
+		} else {
+			masked[k] = v
+		}
+	}
+	return masked
+}
+
+// RestoreSecretConfigValue 将提交值中仍为 SecretMask 的 secret 项还原为原值
+func RestoreSecretConfigValue(value, previous interface{}, schema ConfigSchema) interface{} {
+	if schema.Secret {
+		if s, ok := value.(string); ok && s == SecretMask {
+			return previous
+		}
+		return value
+	}
+	if arr, ok := value.([]interface{}); ok && schema.Items != nil {
+		// 数组按下标对应原值，新增的元素没有原值可还原
+		prevArr, _ := previous.([]interface{})
+		restored := make([]interface{}, len(arr))
+		for i, v := range arr {
+			var prev interface{}
+			if i < len(prevArr) {
+				prev = prevArr[i]
+			}
+			restored[i] = RestoreSecretConfigValue(v, prev, *schema.Items)
+		}
+		return restored
+	}
+	obj, ok := value.(map[string]interface{})
+	if !ok || len(schema.Properties) == 0 {
+		return value
+	}
+	prevObj, _ := previous.(map[string]interface{})
+	restored := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		if propSchema, exists := schema.Properties[k]; exists {
+			restored[k] = RestoreSecretConfigValue(v, prevObj[k], propSchema)
+		} else {
+			restored[k] = v
+		}
+	}
+	return restored
+}
@@ -0,0 +1,35 @@
+package sealpack //nolint:testpackage
+
+import "testing"
+
+func TestMigrateConfigDropsRemovedKeys(t *testing.T) {
+	oldSchemas := map[string]ConfigSchema{
+		"mode":    {Type: "string", Default: "basic"},
+		"legacy":  {Type: "string", Default: "x"},
+		"retries": {Type: "string", Default: "3"},
+	}
+	newSchemas := map[string]ConfigSchema{
+		"mode":    {Type: "string", Default: "basic"},
+		"retries": {Type: "integer", Default: 3},
+		"added":   {Type: "boolean", Default: true},
+	}
+	got := MigrateConfig(map[string]interface{}{
+		"mode":    "advanced",
+		"legacy":  "kept?",
+		"retries": "5",
+		"custom":  1,
+	}, oldSchemas, newSchemas)
+
+	if got["mode"] != "advanced" {
+		t.Fatalf("mode = %v, want the user value to be kept", got["mode"])
+	}
+	if _, ok := got["legacy"]; ok {
+		t.Fatal("keys removed from the schema should be dropped")
+	}
+	if got["retries"] != 3 {
+		t.Fatalf("retries = %v, want the new default after a type change", got["retries"])
+	}
+	if got["added"] != true || got["custom"] != 1 {
+		t.Fatalf("unexpected migrated config: %v", got)
+	}
+}
@@ -0,0 +1,242 @@
+package sealpack
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// archiveEpoch 打包时写入所有条目的固定修改时间，使相同内容得到相同的归档
+var archiveEpoch = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ScaffoldManifest 生成带注释的 info.toml 模板
+func ScaffoldManifest(pkgID, name, author string) ([]byte, error) {
+	if err := ValidatePackageID(pkgID); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		_, name, _ = ParsePackageID(pkgID)
+	}
+	if author == "" {
+		author, _, _ = ParsePackageID(pkgID)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "format_version = %q\n\n", CurrentManifestFormatVersion)
+	b.WriteString("[package]\n")
+	fmt.Fprintf(&b, "id = %q\n", pkgID)
+	fmt.Fprintf(&b, "name = %q\n", name)
+	b.WriteString("version = \"0.1.0\"\n")
+	fmt.Fprintf(&b, "authors = [%q]\n", author)
+	b.WriteString("license = \"MIT\"\n")
+	b.WriteString("description = \"\"\n")
+	b.WriteString("\n# [package.seal]\n# min_version = \"1.5.0\"\n")
+	b.WriteString("\n# 依赖的其他扩展包，值为 semver 版本约束\n[dependencies]\n")
+	b.WriteString("\n[permissions]\nnetwork = false\n# network_hosts = [\"api.example.com\"]\n# file_read = []\n# file_write = []\n")
+	b.WriteString("\n# 只能引用对应目录下的文件，支持 * 与 ** 通配\n[contents]\nscripts = [\"scripts/*.js\"]\n")
+	b.WriteString("# decks = [\"decks/*.toml\"]\n# reply = [\"reply/*.yaml\"]\n# helpdoc = [\"helpdoc/**/*.json\"]\n# templates = [\"templates/*.yaml\"]\n")
+	b.WriteString("\n[store]\nreadme = \"README.md\"\n")
+	b.WriteString("\n# 用户可在 UI 中修改的配置项\n# [config.greeting]\n# type = \"string\"\n# title = \"问候语\"\n# default = \"你好\"\n")
+	return []byte(b.String()), nil
+}
+
+// CollectDirectory 读取扩展包源目录中会被打包的文件。
+// 以 . 开头的文件与目录、已打包的 .sealpack 以及旧的 signature.json 不会被打包；
+// 不属于包根目录布局的顶层条目同样跳过，并通过 skipped 返回。
+func CollectDirectory(dir string) (files map[string][]byte, skipped []string, err error) {
+	files = make(map[string][]byte)
+	root := filepath.Clean(dir)
+	err = filepath.WalkDir(root, func(p string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if p == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !strings.Contains(rel, "/") {
+			if !entry.IsDir() && strings.HasSuffix(rel, Extension) {
+				return nil
+			}
+			if _, ok := allowedArchiveRoots[rel]; !ok || rel == SignatureFile {
+				skipped = append(skipped, rel)
+				if entry.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return fmt.Errorf("不支持打包非普通文件: %s", rel)
+		}
+		if _, _, err := normalizeArchiveEntryName(rel); err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[rel] = data
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, ok := files[InfoFile]; !ok {
+		return nil, nil, fmt.Errorf("目录中缺少 %s", InfoFile)
+	}
+	sort.Strings(skipped)
+	return files, skipped, nil
+}
+
+// ValidatePackageFiles 检查 manifest 引用的文件是否真实存在，以及配置默认值是否符合 schema。
+// 返回的 errors 会导致安装失败或运行异常，warnings 仅作提示。
+func ValidatePackageFiles(manifest *Manifest, files map[string][]byte) (errs []string, warnings []string) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	patterns := map[string][]string{
+		"scripts":   manifest.Contents.Scripts,
+		"decks":     manifest.Contents.Decks,
+		"reply":     manifest.Contents.Reply,
+		"helpdoc":   manifest.Contents.Helpdoc,
+		"templates": manifest.Contents.Templates,
+	}
+	for _, dir := range []string{"scripts", "decks", "reply", "helpdoc", "templates"} {
+		for _, pattern := range patterns[dir] {
+			matched := false
+			for _, name := range names {
+				if MatchContentPattern(pattern, name) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				warnings = append(warnings, fmt.Sprintf("contents.%s 中的 %s 没有匹配任何文件", dir, pattern))
+			}
+		}
+	}
+
+	storePaths := []string{manifest.Store.Readme, manifest.Store.Icon, manifest.Store.Banner}
+	storePaths = append(storePaths, manifest.Store.Screenshots...)
+	for _, item := range storePaths {
+		if item == "" {
+			continue
+		}
+		if _, ok := files[path.Clean(filepath.ToSlash(item))]; !ok {
+			errs = append(errs, "store 引用的文件不存在: "+item)
+		}
+	}
+
+	keys := make([]string, 0, len(manifest.Config))
+	for key := range manifest.Config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		schema := manifest.Config[key]
+		if schema.Default == nil {
+			continue
+		}
+		// 用户配置经 JSON 读写，默认值按相同的类型校验（TOML 整数会变为 float64）
+		if err := ValidateConfigValue(key, NormalizeConfigValue(schema.Default), schema); err != nil {
+			errs = append(errs, "配置项 "+key+" 的默认值无效: "+err.Error())
+		}
+	}
+	return errs, warnings
+}
+
+// MatchContentPattern 判断包内路径是否匹配 contents 中的模式，** 可匹配任意层目录
+func MatchContentPattern(pattern, packagePath string) bool {
+	return matchContentPatternSegments(
+		strings.Split(filepath.ToSlash(pattern), "/"),
+		strings.Split(filepath.ToSlash(packagePath), "/"),
+	)
+}
+
+func matchContentPatternSegments(patternSegments, pathSegments []string) bool {
+	if len(patternSegments) == 0 {
+		return len(pathSegments) == 0
+	}
+	if patternSegments[0] == "**" {
+		for idx := 0; idx <= len(pathSegments); idx++ {
+			if matchContentPatternSegments(patternSegments[1:], pathSegments[idx:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(pathSegments) == 0 {
+		return false
+	}
+	matched, err := path.Match(patternSegments[0], pathSegments[0])
+	if err != nil || !matched {
+		return false
+	}
+	return matchContentPatternSegments(patternSegments[1:], pathSegments[1:])
+}
+
+// WriteArchive 将文件写为 .sealpack。条目按路径排序并使用固定的时间与权限，
+// 相同的输入总是得到字节一致的输出。
+func WriteArchive(w io.Writer, files map[string][]byte) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	zw := zip.NewWriter(w)
+	for _, name := range names {
+		header := &zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: archiveEpoch,
+		}
+		header.SetMode(0o644)
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// InspectFiles 以与安装时相同的规则校验即将打包的文件
+func InspectFiles(files map[string][]byte) (*ArchiveInfo, error) {
+	var buf bytes.Buffer
+	if err := WriteArchive(&buf, files); err != nil {
+		return nil, err
+	}
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		return nil, err
+	}
+	return inspectArchiveFiles(reader.File)
+}
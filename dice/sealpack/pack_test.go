@@ -0,0 +1,89 @@
+package sealpack //nolint:testpackage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCollectDirectoryAndWriteArchiveIsReproducible(t *testing.T) {
+	dir := t.TempDir()
+	manifest, err := ScaffoldManifest("alice/demo", "", "")
+	if err != nil {
+		t.Fatalf("ScaffoldManifest() error = %v", err)
+	}
+	for name, body := range map[string]string{
+		InfoFile:                 string(manifest),
+		"README.md":              "# demo",
+		"scripts/main.js":        "console.log('x')",
+		".git/HEAD":              "ref",
+		"package.json":           "{}",
+		"demo@0.0.1" + Extension: "old build",
+	} {
+		if body == string(manifest) {
+			if err := os.WriteFile(filepath.Join(dir, name), manifest, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(target, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, skipped, err := CollectDirectory(dir)
+	if err != nil {
+		t.Fatalf("CollectDirectory() error = %v", err)
+	}
+	if len(files) != 3 || len(skipped) != 1 || skipped[0] != "package.json" {
+		t.Fatalf("unexpected files %d / skipped %v", len(files), skipped)
+	}
+	info, err := InspectFiles(files)
+	if err != nil {
+		t.Fatalf("InspectFiles() error = %v", err)
+	}
+	if errs, warnings := ValidatePackageFiles(info.Manifest, files); len(errs) != 0 || len(warnings) != 0 {
+		t.Fatalf("scaffolded package should be clean, errs=%v warnings=%v", errs, warnings)
+	}
+
+	var first, second bytes.Buffer
+	if err := WriteArchive(&first, files); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteArchive(&second, files); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Fatal("WriteArchive() should produce identical output for identical input")
+	}
+}
+
+func TestValidatePackageFilesReportsMissingReferences(t *testing.T) {
+	manifest, err := ParseManifest([]byte(strings.Join([]string{
+		"[package]",
+		`id = "alice/demo"`,
+		`name = "Demo"`,
+		`version = "1.0.0"`,
+		"[contents]",
+		`decks = ["decks/*.toml"]`,
+		"[store]",
+		`icon = "assets/icon.png"`,
+		"[config.count]",
+		`type = "integer"`,
+		"max = 3",
+		"default = 5",
+	}, "\n")))
+	if err != nil {
+		t.Fatalf("ParseManifest() error = %v", err)
+	}
+	errs, warnings := ValidatePackageFiles(manifest, map[string][]byte{InfoFile: nil})
+	if len(errs) != 2 || len(warnings) != 1 {
+		t.Fatalf("expected icon and default errors plus an unmatched pattern warning, errs=%v warnings=%v", errs, warnings)
+	}
+}
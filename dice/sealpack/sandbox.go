@@ -285,6 +285,29 @@ func (s *Sandbox) CheckIPCPermission(targetPackageID string) error {
 	}
 }
 
+// CheckModulePath 检查 require 加载的模块文件是否位于包安装目录内，符号链接按实际位置判断
+func (s *Sandbox) CheckModulePath(path string) error {
+	resolve := func(p string) string {
+		if abs, err := filepath.Abs(p); err == nil {
+			p = abs
+		}
+		if resolved, err := filepath.EvalSymlinks(p); err == nil {
+			p = resolved
+		}
+		return p
+	}
+	rel, err := filepath.Rel(resolve(s.BasePath), resolve(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return &PermissionError{
+			PackageID:  s.PackageID,
+			Permission: "file_access",
+			Requested:  path,
+			Message:    "不允许加载扩展包目录以外的模块",
+		}
+	}
+	return nil
+}
+
 // SandboxedFS 沙箱化文件系统访问
 type SandboxedFS struct {
 	sandbox *Sandbox
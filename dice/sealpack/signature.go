@@ -0,0 +1,236 @@
+package sealpack
+
+import (
+	"archive/zip"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"sealdice-core/utils/crypto"
+)
+
+// SignatureFile 包内分离签名文件名，签名覆盖 info.toml 与其余全部文件
+const SignatureFile = "signature.json"
+
+// 签名算法
+const (
+	SignatureAlgorithmRSA   = "rsa-pss-sha256"
+	SignatureAlgorithmECDSA = "ecdsa"
+)
+
+// SignatureStatus 扩展包签名状态
+type SignatureStatus string
+
+const (
+	SignatureStatusTrusted    SignatureStatus = "trusted"     // 由受信任的密钥签名
+	SignatureStatusUnknownKey SignatureStatus = "unknown_key" // 签名有效，但密钥不在信任列表中
+	SignatureStatusUnsigned   SignatureStatus = "unsigned"    // 未签名
+	SignatureStatusInvalid    SignatureStatus = "invalid"     // 签名与内容不符或格式错误
+)
+
+// PackageSignature signature.json 对应结构
+type PackageSignature struct {
+	Algorithm string `json:"algorithm"`
+	Signer    string `json:"signer,omitempty"`
+	PublicKey string `json:"publicKey"` // PEM 格式公钥，用于计算指纹与校验
+	Signature string `json:"signature"` // base64
+}
+
+// TrustedKey 受信任的作者/商店公钥
+type TrustedKey struct {
+	Name      string `json:"name"      yaml:"name"`
+	PublicKey string `json:"publicKey" yaml:"publicKey"`
+}
+
+// SignatureInfo 签名校验结果
+type SignatureInfo struct {
+	Status         SignatureStatus `json:"status"`
+	Signer         string          `json:"signer,omitempty"`
+	KeyFingerprint string          `json:"keyFingerprint,omitempty"`
+	TrustedKeyName string          `json:"trustedKeyName,omitempty"`
+	Message        string          `json:"message,omitempty"`
+}
+
+// KeyFingerprint 计算 PEM 公钥的指纹（DER 的 sha256）
+func KeyFingerprint(publicKeyPEM string) (string, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(publicKeyPEM)))
+	if block == nil {
+		return "", errors.New("无法解析 PEM 公钥")
+	}
+	if _, err := x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(block.Bytes)
+	return "SHA256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// SignaturePayload 生成签名覆盖的内容：按路径排序的 "sha256  path" 行
+func SignaturePayload(files map[string][]byte) []byte {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if name == SignatureFile {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		sum := sha256.Sum256(files[name])
+		b.WriteString(hex.EncodeToString(sum[:]))
+		b.WriteString("  ")
+		b.WriteString(name)
+		b.WriteString("\n")
+	}
+	return []byte(b.String())
+}
+
+// SignFiles 使用 PKCS8 私钥为包内容签名，返回可直接写入 signature.json 的内容
+func SignFiles(files map[string][]byte, privateKeyPEM, signer string) ([]byte, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return nil, errors.New("无法解析 PEM 私钥")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := SignaturePayload(files)
+	sig := &PackageSignature{Signer: signer}
+	var pub interface{}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sig.Algorithm = SignatureAlgorithmRSA
+		sig.Signature, err = crypto.RSASign256(payload, privateKeyPEM)
+		pub = &k.PublicKey
+	case *ecdsa.PrivateKey:
+		sig.Algorithm = SignatureAlgorithmECDSA
+		sig.Signature, err = crypto.EcdsaSign(payload, privateKeyPEM)
+		pub = &k.PublicKey
+	default:
+		return nil, fmt.Errorf("不支持的私钥类型: %T", key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	sig.PublicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	return json.MarshalIndent(sig, "", "  ")
+}
+
+// VerifyArchiveSignature 校验 .sealpack 的分离签名，并根据信任列表给出签名状态。
+// 只有读取归档失败时返回 error，签名本身的问题体现在 SignatureInfo.Status 中。
+func VerifyArchiveSignature(pkgPath string, trusted []TrustedKey) (*SignatureInfo, error) {
+	reader, err := zip.OpenReader(pkgPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open extension package: %w", err)
+	}
+	defer reader.Close()
+
+	files := make(map[string][]byte, len(reader.File))
+	for _, file := range reader.File {
+		normalized, isDir, err := normalizeArchiveEntryName(file.Name)
+		if err != nil {
+			return nil, err
+		}
+		if normalized == "" || isDir {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(rc)
+		if closeErr := rc.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		files[normalized] = data
+	}
+
+	return VerifyFilesSignature(files, trusted), nil
+}
+
+// VerifyFilesSignature 校验已读取的包文件集合的签名
+func VerifyFilesSignature(files map[string][]byte, trusted []TrustedKey) *SignatureInfo {
+	raw, ok := files[SignatureFile]
+	if !ok {
+		return &SignatureInfo{Status: SignatureStatusUnsigned, Message: "扩展包未签名"}
+	}
+
+	var sig PackageSignature
+	if err := json.Unmarshal(raw, &sig); err != nil {
+		return &SignatureInfo{Status: SignatureStatusInvalid, Message: "签名文件格式错误: " + err.Error()}
+	}
+	info := &SignatureInfo{Signer: sig.Signer}
+
+	fingerprint, err := KeyFingerprint(sig.PublicKey)
+	if err != nil {
+		info.Status = SignatureStatusInvalid
+		info.Message = "签名公钥无效: " + err.Error()
+		return info
+	}
+	info.KeyFingerprint = fingerprint
+
+	if err := verifyPayload(SignaturePayload(files), &sig); err != nil {
+		info.Status = SignatureStatusInvalid
+		info.Message = "签名与包内容不符"
+		return info
+	}
+
+	for _, key := range trusted {
+		if fp, err := KeyFingerprint(key.PublicKey); err == nil && fp == fingerprint {
+			info.Status = SignatureStatusTrusted
+			info.TrustedKeyName = key.Name
+			info.Message = "由受信任的密钥签名"
+			return info
+		}
+	}
+	info.Status = SignatureStatusUnknownKey
+	info.Message = "签名有效，但签名密钥不在信任列表中"
+	return info
+}
+
+func verifyPayload(payload []byte, sig *PackageSignature) error {
+	block, _ := pem.Decode([]byte(sig.PublicKey))
+	if block == nil {
+		return errors.New("无法解析 PEM 公钥")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+
+	// utils/crypto 的读取函数会对类型直接断言，先确认算法与密钥类型一致
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		if sig.Algorithm != SignatureAlgorithmRSA {
+			return fmt.Errorf("签名算法 %s 与 RSA 公钥不匹配", sig.Algorithm)
+		}
+		return crypto.RSAVerify256(payload, sig.Signature, sig.PublicKey)
+	case *ecdsa.PublicKey:
+		if sig.Algorithm != SignatureAlgorithmECDSA {
+			return fmt.Errorf("签名算法 %s 与 ECDSA 公钥不匹配", sig.Algorithm)
+		}
+		return crypto.EcdsaVerify(payload, sig.Signature, sig.PublicKey)
+	default:
+		return fmt.Errorf("不支持的公钥类型: %T", pub)
+	}
+}
@@ -0,0 +1,108 @@
+package sealpack //nolint:testpackage
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+)
+
+func TestVerifyArchiveSignatureStatuses(t *testing.T) {
+	for name, signer := range map[string]crypto.Signer{
+		"rsa":   mustGenerateRSAKey(t),
+		"ecdsa": mustGenerateECDSAKey(t),
+	} {
+		t.Run(name, func(t *testing.T) {
+			files := map[string]string{
+				InfoFile:          minimalManifestForArchiveTest("alice/demo", "1.0.0"),
+				"scripts/main.js": "console.log('x')",
+			}
+			signature := signFilesForTest(t, files, signer)
+			trusted := []TrustedKey{{Name: "alice", PublicKey: publicKeyPEMForTest(t, signer)}}
+
+			files[SignatureFile] = signature
+			info, err := VerifyArchiveSignature(createArchiveForTest(t, files), trusted)
+			if err != nil {
+				t.Fatalf("VerifyArchiveSignature() error = %v", err)
+			}
+			if info.Status != SignatureStatusTrusted || info.TrustedKeyName != "alice" {
+				t.Fatalf("status = %s (%s), want trusted by alice", info.Status, info.TrustedKeyName)
+			}
+
+			info, _ = VerifyArchiveSignature(createArchiveForTest(t, files), nil)
+			if info.Status != SignatureStatusUnknownKey {
+				t.Fatalf("status without keyring = %s, want unknown_key", info.Status)
+			}
+
+			files["scripts/main.js"] = "console.log('tampered')"
+			info, _ = VerifyArchiveSignature(createArchiveForTest(t, files), trusted)
+			if info.Status != SignatureStatusInvalid {
+				t.Fatalf("status after tampering = %s, want invalid", info.Status)
+			}
+
+			delete(files, SignatureFile)
+			info, _ = VerifyArchiveSignature(createArchiveForTest(t, files), trusted)
+			if info.Status != SignatureStatusUnsigned {
+				t.Fatalf("status without signature = %s, want unsigned", info.Status)
+			}
+		})
+	}
+}
+
+func TestSignaturePayloadIgnoresSignatureFile(t *testing.T) {
+	a := SignaturePayload(map[string][]byte{InfoFile: []byte("x")})
+	b := SignaturePayload(map[string][]byte{InfoFile: []byte("x"), SignatureFile: []byte("{}")})
+	if string(a) != string(b) {
+		t.Fatalf("payload should not cover %s", SignatureFile)
+	}
+}
+
+func signFilesForTest(t *testing.T, files map[string]string, signer crypto.Signer) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey() error = %v", err)
+	}
+	privatePEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+
+	raw := make(map[string][]byte, len(files))
+	for name, body := range files {
+		raw[name] = []byte(body)
+	}
+	signature, err := SignFiles(raw, privatePEM, "alice")
+	if err != nil {
+		t.Fatalf("SignFiles() error = %v", err)
+	}
+	return string(signature)
+}
+
+func publicKeyPEMForTest(t *testing.T, signer crypto.Signer) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey() error = %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func mustGenerateRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	return key
+}
+
+func mustGenerateECDSAKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	return key
+}
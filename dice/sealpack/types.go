@@ -32,6 +32,15 @@ const (
 	UninstallModeDisable  UninstallMode = "disable_only" // 仅禁用
 )
 
+// DependentsPolicy 禁用或卸载仍被其他已启用扩展包依赖的包时的处理方式
+type DependentsPolicy string
+
+const (
+	DependentsPolicyRefuse  DependentsPolicy = ""        // 拒绝操作（默认）
+	DependentsPolicyForce   DependentsPolicy = "force"   // 强制执行，依赖方保持启用
+	DependentsPolicyCascade DependentsPolicy = "cascade" // 同时禁用所有依赖方
+)
+
 // Manifest manifest.toml 对应结构
 type Manifest struct {
 	FormatVersion string                  `toml:"format_version" json:"formatVersion"`
@@ -133,6 +142,8 @@ type Instance struct {
 	ErrText       string                 `json:"errText"`
 	SourceStatus  PackageSourceStatus    `json:"sourceStatus,omitempty"`
 	SourceWarning string                 `json:"sourceWarning,omitempty"`
+	Signature     *SignatureInfo         `json:"signature,omitempty"` // 源 .sealpack 的签名状态
+	Rollback      *RollbackInfo          `json:"rollback,omitempty"`  // 升级前的版本，可用于回滚
 
 	// PendingReload 待重载的内容类型列表
 	// 当包状态变更（启用/禁用）后设置，重载后清空
@@ -155,6 +166,16 @@ type InstancePersist struct {
 	UserDataPath  string                 `json:"userDataPath"`
 	Config        map[string]interface{} `json:"config"`
 	PendingReload []string               `json:"pendingReload,omitempty"`
+	Rollback      *RollbackInfo          `json:"rollback,omitempty"`
+}
+
+// RollbackInfo 升级时保留的旧版本信息
+type RollbackInfo struct {
+	Version        string                 `json:"version"`
+	SourcePath     string                 `json:"sourcePath"`     // 保留在源目录中的旧版 .sealpack
+	Config         map[string]interface{} `json:"config"`         // 升级前的用户配置
+	UserDataBackup string                 `json:"userDataBackup"` // 升级前 _userdata 的备份目录
+	UpgradeTime    time.Time              `json:"upgradeTime"`
 }
 
 // ArchiveInfo describes a validated .sealpack archive.
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	engine2 "sealdice-core/utils/dboperator/engine"
+)
+
+// AttrsHistoryAdd 记录一次人物卡修改，同一张卡只保留最近 limit 条
+func AttrsHistoryAdd(operator engine2.DatabaseOperator, item *model.AttributesHistoryModel, limit int) error {
+	db := operator.GetDataDB(constant.WRITE)
+	if item.CreatedAt == 0 {
+		item.CreatedAt = time.Now().Unix()
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(item).Error; err != nil {
+			return err
+		}
+		if limit <= 0 {
+			return nil
+		}
+		var expired []uint64
+		err := tx.Model(&model.AttributesHistoryModel{}).
+			Where("attrs_id = ?", item.AttrsID).
+			Order("id DESC").
+			Limit(1000).
+			Offset(limit).
+			Pluck("id", &expired).Error
+		if err != nil || len(expired) == 0 {
+			return err
+		}
+		return tx.Where("id IN ?", expired).Delete(&model.AttributesHistoryModel{}).Error
+	})
+}
+
+// AttrsHistoryList 人物卡的修改记录，从新到旧，不含卡数据
+func AttrsHistoryList(operator engine2.DatabaseOperator, attrsID string, limit int) ([]*model.AttributesHistoryModel, error) {
+	db := operator.GetDataDB(constant.READ)
+	var items []*model.AttributesHistoryModel
+	q := db.Model(&model.AttributesHistoryModel{}).
+		Select("id, attrs_id, sheet_type, actor_id, actor_name, command, changes, created_at").
+		Where("attrs_id = ?", attrsID).
+		Order("id DESC")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	if err := q.Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// AttrsHistoryGet 读取一条修改记录，包括修改前的卡数据
+func AttrsHistoryGet(operator engine2.DatabaseOperator, attrsID string, id uint64) (*model.AttributesHistoryModel, error) {
+	db := operator.GetDataDB(constant.READ)
+	var item model.AttributesHistoryModel
+	err := db.Where("id = ? AND attrs_id = ?", id, attrsID).Take(&item).Error
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// AttrsHistoryDelete 删除一条修改记录
+func AttrsHistoryDelete(operator engine2.DatabaseOperator, id uint64) error {
+	db := operator.GetDataDB(constant.WRITE)
+	return db.Where("id = ?", id).Delete(&model.AttributesHistoryModel{}).Error
+}
+
+// AttrsHistoryDeleteByAttrsID 删除人物卡的全部修改记录，用于删除角色
+func AttrsHistoryDeleteByAttrsID(operator engine2.DatabaseOperator, attrsID string) error {
+	db := operator.GetDataDB(constant.WRITE)
+	return db.Where("attrs_id = ?", attrsID).Delete(&model.AttributesHistoryModel{}).Error
+}
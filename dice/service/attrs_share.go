@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm/clause"
+
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	engine2 "sealdice-core/utils/dboperator/engine"
+)
+
+// AttrsSharedItem 共享给某个用户的人物卡
+type AttrsSharedItem struct {
+	ID         string `gorm:"column:id"         json:"id"`
+	Name       string `gorm:"column:name"       json:"name"`
+	SheetType  string `gorm:"column:sheet_type" json:"sheetType"`
+	OwnerID    string `gorm:"column:owner_id"   json:"ownerId"`
+	Permission string `gorm:"column:permission" json:"permission"`
+}
+
+// AttrsShareSet 把卡共享给用户，已有共享时更新权限
+func AttrsShareSet(operator engine2.DatabaseOperator, attrsID, userID, permission string) error {
+	db := operator.GetDataDB(constant.WRITE)
+	item := &model.AttributesShareModel{
+		AttrsID:    attrsID,
+		UserID:     userID,
+		Permission: permission,
+		CreatedAt:  time.Now().Unix(),
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "attrs_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"permission"}),
+	}).Create(item).Error
+}
+
+// AttrsShareGet 用户对卡的共享权限，没有共享时返回空字符串
+func AttrsShareGet(operator engine2.DatabaseOperator, attrsID, userID string) (string, error) {
+	db := operator.GetDataDB(constant.READ)
+	var item model.AttributesShareModel
+	err := db.Model(&model.AttributesShareModel{}).
+		Select("permission").
+		Where("attrs_id = ? AND user_id = ?", attrsID, userID).
+		Limit(1).
+		Find(&item).Error
+	if err != nil {
+		return "", err
+	}
+	return item.Permission, nil
+}
+
+// AttrsShareDelete 取消对某个用户的共享，返回删除的条数
+func AttrsShareDelete(operator engine2.DatabaseOperator, attrsID, userID string) (int64, error) {
+	db := operator.GetDataDB(constant.WRITE)
+	result := db.Where("attrs_id = ? AND user_id = ?", attrsID, userID).Delete(&model.AttributesShareModel{})
+	return result.RowsAffected, result.Error
+}
+
+// AttrsShareDeleteByAttrsID 删除卡的全部共享，用于删除角色
+func AttrsShareDeleteByAttrsID(operator engine2.DatabaseOperator, attrsID string) error {
+	db := operator.GetDataDB(constant.WRITE)
+	return db.Where("attrs_id = ?", attrsID).Delete(&model.AttributesShareModel{}).Error
+}
+
+// AttrsShareListByAttrsID 卡的全部共享记录，按共享时间排序
+func AttrsShareListByAttrsID(operator engine2.DatabaseOperator, attrsID string) ([]*model.AttributesShareModel, error) {
+	db := operator.GetDataDB(constant.READ)
+	var items []*model.AttributesShareModel
+	err := db.Where("attrs_id = ?", attrsID).Order("id ASC").Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// AttrsSharedListByUserId 共享给用户的全部人物卡；name 不为空时只取该名字的卡
+func AttrsSharedListByUserId(operator engine2.DatabaseOperator, userID, name string) ([]*AttrsSharedItem, error) {
+	db := operator.GetDataDB(constant.READ)
+	attrsTable := (&model.AttributesItemModel{}).TableName()
+	shareTable := (&model.AttributesShareModel{}).TableName()
+	q := db.Table(fmt.Sprintf("%s AS s", shareTable)).
+		Select("t.id, t.name, t.sheet_type, t.owner_id, s.permission").
+		Joins(fmt.Sprintf("JOIN %s AS t ON t.id = s.attrs_id", attrsTable)).
+		Where("s.user_id = ?", userID)
+	if name != "" {
+		q = q.Where("t.name = ?", name)
+	}
+	var items []*AttrsSharedItem
+	if err := q.Order("s.id ASC").Scan(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -367,7 +367,7 @@ func LogGetAllLines(operator engine2.DatabaseOperator, groupID string, logName s
 
 	// 查询行数据
 	err = db.Model(&model.LogOneItem{}).
-		Select("id, nickname, im_userid, time, message, is_dice, command_id, command_info, raw_msg_id, user_uniform_id").
+		Select("id, nickname, im_userid, time, message, is_dice, command_id, command_info, raw_msg_id, user_uniform_id, media").
 		Where("log_id = ?", logID).
 		Order("time ASC").
 		Find(&items).Error
@@ -415,7 +415,7 @@ func LogGetCursorLines(operator engine2.DatabaseOperator, groupID string, logNam
 	}
 	var items []model.LogOneItem
 	stmt := db.Model(&model.LogOneItem{}).
-		Select("id, nickname, im_userid, time, message, is_dice, command_id, command_info, raw_msg_id, user_uniform_id").
+		Select("id, nickname, im_userid, time, message, is_dice, command_id, command_info, raw_msg_id, user_uniform_id, media").
 		Where("log_id = ?", logID)
 	// 获取游标分页器
 	p := CreateLoggerPaginator(cursor, nil)
@@ -443,7 +443,7 @@ func LogGetExportCursorLines(operator engine2.DatabaseOperator, groupID string,
 	}
 	var items []model.LogOneItemParquet
 	stmt := db.Model(&model.LogOneItemParquet{}).
-		Select("id, nickname, im_userid, time, message, is_dice, command_id, command_info, raw_msg_id, user_uniform_id").
+		Select("id, nickname, im_userid, time, message, is_dice, command_id, command_info, raw_msg_id, user_uniform_id, media").
 		Where("log_id = ?", logID)
 	// 获取游标分页器
 	p := CreateLoggerPaginator(cursor, nil)
@@ -482,7 +482,7 @@ func LogGetLinePage(operator engine2.DatabaseOperator, param *QueryLogLinePage)
 
 	// 查询行数据
 	err = db.Model(&model.LogOneItem{}).
-		Select("id, nickname, im_userid, time, message, is_dice, command_id, command_info, raw_msg_id, user_uniform_id").
+		Select("id, nickname, im_userid, time, message, is_dice, command_id, command_info, raw_msg_id, user_uniform_id, media").
 		Where("log_id = ?", logID).
 		Order("time ASC").
 		Limit(param.PageSize).
@@ -538,7 +538,9 @@ func LogDelete(operator engine2.DatabaseOperator, groupID string, logName string
 		}
 		return nil
 	})
-
+	if err == nil {
+		notifyLogRemoved(logID)
+	}
 	return err
 }
 
@@ -570,6 +572,7 @@ func LogAppend(operator engine2.DatabaseOperator, groupID string, logName string
 		CommandInfo: logItem.CommandInfo,
 		RawMsgID:    logItem.RawMsgID,
 		UniformID:   logItem.UniformID,
+		Media:       logItem.Media,
 	}
 	err = db.Transaction(func(tx *gorm.DB) error {
 		if logID == 0 {
@@ -601,8 +604,13 @@ func LogAppend(operator engine2.DatabaseOperator, groupID string, logName string
 		}
 		return nil
 	})
-
-	return err == nil
+	if err != nil {
+		return false
+	}
+	// 回填 ID，图片等附件下载完成后据此补记
+	logItem.ID = newLogItem.ID
+	notifyItemsChanged(newLogItem)
+	return true
 }
 
 // LogAppendByID 向指定 log_id 的日志中追加一条消息。
@@ -626,6 +634,7 @@ func LogAppendByID(operator engine2.DatabaseOperator, logID uint64, groupID stri
 		CommandInfo: logItem.CommandInfo,
 		RawMsgID:    logItem.RawMsgID,
 		UniformID:   logItem.UniformID,
+		Media:       logItem.Media,
 	}
 
 	err := db.Transaction(func(tx *gorm.DB) error {
@@ -649,8 +658,13 @@ func LogAppendByID(operator engine2.DatabaseOperator, logID uint64, groupID stri
 		}
 		return nil
 	})
-
-	return err == nil
+	if err != nil {
+		return false
+	}
+	// 回填 ID，图片等附件下载完成后据此补记
+	logItem.ID = newLogItem.ID
+	notifyItemsChanged(newLogItem)
+	return true
 }
 
 // LogMarkDeleteByMsgID 撤回删除
@@ -665,7 +679,11 @@ func LogMarkDeleteByMsgID(operator engine2.DatabaseOperator, groupID string, log
 		return err
 	}
 	rid := fmt.Sprintf("%v", rawID)
+	var removedIDs []uint64
 	err = db.Transaction(func(tx *gorm.DB) error {
+		if err = tx.Model(&model.LogOneItem{}).Where("log_id = ? AND raw_msg_id = ?", logID, rid).Pluck("id", &removedIDs).Error; err != nil {
+			return err
+		}
 		if err = tx.Where("log_id = ? AND raw_msg_id = ?", logID, rid).Delete(&model.LogOneItem{}).Error; err != nil {
 			zap.S().Named(logger.LogKeyDatabase).Errorf("log delete error %s", err.Error())
 			return err
@@ -680,6 +698,9 @@ func LogMarkDeleteByMsgID(operator engine2.DatabaseOperator, groupID string, log
 		}
 		return nil
 	})
+	if err == nil {
+		notifyItemsRemoved(removedIDs)
+	}
 	return err
 }
 
@@ -721,7 +742,11 @@ func LogEditByMsgID(operator engine2.DatabaseOperator, groupID, logName, newCont
 		}
 		return fmt.Errorf("log edit: %w", err)
 	}
-
+	if logIndexer != nil {
+		items, errIndex := itemsForIndex(db, "log_id = ? AND raw_msg_id = ?", logID, rid)
+		indexerWarn(errIndex)
+		notifyItemsChanged(items...)
+	}
 	return nil
 }
 
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	engine2 "sealdice-core/utils/dboperator/engine"
+)
+
+var ErrLogExists = errors.New("同名日志已存在")
+
+// LogGetRetentionInfos 获取全部日志的信息，供保留策略判断。size 为空的旧日志会现场统计条目数
+func LogGetRetentionInfos(operator engine2.DatabaseOperator) ([]*model.LogInfo, error) {
+	db := operator.GetLogDB(constant.READ)
+	var lst []*model.LogInfo
+	if err := db.Model(&model.LogInfo{}).
+		Select("id, name, group_id, created_at, updated_at, size, upload_url, upload_time").
+		Order("updated_at ASC").
+		Find(&lst).Error; err != nil {
+		return nil, err
+	}
+
+	for _, info := range lst {
+		if info.Size != nil {
+			continue
+		}
+		var count int64
+		if err := db.Model(&model.LogOneItem{}).Where("log_id = ?", info.ID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		size := int(count)
+		info.Size = &size
+	}
+	return lst, nil
+}
+
+// LogGetInfoByName 获取单个日志的完整信息
+func LogGetInfoByName(operator engine2.DatabaseOperator, groupID string, logName string) (*model.LogInfo, error) {
+	db := operator.GetLogDB(constant.READ)
+	logID, err := getIDByGroupIDAndName(db, groupID, logName)
+	if err != nil {
+		return nil, err
+	}
+	return getLogInfoByID(db, logID)
+}
+
+// LogDeleteIfUnchanged 删除已归档的日志。归档期间日志又有新消息（updated_at 变化）时不删除，返回 false
+func LogDeleteIfUnchanged(operator engine2.DatabaseOperator, logID uint64, updatedAt int64) (bool, error) {
+	db := operator.GetLogDB(constant.WRITE)
+	deleted := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		ret := tx.Where("id = ? AND updated_at = ?", logID, updatedAt).Delete(&model.LogInfo{})
+		if ret.Error != nil {
+			return ret.Error
+		}
+		if ret.RowsAffected == 0 {
+			return nil
+		}
+		if err := tx.Where("log_id = ?", logID).Delete(&model.LogOneItem{}).Error; err != nil {
+			return err
+		}
+		deleted = true
+		return nil
+	})
+	if deleted && err == nil {
+		notifyLogRemoved(logID)
+	}
+	return deleted && err == nil, err
+}
+
+// LogRestore 将归档的日志写回数据库。next 每次返回一批条目，返回空切片表示结束。
+// 条目使用新的自增 ID，日志的创建、更新与上传信息保持归档时的值
+func LogRestore(operator engine2.DatabaseOperator, info *model.LogInfo, next func() ([]model.LogOneItemParquet, error)) (uint64, error) {
+	db := operator.GetLogDB(constant.WRITE)
+	if _, err := getIDByGroupIDAndName(db, info.GroupID, info.Name); err == nil {
+		return 0, ErrLogExists
+	} else if !errors.Is(err, ErrLogNotFound) {
+		return 0, err
+	}
+
+	newLog := model.LogInfo{
+		Name:       info.Name,
+		GroupID:    info.GroupID,
+		CreatedAt:  info.CreatedAt,
+		UpdatedAt:  info.UpdatedAt,
+		UploadURL:  info.UploadURL,
+		UploadTime: info.UploadTime,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newLog).Error; err != nil {
+			return err
+		}
+		size := 0
+		for {
+			lines, err := next()
+			if err != nil {
+				return err
+			}
+			if len(lines) == 0 {
+				break
+			}
+			items := make([]model.LogOneItem, 0, len(lines))
+			for _, line := range lines {
+				items = append(items, model.LogOneItem{
+					LogID:          newLog.ID,
+					GroupID:        info.GroupID,
+					Nickname:       line.Nickname,
+					IMUserID:       line.IMUserID,
+					Time:           line.Time,
+					Message:        line.Message,
+					IsDice:         line.IsDice,
+					CommandID:      line.CommandID,
+					CommandInfoStr: line.CommandInfoStr,
+					UniformID:      line.UniformID,
+					MediaStr:       line.MediaStr,
+					RawMsgIDStr:    line.RawMsgIDStr,
+				})
+			}
+			if err = tx.Create(&items).Error; err != nil {
+				return err
+			}
+			size += len(items)
+		}
+		// UpdateColumn 不会刷新 updated_at，保留归档时的值
+		return tx.Model(&model.LogInfo{}).Where("id = ?", newLog.ID).UpdateColumn("size", size).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	if logIndexer != nil {
+		items, errIndex := itemsForIndex(db, "log_id = ?", newLog.ID)
+		indexerWarn(errIndex)
+		notifyItemsChanged(items...)
+	}
+	return newLog.ID, nil
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"sealdice-core/logger"
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	engine2 "sealdice-core/utils/dboperator/engine"
+)
+
+// LogIndexer 日志消息变化时接收通知，用于维护全文索引。通知在数据库写入成功后发出
+type LogIndexer interface {
+	// LogItemsChanged 新增或修改了消息
+	LogItemsChanged(items []model.LogOneItem) error
+	// LogItemsRemoved 删除了消息
+	LogItemsRemoved(ids []uint64) error
+	// LogRemoved 删除了整份日志
+	LogRemoved(logID uint64) error
+}
+
+var logIndexer LogIndexer
+
+// SetLogIndexer 设置全文索引，启动时调用一次，传入 nil 关闭通知
+func SetLogIndexer(indexer LogIndexer) {
+	logIndexer = indexer
+}
+
+func indexerWarn(err error) {
+	if err != nil {
+		zap.S().Named(logger.LogKeyDatabase).Warnf("更新日志全文索引失败: %v", err)
+	}
+}
+
+func notifyItemsChanged(items ...model.LogOneItem) {
+	if logIndexer != nil && len(items) > 0 {
+		indexerWarn(logIndexer.LogItemsChanged(items))
+	}
+}
+
+func notifyItemsRemoved(ids []uint64) {
+	if logIndexer != nil && len(ids) > 0 {
+		indexerWarn(logIndexer.LogItemsRemoved(ids))
+	}
+}
+
+func notifyLogRemoved(logID uint64) {
+	if logIndexer != nil {
+		indexerWarn(logIndexer.LogRemoved(logID))
+	}
+}
+
+// itemsForIndex 重新读取需要索引的列
+func itemsForIndex(db *gorm.DB, where string, args ...interface{}) ([]model.LogOneItem, error) {
+	var items []model.LogOneItem
+	err := db.Model(&model.LogOneItem{}).
+		Select("id, log_id, group_id, im_userid, time, message, is_dice").
+		Where(where, args...).
+		Find(&items).Error
+	return items, err
+}
+
+// LogForEachItem 按 ID 顺序分批读取全部消息，用于重建索引
+func LogForEachItem(operator engine2.DatabaseOperator, batchSize int, fn func(items []model.LogOneItem) error) error {
+	db := operator.GetLogDB(constant.READ)
+	var last uint64
+	for {
+		var items []model.LogOneItem
+		err := db.Model(&model.LogOneItem{}).
+			Select("id, log_id, group_id, im_userid, time, message, is_dice").
+			Where("id > ?", last).
+			Order("id ASC").
+			Limit(batchSize).
+			Find(&items).Error
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		if err = fn(items); err != nil {
+			return err
+		}
+		last = items[len(items)-1].ID
+	}
+}
+
+// LogItemHit 搜索命中的消息及其所属日志
+type LogItemHit struct {
+	Item    *model.LogOneItem `json:"item"`
+	LogName string            `json:"logName"`
+	GroupID string            `json:"groupId"`
+}
+
+// LogGetItemsByIDs 按给定顺序取出消息，已不存在的消息会被跳过
+func LogGetItemsByIDs(operator engine2.DatabaseOperator, ids []uint64) ([]*LogItemHit, error) {
+	db := operator.GetLogDB(constant.READ)
+	if len(ids) == 0 {
+		return []*LogItemHit{}, nil
+	}
+	var items []*model.LogOneItem
+	err := db.Model(&model.LogOneItem{}).
+		Select("id, log_id, group_id, nickname, im_userid, time, message, is_dice, command_id, command_info, raw_msg_id, user_uniform_id, media").
+		Where("id IN ?", ids).
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint64]*model.LogOneItem, len(items))
+	logIDs := make([]uint64, 0, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+		logIDs = append(logIDs, item.LogID)
+	}
+	var logs []model.LogInfo
+	if err = db.Model(&model.LogInfo{}).Select("id, name, group_id").Where("id IN ?", logIDs).Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[uint64]string, len(logs))
+	for _, info := range logs {
+		names[info.ID] = info.Name
+	}
+
+	hits := make([]*LogItemHit, 0, len(ids))
+	for _, id := range ids {
+		item, ok := byID[id]
+		if !ok {
+			continue
+		}
+		hits = append(hits, &LogItemHit{Item: item, LogName: names[item.LogID], GroupID: item.GroupID})
+	}
+	return hits, nil
+}
@@ -0,0 +1,118 @@
+package service_test
+
+import (
+	"reflect"
+	"testing"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+)
+
+type recordingLogIndexer struct {
+	changed map[uint64]string
+	removed []uint64
+	logs    []uint64
+}
+
+func (r *recordingLogIndexer) LogItemsChanged(items []model.LogOneItem) error {
+	for _, item := range items {
+		r.changed[item.ID] = item.Message
+	}
+	return nil
+}
+
+func (r *recordingLogIndexer) LogItemsRemoved(ids []uint64) error {
+	r.removed = append(r.removed, ids...)
+	return nil
+}
+
+func (r *recordingLogIndexer) LogRemoved(logID uint64) error {
+	r.logs = append(r.logs, logID)
+	return nil
+}
+
+func TestLogIndexerReceivesChanges(t *testing.T) {
+	db := newLogInfoTestDB(t)
+	op := &logInfoTestOperator{db: db, dbType: constant.SQLITE}
+	groupID := "QQ-Group:1004"
+
+	indexer := &recordingLogIndexer{changed: map[uint64]string{}}
+	service.SetLogIndexer(indexer)
+	t.Cleanup(func() { service.SetLogIndexer(nil) })
+
+	for _, rawID := range []string{"raw-a", "raw-b"} {
+		if !service.LogAppend(op, groupID, "log", &model.LogOneItem{
+			Nickname: "tester",
+			IMUserID: "user",
+			Message:  "before-" + rawID,
+			RawMsgID: rawID,
+		}) {
+			t.Fatalf("LogAppend(%s) failed", rawID)
+		}
+	}
+	lines, err := service.LogGetAllLines(op, groupID, "log")
+	if err != nil || len(lines) != 2 {
+		t.Fatalf("LogGetAllLines() = %v, %v", lines, err)
+	}
+	idA, idB := lines[0].ID, lines[1].ID
+	want := map[uint64]string{idA: "before-raw-a", idB: "before-raw-b"}
+	if !reflect.DeepEqual(indexer.changed, want) {
+		t.Fatalf("after append changed = %v, want %v", indexer.changed, want)
+	}
+
+	if err = service.LogEditByMsgID(op, groupID, "log", "after-raw-a", "raw-a"); err != nil {
+		t.Fatalf("LogEditByMsgID(): %v", err)
+	}
+	if indexer.changed[idA] != "after-raw-a" {
+		t.Fatalf("after edit changed[%d] = %q, want %q", idA, indexer.changed[idA], "after-raw-a")
+	}
+
+	if err = service.LogMarkDeleteByMsgID(op, groupID, "log", "raw-b"); err != nil {
+		t.Fatalf("LogMarkDeleteByMsgID(): %v", err)
+	}
+	if !reflect.DeepEqual(indexer.removed, []uint64{idB}) {
+		t.Fatalf("removed = %v, want [%d]", indexer.removed, idB)
+	}
+
+	info, err := service.LogGetInfoByName(op, groupID, "log")
+	if err != nil {
+		t.Fatalf("LogGetInfoByName(): %v", err)
+	}
+	if err = service.LogDelete(op, groupID, "log"); err != nil {
+		t.Fatalf("LogDelete(): %v", err)
+	}
+	if !reflect.DeepEqual(indexer.logs, []uint64{info.ID}) {
+		t.Fatalf("removed logs = %v, want [%d]", indexer.logs, info.ID)
+	}
+}
+
+func TestLogGetItemsByIDsKeepsOrder(t *testing.T) {
+	db := newLogInfoTestDB(t)
+	op := &logInfoTestOperator{db: db, dbType: constant.SQLITE}
+	groupID := "QQ-Group:1005"
+
+	for _, msg := range []string{"first", "second", "third"} {
+		if !service.LogAppend(op, groupID, "log", &model.LogOneItem{Nickname: "tester", IMUserID: "user", Message: msg}) {
+			t.Fatalf("LogAppend(%s) failed", msg)
+		}
+	}
+	lines, err := service.LogGetAllLines(op, groupID, "log")
+	if err != nil || len(lines) != 3 {
+		t.Fatalf("LogGetAllLines() = %v, %v", lines, err)
+	}
+
+	hits, err := service.LogGetItemsByIDs(op, []uint64{lines[2].ID, 9999, lines[0].ID})
+	if err != nil {
+		t.Fatalf("LogGetItemsByIDs(): %v", err)
+	}
+	if len(hits) != 2 {
+		t.Fatalf("len(hits) = %d, want 2", len(hits))
+	}
+	if hits[0].Item.Message != "third" || hits[1].Item.Message != "first" {
+		t.Fatalf("hits = %q, %q, want third, first", hits[0].Item.Message, hits[1].Item.Message)
+	}
+	if hits[0].LogName != "log" || hits[0].GroupID != groupID {
+		t.Fatalf("hit log = %q/%q, want log/%s", hits[0].LogName, hits[0].GroupID, groupID)
+	}
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	engine2 "sealdice-core/utils/dboperator/engine"
+)
+
+// LogSetItemMedia 为已记录的消息补记图片与文件，同时刷新日志的更新时间，使之后的上传带上附件
+func LogSetItemMedia(operator engine2.DatabaseOperator, itemID uint64, media []model.LogMedia) error {
+	data, err := json.Marshal(media)
+	if err != nil {
+		return err
+	}
+	db := operator.GetLogDB(constant.WRITE)
+	return db.Transaction(func(tx *gorm.DB) error {
+		var item model.LogOneItem
+		if err := tx.Model(&model.LogOneItem{}).Select("id, log_id").Where("id = ?", itemID).Take(&item).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.LogOneItem{}).Where("id = ?", itemID).Update("media", string(data)).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.LogInfo{}).Where("id = ?", item.LogID).Update("updated_at", time.Now().Unix()).Error
+	})
+}
+
+// LogForEachMediaHash 遍历数据库中全部消息引用的附件，用于清理不再使用的文件
+func LogForEachMediaHash(operator engine2.DatabaseOperator, fn func(hash string)) error {
+	db := operator.GetLogDB(constant.READ)
+	var last uint64
+	for {
+		var items []model.LogOneItem
+		err := db.Model(&model.LogOneItem{}).
+			Select("id, media").
+			Where("id > ? AND media IS NOT NULL AND media <> ''", last).
+			Order("id ASC").
+			Limit(1000).
+			Find(&items).Error
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		for _, item := range items {
+			for _, m := range item.Media {
+				if m.Hash != "" {
+					fn(m.Hash)
+				}
+			}
+		}
+		last = items[len(items)-1].ID
+	}
+}
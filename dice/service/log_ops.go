@@ -0,0 +1,292 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	engine2 "sealdice-core/utils/dboperator/engine"
+)
+
+var (
+	ErrLogNameEmpty      = errors.New("日志名不能为空")
+	ErrLogSameLog        = errors.New("不能对同一份日志进行此操作")
+	ErrLogSplitPoint     = errors.New("分割点无效")
+	ErrLogSplitNoItems   = errors.New("分割点之后没有消息")
+	ErrLogSplitAllItems  = errors.New("分割点之前没有消息")
+	ErrLogSplitNameTaken = errors.New("找不到可用的新日志名")
+)
+
+// LogChange 日志合并、分割、改名后的结果，供调用方同步群内当前日志状态
+type LogChange struct {
+	// FromID 被改名或被合并掉的日志，分割时为原日志
+	FromID uint64 `json:"fromId"`
+	// ToID 改名后的日志（与 FromID 相同），合并的目标日志，或分割出的新日志
+	ToID   uint64 `json:"toId"`
+	ToName string `json:"toName"`
+	// Moved 移动的消息条数
+	Moved int64 `json:"moved"`
+}
+
+// LogSplitAt 分割点，三者取其一。该点及之后的消息移动到新日志
+type LogSplitAt struct {
+	// Time 时间戳，time >= Time 的消息
+	Time int64 `json:"time"`
+	// ItemID 消息 ID，id >= ItemID 的消息
+	ItemID uint64 `json:"itemId"`
+	// Index 第几条消息（从 1 开始，不计已撤回的消息）
+	Index int `json:"index"`
+}
+
+func (at LogSplitAt) valid() bool {
+	n := 0
+	if at.Time > 0 {
+		n++
+	}
+	if at.ItemID > 0 {
+		n++
+	}
+	if at.Index > 0 {
+		n++
+	}
+	return n == 1
+}
+
+// recountLog 按实际消息数重写 size，不计已撤回的消息
+func recountLog(tx *gorm.DB, logID uint64, nowTimestamp int64) error {
+	var count int64
+	if err := tx.Model(&model.LogOneItem{}).
+		Where("log_id = ? AND removed IS NULL", logID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	// 内容变了，已上传的链接不再对应当前内容，需要重新上传
+	return tx.Model(&model.LogInfo{}).
+		Where("id = ?", logID).
+		Updates(map[string]interface{}{
+			"size":        count,
+			"updated_at":  nowTimestamp,
+			"upload_url":  "",
+			"upload_time": 0,
+		}).Error
+}
+
+// reindexLog 消息所属日志变化后，分批通知全文索引
+func reindexLog(db *gorm.DB, logID uint64) {
+	if logIndexer == nil {
+		return
+	}
+	var last uint64
+	for {
+		var items []model.LogOneItem
+		err := db.Model(&model.LogOneItem{}).
+			Select("id, log_id, group_id, im_userid, time, message, is_dice").
+			Where("log_id = ? AND id > ?", logID, last).
+			Order("id ASC").
+			Limit(1000).
+			Find(&items).Error
+		if err != nil {
+			indexerWarn(err)
+			return
+		}
+		if len(items) == 0 {
+			return
+		}
+		notifyItemsChanged(items...)
+		last = items[len(items)-1].ID
+	}
+}
+
+// LogRename 日志改名，新名字已被占用时返回 ErrLogExists
+func LogRename(operator engine2.DatabaseOperator, groupID string, oldName string, newName string) (*LogChange, error) {
+	newName = strings.TrimSpace(newName)
+	if newName == "" {
+		return nil, ErrLogNameEmpty
+	}
+	db := operator.GetLogDB(constant.WRITE)
+	change := &LogChange{ToName: newName}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		logID, err := getIDByGroupIDAndName(tx, groupID, oldName)
+		if err != nil {
+			return err
+		}
+		change.FromID, change.ToID = logID, logID
+		if oldName == newName {
+			return nil
+		}
+		if _, err = getIDByGroupIDAndName(tx, groupID, newName); err == nil {
+			return ErrLogExists
+		} else if !errors.Is(err, ErrLogNotFound) {
+			return err
+		}
+		// 改名不改变内容，保留上传信息
+		return tx.Model(&model.LogInfo{}).Where("id = ?", logID).Update("name", newName).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// LogMerge 把 srcName 的全部消息并入 dstName，随后删除 srcName
+func LogMerge(operator engine2.DatabaseOperator, groupID string, srcName string, dstName string) (*LogChange, error) {
+	if srcName == dstName {
+		return nil, ErrLogSameLog
+	}
+	db := operator.GetLogDB(constant.WRITE)
+	change := &LogChange{ToName: dstName}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		src, err := getIDByGroupIDAndName(tx, groupID, srcName)
+		if err != nil {
+			return fmt.Errorf("%s: %w", srcName, err)
+		}
+		dst, err := getLogInfoByName(tx, groupID, dstName)
+		if err != nil {
+			return fmt.Errorf("%s: %w", dstName, err)
+		}
+		srcInfo, err := getLogInfoByID(tx, src)
+		if err != nil {
+			return err
+		}
+		change.FromID, change.ToID = src, dst.ID
+
+		ret := tx.Model(&model.LogOneItem{}).Where("log_id = ?", src).Update("log_id", dst.ID)
+		if ret.Error != nil {
+			return ret.Error
+		}
+		change.Moved = ret.RowsAffected
+		if err = tx.Where("id = ?", src).Delete(&model.LogInfo{}).Error; err != nil {
+			return err
+		}
+		if srcInfo.CreatedAt > 0 && srcInfo.CreatedAt < dst.CreatedAt {
+			if err = tx.Model(&model.LogInfo{}).Where("id = ?", dst.ID).Update("created_at", srcInfo.CreatedAt).Error; err != nil {
+				return err
+			}
+		}
+		return recountLog(tx, dst.ID, time.Now().Unix())
+	})
+	if err != nil {
+		return nil, err
+	}
+	notifyLogRemoved(change.FromID)
+	reindexLog(db, change.ToID)
+	return change, nil
+}
+
+// LogSplit 把 name 中分割点及之后的消息移动到新日志 newName。newName 为空时自动取名
+func LogSplit(operator engine2.DatabaseOperator, groupID string, name string, newName string, at LogSplitAt) (*LogChange, error) {
+	if !at.valid() {
+		return nil, ErrLogSplitPoint
+	}
+	newName = strings.TrimSpace(newName)
+	db := operator.GetLogDB(constant.WRITE)
+	change := &LogChange{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		logID, err := getIDByGroupIDAndName(tx, groupID, name)
+		if err != nil {
+			return err
+		}
+		change.FromID = logID
+
+		var where string
+		var arg interface{}
+		switch {
+		case at.Time > 0:
+			where, arg = "log_id = ? AND time >= ?", at.Time
+		case at.ItemID > 0:
+			where, arg = "log_id = ? AND id >= ?", at.ItemID
+		default:
+			var item model.LogOneItem
+			err = tx.Model(&model.LogOneItem{}).
+				Select("id").
+				Where("log_id = ? AND removed IS NULL", logID).
+				Order("id ASC").
+				Offset(at.Index - 1).
+				Take(&item).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrLogSplitNoItems
+			} else if err != nil {
+				return err
+			}
+			where, arg = "log_id = ? AND id >= ?", item.ID
+		}
+
+		var moving, total int64
+		if err = tx.Model(&model.LogOneItem{}).Where(where, logID, arg).Count(&moving).Error; err != nil {
+			return err
+		}
+		if moving == 0 {
+			return ErrLogSplitNoItems
+		}
+		if err = tx.Model(&model.LogOneItem{}).Where("log_id = ?", logID).Count(&total).Error; err != nil {
+			return err
+		}
+		if total == moving {
+			return ErrLogSplitAllItems
+		}
+		var firstTime int64
+		if err = tx.Model(&model.LogOneItem{}).Select("COALESCE(MIN(time), 0)").Where(where, logID, arg).Scan(&firstTime).Error; err != nil {
+			return err
+		}
+
+		if newName == "" {
+			if newName, err = nextSplitName(tx, groupID, name); err != nil {
+				return err
+			}
+		} else if _, err = getIDByGroupIDAndName(tx, groupID, newName); err == nil {
+			return ErrLogExists
+		} else if !errors.Is(err, ErrLogNotFound) {
+			return err
+		}
+		newLog := model.LogInfo{Name: newName, GroupID: groupID, CreatedAt: firstTime, UpdatedAt: firstTime}
+		if err = tx.Create(&newLog).Error; err != nil {
+			return err
+		}
+		change.ToID, change.ToName = newLog.ID, newName
+
+		// time 与 id 不一定同序，按实际条件移动
+		ret := tx.Model(&model.LogOneItem{}).Where(where, logID, arg).Update("log_id", newLog.ID)
+		if ret.Error != nil {
+			return ret.Error
+		}
+		change.Moved = ret.RowsAffected
+		now := time.Now().Unix()
+		if err = recountLog(tx, logID, now); err != nil {
+			return err
+		}
+		return recountLog(tx, newLog.ID, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	reindexLog(db, change.ToID)
+	return change, nil
+}
+
+func getLogInfoByName(db *gorm.DB, groupID string, logName string) (*model.LogInfo, error) {
+	logID, err := getIDByGroupIDAndName(db, groupID, logName)
+	if err != nil {
+		return nil, err
+	}
+	return getLogInfoByID(db, logID)
+}
+
+// nextSplitName 依次尝试 name_2、name_3……
+func nextSplitName(db *gorm.DB, groupID string, name string) (string, error) {
+	for i := 2; i < 100; i++ {
+		candidate := fmt.Sprintf("%s_%d", name, i)
+		_, err := getIDByGroupIDAndName(db, groupID, candidate)
+		if errors.Is(err, ErrLogNotFound) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", ErrLogSplitNameTaken
+}
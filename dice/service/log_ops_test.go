@@ -0,0 +1,132 @@
+package service_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+)
+
+func appendTestLines(t *testing.T, op *logInfoTestOperator, groupID, logName string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if !service.LogAppend(op, groupID, logName, &model.LogOneItem{
+			Nickname: "tester",
+			IMUserID: "user",
+			Message:  fmt.Sprintf("%s-%d", logName, i),
+		}) {
+			t.Fatalf("LogAppend(%s, %d) failed", logName, i)
+		}
+	}
+}
+
+func logSize(t *testing.T, op *logInfoTestOperator, groupID, logName string) int {
+	t.Helper()
+	info, err := service.LogGetInfoByName(op, groupID, logName)
+	if err != nil {
+		t.Fatalf("LogGetInfoByName(%s) error = %v", logName, err)
+	}
+	if info.Size == nil {
+		return 0
+	}
+	return *info.Size
+}
+
+func TestLogRename(t *testing.T) {
+	db := newLogInfoTestDB(t)
+	op := &logInfoTestOperator{db: db, dbType: constant.SQLITE}
+	groupID := "QQ-Group:1005"
+	appendTestLines(t, op, groupID, "a", 2)
+	appendTestLines(t, op, groupID, "b", 1)
+	if err := service.LogSetUploadInfo(op, groupID, "a", "https://example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.LogRename(op, groupID, "a", "b"); !errors.Is(err, service.ErrLogExists) {
+		t.Fatalf("LogRename() to existing name error = %v, want ErrLogExists", err)
+	}
+	change, err := service.LogRename(op, groupID, "a", "c")
+	if err != nil {
+		t.Fatalf("LogRename() error = %v", err)
+	}
+	if change.FromID != change.ToID || change.ToName != "c" {
+		t.Fatalf("unexpected change: %+v", change)
+	}
+	if count, ok := service.LogLinesCountGet(op, groupID, "c"); !ok || count != 2 {
+		t.Fatalf("LogLinesCountGet(c) = %d, %v", count, ok)
+	}
+	if url, _, _, _ := service.LogGetUploadInfo(op, groupID, "c"); url != "https://example.com/a" {
+		t.Fatalf("upload url after rename = %q, should be kept", url)
+	}
+}
+
+func TestLogMergeMovesItemsAndDropsSource(t *testing.T) {
+	db := newLogInfoTestDB(t)
+	op := &logInfoTestOperator{db: db, dbType: constant.SQLITE}
+	groupID := "QQ-Group:1006"
+	appendTestLines(t, op, groupID, "a", 2)
+	appendTestLines(t, op, groupID, "b", 3)
+	if err := service.LogSetUploadInfo(op, groupID, "b", "https://example.com/b"); err != nil {
+		t.Fatal(err)
+	}
+
+	indexer := &recordingLogIndexer{changed: map[uint64]string{}}
+	service.SetLogIndexer(indexer)
+	t.Cleanup(func() { service.SetLogIndexer(nil) })
+
+	change, err := service.LogMerge(op, groupID, "a", "b")
+	if err != nil {
+		t.Fatalf("LogMerge() error = %v", err)
+	}
+	if change.Moved != 2 {
+		t.Fatalf("moved = %d, want 2", change.Moved)
+	}
+	if _, ok := service.LogLinesCountGet(op, groupID, "a"); ok {
+		t.Fatal("source log should be deleted")
+	}
+	if size := logSize(t, op, groupID, "b"); size != 5 {
+		t.Fatalf("size of merged log = %d, want 5", size)
+	}
+	if url, _, _, _ := service.LogGetUploadInfo(op, groupID, "b"); url != "" {
+		t.Fatalf("upload url after merge = %q, should be cleared", url)
+	}
+	if len(indexer.logs) != 1 || indexer.logs[0] != change.FromID || len(indexer.changed) != 5 {
+		t.Fatalf("indexer not notified: logs=%v changed=%d", indexer.logs, len(indexer.changed))
+	}
+
+	if _, err = service.LogMerge(op, groupID, "b", "b"); !errors.Is(err, service.ErrLogSameLog) {
+		t.Fatalf("LogMerge() into itself error = %v", err)
+	}
+}
+
+func TestLogSplitByIndex(t *testing.T) {
+	db := newLogInfoTestDB(t)
+	op := &logInfoTestOperator{db: db, dbType: constant.SQLITE}
+	groupID := "QQ-Group:1007"
+	appendTestLines(t, op, groupID, "a", 5)
+
+	if _, err := service.LogSplit(op, groupID, "a", "", service.LogSplitAt{Index: 1}); !errors.Is(err, service.ErrLogSplitAllItems) {
+		t.Fatalf("split at first item error = %v, want ErrLogSplitAllItems", err)
+	}
+	if _, err := service.LogSplit(op, groupID, "a", "", service.LogSplitAt{Index: 6}); !errors.Is(err, service.ErrLogSplitNoItems) {
+		t.Fatalf("split after last item error = %v, want ErrLogSplitNoItems", err)
+	}
+
+	change, err := service.LogSplit(op, groupID, "a", "", service.LogSplitAt{Index: 4})
+	if err != nil {
+		t.Fatalf("LogSplit() error = %v", err)
+	}
+	if change.ToName != "a_2" || change.Moved != 2 {
+		t.Fatalf("unexpected change: %+v", change)
+	}
+	if size := logSize(t, op, groupID, "a"); size != 3 {
+		t.Fatalf("size of original log = %d, want 3", size)
+	}
+	lines, err := service.LogGetAllLines(op, groupID, "a_2")
+	if err != nil || len(lines) != 2 || lines[0].Message != "a-3" {
+		t.Fatalf("LogGetAllLines(a_2) = %v, %v", lines, err)
+	}
+}
@@ -0,0 +1,456 @@
+package dice
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	ds "github.com/sealdice/dicescript"
+)
+
+// 人物卡属性的通用处理：按规则模板把别名、"显示名(别名)"形式的键归一为标准属性名，
+// 以及属性值与 JSON 形式之间的转换。人物卡导入导出与 SealChat 的角色接口共用。
+
+type sheetAttrSnapshot struct {
+	Key   string
+	Value *ds.VMValue
+}
+
+type sheetAttrCandidate struct {
+	RawKey string
+	Value  *ds.VMValue
+}
+
+const sheetValueMaxDepth = 32
+
+func sheetLoadAliasMapValue(aliasMap *SyncMap[string, string], key string) (canonical string, ok bool) {
+	if aliasMap == nil {
+		return "", false
+	}
+
+	defer func() {
+		if recover() != nil {
+			canonical = ""
+			ok = false
+		}
+	}()
+
+	rawValue, exists := aliasMap.m.Load(key)
+	if !exists {
+		return "", false
+	}
+
+	canonical, ok = rawValue.(string)
+	return canonical, ok
+}
+
+func sheetLookupCanonicalAttrKey(tmpl *GameSystemTemplate, key string) (string, bool) {
+	if tmpl == nil || tmpl.AliasMap == nil {
+		return key, false
+	}
+
+	normalized := strings.ToLower(strings.TrimSpace(key))
+	if normalized == "" {
+		return key, false
+	}
+	if value, ok := sheetLoadAliasMapValue(tmpl.AliasMap, normalized); ok {
+		return value, true
+	}
+
+	normalized = chsS2T.Read(normalized)
+	if value, ok := sheetLoadAliasMapValue(tmpl.AliasMap, normalized); ok {
+		return value, true
+	}
+	return key, false
+}
+
+func splitSheetDisplayLabelKey(key string) (string, string, bool) {
+	for _, pair := range [][2]string{{"（", "）"}, {"(", ")"}} {
+		openIdx := strings.Index(key, pair[0])
+		if openIdx <= 0 {
+			continue
+		}
+		closeIdx := strings.LastIndex(key, pair[1])
+		if closeIdx <= openIdx+len(pair[0]) || closeIdx != len(key)-len(pair[1]) {
+			continue
+		}
+
+		left := strings.TrimSpace(key[:openIdx])
+		right := strings.TrimSpace(key[openIdx+len(pair[0]) : closeIdx])
+		if left == "" || right == "" {
+			continue
+		}
+		return left, right, true
+	}
+
+	return "", "", false
+}
+
+func resolveSheetAttrKey(key string, tmpl *GameSystemTemplate) (string, error) {
+	trimmed := strings.TrimSpace(key)
+	if trimmed == "" || tmpl == nil {
+		return trimmed, nil
+	}
+
+	if canonical, ok := sheetLookupCanonicalAttrKey(tmpl, trimmed); ok {
+		return canonical, nil
+	}
+
+	left, right, ok := splitSheetDisplayLabelKey(trimmed)
+	if !ok {
+		return trimmed, nil
+	}
+
+	leftCanonical, leftOK := sheetLookupCanonicalAttrKey(tmpl, left)
+	rightCanonical, rightOK := sheetLookupCanonicalAttrKey(tmpl, right)
+
+	switch {
+	case leftOK && rightOK && leftCanonical == rightCanonical:
+		return leftCanonical, nil
+	case rightOK && !leftOK:
+		return rightCanonical, nil
+	case leftOK && !rightOK:
+		return leftCanonical, nil
+	case leftOK && rightOK && leftCanonical != rightCanonical:
+		return "", fmt.Errorf("ambiguous attribute display label %q", trimmed)
+	default:
+		return trimmed, nil
+	}
+}
+
+func findSheetExistingCanonicalAttrValue(attrs *AttributesItem, canonicalKey string, tmpl *GameSystemTemplate) (*ds.VMValue, bool) {
+	if attrs == nil {
+		return nil, false
+	}
+	if value, exists := attrs.LoadX(canonicalKey); exists {
+		return value, true
+	}
+
+	var matchedValue *ds.VMValue
+	matched := false
+	consistent := true
+	attrs.Range(func(key string, value *ds.VMValue) bool {
+		resolvedKey, err := resolveSheetAttrKey(key, tmpl)
+		if err != nil || resolvedKey != canonicalKey || key == canonicalKey {
+			return true
+		}
+		if !matched {
+			matchedValue = value
+			matched = true
+			return true
+		}
+		if !ds.ValueEqual(matchedValue, value, false) {
+			consistent = false
+			return false
+		}
+		return true
+	})
+
+	if matched && consistent {
+		return matchedValue, true
+	}
+	return nil, false
+}
+
+func normalizeSheetAttrs(attrsData map[string]any, tmpl *GameSystemTemplate, existingAttrs *AttributesItem) (map[string]*ds.VMValue, error) {
+	grouped := make(map[string][]sheetAttrCandidate, len(attrsData))
+	for key, rawValue := range attrsData {
+		if strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid attribute key %q", key)
+		}
+
+		canonicalKey, err := resolveSheetAttrKey(key, tmpl)
+		if err != nil {
+			return nil, err
+		}
+		if canonicalKey == "" {
+			return nil, fmt.Errorf("invalid attribute key %q", key)
+		}
+
+		value, err := anyToVMValue(rawValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for attribute %q: %w", key, err)
+		}
+
+		grouped[canonicalKey] = append(grouped[canonicalKey], sheetAttrCandidate{
+			RawKey: key,
+			Value:  value,
+		})
+	}
+
+	normalized := make(map[string]*ds.VMValue, len(grouped))
+	for canonicalKey, candidates := range grouped {
+		uniqueCandidates := make([]sheetAttrCandidate, 0, len(candidates))
+		for _, candidate := range candidates {
+			duplicated := false
+			for _, existing := range uniqueCandidates {
+				if ds.ValueEqual(existing.Value, candidate.Value, false) {
+					duplicated = true
+					break
+				}
+			}
+			if !duplicated {
+				uniqueCandidates = append(uniqueCandidates, candidate)
+			}
+		}
+
+		if len(uniqueCandidates) == 1 {
+			normalized[canonicalKey] = uniqueCandidates[0].Value
+			continue
+		}
+
+		if existingValue, exists := findSheetExistingCanonicalAttrValue(existingAttrs, canonicalKey, tmpl); exists {
+			changedCandidates := make([]sheetAttrCandidate, 0, len(uniqueCandidates))
+			for _, candidate := range uniqueCandidates {
+				if ds.ValueEqual(existingValue, candidate.Value, false) {
+					continue
+				}
+				changedCandidates = append(changedCandidates, candidate)
+			}
+			if len(changedCandidates) == 1 {
+				normalized[canonicalKey] = changedCandidates[0].Value
+				continue
+			}
+			if len(changedCandidates) == 0 {
+				normalized[canonicalKey] = uniqueCandidates[0].Value
+				continue
+			}
+		}
+
+		return nil, fmt.Errorf("conflicting values for attribute %q", canonicalKey)
+	}
+	return normalized, nil
+}
+
+func cleanupSheetAliasKeys(attrs *AttributesItem, tmpl *GameSystemTemplate, normalizedAttrs map[string]*ds.VMValue) {
+	if attrs == nil || tmpl == nil || len(normalizedAttrs) == 0 {
+		return
+	}
+
+	toDelete := make([]string, 0)
+	attrs.Range(func(key string, value *ds.VMValue) bool {
+		canonicalKey, err := resolveSheetAttrKey(key, tmpl)
+		if err != nil || canonicalKey == "" || canonicalKey == key {
+			return true
+		}
+		if _, exists := normalizedAttrs[canonicalKey]; exists {
+			toDelete = append(toDelete, key)
+		}
+		return true
+	})
+
+	for _, key := range toDelete {
+		attrs.Delete(key)
+	}
+}
+
+func snapshotSheetAttrs(attrs *AttributesItem, tmpl *GameSystemTemplate) ([]sheetAttrSnapshot, map[string]struct{}) {
+	if attrs == nil {
+		return nil, nil
+	}
+
+	snapshots := make([]sheetAttrSnapshot, 0)
+	canonicalKeys := make(map[string]struct{})
+	attrs.Range(func(key string, value *ds.VMValue) bool {
+		snapshots = append(snapshots, sheetAttrSnapshot{
+			Key:   key,
+			Value: value,
+		})
+		if canonicalKey, err := resolveSheetAttrKey(key, tmpl); err == nil && canonicalKey == key && canonicalKey != "" {
+			canonicalKeys[canonicalKey] = struct{}{}
+		}
+		return true
+	})
+	return snapshots, canonicalKeys
+}
+
+// vmValueToAny 将 VMValue 转换为可 JSON 序列化的值
+func vmValueToAny(v *ds.VMValue) any {
+	if v == nil {
+		return nil
+	}
+
+	value, err := vmValueToAnyDepth(v, 0)
+	if err != nil {
+		return v.ToString()
+	}
+	return value
+}
+
+func vmValueToAnyDepth(v *ds.VMValue, depth int) (any, error) {
+	if depth >= sheetValueMaxDepth {
+		return nil, fmt.Errorf("attribute value exceeds max nesting depth %d", sheetValueMaxDepth)
+	}
+
+	switch v.TypeId {
+	case ds.VMTypeInt:
+		return v.MustReadInt(), nil
+	case ds.VMTypeFloat:
+		return v.MustReadFloat(), nil
+	case ds.VMTypeString:
+		s, _ := v.ReadString()
+		return s, nil
+	case ds.VMTypeArray:
+		arrayData := v.MustReadArray()
+		result := make([]any, 0, len(arrayData.List))
+		for _, item := range arrayData.List {
+			if item == nil {
+				result = append(result, nil)
+				continue
+			}
+			converted, err := vmValueToAnyDepth(item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, converted)
+		}
+		return result, nil
+	case ds.VMTypeDict:
+		dictData := v.MustReadDictData()
+		result := make(map[string]any)
+		dictData.Dict.Range(func(key string, value *ds.VMValue) bool {
+			if value == nil {
+				result[key] = nil
+				return true
+			}
+			converted, err := vmValueToAnyDepth(value, depth+1)
+			if err != nil {
+				result = nil
+				return false
+			}
+			result[key] = converted
+			return true
+		})
+		if result == nil {
+			return nil, fmt.Errorf("attribute value exceeds max nesting depth %d", sheetValueMaxDepth)
+		}
+		return result, nil
+	default:
+		// 对于复杂类型，尝试转换为字符串
+		return v.ToString(), nil
+	}
+}
+
+func parseSheetComputedString(value string) (*ds.VMValue, bool, error) {
+	trimmed := strings.TrimSpace(value)
+	if !strings.HasPrefix(trimmed, "&(") || !strings.HasSuffix(trimmed, ")") {
+		return nil, false, nil
+	}
+
+	expr := strings.TrimSpace(trimmed[2 : len(trimmed)-1])
+	if expr == "" {
+		return nil, true, errors.New("empty computed expression")
+	}
+	return ds.NewComputedVal(expr), true, nil
+}
+
+func sheetTypedEnvelopeType(v any) (ds.VMValueType, bool) {
+	switch val := v.(type) {
+	case float64:
+		if val != float64(int64(val)) {
+			return 0, false
+		}
+		return ds.VMValueType(int64(val)), true
+	case int:
+		return ds.VMValueType(val), true
+	case int64:
+		return ds.VMValueType(val), true
+	default:
+		return 0, false
+	}
+}
+
+func parseSheetTypedVMValue(v any) (*ds.VMValue, bool, error) {
+	rawMap, ok := v.(map[string]any)
+	if !ok {
+		return nil, false, nil
+	}
+	rawType, exists := rawMap["t"]
+	if !exists {
+		return nil, false, nil
+	}
+	typeID, ok := sheetTypedEnvelopeType(rawType)
+	if !ok || typeID != ds.VMTypeComputedValue {
+		return nil, false, nil
+	}
+	if _, exists := rawMap["v"]; !exists {
+		return nil, true, errors.New("missing computed value payload")
+	}
+
+	data, err := json.Marshal(rawMap)
+	if err != nil {
+		return nil, true, err
+	}
+	value, err := ds.VMValueFromJSON(data)
+	if err != nil {
+		return nil, true, err
+	}
+	computed, ok := value.ReadComputed()
+	if !ok || strings.TrimSpace(computed.Expr) == "" {
+		return nil, true, errors.New("empty computed expression")
+	}
+	return value, true, nil
+}
+
+// anyToVMValue 将 any 类型转换为 VMValue
+func anyToVMValue(v any) (*ds.VMValue, error) {
+	return anyToVMValueDepth(v, 0)
+}
+
+func anyToVMValueDepth(v any, depth int) (*ds.VMValue, error) {
+	if depth >= sheetValueMaxDepth {
+		return nil, fmt.Errorf("attribute value exceeds max nesting depth %d", sheetValueMaxDepth)
+	}
+
+	if typedValue, matched, err := parseSheetTypedVMValue(v); matched || err != nil {
+		return typedValue, err
+	}
+
+	switch val := v.(type) {
+	case float64:
+		// JSON 解析时数字默认为 float64
+		if val == float64(int64(val)) {
+			return ds.NewIntVal(ds.IntType(val)), nil
+		}
+		return ds.NewFloatVal(val), nil
+	case int:
+		return ds.NewIntVal(ds.IntType(val)), nil
+	case int64:
+		return ds.NewIntVal(ds.IntType(val)), nil
+	case ds.IntType:
+		return ds.NewIntVal(val), nil
+	case string:
+		if computedValue, matched, err := parseSheetComputedString(val); matched || err != nil {
+			return computedValue, err
+		}
+		return ds.NewStrVal(val), nil
+	case []any:
+		items := make([]*ds.VMValue, 0, len(val))
+		for _, item := range val {
+			converted, err := anyToVMValueDepth(item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, converted)
+		}
+		return ds.NewArrayValRaw(items), nil
+	case map[string]any:
+		dict := &ds.ValueMap{}
+		for key, item := range val {
+			converted, err := anyToVMValueDepth(item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			dict.Store(key, converted)
+		}
+		return ds.NewDictVal(dict).V(), nil
+	case bool:
+		if val {
+			return ds.NewIntVal(1), nil
+		}
+		return ds.NewIntVal(0), nil
+	default:
+		return ds.NewStrVal(fmt.Sprintf("%v", v)), nil
+	}
+}
@@ -0,0 +1,521 @@
+package dice
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	ds "github.com/sealdice/dicescript"
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// 内置的人物卡格式：
+//   json  - SheetDocument 本身，可完整往返
+//   dnd5e - Foundry VTT 导出的 D&D 5e 角色 JSON
+//   coc7  - COC7 人物卡表格(xlsx/csv)，按"属性名-数值"的相邻关系读取
+
+func init() {
+	RegisterSheetConverter(sheetJSONConverter{})
+	RegisterSheetConverter(sheetDnd5eConverter{})
+	RegisterSheetConverter(sheetCoc7TableConverter{})
+}
+
+// sheetNumber 读取 JSON 或表格中的数字
+func sheetNumber(v any) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case int64:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	case ds.IntType:
+		return float64(val), true
+	case json.Number:
+		f, err := val.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// sheetNumberValue 数字写入文档的形式，整数保持为整数
+func sheetNumberValue(f float64) any {
+	if f == math.Trunc(f) {
+		return int64(f)
+	}
+	return f
+}
+
+// sheetDocInt 读取文档中的整数属性
+func sheetDocInt(doc *SheetDocument, key string) (ds.IntType, bool) {
+	raw, ok := doc.Attrs[key]
+	if !ok {
+		return 0, false
+	}
+	v, err := anyToVMValue(raw)
+	if err != nil {
+		return 0, false
+	}
+	return v.ReadInt()
+}
+
+func sheetJSONObject(m map[string]any, keys ...string) map[string]any {
+	for _, k := range keys {
+		if m == nil {
+			return nil
+		}
+		m, _ = m[k].(map[string]any)
+	}
+	return m
+}
+
+// sheetJSONConverter 通用格式
+type sheetJSONConverter struct{}
+
+func (sheetJSONConverter) Name() string        { return "json" }
+func (sheetJSONConverter) Description() string { return "海豹通用人物卡 JSON，可完整导回" }
+func (sheetJSONConverter) SheetType() string   { return "" }
+func (sheetJSONConverter) Ext() string         { return ".json" }
+
+func (sheetJSONConverter) Detect(_ string, data []byte) bool {
+	var head struct {
+		Format string          `json:"format"`
+		Attrs  json.RawMessage `json:"attrs"`
+	}
+	if json.Unmarshal(data, &head) != nil {
+		return false
+	}
+	return head.Format == SheetDocumentFormat || (head.Format == "" && bytes.HasPrefix(bytes.TrimSpace(head.Attrs), []byte("{")))
+}
+
+func (sheetJSONConverter) Import(data []byte, _ *GameSystemTemplate) (*SheetDocument, error) {
+	doc := &SheetDocument{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, err
+	}
+	if doc.Format != "" && doc.Format != SheetDocumentFormat {
+		return nil, fmt.Errorf("不是海豹人物卡: %s", doc.Format)
+	}
+	if doc.Version > SheetDocumentVersion {
+		return nil, fmt.Errorf("人物卡版本 %d 过新，请升级海豹", doc.Version)
+	}
+	return doc, nil
+}
+
+func (sheetJSONConverter) Export(doc *SheetDocument, _ *GameSystemTemplate) ([]byte, error) {
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+var (
+	dnd5eAbilities = [][2]string{
+		{"str", "力量"}, {"dex", "敏捷"}, {"con", "体质"}, {"int", "智力"}, {"wis", "感知"}, {"cha", "魅力"},
+	}
+	dnd5eSkills = [][2]string{
+		{"acr", "体操"}, {"ani", "驯兽"}, {"arc", "奥秘"}, {"ath", "运动"}, {"dec", "欺瞒"}, {"his", "历史"},
+		{"ins", "洞悉"}, {"itm", "威吓"}, {"inv", "调查"}, {"med", "医药"}, {"nat", "自然"}, {"prc", "察觉"},
+		{"prf", "表演"}, {"per", "游说"}, {"rel", "宗教"}, {"slt", "巧手"}, {"ste", "隐匿"}, {"sur", "求生"},
+	}
+)
+
+// dnd5eSkillValue 与 .st 设置技能时相同的计算属性
+func dnd5eSkillValue(skill string, base ds.IntType, factor any) *ds.VMValue {
+	m := &ds.ValueMap{}
+	m.Store("base", ds.NewIntVal(base))
+	if f, ok := factor.(float64); ok {
+		m.Store("factor", ds.NewFloatVal(f))
+	} else if f, ok := factor.(int64); ok {
+		m.Store("factor", ds.NewIntVal(ds.IntType(f)))
+	}
+	return ds.NewComputedValRaw(&ds.ComputedData{
+		Expr:  fmt.Sprintf("pbCalc(this.base, this.factor, %s)", dndAttrParent[skill]),
+		Attrs: m,
+	})
+}
+
+// sheetDnd5eConverter Foundry VTT 的 dnd5e 角色导出，兼容旧版本的 data 字段
+type sheetDnd5eConverter struct{}
+
+func (sheetDnd5eConverter) Name() string        { return "dnd5e" }
+func (sheetDnd5eConverter) Description() string { return "Foundry VTT 导出的 D&D 5e 角色 JSON" }
+func (sheetDnd5eConverter) SheetType() string   { return "dnd5e" }
+func (sheetDnd5eConverter) Ext() string         { return ".json" }
+
+func dnd5eSystem(root map[string]any) map[string]any {
+	for _, key := range []string{"system", "data"} {
+		if sys := sheetJSONObject(root, key); sys != nil && sheetJSONObject(sys, "abilities") != nil {
+			return sys
+		}
+	}
+	if sheetJSONObject(root, "abilities") != nil {
+		return root
+	}
+	return nil
+}
+
+func (sheetDnd5eConverter) Detect(_ string, data []byte) bool {
+	var root map[string]any
+	if json.Unmarshal(data, &root) != nil {
+		return false
+	}
+	return dnd5eSystem(root) != nil
+}
+
+func (sheetDnd5eConverter) Import(data []byte, _ *GameSystemTemplate) (*SheetDocument, error) {
+	var root map[string]any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	sys := dnd5eSystem(root)
+	if sys == nil {
+		return nil, errors.New("没有找到 D&D 5e 角色属性")
+	}
+	doc := &SheetDocument{Format: SheetDocumentFormat, Version: SheetDocumentVersion, SheetType: "dnd5e", Attrs: map[string]any{}}
+	doc.Name, _ = root["name"].(string)
+
+	for _, item := range dnd5eAbilities {
+		ability := sheetJSONObject(sys, "abilities", item[0])
+		if v, ok := sheetNumber(ability["value"]); ok {
+			doc.Attrs[item[1]] = sheetNumberValue(v)
+		}
+		if v, ok := sheetNumber(ability["proficient"]); ok && v != 0 {
+			doc.Attrs[stpFormat(item[1])] = sheetNumberValue(v)
+		}
+	}
+
+	attributes := sheetJSONObject(sys, "attributes")
+	hp := sheetJSONObject(attributes, "hp")
+	if v, ok := sheetNumber(hp["value"]); ok {
+		doc.Attrs["hp"] = sheetNumberValue(v)
+	}
+	if v, ok := sheetNumber(hp["max"]); ok {
+		doc.Attrs["hpmax"] = sheetNumberValue(v)
+	}
+	ac := sheetJSONObject(attributes, "ac")
+	if v, ok := sheetNumber(ac["flat"]); ok {
+		doc.Attrs["ac"] = sheetNumberValue(v)
+	} else if v, ok = sheetNumber(ac["value"]); ok {
+		doc.Attrs["ac"] = sheetNumberValue(v)
+	}
+
+	// 熟练加值：有记录用记录，否则按等级计算，等级可能在职业物品上
+	if v, ok := sheetNumber(attributes["prof"]); ok {
+		doc.Attrs["熟练"] = sheetNumberValue(v)
+	} else {
+		level, _ := sheetNumber(sheetJSONObject(sys, "details")["level"])
+		if level == 0 {
+			items, _ := root["items"].([]any)
+			for _, raw := range items {
+				item, _ := raw.(map[string]any)
+				if item["type"] != "class" {
+					continue
+				}
+				for _, key := range []string{"system", "data"} {
+					if n, ok := sheetNumber(sheetJSONObject(item, key)["levels"]); ok {
+						level += n
+						break
+					}
+				}
+			}
+		}
+		if level > 0 {
+			doc.Attrs["熟练"] = int64(2 + (int(level)-1)/4)
+		}
+	}
+
+	for _, item := range dnd5eSkills {
+		skill := sheetJSONObject(sys, "skills", item[0])
+		factor, ok := sheetNumber(skill["value"])
+		if !ok || factor == 0 {
+			// 没有熟练的技能由模板按属性调整值计算
+			continue
+		}
+		doc.Attrs[item[1]] = sheetValueToAny(dnd5eSkillValue(item[1], 0, sheetNumberValue(factor)))
+	}
+	return doc, nil
+}
+
+func (sheetDnd5eConverter) Export(doc *SheetDocument, _ *GameSystemTemplate) ([]byte, error) {
+	abilities := map[string]any{}
+	for _, item := range dnd5eAbilities {
+		ability := map[string]any{"value": 10}
+		if v, ok := sheetDocInt(doc, item[1]); ok {
+			ability["value"] = v
+		}
+		if v, ok := sheetDocInt(doc, stpFormat(item[1])); ok {
+			ability["proficient"] = v
+		}
+		abilities[item[0]] = ability
+	}
+
+	hp := map[string]any{}
+	if v, ok := sheetDocInt(doc, "hp"); ok {
+		hp["value"] = v
+	}
+	if v, ok := sheetDocInt(doc, "hpmax"); ok {
+		hp["max"] = v
+	}
+	attributes := map[string]any{"hp": hp}
+	if v, ok := sheetDocInt(doc, "ac"); ok {
+		attributes["ac"] = map[string]any{"calc": "flat", "flat": v}
+	}
+	if v, ok := sheetDocInt(doc, "熟练"); ok {
+		attributes["prof"] = v
+	}
+
+	skills := map[string]any{}
+	for _, item := range dnd5eSkills {
+		raw, ok := doc.Attrs[item[1]]
+		if !ok {
+			continue
+		}
+		v, err := anyToVMValue(raw)
+		if err != nil {
+			continue
+		}
+		cd, ok := v.ReadComputed()
+		if !ok || cd.Attrs == nil {
+			continue
+		}
+		if factor, exists := cd.Attrs.Load("factor"); exists && factor != nil {
+			var f float64
+			if n, isInt := factor.ReadInt(); isInt {
+				f = float64(n)
+			} else if n, isFloat := factor.ReadFloat(); isFloat {
+				f = n
+			}
+			skills[item[0]] = map[string]any{"value": f}
+		}
+	}
+
+	return json.MarshalIndent(map[string]any{
+		"name": doc.Name,
+		"type": "character",
+		"system": map[string]any{
+			"abilities":  abilities,
+			"attributes": attributes,
+			"skills":     skills,
+		},
+	}, "", "  ")
+}
+
+// coc7TableValueHeaders 表格中表示最终数值的列名，技能表通常还有基础、职业、兴趣等列
+var coc7TableValueHeaders = []string{"成功率", "技能值", "总值", "合计", "总计", "数值", "当前值"}
+
+var coc7TableNameLabels = []string{"姓名", "名字", "角色名", "调查员", "调查员姓名", "name"}
+
+// sheetCoc7TableConverter COC7 人物卡表格
+type sheetCoc7TableConverter struct{}
+
+func (sheetCoc7TableConverter) Name() string        { return "coc7" }
+func (sheetCoc7TableConverter) Description() string { return "COC7 人物卡表格(xlsx/csv)" }
+func (sheetCoc7TableConverter) SheetType() string   { return "coc7" }
+func (sheetCoc7TableConverter) Ext() string         { return ".csv" }
+
+func (sheetCoc7TableConverter) Detect(filename string, data []byte) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".xlsx", ".csv", ".tsv":
+		return true
+	case ".json":
+		return false
+	}
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return true
+	}
+	text := bytes.TrimSpace(data)
+	return len(text) > 0 && text[0] != '{' && text[0] != '[' && bytes.ContainsAny(text, ",\t")
+}
+
+// coc7TableRows 读出表格的所有行，xlsx 读取全部工作表
+func coc7TableRows(data []byte) ([][][]string, error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		f, err := excelize.OpenReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = f.Close() }()
+		var sheets [][][]string
+		for _, name := range f.GetSheetList() {
+			rows, errRows := f.GetRows(name)
+			if errRows != nil {
+				return nil, errRows
+			}
+			sheets = append(sheets, rows)
+		}
+		return sheets, nil
+	}
+
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		// 中文 Excel 另存的 csv 通常是 GBK 编码
+		decoded, err := simplifiedchinese.GB18030.NewDecoder().Bytes(data)
+		if err != nil {
+			return nil, err
+		}
+		data = decoded
+	}
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	if firstLine, _, _ := bytes.Cut(data, []byte("\n")); bytes.Contains(firstLine, []byte("\t")) && !bytes.Contains(firstLine, []byte(",")) {
+		r.Comma = '\t'
+	}
+	var rows [][]string
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, record)
+	}
+	return [][][]string{rows}, nil
+}
+
+func coc7TableCell(rows [][]string, r, c int) string {
+	if r < 0 || r >= len(rows) || c < 0 || c >= len(rows[r]) {
+		return ""
+	}
+	return strings.TrimSpace(rows[r][c])
+}
+
+// coc7TableLabel 单元格作为属性名时的形式，去掉常见的冒号与星号
+func coc7TableLabel(text string) string {
+	return strings.TrimSpace(strings.TrimRight(text, ":：*"))
+}
+
+// coc7TableKnown 属性名是否为模板中的属性
+func coc7TableKnown(tmpl *GameSystemTemplate, label string) (string, bool) {
+	if tmpl == nil {
+		return label, true
+	}
+	if key, ok := sheetLookupCanonicalAttrKey(tmpl, label); ok {
+		return key, true
+	}
+	if _, ok := tmpl.Attrs.Defaults[label]; ok {
+		return label, true
+	}
+	if _, ok := tmpl.Attrs.DefaultsComputed[label]; ok {
+		return label, true
+	}
+	return label, false
+}
+
+// readCoc7Table 在一张表中查找"属性名 → 数值"：
+// 属性名所在行若在某个数值列(如"成功率")的表头之下，取该列；否则取右侧第一个非空单元格中的数字；
+// 右侧是另一个属性名或没有内容时(表头一行、数值一行的排法)取下方单元格
+func readCoc7Table(rows [][]string, tmpl *GameSystemTemplate, doc *SheetDocument) {
+	type header struct{ row, col int }
+	var valueHeaders []header
+	for r, row := range rows {
+		for c := range row {
+			cell := coc7TableCell(rows, r, c)
+			for _, h := range coc7TableValueHeaders {
+				if cell == h {
+					valueHeaders = append(valueHeaders, header{r, c})
+				}
+			}
+		}
+	}
+
+	for r, row := range rows {
+		for c := range row {
+			label := coc7TableLabel(coc7TableCell(rows, r, c))
+			if label == "" {
+				continue
+			}
+			if _, isNumber := sheetNumber(label); isNumber {
+				continue
+			}
+			if doc.Name == "" {
+				for _, l := range coc7TableNameLabels {
+					if strings.EqualFold(label, l) {
+						doc.Name = coc7TableCell(rows, r, c+1)
+					}
+				}
+			}
+			key, ok := coc7TableKnown(tmpl, label)
+			if !ok {
+				continue
+			}
+			if _, exists := doc.Attrs[key]; exists {
+				continue
+			}
+
+			value := ""
+			// 离得最近的数值列
+			best := -1
+			for _, h := range valueHeaders {
+				if h.row < r && h.col > c && (best < 0 || h.col < best) {
+					best = h.col
+				}
+			}
+			if best >= 0 {
+				value = coc7TableCell(rows, r, best)
+			}
+			if _, isNumber := sheetNumber(value); !isNumber {
+				value = coc7TableCell(rows, r+1, c)
+				for cc := c + 1; cc < len(row); cc++ {
+					cell := coc7TableCell(rows, r, cc)
+					if cell == "" {
+						continue
+					}
+					if _, isNumber := sheetNumber(cell); isNumber {
+						value = cell
+					}
+					break
+				}
+			}
+			if n, isNumber := sheetNumber(value); isNumber {
+				doc.Attrs[key] = sheetNumberValue(n)
+			}
+		}
+	}
+}
+
+func (sheetCoc7TableConverter) Import(data []byte, tmpl *GameSystemTemplate) (*SheetDocument, error) {
+	sheets, err := coc7TableRows(data)
+	if err != nil {
+		return nil, err
+	}
+	doc := &SheetDocument{Format: SheetDocumentFormat, Version: SheetDocumentVersion, SheetType: "coc7", Attrs: map[string]any{}}
+	for _, rows := range sheets {
+		readCoc7Table(rows, tmpl, doc)
+	}
+	return doc, nil
+}
+
+// Export 输出"属性,数值"两列的 csv，带 BOM 以便 Excel 正确识别编码
+func (sheetCoc7TableConverter) Export(doc *SheetDocument, _ *GameSystemTemplate) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("\xef\xbb\xbf")
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"属性", "数值"})
+	_ = w.Write([]string{"姓名", doc.Name})
+	for _, key := range sheetSortedKeys(doc.Attrs) {
+		if strings.HasPrefix(key, "$") {
+			continue
+		}
+		v, err := anyToVMValue(doc.Attrs[key])
+		if err != nil || (v.TypeId != ds.VMTypeInt && v.TypeId != ds.VMTypeFloat) {
+			continue
+		}
+		_ = w.Write([]string{key, v.ToString()})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
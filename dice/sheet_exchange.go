@@ -0,0 +1,363 @@
+package dice
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	ds "github.com/sealdice/dicescript"
+
+	"sealdice-core/message"
+	"sealdice-core/utils"
+)
+
+// 人物卡导入导出：各种外部格式先转换为以规则模板标准属性名为键的 SheetDocument，
+// 再由 SheetDocument 写入 AttributesItem；导出时反过来。新增格式只需实现 SheetConverter 并注册。
+
+const (
+	// SheetDocumentFormat SheetDocument 序列化后 format 字段的值
+	SheetDocumentFormat = "sealdice-sheet"
+	// SheetDocumentVersion 当前的 SheetDocument 版本
+	SheetDocumentVersion = 1
+
+	// SheetImportMaxBytes 导入的人物卡文件大小上限
+	SheetImportMaxBytes = 5 << 20
+
+	sheetFetchTimeout = 20 * time.Second
+)
+
+var (
+	ErrSheetFormatUnknown     = errors.New("无法识别的人物卡格式")
+	ErrSheetExportUnsupported = errors.New("此格式不支持导出")
+	ErrSheetSource            = errors.New("只支持从网址或消息附件导入")
+	ErrSheetDownload          = errors.New("下载人物卡失败，请确认网址可以公开访问")
+
+	// sheetPublicClient 下载玩家给出的网址，只连接公网地址
+	sheetPublicClient = utils.NewPublicHTTPClient(0)
+)
+
+// SheetDocument 人物卡的通用形式。Attrs 的键为规则模板中的标准属性名，
+// 值为 JSON 可表示的数字、字符串、数组与对象，计算属性使用 dicescript 的 {"t":..,"v":..} 形式
+type SheetDocument struct {
+	Format    string         `json:"format"`
+	Version   int            `json:"version"`
+	Name      string         `json:"name"`
+	SheetType string         `json:"sheetType"`
+	Attrs     map[string]any `json:"attrs"`
+}
+
+// SheetConverter 人物卡格式转换器
+type SheetConverter interface {
+	// Name 格式名，用于 .pc import/export 指定格式
+	Name() string
+	// Description 格式说明
+	Description() string
+	// SheetType 格式对应的规则，为空表示不限规则
+	SheetType() string
+	// Ext 导出文件的扩展名，含点号
+	Ext() string
+	// Detect 判断数据是否为此格式，filename 可能为空
+	Detect(filename string, data []byte) bool
+	// Import 读取外部数据。tmpl 为导入目标的规则模板，可能为 nil
+	Import(data []byte, tmpl *GameSystemTemplate) (*SheetDocument, error)
+	// Export 输出外部数据，不支持时返回 ErrSheetExportUnsupported
+	Export(doc *SheetDocument, tmpl *GameSystemTemplate) ([]byte, error)
+}
+
+var sheetConverters struct {
+	sync.RWMutex
+	list []SheetConverter
+}
+
+// RegisterSheetConverter 注册人物卡格式转换器，同名的会被替换。自动识别时按注册顺序尝试
+func RegisterSheetConverter(c SheetConverter) {
+	sheetConverters.Lock()
+	defer sheetConverters.Unlock()
+	for idx, item := range sheetConverters.list {
+		if item.Name() == c.Name() {
+			sheetConverters.list[idx] = c
+			return
+		}
+	}
+	sheetConverters.list = append(sheetConverters.list, c)
+}
+
+// SheetConverters 已注册的转换器
+func SheetConverters() []SheetConverter {
+	sheetConverters.RLock()
+	defer sheetConverters.RUnlock()
+	return append([]SheetConverter(nil), sheetConverters.list...)
+}
+
+// GetSheetConverter 按格式名查找转换器，不区分大小写
+func GetSheetConverter(name string) (SheetConverter, bool) {
+	for _, c := range SheetConverters() {
+		if strings.EqualFold(c.Name(), name) {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// DetectSheetConverter 识别数据的格式
+func DetectSheetConverter(filename string, data []byte) (SheetConverter, bool) {
+	for _, c := range SheetConverters() {
+		if c.Detect(filename, data) {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// SheetFormatNames 已注册的格式名，用于提示
+func SheetFormatNames() string {
+	list := SheetConverters()
+	names := make([]string, 0, len(list))
+	for _, c := range list {
+		names = append(names, c.Name())
+	}
+	return strings.Join(names, "/")
+}
+
+// sheetValueToAny 属性值转为文档中的形式，计算属性保留表达式以便原样导回
+func sheetValueToAny(v *ds.VMValue) any {
+	if v != nil && v.TypeId == ds.VMTypeComputedValue {
+		if data, err := v.ToJSON(); err == nil {
+			var m map[string]any
+			if json.Unmarshal(data, &m) == nil {
+				return m
+			}
+		}
+	}
+	return vmValueToAny(v)
+}
+
+type sheetCanonicalEntry struct {
+	Value    *ds.VMValue
+	Priority int
+}
+
+// canonicalSheetAttrs 以标准属性名列出卡上的属性。同一属性既有标准名又有别名时取标准名的值
+func canonicalSheetAttrs(attrs *AttributesItem, tmpl *GameSystemTemplate) map[string]*ds.VMValue {
+	snapshots, canonicalKeys := snapshotSheetAttrs(attrs, tmpl)
+
+	entries := make(map[string]sheetCanonicalEntry)
+	for _, snapshot := range snapshots {
+		key := snapshot.Key
+		value := snapshot.Value
+		outputKey := key
+		priority := 0
+
+		if canonicalKey, err := resolveSheetAttrKey(key, tmpl); err == nil && canonicalKey != "" {
+			if canonicalKey == key {
+				outputKey = canonicalKey
+				priority = 2
+			} else if _, exists := canonicalKeys[canonicalKey]; exists {
+				outputKey = canonicalKey
+				priority = 1
+			}
+		}
+
+		if existing, exists := entries[outputKey]; exists {
+			if existing.Priority > priority {
+				continue
+			}
+			if existing.Priority == priority && existing.Value != nil && value != nil && ds.ValueEqual(existing.Value, value, false) {
+				continue
+			}
+		}
+		entries[outputKey] = sheetCanonicalEntry{Value: value, Priority: priority}
+	}
+
+	result := make(map[string]*ds.VMValue, len(entries))
+	for key, entry := range entries {
+		result[key] = entry.Value
+	}
+	return result
+}
+
+// applySheetAttrs 把以任意名称(标准名、别名或"显示名(别名)")为键的属性写入卡片，并清理同一属性的别名键
+func applySheetAttrs(attrs *AttributesItem, tmpl *GameSystemTemplate, data map[string]any) error {
+	normalized, err := normalizeSheetAttrs(data, tmpl, attrs)
+	if err != nil {
+		return err
+	}
+	for key, value := range normalized {
+		attrs.Store(key, value)
+	}
+	cleanupSheetAliasKeys(attrs, tmpl, normalized)
+	return nil
+}
+
+// sheetTemplate 卡片对应的规则模板，找不到时为 nil
+func (d *Dice) sheetTemplate(sheetType string) *GameSystemTemplate {
+	if sheetType == "" || d.GameSystemMap == nil {
+		return nil
+	}
+	tmpl, _ := d.GameSystemMap.Load(sheetType)
+	return tmpl
+}
+
+// SheetDocumentFromAttrs 将人物卡转为通用形式
+func (d *Dice) SheetDocumentFromAttrs(attrs *AttributesItem) *SheetDocument {
+	doc := &SheetDocument{
+		Format:    SheetDocumentFormat,
+		Version:   SheetDocumentVersion,
+		Name:      attrs.Name,
+		SheetType: attrs.SheetType,
+		Attrs:     map[string]any{},
+	}
+	tmpl := d.sheetTemplate(attrs.SheetType)
+	values := canonicalSheetAttrs(attrs, tmpl)
+	for key, value := range values {
+		// 只以别名存在的属性也改用标准名
+		if canonicalKey, err := resolveSheetAttrKey(key, tmpl); err == nil && canonicalKey != "" {
+			if _, exists := values[canonicalKey]; !exists {
+				key = canonicalKey
+			}
+		}
+		doc.Attrs[key] = sheetValueToAny(value)
+	}
+	return doc
+}
+
+// SheetExport 按指定格式输出人物卡文档，返回文件内容与扩展名
+func (d *Dice) SheetExport(doc *SheetDocument, format string) ([]byte, string, error) {
+	c, ok := GetSheetConverter(format)
+	if !ok {
+		return nil, "", fmt.Errorf("未知的人物卡格式 %s，可用格式: %s", format, SheetFormatNames())
+	}
+	if t := c.SheetType(); t != "" && doc.SheetType != "" && t != doc.SheetType {
+		return nil, "", fmt.Errorf("%s 格式只能导出 %s 规则的人物卡，当前卡为 %s", c.Name(), t, doc.SheetType)
+	}
+	data, err := c.Export(doc, d.sheetTemplate(doc.SheetType))
+	if err != nil {
+		return nil, "", err
+	}
+	return data, c.Ext(), nil
+}
+
+// SheetImport 读取外部人物卡。format 为空时自动识别，defaultSheetType 用于不限规则的格式
+func (d *Dice) SheetImport(data []byte, filename, format, defaultSheetType string) (*SheetDocument, error) {
+	var c SheetConverter
+	var ok bool
+	if format != "" {
+		if c, ok = GetSheetConverter(format); !ok {
+			return nil, fmt.Errorf("未知的人物卡格式 %s，可用格式: %s", format, SheetFormatNames())
+		}
+	} else if c, ok = DetectSheetConverter(filename, data); !ok {
+		return nil, fmt.Errorf("%w，可用格式: %s", ErrSheetFormatUnknown, SheetFormatNames())
+	}
+
+	sheetType := c.SheetType()
+	if sheetType == "" {
+		sheetType = defaultSheetType
+	}
+	doc, err := c.Import(data, d.sheetTemplate(sheetType))
+	if err != nil {
+		return nil, err
+	}
+	if doc.SheetType == "" {
+		doc.SheetType = sheetType
+	}
+	if len(doc.Attrs) == 0 {
+		return nil, errors.New("人物卡中没有读到任何属性")
+	}
+	return doc, nil
+}
+
+// SheetApplyDocument 用文档内容替换卡片上的属性，卡片类型随文档变化
+func (d *Dice) SheetApplyDocument(attrs *AttributesItem, doc *SheetDocument) error {
+	tmpl := d.sheetTemplate(doc.SheetType)
+	// 先在空卡上转换，出错时不影响原卡
+	tmp := &AttributesItem{valueMap: &ds.ValueMap{}}
+	if err := applySheetAttrs(tmp, tmpl, doc.Attrs); err != nil {
+		return err
+	}
+	attrs.Clear()
+	tmp.Range(func(key string, value *ds.VMValue) bool {
+		attrs.Store(key, value)
+		return true
+	})
+	if doc.SheetType != "" {
+		attrs.SetSheetType(doc.SheetType)
+	}
+	return nil
+}
+
+// sheetImportSource 要导入的人物卡来源：参数中的网址，或消息中的第一个文件。fromArg 表示网址由发送者给出
+func sheetImportSource(msg *Message, arg string) (url string, stream io.Reader, filename string, fromArg bool, ok bool) {
+	if strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://") {
+		return arg, nil, path.Base(arg), true, true
+	}
+	for _, elem := range msg.Segment {
+		if e, isFile := elem.(*message.FileElement); isFile {
+			return e.URL, e.Stream, e.File, false, true
+		}
+	}
+	return "", nil, "", false, false
+}
+
+// sheetFetch 读取要导入的人物卡数据，只接受网址、base64 与适配器给出的数据流，不读取本地路径。
+// public 为真时网址来自发送者，只允许访问公网地址
+func sheetFetch(ctx context.Context, url string, stream io.Reader, public bool) ([]byte, error) {
+	var body io.Reader
+	switch {
+	case stream != nil:
+		body = stream
+	case strings.HasPrefix(url, "base64://"):
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(url, "base64://"))
+		if err != nil {
+			return nil, err
+		}
+		body = strings.NewReader(string(data))
+	case strings.HasPrefix(url, "http://"), strings.HasPrefix(url, "https://"):
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, ErrSheetDownload
+		}
+		client := http.DefaultClient
+		if public {
+			client = sheetPublicClient
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, ErrSheetDownload
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			return nil, ErrSheetDownload
+		}
+		body = resp.Body
+	default:
+		return nil, ErrSheetSource
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, SheetImportMaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > SheetImportMaxBytes {
+		return nil, fmt.Errorf("人物卡文件超过 %dMB", SheetImportMaxBytes>>20)
+	}
+	return data, nil
+}
+
+// sheetSortedKeys 按键名排序，保证导出结果稳定
+func sheetSortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
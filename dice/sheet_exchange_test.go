@@ -0,0 +1,254 @@
+//nolint:testpackage
+package dice
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	ds "github.com/sealdice/dicescript"
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func newSheetTestDice(t *testing.T) *Dice {
+	t.Helper()
+	d := &Dice{GameSystemMap: new(SyncMap[string, *GameSystemTemplate])}
+	for _, name := range []string{"coc7.yaml", "dnd5e.yaml"} {
+		tmpl, err := loadBuiltinTemplate(name)
+		if err != nil {
+			t.Fatalf("loadBuiltinTemplate(%s): %v", name, err)
+		}
+		d.GameSystemTemplateAdd(tmpl)
+	}
+	return d
+}
+
+func newSheetTestAttrs(sheetType string) *AttributesItem {
+	return &AttributesItem{valueMap: &ds.ValueMap{}, SheetType: sheetType}
+}
+
+func sheetTestValue(t *testing.T, attrs *AttributesItem, key string) string {
+	t.Helper()
+	v, ok := attrs.LoadX(key)
+	if !ok {
+		t.Fatalf("attribute %s missing", key)
+	}
+	return v.ToString()
+}
+
+func TestSheetJSONRoundTrip(t *testing.T) {
+	d := newSheetTestDice(t)
+	src := newSheetTestAttrs("coc7")
+	src.Name = "阿尔法"
+	src.Store("str", ds.NewIntVal(50)) // 别名，导出时应变为标准名
+	src.Store("侦查", ds.NewIntVal(60))
+	src.Store("备注", ds.NewStrVal("左撇子"))
+	src.Store("计算", ds.NewComputedVal("力量 + 1"))
+
+	data, ext, err := d.SheetExport(d.SheetDocumentFromAttrs(src), "json")
+	if err != nil {
+		t.Fatalf("SheetExport: %v", err)
+	}
+	if ext != ".json" || !bytes.Contains(data, []byte(`"力量": 50`)) {
+		t.Fatalf("unexpected export %s:\n%s", ext, data)
+	}
+
+	doc, err := d.SheetImport(data, "", "", "dnd5e")
+	if err != nil {
+		t.Fatalf("SheetImport: %v", err)
+	}
+	if doc.Name != "阿尔法" || doc.SheetType != "coc7" {
+		t.Fatalf("doc = %+v", doc)
+	}
+	dst := newSheetTestAttrs("")
+	dst.Store("旧属性", ds.NewIntVal(1))
+	if err = d.SheetApplyDocument(dst, doc); err != nil {
+		t.Fatalf("SheetApplyDocument: %v", err)
+	}
+	if dst.SheetType != "coc7" || dst.Len() != 4 {
+		t.Fatalf("sheetType=%s len=%d", dst.SheetType, dst.Len())
+	}
+	if got := sheetTestValue(t, dst, "力量"); got != "50" {
+		t.Fatalf("力量 = %s", got)
+	}
+	if got := sheetTestValue(t, dst, "备注"); got != "左撇子" {
+		t.Fatalf("备注 = %s", got)
+	}
+	if v, _ := dst.LoadX("计算"); v.TypeId != ds.VMTypeComputedValue {
+		t.Fatalf("计算 should stay computed, got %s", v.ToRepr())
+	}
+}
+
+func TestSheetCoc7TableImport(t *testing.T) {
+	d := newSheetTestDice(t)
+	// 表头一行、数值一行的属性区，加上带"成功率"列的技能表
+	csvText := "姓名,约翰,,\n" +
+		"力量,体质,敏捷,\n" +
+		"50,60,70,\n" +
+		"技能,基础,职业,成功率\n" +
+		"侦查,25,30,55\n" +
+		"图書館使用,20,0,20\n" +
+		"序号,1,,\n"
+
+	for name, data := range map[string][]byte{
+		"utf8": []byte("\xef\xbb\xbf" + csvText),
+		"gbk":  sheetTestMust(simplifiedchinese.GB18030.NewEncoder().Bytes([]byte(csvText))),
+	} {
+		t.Run(name, func(t *testing.T) {
+			doc, err := d.SheetImport(data, "card.csv", "", "dnd5e")
+			if err != nil {
+				t.Fatalf("SheetImport: %v", err)
+			}
+			if doc.Name != "约翰" || doc.SheetType != "coc7" {
+				t.Fatalf("doc = %+v", doc)
+			}
+			want := map[string]int64{"力量": 50, "体质": 60, "敏捷": 70, "侦查": 55, "图书馆使用": 20}
+			if len(doc.Attrs) != len(want) {
+				t.Fatalf("attrs = %v", doc.Attrs)
+			}
+			for k, v := range want {
+				if doc.Attrs[k] != v {
+					t.Fatalf("%s = %v, want %d (attrs %v)", k, doc.Attrs[k], v, doc.Attrs)
+				}
+			}
+		})
+	}
+}
+
+func sheetTestMust(data []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func TestSheetCoc7XlsxImportAndCsvExport(t *testing.T) {
+	d := newSheetTestDice(t)
+	f := excelize.NewFile()
+	for cell, value := range map[string]any{"A1": "调查员姓名", "B1": "玛丽", "A2": "STR", "B2": 65, "C2": "SAN", "D2": 55} {
+		_ = f.SetCellValue("Sheet1", cell, value)
+	}
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatalf("write xlsx: %v", err)
+	}
+
+	doc, err := d.SheetImport(buf.Bytes(), "", "", "")
+	if err != nil {
+		t.Fatalf("SheetImport: %v", err)
+	}
+	if doc.Name != "玛丽" || doc.Attrs["力量"] != int64(65) || doc.Attrs["理智"] != int64(55) {
+		t.Fatalf("doc = %+v", doc)
+	}
+
+	attrs := newSheetTestAttrs("")
+	if err = d.SheetApplyDocument(attrs, doc); err != nil {
+		t.Fatalf("SheetApplyDocument: %v", err)
+	}
+	attrs.Name = doc.Name
+	data, ext, err := d.SheetExport(d.SheetDocumentFromAttrs(attrs), "coc7")
+	if err != nil {
+		t.Fatalf("SheetExport: %v", err)
+	}
+	text := strings.TrimPrefix(string(data), "\xef\xbb\xbf")
+	if ext != ".csv" || text != "属性,数值\n姓名,玛丽\n力量,65\n理智,55\n" {
+		t.Fatalf("export %s = %q", ext, text)
+	}
+	if _, _, err = d.SheetExport(d.SheetDocumentFromAttrs(attrs), "dnd5e"); err == nil {
+		t.Fatal("exporting a coc7 sheet as dnd5e should fail")
+	}
+}
+
+func TestSheetDnd5eFoundryImport(t *testing.T) {
+	d := newSheetTestDice(t)
+	data := []byte(`{
+		"name": "Tordek",
+		"type": "character",
+		"system": {
+			"abilities": {
+				"str": {"value": 16, "proficient": 1},
+				"dex": {"value": 12},
+				"con": {"value": 14, "proficient": 1}
+			},
+			"attributes": {"hp": {"value": 20, "max": 24}, "ac": {"flat": 16}},
+			"details": {},
+			"skills": {"ath": {"value": 1}, "prc": {"value": 0.5}, "ste": {"value": 0}}
+		},
+		"items": [{"type": "class", "system": {"levels": 5}}]
+	}`)
+
+	doc, err := d.SheetImport(data, "tordek.json", "", "coc7")
+	if err != nil {
+		t.Fatalf("SheetImport: %v", err)
+	}
+	if doc.Name != "Tordek" || doc.SheetType != "dnd5e" {
+		t.Fatalf("doc = %+v", doc)
+	}
+	attrs := newSheetTestAttrs("")
+	if err = d.SheetApplyDocument(attrs, doc); err != nil {
+		t.Fatalf("SheetApplyDocument: %v", err)
+	}
+	for key, want := range map[string]string{
+		"力量": "16", "敏捷": "12", "$stp_力量": "1", "hp": "20", "hpmax": "24", "ac": "16", "熟练": "3",
+	} {
+		if got := sheetTestValue(t, attrs, key); got != want {
+			t.Fatalf("%s = %s, want %s", key, got, want)
+		}
+	}
+	if _, ok := attrs.LoadX("隐匿"); ok {
+		t.Fatal("skills without proficiency should be left to the template")
+	}
+	athletics, _ := attrs.LoadX("运动")
+	cd, ok := athletics.ReadComputed()
+	if !ok {
+		t.Fatalf("运动 should be a computed skill, got %s", athletics.ToRepr())
+	}
+	if factor, _ := cd.Attrs.Load("factor"); factor.ToString() != "1" {
+		t.Fatalf("运动 factor = %s", factor.ToString())
+	}
+
+	attrs.Name = doc.Name
+	out, _, err := d.SheetExport(d.SheetDocumentFromAttrs(attrs), "dnd5e")
+	if err != nil {
+		t.Fatalf("SheetExport: %v", err)
+	}
+	again, err := d.SheetImport(out, "", "dnd5e", "")
+	if err != nil {
+		t.Fatalf("re-import: %v", err)
+	}
+	for _, key := range []string{"力量", "hp", "ac", "熟练", "$stp_体质"} {
+		if again.Attrs[key] != doc.Attrs[key] {
+			t.Fatalf("round trip %s = %v, want %v", key, again.Attrs[key], doc.Attrs[key])
+		}
+	}
+}
+
+func TestSheetImportDetectsUnknownFormat(t *testing.T) {
+	d := newSheetTestDice(t)
+	if _, err := d.SheetImport([]byte(`{"foo": 1}`), "x.json", "", "coc7"); err == nil {
+		t.Fatal("expected unknown format error")
+	}
+	if _, err := sheetFetch(t.Context(), "/etc/passwd", nil, false); err == nil {
+		t.Fatal("local paths must not be read")
+	}
+}
+
+func TestSheetFetchBlocksPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"name": "阿尔法"}`))
+	}))
+	defer srv.Close()
+
+	// 玩家给出的网址不能指向本机
+	if _, err := sheetFetch(t.Context(), srv.URL, nil, true); !errors.Is(err, ErrSheetDownload) {
+		t.Fatalf("public fetch of loopback error = %v, want ErrSheetDownload", err)
+	}
+	// 平台给出的附件地址不受限制
+	if data, err := sheetFetch(t.Context(), srv.URL, nil, false); err != nil || len(data) == 0 {
+		t.Fatalf("attachment fetch = %q, %v", data, err)
+	}
+}
@@ -747,6 +747,75 @@ func (m *StoreManager) FindPackage(id, version string) (*StorePackage, bool) {
 	return pkg, true
 }
 
+// ErrStorePackageNotFound 商店中没有满足条件的扩展包
+var ErrStorePackageNotFound = errors.New("商店中未找到扩展包")
+
+const (
+	storeResolvePageSize = 50
+	storeResolveMaxPages = 10
+)
+
+// ResolvePackage 在当前商店后端中查找满足全部版本约束、且兼容当前海豹版本的最高版本。
+// 查询使用包 ID 的作者与包名作为 /page 的 author/name 过滤条件，结果会写入缓存以便后续下载。
+func (m *StoreManager) ResolvePackage(id string, constraints []string) (*StorePackage, error) {
+	author, name, err := sealpack.ParsePackageID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		best        *StorePackage
+		bestVersion *semver.Version
+		matched     []*StorePackage
+	)
+	for pageNum := 1; pageNum <= storeResolveMaxPages; pageNum++ {
+		page, err := m.StoreQueryPage(StoreQueryPageParams{
+			Author:   author,
+			Name:     name,
+			PageNum:  pageNum,
+			PageSize: storeResolvePageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, pkg := range page.Data {
+			if pkg.ID != id || !storePackageSatisfies(pkg, constraints) {
+				continue
+			}
+			version, err := semver.NewVersion(pkg.Version)
+			if err != nil {
+				continue
+			}
+			matched = append(matched, pkg)
+			if bestVersion == nil || version.GreaterThan(bestVersion) {
+				best, bestVersion = pkg, version
+			}
+		}
+		if !page.Next {
+			break
+		}
+	}
+
+	if best == nil {
+		if len(constraints) == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrStorePackageNotFound, id)
+		}
+		return nil, fmt.Errorf("%w: %s (需要 %s)", ErrStorePackageNotFound, id, strings.Join(constraints, ", "))
+	}
+	m.RefreshInstalled(matched)
+	return best, nil
+}
+
+func storePackageSatisfies(pkg *StorePackage, constraints []string) bool {
+	for _, constraint := range constraints {
+		if ok, err := sealpack.CheckDependencyConstraint(constraint, pkg.Version); err != nil || !ok {
+			return false
+		}
+	}
+	manifest := &sealpack.Manifest{Package: sealpack.PackageInfo{Seal: pkg.Seal}}
+	return sealpack.CheckSealVersion(manifest, VERSION.String()) == nil
+}
+
 type StoreUploadFormOption struct {
 	Key  string `json:"key"`
 	Desc string `json:"desc"`
@@ -0,0 +1,46 @@
+package dice
+
+import (
+	"net"
+
+	"sealdice-core/dice/storylog"
+	"sealdice-core/logger"
+	"sealdice-core/utils"
+)
+
+// StoryBackendDir 内置日志后端保存上传日志的目录，所有骰子共用
+var StoryBackendDir = "./data/story-backend"
+
+// InitStoryBackend 按 dice.yaml 中 storyLogBackend 的设置启用内置日志后端，接口挂载在 WebUI 上
+func (dm *DiceManager) InitStoryBackend() {
+	if !dm.StoryBackendConfig.Enable {
+		return
+	}
+	log := logger.M()
+	// 挂载在 WebUI 上的后端对外可见，不允许无 token 上传，未设置时自动生成一个并写回 dice.yaml
+	if dm.StoryBackendConfig.Token == "" {
+		dm.StoryBackendConfig.Token = utils.NewID()
+		dm.Save()
+		log.Info("内置日志后端未设置 token，已自动生成并保存到 dice.yaml，其他骰子使用此后端时需填写该 token")
+	}
+	backend, err := storylog.NewBackend(StoryBackendDir, dm.StoryBackendConfig)
+	if err != nil {
+		log.Errorf("内置日志后端启动失败: %v", err)
+		return
+	}
+	backend.RequireToken = true
+	dm.StoryBackend = backend
+	log.Infof("内置日志后端已启用，上传地址: %s", dm.StoryBackendUploadURL())
+	if dm.StoryBackendConfig.PublicURL == "" {
+		log.Warn("内置日志后端未设置 publicUrl，本机上传得到的链接只能在本机打开")
+	}
+}
+
+// StoryBackendUploadURL 本机访问内置日志后端的上传地址
+func (dm *DiceManager) StoryBackendUploadURL() string {
+	port := "3211"
+	if _, p, err := net.SplitHostPort(dm.ServeAddress); err == nil && p != "" {
+		port = p
+	}
+	return "http://127.0.0.1:" + port + storylog.BackendUploadPath
+}
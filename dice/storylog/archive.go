@@ -0,0 +1,295 @@
+package storylog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress/zstd"
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/model"
+	"sealdice-core/utils/dboperator/engine"
+)
+
+// 日志归档：将日志导出为 zstd 压缩的 Parquet 文件后从数据库中删除，需要时可以再导回。
+// 日志信息保存在 Parquet 的 key-value 元数据中，列表时只读取文件尾部。
+
+const (
+	ArchiveDirName = "log-archive"
+	ArchiveExt     = ".parquet"
+
+	archiveMetaPrefix = "sealdice.log."
+	restoreBatchSize  = 1000
+)
+
+// ErrLogChanged 归档期间日志又有新的消息，本次不删除
+var ErrLogChanged = errors.New("归档期间日志有更新，已放弃本次归档")
+
+type ArchiveInfo struct {
+	File       string `json:"file"`
+	FileSize   int64  `json:"fileSize"`
+	GroupID    string `json:"groupId"`
+	LogName    string `json:"logName"`
+	CreatedAt  int64  `json:"createdAt"`
+	UpdatedAt  int64  `json:"updatedAt"`
+	UploadURL  string `json:"uploadUrl"`
+	UploadTime int64  `json:"uploadTime"`
+	Items      int64  `json:"items"`
+	ArchivedAt int64  `json:"archivedAt"`
+}
+
+func (a *ArchiveInfo) metadata() []parquet.WriterOption {
+	kv := map[string]string{
+		"groupId":    a.GroupID,
+		"name":       a.LogName,
+		"createdAt":  strconv.FormatInt(a.CreatedAt, 10),
+		"updatedAt":  strconv.FormatInt(a.UpdatedAt, 10),
+		"uploadUrl":  a.UploadURL,
+		"uploadTime": strconv.FormatInt(a.UploadTime, 10),
+		"archivedAt": strconv.FormatInt(a.ArchivedAt, 10),
+	}
+	opts := make([]parquet.WriterOption, 0, len(kv))
+	for k, v := range kv {
+		opts = append(opts, parquet.KeyValueMetadata(archiveMetaPrefix+k, v))
+	}
+	return opts
+}
+
+func readArchiveInfo(f *parquet.File, file string) (*ArchiveInfo, error) {
+	get := func(key string) string {
+		v, _ := f.Lookup(archiveMetaPrefix + key)
+		return v
+	}
+	getInt := func(key string) int64 {
+		v, _ := strconv.ParseInt(get(key), 10, 64)
+		return v
+	}
+	a := &ArchiveInfo{
+		File:       file,
+		FileSize:   f.Size(),
+		GroupID:    get("groupId"),
+		LogName:    get("name"),
+		CreatedAt:  getInt("createdAt"),
+		UpdatedAt:  getInt("updatedAt"),
+		UploadURL:  get("uploadUrl"),
+		UploadTime: getInt("uploadTime"),
+		Items:      f.NumRows(),
+		ArchivedAt: getInt("archivedAt"),
+	}
+	if a.GroupID == "" || a.LogName == "" {
+		return nil, fmt.Errorf("%s 不是海豹的日志归档", file)
+	}
+	return a, nil
+}
+
+// ArchiveLog 将日志写入 dir 下的归档文件并从数据库删除
+func ArchiveLog(db engine.DatabaseOperator, dir string, info *model.LogInfo) (*ArchiveInfo, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	filename, _ := buildLogFilename(info.GroupID, info.Name, now, ArchiveExt)
+	archive := &ArchiveInfo{
+		File:       filename,
+		GroupID:    info.GroupID,
+		LogName:    info.Name,
+		CreatedAt:  info.CreatedAt,
+		UpdatedAt:  info.UpdatedAt,
+		UploadURL:  info.UploadURL,
+		UploadTime: int64(info.UploadTime),
+		ArchivedAt: now.Unix(),
+	}
+
+	path := filepath.Join(dir, filename)
+	tmpPath := path + ".tmp"
+	if err := writeArchive(db, tmpPath, archive); err != nil {
+		_ = os.Remove(tmpPath)
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return nil, err
+	}
+
+	deleted, err := service.LogDeleteIfUnchanged(db, info.ID, info.UpdatedAt)
+	if err != nil || !deleted {
+		_ = os.Remove(path)
+		if err == nil {
+			err = ErrLogChanged
+		}
+		return nil, err
+	}
+	if stat, statErr := os.Stat(path); statErr == nil {
+		archive.FileSize = stat.Size()
+	}
+	return archive, nil
+}
+
+func writeArchive(db engine.DatabaseOperator, path string, archive *ArchiveInfo) (err error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("创建归档文件失败: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("关闭归档文件失败: %w", closeErr)
+		}
+	}()
+
+	opts := append([]parquet.WriterOption{parquet.Compression(&zstd.Codec{})}, archive.metadata()...)
+	writer := parquet.NewGenericWriter[model.LogOneItemParquet](f, opts...)
+	cursor := paginator.Cursor{}
+	for {
+		lines, next, err := service.LogGetExportCursorLines(db, archive.GroupID, archive.LogName, cursor)
+		if err != nil {
+			return err
+		}
+		if _, err = writer.Write(lines); err != nil {
+			return fmt.Errorf("写入归档文件失败: %w", err)
+		}
+		archive.Items += int64(len(lines))
+		if next.After == nil {
+			break
+		}
+		cursor.After = next.After
+	}
+	if err = writer.Close(); err != nil {
+		return fmt.Errorf("写入归档文件失败: %w", err)
+	}
+	return f.Sync()
+}
+
+// ListArchives 列出 dir 下的全部归档，按归档时间从新到旧排列
+func ListArchives(dir string) ([]*ArchiveInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []*ArchiveInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*ArchiveInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ArchiveExt) {
+			continue
+		}
+		archive, err := statArchive(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			// 损坏或无关的文件仍然列出，便于手动处理
+			info, infoErr := entry.Info()
+			if infoErr != nil {
+				continue
+			}
+			archive = &ArchiveInfo{File: entry.Name(), FileSize: info.Size()}
+		}
+		res = append(res, archive)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].ArchivedAt > res[j].ArchivedAt
+	})
+	return res, nil
+}
+
+func statArchive(path string) (*ArchiveInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	pf, err := parquet.OpenFile(f, stat.Size())
+	if err != nil {
+		return nil, err
+	}
+	return readArchiveInfo(pf, filepath.Base(path))
+}
+
+// archivePath 校验文件名，避免访问归档目录以外的文件
+func archivePath(dir string, file string) (string, error) {
+	if file == "" || filepath.Base(file) != file || !strings.HasSuffix(file, ArchiveExt) {
+		return "", fmt.Errorf("归档文件名无效: %q", file)
+	}
+	return filepath.Join(dir, file), nil
+}
+
+// RestoreArchive 将归档导回数据库，成功后删除归档文件。数据库中已有同名日志时返回 service.ErrLogExists
+func RestoreArchive(db engine.DatabaseOperator, dir string, file string) (*ArchiveInfo, error) {
+	path, err := archivePath(dir, file)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := restoreFromFile(db, path)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Remove(path); err != nil {
+		return archive, fmt.Errorf("日志已恢复，但删除归档文件失败: %w", err)
+	}
+	return archive, nil
+}
+
+func restoreFromFile(db engine.DatabaseOperator, path string) (*ArchiveInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	pf, err := parquet.OpenFile(f, stat.Size())
+	if err != nil {
+		return nil, fmt.Errorf("读取归档文件失败: %w", err)
+	}
+	archive, err := readArchiveInfo(pf, filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+
+	reader := parquet.NewGenericReader[model.LogOneItemParquet](pf)
+	defer func() { _ = reader.Close() }()
+	buf := make([]model.LogOneItemParquet, restoreBatchSize)
+	done := false
+	next := func() ([]model.LogOneItemParquet, error) {
+		if done {
+			return nil, nil
+		}
+		n, readErr := reader.Read(buf)
+		if errors.Is(readErr, io.EOF) {
+			done = true
+		} else if readErr != nil {
+			return nil, fmt.Errorf("读取归档文件失败: %w", readErr)
+		}
+		return buf[:n], nil
+	}
+
+	info := &model.LogInfo{
+		Name:       archive.LogName,
+		GroupID:    archive.GroupID,
+		CreatedAt:  archive.CreatedAt,
+		UpdatedAt:  archive.UpdatedAt,
+		UploadURL:  archive.UploadURL,
+		UploadTime: int(archive.UploadTime),
+	}
+	if _, err = service.LogRestore(db, info, next); err != nil {
+		if errors.Is(err, service.ErrLogExists) {
+			return nil, fmt.Errorf("群 %s 中已有同名日志 <%s>，请先改名或删除后再恢复: %w", info.GroupID, info.Name, err)
+		}
+		return nil, err
+	}
+	return archive, nil
+}
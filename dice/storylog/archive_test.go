@@ -0,0 +1,109 @@
+package storylog_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/dice/storylog"
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	"sealdice-core/utils/dboperator"
+)
+
+func TestArchiveAndRestoreLog(t *testing.T) {
+	op, err := dboperator.OpenEngine(context.Background(), constant.SQLITE, t.TempDir())
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(op.Close)
+	if err = op.GetLogDB(constant.WRITE).AutoMigrate(&model.LogInfo{}, &model.LogOneItem{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		ok := service.LogAppend(op, "QQ-Group:1", "旧团", &model.LogOneItem{
+			Nickname:    "KP",
+			IMUserID:    "QQ:1",
+			Message:     "message",
+			CommandInfo: map[string]interface{}{"cmd": "ra"},
+			RawMsgID:    i + 100,
+		})
+		if !ok {
+			t.Fatalf("append %d failed", i)
+		}
+	}
+	if err = service.LogSetUploadInfo(op, "QQ-Group:1", "旧团", "https://example.com/x"); err != nil {
+		t.Fatal(err)
+	}
+	// 使用明显早于当前的更新时间，恢复时若刷新了 updated_at 会被发现
+	if err = op.GetLogDB(constant.WRITE).Model(&model.LogInfo{}).Where("name = ?", "旧团").
+		UpdateColumn("updated_at", 1600000000).Error; err != nil {
+		t.Fatal(err)
+	}
+	info, err := service.LogGetInfoByName(op, "QQ-Group:1", "旧团")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	archive, err := storylog.ArchiveLog(op, dir, info)
+	if err != nil {
+		t.Fatalf("ArchiveLog: %v", err)
+	}
+	if archive.Items != 5 {
+		t.Errorf("archived %d items, want 5", archive.Items)
+	}
+	if _, err = service.LogGetInfoByName(op, "QQ-Group:1", "旧团"); !errors.Is(err, service.ErrLogNotFound) {
+		t.Fatalf("log should be deleted after archive, got %v", err)
+	}
+
+	list, err := storylog.ListArchives(dir)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("ListArchives = %v, %v", list, err)
+	}
+	if list[0].LogName != "旧团" || list[0].GroupID != "QQ-Group:1" || list[0].Items != 5 || list[0].UploadURL == "" {
+		t.Errorf("unexpected archive info: %+v", list[0])
+	}
+
+	if _, err = storylog.RestoreArchive(op, dir, "../"+list[0].File); err == nil {
+		t.Fatalf("path outside archive dir should be rejected")
+	}
+	if _, err = storylog.RestoreArchive(op, dir, list[0].File); err != nil {
+		t.Fatalf("RestoreArchive: %v", err)
+	}
+	restored, err := service.LogGetInfoByName(op, "QQ-Group:1", "旧团")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.UpdatedAt != 1600000000 || restored.UploadURL != info.UploadURL || restored.Size == nil || *restored.Size != 5 {
+		t.Errorf("restored info = %+v, want %+v", restored, info)
+	}
+	lines, err := service.LogGetAllLines(op, "QQ-Group:1", "旧团")
+	if err != nil || len(lines) != 5 {
+		t.Fatalf("restored lines = %d, %v", len(lines), err)
+	}
+	if lines[0].CommandInfoStr != `{"cmd":"ra"}` {
+		t.Errorf("command info = %q", lines[0].CommandInfoStr)
+	}
+	// 恢复后仍能按消息 ID 编辑与撤回
+	if err = service.LogEditByMsgID(op, "QQ-Group:1", "旧团", "edited", 101); err != nil {
+		t.Fatalf("LogEditByMsgID: %v", err)
+	}
+	if err = service.LogMarkDeleteByMsgID(op, "QQ-Group:1", "旧团", 102); err != nil {
+		t.Fatalf("LogMarkDeleteByMsgID: %v", err)
+	}
+	lines, _ = service.LogGetAllLines(op, "QQ-Group:1", "旧团")
+	edited := 0
+	for _, line := range lines {
+		if line.Message == "edited" {
+			edited++
+		}
+	}
+	if len(lines) != 4 || edited != 1 {
+		t.Errorf("after edit/delete by msg id: %d lines, %d edited", len(lines), edited)
+	}
+	if list, _ = storylog.ListArchives(dir); len(list) != 0 {
+		t.Errorf("archive file should be removed after restore")
+	}
+}
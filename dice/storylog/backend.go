@@ -0,0 +1,376 @@
+package storylog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress/zstd"
+
+	"sealdice-core/model"
+	"sealdice-core/utils"
+)
+
+// 内置日志后端：实现与公共日志服务相同的上传接口（V1 与 V1.5），把日志保存在本地磁盘，
+// 并通过 /log/<key> 提供查看页面。可以随海豹一起挂载在 WebUI 上，也可以用 story-backend 子命令单独运行。
+
+const (
+	// BackendUploadPath 上传接口，与公共日志服务相同
+	BackendUploadPath = "/dice/api/log"
+	// BackendViewPath 查看页面的前缀
+	BackendViewPath = "/log/"
+
+	backendMetaPrefix     = "sealdice.story."
+	defaultMaxUploadBytes = 64 << 20
+)
+
+var backendKeyRe = regexp.MustCompile(`^[0-9A-Za-z]{22}$`)
+
+// BackendConfig 内置日志后端设置
+type BackendConfig struct {
+	Enable bool `json:"enable" yaml:"enable"`
+	// PublicURL 分享链接使用的地址，如 https://log.example.com；留空时使用上传请求的 Host
+	PublicURL string `json:"publicUrl" yaml:"publicUrl"`
+	// Token 上传时需要携带的 Bearer token，留空则不校验；挂载在 WebUI 上时留空会自动生成
+	Token string `json:"token" yaml:"token"` //nolint:gosec
+	// MaxUploadMB 单次上传的大小上限，0 为默认的 64MB
+	MaxUploadMB int `json:"maxUploadMb" yaml:"maxUploadMb"`
+	// TrustForwardedProto 位于反向代理之后时开启，生成链接时采用 X-Forwarded-Proto 中的协议
+	TrustForwardedProto bool `json:"trustForwardedProto" yaml:"trustForwardedProto"`
+}
+
+// StoredLog 后端中保存的一份日志
+type StoredLog struct {
+	Key        string `json:"key"`
+	Name       string `json:"name"`
+	UniformID  string `json:"uniformId"`
+	Version    int    `json:"version"`
+	Items      int64  `json:"items"`
+	UploadedAt int64  `json:"uploadedAt"`
+}
+
+// Backend 内置日志后端
+type Backend struct {
+	Dir    string
+	Config BackendConfig
+	// Media 与骰子共用的附件目录，查看页面据此显示图片；独立部署时为空
+	Media *MediaStore
+	// RequireToken 为真时未设置 Token 会拒绝所有上传，挂载在 WebUI 上时使用
+	RequireToken bool
+
+	mu sync.Mutex
+}
+
+// NewBackend 使用 dir 保存上传的日志
+func NewBackend(dir string, cfg BackendConfig) (*Backend, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Backend{Dir: dir, Config: cfg}, nil
+}
+
+// Handler 上传接口与查看页面
+func (b *Backend) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT "+BackendUploadPath, b.handleUpload)
+	mux.HandleFunc("POST "+BackendUploadPath, b.handleUpload)
+	mux.HandleFunc("GET "+BackendViewPath+"{key}", b.handleView)
+	return mux
+}
+
+func backendError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+func (b *Backend) authorized(r *http.Request) bool {
+	if b.Config.Token == "" {
+		return !b.RequireToken
+	}
+	got := r.Header.Get("Authorization")
+	return subtle.ConstantTimeCompare([]byte(got), []byte("Bearer "+b.Config.Token)) == 1
+}
+
+func (b *Backend) publicURL(r *http.Request) string {
+	if b.Config.PublicURL != "" {
+		return strings.TrimRight(b.Config.PublicURL, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if b.Config.TrustForwardedProto {
+		switch proto := r.Header.Get("X-Forwarded-Proto"); proto {
+		case "http", "https":
+			scheme = proto
+		}
+	}
+	return scheme + "://" + r.Host
+}
+
+func (b *Backend) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if !b.authorized(r) {
+		backendError(w, http.StatusUnauthorized, "token 无效")
+		return
+	}
+	maxBytes := int64(defaultMaxUploadBytes)
+	if b.Config.MaxUploadMB > 0 {
+		maxBytes = int64(b.Config.MaxUploadMB) << 20
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	if err := r.ParseMultipartForm(8 << 20); err != nil {
+		backendError(w, http.StatusBadRequest, "上传内容无效: "+err.Error())
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		backendError(w, http.StatusBadRequest, "缺少日志文件")
+		return
+	}
+	defer func() { _ = file.Close() }()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		backendError(w, http.StatusBadRequest, "读取日志文件失败: "+err.Error())
+		return
+	}
+
+	version, _ := strconv.Atoi(r.FormValue("version"))
+	items, err := decodeUpload(version, r.FormValue("client"), data)
+	if err != nil {
+		backendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	stored, err := b.Save(&StoredLog{
+		Name:      r.FormValue("name"),
+		UniformID: r.FormValue("uniform_id"),
+		Version:   version,
+	}, items)
+	if err != nil {
+		backendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"url": b.publicURL(r) + BackendViewPath + stored.Key,
+	})
+}
+
+// decodeUpload 解析上传的日志：V1.5 为 Parquet，V1 为 zlib 压缩的 JSON
+func decodeUpload(version int, client string, data []byte) ([]model.LogOneItemParquet, error) {
+	if StoryVersion(version) == StoryVersionV105 || client == "Parquet" {
+		items, err := parquet.Read[model.LogOneItemParquet](bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("解析 Parquet 日志失败: %w", err)
+		}
+		return items, nil
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解压日志失败: %w", err)
+	}
+	defer func() { _ = zr.Close() }()
+	var doc struct {
+		Items []model.LogOneItem `json:"items"`
+	}
+	if err = json.NewDecoder(zr).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("解析日志失败: %w", err)
+	}
+	items := make([]model.LogOneItemParquet, 0, len(doc.Items))
+	for _, item := range doc.Items {
+		p := model.LogOneItemParquet{
+			ID:        item.ID,
+			Nickname:  item.Nickname,
+			IMUserID:  item.IMUserID,
+			Time:      item.Time,
+			Message:   item.Message,
+			IsDice:    item.IsDice,
+			CommandID: item.CommandID,
+			UniformID: item.UniformID,
+		}
+		if item.CommandInfo != nil {
+			if info, errInfo := json.Marshal(item.CommandInfo); errInfo == nil {
+				p.CommandInfoStr = string(info)
+			}
+		}
+		if len(item.Media) > 0 {
+			if media, errMedia := json.Marshal(item.Media); errMedia == nil {
+				p.MediaStr = string(media)
+			}
+		}
+		items = append(items, p)
+	}
+	return items, nil
+}
+
+func (b *Backend) path(key string) string {
+	return filepath.Join(b.Dir, key+ArchiveExt)
+}
+
+// Save 保存日志并分配 key
+func (b *Backend) Save(meta *StoredLog, items []model.LogOneItemParquet) (*StoredLog, error) {
+	if len(items) == 0 {
+		return nil, errors.New("日志为空")
+	}
+	stored := *meta
+	stored.Key = utils.NewID()
+	stored.Items = int64(len(items))
+	stored.UploadedAt = time.Now().Unix()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	path := b.path(stored.Key)
+	tmpPath := path + ".tmp"
+	if err := writeStoredLog(tmpPath, &stored, items); err != nil {
+		_ = os.Remove(tmpPath)
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return nil, err
+	}
+	return &stored, nil
+}
+
+func writeStoredLog(path string, stored *StoredLog, items []model.LogOneItemParquet) (err error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("保存日志失败: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("保存日志失败: %w", closeErr)
+		}
+	}()
+	writer := parquet.NewGenericWriter[model.LogOneItemParquet](f,
+		parquet.Compression(&zstd.Codec{}),
+		parquet.KeyValueMetadata(backendMetaPrefix+"name", stored.Name),
+		parquet.KeyValueMetadata(backendMetaPrefix+"uniformId", stored.UniformID),
+		parquet.KeyValueMetadata(backendMetaPrefix+"version", strconv.Itoa(stored.Version)),
+		parquet.KeyValueMetadata(backendMetaPrefix+"uploadedAt", strconv.FormatInt(stored.UploadedAt, 10)),
+	)
+	if _, err = writer.Write(items); err != nil {
+		return fmt.Errorf("保存日志失败: %w", err)
+	}
+	if err = writer.Close(); err != nil {
+		return fmt.Errorf("保存日志失败: %w", err)
+	}
+	return f.Sync()
+}
+
+// Load 读取保存的日志，key 不存在时返回 os.ErrNotExist
+func (b *Backend) Load(key string) (*StoredLog, []model.LogOneItem, error) {
+	if !backendKeyRe.MatchString(key) {
+		return nil, nil, os.ErrNotExist
+	}
+	f, err := os.Open(b.path(key))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = f.Close() }()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	pf, err := parquet.OpenFile(f, stat.Size())
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取日志失败: %w", err)
+	}
+
+	get := func(k string) string {
+		v, _ := pf.Lookup(backendMetaPrefix + k)
+		return v
+	}
+	stored := &StoredLog{Key: key, Name: get("name"), UniformID: get("uniformId"), Items: pf.NumRows()}
+	stored.Version, _ = strconv.Atoi(get("version"))
+	stored.UploadedAt, _ = strconv.ParseInt(get("uploadedAt"), 10, 64)
+
+	reader := parquet.NewGenericReader[model.LogOneItemParquet](pf)
+	defer func() { _ = reader.Close() }()
+	rows := make([]model.LogOneItemParquet, pf.NumRows())
+	n, err := reader.Read(rows)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, fmt.Errorf("读取日志失败: %w", err)
+	}
+	rows = rows[:n]
+
+	items := make([]model.LogOneItem, 0, len(rows))
+	for _, row := range rows {
+		item := model.LogOneItem{
+			ID:        row.ID,
+			Nickname:  row.Nickname,
+			IMUserID:  row.IMUserID,
+			Time:      row.Time,
+			Message:   row.Message,
+			IsDice:    row.IsDice,
+			CommandID: row.CommandID,
+			UniformID: row.UniformID,
+			Media:     model.ParseLogMedia(row.MediaStr),
+		}
+		if row.CommandInfoStr != "" {
+			_ = json.Unmarshal([]byte(row.CommandInfoStr), &item.CommandInfo)
+		}
+		items = append(items, item)
+	}
+	return stored, items, nil
+}
+
+// handleView 默认以 HTML 展示，?format= 可下载其他格式，?hideOoc=1 去掉场外发言
+func (b *Backend) handleView(w http.ResponseWriter, r *http.Request) {
+	stored, items, err := b.Load(r.PathValue("key"))
+	if errors.Is(err, os.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		backendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	opts := ExportOptions{Format: ExportFormatHTML, HideOOC: r.URL.Query().Get("hideOoc") != "", Media: b.Media}
+	if name := r.URL.Query().Get("format"); name != "" {
+		format, ok := ParseExportFormat(name)
+		if !ok {
+			backendError(w, http.StatusBadRequest, "不支持的导出格式: "+name)
+			return
+		}
+		opts.Format = format
+	}
+
+	w.Header().Set("Content-Type", opts.Format.ContentType())
+	if opts.Format != ExportFormatHTML {
+		filename := stored.Name + opts.Format.Ext()
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	}
+	bw := bufio.NewWriter(w)
+	exporter, err := NewExporter(bw, ExportMeta{LogName: stored.Name, ExportedAt: stored.UploadedAt}, opts)
+	if err == nil {
+		err = exporter.Write(items)
+	}
+	if err == nil {
+		err = exporter.Close()
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,25 @@
+//nolint:testpackage
+package storylog
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBackendPublicURLForwardedProto(t *testing.T) {
+	r := httptest.NewRequest("PUT", "http://log.example.com"+BackendUploadPath, nil)
+	r.Header.Set("X-Forwarded-Proto", "https")
+
+	b := &Backend{}
+	if got := b.publicURL(r); got != "http://log.example.com" {
+		t.Errorf("untrusted proxy: publicURL = %q", got)
+	}
+	b.Config.TrustForwardedProto = true
+	if got := b.publicURL(r); got != "https://log.example.com" {
+		t.Errorf("trusted proxy: publicURL = %q", got)
+	}
+	r.Header.Set("X-Forwarded-Proto", "javascript")
+	if got := b.publicURL(r); got != "http://log.example.com" {
+		t.Errorf("bogus proto: publicURL = %q", got)
+	}
+}
@@ -0,0 +1,136 @@
+package storylog_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/dice/storylog"
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	"sealdice-core/utils/dboperator"
+)
+
+func TestBackendUploadAndView(t *testing.T) {
+	op, err := dboperator.OpenEngine(context.Background(), constant.SQLITE, t.TempDir())
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(op.Close)
+	if err = op.GetLogDB(constant.WRITE).AutoMigrate(&model.LogInfo{}, &model.LogOneItem{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range exportTestItems() {
+		item.ID = 0
+		if !service.LogAppend(op, "QQ-Group:1", "深潜者", &item) {
+			t.Fatal("append failed")
+		}
+	}
+
+	backend, err := storylog.NewBackend(t.TempDir(), storylog.BackendConfig{Enable: true, Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(backend.Handler())
+	t.Cleanup(srv.Close)
+
+	for _, version := range []storylog.StoryVersion{storylog.StoryVersionV1, storylog.StoryVersionV105} {
+		env := storylog.UploadEnv{
+			Dir:       t.TempDir(),
+			Db:        op,
+			Log:       zap.NewNop().Sugar(),
+			Backends:  []string{srv.URL + storylog.BackendUploadPath},
+			Version:   version,
+			LogName:   "深潜者",
+			UniformID: "QQ:1",
+			GroupID:   "QQ-Group:1",
+			Token:     "secret",
+		}
+		// 上传记录会使之后的上传直接复用链接，每次都先清掉
+		if err = service.LogSetUploadInfo(op, "QQ-Group:1", "深潜者", ""); err != nil {
+			t.Fatal(err)
+		}
+		url, _, errUpload := storylog.Upload(env)
+		if errUpload != nil {
+			t.Fatalf("upload v%d: %v", version, errUpload)
+		}
+		if !strings.HasPrefix(url, srv.URL+storylog.BackendViewPath) {
+			t.Fatalf("upload v%d returned url %q", version, url)
+		}
+
+		resp, errGet := http.Get(url) //nolint:gosec,noctx
+		if errGet != nil {
+			t.Fatal(errGet)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("view v%d: status %d %s", version, resp.StatusCode, body)
+		}
+		for _, want := range []string{"<title>深潜者</title>", "我要检查这扇&lt;门&gt;", "侦查 35/60 困难成功"} {
+			if !strings.Contains(string(body), want) {
+				t.Errorf("view v%d missing %q", version, want)
+			}
+		}
+	}
+}
+
+func TestBackendRejectsBadToken(t *testing.T) {
+	backend, err := storylog.NewBackend(t.TempDir(), storylog.BackendConfig{Enable: true, Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(backend.Handler())
+	t.Cleanup(srv.Close)
+
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+storylog.BackendUploadPath, strings.NewReader("")) //nolint:noctx
+	req.Header.Set("Authorization", "Bearer wrong")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL + storylog.BackendViewPath + "../../etc/passwd") //nolint:noctx
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestBackendRequireToken(t *testing.T) {
+	backend, err := storylog.NewBackend(t.TempDir(), storylog.BackendConfig{Enable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.RequireToken = true
+	srv := httptest.NewServer(backend.Handler())
+	t.Cleanup(srv.Close)
+
+	for _, auth := range []string{"", "Bearer "} {
+		req, _ := http.NewRequest(http.MethodPut, srv.URL+storylog.BackendUploadPath, strings.NewReader("")) //nolint:noctx
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, errDo := http.DefaultClient.Do(req)
+		if errDo != nil {
+			t.Fatal(errDo)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("auth %q: status = %d, want 401", auth, resp.StatusCode)
+		}
+	}
+}
@@ -8,7 +8,9 @@ const (
 	ExportJsonFilename    = "sealdice-standard-log.json"
 	ExportParquetFilename = "sealdice-standard-log.parquet"
 	ExportReadmeFilename  = "README.txt"
-	ExportReadmeContent   = ExportTxtFilename + ": 纯文本 Log\n" + ExportJsonFilename + ": 海豹标准 Log, 粘贴到染色器可格式化。若是Parquet的格式，暂时无法处理。\n"
+	ExportMediaDir        = "media/"
+	ExportReadmeContent   = ExportTxtFilename + ": 纯文本 Log\n" + ExportJsonFilename + ": 海豹标准 Log, 粘贴到染色器可格式化。若是Parquet的格式，暂时无法处理。\n" +
+		ExportMediaDir + ": 日志中的图片与文件，以内容的 SHA-256 命名\n"
 
 	StoryVersionV1   StoryVersion = 101
 	StoryVersionV105 StoryVersion = 105
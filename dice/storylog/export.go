@@ -0,0 +1,79 @@
+package storylog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/utils/dboperator/engine"
+)
+
+// ErrExportEmpty 日志不存在或筛选后没有可导出的消息
+var ErrExportEmpty = errors.New("此log不存在，或条目数为空，名字是否正确？")
+
+// ExportLog 按选项渲染日志并写入 w，返回导出的消息数
+func ExportLog(db engine.DatabaseOperator, groupID string, logName string, opts ExportOptions, w io.Writer) (int, error) {
+	exporter, err := NewExporter(w, ExportMeta{GroupID: groupID, LogName: logName}, opts)
+	if err != nil {
+		return 0, err
+	}
+	cursor := paginator.Cursor{}
+	for {
+		lines, next, err := service.LogGetCursorLines(db, groupID, logName, cursor)
+		if err != nil {
+			return 0, err
+		}
+		if err = exporter.Write(lines); err != nil {
+			return 0, fmt.Errorf("写入日志导出文件失败: %w", err)
+		}
+		if next.After == nil {
+			break
+		}
+		cursor.After = next.After
+	}
+	if err = exporter.Close(); err != nil {
+		return 0, fmt.Errorf("写入日志导出文件失败: %w", err)
+	}
+	if exporter.Count() == 0 {
+		return 0, ErrExportEmpty
+	}
+	return exporter.Count(), nil
+}
+
+// ExportLogFile 导出到临时文件，返回文件路径，由调用方负责删除
+func ExportLogFile(db engine.DatabaseOperator, groupID string, logName string, fileNamePrefix string, opts ExportOptions) (string, string, error) {
+	if opts.Format == "" {
+		opts.Format = ExportFormatTxt
+	}
+	tempPattern, notice := buildTempPatternExt(fileNamePrefix, opts.Format.Ext())
+	f, err := os.CreateTemp("", tempPattern)
+	if err != nil {
+		return "", notice, errors.New("log导出出现未知错误")
+	}
+
+	w := bufio.NewWriter(f)
+	_, err = ExportLog(db, groupID, logName, opts, w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", notice, err
+	}
+	return f.Name(), notice, nil
+}
+
+// ExportFilename 下载时使用的文件名
+func ExportFilename(groupID string, logName string, format ExportFormat) string {
+	name, _ := buildLogFilename(groupID, logName, time.Now(), format.Ext())
+	return name
+}
@@ -20,6 +20,11 @@ const (
 var invalidFilenameCharsRe = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f]`)
 
 func buildLogBackupFilename(groupID, logName string, now time.Time) (string, string) {
+	return buildLogFilename(groupID, logName, now, ".zip")
+}
+
+// buildLogFilename 生成 "群_日志名.时间戳<ext>" 形式的文件名，日志名不适合作文件名时改用哈希
+func buildLogFilename(groupID, logName string, now time.Time, ext string) (string, string) {
 	groupPart, ok := sanitizeFilenameComponent(groupID)
 	if !ok {
 		groupPart = "group"
@@ -29,35 +34,40 @@ func buildLogBackupFilename(groupID, logName string, now time.Time) (string, str
 	logPart, logOK := sanitizeFilenameComponent(logName)
 	timestamp := now.Format("060102150405")
 	if logOK {
-		name := fmt.Sprintf("%s_%s.%s.zip", groupPart, logPart, timestamp)
+		name := fmt.Sprintf("%s_%s.%s%s", groupPart, logPart, timestamp, ext)
 		if len([]byte(name)) <= maxExportFilenameBytes {
 			return name, ""
 		}
 	}
 
 	hashPart := hashHex(logName)
-	name := fmt.Sprintf("%s_%s.%s.zip", groupPart, hashPart, timestamp)
+	name := fmt.Sprintf("%s_%s.%s%s", groupPart, hashPart, timestamp, ext)
 	if len([]byte(name)) <= maxExportFilenameBytes {
 		return name, fileNameFallbackNotice
 	}
 
-	return fmt.Sprintf("log_%s.%s.zip", hashPart, timestamp), fileNameFallbackNotice
+	return fmt.Sprintf("log_%s.%s%s", hashPart, timestamp, ext), fileNameFallbackNotice
 }
 
 func buildTempPattern(prefix string) (string, string) {
+	return buildTempPatternExt(prefix, ".txt")
+}
+
+// buildTempPatternExt 生成 os.CreateTemp 使用的 "前缀-*<ext>" 形式的文件名模板
+func buildTempPatternExt(prefix string, ext string) (string, string) {
 	cleanPrefix, ok := sanitizeFilenameComponent(prefix)
 	if ok {
-		if len([]byte(cleanPrefix+"-*.txt")) <= maxTempPatternBytes {
-			pattern := cleanPrefix + "-*.txt"
+		if len([]byte(cleanPrefix+"-*"+ext)) <= maxTempPatternBytes {
+			pattern := cleanPrefix + "-*" + ext
 			return pattern, ""
 		}
 	}
 
-	pattern := "log-export-" + hashHex(prefix) + "-*.txt"
+	pattern := "log-export-" + hashHex(prefix) + "-*" + ext
 	if len([]byte(pattern)) > maxTempPatternBytes {
 		pattern = trimUTF8ByBytes(pattern, maxTempPatternBytes)
 		if !strings.Contains(pattern, "*") {
-			pattern = "log-" + hashHex(prefix)[:8] + "-*.txt"
+			pattern = "log-" + hashHex(prefix)[:8] + "-*" + ext
 		}
 	}
 	return pattern, fileNameFallbackNotice
@@ -67,6 +77,10 @@ func BuildTempPattern(prefix string) (string, string) {
 	return buildTempPattern(prefix)
 }
 
+func BuildTempPatternExt(prefix string, ext string) (string, string) {
+	return buildTempPatternExt(prefix, ext)
+}
+
 func sanitizeFilenameComponent(name string) (string, bool) {
 	name = strings.Map(func(r rune) rune {
 		if unicode.IsControl(r) {
@@ -0,0 +1,371 @@
+package storylog
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/model"
+	"sealdice-core/utils"
+	"sealdice-core/utils/dboperator/engine"
+)
+
+// 日志附件：消息中的图片与文件按内容的 SHA-256 存放，同一张图片只保存一份。
+// 消息通过 log_items.media 列引用附件，归档文件中也保留该列，因此清理时要同时考虑数据库与归档。
+
+const (
+	MediaDirName = "log-media"
+
+	defaultMediaMaxMB = 20
+	// mediaGCGrace 刚写入的文件可能还没来得及记到消息上，清理时跳过
+	mediaGCGrace = time.Hour
+)
+
+var (
+	ErrMediaTooLarge = errors.New("附件超过大小限制")
+	ErrMediaSource   = errors.New("不支持的附件来源")
+
+	mediaHashRe = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	// mediaCQRe 消息文本中的图片、文件 CQ 码，导出时由附件代替
+	mediaCQRe = regexp.MustCompile(`\[CQ:(?:image|file),[^\]]*\]`)
+)
+
+// MediaConfig 日志附件设置
+type MediaConfig struct {
+	Enable    bool `json:"enable"    yaml:"enable"`    // 记录日志时下载消息中的图片与文件
+	Files     bool `json:"files"     yaml:"files"`     // 除图片外也下载文件
+	MaxSizeMB int  `json:"maxSizeMB" yaml:"maxSizeMB"` // 单个附件的大小上限，默认 20MB
+}
+
+func (c *MediaConfig) MaxBytes() int64 {
+	if c.MaxSizeMB <= 0 {
+		return defaultMediaMaxMB << 20
+	}
+	return int64(c.MaxSizeMB) << 20
+}
+
+// MediaSource 待下载的附件，URL 支持 http(s):// 与 base64://，Stream 不为空时优先使用
+type MediaSource struct {
+	Kind        string
+	Name        string
+	ContentType string
+	URL         string
+	Stream      io.Reader
+}
+
+// MediaStore 按内容寻址的附件目录，文件位于 <Dir>/<hash 前两位>/<hash>
+type MediaStore struct {
+	Dir    string
+	Client *http.Client
+}
+
+// NewMediaStore 附件网址来自聊天平台的消息，下载时只连接公网地址
+func NewMediaStore(dir string) *MediaStore {
+	return &MediaStore{Dir: dir, Client: utils.NewPublicHTTPClient(time.Minute)}
+}
+
+// Path 附件文件路径，hash 不合法时返回错误，避免访问目录以外的文件
+func (s *MediaStore) Path(hash string) (string, error) {
+	if !mediaHashRe.MatchString(hash) {
+		return "", fmt.Errorf("附件标识无效: %q", hash)
+	}
+	return filepath.Join(s.Dir, hash[:2], hash), nil
+}
+
+func (s *MediaStore) Open(hash string) (*os.File, error) {
+	path, err := s.Path(hash)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Has 附件文件是否存在
+func (s *MediaStore) Has(hash string) bool {
+	path, err := s.Path(hash)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// Put 写入附件，返回内容的 hash 与大小。超过 maxBytes 时返回 ErrMediaTooLarge
+func (s *MediaStore) Put(r io.Reader, maxBytes int64) (string, int64, error) {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return "", 0, err
+	}
+	tmp, err := os.CreateTemp(s.Dir, ".upload-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(r, maxBytes+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	if size > maxBytes {
+		return "", 0, ErrMediaTooLarge
+	}
+
+	hash := hex.EncodeToString(h.Sum(nil))
+	path, _ := s.Path(hash)
+	if _, statErr := os.Stat(path); statErr == nil {
+		// 已有相同内容，刷新修改时间以免被正在进行的清理删掉
+		now := time.Now()
+		_ = os.Chtimes(path, now, now)
+		return hash, size, nil
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", 0, err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return "", 0, err
+	}
+	return hash, size, nil
+}
+
+func (s *MediaStore) open(ctx context.Context, src *MediaSource) (io.ReadCloser, string, error) {
+	if src.Stream != nil {
+		return io.NopCloser(src.Stream), "", nil
+	}
+	switch {
+	case strings.HasPrefix(src.URL, "base64://"):
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(src.URL, "base64://"))
+		if err != nil {
+			return nil, "", err
+		}
+		return io.NopCloser(strings.NewReader(string(data))), "", nil
+	case strings.HasPrefix(src.URL, "http://"), strings.HasPrefix(src.URL, "https://"):
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, src.URL, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		resp, err := s.Client.Do(req)
+		if err != nil {
+			return nil, "", err
+		}
+		if resp.StatusCode != http.StatusOK {
+			_ = resp.Body.Close()
+			return nil, "", fmt.Errorf("下载附件失败: %s", resp.Status)
+		}
+		return resp.Body, resp.Header.Get("Content-Type"), nil
+	default:
+		return nil, "", ErrMediaSource
+	}
+}
+
+// Fetch 下载并保存附件。失败时返回的 LogMedia 仍带有来源信息，只是 Hash 为空
+func (s *MediaStore) Fetch(ctx context.Context, src *MediaSource, maxBytes int64) (model.LogMedia, error) {
+	media := model.LogMedia{Kind: src.Kind, Name: src.Name, ContentType: src.ContentType}
+	if !strings.HasPrefix(src.URL, "base64://") {
+		media.URL = src.URL
+	}
+	body, contentType, err := s.open(ctx, src)
+	if err != nil {
+		return media, err
+	}
+	defer func() { _ = body.Close() }()
+
+	// 读出开头用于判断类型
+	head := make([]byte, 512)
+	n, err := io.ReadFull(body, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return media, err
+	}
+	head = head[:n]
+	if media.ContentType == "" {
+		media.ContentType = contentType
+	}
+	media.ContentType = mediaContentType(media.ContentType, head)
+
+	hash, size, err := s.Put(io.MultiReader(strings.NewReader(string(head)), body), maxBytes)
+	if err != nil {
+		return media, err
+	}
+	media.Hash, media.Size = hash, size
+	return media, nil
+}
+
+// mediaContentType 只保留解析出的媒体类型，无法解析时按内容判断，参数等其余部分一律丢弃
+func mediaContentType(contentType string, head []byte) string {
+	if contentType != "" && contentType != "application/octet-stream" {
+		if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+			return mediaType
+		}
+	}
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	return mediaType
+}
+
+// Usage 附件文件数与总大小
+func (s *MediaStore) Usage() (files int, size int64, err error) {
+	err = s.walk(func(_ string, _ string, info fs.FileInfo) {
+		files++
+		size += info.Size()
+	})
+	return files, size, err
+}
+
+func (s *MediaStore) walk(fn func(path string, hash string, info fs.FileInfo)) error {
+	err := filepath.Walk(s.Dir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !mediaHashRe.MatchString(info.Name()) {
+			return nil
+		}
+		fn(path, info.Name(), info)
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// GC 删除 keep 返回 false 的附件，返回删除的文件数与释放的空间
+func (s *MediaStore) GC(keep func(hash string) bool) (removed int, freed int64, err error) {
+	deadline := time.Now().Add(-mediaGCGrace)
+	var firstErr error
+	err = s.walk(func(path string, hash string, info fs.FileInfo) {
+		if keep(hash) || info.ModTime().After(deadline) {
+			return
+		}
+		if errRemove := os.Remove(path); errRemove != nil {
+			if firstErr == nil {
+				firstErr = errRemove
+			}
+			return
+		}
+		removed++
+		freed += info.Size()
+	})
+	if err == nil {
+		err = firstErr
+	}
+	return removed, freed, err
+}
+
+// MediaGC 清理数据库与 archiveDirs 下的归档都不再引用的附件
+func MediaGC(db engine.DatabaseOperator, store *MediaStore, archiveDirs ...string) (int, int64, error) {
+	used := map[string]struct{}{}
+	add := func(hash string) { used[hash] = struct{}{} }
+	if err := service.LogForEachMediaHash(db, add); err != nil {
+		return 0, 0, err
+	}
+	for _, dir := range archiveDirs {
+		if err := forEachArchiveMediaHash(dir, add); err != nil {
+			return 0, 0, err
+		}
+	}
+	return store.GC(func(hash string) bool {
+		_, ok := used[hash]
+		return ok
+	})
+}
+
+func forEachArchiveMediaHash(dir string, fn func(hash string)) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ArchiveExt) {
+			continue
+		}
+		if err = readArchiveMediaHashes(filepath.Join(dir, entry.Name()), fn); err != nil {
+			return fmt.Errorf("读取归档 %s 的附件失败: %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+func readArchiveMediaHashes(path string, fn func(hash string)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	pf, err := parquet.OpenFile(f, stat.Size())
+	if err != nil {
+		return err
+	}
+	reader := parquet.NewGenericReader[model.LogOneItemParquet](pf)
+	defer func() { _ = reader.Close() }()
+	buf := make([]model.LogOneItemParquet, restoreBatchSize)
+	for {
+		n, readErr := reader.Read(buf)
+		for _, line := range buf[:n] {
+			for _, m := range model.ParseLogMedia(line.MediaStr) {
+				if m.Hash != "" {
+					fn(m.Hash)
+				}
+			}
+		}
+		if errors.Is(readErr, io.EOF) {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+// logMediaHashes 日志引用的全部附件，按首次出现的顺序
+func logMediaHashes(db engine.DatabaseOperator, groupID string, logName string) ([]model.LogMedia, error) {
+	var res []model.LogMedia
+	seen := map[string]bool{}
+	cursor := paginator.Cursor{}
+	for {
+		lines, next, err := service.LogGetExportCursorLines(db, groupID, logName, cursor)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range lines {
+			for _, m := range model.ParseLogMedia(line.MediaStr) {
+				if m.Hash != "" && !seen[m.Hash] {
+					seen[m.Hash] = true
+					res = append(res, m)
+				}
+			}
+		}
+		if next.After == nil {
+			return res, nil
+		}
+		cursor.After = next.After
+	}
+}
+
+// StripMediaCQ 去掉消息文本中已作为附件保存的图片、文件 CQ 码
+func StripMediaCQ(message string) string {
+	return strings.TrimSpace(mediaCQRe.ReplaceAllString(message, ""))
+}
@@ -0,0 +1,238 @@
+package storylog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/dice/storylog"
+	"sealdice-core/model"
+	"sealdice-core/utils"
+	"sealdice-core/utils/constant"
+	"sealdice-core/utils/dboperator"
+)
+
+// 1x1 的 PNG
+var testPNG, _ = base64.StdEncoding.DecodeString("iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNk+M9QDwADhgGAWjR9awAAAABJRU5ErkJggg==")
+
+func ageMedia(t *testing.T, store *storylog.MediaStore, hash string) {
+	t.Helper()
+	path, err := store.Path(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err = os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMediaStorePutAndGC(t *testing.T) {
+	store := storylog.NewMediaStore(t.TempDir())
+	h1, size, err := store.Put(strings.NewReader("hello"), 1024)
+	if err != nil || size != 5 {
+		t.Fatalf("Put() = %q, %d, %v", h1, size, err)
+	}
+	if h, _, _ := store.Put(strings.NewReader("hello"), 1024); h != h1 {
+		t.Fatalf("same content should share a hash: %q != %q", h, h1)
+	}
+	h2, _, err := store.Put(strings.NewReader("world"), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = store.Put(strings.NewReader("too large"), 4); !errors.Is(err, storylog.ErrMediaTooLarge) {
+		t.Fatalf("Put() over limit error = %v", err)
+	}
+	if _, err = store.Path("../../etc/passwd"); err == nil {
+		t.Fatal("Path() should reject invalid hashes")
+	}
+	if files, total, _ := store.Usage(); files != 2 || total != 10 {
+		t.Fatalf("Usage() = %d, %d", files, total)
+	}
+
+	// 刚写入的文件不会被清理
+	if removed, _, _ := store.GC(func(string) bool { return false }); removed != 0 {
+		t.Fatalf("GC() removed %d fresh files", removed)
+	}
+	ageMedia(t, store, h1)
+	ageMedia(t, store, h2)
+	removed, freed, err := store.GC(func(hash string) bool { return hash == h1 })
+	if err != nil || removed != 1 || freed != 5 {
+		t.Fatalf("GC() = %d, %d, %v", removed, freed, err)
+	}
+	if !store.Has(h1) || store.Has(h2) {
+		t.Fatal("GC() removed the wrong file")
+	}
+}
+
+func TestMediaStoreFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(testPNG)
+	}))
+	defer srv.Close()
+
+	store := storylog.NewMediaStore(t.TempDir())
+	// 默认只连接公网地址，测试服务器在本机上
+	if _, err := store.Fetch(context.Background(), &storylog.MediaSource{Kind: model.LogMediaKindImage, URL: srv.URL + "/a.png"}, 1<<20); !errors.Is(err, utils.ErrAddrNotPublic) {
+		t.Fatalf("Fetch(loopback) error = %v, want ErrAddrNotPublic", err)
+	}
+	store.Client = srv.Client()
+	m, err := store.Fetch(context.Background(), &storylog.MediaSource{Kind: model.LogMediaKindImage, URL: srv.URL + "/a.png"}, 1<<20)
+	if err != nil {
+		t.Fatalf("Fetch(http) error = %v", err)
+	}
+	if m.ContentType != "image/png" || m.Size != int64(len(testPNG)) || m.URL == "" || !store.Has(m.Hash) {
+		t.Fatalf("unexpected media: %+v", m)
+	}
+
+	b64 := "base64://" + base64.StdEncoding.EncodeToString(testPNG)
+	m2, err := store.Fetch(context.Background(), &storylog.MediaSource{Kind: model.LogMediaKindImage, URL: b64}, 1<<20)
+	if err != nil || m2.Hash != m.Hash || m2.URL != "" {
+		t.Fatalf("Fetch(base64) = %+v, %v", m2, err)
+	}
+
+	m3, err := store.Fetch(context.Background(), &storylog.MediaSource{Kind: model.LogMediaKindFile, Name: "a.txt", Stream: bytes.NewReader([]byte("notes"))}, 1<<20)
+	if err != nil || m3.Name != "a.txt" || !strings.HasPrefix(m3.ContentType, "text/plain") {
+		t.Fatalf("Fetch(stream) = %+v, %v", m3, err)
+	}
+
+	// 无法解析的类型按内容判断，不原样保存
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", `image/png"><script>`)
+		_, _ = w.Write(testPNG)
+	}))
+	defer bad.Close()
+	m4, err := store.Fetch(context.Background(), &storylog.MediaSource{Kind: model.LogMediaKindImage, URL: bad.URL}, 1<<20)
+	if err != nil || m4.ContentType != "image/png" {
+		t.Fatalf("Fetch(bad content type) = %+v, %v", m4, err)
+	}
+
+	// 不读取本地文件
+	if _, err = store.Fetch(context.Background(), &storylog.MediaSource{URL: "/etc/hosts"}, 1<<20); !errors.Is(err, storylog.ErrMediaSource) {
+		t.Fatalf("Fetch(local path) error = %v", err)
+	}
+}
+
+func TestMediaGCKeepsArchivedMedia(t *testing.T) {
+	op, err := dboperator.OpenEngine(context.Background(), constant.SQLITE, t.TempDir())
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(op.Close)
+	if err = op.GetLogDB(constant.WRITE).AutoMigrate(&model.LogInfo{}, &model.LogOneItem{}); err != nil {
+		t.Fatal(err)
+	}
+	store := storylog.NewMediaStore(t.TempDir())
+	put := func(content string) string {
+		h, _, errPut := store.Put(strings.NewReader(content), 1024)
+		if errPut != nil {
+			t.Fatal(errPut)
+		}
+		ageMedia(t, store, h)
+		return h
+	}
+	archived, kept, orphan := put("archived"), put("kept"), put("orphan")
+
+	attach := func(logName string, hash string) {
+		item := &model.LogOneItem{Nickname: "KP", IMUserID: "QQ:1", Message: "[CQ:image,file=x]"}
+		if !service.LogAppend(op, "QQ-Group:1", logName, item) || item.ID == 0 {
+			t.Fatalf("append to %s failed", logName)
+		}
+		media := []model.LogMedia{{Kind: model.LogMediaKindImage, Hash: hash, ContentType: "image/png"}}
+		if errSet := service.LogSetItemMedia(op, item.ID, media); errSet != nil {
+			t.Fatal(errSet)
+		}
+	}
+	attach("旧团", archived)
+	attach("新团", kept)
+
+	info, err := service.LogGetInfoByName(op, "QQ-Group:1", "旧团")
+	if err != nil {
+		t.Fatal(err)
+	}
+	archiveDir := t.TempDir()
+	if _, err = storylog.ArchiveLog(op, archiveDir, info); err != nil {
+		t.Fatalf("ArchiveLog: %v", err)
+	}
+
+	removed, _, err := storylog.MediaGC(op, store, archiveDir)
+	if err != nil || removed != 1 {
+		t.Fatalf("MediaGC() = %d, %v", removed, err)
+	}
+	if !store.Has(archived) || !store.Has(kept) || store.Has(orphan) {
+		t.Fatal("MediaGC() should only remove unreferenced media")
+	}
+
+	// 恢复后附件信息仍在
+	list, err := storylog.ListArchives(archiveDir)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("ListArchives = %v, %v", list, err)
+	}
+	if _, err = storylog.RestoreArchive(op, archiveDir, list[0].File); err != nil {
+		t.Fatalf("RestoreArchive: %v", err)
+	}
+	items, err := service.LogGetAllLines(op, "QQ-Group:1", "旧团")
+	if err != nil || len(items) != 1 || len(items[0].Media) != 1 || items[0].Media[0].Hash != archived {
+		t.Fatalf("restored items = %+v, %v", items, err)
+	}
+}
+
+func TestExportEmbedsMedia(t *testing.T) {
+	store := storylog.NewMediaStore(t.TempDir())
+	hash, size, err := store.Put(bytes.NewReader(testPNG), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := []model.LogOneItem{{
+		Nickname: "张三", IMUserID: "QQ:100", Time: 1700000000,
+		Message: "看这个[CQ:image,file=abc.image][CQ:file,file=notes.txt]",
+		Media: []model.LogMedia{
+			{Kind: model.LogMediaKindImage, Hash: hash, ContentType: "image/png", Size: size},
+			{Kind: model.LogMediaKindFile, Name: "notes.txt"},
+			{Kind: model.LogMediaKindImage, Hash: hash, ContentType: "image/svg+xml", Size: size},
+			{Kind: model.LogMediaKindImage, Hash: hash, ContentType: `image/png"><script>`, Size: size},
+		},
+	}}
+	render := func(format storylog.ExportFormat, media *storylog.MediaStore) string {
+		var buf bytes.Buffer
+		e, errNew := storylog.NewExporter(&buf, storylog.ExportMeta{LogName: "测试"}, storylog.ExportOptions{Format: format, Media: media})
+		if errNew != nil {
+			t.Fatal(errNew)
+		}
+		if errNew = e.Write(items); errNew != nil {
+			t.Fatal(errNew)
+		}
+		if errNew = e.Close(); errNew != nil {
+			t.Fatal(errNew)
+		}
+		return buf.String()
+	}
+
+	out := render(storylog.ExportFormatHTML, store)
+	if !strings.Contains(out, `<img src="data:image/png;base64,`) || !strings.Contains(out, "[文件: notes.txt]") || strings.Contains(out, "CQ:image") {
+		t.Errorf("html should embed the image:\n%s", out)
+	}
+	if strings.Count(out, "<img") != 1 || strings.Contains(out, "<script>") {
+		t.Errorf("html should only embed whitelisted image types:\n%s", out)
+	}
+	out = render(storylog.ExportFormatMarkdown, store)
+	if !strings.Contains(out, "](data:image/png;base64,") {
+		t.Errorf("markdown should embed the image:\n%s", out)
+	}
+	out = render(storylog.ExportFormatTxt, nil)
+	if !strings.Contains(out, "看这个\n[图片]\n[文件: notes.txt]") {
+		t.Errorf("txt should use placeholders:\n%s", out)
+	}
+	out = render(storylog.ExportFormatHTML, nil)
+	if strings.Contains(out, "<img") || !strings.Contains(out, "[图片]") {
+		t.Errorf("html without a store should use placeholders:\n%s", out)
+	}
+}
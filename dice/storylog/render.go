@@ -0,0 +1,510 @@
+package storylog
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"sealdice-core/model"
+)
+
+// 本地日志导出：不依赖日志后端，直接把日志渲染为文本、HTML、Markdown、Word 或结构化 JSON。
+// 各格式按批写入，日志较大时也不需要整份读入内存。
+
+// ExportFormat 本地导出的日志格式
+type ExportFormat string
+
+const (
+	ExportFormatTxt      ExportFormat = "txt"
+	ExportFormatHTML     ExportFormat = "html"
+	ExportFormatMarkdown ExportFormat = "md"
+	ExportFormatDocx     ExportFormat = "docx"
+	ExportFormatJSON     ExportFormat = "json"
+)
+
+var exportFormatNames = map[string]ExportFormat{
+	"txt":      ExportFormatTxt,
+	"text":     ExportFormatTxt,
+	"html":     ExportFormatHTML,
+	"htm":      ExportFormatHTML,
+	"md":       ExportFormatMarkdown,
+	"markdown": ExportFormatMarkdown,
+	"docx":     ExportFormatDocx,
+	"word":     ExportFormatDocx,
+	"json":     ExportFormatJSON,
+}
+
+// ParseExportFormat 解析格式名，不区分大小写，支持 markdown、word 等别名
+func ParseExportFormat(name string) (ExportFormat, bool) {
+	f, ok := exportFormatNames[strings.ToLower(strings.TrimSpace(name))]
+	return f, ok
+}
+
+// Ext 文件扩展名，带点
+func (f ExportFormat) Ext() string {
+	return "." + string(f)
+}
+
+// ContentType 下载时使用的 MIME 类型
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportFormatHTML:
+		return "text/html; charset=utf-8"
+	case ExportFormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	case ExportFormatDocx:
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	case ExportFormatJSON:
+		return "application/json; charset=utf-8"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+// ExportOptions 导出选项
+type ExportOptions struct {
+	Format ExportFormat `json:"format"`
+	// HideOOC 不导出场外发言，即以半角或全角左括号开头的消息
+	HideOOC bool `json:"hideOoc"`
+	// NameMap 发言人改名，键为 IM 账号或昵称，账号优先
+	NameMap map[string]string `json:"nameMap"`
+	// Media 附件目录，不为空时 HTML 与 Markdown 直接内嵌图片
+	Media *MediaStore `json:"-"`
+}
+
+// ExportRoll 从指令信息中提取的一次骰点或检定
+type ExportRoll struct {
+	Expr   string `json:"expr"`
+	Result string `json:"result"`
+	// Rank COC 检定的成功等级：-2 大失败，-1 失败，1 成功，2 困难成功，3 极难成功，4 大成功；0 为普通骰点
+	Rank   int    `json:"rank,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// ExportLine 导出的一条消息，也是 JSON 格式中 items 的元素
+type ExportLine struct {
+	ID       uint64       `json:"id"`
+	Time     int64        `json:"time"`
+	Speaker  string       `json:"speaker"`
+	Nickname string       `json:"nickname"`
+	IMUserID string       `json:"imUserId"`
+	IsDice   bool         `json:"isDice"`
+	Message  string       `json:"message"`
+	Rolls    []ExportRoll `json:"rolls,omitempty"`
+	// Media 消息中的图片与文件，Message 中对应的 CQ 码已去掉
+	Media []model.LogMedia `json:"media,omitempty"`
+
+	color string
+	// images 与 Media 一一对应的内嵌图片 data URI，无法内嵌时为空串
+	images []string
+}
+
+// ExportSpeaker 发言人，JSON 格式中按首次发言顺序列出
+type ExportSpeaker struct {
+	Name     string `json:"name"`
+	IMUserID string `json:"imUserId"`
+	Color    string `json:"color"`
+}
+
+// ExportMeta 日志信息，写在导出文件的开头
+type ExportMeta struct {
+	GroupID    string `json:"groupId"`
+	LogName    string `json:"logName"`
+	ExportedAt int64  `json:"exportedAt"`
+}
+
+// subtitle 标题下方的说明，如 "QQ-Group:1 · 导出于 2024-01-01 12:00:00"
+func (m *ExportMeta) subtitle() string {
+	exported := "导出于 " + formatLineTime(m.ExportedAt)
+	if m.GroupID == "" {
+		return exported
+	}
+	return m.GroupID + " · " + exported
+}
+
+var rankNames = map[int]string{
+	-2: "大失败",
+	-1: "失败",
+	1:  "成功",
+	2:  "困难成功",
+	3:  "极难成功",
+	4:  "大成功",
+}
+
+// RankName 成功等级的中文名，普通骰点返回空串
+func (r *ExportRoll) RankName() string {
+	return rankNames[r.Rank]
+}
+
+func (r *ExportRoll) rankClass() string {
+	switch {
+	case r.Rank >= 4:
+		return "crit"
+	case r.Rank > 0:
+		return "success"
+	case r.Rank <= -2:
+		return "fumble"
+	case r.Rank < 0:
+		return "failure"
+	default:
+		return "roll"
+	}
+}
+
+// String 单行文本形式，如 "侦查 35/60 困难成功"
+func (r *ExportRoll) String() string {
+	parts := make([]string, 0, 4)
+	for _, s := range []string{r.Expr, r.Result, r.RankName(), r.Detail} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// speakerColors 发言人配色，按首次发言顺序循环使用
+var speakerColors = []string{
+	"#c0392b", "#2471a3", "#1e8449", "#af601a", "#7d3c98", "#138d75",
+	"#b03a2e", "#2e4053", "#9a7d0a", "#a93226", "#1f618d", "#6c3483",
+}
+
+const diceSpeakerColor = "#7f8c8d"
+
+// maxEmbedImageSize 内嵌到导出文件的单张图片上限，更大的图片只保留占位
+const maxEmbedImageSize = 5 << 20
+
+// embedImageTypes 可以内嵌为 data URI 的图片类型，其余类型（如可携带脚本的 SVG）只保留占位
+var embedImageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+	"image/bmp":  true,
+}
+
+type logRenderer interface {
+	begin(meta *ExportMeta) error
+	line(l *ExportLine) error
+	end(speakers []ExportSpeaker) error
+}
+
+// Exporter 把日志消息逐批渲染为指定格式
+type Exporter struct {
+	opts     ExportOptions
+	meta     ExportMeta
+	r        logRenderer
+	colors   map[string]string
+	speakers []ExportSpeaker
+	count    int
+}
+
+// NewExporter 创建导出器并写入文件头，写完全部消息后需调用 Close
+func NewExporter(w io.Writer, meta ExportMeta, opts ExportOptions) (*Exporter, error) {
+	var r logRenderer
+	switch opts.Format {
+	case ExportFormatTxt, "":
+		opts.Format = ExportFormatTxt
+		r = &txtRenderer{w: w}
+	case ExportFormatHTML:
+		r = &htmlRenderer{w: w}
+	case ExportFormatMarkdown:
+		r = &markdownRenderer{w: w}
+	case ExportFormatDocx:
+		r = &docxRenderer{w: w}
+	case ExportFormatJSON:
+		r = &jsonRenderer{w: w}
+	default:
+		return nil, fmt.Errorf("不支持的导出格式: %s", opts.Format)
+	}
+	if meta.ExportedAt == 0 {
+		meta.ExportedAt = time.Now().Unix()
+	}
+	e := &Exporter{opts: opts, meta: meta, r: r, colors: map[string]string{}}
+	if err := r.begin(&e.meta); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// IsOOC 是否为场外发言
+func IsOOC(message string) bool {
+	message = strings.TrimSpace(message)
+	return strings.HasPrefix(message, "(") || strings.HasPrefix(message, "（")
+}
+
+func (e *Exporter) speakerName(item *model.LogOneItem) string {
+	if name, ok := e.opts.NameMap[item.IMUserID]; ok && item.IMUserID != "" && name != "" {
+		return name
+	}
+	if name, ok := e.opts.NameMap[item.Nickname]; ok && name != "" {
+		return name
+	}
+	return item.Nickname
+}
+
+func (e *Exporter) speakerColor(item *model.LogOneItem, name string) string {
+	if item.IsDice {
+		return diceSpeakerColor
+	}
+	key := item.IMUserID
+	if key == "" {
+		key = name
+	}
+	if color, ok := e.colors[key]; ok {
+		return color
+	}
+	color := speakerColors[len(e.colors)%len(speakerColors)]
+	e.colors[key] = color
+	e.speakers = append(e.speakers, ExportSpeaker{Name: name, IMUserID: item.IMUserID, Color: color})
+	return color
+}
+
+// Write 写入一批消息，消息需按时间顺序给出
+func (e *Exporter) Write(items []model.LogOneItem) error {
+	for i := range items {
+		item := &items[i]
+		if e.opts.HideOOC && IsOOC(item.Message) {
+			continue
+		}
+		name := e.speakerName(item)
+		l := &ExportLine{
+			ID:       item.ID,
+			Time:     item.Time,
+			Speaker:  name,
+			Nickname: item.Nickname,
+			IMUserID: item.IMUserID,
+			IsDice:   item.IsDice,
+			Message:  item.Message,
+			Rolls:    extractRolls(item.CommandInfo),
+			color:    e.speakerColor(item, name),
+		}
+		if len(item.Media) > 0 {
+			l.Message = StripMediaCQ(item.Message)
+			l.Media = item.Media
+			l.images = e.embedImages(item.Media)
+		}
+		if err := e.r.line(l); err != nil {
+			return err
+		}
+		e.count++
+	}
+	return nil
+}
+
+// embedImages 读取可内嵌的图片，只有 HTML 与 Markdown 需要
+func (e *Exporter) embedImages(media []model.LogMedia) []string {
+	if e.opts.Media == nil || (e.opts.Format != ExportFormatHTML && e.opts.Format != ExportFormatMarkdown) {
+		return nil
+	}
+	images := make([]string, len(media))
+	for i, m := range media {
+		if m.Kind != model.LogMediaKindImage || m.Hash == "" || m.Size > maxEmbedImageSize || !embedImageTypes[m.ContentType] {
+			continue
+		}
+		f, err := e.opts.Media.Open(m.Hash)
+		if err != nil {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(f, maxEmbedImageSize+1))
+		_ = f.Close()
+		if err != nil || len(data) > maxEmbedImageSize {
+			continue
+		}
+		images[i] = "data:" + m.ContentType + ";base64," + base64.StdEncoding.EncodeToString(data)
+	}
+	return images
+}
+
+// image 第 i 个附件的内嵌图片，没有时为空串
+func (l *ExportLine) image(i int) string {
+	if i < len(l.images) {
+		return l.images[i]
+	}
+	return ""
+}
+
+// mediaLabel 附件的文字占位，如 "[图片: a.png]"
+func mediaLabel(m *model.LogMedia) string {
+	kind := "文件"
+	if m.Kind == model.LogMediaKindImage {
+		kind = "图片"
+	}
+	if m.Name == "" {
+		return "[" + kind + "]"
+	}
+	return "[" + kind + ": " + m.Name + "]"
+}
+
+// mediaText 消息文本后接附件占位，用于不能显示图片的格式
+func (l *ExportLine) mediaText() string {
+	text := l.Message
+	for i := range l.Media {
+		if text != "" {
+			text += "\n"
+		}
+		text += mediaLabel(&l.Media[i])
+	}
+	return text
+}
+
+// Count 已写入的消息数
+func (e *Exporter) Count() int {
+	return e.count
+}
+
+// Close 写入文件尾
+func (e *Exporter) Close() error {
+	return e.r.end(e.speakers)
+}
+
+func numberOf(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func textOf(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	if n, ok := numberOf(v); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// extractRolls 从指令信息中取出骰点结果，暗骰不导出
+func extractRolls(commandInfo interface{}) []ExportRoll {
+	info, ok := commandInfo.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if hide, _ := info["hide"].(bool); hide {
+		return nil
+	}
+	items, ok := info["items"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var rolls []ExportRoll
+	for _, raw := range items {
+		item, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		roll := ExportRoll{}
+		if rank, ok := numberOf(item["rank"]); ok {
+			roll.Rank = int(rank)
+		}
+		switch info["cmd"] {
+		case "ra":
+			roll.Expr = textOf(item["expr2"])
+			roll.Result = textOf(item["outcome"]) + "/" + textOf(item["checkVal"])
+		case "sc":
+			roll.Expr = "理智"
+			roll.Result = textOf(item["outcome"]) + "/" + textOf(item["sanOld"])
+			if item["sanNew"] != nil {
+				roll.Detail = fmt.Sprintf("理智 %s → %s", textOf(item["sanOld"]), textOf(item["sanNew"]))
+			}
+		default:
+			roll.Expr = textOf(item["expr"])
+			if reason := textOf(item["reason"]); reason != "" {
+				roll.Expr = reason + " " + roll.Expr
+			}
+			roll.Result = textOf(item["result"])
+		}
+		if roll.Expr == "" && roll.Result == "" {
+			continue
+		}
+		rolls = append(rolls, roll)
+	}
+	return rolls
+}
+
+func formatLineTime(t int64) string {
+	return time.Unix(t, 0).Format("2006-01-02 15:04:05")
+}
+
+// txtRenderer 与 GetLogTxt 相同的纯文本格式
+type txtRenderer struct {
+	w io.Writer
+}
+
+func (r *txtRenderer) begin(_ *ExportMeta) error { return nil }
+
+func (r *txtRenderer) line(l *ExportLine) error {
+	_, err := fmt.Fprintf(r.w, "%s(%v) %s\n%s\n\n", l.Speaker, l.IMUserID, formatLineTime(l.Time), l.mediaText())
+	return err
+}
+
+func (r *txtRenderer) end(_ []ExportSpeaker) error { return nil }
+
+// jsonRenderer 结构化 JSON：{"groupId", "logName", "exportedAt", "items": [...], "speakers": [...]}
+type jsonRenderer struct {
+	w     io.Writer
+	first bool
+}
+
+func (r *jsonRenderer) begin(meta *ExportMeta) error {
+	head, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	// 去掉结尾的 }，接着写 items
+	if _, err = r.w.Write(head[:len(head)-1]); err != nil {
+		return err
+	}
+	r.first = true
+	_, err = io.WriteString(r.w, `,"items":[`)
+	return err
+}
+
+func (r *jsonRenderer) line(l *ExportLine) error {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	if !r.first {
+		if _, err = io.WriteString(r.w, ",\n"); err != nil {
+			return err
+		}
+	}
+	r.first = false
+	_, err = r.w.Write(data)
+	return err
+}
+
+func (r *jsonRenderer) end(speakers []ExportSpeaker) error {
+	if speakers == nil {
+		speakers = []ExportSpeaker{}
+	}
+	data, err := json.Marshal(speakers)
+	if err != nil {
+		return err
+	}
+	if _, err = io.WriteString(r.w, `],"speakers":`); err != nil {
+		return err
+	}
+	if _, err = r.w.Write(data); err != nil {
+		return err
+	}
+	_, err = io.WriteString(r.w, "}\n")
+	return err
+}
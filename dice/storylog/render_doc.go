@@ -0,0 +1,274 @@
+package storylog
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+)
+
+const htmlHead = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+<style>
+body { max-width: 860px; margin: 2em auto; padding: 0 1em; font-family: "Noto Serif SC", "Source Han Serif SC", "SimSun", serif; line-height: 1.7; color: #222; background: #fdfcf8; }
+header { border-bottom: 1px solid #ccc; margin-bottom: 1.5em; }
+header h1 { margin-bottom: 0.2em; }
+header p { margin-top: 0; color: #888; font-size: 0.9em; }
+.line { margin: 0.6em 0; }
+.line .time { color: #aaa; font-size: 0.8em; margin-right: 0.5em; }
+.line .speaker { font-weight: bold; }
+.line .msg { white-space: pre-wrap; word-break: break-word; }
+.line.dice .msg { color: #555; font-style: italic; }
+.media img { display: block; max-width: 100%%; max-height: 480px; margin: 0.3em 0; border-radius: 4px; }
+.media .file { display: inline-block; margin-right: 0.4em; color: #888; font-size: 0.9em; }
+.rolls { margin-top: 0.2em; }
+.rolls span { display: inline-block; margin-right: 0.4em; padding: 0 0.5em; border-radius: 3px; font-size: 0.9em; font-style: normal; background: #ecf0f1; }
+.rolls .success { background: #d5f5e3; color: #1e8449; }
+.rolls .crit { background: #f9e79f; color: #7d6608; font-weight: bold; }
+.rolls .failure { background: #fadbd8; color: #a93226; }
+.rolls .fumble { background: #922b21; color: #fff; font-weight: bold; }
+</style>
+</head>
+<body>
+<header><h1>%s</h1><p>%s</p></header>
+<main>
+`
+
+const htmlFoot = `</main>
+</body>
+</html>
+`
+
+// htmlRenderer 自带样式的单文件 HTML，发言人按颜色区分，检定结果按成功等级着色
+type htmlRenderer struct {
+	w io.Writer
+}
+
+func (r *htmlRenderer) begin(meta *ExportMeta) error {
+	title := html.EscapeString(meta.LogName)
+	_, err := fmt.Fprintf(r.w, htmlHead, title, title, html.EscapeString(meta.subtitle()))
+	return err
+}
+
+func (r *htmlRenderer) line(l *ExportLine) error {
+	class := "line"
+	if l.IsDice {
+		class += " dice"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `<div class="%s"><span class="time">%s</span><span class="speaker" style="color:%s">%s</span>`,
+		class, time.Unix(l.Time, 0).Format("15:04:05"), l.color, html.EscapeString(l.Speaker))
+	fmt.Fprintf(&b, `<div class="msg">%s</div>`, html.EscapeString(l.Message))
+	if len(l.Media) > 0 {
+		b.WriteString(`<div class="media">`)
+		for i := range l.Media {
+			label := html.EscapeString(mediaLabel(&l.Media[i]))
+			if src := l.image(i); src != "" {
+				fmt.Fprintf(&b, `<img src="%s" alt="%s">`, html.EscapeString(src), label)
+			} else {
+				fmt.Fprintf(&b, `<span class="file">%s</span>`, label)
+			}
+		}
+		b.WriteString(`</div>`)
+	}
+	if len(l.Rolls) > 0 {
+		b.WriteString(`<div class="rolls">`)
+		for i := range l.Rolls {
+			fmt.Fprintf(&b, `<span class="%s">%s</span>`, l.Rolls[i].rankClass(), html.EscapeString(l.Rolls[i].String()))
+		}
+		b.WriteString(`</div>`)
+	}
+	b.WriteString("</div>\n")
+	_, err := io.WriteString(r.w, b.String())
+	return err
+}
+
+func (r *htmlRenderer) end(_ []ExportSpeaker) error {
+	_, err := io.WriteString(r.w, htmlFoot)
+	return err
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`,
+	`<`, `&lt;`, `>`, `&gt;`, `#`, `\#`, `|`, `\|`, `~`, `\~`,
+)
+
+// markdownRenderer 每条消息一段，骰子的回复以引用块呈现
+type markdownRenderer struct {
+	w io.Writer
+}
+
+func (r *markdownRenderer) begin(meta *ExportMeta) error {
+	_, err := fmt.Fprintf(r.w, "# %s\n\n> %s\n\n", markdownEscaper.Replace(meta.LogName), markdownEscaper.Replace(meta.subtitle()))
+	return err
+}
+
+func (r *markdownRenderer) line(l *ExportLine) error {
+	var b strings.Builder
+	prefix := ""
+	if l.IsDice {
+		prefix = "> "
+	}
+	fmt.Fprintf(&b, "%s**%s** `%s`  \n", prefix, markdownEscaper.Replace(l.Speaker), time.Unix(l.Time, 0).Format("15:04:05"))
+	lines := strings.Split(strings.TrimRight(l.Message, "\n"), "\n")
+	for i, text := range lines {
+		b.WriteString(prefix)
+		b.WriteString(markdownEscaper.Replace(text))
+		if i < len(lines)-1 {
+			b.WriteString("  ")
+		}
+		b.WriteString("\n")
+	}
+	for i := range l.Media {
+		label := markdownEscaper.Replace(mediaLabel(&l.Media[i]))
+		if src := l.image(i); src != "" {
+			label = "![" + label + "](" + src + ")"
+		}
+		b.WriteString(prefix + "\n" + prefix + label + "\n")
+	}
+	if len(l.Rolls) > 0 {
+		b.WriteString(prefix + "\n" + prefix)
+		for i := range l.Rolls {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			roll := &l.Rolls[i]
+			text := "`" + strings.ReplaceAll(roll.String(), "`", "'") + "`"
+			if roll.Rank >= 4 || roll.Rank <= -2 {
+				text = "**" + text + "**"
+			}
+			b.WriteString(text)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(r.w, b.String())
+	return err
+}
+
+func (r *markdownRenderer) end(_ []ExportSpeaker) error { return nil }
+
+const docxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+</Types>`
+
+const docxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>
+</Relationships>`
+
+const docxDocumentHead = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>`
+
+const docxDocumentFoot = `<w:sectPr><w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:top="1440" w:right="1440" w:bottom="1440" w:left="1440" w:header="851" w:footer="992" w:gutter="0"/></w:sectPr></w:body></w:document>`
+
+var docxRollColors = map[string]string{
+	"crit":    "B7950B",
+	"success": "1E8449",
+	"failure": "A93226",
+	"fumble":  "922B21",
+	"roll":    "2E4053",
+}
+
+// docxRenderer 最小的 Word 文档，只包含正文。正文直接写入 zip，不在内存中拼接整份文档
+type docxRenderer struct {
+	w    io.Writer
+	zw   *zip.Writer
+	body io.Writer
+}
+
+func docxText(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// docxRun 一段文字，换行转为 <w:br/>
+func docxRun(text string, color string, bold, italic bool, size int) string {
+	var b strings.Builder
+	b.WriteString("<w:r><w:rPr>")
+	if bold {
+		b.WriteString("<w:b/>")
+	}
+	if italic {
+		b.WriteString("<w:i/>")
+	}
+	if color != "" {
+		fmt.Fprintf(&b, `<w:color w:val="%s"/>`, strings.TrimPrefix(color, "#"))
+	}
+	if size > 0 {
+		fmt.Fprintf(&b, `<w:sz w:val="%d"/>`, size)
+	}
+	b.WriteString("</w:rPr>")
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			b.WriteString("<w:br/>")
+		}
+		fmt.Fprintf(&b, `<w:t xml:space="preserve">%s</w:t>`, docxText(line))
+	}
+	b.WriteString("</w:r>")
+	return b.String()
+}
+
+func docxParagraph(runs ...string) string {
+	return `<w:p><w:pPr><w:spacing w:after="120"/></w:pPr>` + strings.Join(runs, "") + "</w:p>"
+}
+
+func (r *docxRenderer) begin(meta *ExportMeta) error {
+	r.zw = zip.NewWriter(r.w)
+	for name, content := range map[string]string{
+		"[Content_Types].xml": docxContentTypes,
+		"_rels/.rels":         docxRels,
+	} {
+		f, err := r.zw.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err = io.WriteString(f, content); err != nil {
+			return err
+		}
+	}
+	body, err := r.zw.Create("word/document.xml")
+	if err != nil {
+		return err
+	}
+	r.body = body
+	head := docxDocumentHead +
+		docxParagraph(docxRun(meta.LogName, "", true, false, 36)) +
+		docxParagraph(docxRun(meta.subtitle(), "888888", false, false, 18))
+	_, err = io.WriteString(r.body, head)
+	return err
+}
+
+func (r *docxRenderer) line(l *ExportLine) error {
+	runs := []string{
+		docxRun(time.Unix(l.Time, 0).Format("15:04:05")+" ", "AAAAAA", false, false, 16),
+		docxRun(l.Speaker, l.color, true, false, 0),
+		docxRun("：", "", false, false, 0),
+		docxRun(l.mediaText(), "", false, l.IsDice, 0),
+	}
+	for i := range l.Rolls {
+		roll := &l.Rolls[i]
+		runs = append(runs, docxRun(" ["+roll.String()+"]", docxRollColors[roll.rankClass()], roll.Rank >= 4 || roll.Rank <= -2, false, 0))
+	}
+	_, err := io.WriteString(r.body, docxParagraph(runs...))
+	return err
+}
+
+func (r *docxRenderer) end(_ []ExportSpeaker) error {
+	if _, err := io.WriteString(r.body, docxDocumentFoot); err != nil {
+		return err
+	}
+	return r.zw.Close()
+}
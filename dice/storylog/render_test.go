@@ -0,0 +1,195 @@
+package storylog_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/dice/storylog"
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	"sealdice-core/utils/dboperator"
+)
+
+func exportTestItems() []model.LogOneItem {
+	return []model.LogOneItem{
+		{ID: 1, Nickname: "张三", IMUserID: "QQ:100", Time: 1700000000, Message: "我要检查这扇<门>"},
+		{ID: 2, Nickname: "张三", IMUserID: "QQ:100", Time: 1700000001, Message: ".ra 侦查"},
+		{ID: 3, Nickname: "海豹", IMUserID: "QQ:1", Time: 1700000002, IsDice: true, Message: "<阿尔弗雷德>的“侦查”检定结果为: D100=35/60 困难成功",
+			CommandInfo: map[string]interface{}{
+				"cmd": "ra", "rule": "coc7", "pcName": "阿尔弗雷德",
+				"items": []interface{}{map[string]interface{}{"expr2": "侦查", "outcome": float64(35), "checkVal": float64(60), "rank": float64(2)}},
+			}},
+		{ID: 4, Nickname: "李四", IMUserID: "QQ:200", Time: 1700000003, Message: "（我去倒杯水）"},
+		{ID: 5, Nickname: "海豹", IMUserID: "QQ:1", Time: 1700000004, IsDice: true, Message: "暗骰结果",
+			CommandInfo: map[string]interface{}{
+				"cmd": "roll", "hide": true,
+				"items": []interface{}{map[string]interface{}{"expr": "D100", "result": float64(99)}},
+			}},
+	}
+}
+
+func exportToString(t *testing.T, opts storylog.ExportOptions) string {
+	t.Helper()
+	var buf bytes.Buffer
+	e, err := storylog.NewExporter(&buf, storylog.ExportMeta{GroupID: "QQ-Group:1", LogName: "深潜者"}, opts)
+	if err != nil {
+		t.Fatalf("NewExporter: %v", err)
+	}
+	if err = e.Write(exportTestItems()); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err = e.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.String()
+}
+
+func TestParseExportFormat(t *testing.T) {
+	for name, want := range map[string]storylog.ExportFormat{
+		"html": storylog.ExportFormatHTML, "Markdown": storylog.ExportFormatMarkdown,
+		"word": storylog.ExportFormatDocx, "JSON": storylog.ExportFormatJSON, "txt": storylog.ExportFormatTxt,
+	} {
+		if got, ok := storylog.ParseExportFormat(name); !ok || got != want {
+			t.Errorf("ParseExportFormat(%q) = %q, %v, want %q", name, got, ok, want)
+		}
+	}
+	if _, ok := storylog.ParseExportFormat("pdf"); ok {
+		t.Error("pdf should not be a supported format")
+	}
+}
+
+func TestExportHTML(t *testing.T) {
+	out := exportToString(t, storylog.ExportOptions{
+		Format:  storylog.ExportFormatHTML,
+		NameMap: map[string]string{"QQ:100": "阿尔弗雷德"},
+	})
+	for _, want := range []string{
+		"<title>深潜者</title>",
+		"我要检查这扇&lt;门&gt;",
+		`<span class="success">侦查 35/60 困难成功</span>`,
+		">阿尔弗雷德</span>",
+		"（我去倒杯水）",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("html output missing %q", want)
+		}
+	}
+	if strings.Contains(out, ">张三<") {
+		t.Error("speaker should be renamed by IM user id")
+	}
+	if strings.Contains(out, "D100 99") {
+		t.Error("hidden roll should not be exported")
+	}
+}
+
+func TestExportMarkdownHidesOOC(t *testing.T) {
+	out := exportToString(t, storylog.ExportOptions{Format: storylog.ExportFormatMarkdown, HideOOC: true})
+	if !strings.HasPrefix(out, "# 深潜者\n") {
+		t.Errorf("markdown should start with the log name, got %q", out[:min(len(out), 40)])
+	}
+	if strings.Contains(out, "倒杯水") {
+		t.Error("OOC message should be filtered")
+	}
+	if !strings.Contains(out, "> **海豹**") || !strings.Contains(out, "`侦查 35/60 困难成功`") {
+		t.Errorf("dice reply should be quoted with its roll, got:\n%s", out)
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	out := exportToString(t, storylog.ExportOptions{Format: storylog.ExportFormatJSON, HideOOC: true})
+	var doc struct {
+		GroupID  string                   `json:"groupId"`
+		LogName  string                   `json:"logName"`
+		Items    []storylog.ExportLine    `json:"items"`
+		Speakers []storylog.ExportSpeaker `json:"speakers"`
+	}
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("invalid json: %v\n%s", err, out)
+	}
+	if doc.LogName != "深潜者" || doc.GroupID != "QQ-Group:1" {
+		t.Errorf("unexpected meta: %+v", doc)
+	}
+	if len(doc.Items) != 4 {
+		t.Fatalf("got %d items, want 4", len(doc.Items))
+	}
+	rolls := doc.Items[2].Rolls
+	if len(rolls) != 1 || rolls[0].Expr != "侦查" || rolls[0].Result != "35/60" || rolls[0].Rank != 2 {
+		t.Errorf("unexpected rolls: %+v", rolls)
+	}
+	if len(doc.Speakers) != 1 || doc.Speakers[0].IMUserID != "QQ:100" {
+		t.Errorf("unexpected speakers: %+v", doc.Speakers)
+	}
+}
+
+func TestExportDocx(t *testing.T) {
+	out := exportToString(t, storylog.ExportOptions{Format: storylog.ExportFormatDocx})
+	r, err := zip.NewReader(strings.NewReader(out), int64(len(out)))
+	if err != nil {
+		t.Fatalf("docx is not a zip: %v", err)
+	}
+	var document string
+	for _, f := range r.File {
+		if f.Name != "word/document.xml" {
+			continue
+		}
+		rc, errOpen := f.Open()
+		if errOpen != nil {
+			t.Fatal(errOpen)
+		}
+		data, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		document = string(data)
+	}
+	if document == "" {
+		t.Fatal("docx missing word/document.xml")
+	}
+	if !strings.Contains(document, "我要检查这扇&lt;门&gt;") || !strings.Contains(document, "侦查 35/60 困难成功") {
+		t.Errorf("unexpected document.xml:\n%s", document)
+	}
+}
+
+func TestExportLogFile(t *testing.T) {
+	op, err := dboperator.OpenEngine(context.Background(), constant.SQLITE, t.TempDir())
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(op.Close)
+	if err = op.GetLogDB(constant.WRITE).AutoMigrate(&model.LogInfo{}, &model.LogOneItem{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range exportTestItems() {
+		item.ID = 0
+		if !service.LogAppend(op, "QQ-Group:1", "深潜者", &item) {
+			t.Fatal("append failed")
+		}
+	}
+
+	path, _, err := storylog.ExportLogFile(op, "QQ-Group:1", "深潜者", "深潜者", storylog.ExportOptions{Format: storylog.ExportFormatHTML})
+	if err != nil {
+		t.Fatalf("ExportLogFile: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Remove(path) })
+	if !strings.HasSuffix(path, ".html") {
+		t.Errorf("export file %q should end with .html", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "侦查 35/60 困难成功") {
+		t.Error("roll from stored command info should be rendered")
+	}
+
+	_, _, err = storylog.ExportLogFile(op, "QQ-Group:1", "不存在", "x", storylog.ExportOptions{Format: storylog.ExportFormatJSON})
+	if !errors.Is(err, storylog.ErrExportEmpty) {
+		t.Errorf("export of missing log = %v, want ErrExportEmpty", err)
+	}
+}
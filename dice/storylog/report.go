@@ -0,0 +1,449 @@
+package storylog
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
+
+	"sealdice-core/dice/service"
+	"sealdice-core/model"
+	"sealdice-core/utils/dboperator/engine"
+)
+
+// 跑团报告：按参与者统计发言，按调查员统计检定与理智损失，并给出团的时长与中间的空档。
+// 检定数据来自骰子回复中结构化的 CommandInfo，暗骰不计入。
+
+const (
+	defaultReportIdleGap = 15 * 60
+	defaultReportBucket  = 30 * 60
+)
+
+// ReportOptions 报告选项
+type ReportOptions struct {
+	// IdleGap 两条消息间隔超过该秒数视为空档，默认 15 分钟
+	IdleGap int64 `json:"idleGap"`
+	// Bucket 发言分布的时间段长度，单位秒，默认 30 分钟
+	Bucket int64 `json:"bucket"`
+	// Alias 技能名归一化，如把“侦察”映射为“侦查”，可为空
+	Alias func(string) string `json:"-"`
+}
+
+// IdleGap 一段没有任何消息的空档
+type IdleGap struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// ParticipantStat 一位参与者（按 IM 账号区分）的发言统计，不含骰子的回复
+type ParticipantStat struct {
+	IMUserID string `json:"imUserId"`
+	Name     string `json:"name"`
+	Messages int    `json:"messages"`
+	// Chars 发言总字数
+	Chars int `json:"chars"`
+	// Commands 以 . 或 。开头的指令消息数
+	Commands  int   `json:"commands"`
+	FirstTime int64 `json:"firstTime"`
+	LastTime  int64 `json:"lastTime"`
+	// Timeline 每个时间段内的发言数，时间段从报告的 Start 开始，长度为 Bucket
+	Timeline []int `json:"timeline"`
+}
+
+// CheckStat 检定次数与结果。Judged 为能判断成败的次数，DND 检定没有难度等级，只能判断天然 20 与天然 1
+type CheckStat struct {
+	Checks   int `json:"checks"`
+	Judged   int `json:"judged"`
+	Success  int `json:"success"`
+	Critical int `json:"critical"`
+	Failure  int `json:"failure"`
+	Fumble   int `json:"fumble"`
+}
+
+// SkillStat 某一技能的检定统计
+type SkillStat struct {
+	Name string `json:"name"`
+	CheckStat
+}
+
+// InvestigatorStat 一位调查员（按角色名区分）的检定统计
+type InvestigatorStat struct {
+	Name string `json:"name"`
+	Rule string `json:"rule"`
+	CheckStat
+	Skills []*SkillStat `json:"skills"`
+	// SanChecks 理智检定次数，SanLost 累计损失，SanStart/SanEnd 首次检定前与最后一次检定后的理智
+	SanChecks int `json:"sanChecks"`
+	SanLost   int `json:"sanLost"`
+	SanStart  int `json:"sanStart"`
+	SanEnd    int `json:"sanEnd"`
+
+	skills map[string]*SkillStat
+}
+
+// SessionReport 一份日志的跑团报告
+type SessionReport struct {
+	GroupID string `json:"groupId"`
+	LogName string `json:"logName"`
+	Start   int64  `json:"start"`
+	End     int64  `json:"end"`
+	// Duration 首末消息间隔，ActiveDuration 扣除空档后的时长，单位秒
+	Duration       int64     `json:"duration"`
+	ActiveDuration int64     `json:"activeDuration"`
+	IdleGap        int64     `json:"idleGap"`
+	IdleGaps       []IdleGap `json:"idleGaps"`
+	Bucket         int64     `json:"bucket"`
+	// Messages 全部消息数，DiceMessages 其中骰子的回复数
+	Messages      int                 `json:"messages"`
+	DiceMessages  int                 `json:"diceMessages"`
+	Participants  []*ParticipantStat  `json:"participants"`
+	Investigators []*InvestigatorStat `json:"investigators"`
+}
+
+var reportSkillSuffix = regexp.MustCompile(`^([^\d\s]+)\d+$`)
+
+func (s *CheckStat) add(rank int, judged bool) {
+	s.Checks++
+	if !judged {
+		return
+	}
+	s.Judged++
+	switch {
+	case rank >= 4:
+		s.Success++
+		s.Critical++
+	case rank > 0:
+		s.Success++
+	case rank <= -2:
+		s.Failure++
+		s.Fumble++
+	case rank < 0:
+		s.Failure++
+	}
+}
+
+// SuccessRate 成功率，没有可判断的检定时为 0
+func (s *CheckStat) SuccessRate() float64 {
+	if s.Judged == 0 {
+		return 0
+	}
+	return float64(s.Success) / float64(s.Judged)
+}
+
+// ReportBuilder 按消息顺序逐批接收日志并累计统计，第一条消息的时间作为团的起点
+type ReportBuilder struct {
+	opts          ReportOptions
+	report        *SessionReport
+	participants  map[string]*ParticipantStat
+	investigators map[string]*InvestigatorStat
+	lastTime      int64
+}
+
+func NewReportBuilder(groupID string, logName string, opts ReportOptions) *ReportBuilder {
+	if opts.IdleGap <= 0 {
+		opts.IdleGap = defaultReportIdleGap
+	}
+	if opts.Bucket <= 0 {
+		opts.Bucket = defaultReportBucket
+	}
+	return &ReportBuilder{
+		opts: opts,
+		report: &SessionReport{
+			GroupID:  groupID,
+			LogName:  logName,
+			IdleGap:  opts.IdleGap,
+			Bucket:   opts.Bucket,
+			IdleGaps: []IdleGap{},
+		},
+		participants:  map[string]*ParticipantStat{},
+		investigators: map[string]*InvestigatorStat{},
+	}
+}
+
+func (b *ReportBuilder) Add(items []model.LogOneItem) {
+	for i := range items {
+		item := &items[i]
+		b.addTime(item.Time)
+		if item.IsDice {
+			b.report.DiceMessages++
+			b.addCommandInfo(item.CommandInfo)
+			continue
+		}
+		key := item.IMUserID
+		if key == "" {
+			key = item.Nickname
+		}
+		p := b.participants[key]
+		if p == nil {
+			p = &ParticipantStat{IMUserID: item.IMUserID, FirstTime: item.Time}
+			b.participants[key] = p
+		}
+		p.Name = item.Nickname
+		p.Messages++
+		p.Chars += utf8.RuneCountInString(item.Message)
+		if strings.HasPrefix(item.Message, ".") || strings.HasPrefix(item.Message, "。") {
+			p.Commands++
+		}
+		if item.Time < p.FirstTime {
+			p.FirstTime = item.Time
+		}
+		if item.Time > p.LastTime {
+			p.LastTime = item.Time
+		}
+		idx := 0
+		if item.Time > b.report.Start {
+			idx = int((item.Time - b.report.Start) / b.opts.Bucket)
+		}
+		for len(p.Timeline) <= idx {
+			p.Timeline = append(p.Timeline, 0)
+		}
+		p.Timeline[idx]++
+	}
+}
+
+func (b *ReportBuilder) addTime(t int64) {
+	r := b.report
+	r.Messages++
+	if r.Messages == 1 {
+		r.Start, r.End, b.lastTime = t, t, t
+		return
+	}
+	if gap := t - b.lastTime; gap >= r.IdleGap {
+		r.IdleGaps = append(r.IdleGaps, IdleGap{Start: b.lastTime, End: t})
+	}
+	if t > r.End {
+		r.End = t
+	}
+	b.lastTime = t
+}
+
+func (b *ReportBuilder) skillName(name string) string {
+	name = strings.TrimSpace(name)
+	if m := reportSkillSuffix.FindStringSubmatch(name); m != nil {
+		name = m[1]
+	}
+	if b.opts.Alias != nil && name != "" {
+		name = b.opts.Alias(name)
+	}
+	return name
+}
+
+func (b *ReportBuilder) investigator(name string, rule string) *InvestigatorStat {
+	inv := b.investigators[name]
+	if inv == nil {
+		inv = &InvestigatorStat{Name: name, Rule: rule, skills: map[string]*SkillStat{}}
+		b.investigators[name] = inv
+	}
+	return inv
+}
+
+func (inv *InvestigatorStat) addCheck(skill string, rank int, judged bool) {
+	inv.CheckStat.add(rank, judged)
+	if skill == "" {
+		return
+	}
+	s := inv.skills[skill]
+	if s == nil {
+		s = &SkillStat{Name: skill}
+		inv.skills[skill] = s
+	}
+	s.add(rank, judged)
+}
+
+func (b *ReportBuilder) addCommandInfo(commandInfo interface{}) {
+	info, ok := commandInfo.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if hide, _ := info["hide"].(bool); hide {
+		return
+	}
+	items, ok := info["items"].([]interface{})
+	if !ok {
+		return
+	}
+	rule := textOf(info["rule"])
+	cmd := textOf(info["cmd"])
+	pcName := textOf(info["pcName"])
+	if pcName == "" {
+		return
+	}
+
+	for _, raw := range items {
+		item, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		rank, _ := numberOf(item["rank"])
+		switch {
+		case rule == "coc7" && (cmd == "ra" || cmd == "rc"):
+			b.investigator(pcName, rule).addCheck(b.skillName(textOf(item["expr2"])), int(rank), true)
+		case rule == "coc7" && cmd == "sc":
+			inv := b.investigator(pcName, rule)
+			inv.addCheck("理智", int(rank), true)
+			sanOld, okOld := numberOf(item["sanOld"])
+			sanNew, okNew := numberOf(item["sanNew"])
+			if !okOld || !okNew {
+				continue
+			}
+			if inv.SanChecks == 0 {
+				inv.SanStart = int(sanOld)
+			}
+			inv.SanChecks++
+			inv.SanEnd = int(sanNew)
+			if sanOld > sanNew {
+				inv.SanLost += int(sanOld - sanNew)
+			}
+		case rule == "dnd5e" && cmd == "rc":
+			// 只有天然 20 与天然 1 能判断结果
+			outcome, hasOutcome := numberOf(item["outcome"])
+			judged := hasOutcome && (outcome == 20 || outcome == 1)
+			r := 0
+			if outcome == 20 {
+				r = 4
+			} else if outcome == 1 {
+				r = -2
+			}
+			b.investigator(pcName, rule).addCheck(b.skillName(textOf(item["reason"])), r, judged)
+		}
+	}
+}
+
+// Build 生成报告。Build 之后不应再调用 Add
+func (b *ReportBuilder) Build() *SessionReport {
+	r := b.report
+	r.Duration = r.End - r.Start
+	r.ActiveDuration = r.Duration
+	for _, gap := range r.IdleGaps {
+		r.ActiveDuration -= gap.End - gap.Start
+	}
+
+	buckets := int(r.Duration/r.Bucket) + 1
+	r.Participants = make([]*ParticipantStat, 0, len(b.participants))
+	for _, p := range b.participants {
+		for len(p.Timeline) < buckets {
+			p.Timeline = append(p.Timeline, 0)
+		}
+		r.Participants = append(r.Participants, p)
+	}
+	sort.Slice(r.Participants, func(i, j int) bool {
+		if r.Participants[i].Messages != r.Participants[j].Messages {
+			return r.Participants[i].Messages > r.Participants[j].Messages
+		}
+		return r.Participants[i].IMUserID < r.Participants[j].IMUserID
+	})
+
+	r.Investigators = make([]*InvestigatorStat, 0, len(b.investigators))
+	for _, inv := range b.investigators {
+		inv.Skills = make([]*SkillStat, 0, len(inv.skills))
+		for _, s := range inv.skills {
+			inv.Skills = append(inv.Skills, s)
+		}
+		sort.Slice(inv.Skills, func(i, j int) bool {
+			if inv.Skills[i].Checks != inv.Skills[j].Checks {
+				return inv.Skills[i].Checks > inv.Skills[j].Checks
+			}
+			return inv.Skills[i].Name < inv.Skills[j].Name
+		})
+		r.Investigators = append(r.Investigators, inv)
+	}
+	sort.Slice(r.Investigators, func(i, j int) bool {
+		if r.Investigators[i].Checks != r.Investigators[j].Checks {
+			return r.Investigators[i].Checks > r.Investigators[j].Checks
+		}
+		return r.Investigators[i].Name < r.Investigators[j].Name
+	})
+	return r
+}
+
+// BuildReport 读取日志并生成报告
+func BuildReport(db engine.DatabaseOperator, groupID string, logName string, opts ReportOptions) (*SessionReport, error) {
+	b := NewReportBuilder(groupID, logName, opts)
+	if err := forEachLogBatch(db, groupID, logName, b.Add); err != nil {
+		return nil, err
+	}
+	if b.report.Messages == 0 {
+		return nil, ErrExportEmpty
+	}
+	return b.Build(), nil
+}
+
+func forEachLogBatch(db engine.DatabaseOperator, groupID string, logName string, fn func(items []model.LogOneItem)) error {
+	cursor := paginator.Cursor{}
+	for {
+		lines, next, err := service.LogGetCursorLines(db, groupID, logName, cursor)
+		if err != nil {
+			return err
+		}
+		fn(lines)
+		if next.After == nil {
+			return nil
+		}
+		cursor.After = next.After
+	}
+}
+
+func formatReportDuration(seconds int64) string {
+	d := time.Duration(seconds) * time.Second
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	if h > 0 {
+		return fmt.Sprintf("%d小时%d分", h, m)
+	}
+	return fmt.Sprintf("%d分", m)
+}
+
+func formatRate(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", float64(n)*100/float64(total))
+}
+
+// Text 供骰子直接回复的文字版报告，技能只列出检定次数最多的几项
+func (r *SessionReport) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "【%s】跑团报告\n", r.LogName)
+	fmt.Fprintf(&b, "时间: %s ~ %s\n", time.Unix(r.Start, 0).Format("2006-01-02 15:04"), time.Unix(r.End, 0).Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, "时长: %s，扣除%d段空档(≥%d分钟)后 %s\n",
+		formatReportDuration(r.Duration), len(r.IdleGaps), r.IdleGap/60, formatReportDuration(r.ActiveDuration))
+	fmt.Fprintf(&b, "消息: %d条，其中骰子回复%d条\n", r.Messages, r.DiceMessages)
+
+	players := r.Messages - r.DiceMessages
+	if len(r.Participants) > 0 {
+		b.WriteString("\n发言:\n")
+		for _, p := range r.Participants {
+			fmt.Fprintf(&b, "- %s: %d条(%s) %d字，活跃于 %s-%s\n", p.Name, p.Messages, formatRate(p.Messages, players), p.Chars,
+				time.Unix(p.FirstTime, 0).Format("15:04"), time.Unix(p.LastTime, 0).Format("15:04"))
+		}
+	}
+
+	if len(r.Investigators) > 0 {
+		b.WriteString("\n检定:\n")
+		for _, inv := range r.Investigators {
+			fmt.Fprintf(&b, "- <%s> %d次", inv.Name, inv.Checks)
+			if inv.Judged > 0 {
+				fmt.Fprintf(&b, "，成功率%s，大成功%d，大失败%d", formatRate(inv.Success, inv.Judged), inv.Critical, inv.Fumble)
+			}
+			if inv.SanChecks > 0 {
+				fmt.Fprintf(&b, "，理智 %d➯%d(共损失%d)", inv.SanStart, inv.SanEnd, inv.SanLost)
+			}
+			b.WriteString("\n")
+			var skills []string
+			for i, s := range inv.Skills {
+				if i >= 5 {
+					break
+				}
+				skills = append(skills, fmt.Sprintf("%s%d", s.Name, s.Checks))
+			}
+			if len(skills) > 0 {
+				b.WriteString("  常用: " + strings.Join(skills, " ") + "\n")
+			}
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,83 @@
+package storylog_test
+
+import (
+	"strings"
+	"testing"
+
+	"sealdice-core/dice/storylog"
+	"sealdice-core/model"
+)
+
+func reportTestItems() []model.LogOneItem {
+	sc := func(outcome, rank, sanOld, sanNew float64) map[string]interface{} {
+		return map[string]interface{}{
+			"cmd": "sc", "rule": "coc7", "pcName": "阿尔弗雷德",
+			"items": []interface{}{map[string]interface{}{"outcome": outcome, "rank": rank, "sanOld": sanOld, "sanNew": sanNew}},
+		}
+	}
+	ra := func(skill string, rank float64) map[string]interface{} {
+		return map[string]interface{}{
+			"cmd": "ra", "rule": "coc7", "pcName": "阿尔弗雷德",
+			"items": []interface{}{map[string]interface{}{"expr2": skill, "rank": rank}},
+		}
+	}
+	items := exportTestItems()
+	items = append(items,
+		model.LogOneItem{Nickname: "海豹", IMUserID: "QQ:1", Time: 1700000010, IsDice: true, CommandInfo: ra("侦查50", 4)},
+		model.LogOneItem{Nickname: "海豹", IMUserID: "QQ:1", Time: 1700000011, IsDice: true, CommandInfo: ra("图书馆", -2)},
+		model.LogOneItem{Nickname: "海豹", IMUserID: "QQ:1", Time: 1700000012, IsDice: true, CommandInfo: sc(80, -1, 60, 55)},
+		// 一小时后继续
+		model.LogOneItem{Nickname: "张三", IMUserID: "QQ:100", Time: 1700003612, Message: "继续"},
+		model.LogOneItem{Nickname: "海豹", IMUserID: "QQ:1", Time: 1700003613, IsDice: true, CommandInfo: sc(20, 1, 55, 54)},
+		model.LogOneItem{Nickname: "海豹", IMUserID: "QQ:1", Time: 1700003614, IsDice: true, CommandInfo: map[string]interface{}{
+			"cmd": "rc", "rule": "dnd5e", "pcName": "索菲亚",
+			"items": []interface{}{map[string]interface{}{"reason": "运动", "result": float64(23), "outcome": float64(20)}},
+		}},
+	)
+	return items
+}
+
+func TestReportBuilder(t *testing.T) {
+	b := storylog.NewReportBuilder("QQ-Group:1", "深潜者", storylog.ReportOptions{})
+	b.Add(reportTestItems())
+	r := b.Build()
+
+	if r.Messages != 11 || r.DiceMessages != 7 {
+		t.Errorf("messages = %d/%d, want 11/7", r.Messages, r.DiceMessages)
+	}
+	if r.Duration != 3614 || len(r.IdleGaps) != 1 || r.ActiveDuration != 14 {
+		t.Errorf("duration = %d active = %d gaps = %v", r.Duration, r.ActiveDuration, r.IdleGaps)
+	}
+	if len(r.Participants) != 2 || r.Participants[0].IMUserID != "QQ:100" || r.Participants[0].Messages != 3 {
+		t.Fatalf("unexpected participants: %+v", r.Participants)
+	}
+	if p := r.Participants[0]; p.Commands != 1 || len(p.Timeline) != 3 || p.Timeline[0] != 2 || p.Timeline[2] != 1 {
+		t.Errorf("unexpected participant stat: %+v", p)
+	}
+
+	if len(r.Investigators) != 2 {
+		t.Fatalf("unexpected investigators: %+v", r.Investigators)
+	}
+	inv := r.Investigators[0]
+	// 阿尔弗雷德: 侦查(来自导出样例) + 侦查50 + 图书馆 + 两次理智
+	if inv.Name != "阿尔弗雷德" || inv.Checks != 5 || inv.Critical != 1 || inv.Fumble != 1 || inv.Success != 3 {
+		t.Errorf("unexpected investigator: %+v", inv.CheckStat)
+	}
+	if inv.SanChecks != 2 || inv.SanStart != 60 || inv.SanEnd != 54 || inv.SanLost != 6 {
+		t.Errorf("unexpected san: %+v", inv)
+	}
+	if inv.Skills[0].Name != "侦查" || inv.Skills[0].Checks != 2 {
+		t.Errorf("skill names should drop the trailing value: %+v", inv.Skills[0])
+	}
+	dnd := r.Investigators[1]
+	if dnd.Rule != "dnd5e" || dnd.Checks != 1 || dnd.Judged != 1 || dnd.Critical != 1 {
+		t.Errorf("unexpected dnd investigator: %+v", dnd.CheckStat)
+	}
+
+	text := r.Text()
+	for _, want := range []string{"【深潜者】跑团报告", "张三: 3条", "<阿尔弗雷德> 5次", "理智 60➯54(共损失6)"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text missing %q:\n%s", want, text)
+		}
+	}
+}
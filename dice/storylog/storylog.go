@@ -27,6 +27,8 @@ type UploadEnv struct {
 	UniformID string
 	GroupID   string
 	Token     string
+	// Media 不为空时，本地备份的 zip 中一并打包日志引用的图片与文件
+	Media *MediaStore
 
 	lines  []*model.LogOneItem
 	data   *[]byte
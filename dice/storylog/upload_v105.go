@@ -231,6 +231,37 @@ func formatAndBackupV105(env *UploadEnv, tempLog *os.File, parquetFile *bytes.Bu
 	if _, err = parquetWriter.Write(parquetFile.Bytes()); err != nil {
 		return fmt.Errorf("写入Parquet文件失败: %w", err)
 	}
+	if env.Media != nil {
+		return writeMediaToZip(env, writer)
+	}
+	return nil
+}
+
+// writeMediaToZip 将日志引用的附件写入 media/<hash>，缺失的文件跳过
+func writeMediaToZip(env *UploadEnv, writer *zip.Writer) error {
+	media, err := logMediaHashes(env.Db, env.GroupID, env.LogName)
+	if err != nil {
+		return fmt.Errorf("读取日志附件失败: %w", err)
+	}
+	missing := 0
+	for _, m := range media {
+		f, openErr := env.Media.Open(m.Hash)
+		if openErr != nil {
+			missing++
+			continue
+		}
+		w, createErr := writer.CreateHeader(&zip.FileHeader{Name: ExportMediaDir + m.Hash, Method: zip.Store})
+		if createErr == nil {
+			_, createErr = io.Copy(w, f)
+		}
+		_ = f.Close()
+		if createErr != nil {
+			return fmt.Errorf("写入日志附件失败: %w", createErr)
+		}
+	}
+	if missing > 0 {
+		env.appendNotice(fmt.Sprintf("有 %d 个图片或文件已不在本地，未能打包", missing))
+	}
 	return nil
 }
 
@@ -299,7 +299,7 @@ func GetCtxProxyAtPos(ctx *MsgContext, cmdArgs *CmdArgs, pos int) *MsgContext {
 	return GetCtxProxyAtPosRaw(ctx, cmdArgs, pos, true)
 }
 
-// GetCtxProxyAtPosRaw 等着后续再加 @名字 版本，以及 @team 版本
+// GetCtxProxyAtPosRaw 依次对应 @某人 与 @npc:名字，等着后续再加 @team 版本
 func GetCtxProxyAtPosRaw(ctx *MsgContext, cmdArgs *CmdArgs, pos int, setTempVar bool) *MsgContext {
 	cur := 0
 	for _, i := range cmdArgs.At {
@@ -350,6 +350,25 @@ func GetCtxProxyAtPosRaw(ctx *MsgContext, cmdArgs *CmdArgs, pos int, setTempVar
 		}
 		return mctx
 	}
+
+	// 其次是 @npc:名字 引用的卡
+	if len(cmdArgs.AtSheets) > 0 && ctx.Dice != nil && ctx.Dice.AttrsManager != nil {
+		am := ctx.Dice.AttrsManager
+		for _, name := range cmdArgs.AtSheets {
+			if pos != cur {
+				cur++
+				continue
+			}
+			mctx, err := am.SheetProxyCtx(ctx, name)
+			if err != nil {
+				break
+			}
+			if setTempVar {
+				SetTempVars(mctx, ctx.Player.Name)
+			}
+			return mctx
+		}
+	}
 	ctx.DelegateText = ""
 	return ctx
 }
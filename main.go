@@ -2,6 +2,7 @@ package main
 
 // _ "net/http/pprof"
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/fs"
@@ -110,6 +111,9 @@ func cleanupCreate(diceManager *dice.DiceManager) func() {
 		if diceManager.Help != nil {
 			diceManager.Help.Close()
 		}
+		if diceManager.LogSearch != nil {
+			diceManager.LogSearch.Close()
+		}
 		if diceManager.IsReady {
 			diceManager.Save()
 		}
@@ -171,11 +175,25 @@ func main() {
 		UpdateTest             bool   `description:"更新测试"                                                            long:"update-test"`
 		LogLevel               int8   `choice:"-1"                                                                   choice:"0"              choice:"1" choice:"2" choice:"3" choice:"4" choice:"5" default:"0" description:"设置日志等级"             long:"log-level"`
 		ContainerMode          bool   `description:"容器模式，该模式下禁用内置客户端"                                                long:"container-mode"`
+		Migrations             bool   `description:"列出已执行与待执行的数据升级"                                                  long:"migrations"`
+		MigrateDryRun          bool   `description:"预览待执行的数据升级将产生的改动，不做任何修改"                                         long:"migrate-dry-run"`
+		MigrateRollback        string `description:"回滚指定ID及其之后执行的数据升级，回滚前会自动备份"                                      long:"migrate-rollback"`
 	}
 	// pprof
 	// go func() {
 	//	http.ListenAndServe("0.0.0.0:8899", nil)
 	// }()
+	// 扩展包开发工具不需要加载数据
+	if len(os.Args) > 1 && os.Args[1] == "pack" {
+		os.Exit(runPackCommand(os.Args[2:]))
+	}
+	// 跨数据库迁移自行加锁，要求海豹未在运行
+	if len(os.Args) > 1 && os.Args[1] == "migrate-db" {
+		os.Exit(runDBMigrateCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "story-backend" {
+		os.Exit(runStoryBackendCommand(os.Args[2:]))
+	}
 	// 读取命令行传参
 	_, err := flags.ParseArgs(&opts, os.Args)
 	if err != nil {
@@ -246,9 +264,13 @@ func main() {
 	// 提早初始化是为了读取ServiceName
 
 	// diceManager初始化数据库
-	operator, err := dboperator.GetDatabaseOperator()
+	// 退出时取消，数据库关闭超时后据此中止未完成的查询
+	dbCtx, cancelDB := context.WithCancel(context.Background())
+	defer cancelDB()
+	operator, err := dboperator.GetDatabaseOperator(dbCtx)
 	if err != nil {
 		log.Errorf("Failed to init database: %v", err)
+		showMsgBox("数据库初始化失败", err.Error())
 		return
 	}
 	diceManager := &dice.DiceManager{
@@ -411,7 +433,22 @@ func main() {
 	//	log.Fatalf("您的146数据库可能存在问题，为保护数据，已经停止执行150升级命令。请尝试联系开发者，并提供你的日志。\n"+
 	//		"数据已回滚，您可暂时使用旧版本等待进一步的修复和更新。您的报错内容为: %v", err)
 	// }
-	err = v2.InitUpgrader(operator)
+	if opts.Migrations || opts.MigrateDryRun || opts.MigrateRollback != "" {
+		switch {
+		case opts.Migrations:
+			err = printMigrationStatus(operator)
+		case opts.MigrateDryRun:
+			err = printMigrationDryRun(operator)
+		default:
+			err = runMigrationRollback(diceManager, operator, opts.MigrateRollback)
+		}
+		if err != nil {
+			log.Errorf("升级管理操作失败: %v", err)
+		}
+		return
+	}
+
+	err = v2.InitUpgrader(operator, backupBeforeUpgrade(diceManager, "执行升级"))
 	if err != nil {
 		log.Warnf("升级流程出现问题，请检查，问题为: %v", err)
 	}
@@ -1,7 +1,7 @@
 package v2
 
 import (
-	_ "time"
+	"time"
 
 	v120 "sealdice-core/migrate/v2/v120"
 	v131 "sealdice-core/migrate/v2/v131"
@@ -10,13 +10,20 @@ import (
 	v150 "sealdice-core/migrate/v2/v150"
 	v151 "sealdice-core/migrate/v2/v151"
 	v160 "sealdice-core/migrate/v2/v160"
+	"sealdice-core/utils/constant"
 	operator "sealdice-core/utils/dboperator/engine"
 	upgrade "sealdice-core/utils/upgrader"
 	"sealdice-core/utils/upgrader/store"
 )
 
-func InitUpgrader(operator operator.DatabaseOperator) error {
-	storer := store.NewJSONStore("upgrade_metadata.json")
+// MetadataFile 升级记录文件，位于工作目录；跨数据库迁移的记录保存在目标库的 upgrade_metadata 表中
+const MetadataFile = "upgrade_metadata.json"
+
+func newManager(operator operator.DatabaseOperator) *upgrade.Manager {
+	storer := &store.LayeredStore{
+		Local:    store.NewJSONStore(MetadataFile),
+		Database: store.NewDBStore(operator.GetDataDB(constant.WRITE)),
+	}
 	mgr := &upgrade.Manager{Store: storer, Database: operator}
 	// V120注册
 	mgr.Register(v120.V120Migration)
@@ -35,9 +42,68 @@ func InitUpgrader(operator operator.DatabaseOperator) error {
 	// v160注册
 	mgr.Register(v160.V160LogIDZeroCleanMigration)
 	mgr.Register(v160.V160LogRawMsgIDIndexMigration)
+	mgr.Register(v160.V160LogMediaColumnMigration)
+	mgr.Register(v160.V160AttrsHistoryMigration)
+	mgr.Register(v160.V160AttrsShareMigration)
+	return mgr
+}
+
+// InitUpgrader 执行全部待执行的升级；存在待执行升级时先调用 beforeChange（可为 nil），一般用于升级前备份
+func InitUpgrader(operator operator.DatabaseOperator, beforeChange func(upgrades []upgrade.Upgrade) error) error {
+	mgr := newManager(operator)
+	mgr.BeforeChange = beforeChange
 	err := mgr.ApplyAll()
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// Status 列出全部升级及其执行状态
+func Status(operator operator.DatabaseOperator) ([]upgrade.Status, error) {
+	return newManager(operator).Status()
+}
+
+// DryRun 预览待执行升级会产生的改动
+func DryRun(operator operator.DatabaseOperator) ([]upgrade.PlanResult, error) {
+	return newManager(operator).DryRun()
+}
+
+// Rollback 撤销 id 及其之后执行的全部升级，确认可以回滚后、开始撤销前调用 beforeChange（可为 nil）
+func Rollback(operator operator.DatabaseOperator, id string, logf func(string), beforeChange func(upgrades []upgrade.Upgrade) error) ([]string, error) {
+	mgr := newManager(operator)
+	mgr.BeforeChange = beforeChange
+	return mgr.Rollback(id, logf)
+}
+
+// PrepareCopyTarget 为跨数据库迁移的目标库建立与当前版本一致的表结构与索引
+func PrepareCopyTarget(operator operator.DatabaseOperator, logf func(string)) error {
+	if err := v150.InitSchema(operator, logf); err != nil {
+		return err
+	}
+	if err := v160.V160LogRawMsgIDIndexMigrate(operator, logf); err != nil {
+		return err
+	}
+	if err := v160.V160AttrsHistoryMigrate(operator, logf); err != nil {
+		return err
+	}
+	return v160.V160AttrsShareMigrate(operator, logf)
+}
+
+// RecordCopy 跨数据库迁移完成后调用：目标库中的数据已是升级后的状态，
+// 在目标库中将全部升级标记为已执行，并追加一条迁移记录，切换到目标库启动时不再重复升级。
+// 记录只写入目标库，不改动当前工作目录中的升级记录文件。
+func RecordCopy(operator operator.DatabaseOperator, id string, logs []string) error {
+	mgr := newManager(operator)
+	mgr.Store = store.NewDBStore(operator.GetDataDB(constant.WRITE))
+	if _, err := mgr.MarkApplied("由跨数据库迁移标记"); err != nil {
+		return err
+	}
+	return mgr.Store.SaveRecord(upgrade.UpgradeRecord{
+		ID:        id,
+		Timestamp: time.Now(),
+		Success:   true,
+		Message:   "成功",
+		Logs:      logs,
+	})
+}
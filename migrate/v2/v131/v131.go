@@ -25,4 +25,15 @@ var V131ConfigUpdateMigration = upgrade.Upgrade{
 		logf("[INFO] V131配置文件迁移自定义文案升级完成")
 		return nil
 	},
+	Plan: func(operator engine.DatabaseOperator) (*upgrade.Plan, error) {
+		keys, err := V131DeprecatedConfigPending()
+		if err != nil {
+			return nil, err
+		}
+		plan := &upgrade.Plan{ConfigKeys: keys}
+		if len(keys) > 0 {
+			plan.Notes = append(plan.Notes, "写入 configs/text-template.yaml，原文件备份为 text-template.yaml.bak")
+		}
+		return plan, nil
+	},
 }
@@ -145,3 +145,41 @@ func V131DeprecatedConfig2CustomText() error {
 
 	return nil
 }
+
+// V131DeprecatedConfigPending 返回 serve.yaml 中将被迁移到自定义文案并删除的配置项
+func V131DeprecatedConfigPending() ([]string, error) {
+	confPath := filepath.Clean("./data/default/serve.yaml")
+	customTextPath := filepath.Clean("./data/default/configs/text-template.yaml")
+	if _, err := os.Stat(confPath); err != nil {
+		return nil, nil //nolint:nilerr
+	}
+	if _, err := os.Stat(customTextPath); err != nil {
+		return nil, nil //nolint:nilerr
+	}
+	confData, err := os.ReadFile(confPath)
+	if err != nil {
+		return nil, err
+	}
+	conf := v131DeprecatedConfig{}
+	if err = yaml.Unmarshal(confData, &conf); err != nil {
+		return nil, err
+	}
+	// 与 V131DeprecatedConfig2CustomText 的判断一致：没有需要迁移的文本时不会改写任何文件
+	needUpdate := conf.HelpMasterInfo != "" || conf.HelpMasterLicense != "" || conf.CustomBotExtraText != "" ||
+		(conf.CustomDrawKeysText != "" && conf.CustomDrawKeysTextEnable)
+	if !needUpdate {
+		return nil, nil
+	}
+
+	raw := make(map[string]interface{})
+	if err = yaml.Unmarshal(confData, &raw); err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, key := range []string{"helpMasterInfo", "helpMasterLicense", "customBotExtraText", "customDrawKeysText", "customDrawKeysTextEnable"} {
+		if _, ok := raw[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
@@ -28,4 +28,18 @@ var V141ConfigUpdateMigration = upgrade.Upgrade{
 		logf("[INFO] V141已弃用配置项进行重命名升级完成")
 		return nil
 	},
+	Down: func(logf func(string), operator engine.DatabaseOperator) error {
+		if err := V141DeprecatedConfigRevert(); err != nil {
+			return err
+		}
+		logf("[INFO] V141配置项已改回旧名称")
+		return nil
+	},
+	Plan: func(operator engine.DatabaseOperator) (*upgrade.Plan, error) {
+		keys, err := V141DeprecatedConfigPending()
+		if err != nil {
+			return nil, err
+		}
+		return &upgrade.Plan{ConfigKeys: keys}, nil
+	},
 }
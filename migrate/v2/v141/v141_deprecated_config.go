@@ -8,48 +8,77 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+var v141ConfigPath = filepath.Clean("./data/default/serve.yaml")
+
+// v141Renames 旧字段名 -> 新字段名
+var v141Renames = [][2]string{
+	{"customReplenishRate", "personalReplenishRate"},
+	{"customBurst", "personalBurst"},
+}
+
 func V141DeprecatedConfigRename() error {
-	var err error
-	var confp = filepath.Clean("./data/default/serve.yaml")
+	_, err := v141RenameKeys(v141Renames, false)
+	return err
+}
+
+// V141DeprecatedConfigRevert 将字段名改回旧版本使用的名字
+func V141DeprecatedConfigRevert() error {
+	reversed := make([][2]string, 0, len(v141Renames))
+	for _, pair := range v141Renames {
+		reversed = append(reversed, [2]string{pair[1], pair[0]})
+	}
+	_, err := v141RenameKeys(reversed, false)
+	return err
+}
 
-	if _, err = os.Stat(confp); err != nil {
+// V141DeprecatedConfigPending 返回配置文件中仍需重命名的旧字段
+func V141DeprecatedConfigPending() ([]string, error) {
+	return v141RenameKeys(v141Renames, true)
+}
+
+// v141RenameKeys 按 pairs 重命名配置项，返回被重命名的字段；dryRun 时不写回文件
+func v141RenameKeys(pairs [][2]string, dryRun bool) ([]string, error) {
+	if _, err := os.Stat(v141ConfigPath); err != nil {
 		// No renaming is needed if config hasn't been created.
 		if errors.Is(err, os.ErrNotExist) {
-			return nil
+			return nil, nil
 		}
-		return err
+		return nil, err
 	}
 
-	content, err := os.ReadFile(confp)
+	content, err := os.ReadFile(v141ConfigPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// TODO: Rename fields without reading the whole config file?
 	var data map[string]any
 	err = yaml.Unmarshal(content, &data)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if rate, ok := data["customReplenishRate"]; ok {
-		data["personalReplenishRate"] = rate
-		delete(data, "customReplenishRate")
+	var renamed []string
+	for _, pair := range pairs {
+		if v, ok := data[pair[0]]; ok {
+			data[pair[1]] = v
+			delete(data, pair[0])
+			renamed = append(renamed, pair[0])
+		}
 	}
-	if burst, ok := data["customBurst"]; ok {
-		data["personalBurst"] = burst
-		delete(data, "customBurst")
+	if dryRun || len(renamed) == 0 {
+		return renamed, nil
 	}
 
 	modified, err := yaml.Marshal(data)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	err = os.WriteFile(confp, modified, 0644)
+	err = os.WriteFile(v141ConfigPath, modified, 0644)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return renamed, nil
 }
@@ -487,6 +487,17 @@ func V150AttrsMigrate(dboperator operator.DatabaseOperator, logf func(string)) e
 	return nil
 }
 
+// InitSchema 只建立 data、censor、logs 三个库的表结构，不做旧数据转换，用于准备一个空的目标库
+func InitSchema(dboperator operator.DatabaseOperator, logf func(string)) error {
+	if err := dataDBInit(dboperator, logf); err != nil {
+		return err
+	}
+	if err := censorDBInit(dboperator, logf); err != nil {
+		return err
+	}
+	return logDBInit(dboperator, logf)
+}
+
 const createSql = `
 CREATE TABLE attrs__temp (
     id TEXT PRIMARY KEY,
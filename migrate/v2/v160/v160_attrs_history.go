@@ -0,0 +1,71 @@
+package v160
+
+import (
+	"fmt"
+
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	operator "sealdice-core/utils/dboperator/engine"
+	upgrade "sealdice-core/utils/upgrader"
+)
+
+// V160AttrsHistoryMigrate 建立 attrs_history 表，保存人物卡的修改记录
+func V160AttrsHistoryMigrate(dboperator operator.DatabaseOperator, logf func(string)) error {
+	db := dboperator.GetDataDB(constant.WRITE)
+	if db.Migrator().HasTable(&model.AttributesHistoryModel{}) {
+		logf("数据修复 - attrs_history 表已存在，无需处理")
+		return nil
+	}
+	if err := db.Migrator().CreateTable(&model.AttributesHistoryModel{}); err != nil {
+		return err
+	}
+	logf("数据修复 - 已建立 attrs_history 表")
+	return nil
+}
+
+// V160AttrsHistoryPlan 预览：attrs_history 表不存在时将建立该表
+func V160AttrsHistoryPlan(dboperator operator.DatabaseOperator) (*upgrade.Plan, error) {
+	plan := &upgrade.Plan{}
+	db := dboperator.GetDataDB(constant.READ)
+	if db.Migrator().HasTable(&model.AttributesHistoryModel{}) {
+		plan.Notes = append(plan.Notes, "attrs_history 表已存在，无需处理")
+		return plan, nil
+	}
+	plan.Tables = append(plan.Tables, "attrs_history")
+	plan.Notes = append(plan.Notes, "建立 attrs_history 表")
+	return plan, nil
+}
+
+// V160AttrsHistoryRevert 删除 attrs_history 表，人物卡的修改记录会丢失，卡片本身不受影响
+func V160AttrsHistoryRevert(dboperator operator.DatabaseOperator, logf func(string)) error {
+	db := dboperator.GetDataDB(constant.WRITE)
+	if !db.Migrator().HasTable(&model.AttributesHistoryModel{}) {
+		logf("数据回滚 - attrs_history 表不存在，无需处理")
+		return nil
+	}
+	if err := db.Migrator().DropTable(&model.AttributesHistoryModel{}); err != nil {
+		return err
+	}
+	logf("数据回滚 - 已删除 attrs_history 表")
+	return nil
+}
+
+var V160AttrsHistoryMigration = upgrade.Upgrade{
+	ID: "008c_V160AttrsHistoryMigration",
+	Description: `
+# 升级说明
+增加 attrs_history 表，记录人物卡的修改历史，用于 .pc history 与 .st undo
+`,
+	Apply: func(logf func(string), operator operator.DatabaseOperator) error {
+		logf(fmt.Sprintf("[INFO] V160人物卡历史升级开始 type=%s", operator.Type()))
+		if err := V160AttrsHistoryMigrate(operator, logf); err != nil {
+			return err
+		}
+		logf("[INFO] V160人物卡历史升级处置完毕")
+		return nil
+	},
+	Down: func(logf func(string), operator operator.DatabaseOperator) error {
+		return V160AttrsHistoryRevert(operator, logf)
+	},
+	Plan: V160AttrsHistoryPlan,
+}
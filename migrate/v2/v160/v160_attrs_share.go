@@ -0,0 +1,71 @@
+package v160
+
+import (
+	"fmt"
+
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	operator "sealdice-core/utils/dboperator/engine"
+	upgrade "sealdice-core/utils/upgrader"
+)
+
+// V160AttrsShareMigrate 建立 attrs_shares 表，保存人物卡的共享记录
+func V160AttrsShareMigrate(dboperator operator.DatabaseOperator, logf func(string)) error {
+	db := dboperator.GetDataDB(constant.WRITE)
+	if db.Migrator().HasTable(&model.AttributesShareModel{}) {
+		logf("数据修复 - attrs_shares 表已存在，无需处理")
+		return nil
+	}
+	if err := db.Migrator().CreateTable(&model.AttributesShareModel{}); err != nil {
+		return err
+	}
+	logf("数据修复 - 已建立 attrs_shares 表")
+	return nil
+}
+
+// V160AttrsSharePlan 预览：attrs_shares 表不存在时将建立该表
+func V160AttrsSharePlan(dboperator operator.DatabaseOperator) (*upgrade.Plan, error) {
+	plan := &upgrade.Plan{}
+	db := dboperator.GetDataDB(constant.READ)
+	if db.Migrator().HasTable(&model.AttributesShareModel{}) {
+		plan.Notes = append(plan.Notes, "attrs_shares 表已存在，无需处理")
+		return plan, nil
+	}
+	plan.Tables = append(plan.Tables, "attrs_shares")
+	plan.Notes = append(plan.Notes, "建立 attrs_shares 表")
+	return plan, nil
+}
+
+// V160AttrsShareRevert 删除 attrs_shares 表，人物卡的共享记录会丢失，卡片本身不受影响
+func V160AttrsShareRevert(dboperator operator.DatabaseOperator, logf func(string)) error {
+	db := dboperator.GetDataDB(constant.WRITE)
+	if !db.Migrator().HasTable(&model.AttributesShareModel{}) {
+		logf("数据回滚 - attrs_shares 表不存在，无需处理")
+		return nil
+	}
+	if err := db.Migrator().DropTable(&model.AttributesShareModel{}); err != nil {
+		return err
+	}
+	logf("数据回滚 - 已删除 attrs_shares 表")
+	return nil
+}
+
+var V160AttrsShareMigration = upgrade.Upgrade{
+	ID: "008d_V160AttrsShareMigration",
+	Description: `
+# 升级说明
+增加 attrs_shares 表，记录人物卡共享给哪些用户及其权限，用于 .pc share 与 @npc 引用
+`,
+	Apply: func(logf func(string), operator operator.DatabaseOperator) error {
+		logf(fmt.Sprintf("[INFO] V160人物卡共享升级开始 type=%s", operator.Type()))
+		if err := V160AttrsShareMigrate(operator, logf); err != nil {
+			return err
+		}
+		logf("[INFO] V160人物卡共享升级处置完毕")
+		return nil
+	},
+	Down: func(logf func(string), operator operator.DatabaseOperator) error {
+		return V160AttrsShareRevert(operator, logf)
+	},
+	Plan: V160AttrsSharePlan,
+}
@@ -39,6 +39,48 @@ func V160LogRawMsgIDIndexMigrate(dboperator operator.DatabaseOperator, logf func
 	return nil
 }
 
+func hasLogRawMsgIDIndex(dboperator operator.DatabaseOperator) bool {
+	db := dboperator.GetLogDB(constant.READ)
+	if dboperator.Type() == constant.MYSQL {
+		return db.Migrator().HasIndex(&model.LogOneItemHookMySQL{}, "idx_log_delete_by_id")
+	}
+	return db.Migrator().HasIndex(&model.LogOneItem{}, "idx_log_delete_by_id")
+}
+
+// V160LogRawMsgIDIndexPlan 预览：索引不存在时将为 log_items 建立复合索引
+func V160LogRawMsgIDIndexPlan(dboperator operator.DatabaseOperator) (*upgrade.Plan, error) {
+	plan := &upgrade.Plan{}
+	if !dboperator.GetLogDB(constant.READ).Migrator().HasTable(&model.LogOneItem{}) {
+		plan.Notes = append(plan.Notes, "log_items 表不存在，无需处理")
+		return plan, nil
+	}
+	if hasLogRawMsgIDIndex(dboperator) {
+		plan.Notes = append(plan.Notes, "复合索引已存在，无需处理")
+		return plan, nil
+	}
+	plan.Tables = append(plan.Tables, "log_items")
+	plan.Notes = append(plan.Notes, "创建索引 idx_log_delete_by_id(group_id, raw_msg_id, id)")
+	return plan, nil
+}
+
+// V160LogRawMsgIDIndexRevert 删除升级建立的复合索引
+func V160LogRawMsgIDIndexRevert(dboperator operator.DatabaseOperator, logf func(string)) error {
+	db := dboperator.GetLogDB(constant.WRITE)
+	if !db.Migrator().HasTable(&model.LogOneItem{}) || !hasLogRawMsgIDIndex(dboperator) {
+		logf("数据回滚 - LogItems表，复合索引不存在，无需处理")
+		return nil
+	}
+	stmt := "DROP INDEX IF EXISTS idx_log_delete_by_id"
+	if dboperator.Type() == constant.MYSQL {
+		stmt = "DROP INDEX idx_log_delete_by_id ON log_items"
+	}
+	if err := db.Exec(stmt).Error; err != nil {
+		return err
+	}
+	logf("数据回滚 - LogItems表，已删除复合索引 idx_log_delete_by_id")
+	return nil
+}
+
 var V160LogRawMsgIDIndexMigration = upgrade.Upgrade{
 	ID: "008a_V160LogRawMsgIDIndexMigration",
 	Description: `
@@ -54,4 +96,8 @@ var V160LogRawMsgIDIndexMigration = upgrade.Upgrade{
 		logf("[INFO] V160日志索引修复处置完毕")
 		return nil
 	},
+	Down: func(logf func(string), operator operator.DatabaseOperator) error {
+		return V160LogRawMsgIDIndexRevert(operator, logf)
+	},
+	Plan: V160LogRawMsgIDIndexPlan,
 }
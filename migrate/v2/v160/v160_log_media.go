@@ -0,0 +1,88 @@
+package v160
+
+import (
+	"fmt"
+
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	operator "sealdice-core/utils/dboperator/engine"
+	upgrade "sealdice-core/utils/upgrader"
+)
+
+func logItemModel(dboperator operator.DatabaseOperator) interface{} {
+	if dboperator.Type() == constant.MYSQL {
+		return &model.LogOneItemHookMySQL{}
+	}
+	return &model.LogOneItem{}
+}
+
+// V160LogMediaColumnMigrate 为 log_items 增加 media 列，记录消息中的图片与文件
+func V160LogMediaColumnMigrate(dboperator operator.DatabaseOperator, logf func(string)) error {
+	db := dboperator.GetLogDB(constant.WRITE)
+	m := logItemModel(dboperator)
+	if !db.Migrator().HasTable(m) {
+		return nil
+	}
+	if db.Migrator().HasColumn(m, "media") {
+		logf("数据修复 - LogItems表，media 列已存在，无需处理")
+		return nil
+	}
+	if err := db.Migrator().AddColumn(m, "MediaStr"); err != nil {
+		return err
+	}
+	logf("数据修复 - LogItems表，已增加 media 列")
+	return nil
+}
+
+// V160LogMediaColumnPlan 预览：media 列不存在时将为 log_items 增加该列
+func V160LogMediaColumnPlan(dboperator operator.DatabaseOperator) (*upgrade.Plan, error) {
+	plan := &upgrade.Plan{}
+	db := dboperator.GetLogDB(constant.READ)
+	m := logItemModel(dboperator)
+	if !db.Migrator().HasTable(m) {
+		plan.Notes = append(plan.Notes, "log_items 表不存在，无需处理")
+		return plan, nil
+	}
+	if db.Migrator().HasColumn(m, "media") {
+		plan.Notes = append(plan.Notes, "media 列已存在，无需处理")
+		return plan, nil
+	}
+	plan.Tables = append(plan.Tables, "log_items")
+	plan.Notes = append(plan.Notes, "增加 media 列")
+	return plan, nil
+}
+
+// V160LogMediaColumnRevert 删除 media 列，已记录的图片与文件信息会丢失，文件本身保留在 log-media 目录
+func V160LogMediaColumnRevert(dboperator operator.DatabaseOperator, logf func(string)) error {
+	db := dboperator.GetLogDB(constant.WRITE)
+	m := logItemModel(dboperator)
+	if !db.Migrator().HasTable(m) || !db.Migrator().HasColumn(m, "media") {
+		logf("数据回滚 - LogItems表，media 列不存在，无需处理")
+		return nil
+	}
+	if err := db.Migrator().DropColumn(m, "media"); err != nil {
+		return err
+	}
+	logf("数据回滚 - LogItems表，已删除 media 列")
+	return nil
+}
+
+var V160LogMediaColumnMigration = upgrade.Upgrade{
+	ID: "008b_V160LogMediaColumnMigration",
+	Description: `
+# 升级说明
+日志消息增加 media 列，用于记录消息中的图片与文件
+`,
+	Apply: func(logf func(string), operator operator.DatabaseOperator) error {
+		logf(fmt.Sprintf("[INFO] V160日志媒体列升级开始 type=%s", operator.Type()))
+		if err := V160LogMediaColumnMigrate(operator, logf); err != nil {
+			return err
+		}
+		logf("[INFO] V160日志媒体列升级处置完毕")
+		return nil
+	},
+	Down: func(logf func(string), operator operator.DatabaseOperator) error {
+		return V160LogMediaColumnRevert(operator, logf)
+	},
+	Plan: V160LogMediaColumnPlan,
+}
@@ -71,6 +71,34 @@ func V160LogIDZeroCleanMigrate(dboperator operator.DatabaseOperator, logf func(s
 	return nil
 }
 
+// V160LogIDZeroCleanPlan 预览：统计将被删除的 log_id = 0 行，删除后各日志的 size 会被重算
+func V160LogIDZeroCleanPlan(dboperator operator.DatabaseOperator) (*upgrade.Plan, error) {
+	db := dboperator.GetLogDB(constant.READ)
+	migrator := db.Migrator()
+	plan := &upgrade.Plan{}
+	if !migrator.HasTable(&model.LogInfo{}) || !migrator.HasTable(&model.LogOneItem{}) {
+		return plan, nil
+	}
+
+	var items, logs int64
+	if err := db.Model(&model.LogOneItem{}).Where("log_id = 0").Count(&items).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&model.LogInfo{}).Where("id = 0").Count(&logs).Error; err != nil {
+		return nil, err
+	}
+	if items == 0 && logs == 0 {
+		return plan, nil
+	}
+	plan.Tables = []string{"log_items", "logs"}
+	plan.Rows = items + logs
+	plan.Notes = append(plan.Notes,
+		fmt.Sprintf("删除 log_items 中 %d 条、logs 中 %d 条 log_id = 0 的记录，并重算各日志的 size", items, logs),
+		"删除不可回滚，需要时请从升级前备份恢复",
+	)
+	return plan, nil
+}
+
 var V160LogIDZeroCleanMigration = upgrade.Upgrade{
 	ID: "008_V160LogIDZeroCleanMigration",
 	Description: `
@@ -86,4 +114,5 @@ var V160LogIDZeroCleanMigration = upgrade.Upgrade{
 		logf("[INFO] V160清理log_id=0数据处置完毕")
 		return nil
 	},
+	Plan: V160LogIDZeroCleanPlan,
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"sealdice-core/dice"
+	"sealdice-core/logger"
+	v2 "sealdice-core/migrate/v2"
+	"sealdice-core/utils/dboperator/engine"
+	upgrade "sealdice-core/utils/upgrader"
+)
+
+// 升级管理: --migrations 列出升级状态，--migrate-dry-run 预览待执行升级，--migrate-rollback <ID> 回滚
+
+// backupBeforeUpgrade 执行或回滚升级前先做一次基础备份，出错时可从 backups 目录恢复
+func backupBeforeUpgrade(dm *dice.DiceManager, action string) func(upgrades []upgrade.Upgrade) error {
+	return func(upgrades []upgrade.Upgrade) error {
+		// 全新安装时没有需要保护的数据
+		if len(dm.Dice) == 0 {
+			return nil
+		}
+		ids := make([]string, 0, len(upgrades))
+		for _, up := range upgrades {
+			ids = append(ids, up.ID)
+		}
+		fn, err := dm.Backup(dice.BackupSelectionBasic, false)
+		if err != nil {
+			return fmt.Errorf("备份失败: %w", err)
+		}
+		logger.M().Infof("即将%s %s，已备份当前数据到 %s", action, strings.Join(ids, ", "), fn)
+		return nil
+	}
+}
+
+func printMigrationStatus(operator engine.DatabaseOperator) error {
+	list, err := v2.Status(operator)
+	if err != nil {
+		return err
+	}
+	for _, st := range list {
+		state := "待执行"
+		if st.Applied {
+			state = "已执行 " + st.Record.Timestamp.Format("2006-01-02 15:04:05")
+			if !st.Record.Success {
+				state += " (失败: " + st.Record.Message + ")"
+			}
+		}
+		reversible := ""
+		if st.Reversible {
+			reversible = " [可回滚]"
+		}
+		fmt.Fprintf(os.Stdout, "%s\t%s%s\n", st.ID, state, reversible)
+	}
+	return nil
+}
+
+func printMigrationDryRun(operator engine.DatabaseOperator) error {
+	results, err := v2.DryRun(operator)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(os.Stdout, "没有待执行的升级")
+		return nil
+	}
+	for _, res := range results {
+		fmt.Fprintln(os.Stdout, res.ID)
+		plan := res.Plan
+		if plan.Empty() && len(plan.Notes) == 0 {
+			fmt.Fprintln(os.Stdout, "  无改动")
+		}
+		if len(plan.Tables) > 0 {
+			fmt.Fprintf(os.Stdout, "  数据表: %s\n", strings.Join(plan.Tables, ", "))
+		}
+		if plan.Rows > 0 {
+			fmt.Fprintf(os.Stdout, "  影响行数: %d\n", plan.Rows)
+		}
+		if len(plan.ConfigKeys) > 0 {
+			fmt.Fprintf(os.Stdout, "  配置项: %s\n", strings.Join(plan.ConfigKeys, ", "))
+		}
+		for _, note := range plan.Notes {
+			fmt.Fprintf(os.Stdout, "  %s\n", note)
+		}
+	}
+	return nil
+}
+
+func runMigrationRollback(dm *dice.DiceManager, operator engine.DatabaseOperator, id string) error {
+	reverted, err := v2.Rollback(operator, id, func(s string) {
+		fmt.Fprintln(os.Stdout, s)
+	}, backupBeforeUpgrade(dm, "回滚升级"))
+	if len(reverted) > 0 {
+		fmt.Fprintf(os.Stdout, "已回滚: %s\n", strings.Join(reverted, ", "))
+	}
+	return err
+}
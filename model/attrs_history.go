@@ -0,0 +1,53 @@
+package model
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// AttributesHistoryModel 人物卡的一次修改记录。Data 为修改前的完整卡数据，撤销或回滚时直接写回
+type AttributesHistoryModel struct {
+	ID        uint64 `gorm:"column:id;primaryKey;autoIncrement"                       json:"id"`
+	AttrsID   string `gorm:"column:attrs_id;size:255;index:idx_attrs_history_attrs_id" json:"attrsId"`
+	Data      []byte `gorm:"column:data"                                              json:"-"`
+	SheetType string `gorm:"column:sheet_type"                                        json:"sheetType"` // 修改前的卡片类型
+	ActorID   string `gorm:"column:actor_id"                                          json:"actorId"`   // 修改人的 UniformID，由后台修改时为空
+	ActorName string `gorm:"column:actor_name"                                        json:"actorName"`
+	Command   string `gorm:"column:command"                                           json:"command"` // 触发修改的指令原文
+	CreatedAt int64  `gorm:"column:created_at"                                        json:"createdAt"`
+
+	Changes    []AttrsChange `gorm:"-"            json:"changes"`
+	ChangesStr string        `gorm:"column:changes" json:"-"`
+}
+
+func (*AttributesHistoryModel) TableName() string {
+	return "attrs_history"
+}
+
+// AttrsChange 一项属性的变化，新增时 Old 为空，删除时 New 为空
+type AttrsChange struct {
+	Key string `json:"key"`
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+func (m *AttributesHistoryModel) BeforeSave(_ *gorm.DB) error {
+	if m.Changes == nil {
+		m.ChangesStr = ""
+		return nil
+	}
+	data, err := json.Marshal(m.Changes)
+	if err != nil {
+		return err
+	}
+	m.ChangesStr = string(data)
+	return nil
+}
+
+func (m *AttributesHistoryModel) AfterFind(_ *gorm.DB) error {
+	if m.ChangesStr == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(m.ChangesStr), &m.Changes)
+}
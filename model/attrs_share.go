@@ -0,0 +1,20 @@
+package model
+
+// 人物卡共享权限
+const (
+	AttrsShareRead = "read" // 只读，可以在检定中引用
+	AttrsShareEdit = "edit" // 可以修改属性
+)
+
+// AttributesShareModel 人物卡共享记录：把卡共享给某个用户，同一张卡对同一用户只有一条记录
+type AttributesShareModel struct {
+	ID         uint64 `gorm:"column:id;primaryKey;autoIncrement"                                                        json:"id"`
+	AttrsID    string `gorm:"column:attrs_id;size:255;uniqueIndex:idx_attrs_shares_attrs_user"                          json:"attrsId"`
+	UserID     string `gorm:"column:user_id;size:255;uniqueIndex:idx_attrs_shares_attrs_user;index:idx_attrs_shares_user" json:"userId"`
+	Permission string `gorm:"column:permission"                                                                         json:"permission"` // read 或 edit
+	CreatedAt  int64  `gorm:"column:created_at"                                                                         json:"createdAt"`
+}
+
+func (*AttributesShareModel) TableName() string {
+	return "attrs_shares"
+}
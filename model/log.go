@@ -17,6 +17,8 @@ type LogOneItemParquet struct {
 	CommandID      int64  `gorm:"column:command_id"      json:"commandId" parquet:"commandId, type=INT_64"`
 	CommandInfoStr string `gorm:"column:command_info"    json:"-"         parquet:"commandInfo, type=UTF8"`
 	UniformID      string `gorm:"column:user_uniform_id" json:"uniformId" parquet:"uniformId, type=UTF8"`
+	MediaStr       string `gorm:"column:media"           json:"-"         parquet:"media, type=UTF8"`
+	RawMsgIDStr    string `gorm:"column:raw_msg_id"      json:"-"         parquet:"rawMsgId, type=UTF8"`
 }
 
 // 兼容旧版本的数据库设计
@@ -40,6 +42,9 @@ type LogOneItem struct {
 	RawMsgID    interface{} `gorm:"-"                                                                 json:"rawMsgId"  parquet:"-"`
 	RawMsgIDStr string      `gorm:"column:raw_msg_id;index:idx_raw_msg_id;index:idx_log_delete_by_id,priority:2" json:"-"`
 	UniformID   string      `gorm:"column:user_uniform_id"                                            json:"uniformId"`
+	// 消息中的图片与文件，MediaStr 为其 JSON
+	Media    []LogMedia `gorm:"-"            json:"media,omitempty" parquet:"-"`
+	MediaStr string     `gorm:"column:media" json:"-"`
 	// 数据库里没有的
 	Channel string `gorm:"-" json:"channel"`
 	// 数据库里有，JSON里没有的
@@ -69,6 +74,14 @@ func (item *LogOneItem) BeforeSave(_ *gorm.DB) (err error) {
 		item.RawMsgIDStr = fmt.Sprintf("%v", item.RawMsgID)
 	}
 
+	if len(item.Media) > 0 {
+		if data, err := json.Marshal(item.Media); err == nil {
+			item.MediaStr = string(data)
+		} else {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -86,9 +99,43 @@ func (item *LogOneItem) AfterFind(_ *gorm.DB) (err error) {
 		item.RawMsgID = item.RawMsgIDStr
 	}
 
+	if item.MediaStr != "" {
+		if err := json.Unmarshal([]byte(item.MediaStr), &item.Media); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// LogMedia 日志消息中的一张图片或一个文件。内容按 SHA-256 存放在日志库旁的 log-media 目录，Hash 为空表示未能下载
+type LogMedia struct {
+	Kind        string `json:"kind"` // image 或 file
+	Hash        string `json:"hash,omitempty"`
+	Name        string `json:"name,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+	// URL 来源地址，平台给出的地址通常会过期
+	URL string `json:"url,omitempty"`
+}
+
+const (
+	LogMediaKindImage = "image"
+	LogMediaKindFile  = "file"
+)
+
+// ParseLogMedia 解析 media 列，内容有误时返回 nil
+func ParseLogMedia(s string) []LogMedia {
+	if s == "" {
+		return nil
+	}
+	var media []LogMedia
+	if err := json.Unmarshal([]byte(s), &media); err != nil {
+		return nil
+	}
+	return media
+}
+
 type LogInfo struct {
 	ID        uint64 `gorm:"primaryKey;autoIncrement;column:id"                      json:"id"`
 	Name      string `gorm:"index:idx_log_group_id_name,unique"                      json:"name"`
@@ -147,6 +194,7 @@ type LogOneItemHookMySQL struct {
 	RawMsgID       interface{} `gorm:"-"                                  json:"rawMsgId"`
 	RawMsgIDStr    string      `gorm:"column:raw_msg_id;index:idx_log_delete_by_id,priority:2" json:"-"`
 	UniformID      string      `gorm:"column:user_uniform_id"             json:"uniformId"`
+	MediaStr       string      `gorm:"column:media"                       json:"-"`
 	Channel        string      `gorm:"-"                                  json:"channel"`
 	Removed        *int        `gorm:"column:removed"                     json:"-"`
 	ParentID       *int        `gorm:"column:parent_id"                   json:"-"`
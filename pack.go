@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jessevdk/go-flags"
+
+	"sealdice-core/dice"
+	"sealdice-core/dice/sealpack"
+)
+
+// 扩展包开发工具: sealdice pack <init|check|build>
+// 在加载数据、获取文件锁之前执行，不影响正在运行的海豹。
+
+type packInitCommand struct {
+	ID     string `description:"包ID，格式为 作者/包名" long:"id"     required:"true"`
+	Name   string `description:"包显示名称"         long:"name"`
+	Author string `description:"作者名"           long:"author"`
+	Args   struct {
+		Dir string `description:"扩展包源目录，默认为当前目录" positional-arg-name:"dir"`
+	} `positional-args:"true"`
+}
+
+func (c *packInitCommand) Execute(_ []string) error {
+	dir := packCommandDir(c.Args.Dir)
+	if err := dice.ScaffoldPackageDir(dir, c.ID, c.Name, c.Author); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "已在 %s 创建扩展包 %s\n", dir, c.ID)
+	return nil
+}
+
+type packCheckCommand struct {
+	Args struct {
+		Dir string `description:"扩展包源目录，默认为当前目录" positional-arg-name:"dir"`
+	} `positional-args:"true"`
+}
+
+func (c *packCheckCommand) Execute(_ []string) error {
+	report, err := dice.LintPackageDir(packCommandDir(c.Args.Dir))
+	if err != nil {
+		return err
+	}
+	printPackLintReport(report)
+	if !report.OK() {
+		return errors.New("扩展包检查未通过")
+	}
+	fmt.Fprintln(os.Stdout, "检查通过")
+	return nil
+}
+
+type packBuildCommand struct {
+	Output  string `description:"输出文件，默认为 <包名>@<版本>.sealpack" long:"output" short:"o"`
+	KeyFile string `description:"用于签名的 PKCS8 PEM 私钥文件"       long:"key"`
+	Signer  string `description:"写入签名的签名者名称"                long:"signer"`
+	Args    struct {
+		Dir string `description:"扩展包源目录，默认为当前目录" positional-arg-name:"dir"`
+	} `positional-args:"true"`
+}
+
+func (c *packBuildCommand) Execute(_ []string) error {
+	dir := packCommandDir(c.Args.Dir)
+	var privateKey string
+	if c.KeyFile != "" {
+		data, err := os.ReadFile(c.KeyFile)
+		if err != nil {
+			return err
+		}
+		privateKey = string(data)
+	}
+
+	output := c.Output
+	if output == "" {
+		manifest, err := sealpack.ParseManifestFile(filepath.Join(dir, sealpack.InfoFile))
+		if err != nil {
+			return err
+		}
+		output = sealpack.PackageSourceFileName(manifest.Package.ID, manifest.Package.Version)
+	}
+
+	report, err := dice.PackPackageDir(dir, output, privateKey, c.Signer)
+	if report != nil {
+		printPackLintReport(report)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "已生成 %s (%d 个文件)\n", output, len(report.Files))
+	return nil
+}
+
+func packCommandDir(dir string) string {
+	if dir == "" {
+		return "."
+	}
+	return dir
+}
+
+func printPackLintReport(report *dice.PackageLintReport) {
+	for _, item := range report.Warnings {
+		fmt.Fprintln(os.Stdout, "警告:", item)
+	}
+	for _, item := range report.Errors {
+		fmt.Fprintln(os.Stdout, "错误:", item)
+	}
+}
+
+// runPackCommand 执行 pack 子命令，返回进程退出码
+func runPackCommand(args []string) int {
+	parser := flags.NewNamedParser("sealdice pack", flags.Default)
+	_, _ = parser.AddCommand("init", "创建扩展包模板", "在目录中生成 info.toml、README.md 与示例脚本", &packInitCommand{})
+	_, _ = parser.AddCommand("check", "检查扩展包", "校验 info.toml、文件路径、配置默认值，并用运行时解析器检查脚本、牌堆、回复、模板与帮助文档", &packCheckCommand{})
+	_, _ = parser.AddCommand("build", "打包扩展包", "检查通过后生成可复现的 .sealpack 文件", &packBuildCommand{})
+	// flags.Default 已负责输出错误信息
+	if _, err := parser.ParseArgs(args); err != nil {
+		var flagsErr *flags.Error
+		if errors.As(err, &flagsErr) && flagsErr.Type == flags.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+	return 0
+}
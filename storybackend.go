@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"sealdice-core/dice/storylog"
+)
+
+// 独立运行的日志后端: sealdice story-backend --listen :3212 --public-url https://log.example.com
+// 与随海豹启用的内置后端相同，只是不启动骰子，适合单独部署给多个骰子共用。
+
+type storyBackendCommand struct {
+	Listen      string `default:":3212"              description:"监听地址"                      long:"listen"`
+	Dir         string `default:"./data/story-backend" description:"保存上传日志的目录"                long:"dir"`
+	PublicURL   string `description:"分享链接使用的地址，留空时使用请求的 Host" long:"public-url"`
+	Token       string `description:"上传时需要携带的 token，留空则不校验，也可用环境变量 STORY_BACKEND_TOKEN" long:"token"`
+	MaxUploadMB int    `default:"64"                 description:"单次上传的大小上限(MB)"             long:"max-upload-mb"`
+	TrustProto  bool   `description:"位于反向代理之后时开启，生成链接时采用 X-Forwarded-Proto 中的协议" long:"trust-forwarded-proto"`
+}
+
+func (c *storyBackendCommand) run() error {
+	token := c.Token
+	if token == "" {
+		token = os.Getenv("STORY_BACKEND_TOKEN")
+	}
+	backend, err := storylog.NewBackend(c.Dir, storylog.BackendConfig{
+		Enable:      true,
+		PublicURL:   c.PublicURL,
+		Token:       token,
+		MaxUploadMB: c.MaxUploadMB,
+
+		TrustForwardedProto: c.TrustProto,
+	})
+	if err != nil {
+		return err
+	}
+	server := &http.Server{
+		Addr:              c.Listen,
+		Handler:           backend.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	fmt.Fprintf(os.Stdout, "日志后端已启动: %s，上传地址为 %s\n", c.Listen, storylog.BackendUploadPath)
+	if token == "" {
+		fmt.Fprintln(os.Stdout, "未设置 token，任何人都可以上传日志")
+	}
+	return server.ListenAndServe()
+}
+
+// runStoryBackendCommand 执行 story-backend 子命令，返回进程退出码
+func runStoryBackendCommand(args []string) int {
+	var cmd storyBackendCommand
+	parser := flags.NewNamedParser("sealdice story-backend", flags.Default)
+	_, _ = parser.AddGroup("日志后端选项", "运行与公共日志服务接口兼容的日志后端", &cmd)
+	if _, err := parser.ParseArgs(args); err != nil {
+		var flagsErr *flags.Error
+		if errors.As(err, &flagsErr) && flagsErr.Type == flags.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+	if err := cmd.run(); err != nil {
+		fmt.Fprintln(os.Stderr, "日志后端运行失败:", err)
+		return 1
+	}
+	return 0
+}
@@ -2,13 +2,14 @@ package dboperator
 
 import (
 	"context"
-	"os"
+	"fmt"
 	"sync"
 
 	"go.uber.org/zap"
 
 	"sealdice-core/logger"
 	"sealdice-core/utils/constant"
+	"sealdice-core/utils/dboperator/dbconfig"
 	operator "sealdice-core/utils/dboperator/engine"
 	"sealdice-core/utils/dboperator/engine/mysql"
 	"sealdice-core/utils/dboperator/engine/pgsql"
@@ -19,49 +20,108 @@ var (
 	engine            operator.DatabaseOperator
 	once              sync.Once
 	errEngineInstance error
+	// activeConfig 全局引擎实际使用的配置（已用环境变量补全）
+	activeConfig dbconfig.Config
 )
 
-// initEngine 初始化数据库引擎，仅执行一次
-func initEngine() {
+// initEngine 初始化数据库引擎，仅执行一次。ctx 在程序退出时取消，引擎据此中止未完成的查询
+func initEngine(ctx context.Context) {
 	log := zap.S().Named(logger.LogKeyDatabase)
 
-	dbType := os.Getenv("DB_TYPE")
-	switch dbType {
+	cfg, err := dbconfig.Load(dbconfig.DefaultConfigPath)
+	if err != nil {
+		errEngineInstance = err
+		log.Error("数据库配置读取失败:", err)
+		return
+	}
+	cfg.FillFromEnv()
+	switch cfg.Engine {
 	case constant.SQLITE:
 		log.Info("当前选择使用: SQLITE数据库")
-		engine = &sqlite.SQLiteEngine{}
 	case constant.MYSQL:
 		log.Info("当前选择使用: MYSQL数据库")
-		engine = &mysql.MYSQLEngine{}
 	case constant.POSTGRESQL:
 		log.Info("当前选择使用: POSTGRESQL数据库")
-		engine = &pgsql.PGSQLEngine{}
-	default:
+	case "":
 		log.Warn("未配置数据库类型，默认使用: SQLITE数据库")
-		engine = &sqlite.SQLiteEngine{}
+		cfg.Engine = constant.SQLITE
+	}
+	if errEngineInstance = cfg.Validate(); errEngineInstance != nil {
+		log.Error(errEngineInstance)
+		return
 	}
-	// TODO: 使用统一管理的context，以确保在程序关闭时，可以正确销毁数据库的context从而优雅退出
-	errEngineInstance = engine.Init(context.Background())
+	activeConfig = cfg
+	engine, _ = newEngine(cfg)
+	errEngineInstance = engine.Init(ctx)
 	if errEngineInstance != nil {
 		log.Error("数据库引擎初始化失败:", errEngineInstance)
 	}
 }
 
+// newEngine 按配置创建未初始化的引擎，DSN 为空时由引擎从环境变量读取；
+// 未知类型返回 SQLITE 引擎与错误
+func newEngine(cfg dbconfig.Config) (operator.DatabaseOperator, error) {
+	switch cfg.Engine {
+	case constant.SQLITE:
+		return &sqlite.SQLiteEngine{DataDir: cfg.DSN, Config: cfg}, nil
+	case constant.MYSQL:
+		return &mysql.MYSQLEngine{DSN: cfg.DSN, Config: cfg}, nil
+	case constant.POSTGRESQL:
+		return &pgsql.PGSQLEngine{DSN: cfg.DSN, Config: cfg}, nil
+	default:
+		return &sqlite.SQLiteEngine{DataDir: cfg.DSN, Config: cfg}, fmt.Errorf("未知的数据库类型: %s", cfg.Engine)
+	}
+}
+
+// OpenEngine 打开一个独立于全局引擎的数据库连接，用于跨数据库迁移等工具。
+// SQLITE 的 dsn 为数据目录，MYSQL/POSTGRESQL 为连接串；dsn 为空时读取环境变量。
+func OpenEngine(ctx context.Context, dbType, dsn string) (operator.DatabaseOperator, error) {
+	dbEngine, err := newEngine(dbconfig.Config{Engine: dbType, DSN: dsn})
+	if err != nil {
+		return nil, err
+	}
+	if err = dbEngine.Init(ctx); err != nil {
+		return nil, err
+	}
+	return dbEngine, nil
+}
+
 // getEngine 获取数据库引擎，确保只初始化一次
-func getEngine() (operator.DatabaseOperator, error) {
-	once.Do(initEngine)
+func getEngine(ctx context.Context) (operator.DatabaseOperator, error) {
+	once.Do(func() {
+		initEngine(ctx)
+	})
 	return engine, errEngineInstance
 }
 
-// GetDatabaseOperator 初始化数据和日志数据库
-func GetDatabaseOperator() (operator.DatabaseOperator, error) {
-	return getEngine()
+// GetDatabaseOperator 初始化数据和日志数据库，ctx 应在程序退出时取消
+func GetDatabaseOperator(ctx context.Context) (operator.DatabaseOperator, error) {
+	return getEngine(ctx)
+}
+
+// Status 数据库配置与连接池状态
+type Status struct {
+	Engine string               `json:"engine"`
+	Config dbconfig.Config      `json:"config"` // 连接串中的密码已隐去
+	Pools  []dbconfig.PoolStats `json:"pools"`
+}
+
+// GetStatus 返回 op 的配置与连接池状态
+func GetStatus(op operator.DatabaseOperator) Status {
+	st := Status{Engine: op.Type()}
+	if op == engine {
+		st.Config = activeConfig.Redacted()
+	}
+	if p, ok := op.(operator.PoolStatsProvider); ok {
+		st.Pools = p.PoolStats()
+	}
+	return st
 }
 
 // DBCheck 检查数据库状态
 func DBCheck() {
 	log := zap.S().Named(logger.LogKeyDatabase)
-	dbEngine, err := getEngine()
+	dbEngine, err := getEngine(context.Background())
 	if err != nil {
 		log.Error("数据库引擎获取失败:", err)
 		return
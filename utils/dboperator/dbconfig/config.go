@@ -0,0 +1,253 @@
+// Package dbconfig 数据库连接配置：data/dice.yaml 中的 database 段，未填写的项由环境变量补全
+package dbconfig
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"sealdice-core/utils/constant"
+)
+
+// DefaultConfigPath 数据库配置所在的文件，与 DiceManager 共用
+const DefaultConfigPath = "./data/dice.yaml"
+
+// DefaultSQLiteBusyTimeout SQLite 等待写锁的默认时长，单位毫秒
+const DefaultSQLiteBusyTimeout = 15000
+
+// DefaultCloseTimeout Close 等待进行中的查询结束的默认时长，超时后取消它们的 context
+const DefaultCloseTimeout = 10 * time.Second
+
+type Config struct {
+	// Engine 数据库类型: sqlite / mysql / postgres，为空时读取环境变量 DB_TYPE
+	Engine string `json:"engine" yaml:"engine,omitempty"`
+	// DSN 连接串，sqlite 为数据目录；为空时读取 DB_DSN（sqlite 为 DATADIR）
+	DSN string `json:"dsn" yaml:"dsn,omitempty"`
+	// 分库连接串，为空时与 DSN 相同；sqlite 为对应数据库文件的路径
+	DataDSN   string `json:"dataDsn"   yaml:"dataDsn,omitempty"`
+	LogDSN    string `json:"logDsn"    yaml:"logDsn,omitempty"`
+	CensorDSN string `json:"censorDsn" yaml:"censorDsn,omitempty"`
+
+	// 连接池，0 为使用默认值。sqlite 的写连接固定为 1，MaxOpenConns 只作用于读连接
+	MaxOpenConns    int           `json:"maxOpenConns"    yaml:"maxOpenConns,omitempty"`
+	MaxIdleConns    int           `json:"maxIdleConns"    yaml:"maxIdleConns,omitempty"`
+	ConnMaxLifetime time.Duration `json:"connMaxLifetime" yaml:"connMaxLifetime,omitempty"`
+	ConnMaxIdleTime time.Duration `json:"connMaxIdleTime" yaml:"connMaxIdleTime,omitempty"`
+	// CloseTimeout 关闭时等待进行中查询的时长，0 为 DefaultCloseTimeout
+	CloseTimeout time.Duration `json:"closeTimeout" yaml:"closeTimeout,omitempty"`
+
+	SQLite SQLiteConfig `json:"sqlite" yaml:"sqlite,omitempty"`
+}
+
+type SQLiteConfig struct {
+	// WAL 是否使用 WAL 日志模式，默认开启
+	WAL *bool `json:"wal" yaml:"wal,omitempty"`
+	// BusyTimeout 等待锁的毫秒数，0 为 DefaultSQLiteBusyTimeout
+	BusyTimeout int `json:"busyTimeout" yaml:"busyTimeout,omitempty"`
+}
+
+// UseWAL 未配置时默认开启
+func (c *SQLiteConfig) UseWAL() bool {
+	return c.WAL == nil || *c.WAL
+}
+
+// BusyTimeoutMS 实际使用的 busy_timeout
+func (c *SQLiteConfig) BusyTimeoutMS() int {
+	if c.BusyTimeout == 0 {
+		return DefaultSQLiteBusyTimeout
+	}
+	return c.BusyTimeout
+}
+
+// Load 从 path 读取 database 段，文件不存在时返回空配置
+func Load(path string) (Config, error) {
+	var file struct {
+		Database Config `yaml:"database"`
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Config{}, nil
+	}
+	if err != nil {
+		return Config{}, err
+	}
+	if err = yaml.Unmarshal(data, &file); err != nil {
+		return Config{}, fmt.Errorf("读取 %s 的 database 配置失败: %w", path, err)
+	}
+	return file.Database, nil
+}
+
+// FillFromEnv 用环境变量补全未填写的 Engine 与 DSN，配置文件中的值优先
+func (c *Config) FillFromEnv() {
+	if c.Engine == "" {
+		c.Engine = os.Getenv("DB_TYPE")
+	}
+	if c.DSN != "" {
+		return
+	}
+	if c.Engine == constant.SQLITE || c.Engine == "" {
+		c.DSN = os.Getenv("DATADIR")
+	} else {
+		c.DSN = os.Getenv("DB_DSN")
+	}
+}
+
+// Validate 检查配置，返回的错误可直接展示给用户
+func (c *Config) Validate() error {
+	var problems []string
+	switch c.Engine {
+	case "", constant.SQLITE:
+	case constant.MYSQL, constant.POSTGRESQL:
+		if c.DSN == "" {
+			problems = append(problems, fmt.Sprintf("使用 %s 时必须填写 database.dsn 或环境变量 DB_DSN", c.Engine))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("database.engine 不支持 %q，可选 %s/%s/%s",
+			c.Engine, constant.SQLITE, constant.MYSQL, constant.POSTGRESQL))
+	}
+	if c.MaxOpenConns < 0 {
+		problems = append(problems, "database.maxOpenConns 不能为负数")
+	}
+	if c.MaxIdleConns < 0 {
+		problems = append(problems, "database.maxIdleConns 不能为负数")
+	}
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		problems = append(problems, "database.maxIdleConns 不能大于 maxOpenConns")
+	}
+	if c.ConnMaxLifetime < 0 || c.ConnMaxIdleTime < 0 || c.CloseTimeout < 0 {
+		problems = append(problems, "database 中的时长不能为负数")
+	}
+	if c.SQLite.BusyTimeout < 0 {
+		problems = append(problems, "database.sqlite.busyTimeout 不能为负数")
+	}
+	if len(problems) > 0 {
+		return errors.New("数据库配置错误: " + strings.Join(problems, "；"))
+	}
+	return nil
+}
+
+// CloseWait 实际使用的关闭等待时长
+func (c *Config) CloseWait() time.Duration {
+	if c.CloseTimeout == 0 {
+		return DefaultCloseTimeout
+	}
+	return c.CloseTimeout
+}
+
+// ApplyPool 将连接池配置应用到 db，0 值保持 database/sql 的默认行为
+func (c *Config) ApplyPool(db *sql.DB) {
+	if c.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(c.MaxOpenConns)
+	}
+	if c.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(c.MaxIdleConns)
+	}
+	if c.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	}
+	if c.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+	}
+}
+
+// Redacted 返回隐去连接串密码的副本，用于展示
+func (c *Config) Redacted() Config {
+	r := *c
+	r.DSN = RedactDSN(r.DSN)
+	r.DataDSN = RedactDSN(r.DataDSN)
+	r.LogDSN = RedactDSN(r.LogDSN)
+	r.CensorDSN = RedactDSN(r.CensorDSN)
+	return r
+}
+
+// mysql: user:pass@tcp(host)/db ; postgres 键值形式: password=xxx
+var (
+	reMySQLPassword = regexp.MustCompile(`^([^:@/]*):[^@]*@`)
+	rePGPassword    = regexp.MustCompile(`(?i)(password=)(?:'[^']*'|\S+)`)
+)
+
+// RedactDSN 将连接串中的密码替换为 ***
+func RedactDSN(dsn string) string {
+	if dsn == "" {
+		return dsn
+	}
+	if u, err := url.Parse(dsn); err == nil && u.User != nil && u.Scheme != "" {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "***")
+			return strings.Replace(u.String(), "%2A%2A%2A", "***", 1)
+		}
+		return dsn
+	}
+	if rePGPassword.MatchString(dsn) {
+		return rePGPassword.ReplaceAllString(dsn, "${1}***")
+	}
+	return reMySQLPassword.ReplaceAllString(dsn, "${1}:***@")
+}
+
+// PoolStats 单个连接池的状态
+type PoolStats struct {
+	Name string `json:"name"` // data / logs / censor
+	Mode string `json:"mode"` // read / write，mysql 与 postgres 不区分读写时为空
+
+	MaxOpenConnections int   `json:"maxOpenConnections"`
+	OpenConnections    int   `json:"openConnections"`
+	InUse              int   `json:"inUse"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"waitCount"`
+	WaitDurationMS     int64 `json:"waitDurationMs"`
+	MaxIdleClosed      int64 `json:"maxIdleClosed"`
+	MaxIdleTimeClosed  int64 `json:"maxIdleTimeClosed"`
+	MaxLifetimeClosed  int64 `json:"maxLifetimeClosed"`
+}
+
+// NewPoolStats 从 sql.DB 读取状态
+func NewPoolStats(name, mode string, db *sql.DB) PoolStats {
+	s := db.Stats()
+	return PoolStats{
+		Name:               name,
+		Mode:               mode,
+		MaxOpenConnections: s.MaxOpenConnections,
+		OpenConnections:    s.OpenConnections,
+		InUse:              s.InUse,
+		Idle:               s.Idle,
+		WaitCount:          s.WaitCount,
+		WaitDurationMS:     s.WaitDuration.Milliseconds(),
+		MaxIdleClosed:      s.MaxIdleClosed,
+		MaxIdleTimeClosed:  s.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  s.MaxLifetimeClosed,
+	}
+}
+
+// CloseGracefully 关闭连接池：sql.DB.Close 会等待已开始的查询结束，
+// 超过 timeout 仍未结束时调用 cancel 取消引擎 context 下的查询，再等待关闭完成
+func CloseGracefully(dbs []*sql.DB, timeout time.Duration, cancel context.CancelFunc) error {
+	errs := make(chan error, len(dbs))
+	for _, db := range dbs {
+		go func(db *sql.DB) {
+			errs <- db.Close()
+		}(db)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var result []error
+	for range dbs {
+		select {
+		case err := <-errs:
+			result = append(result, err)
+		case <-timer.C:
+			cancel()
+			result = append(result, <-errs)
+		}
+	}
+	cancel()
+	return errors.Join(result...)
+}
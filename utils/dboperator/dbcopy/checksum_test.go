@@ -0,0 +1,33 @@
+package dbcopy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChecksumValueIgnoresDriverDifferences(t *testing.T) {
+	local := time.FixedZone("UTC+8", 8*3600)
+	at := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+	cases := []struct {
+		name string
+		a, b interface{}
+	}{
+		{"time zone and precision", at, at.In(local).Truncate(time.Millisecond)},
+		{"integer and float", int64(3), float64(3)},
+		{"json key order", []byte(`{"b": 1, "a": [1, 2]}`), []byte(`{"a":[1,2],"b":1}`)},
+		{"bool and int", true, int64(1)},
+	}
+	for _, c := range cases {
+		if checksumValue(c.a) != checksumValue(c.b) {
+			t.Errorf("%s: %q != %q", c.name, checksumValue(c.a), checksumValue(c.b))
+		}
+	}
+
+	var nilTime *time.Time
+	if checksumValue(nilTime) == checksumValue("") || checksumValue(nil) != checksumValue(nilTime) {
+		t.Errorf("NULL should differ from an empty value")
+	}
+	if checksumValue([]byte("ab")) == checksumValue([]byte("ac")) {
+		t.Errorf("different bytes should not collide")
+	}
+}
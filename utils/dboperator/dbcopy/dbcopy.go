@@ -0,0 +1,357 @@
+// Package dbcopy 在两个数据库引擎之间复制海豹的全部数据表，并逐表校验行数与校验和
+package dbcopy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	"sealdice-core/utils/dboperator/engine"
+)
+
+// DefaultBatchSize 每批读取与写入的行数
+const DefaultBatchSize = 500
+
+// Table 一张需要迁移的数据表
+type Table struct {
+	Name  string
+	Model interface{}
+	// DB 返回该表所在的库
+	DB func(operator engine.DatabaseOperator) *gorm.DB
+}
+
+func dataDB(operator engine.DatabaseOperator) *gorm.DB {
+	return operator.GetDataDB(constant.WRITE)
+}
+
+func logDB(operator engine.DatabaseOperator) *gorm.DB {
+	return operator.GetLogDB(constant.WRITE)
+}
+
+func censorDB(operator engine.DatabaseOperator) *gorm.DB {
+	return operator.GetCensorDB(constant.WRITE)
+}
+
+// Tables 全部模型表，按所在的库排列
+var Tables = []Table{
+	{Name: "attrs", Model: &model.AttributesItemModel{}, DB: dataDB},
+	{Name: "attrs_history", Model: &model.AttributesHistoryModel{}, DB: dataDB},
+	{Name: "attrs_shares", Model: &model.AttributesShareModel{}, DB: dataDB},
+	{Name: "group_info", Model: &model.GroupInfo{}, DB: dataDB},
+	{Name: "group_player_info", Model: &model.GroupPlayerInfoBase{}, DB: dataDB},
+	{Name: "ban_info", Model: &model.BanInfo{}, DB: dataDB},
+	{Name: "endpoint_info", Model: &model.EndpointInfo{}, DB: dataDB},
+	{Name: "logs", Model: &model.LogInfo{}, DB: logDB},
+	{Name: "log_items", Model: &model.LogOneItem{}, DB: logDB},
+	{Name: "censor_log", Model: &model.CensorLog{}, DB: censorDB},
+}
+
+// Options 迁移选项
+type Options struct {
+	BatchSize int
+	// Overwrite 为 true 时先清空目标表，否则目标表非空时拒绝迁移
+	Overwrite bool
+	// Progress 每写入一批后回调
+	Progress func(table string, copied, total int64)
+}
+
+// TableResult 单表的迁移结果
+type TableResult struct {
+	Table          string        `json:"table"`
+	SourceRows     int64         `json:"sourceRows"`
+	TargetRows     int64         `json:"targetRows"`
+	SourceChecksum string        `json:"sourceChecksum"`
+	TargetChecksum string        `json:"targetChecksum"`
+	Duration       time.Duration `json:"duration"`
+}
+
+// OK 行数与校验和均一致
+func (r *TableResult) OK() bool {
+	return r.SourceRows == r.TargetRows && r.SourceChecksum == r.TargetChecksum
+}
+
+func (r *TableResult) String() string {
+	return fmt.Sprintf("%s: %d 行 校验和 %s 用时 %s", r.Table, r.TargetRows, r.TargetChecksum, r.Duration.Round(time.Millisecond))
+}
+
+// ErrVerifyFailed 复制后目标表与源表不一致
+var ErrVerifyFailed = errors.New("数据校验不一致")
+
+// Copy 将 src 中的全部数据表复制到 dst。dst 需已建好表结构。
+// 每张表复制完成后重新读取目标表，比对行数与校验和；任意一张不一致时返回 ErrVerifyFailed。
+func Copy(src, dst engine.DatabaseOperator, opts Options) ([]*TableResult, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if !opts.Overwrite {
+		for _, t := range Tables {
+			var count int64
+			if err := t.DB(dst).Model(t.Model).Count(&count).Error; err != nil {
+				return nil, fmt.Errorf("%s: %w", t.Name, err)
+			}
+			if count > 0 {
+				return nil, fmt.Errorf("目标表 %s 已有 %d 行数据，如需覆盖请清空目标库", t.Name, count)
+			}
+		}
+	}
+
+	results := make([]*TableResult, 0, len(Tables))
+	var failed bool
+	for _, t := range Tables {
+		res, err := copyTable(t, src, dst, opts)
+		if err != nil {
+			return results, fmt.Errorf("%s: %w", t.Name, err)
+		}
+		results = append(results, res)
+		if !res.OK() {
+			failed = true
+		}
+	}
+	if failed {
+		return results, ErrVerifyFailed
+	}
+	return results, nil
+}
+
+func copyTable(t Table, src, dst engine.DatabaseOperator, opts Options) (*TableResult, error) {
+	start := time.Now()
+	// 跳过钩子，原样复制数据库中的值
+	srcDB := t.DB(src).Session(&gorm.Session{SkipHooks: true})
+	dstDB := t.DB(dst).Session(&gorm.Session{SkipHooks: true})
+
+	sch, err := parseSchema(srcDB, t.Model)
+	if err != nil {
+		return nil, err
+	}
+	res := &TableResult{Table: t.Name}
+	if err = srcDB.Model(t.Model).Count(&res.SourceRows).Error; err != nil {
+		return nil, err
+	}
+	if opts.Overwrite {
+		if err = dstDB.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(t.Model).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	var srcSum tableChecksum
+	var copied int64
+	err = eachBatch(srcDB, t.Model, sch, opts.BatchSize, func(rows reflect.Value) error {
+		srcSum.add(sch, rows)
+		// 以列名映射写入：结构体写入时 GORM 会把零值的 created_at/updated_at 填为当前时间
+		values := rowValues(sch, rows)
+		if err := dstDB.Model(t.Model).Create(&values).Error; err != nil {
+			return err
+		}
+		copied += int64(rows.Elem().Len())
+		if opts.Progress != nil {
+			opts.Progress(t.Name, copied, res.SourceRows)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	res.SourceChecksum = srcSum.String()
+
+	if dst.Type() == constant.POSTGRESQL {
+		if err = resetPostgresSequence(dstDB, t.Name, sch); err != nil {
+			return nil, err
+		}
+	}
+
+	// 重新读取目标表校验
+	if err = dstDB.Model(t.Model).Count(&res.TargetRows).Error; err != nil {
+		return nil, err
+	}
+	var dstSum tableChecksum
+	err = eachBatch(dstDB, t.Model, sch, opts.BatchSize, func(rows reflect.Value) error {
+		dstSum.add(sch, rows)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	res.TargetChecksum = dstSum.String()
+	res.Duration = time.Since(start)
+	return res, nil
+}
+
+func parseSchema(db *gorm.DB, m interface{}) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(m); err != nil {
+		return nil, err
+	}
+	if stmt.Schema.PrioritizedPrimaryField == nil {
+		return nil, errors.New("缺少主键，无法分批读取")
+	}
+	return stmt.Schema, nil
+}
+
+// eachBatch 按主键顺序分批读取整张表，fn 收到的是指向模型切片的指针
+func eachBatch(db *gorm.DB, m interface{}, sch *schema.Schema, batchSize int, fn func(rows reflect.Value) error) error {
+	pk := sch.PrioritizedPrimaryField
+	elemType := reflect.TypeOf(m).Elem()
+	var last interface{}
+	for {
+		rows := reflect.New(reflect.SliceOf(elemType))
+		query := db.Model(m).Order(clause.OrderByColumn{Column: clause.Column{Name: pk.DBName}}).Limit(batchSize)
+		if last != nil {
+			query = query.Where(clause.Gt{Column: clause.Column{Name: pk.DBName}, Value: last})
+		}
+		if err := query.Find(rows.Interface()).Error; err != nil {
+			return err
+		}
+		n := rows.Elem().Len()
+		if n == 0 {
+			return nil
+		}
+		if err := fn(rows); err != nil {
+			return err
+		}
+		if n < batchSize {
+			return nil
+		}
+		last, _ = pk.ValueOf(context.Background(), rows.Elem().Index(n-1))
+	}
+}
+
+// rowValues 将模型切片转换为列名到值的映射
+func rowValues(sch *schema.Schema, rows reflect.Value) []map[string]interface{} {
+	list := rows.Elem()
+	values := make([]map[string]interface{}, 0, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		row := list.Index(i)
+		m := make(map[string]interface{}, len(sch.DBNames))
+		for _, name := range sch.DBNames {
+			m[name], _ = sch.FieldsByDBName[name].ValueOf(context.Background(), row)
+		}
+		values = append(values, m)
+	}
+	return values
+}
+
+// tableChecksum 各行哈希的异或，与读取顺序无关：不同引擎对字符串主键的排序规则可能不同
+type tableChecksum [sha256.Size]byte
+
+// add 按列顺序计算每一行的哈希并累加，NULL 与零值区分开
+func (c *tableChecksum) add(sch *schema.Schema, rows reflect.Value) {
+	list := rows.Elem()
+	h := sha256.New()
+	for i := 0; i < list.Len(); i++ {
+		row := list.Index(i)
+		h.Reset()
+		for _, name := range sch.DBNames {
+			v, _ := sch.FieldsByDBName[name].ValueOf(context.Background(), row)
+			_, _ = fmt.Fprintf(h, "%s=%s;", name, checksumValue(v))
+		}
+		sum := h.Sum(nil)
+		for j := range c {
+			c[j] ^= sum[j]
+		}
+	}
+}
+
+// checksumValue 将列值转换为与驱动无关的文本：不同引擎读回的时间时区与精度、
+// JSON 的格式、数值的类型可能不同，直接使用 %v 会让一致的数据校验失败
+func checksumValue(v interface{}) string {
+	if valuer, ok := v.(driver.Valuer); ok {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return "\x00"
+		}
+		if dv, err := valuer.Value(); err == nil {
+			v = dv
+		}
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return "\x00"
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return "\x00"
+	}
+
+	switch val := rv.Interface().(type) {
+	case time.Time:
+		// MySQL 默认只保存到毫秒
+		return val.UTC().Truncate(time.Millisecond).Format(time.RFC3339Nano)
+	case []byte:
+		return checksumBytes(val)
+	case string:
+		return val
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		if rv.Bool() {
+			return "1"
+		}
+		return "0"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			return strconv.FormatInt(int64(f), 10)
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	case reflect.String:
+		return rv.String()
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return checksumBytes(rv.Bytes())
+		}
+	}
+	return fmt.Sprintf("%v", rv.Interface())
+}
+
+// checksumBytes JSON 内容按键排序后的紧凑格式计算（PostgreSQL 的 jsonb 会重排键），其他内容按原始字节计算
+func checksumBytes(b []byte) string {
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		dec.UseNumber()
+		var parsed interface{}
+		if err := dec.Decode(&parsed); err == nil && !dec.More() {
+			if canonical, err := json.Marshal(parsed); err == nil {
+				return string(canonical)
+			}
+		}
+	}
+	return hex.EncodeToString(b)
+}
+
+func (c *tableChecksum) String() string {
+	return hex.EncodeToString(c[:])
+}
+
+// resetPostgresSequence 显式写入自增主键后，PostgreSQL 的序列不会跟着前进，需要手动对齐
+func resetPostgresSequence(db *gorm.DB, table string, sch *schema.Schema) error {
+	pk := sch.PrioritizedPrimaryField
+	if !pk.AutoIncrement {
+		return nil
+	}
+	return db.Exec(fmt.Sprintf(
+		"SELECT setval(pg_get_serial_sequence('%s', '%s'), COALESCE(MAX(%s), 1), MAX(%s) IS NOT NULL) FROM %s",
+		table, pk.DBName, pk.DBName, pk.DBName, table,
+	)).Error
+}
@@ -0,0 +1,91 @@
+package dbcopy_test
+
+import (
+	"context"
+	"testing"
+
+	"sealdice-core/model"
+	"sealdice-core/utils/constant"
+	"sealdice-core/utils/dboperator"
+	"sealdice-core/utils/dboperator/dbcopy"
+	"sealdice-core/utils/dboperator/engine"
+)
+
+func openSQLite(t *testing.T) engine.DatabaseOperator {
+	t.Helper()
+	op, err := dboperator.OpenEngine(context.Background(), constant.SQLITE, t.TempDir())
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(op.Close)
+	for _, table := range dbcopy.Tables {
+		if err = table.DB(op).AutoMigrate(table.Model); err != nil {
+			t.Fatalf("migrate %s: %v", table.Name, err)
+		}
+	}
+	return op
+}
+
+func TestCopyPreservesRows(t *testing.T) {
+	src := openSQLite(t)
+	dst := openSQLite(t)
+
+	dataDB := src.GetDataDB(constant.WRITE)
+	// 零值时间戳与 NULL 需要原样保留
+	if err := dataDB.Exec("INSERT INTO group_info (id, created_at, updated_at, data) VALUES ('QQ-Group:1', 0, NULL, x'7b7d')").Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"b", "A", "a"} {
+		if err := dataDB.Exec("INSERT INTO attrs (id, data, attrs_type, is_hidden, created_at, updated_at) VALUES (?, ?, 'character', 0, 0, 0)", id, []byte(id)).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	logDB := src.GetLogDB(constant.WRITE)
+	if err := logDB.Create(&model.LogInfo{Name: "log", GroupID: "QQ-Group:1"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 7; i++ {
+		if err := logDB.Exec("INSERT INTO log_items (log_id, group_id, message, command_info) VALUES (1, 'QQ-Group:1', ?, '{\"b\": 1, \"a\": 2}')", i).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var progress int
+	results, err := dbcopy.Copy(src, dst, dbcopy.Options{
+		BatchSize: 3,
+		Progress:  func(string, int64, int64) { progress++ },
+	})
+	if err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	for _, res := range results {
+		if !res.OK() {
+			t.Errorf("%s not verified: %+v", res.Table, res)
+		}
+	}
+	if progress == 0 {
+		t.Errorf("progress callback not called")
+	}
+
+	var group model.GroupInfo
+	if err = dst.GetDataDB(constant.READ).First(&group, "id = ?", "QQ-Group:1").Error; err != nil {
+		t.Fatal(err)
+	}
+	if group.CreatedAt != 0 || group.UpdatedAt != nil {
+		t.Errorf("timestamps changed: created=%d updated=%v", group.CreatedAt, group.UpdatedAt)
+	}
+	var info string
+	if err = dst.GetLogDB(constant.READ).Raw("SELECT command_info FROM log_items WHERE id = 7").Scan(&info).Error; err != nil {
+		t.Fatal(err)
+	}
+	if info != `{"b": 1, "a": 2}` {
+		t.Errorf("command_info = %q, want raw value", info)
+	}
+
+	if _, err = dbcopy.Copy(src, dst, dbcopy.Options{}); err == nil {
+		t.Fatalf("copy into non-empty target should fail without Overwrite")
+	}
+	if _, err = dbcopy.Copy(src, dst, dbcopy.Options{Overwrite: true}); err != nil {
+		t.Fatalf("overwrite copy: %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"gorm.io/gorm"
 
 	"sealdice-core/utils/constant"
+	"sealdice-core/utils/dboperator/dbconfig"
 	"sealdice-core/utils/dboperator/engine/mysql"
 	"sealdice-core/utils/dboperator/engine/pgsql"
 	"sealdice-core/utils/dboperator/engine/sqlite"
@@ -23,9 +24,18 @@ type DatabaseOperator interface {
 	Close()
 }
 
+// PoolStatsProvider 可选实现，用于展示连接池状态
+type PoolStatsProvider interface {
+	PoolStats() []dbconfig.PoolStats
+}
+
 // 实现检查 copied from platform
 var (
 	_ DatabaseOperator = (*sqlite.SQLiteEngine)(nil)
 	_ DatabaseOperator = (*mysql.MYSQLEngine)(nil)
 	_ DatabaseOperator = (*pgsql.PGSQLEngine)(nil)
+
+	_ PoolStatsProvider = (*sqlite.SQLiteEngine)(nil)
+	_ PoolStatsProvider = (*mysql.MYSQLEngine)(nil)
+	_ PoolStatsProvider = (*pgsql.PGSQLEngine)(nil)
 )
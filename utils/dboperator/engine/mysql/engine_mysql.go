@@ -2,6 +2,7 @@ package mysql
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"os"
@@ -13,30 +14,94 @@ import (
 	"sealdice-core/model"
 	"sealdice-core/utils/cache"
 	"sealdice-core/utils/constant"
+	"sealdice-core/utils/dboperator/dbconfig"
 )
 
 type MYSQLEngine struct {
-	DSN      string
+	DSN string
+	// Config 分库连接串与连接池配置，主连接串以 DSN 为准
+	Config   dbconfig.Config
 	DB       *gorm.DB
 	dataDB   *gorm.DB
 	logsDB   *gorm.DB
 	censorDB *gorm.DB
-	ctx      context.Context
+	// 单独配置了连接串的库，键为 data/logs/censor
+	conns  map[string]*gorm.DB
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 func (s *MYSQLEngine) Close() {
 	log := zap.S().Named(logger.LogKeyDatabase)
+	if s.DB == nil {
+		return
+	}
 
-	db, err := s.DB.DB()
-	if err != nil {
+	pools := make([]*sql.DB, 0, 1+len(s.conns))
+	for _, conn := range append([]*gorm.DB{s.DB}, s.connList()...) {
+		db, err := conn.DB()
+		if err != nil {
+			log.Errorf("failed to close db: %v", err)
+			continue
+		}
+		pools = append(pools, db)
+	}
+	if err := dbconfig.CloseGracefully(pools, s.Config.CloseWait(), s.cancel); err != nil {
 		log.Errorf("failed to close db: %v", err)
-		return
 	}
-	err = db.Close()
+}
+
+func (s *MYSQLEngine) connList() []*gorm.DB {
+	list := make([]*gorm.DB, 0, len(s.conns))
+	for _, name := range []string{"data", "logs", "censor"} {
+		if conn, ok := s.conns[name]; ok {
+			list = append(list, conn)
+		}
+	}
+	return list
+}
+
+// open 打开一个连接并应用连接池配置
+func (s *MYSQLEngine) open(dsn string) (*gorm.DB, error) {
+	conn, err := MySQLDBInit(dsn)
+	if err != nil {
+		return nil, err
+	}
+	db, err := conn.DB()
 	if err != nil {
-		log.Errorf("failed to close db: %v", err)
-		return
+		return nil, err
+	}
+	s.Config.ApplyPool(db)
+	return conn, nil
+}
+
+// connFor 返回 name 库使用的连接：单独配置了连接串时新开一个，否则共用 DB
+func (s *MYSQLEngine) connFor(name, dsn string) (*gorm.DB, error) {
+	if dsn == "" || dsn == s.DSN {
+		return s.DB, nil
+	}
+	conn, err := s.open(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%s 库连接失败: %w", name, err)
 	}
+	s.conns[name] = conn
+	return conn, nil
+}
+
+// PoolStats 各连接池的状态，共用的主连接池名为 default
+func (s *MYSQLEngine) PoolStats() []dbconfig.PoolStats {
+	var stats []dbconfig.PoolStats
+	if db, err := s.DB.DB(); err == nil {
+		stats = append(stats, dbconfig.NewPoolStats("default", "", db))
+	}
+	for _, name := range []string{"data", "logs", "censor"} {
+		if conn, ok := s.conns[name]; ok {
+			if db, err := conn.DB(); err == nil {
+				stats = append(stats, dbconfig.NewPoolStats(name, "", db))
+			}
+		}
+	}
+	return stats
 }
 
 func (s *MYSQLEngine) GetDataDB(_ constant.DBMode) *gorm.DB {
@@ -55,13 +120,17 @@ func (s *MYSQLEngine) Init(ctx context.Context) error {
 	if ctx == nil {
 		return errors.New("ctx is missing")
 	}
-	s.ctx = ctx
-	s.DSN = os.Getenv("DB_DSN")
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	// 未预先指定时从环境变量读取
+	if s.DSN == "" {
+		s.DSN = os.Getenv("DB_DSN")
+	}
 	if s.DSN == "" {
 		return errors.New("DB_DSN is missing")
 	}
 	var err error
-	s.DB, err = MySQLDBInit(s.DSN)
+	s.conns = map[string]*gorm.DB{}
+	s.DB, err = s.open(s.DSN)
 	if err != nil {
 		return err
 	}
@@ -87,22 +156,34 @@ func (s *MYSQLEngine) DBCheck() {
 
 // DataDBInit 初始化
 func (s *MYSQLEngine) dataDBInit() (*gorm.DB, error) {
+	conn, err := s.connFor("data", s.Config.DataDSN)
+	if err != nil {
+		return nil, err
+	}
 	dataContext := context.WithValue(s.ctx, cache.CacheKey, cache.DataDBCacheKey)
-	dataDB := s.DB.WithContext(dataContext)
+	dataDB := conn.WithContext(dataContext)
 	return dataDB, nil
 }
 
 func (s *MYSQLEngine) logDBInit() (*gorm.DB, error) {
 	// logs特殊建表
+	conn, err := s.connFor("logs", s.Config.LogDSN)
+	if err != nil {
+		return nil, err
+	}
 	logsContext := context.WithValue(s.ctx, cache.CacheKey, cache.LogsDBCacheKey)
-	logDB := s.DB.WithContext(logsContext)
+	logDB := conn.WithContext(logsContext)
 	return logDB, nil
 }
 
 func (s *MYSQLEngine) censorDBInit() (*gorm.DB, error) {
+	conn, err := s.connFor("censor", s.Config.CensorDSN)
+	if err != nil {
+		return nil, err
+	}
 	censorContext := context.WithValue(s.ctx, cache.CacheKey, cache.CensorsDBCacheKey)
-	censorDB := s.DB.WithContext(censorContext)
-	if err := censorDB.AutoMigrate(&model.CensorLog{}); err != nil {
+	censorDB := conn.WithContext(censorContext)
+	if err = censorDB.AutoMigrate(&model.CensorLog{}); err != nil {
 		return nil, err
 	}
 	return censorDB, nil
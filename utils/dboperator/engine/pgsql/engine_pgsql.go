@@ -2,6 +2,7 @@ package pgsql
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"os"
@@ -12,29 +13,95 @@ import (
 	"sealdice-core/logger"
 	"sealdice-core/utils/cache"
 	"sealdice-core/utils/constant"
+	"sealdice-core/utils/dboperator/dbconfig"
 )
 
 type PGSQLEngine struct {
-	DSN      string
+	DSN string
+	// Config 分库连接串与连接池配置，主连接串以 DSN 为准
+	Config   dbconfig.Config
 	DB       *gorm.DB
 	dataDB   *gorm.DB
 	logsDB   *gorm.DB
 	censorDB *gorm.DB
-	ctx      context.Context
+	// 单独配置了连接串的库，键为 data/logs/censor
+	conns  map[string]*gorm.DB
+	ctx    context.Context
+	cancel context.CancelFunc
 	// 其他引擎不需要读写分离
 }
 
 func (s *PGSQLEngine) Close() {
 	log := zap.S().Named(logger.LogKeyDatabase)
-	db, err := s.DB.DB()
-	if err != nil {
-		log.Errorf("failed to close db: %v", err)
+	if s.DB == nil {
 		return
 	}
-	err = db.Close()
+
+	pools := make([]*sql.DB, 0, 1+len(s.conns))
+	for _, conn := range append([]*gorm.DB{s.DB}, s.connList()...) {
+		db, err := conn.DB()
+		if err != nil {
+			log.Errorf("failed to close db: %v", err)
+			continue
+		}
+		pools = append(pools, db)
+	}
+	if err := dbconfig.CloseGracefully(pools, s.Config.CloseWait(), s.cancel); err != nil {
+		log.Errorf("failed to close db: %v", err)
+	}
+}
+
+func (s *PGSQLEngine) connList() []*gorm.DB {
+	list := make([]*gorm.DB, 0, len(s.conns))
+	for _, name := range []string{"data", "logs", "censor"} {
+		if conn, ok := s.conns[name]; ok {
+			list = append(list, conn)
+		}
+	}
+	return list
+}
+
+// open 打开一个连接并应用连接池配置
+func (s *PGSQLEngine) open(dsn string) (*gorm.DB, error) {
+	conn, err := PostgresDBInit(dsn)
 	if err != nil {
-		return
+		return nil, err
 	}
+	db, err := conn.DB()
+	if err != nil {
+		return nil, err
+	}
+	s.Config.ApplyPool(db)
+	return conn, nil
+}
+
+// connFor 返回 name 库使用的连接：单独配置了连接串时新开一个，否则共用 DB
+func (s *PGSQLEngine) connFor(name, dsn string) (*gorm.DB, error) {
+	if dsn == "" || dsn == s.DSN {
+		return s.DB, nil
+	}
+	conn, err := s.open(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%s 库连接失败: %w", name, err)
+	}
+	s.conns[name] = conn
+	return conn, nil
+}
+
+// PoolStats 各连接池的状态，共用的主连接池名为 default
+func (s *PGSQLEngine) PoolStats() []dbconfig.PoolStats {
+	var stats []dbconfig.PoolStats
+	if db, err := s.DB.DB(); err == nil {
+		stats = append(stats, dbconfig.NewPoolStats("default", "", db))
+	}
+	for _, name := range []string{"data", "logs", "censor"} {
+		if conn, ok := s.conns[name]; ok {
+			if db, err := conn.DB(); err == nil {
+				stats = append(stats, dbconfig.NewPoolStats(name, "", db))
+			}
+		}
+	}
+	return stats
 }
 
 func (s *PGSQLEngine) GetDataDB(_ constant.DBMode) *gorm.DB {
@@ -53,13 +120,17 @@ func (s *PGSQLEngine) Init(ctx context.Context) error {
 	if ctx == nil {
 		return errors.New("ctx is missing")
 	}
-	s.ctx = ctx
-	s.DSN = os.Getenv("DB_DSN")
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	// 未预先指定时从环境变量读取
+	if s.DSN == "" {
+		s.DSN = os.Getenv("DB_DSN")
+	}
 	if s.DSN == "" {
 		return errors.New("DB_DSN is missing")
 	}
 	var err error
-	s.DB, err = PostgresDBInit(s.DSN)
+	s.conns = map[string]*gorm.DB{}
+	s.DB, err = s.open(s.DSN)
 	if err != nil {
 		return err
 	}
@@ -87,21 +158,33 @@ func (s *PGSQLEngine) DBCheck() {
 // DataDBInit 初始化
 func (s *PGSQLEngine) dataDBInit() (*gorm.DB, error) {
 	// data建表
+	conn, err := s.connFor("data", s.Config.DataDSN)
+	if err != nil {
+		return nil, err
+	}
 	dataContext := context.WithValue(s.ctx, cache.CacheKey, cache.DataDBCacheKey)
-	dataDB := s.DB.WithContext(dataContext)
+	dataDB := conn.WithContext(dataContext)
 	return dataDB, nil
 }
 
 func (s *PGSQLEngine) logDBInit() (*gorm.DB, error) {
 	// logs建表
+	conn, err := s.connFor("logs", s.Config.LogDSN)
+	if err != nil {
+		return nil, err
+	}
 	logsContext := context.WithValue(s.ctx, cache.CacheKey, cache.LogsDBCacheKey)
-	logDB := s.DB.WithContext(logsContext)
+	logDB := conn.WithContext(logsContext)
 	return logDB, nil
 }
 
 func (s *PGSQLEngine) censorDBInit() (*gorm.DB, error) {
+	conn, err := s.connFor("censor", s.Config.CensorDSN)
+	if err != nil {
+		return nil, err
+	}
 	censorContext := context.WithValue(s.ctx, cache.CacheKey, cache.CensorsDBCacheKey)
-	censorDB := s.DB.WithContext(censorContext)
+	censorDB := conn.WithContext(censorContext)
 	return censorDB, nil
 }
 func (s *PGSQLEngine) Type() string {
@@ -2,6 +2,7 @@ package sqlite
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"os"
@@ -14,11 +15,15 @@ import (
 	"sealdice-core/logger"
 	"sealdice-core/utils/cache"
 	"sealdice-core/utils/constant"
+	"sealdice-core/utils/dboperator/dbconfig"
 )
 
 type SQLiteEngine struct {
 	DataDir string
-	ctx     context.Context
+	// Config 各库文件路径、连接池与 PRAGMA 配置，数据目录以 DataDir 为准
+	Config dbconfig.Config
+	ctx    context.Context
+	cancel context.CancelFunc
 	// 用于控制readList和writeList的读写锁
 	mu        sync.RWMutex
 	readList  map[dbName]*gorm.DB
@@ -44,29 +49,52 @@ func (s *SQLiteEngine) Close() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// 关闭 readList 中的连接
-	for name, db := range s.readList {
-		sqlDB, err := db.DB()
-		if err != nil {
-			log.Errorf("failed to get sql.DB for %s: %v", name, err)
-			continue
-		}
-		if err = sqlDB.Close(); err != nil {
-			log.Errorf("failed to close db %s: %v", name, err)
+	var pools []*sql.DB
+	for _, list := range []map[dbName]*gorm.DB{s.readList, s.writeList} {
+		for name, db := range list {
+			sqlDB, err := db.DB()
+			if err != nil {
+				log.Errorf("failed to get sql.DB for %s: %v", name, err)
+				continue
+			}
+			pools = append(pools, sqlDB)
 		}
 	}
+	if len(pools) == 0 {
+		return
+	}
+	if err := dbconfig.CloseGracefully(pools, s.Config.CloseWait(), s.cancel); err != nil {
+		log.Errorf("failed to close db: %v", err)
+	}
+}
 
-	// 关闭 writeList 中的连接
-	for name, db := range s.writeList {
-		sqlDB, err := db.DB()
-		if err != nil {
-			log.Errorf("failed to get sql.DB for %s: %v", name, err)
-			continue
-		}
-		if err = sqlDB.Close(); err != nil {
-			log.Errorf("failed to close db %s: %v", name, err)
+// PoolStats 各库读写连接池的状态
+func (s *SQLiteEngine) PoolStats() []dbconfig.PoolStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var stats []dbconfig.PoolStats
+	for _, key := range []dbName{DataDBKey, LogsDBKey, CensorsDBKey} {
+		for _, item := range []struct {
+			mode constant.DBMode
+			db   *gorm.DB
+		}{{constant.READ, s.readList[key]}, {constant.WRITE, s.writeList[key]}} {
+			if item.db == nil {
+				continue
+			}
+			if sqlDB, err := item.db.DB(); err == nil {
+				stats = append(stats, dbconfig.NewPoolStats(string(key), string(item.mode), sqlDB))
+			}
 		}
 	}
+	return stats
+}
+
+// dbPath 库文件路径，未单独配置时位于数据目录下
+func (s *SQLiteEngine) dbPath(configured, filename string) (string, error) {
+	if configured != "" {
+		return filepath.Abs(configured)
+	}
+	return filepath.Abs(filepath.Join(s.DataDir, filename))
 }
 
 func (s *SQLiteEngine) getDBByModeAndKey(mode constant.DBMode, key dbName) *gorm.DB {
@@ -103,8 +131,11 @@ func (s *SQLiteEngine) Init(ctx context.Context) error {
 	if ctx == nil {
 		return errors.New("ctx is missing")
 	}
-	s.ctx = ctx
-	s.DataDir = os.Getenv("DATADIR")
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	// 未预先指定时从环境变量读取
+	if s.DataDir == "" {
+		s.DataDir = os.Getenv("DATADIR")
+	}
 	if s.DataDir == "" {
 		log.Debug("未能发现SQLITE定义位置，使用默认data地址")
 		s.DataDir = defaultDataDir
@@ -136,7 +167,6 @@ func (s *SQLiteEngine) Init(ctx context.Context) error {
 }
 
 func (s *SQLiteEngine) DBCheck() {
-	dataDir := s.DataDir
 	checkDB := func(db *gorm.DB) bool {
 		rows, err := db.Raw("PRAGMA integrity_check").Rows()
 		if err != nil {
@@ -168,7 +198,7 @@ func (s *SQLiteEngine) DBCheck() {
 	var censorDB *gorm.DB
 	var err error
 
-	dbDataPath, _ := filepath.Abs(filepath.Join(dataDir, "data.db"))
+	dbDataPath, _ := s.dbPath(s.Config.DataDSN, "data.db")
 	dataDB, err = SQLiteDBInit(dbDataPath, false)
 	if err != nil {
 		fmt.Fprintln(os.Stdout, "数据库 data.db 无法打开")
@@ -179,7 +209,7 @@ func (s *SQLiteEngine) DBCheck() {
 		db.Close()
 	}
 
-	dbDataLogsPath, _ := filepath.Abs(filepath.Join(dataDir, "data-logs.db"))
+	dbDataLogsPath, _ := s.dbPath(s.Config.LogDSN, "data-logs.db")
 	logsDB, err = SQLiteDBInit(dbDataLogsPath, false)
 	if err != nil {
 		fmt.Fprintln(os.Stdout, "数据库 data-logs.db 无法打开")
@@ -190,7 +220,7 @@ func (s *SQLiteEngine) DBCheck() {
 		db.Close()
 	}
 
-	dbDataCensorPath, _ := filepath.Abs(filepath.Join(dataDir, "data-censor.db"))
+	dbDataCensorPath, _ := s.dbPath(s.Config.CensorDSN, "data-censor.db")
 	censorDB, err = SQLiteDBInit(dbDataCensorPath, false)
 	if err != nil {
 		fmt.Fprintln(os.Stdout, "数据库 data-censor.db 无法打开")
@@ -209,8 +239,11 @@ func (s *SQLiteEngine) DBCheck() {
 
 // 初始化
 func (s *SQLiteEngine) dataDBInit() error {
-	dbDataPath, _ := filepath.Abs(filepath.Join(s.DataDir, "data.db"))
-	readDB, writeDB, err := SQLiteDBRWInit(dbDataPath)
+	dbDataPath, err := s.dbPath(s.Config.DataDSN, "data.db")
+	if err != nil {
+		return err
+	}
+	readDB, writeDB, err := SQLiteDBRWInit(dbDataPath, &s.Config)
 	if err != nil {
 		return err
 	}
@@ -224,8 +257,11 @@ func (s *SQLiteEngine) dataDBInit() error {
 }
 
 func (s *SQLiteEngine) LogDBInit() error {
-	dbDataLogsPath, _ := filepath.Abs(filepath.Join(s.DataDir, "data-logs.db"))
-	readDB, writeDB, err := SQLiteDBRWInit(dbDataLogsPath)
+	dbDataLogsPath, err := s.dbPath(s.Config.LogDSN, "data-logs.db")
+	if err != nil {
+		return err
+	}
+	readDB, writeDB, err := SQLiteDBRWInit(dbDataLogsPath, &s.Config)
 	if err != nil {
 		return err
 	}
@@ -239,11 +275,11 @@ func (s *SQLiteEngine) LogDBInit() error {
 }
 
 func (s *SQLiteEngine) CensorDBInit() error {
-	path, err := filepath.Abs(filepath.Join(s.DataDir, "data-censor.db"))
+	path, err := s.dbPath(s.Config.CensorDSN, "data-censor.db")
 	if err != nil {
 		return err
 	}
-	readDB, writeDB, err := SQLiteDBRWInit(path)
+	readDB, writeDB, err := SQLiteDBRWInit(path, &s.Config)
 	if err != nil {
 		return err
 	}
@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"fmt"
 	"runtime"
+	"strconv"
 
 	"github.com/glebarez/sqlite"
 
@@ -13,6 +14,7 @@ import (
 
 	"sealdice-core/logger"
 	"sealdice-core/utils/cache"
+	"sealdice-core/utils/dboperator/dbconfig"
 )
 
 // 警告：不要在一个事务（写事务）里使用读的DB！否则读的DB会发现有人在写而锁住，从而死锁。
@@ -40,7 +42,7 @@ func SQLiteDBInit(path string, useWAL bool) (*gorm.DB, error) {
 	return open, err
 }
 
-func createReadDB(path string, gormConf gorm.Config) (*gorm.DB, error) {
+func createReadDB(path string, gormConf gorm.Config, cfg *dbconfig.Config) (*gorm.DB, error) {
 	// _txlock=immediate 解决BEGIN IMMEDIATELY
 	path = fmt.Sprintf("file:%v?_txlock=immediate", path)
 	// ---- 创建读连接 -----
@@ -52,15 +54,16 @@ func createReadDB(path string, gormConf gorm.Config) (*gorm.DB, error) {
 	if err != nil {
 		return nil, err
 	}
-	err = SetDefaultPragmas(readPool)
+	err = SetDefaultPragmas(readPool, cfg.SQLite)
 	if err != nil {
 		return nil, err
 	}
 	readPool.SetMaxOpenConns(max(4, runtime.NumCPU()))
+	cfg.ApplyPool(readPool)
 	return readDB, nil
 }
 
-func createWriteDB(path string, gormConf gorm.Config) (*gorm.DB, error) {
+func createWriteDB(path string, gormConf gorm.Config, cfg *dbconfig.Config) (*gorm.DB, error) {
 	// 注意基于wasm的版本必须添加file:
 	path = fmt.Sprintf("file:%v?_txlock=immediate", path)
 	// ---- 创建写连接 -----
@@ -72,25 +75,29 @@ func createWriteDB(path string, gormConf gorm.Config) (*gorm.DB, error) {
 	if err != nil {
 		return nil, err
 	}
-	err = SetDefaultPragmas(writePool)
+	err = SetDefaultPragmas(writePool, cfg.SQLite)
 	if err != nil {
 		return nil, err
 	}
+	// 连接数不可配置，只应用连接的生命周期
+	writeCfg := *cfg
+	writeCfg.MaxOpenConns, writeCfg.MaxIdleConns = 0, 0
+	writeCfg.ApplyPool(writePool)
 	writePool.SetMaxOpenConns(1) // only use one active connection for writing
 	return writeDB, nil
 }
 
-func SQLiteDBRWInit(path string) (*gorm.DB, *gorm.DB, error) {
+func SQLiteDBRWInit(path string, cfg *dbconfig.Config) (*gorm.DB, *gorm.DB, error) {
 	// 由于现在我们只有一个写入连接，所以不需要使用事务
 	gormConf := gorm.Config{
 		Logger:                 logger.DefaultSealLogger,
 		SkipDefaultTransaction: true,
 	}
-	readDB, err := createReadDB(path, gormConf)
+	readDB, err := createReadDB(path, gormConf, cfg)
 	if err != nil {
 		return nil, nil, err
 	}
-	writeDB, err := createWriteDB(path, gormConf)
+	writeDB, err := createWriteDB(path, gormConf, cfg)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -115,16 +122,20 @@ func SQLiteDBRWInit(path string) (*gorm.DB, *gorm.DB, error) {
 // https://litestream.io/tips/
 // copied from https://github.com/bihe/monorepo
 // add PRAGMA optimize=0x10002; from https://github.com/Palats/mastopoof
-func SetDefaultPragmas(db *sql.DB) error {
+func SetDefaultPragmas(db *sql.DB, opts dbconfig.SQLiteConfig) error {
 	var (
 		stmt string
 		val  string
 	)
+	journalMode := "wal"
+	if !opts.UseWAL() {
+		journalMode = "delete"
+	}
 	// 外键的暂时弃用，反正咱也不用外键536870912
 	// "foreign_keys": "1",     // 1(bool) --> https://www.sqlite.org/pragma.html#pragma_foreign_keys
 	defaultPragmas := map[string]string{
-		"journal_mode": "wal",   // https://www.sqlite.org/pragma.html#pragma_journal_mode
-		"busy_timeout": "15000", // https://www.sqlite.org/pragma.html#pragma_busy_timeout
+		"journal_mode": journalMode,                        // https://www.sqlite.org/pragma.html#pragma_journal_mode
+		"busy_timeout": strconv.Itoa(opts.BusyTimeoutMS()), // https://www.sqlite.org/pragma.html#pragma_busy_timeout
 		// 在 WAL 模式下使用 synchronous=NORMAL 提交的事务可能会在断电或系统崩溃后回滚。
 		// 无论同步设置或日志模式如何，事务在应用程序崩溃时都是持久的。
 		// 对于在 WAL 模式下运行的大多数应用程序来说，synchronous=NORMAL 设置是一个不错的选择。
@@ -55,6 +55,9 @@ var WebSocketLogger Logger = &defaultLogger{}
 // GlobalConnManager 是一个全局的WebSocket管理器 用来最后优雅销毁的
 var GlobalConnManager = &WebSocketManager{}
 
+// OwnerResolver 在创建连接时确定其所属者（如脚本文件），用于按所属者关闭连接；为 nil 时不记录
+var OwnerResolver func(rt *goja.Runtime) string
+
 // SetLogger 设置全局日志实例
 func SetLogger(logger Logger) {
 	WebSocketLogger = logger
@@ -99,6 +102,28 @@ func (m *WebSocketManager) CloseAll() {
 	}
 }
 
+// CloseByOwner 关闭属于 owner 的连接，返回关闭的数量
+func (m *WebSocketManager) CloseByOwner(owner string) int {
+	m.mutex.Lock()
+	var matched []*WebSocketConnection
+	kept := m.connections[:0]
+	for _, conn := range m.connections {
+		if conn != nil && conn.owner == owner {
+			matched = append(matched, conn)
+		} else {
+			kept = append(kept, conn)
+		}
+	}
+	m.connections = kept
+	m.mutex.Unlock()
+
+	// 在锁外关闭连接，避免死锁
+	for _, conn := range matched {
+		conn.closeWithoutUnregister()
+	}
+	return len(matched)
+}
+
 // Unregister 移除已关闭的连接
 func (m *WebSocketManager) Unregister(conn *WebSocketConnection) {
 	m.mutex.Lock()
@@ -167,6 +192,7 @@ type WebSocketConnection struct {
 	done         chan struct{}
 	shutdownOnce sync.Once
 	jsObject     *goja.Object
+	owner        string
 
 	// WebSocket API 属性
 	url        string
@@ -260,6 +286,9 @@ func (ws *WebSocket) NewWebSocketConnection(call goja.FunctionCall) goja.Value {
 		onerror:        goja.Undefined(),
 		eventListeners: make(map[string][]goja.Value),
 	}
+	if OwnerResolver != nil {
+		conn.owner = OwnerResolver(rt)
+	}
 	// 注册到全局管理器
 	GlobalConnManager.Register(conn)
 
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrAddrNotPublic 连接的地址不是公网地址
+var ErrAddrNotPublic = errors.New("不允许访问的地址")
+
+// cgnatPrefix 运营商级 NAT 地址段，与内网地址一样不允许访问
+var cgnatPrefix = netip.MustParsePrefix("100.64.0.0/10")
+
+// PublicDialControl 用作 net.Dialer 的 Control，拒绝连接回环、内网、链路本地等非公网地址。
+// 在拨号时检查，域名解析与重定向得到的地址同样受限
+func PublicDialControl(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || cgnatPrefix.Contains(ip) {
+		return ErrAddrNotPublic
+	}
+	return nil
+}
+
+// NewPublicHTTPClient 只连接公网地址的 http.Client，用于下载由用户或聊天平台给出的网址。
+// 不使用代理，免得绕过地址检查
+func NewPublicHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{Timeout: 10 * time.Second, Control: PublicDialControl}).DialContext,
+		},
+	}
+}
@@ -0,0 +1,18 @@
+//nolint:testpackage
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPublicDialControl(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:80", "10.0.0.1:80", "169.254.169.254:80", "[::1]:80", "[::ffff:192.168.1.1]:80", "100.64.0.1:80"} {
+		if err := PublicDialControl("tcp", addr, nil); !errors.Is(err, ErrAddrNotPublic) {
+			t.Errorf("dial %s error = %v, want ErrAddrNotPublic", addr, err)
+		}
+	}
+	if err := PublicDialControl("tcp", "1.1.1.1:443", nil); err != nil {
+		t.Errorf("dial public address: %v", err)
+	}
+}
@@ -4,6 +4,7 @@ package upgrade
 import (
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 
 	"sealdice-core/utils/dboperator/engine"
@@ -13,6 +14,8 @@ type Manager struct {
 	Upgrades []Upgrade
 	Store    Store
 	Database engine.DatabaseOperator
+	// BeforeChange 在执行升级或回滚之前调用，参数为即将执行或撤销的升级，一般用于备份；返回错误时中止
+	BeforeChange func(upgrades []Upgrade) error
 }
 
 func (m *Manager) Register(up Upgrade) {
@@ -20,19 +23,17 @@ func (m *Manager) Register(up Upgrade) {
 }
 
 func (m *Manager) ApplyAll() error {
-	sort.Slice(m.Upgrades, func(i, j int) bool {
-		return m.Upgrades[i].ID < m.Upgrades[j].ID
-	})
-
-	for _, up := range m.Upgrades {
-		applied, err := m.Store.IsApplied(up.ID)
-		if err != nil {
-			return err
-		}
-		if applied {
-			continue
+	pending, err := m.Pending()
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 && m.BeforeChange != nil {
+		if err = m.BeforeChange(pending); err != nil {
+			return fmt.Errorf("升级前准备失败: %w", err)
 		}
+	}
 
+	for _, up := range pending {
 		logs := []string{}
 		logf := func(msg string) {
 			logs = append(logs, msg)
@@ -62,3 +63,164 @@ func (m *Manager) ApplyAll() error {
 	}
 	return nil
 }
+
+// MarkApplied 将尚未执行的升级记为已执行而不调用 Apply，用于数据已由其他途径处于最新状态的场合（如跨数据库迁移）
+func (m *Manager) MarkApplied(message string) ([]string, error) {
+	var marked []string
+	for _, up := range m.Upgrades {
+		applied, err := m.Store.IsApplied(up.ID)
+		if err != nil {
+			return marked, err
+		}
+		if applied {
+			continue
+		}
+		err = m.Store.SaveRecord(UpgradeRecord{
+			ID:        up.ID,
+			Timestamp: time.Now(),
+			Success:   true,
+			Message:   message,
+		})
+		if err != nil {
+			return marked, err
+		}
+		marked = append(marked, up.ID)
+	}
+	return marked, nil
+}
+
+// Status 一项升级的执行状态
+type Status struct {
+	ID          string         `json:"id"`
+	Description string         `json:"description"`
+	Applied     bool           `json:"applied"`
+	Reversible  bool           `json:"reversible"`
+	Record      *UpgradeRecord `json:"record,omitempty"`
+}
+
+// Status 列出全部已注册的升级及其执行状态，按 ID 排序
+func (m *Manager) Status() ([]Status, error) {
+	m.sort()
+	records, err := m.Store.LoadRecords()
+	if err != nil {
+		return nil, err
+	}
+	byID := map[string]*UpgradeRecord{}
+	for i := range records {
+		byID[records[i].ID] = &records[i]
+	}
+	list := make([]Status, 0, len(m.Upgrades))
+	for _, up := range m.Upgrades {
+		rec := byID[up.ID]
+		list = append(list, Status{
+			ID:          up.ID,
+			Description: strings.TrimSpace(up.Description),
+			Applied:     rec != nil,
+			Reversible:  up.Reversible(),
+			Record:      rec,
+		})
+	}
+	return list, nil
+}
+
+// Pending 尚未执行的升级，按 ID 排序
+func (m *Manager) Pending() ([]Upgrade, error) {
+	m.sort()
+	var pending []Upgrade
+	for _, up := range m.Upgrades {
+		applied, err := m.Store.IsApplied(up.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !applied {
+			pending = append(pending, up)
+		}
+	}
+	return pending, nil
+}
+
+// PlanResult 一项待执行升级的预览
+type PlanResult struct {
+	ID   string `json:"id"`
+	Plan *Plan  `json:"plan"`
+}
+
+// DryRun 预览全部待执行升级会产生的改动，不修改数据库、配置与升级记录。
+// 未提供 Plan 的升级只给出提示，无法预估其影响。
+func (m *Manager) DryRun() ([]PlanResult, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]PlanResult, 0, len(pending))
+	for _, up := range pending {
+		if up.Plan == nil {
+			results = append(results, PlanResult{ID: up.ID, Plan: &Plan{Notes: []string{"未提供预览，无法预估改动"}}})
+			continue
+		}
+		plan, err := up.Plan(m.Database)
+		if err != nil {
+			return results, fmt.Errorf("预览升级 %s 失败: %w", up.ID, err)
+		}
+		if plan == nil {
+			plan = &Plan{}
+		}
+		results = append(results, PlanResult{ID: up.ID, Plan: plan})
+	}
+	return results, nil
+}
+
+// Rollback 按执行的逆序撤销 id 及其之后的全部已执行升级，并删除对应的升级记录。
+// 只要其中任意一项没有提供 Down 就不做任何修改，此时只能从升级前的备份恢复。
+func (m *Manager) Rollback(id string, logf func(string)) ([]string, error) {
+	m.sort()
+	found := false
+	var targets []Upgrade
+	for _, up := range m.Upgrades {
+		if up.ID == id {
+			found = true
+		}
+		if up.ID < id {
+			continue
+		}
+		applied, err := m.Store.IsApplied(up.ID)
+		if err != nil {
+			return nil, err
+		}
+		if applied {
+			targets = append(targets, up)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("升级 %s 不存在", id)
+	}
+	for _, up := range targets {
+		if !up.Reversible() {
+			return nil, fmt.Errorf("升级 %s 不支持回滚，请从升级前的备份恢复", up.ID)
+		}
+	}
+	if len(targets) > 0 && m.BeforeChange != nil {
+		if err := m.BeforeChange(targets); err != nil {
+			return nil, fmt.Errorf("回滚前准备失败: %w", err)
+		}
+	}
+
+	var reverted []string
+	for i := len(targets) - 1; i >= 0; i-- {
+		up := targets[i]
+		if err := up.Down(logf, m.Database); err != nil {
+			return reverted, fmt.Errorf("回滚升级 %s 失败: %w", up.ID, err)
+		}
+		if err := m.Store.RemoveRecord(up.ID); err != nil {
+			return reverted, fmt.Errorf("删除升级记录失败: %w", err)
+		}
+		reverted = append(reverted, up.ID)
+	}
+	return reverted, nil
+}
+
+func (m *Manager) sort() {
+	sort.Slice(m.Upgrades, func(i, j int) bool {
+		return m.Upgrades[i].ID < m.Upgrades[j].ID
+	})
+}
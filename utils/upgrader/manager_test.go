@@ -0,0 +1,157 @@
+package upgrade_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sealdice-core/utils/dboperator/engine"
+	upgrade "sealdice-core/utils/upgrader"
+	"sealdice-core/utils/upgrader/store"
+)
+
+type fakeState struct {
+	applied []string
+}
+
+func (s *fakeState) upgrade(id string, reversible bool) upgrade.Upgrade {
+	up := upgrade.Upgrade{
+		ID: id,
+		Apply: func(_ func(string), _ engine.DatabaseOperator) error {
+			s.applied = append(s.applied, id)
+			return nil
+		},
+		Plan: func(_ engine.DatabaseOperator) (*upgrade.Plan, error) {
+			return &upgrade.Plan{Tables: []string{id}}, nil
+		},
+	}
+	if reversible {
+		up.Down = func(_ func(string), _ engine.DatabaseOperator) error {
+			if n := len(s.applied); n == 0 || s.applied[n-1] != id {
+				return errors.New("rollback out of order")
+			}
+			s.applied = s.applied[:len(s.applied)-1]
+			return nil
+		}
+	}
+	return up
+}
+
+func newTestManager(t *testing.T, s *fakeState, reversible ...bool) *upgrade.Manager {
+	t.Helper()
+	mgr := &upgrade.Manager{Store: store.NewJSONStore(filepath.Join(t.TempDir(), "upgrade_metadata.json"))}
+	ids := []string{"003_c", "001_a", "002_b"}
+	for i, id := range ids {
+		mgr.Register(s.upgrade(id, reversible[i]))
+	}
+	return mgr
+}
+
+func TestManagerDryRunAndBeforeChange(t *testing.T) {
+	s := &fakeState{}
+	mgr := newTestManager(t, s, true, true, true)
+
+	plans, err := mgr.DryRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plans) != 3 || plans[0].ID != "001_a" || plans[0].Plan.Tables[0] != "001_a" {
+		t.Fatalf("unexpected plans: %+v", plans)
+	}
+	if len(s.applied) != 0 {
+		t.Fatalf("dry run applied upgrades: %v", s.applied)
+	}
+
+	var hooked []string
+	mgr.BeforeChange = func(pending []upgrade.Upgrade) error {
+		for _, up := range pending {
+			hooked = append(hooked, up.ID)
+		}
+		return nil
+	}
+	if err = mgr.ApplyAll(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"001_a", "002_b", "003_c"}
+	if !reflect.DeepEqual(hooked, want) || !reflect.DeepEqual(s.applied, want) {
+		t.Fatalf("hooked %v applied %v", hooked, s.applied)
+	}
+
+	hooked = nil
+	if err = mgr.ApplyAll(); err != nil {
+		t.Fatal(err)
+	}
+	if hooked != nil {
+		t.Fatalf("BeforeChange called without pending upgrades")
+	}
+	if plans, _ = mgr.DryRun(); len(plans) != 0 {
+		t.Fatalf("nothing should be pending: %+v", plans)
+	}
+}
+
+func TestManagerBeforeChangeAborts(t *testing.T) {
+	s := &fakeState{}
+	mgr := newTestManager(t, s, true, true, true)
+	mgr.BeforeChange = func([]upgrade.Upgrade) error { return errors.New("disk full") }
+	if err := mgr.ApplyAll(); err == nil {
+		t.Fatal("ApplyAll should fail when BeforeChange fails")
+	}
+	if len(s.applied) != 0 {
+		t.Fatalf("upgrades applied after failed BeforeChange: %v", s.applied)
+	}
+}
+
+func TestManagerRollback(t *testing.T) {
+	s := &fakeState{}
+	// 003_c 不可回滚
+	mgr := newTestManager(t, s, false, true, true)
+	if err := mgr.ApplyAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mgr.Rollback("002_b", func(string) {}); err == nil {
+		t.Fatal("rollback across an irreversible upgrade should fail")
+	}
+	if len(s.applied) != 3 {
+		t.Fatalf("failed rollback changed state: %v", s.applied)
+	}
+	if _, err := mgr.Rollback("009_x", func(string) {}); err == nil {
+		t.Fatal("rollback of unknown id should fail")
+	}
+
+	s = &fakeState{}
+	mgr = newTestManager(t, s, true, true, true)
+	if err := mgr.ApplyAll(); err != nil {
+		t.Fatal(err)
+	}
+	reverted, err := mgr.Rollback("002_b", func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reverted, []string{"003_c", "002_b"}) {
+		t.Fatalf("reverted %v", reverted)
+	}
+	status, err := mgr.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied := map[string]bool{}
+	for _, st := range status {
+		applied[st.ID] = st.Applied
+		if !st.Reversible {
+			t.Errorf("%s should be reversible", st.ID)
+		}
+	}
+	if !applied["001_a"] || applied["002_b"] || applied["003_c"] {
+		t.Fatalf("unexpected status after rollback: %v", applied)
+	}
+
+	// 回滚后可以重新执行
+	if err = mgr.ApplyAll(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.applied, []string{"001_a", "002_b", "003_c"}) {
+		t.Fatalf("re-apply %v", s.applied)
+	}
+}
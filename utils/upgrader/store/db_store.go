@@ -0,0 +1,140 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+
+	upgrade "sealdice-core/utils/upgrader"
+)
+
+// DBStore 将升级记录保存在数据库自身的 upgrade_metadata 表中，记录随数据库一起迁移。
+// 表只在第一次写入时创建，未创建时视为没有记录。
+type DBStore struct {
+	DB *gorm.DB
+}
+
+type dbRecord struct {
+	ID        string    `gorm:"column:id;primaryKey"`
+	Timestamp time.Time `gorm:"column:timestamp"`
+	Success   bool      `gorm:"column:success"`
+	Message   string    `gorm:"column:message"`
+	Logs      string    `gorm:"column:logs"`
+}
+
+func (dbRecord) TableName() string {
+	return "upgrade_metadata"
+}
+
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{DB: db}
+}
+
+func (ds *DBStore) exists() bool {
+	return ds.DB.Migrator().HasTable(&dbRecord{})
+}
+
+func (ds *DBStore) IsApplied(id string) (bool, error) {
+	if !ds.exists() {
+		return false, nil
+	}
+	var count int64
+	err := ds.DB.Model(&dbRecord{}).Where("id = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+func (ds *DBStore) SaveRecord(rec upgrade.UpgradeRecord) error {
+	if err := ds.DB.AutoMigrate(&dbRecord{}); err != nil {
+		return err
+	}
+	logs, err := json.Marshal(rec.Logs)
+	if err != nil {
+		return err
+	}
+	return ds.DB.Save(&dbRecord{
+		ID:        rec.ID,
+		Timestamp: rec.Timestamp,
+		Success:   rec.Success,
+		Message:   rec.Message,
+		Logs:      string(logs),
+	}).Error
+}
+
+func (ds *DBStore) LoadRecords() ([]upgrade.UpgradeRecord, error) {
+	if !ds.exists() {
+		return []upgrade.UpgradeRecord{}, nil
+	}
+	var rows []dbRecord
+	if err := ds.DB.Order("timestamp").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	records := make([]upgrade.UpgradeRecord, 0, len(rows))
+	for _, row := range rows {
+		rec := upgrade.UpgradeRecord{
+			ID:        row.ID,
+			Timestamp: row.Timestamp,
+			Success:   row.Success,
+			Message:   row.Message,
+		}
+		_ = json.Unmarshal([]byte(row.Logs), &rec.Logs)
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+func (ds *DBStore) RemoveRecord(id string) error {
+	if !ds.exists() {
+		return nil
+	}
+	return ds.DB.Where("id = ?", id).Delete(&dbRecord{}).Error
+}
+
+// LayeredStore 新记录只写入 Local（工作目录中的记录文件）；
+// Database 中已有的记录（如跨数据库迁移时写入目标库的标记）同样视为已执行
+type LayeredStore struct {
+	Local    upgrade.Store
+	Database upgrade.Store
+}
+
+func (ls *LayeredStore) IsApplied(id string) (bool, error) {
+	applied, err := ls.Local.IsApplied(id)
+	if err != nil || applied {
+		return applied, err
+	}
+	return ls.Database.IsApplied(id)
+}
+
+func (ls *LayeredStore) SaveRecord(rec upgrade.UpgradeRecord) error {
+	return ls.Local.SaveRecord(rec)
+}
+
+func (ls *LayeredStore) LoadRecords() ([]upgrade.UpgradeRecord, error) {
+	records, err := ls.Local.LoadRecords()
+	if err != nil {
+		return nil, err
+	}
+	dbRecords, err := ls.Database.LoadRecords()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]struct{}, len(records))
+	merged := append([]upgrade.UpgradeRecord(nil), records...)
+	for _, rec := range records {
+		seen[rec.ID] = struct{}{}
+	}
+	for _, rec := range dbRecords {
+		if _, ok := seen[rec.ID]; !ok {
+			merged = append(merged, rec)
+		}
+	}
+	return merged, nil
+}
+
+// RemoveRecord 回滚后两处的记录都需要删除，否则升级不会再次执行
+func (ls *LayeredStore) RemoveRecord(id string) error {
+	if err := ls.Local.RemoveRecord(id); err != nil {
+		return err
+	}
+	return ls.Database.RemoveRecord(id)
+}
@@ -0,0 +1,52 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sealdice-core/utils/constant"
+	"sealdice-core/utils/dboperator"
+	upgrade "sealdice-core/utils/upgrader"
+	"sealdice-core/utils/upgrader/store"
+)
+
+func TestLayeredStoreReadsDatabaseRecords(t *testing.T) {
+	op, err := dboperator.OpenEngine(context.Background(), constant.SQLITE, t.TempDir())
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(op.Close)
+	dbStore := store.NewDBStore(op.GetDataDB(constant.WRITE))
+	if applied, err := dbStore.IsApplied("v1"); err != nil || applied {
+		t.Fatalf("empty database: applied=%v err=%v", applied, err)
+	}
+
+	// 模拟跨数据库迁移写入目标库的标记
+	if err = dbStore.SaveRecord(upgrade.UpgradeRecord{ID: "v1", Timestamp: time.Now(), Success: true, Logs: []string{"copied"}}); err != nil {
+		t.Fatal(err)
+	}
+	localPath := filepath.Join(t.TempDir(), "upgrade_metadata.json")
+	layered := &store.LayeredStore{Local: store.NewJSONStore(localPath), Database: dbStore}
+	if applied, _ := layered.IsApplied("v1"); !applied {
+		t.Fatal("record in the database should count as applied")
+	}
+	if err = layered.SaveRecord(upgrade.UpgradeRecord{ID: "v2", Timestamp: time.Now(), Success: true}); err != nil {
+		t.Fatal(err)
+	}
+	if applied, _ := dbStore.IsApplied("v2"); applied {
+		t.Fatal("new records should only be written to the local store")
+	}
+	records, err := layered.LoadRecords()
+	if err != nil || len(records) != 2 {
+		t.Fatalf("records = %+v, err = %v", records, err)
+	}
+
+	if err = layered.RemoveRecord("v1"); err != nil {
+		t.Fatal(err)
+	}
+	if applied, _ := layered.IsApplied("v1"); applied {
+		t.Fatal("removed record should no longer count as applied")
+	}
+}
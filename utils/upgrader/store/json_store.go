@@ -86,3 +86,17 @@ func (js *JSONStore) LoadRecords() ([]upgrade.UpgradeRecord, error) {
 	}
 	return js.data, nil
 }
+
+func (js *JSONStore) RemoveRecord(id string) error {
+	if err := js.load(); err != nil {
+		return err
+	}
+	kept := js.data[:0]
+	for _, rec := range js.data {
+		if rec.ID != id {
+			kept = append(kept, rec)
+		}
+	}
+	js.data = kept
+	return js.save()
+}
@@ -12,6 +12,28 @@ type Upgrade struct {
 	// TODO: 有更好的想法吗，需要啥就从这里传是不是太抽象了
 	// 或许可以在这放一个logger，这个logger会在使用时注入，这样会好看些
 	Apply func(logf func(string), operator engine.DatabaseOperator) error
+	// Down 撤销 Apply 的改动，可选；为 nil 时该升级无法回滚，只能从升级前的备份恢复
+	Down func(logf func(string), operator engine.DatabaseOperator) error
+	// Plan 在不做任何修改的前提下预估 Apply 的影响，可选，供 DryRun 使用
+	Plan func(operator engine.DatabaseOperator) (*Plan, error)
+}
+
+// Reversible 是否提供了回滚步骤
+func (up *Upgrade) Reversible() bool {
+	return up.Down != nil
+}
+
+// Plan 一次升级预计产生的改动
+type Plan struct {
+	Tables     []string `json:"tables"`     // 将被修改结构、建立索引或写入数据的表
+	Rows       int64    `json:"rows"`       // 预计写入或删除的行数
+	ConfigKeys []string `json:"configKeys"` // 将被改写的配置项
+	Notes      []string `json:"notes"`
+}
+
+// Empty 预计不产生任何改动
+func (p *Plan) Empty() bool {
+	return len(p.Tables) == 0 && p.Rows == 0 && len(p.ConfigKeys) == 0
 }
 
 type UpgradeRecord struct {
@@ -26,4 +48,6 @@ type Store interface {
 	IsApplied(id string) (bool, error)
 	SaveRecord(record UpgradeRecord) error
 	LoadRecords() ([]UpgradeRecord, error)
+	// RemoveRecord 删除升级记录，用于回滚后允许再次执行
+	RemoveRecord(id string) error
 }